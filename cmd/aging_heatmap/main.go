@@ -0,0 +1,12 @@
+package main
+
+import (
+	"os"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/commands/aging"
+)
+
+func main() {
+	cli.RunCommand(cli.Command{Name: "aging", Main: aging.Main}, os.Args[1:])
+}
@@ -0,0 +1,12 @@
+package main
+
+import (
+	"os"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/commands/burndown"
+)
+
+func main() {
+	cli.RunCommand(cli.Command{Name: "burndown", Main: burndown.Main}, os.Args[1:])
+}
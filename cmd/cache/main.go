@@ -0,0 +1,12 @@
+package main
+
+import (
+	"os"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/commands/cache"
+)
+
+func main() {
+	cli.RunCommand(cli.Command{Name: "cache", Main: cache.Main}, os.Args[1:])
+}
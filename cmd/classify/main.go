@@ -0,0 +1,12 @@
+package main
+
+import (
+	"os"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/commands/classify"
+)
+
+func main() {
+	cli.RunCommand(cli.Command{Name: "classify", Main: classify.Main}, os.Args[1:])
+}
@@ -0,0 +1,12 @@
+package main
+
+import (
+	"os"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/commands/criticalpath"
+)
+
+func main() {
+	cli.RunCommand(cli.Command{Name: "critical-path", Main: criticalpath.Main}, os.Args[1:])
+}
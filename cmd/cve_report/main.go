@@ -0,0 +1,12 @@
+package main
+
+import (
+	"os"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/commands/cve"
+)
+
+func main() {
+	cli.RunCommand(cli.Command{Name: "cve", Main: cve.Main}, os.Args[1:])
+}
@@ -0,0 +1,12 @@
+package main
+
+import (
+	"os"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/commands/denied"
+)
+
+func main() {
+	cli.RunCommand(cli.Command{Name: "denied", Main: denied.Main}, os.Args[1:])
+}
@@ -0,0 +1,12 @@
+package main
+
+import (
+	"os"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/commands/estimates"
+)
+
+func main() {
+	cli.RunCommand(cli.Command{Name: "estimates", Main: estimates.Main}, os.Args[1:])
+}
@@ -0,0 +1,12 @@
+package main
+
+import (
+	"os"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/commands/export"
+)
+
+func main() {
+	cli.RunCommand(cli.Command{Name: "export", Main: export.Main}, os.Args[1:])
+}
@@ -0,0 +1,12 @@
+package main
+
+import (
+	"os"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/commands/fields"
+)
+
+func main() {
+	cli.RunCommand(cli.Command{Name: "fields", Main: fields.Main}, os.Args[1:])
+}
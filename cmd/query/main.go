@@ -0,0 +1,12 @@
+package main
+
+import (
+	"os"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/commands/list"
+)
+
+func main() {
+	cli.RunCommand(cli.Command{Name: "list", Main: list.Main}, os.Args[1:])
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"os"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/commands/aging"
+	"github.com/jctanner/rhoai-jira/internal/commands/apiload"
+	"github.com/jctanner/rhoai-jira/internal/commands/boards"
+	"github.com/jctanner/rhoai-jira/internal/commands/burndown"
+	"github.com/jctanner/rhoai-jira/internal/commands/cache"
+	"github.com/jctanner/rhoai-jira/internal/commands/capacity"
+	"github.com/jctanner/rhoai-jira/internal/commands/classify"
+	"github.com/jctanner/rhoai-jira/internal/commands/criticalpath"
+	"github.com/jctanner/rhoai-jira/internal/commands/cve"
+	"github.com/jctanner/rhoai-jira/internal/commands/denied"
+	"github.com/jctanner/rhoai-jira/internal/commands/edits"
+	"github.com/jctanner/rhoai-jira/internal/commands/epics"
+	"github.com/jctanner/rhoai-jira/internal/commands/escalations"
+	"github.com/jctanner/rhoai-jira/internal/commands/estimates"
+	"github.com/jctanner/rhoai-jira/internal/commands/export"
+	"github.com/jctanner/rhoai-jira/internal/commands/fetch"
+	"github.com/jctanner/rhoai-jira/internal/commands/fields"
+	"github.com/jctanner/rhoai-jira/internal/commands/forecast"
+	"github.com/jctanner/rhoai-jira/internal/commands/handoffs"
+	"github.com/jctanner/rhoai-jira/internal/commands/links"
+	"github.com/jctanner/rhoai-jira/internal/commands/list"
+	"github.com/jctanner/rhoai-jira/internal/commands/live"
+	"github.com/jctanner/rhoai-jira/internal/commands/plan"
+	"github.com/jctanner/rhoai-jira/internal/commands/prs"
+	"github.com/jctanner/rhoai-jira/internal/commands/quality"
+	"github.com/jctanner/rhoai-jira/internal/commands/releases"
+	"github.com/jctanner/rhoai-jira/internal/commands/rollforward"
+	"github.com/jctanner/rhoai-jira/internal/commands/rpc"
+	"github.com/jctanner/rhoai-jira/internal/commands/run"
+	"github.com/jctanner/rhoai-jira/internal/commands/seasonality"
+	"github.com/jctanner/rhoai-jira/internal/commands/server"
+	"github.com/jctanner/rhoai-jira/internal/commands/snapshot"
+	"github.com/jctanner/rhoai-jira/internal/commands/sprintreport"
+	"github.com/jctanner/rhoai-jira/internal/commands/sprints"
+	"github.com/jctanner/rhoai-jira/internal/commands/stakeholders"
+	"github.com/jctanner/rhoai-jira/internal/commands/stale"
+	"github.com/jctanner/rhoai-jira/internal/commands/stats"
+	"github.com/jctanner/rhoai-jira/internal/commands/taxonomy"
+	"github.com/jctanner/rhoai-jira/internal/commands/timeinstatus"
+	"github.com/jctanner/rhoai-jira/internal/commands/track"
+	"github.com/jctanner/rhoai-jira/internal/commands/trends"
+	"github.com/jctanner/rhoai-jira/internal/commands/volatility"
+	"github.com/jctanner/rhoai-jira/internal/commands/workload"
+	"github.com/jctanner/rhoai-jira/internal/commands/worklogs"
+)
+
+func commands() *cli.Commands {
+	c := &cli.Commands{Program: "rhoai-jira"}
+	c.Register(cli.Command{Name: "fetch", Summary: "sync issues and changelogs from Jira into the cache", Main: fetch.Main})
+	c.Register(cli.Command{Name: "sprints", Summary: "list the cached issues in a sprint", Main: sprints.Main})
+	c.Register(cli.Command{Name: "track", Summary: "sprint membership, effort and status over time", Main: track.Main})
+	c.Register(cli.Command{Name: "list", Aliases: []string{"query"}, Summary: "list cached issues matching a JQL-lite query, or boards/versions/components/statuses/sprints", Main: list.Main})
+	c.Register(cli.Command{Name: "burndown", Summary: "daily remaining effort for a sprint", Main: burndown.Main})
+	c.Register(cli.Command{Name: "sprint-report", Summary: "issues committed, added, removed, completed and spilled over in a sprint", Main: sprintreport.Main})
+	c.Register(cli.Command{Name: "aging", Summary: "open issue age by priority heatmap", Main: aging.Main})
+	c.Register(cli.Command{Name: "seasonality", Summary: "created/resolved counts by weekday and hour", Main: seasonality.Main})
+	c.Register(cli.Command{Name: "estimates", Summary: "estimated vs logged time", Main: estimates.Main})
+	c.Register(cli.Command{Name: "critical-path", Summary: "longest blocker chain of an epic or release", Main: criticalpath.Main})
+	c.Register(cli.Command{Name: "fields", Summary: "group issues by any configured field (fields discover maps names to ids)", Main: fields.Main})
+	c.Register(cli.Command{Name: "classify", Summary: "apply classification rules to cached issues", Main: classify.Main})
+	c.Register(cli.Command{Name: "cve", Summary: "CVE issues against their SLA", Main: cve.Main})
+	c.Register(cli.Command{Name: "denied", Summary: "coverage of issues the token cannot read", Main: denied.Main})
+	c.Register(cli.Command{Name: "show-edits", Summary: "diff summary and description edits of an issue", Main: edits.Main})
+	c.Register(cli.Command{Name: "rollforward", Summary: "reconstruct issue snapshots at a point in time", Main: rollforward.Main})
+	c.Register(cli.Command{Name: "cache", Summary: "cache maintenance (manifests, sprint or epic extracts, compaction)", Main: cache.Main})
+	c.Register(cli.Command{Name: "run", Summary: "run a pipeline of syncs and reports and publish the outputs", Main: run.Main})
+	c.Register(cli.Command{Name: "stats", Summary: "field distributions, null rates and top values across the cache", Main: stats.Main})
+	c.Register(cli.Command{Name: "live", Summary: "continuously refreshing sprint room view for a wallboard", Main: live.Main})
+	c.Register(cli.Command{Name: "taxonomy", Summary: "audit labels and components for duplicates and unused values", Main: taxonomy.Main})
+	c.Register(cli.Command{Name: "workload", Summary: "open issues and effort per assignee over time, and reassignment churn in a sprint", Main: workload.Main})
+	c.Register(cli.Command{Name: "handoffs", Summary: "assignee handoff chains, excessive handoffs and common handoff pairs", Main: handoffs.Main})
+	c.Register(cli.Command{Name: "export", Summary: "stream the cache as NDJSON, JSON or Parquet: flattened issues or changelog events", Main: export.Main})
+	c.Register(cli.Command{Name: "server", Summary: "HTML dashboard of sprints, burndowns, assignee load and search over the cache", Main: server.Main})
+	c.Register(cli.Command{Name: "links", Summary: "graph of issue links, epics and parents as DOT, GraphML or JSON", Main: links.Main})
+	c.Register(cli.Command{Name: "api-load", Summary: "requests made to Jira per hour or day, endpoint and project, from the audit log", Main: apiload.Main})
+	c.Register(cli.Command{Name: "boards", Summary: "board column configurations (boards fetch reads them from the Agile API)", Main: boards.Main})
+	c.Register(cli.Command{Name: "escalations", Summary: "stale blockers of active sprint work, most urgent first", Main: escalations.Main})
+	c.Register(cli.Command{Name: "plan", Summary: "simulate a candidate sprint scope against past velocity: completion odds and cut lines", Main: plan.Main})
+	c.Register(cli.Command{Name: "epics", Summary: "progress of every epic: children done, effort, statuses and activity", Main: epics.Main})
+	c.Register(cli.Command{Name: "trends", Summary: "issues created, resolved and open per label or component each week", Main: trends.Main})
+	c.Register(cli.Command{Name: "time-in-status", Summary: "time each issue spent in each status, in calendar or business hours", Main: timeinstatus.Main})
+	c.Register(cli.Command{Name: "stale", Summary: "open issues without updates, too long in a status or unassigned in an active sprint; --fail gates CI", Main: stale.Main})
+	c.Register(cli.Command{Name: "worklogs", Summary: "hours logged per person, sprint, epic or month, from worklogs saved by fetch --worklogs", Main: worklogs.Main})
+	c.Register(cli.Command{Name: "quality", Summary: "reopen rate, time to resolution and fix version slips per component and quarter", Main: quality.Main})
+	c.Register(cli.Command{Name: "capacity", Summary: "sprint load per assignee against configured capacity; flags overallocation", Main: capacity.Main})
+	c.Register(cli.Command{Name: "prs", Summary: "GitHub and GitLab pull requests each issue links to; --github tells which were merged", Main: prs.Main})
+	c.Register(cli.Command{Name: "snapshot", Summary: "status, effort and assignee of a sprint's issues at a point in time, from changelogs", Main: snapshot.Main})
+	c.Register(cli.Command{Name: "forecast", Summary: "Monte Carlo completion dates for a backlog or epic from weekly throughput", Main: forecast.Main})
+	c.Register(cli.Command{Name: "stakeholders", Summary: "who watches and who is @mentioned across a sprint or epic, from watchers saved by fetch --watchers", Main: stakeholders.Main})
+	c.Register(cli.Command{Name: "volatility", Summary: "how often and by how much story points change on issues once they are in a sprint, per sprint or team", Main: volatility.Main})
+	c.Register(cli.Command{Name: "releases", Summary: "issues, completion and scope changes per fix version, with versions saved by releases fetch", Main: releases.Main})
+	c.Register(cli.Command{Name: "rpc", Summary: "JSON-RPC 2.0 on stdin/stdout for notebooks and other tooling (also --json-rpc)", Main: rpc.Main})
+	return c
+}
+
+func main() {
+	commands().Run(os.Args[1:])
+}
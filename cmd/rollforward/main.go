@@ -0,0 +1,12 @@
+package main
+
+import (
+	"os"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/commands/rollforward"
+)
+
+func main() {
+	cli.RunCommand(cli.Command{Name: "rollforward", Main: rollforward.Main}, os.Args[1:])
+}
@@ -0,0 +1,12 @@
+package main
+
+import (
+	"os"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/commands/seasonality"
+)
+
+func main() {
+	cli.RunCommand(cli.Command{Name: "seasonality", Main: seasonality.Main}, os.Args[1:])
+}
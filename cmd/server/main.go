@@ -0,0 +1,12 @@
+package main
+
+import (
+	"os"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/commands/server"
+)
+
+func main() {
+	cli.RunCommand(cli.Command{Name: "server", Main: server.Main}, os.Args[1:])
+}
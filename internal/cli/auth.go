@@ -0,0 +1,117 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/jctanner/rhoai-jira/internal/jira"
+)
+
+// AuthFlags select how requests to Jira are authenticated.
+type AuthFlags struct {
+	Method            string
+	Token             string
+	Email             string
+	OAuthTokenURL     string
+	OAuthClientID     string
+	OAuthClientSecret string
+	OAuthRefreshToken string
+	OAuthTokenFile    string
+}
+
+// AddAuthFlags registers -auth, -token, -email and the -oauth-* flags on a
+// flag set. Secrets fall back to environment variables.
+func AddAuthFlags(fs *flag.FlagSet) *AuthFlags {
+	a := &AuthFlags{}
+	fs.StringVar(&a.Method, "auth", settings.Auth, "Authentication: pat (Jira Server bearer token), basic (Jira Cloud email + API token) or oauth (default: basic with --email, oauth with a refresh token, else pat)")
+	fs.StringVar(&a.Token, "token", "", "Jira personal access token or Cloud API token (or fallback to JIRA_TOKEN env var)")
+	fs.StringVar(&a.Email, "email", "", "Account email for Jira Cloud basic auth (or JIRA_EMAIL env var)")
+	fs.StringVar(&a.OAuthTokenURL, "oauth-token-url", jira.AtlassianTokenURL, "OAuth 2.0 token endpoint")
+	fs.StringVar(&a.OAuthClientID, "oauth-client-id", "", "OAuth 2.0 client id (or JIRA_OAUTH_CLIENT_ID env var)")
+	fs.StringVar(&a.OAuthClientSecret, "oauth-client-secret", "", "OAuth 2.0 client secret (or JIRA_OAUTH_CLIENT_SECRET env var)")
+	fs.StringVar(&a.OAuthRefreshToken, "oauth-refresh-token", "", "OAuth 2.0 refresh token (or JIRA_OAUTH_REFRESH_TOKEN env var)")
+	fs.StringVar(&a.OAuthTokenFile, "oauth-token-file", "", "File holding the OAuth 2.0 refresh token; rewritten when Jira rotates it")
+	return a
+}
+
+func envDefault(v *string, name string) {
+	if *v == "" {
+		*v = os.Getenv(name)
+	}
+}
+
+// Authenticator builds the authenticator selected by the flags.
+func (a *AuthFlags) Authenticator() (jira.Authenticator, error) {
+	envDefault(&a.Token, "JIRA_TOKEN")
+	envDefault(&a.Email, "JIRA_EMAIL")
+	if a.Email == "" {
+		a.Email = settings.Email
+	}
+	if a.Token == "" {
+		token, err := settings.ResolveToken()
+		if err != nil {
+			return nil, err
+		}
+		a.Token = token
+	}
+	envDefault(&a.OAuthClientID, "JIRA_OAUTH_CLIENT_ID")
+	envDefault(&a.OAuthClientSecret, "JIRA_OAUTH_CLIENT_SECRET")
+	envDefault(&a.OAuthRefreshToken, "JIRA_OAUTH_REFRESH_TOKEN")
+	if a.OAuthRefreshToken == "" && a.OAuthTokenFile != "" {
+		data, err := os.ReadFile(a.OAuthTokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("read oauth token file: %w", err)
+		}
+		a.OAuthRefreshToken = strings.TrimSpace(string(data))
+	}
+
+	method := strings.ToLower(a.Method)
+	if method == "" {
+		switch {
+		case a.Email != "":
+			method = "basic"
+		case a.OAuthRefreshToken != "":
+			method = "oauth"
+		default:
+			method = "pat"
+		}
+	}
+
+	switch method {
+	case "pat", "bearer":
+		if a.Token == "" {
+			return nil, fmt.Errorf("token must be passed via --token or JIRA_TOKEN")
+		}
+		return jira.BearerAuth{Token: a.Token}, nil
+	case "basic":
+		if a.Email == "" || a.Token == "" {
+			return nil, fmt.Errorf("basic auth needs --email (or JIRA_EMAIL) and an API token via --token (or JIRA_TOKEN)")
+		}
+		return jira.BasicAuth{Email: a.Email, Token: a.Token}, nil
+	case "oauth":
+		if a.OAuthClientID == "" || a.OAuthClientSecret == "" || a.OAuthRefreshToken == "" {
+			return nil, fmt.Errorf("oauth needs a client id, client secret and refresh token")
+		}
+		auth := &jira.OAuth2Auth{
+			TokenURL:     a.OAuthTokenURL,
+			ClientID:     a.OAuthClientID,
+			ClientSecret: a.OAuthClientSecret,
+			RefreshToken: a.OAuthRefreshToken,
+		}
+		tokenFile := a.OAuthTokenFile
+		auth.OnRefresh = func(refreshToken string) {
+			if tokenFile == "" {
+				log.Printf("warning: Jira rotated the OAuth refresh token; use --oauth-token-file to keep it across runs")
+				return
+			}
+			if err := os.WriteFile(tokenFile, []byte(refreshToken+"\n"), 0o600); err != nil {
+				log.Printf("failed to save rotated refresh token: %v", err)
+			}
+		}
+		return auth, nil
+	}
+	return nil, fmt.Errorf("unknown --auth %q (want pat, basic or oauth)", a.Method)
+}
@@ -0,0 +1,228 @@
+// Package cli holds the flag handling shared by the rhoai-jira subcommands.
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jctanner/rhoai-jira/internal/config"
+	"github.com/jctanner/rhoai-jira/internal/jira"
+)
+
+// CacheFlags locate the cache a report reads from.
+type CacheFlags struct {
+	Dir     string
+	Cache   string
+	Project string
+	// FetchMissing reads issues missing from the cache through to Jira.
+	FetchMissing bool
+	// Archived includes the archive tier of a directory cache.
+	Archived bool
+	// Wait is how long to wait for a fetch writing the cache to finish.
+	Wait time.Duration
+
+	fs *flag.FlagSet
+}
+
+// AddCacheFlags registers -dir, -cache, -project, -fetch-missing,
+// -archived and -wait on a flag set.
+func AddCacheFlags(fs *flag.FlagSet) *CacheFlags {
+	c := &CacheFlags{fs: fs}
+	fs.StringVar(&c.Dir, "dir", "issues", "Directory containing cached issues")
+	fs.StringVar(&c.Cache, "cache", "", "Cache backend such as dir:issues or sqlite:issues.db (defaults to -dir)")
+	fs.StringVar(&c.Project, "project", "", "Filter on a specific project")
+	fs.BoolVar(&c.FetchMissing, "fetch-missing", settings.FetchMissing, "Fetch issues missing from the cache from Jira on demand and save them (needs credentials)")
+	fs.BoolVar(&c.Archived, "archived", false, "Include issues moved to the archive tier of a directory cache (slower)")
+	fs.DurationVar(&c.Wait, "wait", 0, "Wait this long for a fetch writing the cache to finish instead of failing at once")
+	return c
+}
+
+// Spec returns the cache backend spec: -cache, an explicit -dir, the cache
+// of the config file, and finally the default directory.
+func (c *CacheFlags) Spec() string {
+	if c.Cache != "" {
+		return c.Cache
+	}
+	dirSet := false
+	if c.fs != nil {
+		c.fs.Visit(func(f *flag.Flag) {
+			dirSet = dirSet || f.Name == "dir"
+		})
+	}
+	if dirSet {
+		return c.Dir
+	}
+	return CacheSpec(c.Dir)
+}
+
+// Open opens the selected cache backend and holds the shared cache lock
+// until the store is closed. With -fetch-missing and usable credentials,
+// reads of uncached issues go through to Jira.
+func (c *CacheFlags) Open() (jira.Store, error) {
+	store, err := c.OpenUnlocked()
+	if err != nil {
+		return nil, err
+	}
+	if err := c.Lock(store); err != nil {
+		store.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+// OpenForWriting opens the cache like Open with the exclusive lock, for
+// commands that save to it.
+func (c *CacheFlags) OpenForWriting() (jira.Store, error) {
+	store, err := c.OpenUnlocked()
+	if err != nil {
+		return nil, err
+	}
+	if err := LockError(jira.LockStore(store, true, c.Wait)); err != nil {
+		store.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+// Lock takes the shared cache lock of a store opened with OpenUnlocked,
+// waiting up to -wait, until jira.UnlockStore.
+func (c *CacheFlags) Lock(store jira.Store) error {
+	return LockError(jira.LockStore(store, false, c.Wait))
+}
+
+// LockError adds the --wait hint to errors for a locked cache.
+func LockError(err error) error {
+	if errors.Is(err, jira.ErrCacheLocked) {
+		return fmt.Errorf("%w; pass --wait to wait for it", err)
+	}
+	return err
+}
+
+// OpenUnlocked opens the cache like Open without locking it, for commands
+// that keep running, which take the lock around each reload instead so
+// fetch can update the cache in between.
+func (c *CacheFlags) OpenUnlocked() (jira.Store, error) {
+	store, err := jira.OpenStore(c.Spec())
+	if dir, ok := store.(*jira.DirStore); ok {
+		dir.IncludeArchived = c.Archived
+	}
+	if err != nil || !c.FetchMissing {
+		return store, err
+	}
+	auth, err := (&AuthFlags{Method: settings.Auth}).Authenticator()
+	if err != nil {
+		log.Printf("--fetch-missing disabled: %v", err)
+		return store, nil
+	}
+	client := jira.NewClient(BaseURL(), "")
+	client.Auth = auth
+	return jira.NewReadThroughStore(context.Background(), store, client), nil
+}
+
+// Command is a subcommand of the rhoai-jira binary.
+type Command struct {
+	Name    string
+	Aliases []string
+	Summary string
+	Main    func(args []string)
+}
+
+// RunCommand runs cmd as the only command of a binary, as the programs
+// under cmd/ do, so the global flags and the config file apply to it the
+// same as under rhoai-jira.
+func RunCommand(cmd Command, args []string) {
+	c := &Commands{Program: cmd.Name}
+	c.Register(cmd)
+	c.Run(append([]string{cmd.Name}, args...))
+}
+
+// Commands dispatches to registered subcommands.
+type Commands struct {
+	Program string
+	list    []Command
+}
+
+func (c *Commands) Register(cmd Command) {
+	c.list = append(c.list, cmd)
+}
+
+func (c *Commands) Lookup(name string) (Command, bool) {
+	for _, cmd := range c.list {
+		if cmd.Name == name {
+			return cmd, true
+		}
+		for _, alias := range cmd.Aliases {
+			if alias == name {
+				return cmd, true
+			}
+		}
+	}
+	return Command{}, false
+}
+
+func (c *Commands) Usage() {
+	fmt.Fprintf(os.Stderr, "usage: %s <command> [flags]\n\ncommands:\n", c.Program)
+	sorted := append([]Command(nil), c.list...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+	for _, cmd := range sorted {
+		name := cmd.Name
+		if len(cmd.Aliases) > 0 {
+			name += " (" + strings.Join(cmd.Aliases, ", ") + ")"
+		}
+		fmt.Fprintf(os.Stderr, "  %-24s %s\n", name, cmd.Summary)
+	}
+	fmt.Fprintf(os.Stderr, "\nglobal flags:\n  --config FILE            config file (default $%s or ~/.rhoai-jira.yaml)\n  --log-level LEVEL        debug, info, warn or error (default info)\n  --log-format FORMAT      text or json (default text)\n", config.EnvVar)
+	fmt.Fprintf(os.Stderr, "\nRun '%s <command> -h' for the flags of a command.\n", c.Program)
+}
+
+// Run executes the subcommand named by args[0]. The --config, --log-level
+// and --log-format flags may appear before or after the command name;
+// --json-rpc runs the rpc command.
+func (c *Commands) Run(args []string) {
+	global := map[string]string{}
+	for _, name := range []string{"config", "log-level", "log-format"} {
+		value, rest, ok := splitGlobalFlag(args, name)
+		if !ok {
+			Fatalf(ExitUsage, "--%s needs a value", name)
+		}
+		global[name], args = value, rest
+	}
+	if err := SetupLogging(global["log-level"], global["log-format"]); err != nil {
+		Fatalf(ExitUsage, "%v", err)
+	}
+	path := global["config"]
+	if err := LoadConfig(path); err != nil {
+		Fatalf(ExitUsage, "%v", err)
+	}
+	if len(args) == 0 {
+		c.Usage()
+		os.Exit(2)
+	}
+	switch args[0] {
+	case "help", "-h", "-help", "--help":
+		if len(args) > 1 {
+			if cmd, ok := c.Lookup(args[1]); ok {
+				cmd.Main([]string{"-h"})
+				return
+			}
+		}
+		c.Usage()
+		return
+	case "-json-rpc", "--json-rpc":
+		args = append([]string{"rpc"}, args[1:]...)
+	}
+	cmd, ok := c.Lookup(args[0])
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", args[0])
+		c.Usage()
+		os.Exit(2)
+	}
+	cmd.Main(args[1:])
+}
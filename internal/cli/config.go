@@ -0,0 +1,143 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jctanner/rhoai-jira/internal/config"
+	"github.com/jctanner/rhoai-jira/internal/jira"
+	"github.com/jctanner/rhoai-jira/internal/render"
+)
+
+// DefaultBaseURL is the Jira used when neither --base-url nor the config
+// file name one.
+const DefaultBaseURL = "https://issues.redhat.com"
+
+var settings = &config.Config{}
+
+// Settings returns the loaded config file; it is empty when there is none.
+func Settings() *config.Config {
+	return settings
+}
+
+// LoadConfig reads the config file (see config.Load) and applies the
+// settings that are not flags: custom field ids, request pacing and
+// retries, the HTTP transport, the API version, the API audit log and the
+// output directory.
+func LoadConfig(path string) error {
+	c, err := config.Load(path)
+	if err != nil {
+		return err
+	}
+	interval, _ := c.MinInterval()
+	if interval > 0 {
+		jira.DefaultMinInterval = interval
+	}
+	if rate, burst := c.Rate(); rate > 0 {
+		jira.DefaultLimiter = jira.NewTokenBucket(rate, burst)
+	} else if c.RateLimit.Burst > 0 {
+		jira.DefaultLimiter = jira.NewTokenBucket(jira.DefaultRate, burst)
+	}
+	if c.RateLimit.MaxAttempts > 0 {
+		jira.DefaultRetryPolicy.MaxAttempts = c.RateLimit.MaxAttempts
+	}
+	if c.HTTP != (config.HTTP{}) {
+		httpClient, err := jira.NewHTTPClient(jira.TransportOptions{
+			Proxy:              c.HTTP.Proxy,
+			CAFile:             c.Resolve(c.HTTP.CAFile),
+			InsecureSkipVerify: c.HTTP.InsecureSkipVerify,
+		})
+		if err != nil {
+			return fmt.Errorf("config %s: %w", c.Path, err)
+		}
+		jira.DefaultHTTPClient = httpClient
+	}
+	if c.AuditLog != "" {
+		jira.DefaultAuditLog = &jira.AuditLog{Path: c.Resolve(c.AuditLog)}
+	}
+	jira.DefaultAPIVersion = string(c.APIVersion)
+	if c.ADFFormat != "" {
+		jira.DefaultADFFormat = c.ADFFormat
+	}
+	jira.SetCustomFields(c.Fields.Sprint, c.Fields.StoryPoints, c.Fields.EpicLink)
+	var rules []jira.SprintRule
+	for _, s := range c.Sprints {
+		rule, err := jira.NewSprintRule(s.Pattern, s.Name, s.Team)
+		if err != nil {
+			return fmt.Errorf("config %s: %w", c.Path, err)
+		}
+		rules = append(rules, rule)
+	}
+	jira.SetSprintRules(rules)
+	render.OutputDir = c.Resolve(c.OutputDir)
+	settings = c
+	if path := FieldsConfig(); path != "" {
+		extractors, err := jira.LoadExtractors(path)
+		if err != nil {
+			return fmt.Errorf("config %s: %w", c.Path, err)
+		}
+		jira.SetIndexExtractors(extractors)
+	}
+	if path != "" {
+		// Subprocesses such as pipeline steps read the same file.
+		os.Setenv(config.EnvVar, c.Path)
+	}
+	return nil
+}
+
+// BaseURL returns the configured Jira base URL or DefaultBaseURL.
+func BaseURL() string {
+	if settings.BaseURL != "" {
+		return strings.TrimRight(settings.BaseURL, "/")
+	}
+	return DefaultBaseURL
+}
+
+// CacheSpec returns the configured cache or def.
+func CacheSpec(def string) string {
+	if spec := settings.CacheSpec(); spec != "" {
+		return spec
+	}
+	return def
+}
+
+// AuditLog returns the configured API audit log, if any.
+func AuditLog() string {
+	return settings.Resolve(settings.AuditLog)
+}
+
+// Holidays returns the configured holidays, checked when the config was
+// loaded.
+func Holidays() []config.Holiday {
+	holidays, _ := settings.HolidayDates()
+	return holidays
+}
+
+// FieldsConfig returns the configured fields config file, if any.
+func FieldsConfig() string {
+	return settings.Resolve(settings.FieldsConfig)
+}
+
+// splitGlobalFlag removes a flag such as -config/--config from args,
+// returning its value and the remaining arguments.
+func splitGlobalFlag(args []string, flagName string) (string, []string, bool) {
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !strings.HasPrefix(arg, "-") || name != flagName {
+			continue
+		}
+		rest := append([]string(nil), args[:i]...)
+		if hasValue {
+			return value, append(rest, args[i+1:]...), true
+		}
+		if i+1 >= len(args) {
+			return "", args, false
+		}
+		return args[i+1], append(rest, args[i+2:]...), true
+	}
+	return "", args, true
+}
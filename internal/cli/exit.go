@@ -0,0 +1,124 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"os/exec"
+
+	"github.com/jctanner/rhoai-jira/internal/jira"
+)
+
+// Exit codes shared by every subcommand, so cron and CI wrappers can branch
+// on the kind of failure instead of matching log output. Codes are stable;
+// new ones are only ever appended.
+const (
+	// ExitOK means the command did everything it was asked to.
+	ExitOK = 0
+	// ExitFailure is any error that does not fit a more specific code.
+	ExitFailure = 1
+	// ExitUsage means invalid flags or arguments (the flag package also
+	// exits with 2 on parse errors).
+	ExitUsage = 2
+	// ExitAuth means Jira rejected the credentials: a 401, or a 403 on a
+	// request that is not for a single issue.
+	ExitAuth = 3
+	// ExitPartialSync means a sync ran to completion but some issues could
+	// not be fetched; the cache is usable but incomplete.
+	ExitPartialSync = 4
+	// ExitRateLimited means the command gave up after Jira kept answering
+	// 429 Too Many Requests.
+	ExitRateLimited = 5
+	// ExitCacheCorrupt means cache files could not be parsed or do not
+	// match the manifest.
+	ExitCacheCorrupt = 6
+	// ExitNoData means the cache holds too little data for the report,
+	// such as an unknown sprint or no matching issues.
+	ExitNoData = 7
+	// ExitUnavailable means Jira could not be reached or answered with a
+	// server error.
+	ExitUnavailable = 8
+	// ExitFindings means a check such as stale --fail found issues to
+	// act on; the report was still written.
+	ExitFindings = 9
+	// ExitLocked means another process, such as a fetch, held the cache
+	// lock for longer than --wait.
+	ExitLocked = 10
+	// ExitInterrupted means the command was cancelled by a signal.
+	ExitInterrupted = 130
+)
+
+// ErrNoData is wrapped by report errors caused by missing data.
+var ErrNoData = errors.New("insufficient data")
+
+// ExitError carries an explicit exit code through an error chain.
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string { return e.Err.Error() }
+func (e *ExitError) Unwrap() error { return e.Err }
+
+// WithCode attaches an exit code to an error.
+func WithCode(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &ExitError{Code: code, Err: err}
+}
+
+// ExitCode classifies an error into one of the exit codes above.
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.Code
+	}
+	var procErr *exec.ExitError
+	if errors.As(err, &procErr) && procErr.ExitCode() > 0 {
+		return procErr.ExitCode()
+	}
+	var statusErr *jira.StatusError
+	if errors.As(err, &statusErr) {
+		switch {
+		case statusErr.StatusCode == 401 || statusErr.StatusCode == 403:
+			return ExitAuth
+		case statusErr.StatusCode == 429:
+			return ExitRateLimited
+		case statusErr.StatusCode >= 500:
+			return ExitUnavailable
+		}
+	}
+	var netErr net.Error
+	switch {
+	case errors.Is(err, jira.ErrCorruptCache):
+		return ExitCacheCorrupt
+	case errors.Is(err, jira.ErrCacheLocked):
+		return ExitLocked
+	case errors.Is(err, ErrNoData):
+		return ExitNoData
+	case errors.Is(err, context.Canceled):
+		return ExitInterrupted
+	case errors.As(err, &netErr):
+		return ExitUnavailable
+	}
+	return ExitFailure
+}
+
+// Fatal logs err and exits with its classified exit code.
+func Fatal(err error) {
+	slog.Error(err.Error())
+	os.Exit(ExitCode(err))
+}
+
+// Fatalf logs a message and exits with the given code.
+func Fatalf(code int, format string, args ...interface{}) {
+	slog.Error(fmt.Sprintf(format, args...))
+	os.Exit(code)
+}
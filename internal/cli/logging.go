@@ -0,0 +1,143 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Environment variables carrying --log-level and --log-format to
+// subprocesses such as pipeline steps.
+const (
+	LogLevelEnv  = "RHOAI_JIRA_LOG_LEVEL"
+	LogFormatEnv = "RHOAI_JIRA_LOG_FORMAT"
+)
+
+// SetupLogging installs the default slog logger writing to stderr at level
+// (debug, info, warn or error) in format: text, the classic log lines, or
+// json, one object per line for journald and log shippers. Empty values
+// fall back to the environment and then to info and text. Plain log.Printf
+// calls are logged at info; commands log through Infof and Warnf
+// so --log-level can tell their lines apart.
+func SetupLogging(level, format string) error {
+	if level == "" {
+		level = os.Getenv(LogLevelEnv)
+	}
+	if format == "" {
+		format = os.Getenv(LogFormatEnv)
+	}
+	var lvl slog.Level
+	if level != "" {
+		if err := lvl.UnmarshalText([]byte(level)); err != nil {
+			return fmt.Errorf("invalid --log-level %q (expected debug, info, warn or error)", level)
+		}
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+	var handler slog.Handler
+	switch format {
+	case "", "text":
+		handler = &textHandler{out: os.Stderr, level: lvl, mu: &outputMu}
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	default:
+		return fmt.Errorf("invalid --log-format %q (expected text or json)", format)
+	}
+	slog.SetDefault(slog.New(handler))
+	outputMu.Lock()
+	statusEnabled = (format == "" || format == "text") && IsTerminal(os.Stderr)
+	outputMu.Unlock()
+	if level != "" {
+		os.Setenv(LogLevelEnv, level)
+	}
+	if format != "" {
+		os.Setenv(LogFormatEnv, format)
+	}
+	return nil
+}
+
+// textHandler writes records the way the log package always has, a
+// timestamp and the message, with the level in front of everything but
+// info and the attributes appended as key=value.
+type textHandler struct {
+	out   io.Writer
+	level slog.Level
+	attrs []slog.Attr
+	group string
+	mu    *sync.Mutex
+}
+
+func (h *textHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h *textHandler) Handle(_ context.Context, r slog.Record) error {
+	var buf bytes.Buffer
+	t := r.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	buf.WriteString(t.Format("2006/01/02 15:04:05 "))
+	if r.Level != slog.LevelInfo {
+		buf.WriteString(r.Level.String())
+		buf.WriteByte(' ')
+	}
+	buf.WriteString(r.Message)
+	write := func(a slog.Attr) {
+		if a.Equal(slog.Attr{}) {
+			return
+		}
+		key := a.Key
+		if h.group != "" {
+			key = h.group + "." + key
+		}
+		value := a.Value.Resolve().String()
+		if strings.ContainsAny(value, " \t\n\"=") || value == "" {
+			value = fmt.Sprintf("%q", value)
+		}
+		fmt.Fprintf(&buf, " %s=%s", key, value)
+	}
+	for _, a := range h.attrs {
+		write(a)
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		write(a)
+		return true
+	})
+	buf.WriteByte('\n')
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := h.out.Write(wrapStatus(buf.Bytes()))
+	return err
+}
+
+func (h *textHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	c := *h
+	c.attrs = append(append([]slog.Attr(nil), h.attrs...), attrs...)
+	return &c
+}
+
+func (h *textHandler) WithGroup(name string) slog.Handler {
+	c := *h
+	if c.group != "" {
+		name = c.group + "." + name
+	}
+	c.group = name
+	return &c
+}
+
+// Infof logs a formatted message at info, for progress and summaries.
+func Infof(format string, args ...interface{}) {
+	slog.Info(fmt.Sprintf(format, args...))
+}
+
+// Warnf logs a formatted message at warn, for failures a command carries
+// on past and results it could only partly compute.
+func Warnf(format string, args ...interface{}) {
+	slog.Warn(fmt.Sprintf(format, args...))
+}
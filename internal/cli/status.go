@@ -0,0 +1,51 @@
+package cli
+
+import (
+	"os"
+	"strings"
+	"sync"
+)
+
+// A status line, such as a progress bar, stays at the bottom of the
+// terminal: log records clear it, print above it and draw it again.
+var (
+	outputMu      sync.Mutex
+	statusLine    string
+	statusEnabled bool
+)
+
+// IsTerminal reports whether f is a terminal rather than a file or pipe.
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// StatusAvailable reports whether the log goes to a terminal as text, so a
+// status line can be kept below it.
+func StatusAvailable() bool {
+	outputMu.Lock()
+	defer outputMu.Unlock()
+	return statusEnabled
+}
+
+// SetStatus draws line as the status line, replacing the previous one; ""
+// removes it. It does nothing unless StatusAvailable.
+func SetStatus(line string) {
+	outputMu.Lock()
+	defer outputMu.Unlock()
+	if !statusEnabled {
+		return
+	}
+	line = strings.ReplaceAll(line, "\n", " ")
+	os.Stderr.WriteString("\r\033[K" + line)
+	statusLine = line
+}
+
+// wrapStatus surrounds a log record with what clears the status line and
+// draws it again. The caller holds outputMu.
+func wrapStatus(record []byte) []byte {
+	if statusLine == "" {
+		return record
+	}
+	return append(append([]byte("\r\033[K"), record...), statusLine...)
+}
@@ -0,0 +1,186 @@
+package aging
+
+import (
+	"flag"
+	"fmt"
+	"html"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/jira"
+	"github.com/jctanner/rhoai-jira/internal/render"
+)
+
+var priorityOrder = []string{"Blocker", "Critical", "Major", "Normal", "Minor", "Undefined"}
+
+type AgeBucket struct {
+	Label   string
+	MaxDays int // 0 means unbounded
+}
+
+func parseBuckets(spec string) ([]AgeBucket, error) {
+	var buckets []AgeBucket
+	prev := 0
+	for _, part := range strings.Split(spec, ",") {
+		days, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || days <= prev {
+			return nil, fmt.Errorf("invalid bucket list %q (expected increasing day counts)", spec)
+		}
+		buckets = append(buckets, AgeBucket{Label: fmt.Sprintf("%d-%dd", prev, days), MaxDays: days})
+		prev = days
+	}
+	buckets = append(buckets, AgeBucket{Label: fmt.Sprintf("%dd+", prev)})
+	return buckets, nil
+}
+
+func bucketFor(buckets []AgeBucket, ageDays int) int {
+	for i, b := range buckets {
+		if b.MaxDays == 0 || ageDays < b.MaxDays {
+			return i
+		}
+	}
+	return len(buckets) - 1
+}
+
+func sortPriorities(seen map[string]bool) []string {
+	var result []string
+	for _, p := range priorityOrder {
+		if seen[p] {
+			result = append(result, p)
+			delete(seen, p)
+		}
+	}
+	var rest []string
+	for p := range seen {
+		rest = append(rest, p)
+	}
+	sort.Strings(rest)
+	return append(result, rest...)
+}
+
+func writeSVG(path string, priorities []string, buckets []AgeBucket, counts map[string][]int) error {
+	const cellW, cellH, labelW, headerH = 90, 36, 110, 40
+
+	maxCount := 0
+	for _, row := range counts {
+		for _, c := range row {
+			if c > maxCount {
+				maxCount = c
+			}
+		}
+	}
+
+	width := labelW + cellW*len(buckets) + 10
+	height := headerH + cellH*len(priorities) + 10
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" font-family="sans-serif" font-size="12">`+"\n", width, height)
+	for j, bucket := range buckets {
+		fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="middle">%s</text>`+"\n", labelW+j*cellW+cellW/2, headerH-14, html.EscapeString(bucket.Label))
+	}
+	for i, priority := range priorities {
+		y := headerH + i*cellH
+		fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="end">%s</text>`+"\n", labelW-8, y+cellH/2+4, html.EscapeString(priority))
+		for j := range buckets {
+			count := counts[priority][j]
+			intensity := 0.0
+			if maxCount > 0 {
+				intensity = float64(count) / float64(maxCount)
+			}
+			// white -> red
+			shade := int(255 - intensity*200)
+			fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%d" height="%d" fill="rgb(255,%d,%d)" stroke="#ccc"/>`+"\n", labelW+j*cellW, y, cellW, cellH, shade, shade)
+			fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="middle">%d</text>`+"\n", labelW+j*cellW+cellW/2, y+cellH/2+4, count)
+		}
+	}
+	b.WriteString("</svg>\n")
+
+	return os.WriteFile(path, []byte(b.String()), 0644)
+}
+
+func Main(args []string) {
+	fs := flag.NewFlagSet("aging", flag.ExitOnError)
+	cacheFlags := cli.AddCacheFlags(fs)
+	issueType := fs.String("issue-type", "Bug", "Only count issues of this type (empty for all)")
+	bucketSpec := fs.String("buckets", "7,30,90,180,365", "Comma separated age bucket boundaries in days")
+	svgOut := fs.String("svg", "", "Optional heatmap SVG output file")
+	var renderOpts render.Options
+	render.AddFlags(fs, &renderOpts)
+	fs.Parse(args)
+
+	store, err := cacheFlags.Open()
+	if err != nil {
+		cli.Fatal(err)
+	}
+	defer store.Close()
+	renderOpts.SetSource(store)
+
+	buckets, err := parseBuckets(*bucketSpec)
+	if err != nil {
+		cli.Fatal(err)
+	}
+
+	now := time.Now()
+	counts := make(map[string][]int)
+	seen := make(map[string]bool)
+	for _, issue := range jira.LoadIssues(store, cacheFlags.Project) {
+		if issue.IsDone() {
+			continue
+		}
+		if *issueType != "" && issue.Fields.IssueType.Name != *issueType {
+			continue
+		}
+		created, err := issue.CreatedTime()
+		if err != nil {
+			cli.Warnf("could not parse created time for %s: %v", issue.Key, err)
+			continue
+		}
+
+		priority := issue.PriorityName()
+		if counts[priority] == nil {
+			counts[priority] = make([]int, len(buckets))
+		}
+		seen[priority] = true
+		ageDays := int(now.Sub(created).Hours() / 24)
+		counts[priority][bucketFor(buckets, ageDays)]++
+	}
+	priorities := sortPriorities(seen)
+
+	headers := []string{"priority"}
+	for _, b := range buckets {
+		headers = append(headers, b.Label)
+	}
+	headers = append(headers, "total")
+	table := render.NewTable(headers...)
+	for _, priority := range priorities {
+		row := []string{priority}
+		total := 0
+		for _, c := range counts[priority] {
+			row = append(row, fmt.Sprintf("%d", c))
+			total += c
+		}
+		row = append(row, fmt.Sprintf("%d", total))
+		table.Append(row...)
+	}
+	if err := renderOpts.Write(table); err != nil {
+		cli.Fatal(err)
+	}
+
+	if *svgOut != "" {
+		path, err := render.OutputPath(*svgOut)
+		if err != nil {
+			cli.Fatal(err)
+		}
+		if err := writeSVG(path, priorities, buckets, counts); err != nil {
+			cli.Fatal(fmt.Errorf("failed to write heatmap: %w", err))
+		}
+		if err := renderOpts.WriteSidecar(path, -1); err != nil {
+			cli.Fatal(err)
+		}
+		cli.Infof("wrote %s", path)
+	}
+}
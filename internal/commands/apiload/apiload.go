@@ -0,0 +1,182 @@
+package apiload
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/jira"
+	"github.com/jctanner/rhoai-jira/internal/query"
+	"github.com/jctanner/rhoai-jira/internal/render"
+	"github.com/jctanner/rhoai-jira/internal/tools"
+)
+
+type load struct {
+	calls       int
+	errors      int
+	rateLimited int
+	retries     int
+	bytes       int64
+	totalMs     int64
+	maxMs       int64
+}
+
+func (l *load) add(e jira.AuditEntry) {
+	l.calls++
+	switch {
+	case e.Status == 429:
+		l.rateLimited++
+	case e.Status != 200:
+		l.errors++
+	}
+	if e.Attempt > 1 {
+		l.retries++
+	}
+	l.bytes += e.Bytes
+	l.totalMs += e.DurationMs
+	if e.DurationMs > l.maxMs {
+		l.maxMs = e.DurationMs
+	}
+}
+
+func Main(args []string) {
+	fs := flag.NewFlagSet("api-load", flag.ExitOnError)
+	logPath := fs.String("log", cli.AuditLog(), "API audit log to read (audit_log in the config file)")
+	bucket := fs.String("bucket", "hour", "Time bucket: hour, day or none")
+	var by tools.StringList
+	fs.Var(&by, "by", "Comma-separated breakdown within each bucket: endpoint, project (default endpoint)")
+	since := fs.String("since", "", "Only requests on or after this date (2025-01-31 or -7d)")
+	var renderOpts render.Options
+	render.AddFlags(fs, &renderOpts)
+	fs.Parse(args)
+
+	if *logPath == "" {
+		cli.Fatalf(cli.ExitUsage, "no audit log; set audit_log in the config file or pass --log")
+	}
+	var format string
+	switch *bucket {
+	case "hour":
+		format = "2006-01-02T15:00Z"
+	case "day":
+		format = "2006-01-02"
+	case "none":
+	default:
+		cli.Fatalf(cli.ExitUsage, "--bucket must be hour, day or none")
+	}
+	if len(by) == 0 {
+		by = tools.StringList{"endpoint"}
+	}
+	for _, b := range by {
+		if b != "endpoint" && b != "project" {
+			cli.Fatalf(cli.ExitUsage, "--by takes endpoint and project, not %q", b)
+		}
+	}
+	var sinceTime time.Time
+	if *since != "" {
+		t, err := query.ParseDate(*since, time.Now())
+		if err != nil {
+			cli.Fatal(cli.WithCode(cli.ExitUsage, err))
+		}
+		sinceTime = t
+	}
+
+	entries, err := jira.ReadAuditLog(*logPath)
+	if errors.Is(err, os.ErrNotExist) {
+		cli.Fatalf(cli.ExitNoData, "audit log %s does not exist yet", *logPath)
+	}
+	if err != nil {
+		cli.Fatal(err)
+	}
+
+	groups := map[string]*load{}
+	hours := map[string]int{}
+	total := &load{}
+	var first, last time.Time
+	for _, e := range entries {
+		if e.Time.Before(sinceTime) {
+			continue
+		}
+		parts := []string{}
+		if format != "" {
+			parts = append(parts, e.Time.UTC().Format(format))
+		}
+		for _, b := range by {
+			value := e.Endpoint
+			if b == "project" {
+				value = e.Project
+			}
+			parts = append(parts, value)
+		}
+		key := strings.Join(parts, "\x00")
+		if groups[key] == nil {
+			groups[key] = &load{}
+		}
+		groups[key].add(e)
+		total.add(e)
+		hours[e.Time.UTC().Format("2006-01-02T15")]++
+		if first.IsZero() || e.Time.Before(first) {
+			first = e.Time
+		}
+		if e.Time.After(last) {
+			last = e.Time
+		}
+	}
+	if total.calls == 0 {
+		cli.Fatalf(cli.ExitNoData, "no requests in %s", *logPath)
+	}
+
+	peakHour, peak := "", 0
+	for h, n := range hours {
+		if n > peak || (n == peak && h < peakHour) {
+			peakHour, peak = h, n
+		}
+	}
+	cli.Infof("%d requests from %s to %s: %d errors, %d rate limited, %s received; peak %d in the hour from %s:00Z",
+		total.calls, first.UTC().Format(time.RFC3339), last.UTC().Format(time.RFC3339),
+		total.errors, total.rateLimited, humanBytes(total.bytes), peak, peakHour)
+
+	headers := []string{}
+	if format != "" {
+		headers = append(headers, *bucket)
+	}
+	headers = append(headers, by...)
+	headers = append(headers, "calls", "errors", "rate_limited", "retries", "bytes", "avg_ms", "max_ms")
+	table := render.NewTable(headers...)
+	keys := make([]string, 0, len(groups))
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		l := groups[key]
+		row := append(strings.Split(key, "\x00"),
+			strconv.Itoa(l.calls),
+			strconv.Itoa(l.errors),
+			strconv.Itoa(l.rateLimited),
+			strconv.Itoa(l.retries),
+			strconv.FormatInt(l.bytes, 10),
+			strconv.FormatInt(l.totalMs/int64(l.calls), 10),
+			strconv.FormatInt(l.maxMs, 10),
+		)
+		table.Append(row...)
+	}
+	if err := renderOpts.Write(table); err != nil {
+		cli.Fatal(err)
+	}
+}
+
+func humanBytes(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MiB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KiB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", n)
+}
@@ -0,0 +1,47 @@
+package boards
+
+import (
+	"flag"
+	"strconv"
+	"strings"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/render"
+)
+
+// Main prints the board column configurations saved in the cache, one row
+// per column, or with "fetch" reads them from the Agile API.
+func Main(args []string) {
+	if len(args) > 0 && args[0] == "fetch" {
+		fetch(args[1:])
+		return
+	}
+	fs := flag.NewFlagSet("boards", flag.ExitOnError)
+	cacheFlags := cli.AddCacheFlags(fs)
+	var renderOpts render.Options
+	render.AddFlags(fs, &renderOpts)
+	fs.Parse(args)
+
+	store, err := cacheFlags.Open()
+	if err != nil {
+		cli.Fatal(err)
+	}
+	defer store.Close()
+	m, err := store.ReadBoards()
+	if err != nil {
+		cli.Fatal(err)
+	}
+	if len(m.Boards) == 0 {
+		cli.Fatalf(cli.ExitNoData, "no board configurations in the cache; run boards fetch first")
+	}
+
+	table := render.NewTable("board_id", "board", "column", "position", "statuses")
+	for _, b := range m.Boards {
+		for i, c := range b.Columns {
+			table.Append(strconv.Itoa(b.ID), b.Name, c.Name, strconv.Itoa(i+1), strings.Join(c.Statuses, "; "))
+		}
+	}
+	if err := renderOpts.Write(table); err != nil {
+		cli.Fatal(err)
+	}
+}
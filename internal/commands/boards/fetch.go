@@ -0,0 +1,80 @@
+package boards
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strconv"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/jira"
+)
+
+// fetch reads the column configuration of boards from the Agile API and
+// saves it in the cache, where the tracker picks it up. Without --board
+// every board referenced by the sprints of cached issues is fetched.
+func fetch(args []string) {
+	fs := flag.NewFlagSet("boards fetch", flag.ExitOnError)
+	cacheFlags := cli.AddCacheFlags(fs)
+	baseURL := fs.String("base-url", cli.BaseURL(), "Jira base URL")
+	var boardIDs []int
+	fs.Func("board", "Board (rapid view) id to fetch (repeatable; default every board of cached sprints)", func(s string) error {
+		id, err := strconv.Atoi(s)
+		if err != nil || id <= 0 {
+			return fmt.Errorf("invalid board id %q", s)
+		}
+		boardIDs = append(boardIDs, id)
+		return nil
+	})
+	auth := cli.AddAuthFlags(fs)
+	fs.Parse(args)
+
+	authenticator, err := auth.Authenticator()
+	if err != nil {
+		cli.Fatal(err)
+	}
+	store, err := cacheFlags.OpenForWriting()
+	if err != nil {
+		cli.Fatal(err)
+	}
+	defer store.Close()
+
+	if len(boardIDs) == 0 {
+		for _, b := range jira.CachedBoards(jira.LoadIssues(store, cacheFlags.Project)) {
+			boardIDs = append(boardIDs, b.ID)
+		}
+	}
+	if len(boardIDs) == 0 {
+		cli.Fatalf(cli.ExitNoData, "no cached sprint references a board; pass --board")
+	}
+
+	client := jira.NewClient(*baseURL, "")
+	client.Auth = authenticator
+	ctx := context.Background()
+	statuses, err := client.ListStatuses(ctx)
+	if err != nil {
+		cli.Fatal(err)
+	}
+	m, err := store.ReadBoards()
+	if err != nil {
+		cli.Fatal(err)
+	}
+	fetched := 0
+	for _, id := range boardIDs {
+		board, err := client.FetchBoardConfig(ctx, id, statuses)
+		if err != nil {
+			cli.Warnf("skipping board %d: %v", id, err)
+			continue
+		}
+		m.Put(board)
+		fetched++
+		cli.Infof("board %d (%s): %d columns", board.ID, board.Name, len(board.Columns))
+	}
+	if fetched == 0 {
+		cli.Fatalf(cli.ExitFailure, "no board configuration could be fetched")
+	}
+	if err := store.SaveBoards(m); err != nil {
+		cli.Fatal(err)
+	}
+	cli.Infof("saved %d of %d board configurations to the cache", fetched, len(boardIDs))
+}
@@ -0,0 +1,89 @@
+package burndown
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jctanner/rhoai-jira/internal/config"
+	"github.com/jctanner/rhoai-jira/internal/jira"
+	"github.com/jctanner/rhoai-jira/internal/render"
+)
+
+// DefaultScopeThreshold is the effort a day has to add to the sprint to be
+// marked on the chart.
+const DefaultScopeThreshold = 5
+
+// AnnotationOptions selects the events marked on a burndown chart.
+type AnnotationOptions struct {
+	Effort jira.EffortSource
+	// ScopeThreshold marks days adding more effort than this; zero or
+	// less marks none.
+	ScopeThreshold float64
+	Holidays       []config.Holiday
+}
+
+// Annotations mines the events that explain a burndown: days adding more
+// scope than the threshold, edits of the sprint goal and holidays.
+func Annotations(tracked []Tracked, sprint string, days []Day, opts AnnotationOptions) []render.Annotation {
+	index := map[string]int{}
+	for i, d := range days {
+		index[d.Date.Format("2006-01-02")] = i
+	}
+	var annotations []render.Annotation
+	add := func(at time.Time, label, color string) {
+		if i, ok := index[at.Format("2006-01-02")]; ok {
+			annotations = append(annotations, render.Annotation{Index: i, Label: label, Color: color})
+		}
+	}
+	for _, h := range opts.Holidays {
+		label := h.Name
+		if label == "" {
+			label = "holiday"
+		}
+		add(h.Date, label, "#2ca02c")
+	}
+	for _, at := range goalEdits(tracked, sprint) {
+		add(at, "goal edited", "#9467bd")
+	}
+	if opts.ScopeThreshold > 0 {
+		for _, d := range days {
+			if d.ScopeAdded > opts.ScopeThreshold {
+				add(d.Date, fmt.Sprintf("+%.1f %s", d.ScopeAdded, opts.Effort.ColumnName()), "#ff7f0e")
+			}
+		}
+	}
+	return annotations
+}
+
+// goalEdits finds when the goal of the sprint changed. Jira keeps no
+// history of sprint goals, but every cached issue holds the goal as it was
+// when the issue was fetched, so issues fetched at different times tell
+// that the goal was edited at or before the first fetch showing the new
+// one.
+func goalEdits(tracked []Tracked, sprint string) []time.Time {
+	type seen struct {
+		at   time.Time
+		goal string
+	}
+	var goals []seen
+	for _, t := range tracked {
+		fetched, err := time.Parse(time.RFC3339, t.Issue.Fetched)
+		if err != nil {
+			continue
+		}
+		for _, s := range t.Issue.Fields.Sprints {
+			if jira.SameSprint(s.Name, sprint) {
+				goals = append(goals, seen{at: fetched, goal: s.Goal})
+			}
+		}
+	}
+	sort.Slice(goals, func(i, j int) bool { return goals[i].at.Before(goals[j].at) })
+	var edits []time.Time
+	for i := 1; i < len(goals); i++ {
+		if goals[i].goal != goals[i-1].goal {
+			edits = append(edits, goals[i].at)
+		}
+	}
+	return edits
+}
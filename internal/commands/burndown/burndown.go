@@ -0,0 +1,415 @@
+package burndown
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/events"
+	"github.com/jctanner/rhoai-jira/internal/jira"
+	"github.com/jctanner/rhoai-jira/internal/render"
+)
+
+// IssueState is what an issue contributes to the burndown at one instant.
+type IssueState struct {
+	InSprint bool
+	Effort   float64
+	Done     bool
+}
+
+// Tracked is a candidate issue with the changelog used to replay it.
+type Tracked struct {
+	Issue     jira.JiraIssueWithSprints
+	Changelog jira.Changelog
+	Created   time.Time
+	// Subtasks are rolled up into the issue by LoadRollup.
+	Subtasks []Tracked
+}
+
+func parseSprintTime(s string) (time.Time, bool) {
+	if s == "" {
+		return time.Time{}, false
+	}
+	for _, layout := range []string{time.RFC3339Nano, jira.JiraTimeLayout, "2006-01-02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func mentionsSprint(t Tracked, sprint string) bool {
+	if t.Issue.InSprint(sprint) {
+		return true
+	}
+	for _, e := range events.Filter(events.Normalize(t.Issue.Key, t.Changelog), events.SprintAdded, events.SprintRemoved) {
+		if jira.SameSprint(e.Sprint, sprint) {
+			return true
+		}
+	}
+	return false
+}
+
+// StateAt replays the changelog of an issue up to at.
+func StateAt(t Tracked, sprint string, effort jira.EffortSource, at time.Time) IssueState {
+	var state IssueState
+	if at.Before(t.Created) {
+		return state
+	}
+
+	if value, ok := jira.ValueAt(t.Changelog, "Sprint", at); ok {
+		for _, name := range events.SplitList(value) {
+			if jira.SameSprint(name, sprint) {
+				state.InSprint = true
+			}
+		}
+	} else {
+		state.InSprint = t.Issue.InSprint(sprint)
+	}
+
+	state.Effort = effort.EffortAt(t.Issue, t.Changelog, at)
+
+	if value, ok := jira.ValueAt(t.Changelog, "resolution", at); ok {
+		state.Done = value != ""
+	} else if resolved, err := t.Issue.ResolvedTime(); err == nil {
+		state.Done = !resolved.After(at)
+	}
+	if !state.Done {
+		if value, ok := jira.ValueAt(t.Changelog, "status", at); ok {
+			state.Done = jira.Status{Name: value}.IsDone()
+		}
+	}
+
+	// A story with subtasks is done once all of them are, and its effort
+	// is theirs; counted issues stay one per story.
+	var efforts []float64
+	done := true
+	for _, sub := range t.Subtasks {
+		if at.Before(sub.Created) {
+			continue
+		}
+		s := StateAt(sub, sprint, effort, at)
+		efforts = append(efforts, s.Effort)
+		done = done && s.Done
+	}
+	if len(efforts) > 0 {
+		if effort != jira.EffortCount {
+			state.Effort = jira.RollupEffort(state.Effort, efforts)
+		}
+		state.Done = done
+	}
+	return state
+}
+
+// SprintWindow finds the sprint dates on any cached issue in the sprint.
+func SprintWindow(tracked []Tracked, sprint string) (time.Time, time.Time, bool) {
+	for _, t := range tracked {
+		for _, s := range t.Issue.Fields.Sprints {
+			if !jira.SameSprint(s.Name, sprint) {
+				continue
+			}
+			start, ok := parseSprintTime(s.StartDate)
+			if !ok {
+				start, ok = parseSprintTime(s.ActivatedDate)
+			}
+			if !ok {
+				continue
+			}
+			end, ok := time.Time{}, false
+			if s.CompleteDate != nil {
+				end, ok = parseSprintTime(*s.CompleteDate)
+			}
+			if !ok {
+				end, ok = parseSprintTime(s.EndDate)
+			}
+			if ok {
+				return start, end, true
+			}
+		}
+	}
+	return time.Time{}, time.Time{}, false
+}
+
+func writeChart(path string, chart *render.LineChart) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".svg":
+		return chart.WriteSVG(f)
+	case ".png":
+		return chart.WritePNG(f)
+	default:
+		return fmt.Errorf("unsupported chart format %q (use .svg or .png)", path)
+	}
+}
+
+// Load reads the cached issues that were ever in the sprint, with their
+// changelogs and how many of them had one.
+func Load(store jira.Store, project, sprint string) ([]Tracked, jira.ChangelogCoverage) {
+	tracked, coverage, _ := load(store, project, sprint)
+	return tracked, coverage
+}
+
+// LoadRollup is Load with the subtasks of the stories in the sprint rolled
+// up into them rather than tracked on their own, whether or not their own
+// sprint field mentions the sprint. Subtasks whose story was never in the
+// sprint are tracked on their own.
+func LoadRollup(store jira.Store, project, sprint string) ([]Tracked, jira.ChangelogCoverage) {
+	tracked, coverage, issues := load(store, project, sprint)
+	loaded := map[string]Tracked{}
+	for _, t := range tracked {
+		loaded[t.Issue.Key] = t
+	}
+	subtasks := jira.SubtasksByParent(issues)
+	var out []Tracked
+	for _, t := range tracked {
+		if _, ok := loaded[t.Issue.SubtaskParent()]; ok {
+			continue
+		}
+		for _, sub := range subtasks[t.Issue.Key] {
+			st, ok := loaded[sub.Key]
+			if !ok {
+				created, err := sub.CreatedTime()
+				if err != nil {
+					continue
+				}
+				changelog, err := store.ReadChangelog(sub.Key)
+				coverage.Add(err)
+				st = Tracked{Issue: sub, Changelog: changelog, Created: created}
+			}
+			t.Subtasks = append(t.Subtasks, st)
+		}
+		out = append(out, t)
+	}
+	return out, coverage
+}
+
+// load is Load, also returning every cached issue of the project.
+func load(store jira.Store, project, sprint string) ([]Tracked, jira.ChangelogCoverage, []jira.JiraIssueWithSprints) {
+	var tracked []Tracked
+	var coverage jira.ChangelogCoverage
+	issues := jira.LoadIssues(store, project)
+	for _, issue := range issues {
+		created, err := issue.CreatedTime()
+		if err != nil {
+			continue
+		}
+		changelog, err := store.ReadChangelog(issue.Key)
+		t := Tracked{Issue: issue, Changelog: changelog, Created: created}
+		if mentionsSprint(t, sprint) {
+			tracked = append(tracked, t)
+			coverage.Add(err)
+		}
+	}
+	return tracked, coverage, issues
+}
+
+// Day is the state of the sprint at the end of one day.
+type Day struct {
+	Date           time.Time
+	Scope          float64
+	Completed      float64
+	CompletedToday float64
+	ScopeAdded     float64
+	ScopeRemoved   float64
+	Ideal          float64
+}
+
+// Remaining is the effort still open at the end of the day.
+func (d Day) Remaining() float64 {
+	return d.Scope - d.Completed
+}
+
+// Burndown replays the tracked issues day by day from start to end, or to
+// now for a running sprint. It also returns the issues and effort in the
+// sprint at its start.
+func Burndown(tracked []Tracked, sprint string, effort jira.EffortSource, start, end, now time.Time) (days []Day, startIssues int, startScope float64) {
+	measure := func(at time.Time) map[string]IssueState {
+		states := make(map[string]IssueState)
+		for _, t := range tracked {
+			if s := StateAt(t, sprint, effort, at); s.InSprint {
+				states[t.Issue.Key] = s
+			}
+		}
+		return states
+	}
+	total := func(states map[string]IssueState) (scope, done float64) {
+		for _, s := range states {
+			scope += s.Effort
+			if s.Done {
+				done += s.Effort
+			}
+		}
+		return scope, done
+	}
+
+	prev := measure(start)
+	startIssues = len(prev)
+	startScope, prevDone := total(prev)
+
+	var dates []time.Time
+	for d := start.Truncate(24 * time.Hour); !d.After(end); d = d.Add(24 * time.Hour) {
+		dates = append(dates, d)
+	}
+	for i, date := range dates {
+		at := date.Add(24*time.Hour - time.Nanosecond)
+		if at.After(end) {
+			at = end
+		}
+		if at.After(now) {
+			break
+		}
+		states := measure(at)
+		scope, done := total(states)
+
+		day := Day{Date: date, Scope: scope, Completed: done, CompletedToday: done - prevDone, Ideal: startScope}
+		for key, s := range states {
+			before := prev[key].Effort
+			if s.Effort > before {
+				day.ScopeAdded += s.Effort - before
+			} else if s.Effort < before {
+				day.ScopeRemoved += before - s.Effort
+			}
+		}
+		for key, s := range prev {
+			if _, ok := states[key]; !ok {
+				day.ScopeRemoved += s.Effort
+			}
+		}
+		if len(dates) > 1 {
+			day.Ideal = startScope * (1 - float64(i)/float64(len(dates)-1))
+		}
+		days = append(days, day)
+		prev, prevDone = states, done
+	}
+	return days, startIssues, startScope
+}
+
+// Chart plots the remaining effort, the ideal line and the scope.
+func Chart(sprint string, days []Day) *render.LineChart {
+	chart := &render.LineChart{
+		Title: fmt.Sprintf("Burndown: %s", sprint),
+		Series: []render.Series{
+			{Name: "remaining", Color: "#d62728"},
+			{Name: "ideal", Color: "#7f7f7f", Dashed: true},
+			{Name: "scope", Color: "#1f77b4"},
+		},
+	}
+	for _, d := range days {
+		chart.Labels = append(chart.Labels, d.Date.Format("2006-01-02"))
+		chart.Series[0].Values = append(chart.Series[0].Values, d.Remaining())
+		chart.Series[1].Values = append(chart.Series[1].Values, d.Ideal)
+		chart.Series[2].Values = append(chart.Series[2].Values, d.Scope)
+	}
+	return chart
+}
+
+func Main(args []string) {
+	fs := flag.NewFlagSet("burndown", flag.ExitOnError)
+	cacheFlags := cli.AddCacheFlags(fs)
+	sprint := fs.String("sprint-filter", "", "Sprint name to burn down (required)")
+	effortStr := fs.String("effort", "points", "Effort source (points, time, count)")
+	startStr := fs.String("start", "", "Sprint start date YYYY-MM-DD (default: from the sprint)")
+	endStr := fs.String("end", "", "Sprint end date YYYY-MM-DD (default: from the sprint)")
+	chartOut := fs.String("chart", "", "Optional chart output file (.svg or .png)")
+	annotateScope := fs.Float64("annotate-scope", DefaultScopeThreshold, "Mark days adding more effort than this on the chart (0 to mark none); holidays come from the config file")
+	var renderOpts render.Options
+	render.AddFlags(fs, &renderOpts)
+	fs.Parse(args)
+
+	if *sprint == "" {
+		cli.Fatalf(cli.ExitUsage, "--sprint-filter must be provided.")
+	}
+	effort, err := jira.ParseEffortSource(*effortStr)
+	if err != nil {
+		cli.Fatal(err)
+	}
+	store, err := cacheFlags.Open()
+	if err != nil {
+		cli.Fatal(err)
+	}
+	defer store.Close()
+	renderOpts.SetSource(store)
+
+	tracked, coverage := Load(store, cacheFlags.Project, *sprint)
+	if len(tracked) == 0 {
+		cli.Fatalf(cli.ExitNoData, "no cached issues were ever in sprint %q", *sprint)
+	}
+	coverage.Log()
+
+	start, end, ok := SprintWindow(tracked, *sprint)
+	if *startStr != "" {
+		if start, ok = parseSprintTime(*startStr); !ok {
+			cli.Fatalf(cli.ExitUsage, "invalid --start %q", *startStr)
+		}
+	}
+	if *endStr != "" {
+		var endOK bool
+		if end, endOK = parseSprintTime(*endStr); !endOK {
+			cli.Fatalf(cli.ExitUsage, "invalid --end %q", *endStr)
+		}
+		end = end.Add(24*time.Hour - time.Nanosecond)
+	}
+	if start.IsZero() || end.IsZero() {
+		cli.Fatalf(cli.ExitNoData, "could not determine the dates of sprint %q; pass --start and --end", *sprint)
+	}
+
+	days, startIssues, startScope := Burndown(tracked, *sprint, effort, start, end, time.Now())
+	cli.Infof("sprint %q: %s to %s, %d issues and %.1f %s at start", *sprint, start.Format("2006-01-02"), end.Format("2006-01-02"), startIssues, startScope, effort.ColumnName())
+
+	table := render.NewTable("date", "scope", "completed", "remaining", "completed_today", "scope_added", "scope_removed", "ideal")
+	table.SetNumeric("scope", "completed", "remaining", "completed_today", "scope_added", "scope_removed", "ideal")
+	for _, d := range days {
+		table.Append(
+			d.Date.Format("2006-01-02"),
+			fmt.Sprintf("%.1f", d.Scope),
+			fmt.Sprintf("%.1f", d.Completed),
+			fmt.Sprintf("%.1f", d.Remaining()),
+			fmt.Sprintf("%.1f", d.CompletedToday),
+			fmt.Sprintf("%.1f", d.ScopeAdded),
+			fmt.Sprintf("%.1f", d.ScopeRemoved),
+			fmt.Sprintf("%.1f", d.Ideal),
+		)
+	}
+	chart := Chart(*sprint, days)
+	chart.Annotations = Annotations(tracked, *sprint, days, AnnotationOptions{
+		Effort:         effort,
+		ScopeThreshold: *annotateScope,
+		Holidays:       cli.Holidays(),
+	})
+	for _, a := range chart.Annotations {
+		cli.Infof("%s: %s", chart.Labels[a.Index], a.Label)
+	}
+
+	if *chartOut != "" && len(chart.Labels) > 0 {
+		path, err := render.OutputPath(*chartOut)
+		if err != nil {
+			cli.Fatal(err)
+		}
+		if err := writeChart(path, chart); err != nil {
+			cli.Fatal(fmt.Errorf("failed to write chart: %w", err))
+		}
+		if err := renderOpts.WriteSidecar(path, -1); err != nil {
+			cli.Fatal(err)
+		}
+		cli.Infof("wrote %s", path)
+	}
+	if renderOpts.HTML() {
+		renderOpts.Title = chart.Title
+		renderOpts.AddNote("%s to %s, %d issues and %.1f %s at start", start.Format("2006-01-02"), end.Format("2006-01-02"), startIssues, startScope, effort.ColumnName())
+		for _, a := range chart.Annotations {
+			renderOpts.AddNote("%s: %s", chart.Labels[a.Index], a.Label)
+		}
+		renderOpts.AddChart(chart)
+	}
+	if err := renderOpts.Write(table); err != nil {
+		cli.Fatal(err)
+	}
+}
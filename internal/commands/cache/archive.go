@@ -0,0 +1,52 @@
+package cache
+
+import (
+	"flag"
+	"strings"
+	"time"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/jira"
+)
+
+// archive moves issues resolved long ago out of the cache directory into
+// the archive tier, where reports read them only by key or with -archived.
+func archive(args []string) {
+	fs := flag.NewFlagSet("archive", flag.ExitOnError)
+	dir := fs.String("dir", "issues", "Cache directory")
+	project := fs.String("project", "", "Only archive issues of this project")
+	years := fs.Int("years", 2, "Archive issues resolved more than this many years ago")
+	before := fs.String("before", "", "Archive issues resolved before this date (YYYY-MM-DD), instead of --years")
+	dryRun := fs.Bool("dry-run", false, "Report what would be archived without changing the cache")
+	wait := fs.Duration("wait", 0, "Wait this long for other processes using the cache to finish instead of failing at once")
+	fs.Parse(args)
+
+	cutoff := time.Now().AddDate(-*years, 0, 0)
+	if *before != "" {
+		t, err := time.Parse("2006-01-02", *before)
+		if err != nil {
+			cli.Fatalf(cli.ExitUsage, "invalid --before %q", *before)
+		}
+		cutoff = t
+	} else if *years < 1 {
+		cli.Fatalf(cli.ExitUsage, "--years must be at least 1")
+	}
+
+	store, err := jira.NewDirStore(*dir)
+	if err != nil {
+		cli.Fatal(err)
+	}
+	if err := store.Lock(!*dryRun, *wait); err != nil {
+		cli.Fatal(cli.LockError(err))
+	}
+	defer store.Close()
+	result, err := jira.ArchiveIssues(store, jira.ArchiveOptions{Project: *project, ResolvedBefore: cutoff, DryRun: *dryRun})
+	if err != nil {
+		cli.Fatal(err)
+	}
+	verb := "archived"
+	if *dryRun {
+		verb = "would archive"
+	}
+	cli.Infof("%s %d issues resolved before %s (%d files, %.1f MiB) into %s", verb, result.Issues, cutoff.Format("2006-01-02"), result.Files, float64(result.Bytes)/(1<<20), strings.Join(result.Bundles, ", "))
+}
@@ -0,0 +1,100 @@
+package cache
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/jira"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: %s <command> [flags]\n\n", "cache")
+	fmt.Fprintln(os.Stderr, "commands:")
+	fmt.Fprintln(os.Stderr, "  build-manifest    hash every cache file and write manifest.json")
+	fmt.Fprintln(os.Stderr, "  verify-manifest   rehash the cache and report files that differ from the manifest")
+	fmt.Fprintln(os.Stderr, "  extract           copy the issues of a sprint or epic into a standalone cache")
+	fmt.Fprintln(os.Stderr, "  archive           move issues resolved long ago into compressed bundles below archive/")
+	fmt.Fprintln(os.Stderr, "  diffs             field changes between fetches recorded by fetch --record-diffs")
+	fmt.Fprintln(os.Stderr, "  compact           rewrite issues without indentation and zstd-compressed, in place")
+	fmt.Fprintln(os.Stderr, "  verify            report empty or corrupt cache files and optionally fetch them again")
+	fmt.Fprintln(os.Stderr, "  index             update the index of issue keys, sprints, status and points used to skip reading issues")
+	fmt.Fprintln(os.Stderr, "  tombstones        list the issues found deleted from Jira or moved to another project")
+}
+
+func buildManifest(args []string) {
+	fs := flag.NewFlagSet("build-manifest", flag.ExitOnError)
+	dir := fs.String("dir", "issues", "Cache directory")
+	workers := fs.Int("workers", runtime.NumCPU(), "Number of parallel hashing workers")
+	fs.Parse(args)
+
+	m, err := jira.BuildManifest(*dir, *workers)
+	if err != nil {
+		cli.Fatal(err)
+	}
+	cli.Infof("recorded %d files in %s", len(m.Files), jira.ManifestFile)
+}
+
+func verifyManifest(args []string) {
+	fs := flag.NewFlagSet("verify-manifest", flag.ExitOnError)
+	dir := fs.String("dir", "issues", "Cache directory")
+	workers := fs.Int("workers", runtime.NumCPU(), "Number of parallel hashing workers")
+	ignoreUntracked := fs.Bool("ignore-untracked", false, "Do not report files missing from the manifest")
+	fs.Parse(args)
+
+	problems, err := jira.VerifyManifest(*dir, *workers)
+	if err != nil {
+		cli.Fatal(err)
+	}
+
+	count := 0
+	for _, p := range problems {
+		if *ignoreUntracked && p.Kind == jira.ManifestUntracked {
+			continue
+		}
+		count++
+		if p.Detail != "" {
+			fmt.Printf("%s\t%s\t%s\n", p.Kind, p.Name, p.Detail)
+		} else {
+			fmt.Printf("%s\t%s\n", p.Kind, p.Name)
+		}
+	}
+	if count > 0 {
+		cli.Warnf("%d problems found", count)
+		os.Exit(cli.ExitCacheCorrupt)
+	}
+	cli.Infof("cache matches manifest")
+}
+
+func Main(args []string) {
+	if len(args) < 1 {
+		usage()
+		os.Exit(cli.ExitUsage)
+	}
+
+	switch args[0] {
+	case "build-manifest":
+		buildManifest(args[1:])
+	case "verify-manifest":
+		verifyManifest(args[1:])
+	case "extract":
+		extract(args[1:])
+	case "archive":
+		archive(args[1:])
+	case "diffs":
+		diffs(args[1:])
+	case "compact":
+		compact(args[1:])
+	case "verify":
+		verify(args[1:])
+	case "index":
+		index(args[1:])
+	case "tombstones":
+		tombstones(args[1:])
+	default:
+		usage()
+		os.Exit(cli.ExitUsage)
+	}
+}
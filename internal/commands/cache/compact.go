@@ -0,0 +1,41 @@
+package cache
+
+import (
+	"flag"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/jira"
+)
+
+// compact rewrites the issues of a directory cache in place without
+// indentation and, unless --compress=false, zstd-compressed. Files gzipped
+// by earlier versions are migrated to zstd.
+func compact(args []string) {
+	fs := flag.NewFlagSet("compact", flag.ExitOnError)
+	dir := fs.String("dir", "issues", "Cache directory")
+	compress := fs.Bool("compress", true, "Compress the files of each issue as {KEY}.json.zst, migrating gzipped {KEY}.json.gz files; false writes compressed files back plain")
+	dryRun := fs.Bool("dry-run", false, "Report the space saved without changing the cache")
+	wait := fs.Duration("wait", 0, "Wait this long for other processes using the cache to finish instead of failing at once")
+	fs.Parse(args)
+
+	store, err := jira.NewDirStore(*dir)
+	if err != nil {
+		cli.Fatal(err)
+	}
+	if err := store.Lock(!*dryRun, *wait); err != nil {
+		cli.Fatal(cli.LockError(err))
+	}
+	defer store.Close()
+	result, err := jira.CompactCache(store, jira.CompactOptions{Compress: *compress, DryRun: *dryRun})
+	if err != nil {
+		cli.Fatal(err)
+	}
+	verb := "rewrote"
+	if *dryRun {
+		verb = "would rewrite"
+	}
+	cli.Infof("%s %d files: %.1f MiB to %.1f MiB", verb, result.Files, float64(result.Before)/(1<<20), float64(result.After)/(1<<20))
+	if *compress && !*dryRun && !cli.Settings().Compress {
+		cli.Infof("set compress: true in the config file (or pass fetch --compress) to keep new fetches compressed")
+	}
+}
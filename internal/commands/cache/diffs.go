@@ -0,0 +1,60 @@
+package cache
+
+import (
+	"flag"
+	"strings"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/jira"
+	"github.com/jctanner/rhoai-jira/internal/render"
+	"github.com/jctanner/rhoai-jira/internal/tools"
+)
+
+// maxDiffValue is how much of a changed value the table shows; long
+// descriptions would drown the other columns.
+const maxDiffValue = 120
+
+func diffValue(v []byte) string {
+	s := string(v)
+	if len(s) > maxDiffValue {
+		s = s[:maxDiffValue] + "..."
+	}
+	return s
+}
+
+// diffs lists the field changes recorded by fetch --record-diffs.
+func diffs(args []string) {
+	fs := flag.NewFlagSet("diffs", flag.ExitOnError)
+	dir := fs.String("dir", "issues", "Cache directory")
+	var fields tools.StringList
+	fs.Var(&fields, "field", "Only changes of these fields (comma separated or repeated)")
+	var renderOpts render.Options
+	render.AddFlags(fs, &renderOpts)
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		cli.Fatalf(cli.ExitUsage, "usage: cache diffs [flags] KEY...")
+	}
+
+	store := &jira.DirStore{Dir: *dir}
+	table := render.NewTable("key", "since", "at", "field", "from", "to")
+	for _, key := range fs.Args() {
+		logged, err := store.ReadDiffs(strings.ToUpper(key))
+		if err != nil {
+			cli.Fatal(err)
+		}
+		for _, d := range logged {
+			for _, c := range d.Changes {
+				if len(fields) > 0 && !tools.ItemInList(fields, c.Field) {
+					continue
+				}
+				table.Append(d.Key, d.Since, d.At, c.Field, diffValue(c.From), diffValue(c.To))
+			}
+		}
+	}
+	if len(table.Rows) == 0 {
+		cli.Fatalf(cli.ExitNoData, "no recorded diffs; fetch with --record-diffs to record them")
+	}
+	if err := renderOpts.Write(table); err != nil {
+		cli.Fatal(err)
+	}
+}
@@ -0,0 +1,95 @@
+package cache
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/jira"
+)
+
+// extract copies the issues of one sprint or epic into a standalone cache
+// that can be shared as a reproducible dataset.
+func extract(args []string) {
+	fs := flag.NewFlagSet("extract", flag.ExitOnError)
+	cacheFlags := cli.AddCacheFlags(fs)
+	sprint := fs.String("sprint", "", "Extract the issues that are or were in this sprint")
+	epic := fs.String("epic", "", "Extract this epic and its issues")
+	out := fs.String("out", "", "Cache to create: a directory, dir:PATH or sqlite:FILE (required)")
+	linkDepth := fs.Int("link-depth", 1, "Hops of issue links to follow from the selected issues (0 for none)")
+	force := fs.Bool("force", false, "Write into an output cache that already holds issues")
+	fs.Parse(args)
+
+	if *out == "" {
+		cli.Fatalf(cli.ExitUsage, "--out must be provided.")
+	}
+	if (*sprint == "") == (*epic == "") {
+		cli.Fatalf(cli.ExitUsage, "Exactly one of --sprint or --epic must be provided.")
+	}
+
+	src, err := cacheFlags.Open()
+	if err != nil {
+		cli.Fatal(err)
+	}
+	defer src.Close()
+	subset, err := jira.SelectSubset(src, jira.SubsetOptions{Sprint: *sprint, Epic: *epic, LinkDepth: *linkDepth})
+	if err != nil {
+		cli.Fatal(cli.WithCode(cli.ExitNoData, err))
+	}
+	subset.Source = cacheFlags.Spec()
+
+	dst, err := jira.OpenStore(*out)
+	if err != nil {
+		cli.Fatal(err)
+	}
+	if err := jira.LockStore(dst, true, cacheFlags.Wait); err != nil {
+		dst.Close()
+		cli.Fatal(cli.LockError(err))
+	}
+	if len(dst.IssueKeys("")) > 0 && !*force {
+		dst.Close()
+		cli.Fatalf(cli.ExitUsage, "%s already holds issues; use --force to add to it", *out)
+	}
+	if err := jira.CopyIssues(src, dst, subset.Keys); err != nil {
+		dst.Close()
+		cli.Fatal(err)
+	}
+	if err := dst.Close(); err != nil {
+		cli.Fatal(err)
+	}
+
+	// A directory cache also gets the selection record and a manifest so
+	// whoever receives it can check it arrived intact.
+	if dir, ok := dirOf(*out); ok {
+		data, err := json.MarshalIndent(subset, "", "  ")
+		if err != nil {
+			cli.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, jira.SubsetFile), append(data, '\n'), 0o644); err != nil {
+			cli.Fatal(err)
+		}
+		if _, err := jira.BuildManifest(dir, runtime.NumCPU()); err != nil {
+			cli.Fatal(err)
+		}
+	}
+	if len(subset.Missing) > 0 {
+		cli.Infof("%d parents or linked issues are not in the source cache: %s", len(subset.Missing), strings.Join(subset.Missing, " "))
+	}
+	cli.Infof("extracted %d issues into %s", len(subset.Keys), *out)
+}
+
+// dirOf returns the directory of a directory cache spec.
+func dirOf(spec string) (string, bool) {
+	kind, target, found := strings.Cut(spec, ":")
+	switch {
+	case !found:
+		return spec, true
+	case kind == "dir":
+		return target, true
+	}
+	return "", false
+}
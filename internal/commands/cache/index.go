@@ -0,0 +1,23 @@
+package cache
+
+import (
+	"flag"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/jira"
+)
+
+// index brings the cache index up to date. Commands that use it update it
+// themselves; this is for warming it after a fetch or rebuilding it.
+func index(args []string) {
+	fs := flag.NewFlagSet("index", flag.ExitOnError)
+	dir := fs.String("dir", "issues", "Cache directory")
+	rebuild := fs.Bool("rebuild", false, "Read every issue again instead of only those changed since the last update")
+	fs.Parse(args)
+
+	x, err := jira.UpdateCacheIndex(*dir, *rebuild)
+	if err != nil {
+		cli.Fatal(err)
+	}
+	cli.Infof("indexed %d issues in %s/%s", len(x.Issues), jira.IndexDir, jira.IndexFile)
+}
@@ -0,0 +1,42 @@
+package cache
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/render"
+)
+
+// tombstones lists the issues a sync found deleted from Jira or moved to
+// another project, whose files it put away below tombstones/.
+func tombstones(args []string) {
+	fs := flag.NewFlagSet("tombstones", flag.ExitOnError)
+	cacheFlags := cli.AddCacheFlags(fs)
+	var renderOpts render.Options
+	render.AddFlags(fs, &renderOpts)
+	fs.Parse(args)
+
+	store, err := cacheFlags.Open()
+	if err != nil {
+		cli.Fatal(err)
+	}
+	defer store.Close()
+	renderOpts.SetSource(store)
+
+	table := render.NewTable("key", "reason", "moved_to", "status", "tombstoned")
+	for _, t := range store.Tombstones(cacheFlags.Project) {
+		status := ""
+		if t.Status != 0 {
+			status = fmt.Sprintf("%d", t.Status)
+		}
+		table.Append(t.Key, t.Reason, t.MovedTo, status, t.Time.Format(time.RFC3339))
+	}
+	if len(table.Rows) == 0 {
+		cli.Fatalf(cli.ExitNoData, "no tombstoned issues; fetch --reconcile looks for them")
+	}
+	if err := renderOpts.Write(table); err != nil {
+		cli.Fatal(err)
+	}
+}
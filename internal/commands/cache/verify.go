@@ -0,0 +1,144 @@
+package cache
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"sort"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/jira"
+)
+
+// verify reads every issue file of a directory cache and reports the empty
+// and corrupt ones, such as those truncated by a fetch killed mid-write.
+// With --refetch the issues they belong to are fetched again.
+func verify(args []string) {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	dir := fs.String("dir", "issues", "Cache directory")
+	workers := fs.Int("workers", runtime.NumCPU(), "Number of parallel reading workers")
+	refetch := fs.Bool("refetch", false, "Fetch the issues of damaged files again and delete temporary files left by interrupted writes")
+	baseURL := fs.String("base-url", cli.BaseURL(), "Jira base URL, with --refetch")
+	wait := fs.Duration("wait", 0, "Wait this long for other processes using the cache to finish instead of failing at once")
+	auth := cli.AddAuthFlags(fs)
+	fs.Parse(args)
+
+	// A fetch in progress would show as damaged files; repairs write.
+	store, err := jira.NewDirStore(*dir)
+	if err != nil {
+		cli.Fatal(err)
+	}
+	if err := store.Lock(*refetch, *wait); err != nil {
+		cli.Fatal(cli.LockError(err))
+	}
+	defer store.Close()
+	problems, err := jira.VerifyCache(*dir, *workers)
+	if err != nil {
+		cli.Fatal(err)
+	}
+	if *refetch && len(problems) > 0 {
+		authenticator, err := auth.Authenticator()
+		if err != nil {
+			cli.Fatal(err)
+		}
+		client := jira.NewClient(*baseURL, "")
+		client.Auth = authenticator
+		store.Compress = cli.Settings().Compress
+		repair(context.Background(), client, store, problems)
+		if err := store.Flush(); err != nil {
+			cli.Fatal(err)
+		}
+		if problems, err = jira.VerifyCache(*dir, *workers); err != nil {
+			cli.Fatal(err)
+		}
+	}
+
+	for _, p := range problems {
+		if p.Detail != "" {
+			fmt.Printf("%s\t%s\t%s\n", p.Kind, p.Name, p.Detail)
+		} else {
+			fmt.Printf("%s\t%s\n", p.Kind, p.Name)
+		}
+	}
+	if len(problems) > 0 {
+		cli.Warnf("%d problems found", len(problems))
+		if !*refetch {
+			cli.Infof("run cache verify --refetch to fetch the damaged issues again")
+		}
+		os.Exit(cli.ExitCacheCorrupt)
+	}
+	cli.Infof("every cache file is readable")
+}
+
+// repair fetches the issues of damaged files again: the issue and its
+// changelog when either is damaged, the comments, worklogs or watchers
+// when they are. Other damaged files are deleted, so readers treat them as
+// missing until the next fetch, as are temporary files.
+func repair(ctx context.Context, client *jira.Client, store *jira.DirStore, problems []jira.CacheProblem) {
+	byKey := map[string][]string{}
+	for _, p := range problems {
+		if p.Kind == jira.CacheTemp {
+			if err := jira.RemoveCacheFile(store.Dir, p.Name); err != nil {
+				cli.Warnf("%s: %v", p.Name, err)
+			}
+			continue
+		}
+		byKey[p.Key] = append(byKey[p.Key], p.Name)
+	}
+	keys := make([]string, 0, len(byKey))
+	for key := range byKey {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		var issue, comments, worklogs, watchers bool
+		for _, name := range byKey[key] {
+			switch jira.TrimCompressedSuffix(name) {
+			case key + ".json", key + ".changelog.json":
+				issue = true
+			case key + ".comments.json":
+				comments = true
+			case key + ".worklogs.json":
+				worklogs = true
+			case key + ".watchers.json":
+				watchers = true
+			default:
+				cli.Infof("%s: deleting %s", key, name)
+				if err := jira.RemoveCacheFile(store.Dir, name); err != nil {
+					cli.Warnf("%s: %v", key, err)
+				}
+			}
+		}
+		if issue {
+			if err := client.SyncIssue(ctx, store, key); err != nil {
+				cli.Warnf("%s: refetch failed: %v", key, err)
+				continue
+			}
+			cli.Infof("%s: refetched", key)
+		}
+		if comments {
+			if _, err := client.SyncComments(ctx, store, key); err != nil {
+				cli.Warnf("%s: comments refetch failed: %v", key, err)
+				continue
+			}
+			cli.Infof("%s: refetched comments", key)
+		}
+		if worklogs {
+			if _, err := client.SyncWorklogs(ctx, store, key); err != nil {
+				cli.Warnf("%s: worklogs refetch failed: %v", key, err)
+				continue
+			}
+			cli.Infof("%s: refetched worklogs", key)
+		}
+		if watchers {
+			if _, err := client.SyncWatchers(ctx, store, key); err != nil {
+				cli.Warnf("%s: watchers refetch failed: %v", key, err)
+				continue
+			}
+			cli.Infof("%s: refetched watchers", key)
+		}
+	}
+}
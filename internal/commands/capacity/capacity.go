@@ -0,0 +1,236 @@
+// Package capacity compares the work assigned to each person in a sprint,
+// as the cache holds it now, with what they can take on according to the
+// capacity section of the config file, and flags who is overallocated.
+package capacity
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/config"
+	"github.com/jctanner/rhoai-jira/internal/jira"
+	"github.com/jctanner/rhoai-jira/internal/render"
+	"github.com/jctanner/rhoai-jira/internal/tools"
+)
+
+// Unassigned names the row of the sprint's unassigned issues.
+const Unassigned = "(unassigned)"
+
+// Load is the share of a sprint assigned to one person against their
+// capacity.
+type Load struct {
+	Assignee  string
+	Capacity  float64
+	Issues    int
+	Open      int
+	Scope     float64
+	Remaining float64
+}
+
+// Over reports whether the person holds more than their capacity of
+// load, which is the scope or, with remaining, the remaining effort.
+func (l Load) Over(remaining bool) bool {
+	return l.Assignee != Unassigned && l.load(remaining) > l.Capacity
+}
+
+func (l Load) load(remaining bool) float64 {
+	if remaining {
+		return l.Remaining
+	}
+	return l.Scope
+}
+
+// Loads totals the issues of a sprint per assignee. Everyone with an
+// explicit capacity is listed, with or without work.
+func Loads(issues []jira.JiraIssueWithSprints, sprint string, effort jira.EffortSource, capacity config.Capacity) []Load {
+	byName := map[string]*Load{}
+	get := func(name string) *Load {
+		if byName[name] == nil {
+			byName[name] = &Load{Assignee: name, Capacity: capacity.Of(name)}
+		}
+		return byName[name]
+	}
+	for name := range capacity.People {
+		get(name)
+	}
+	for _, issue := range issues {
+		if !issue.InSprint(sprint) {
+			continue
+		}
+		name := issue.AssigneeID()
+		if name == "" {
+			name = Unassigned
+		}
+		l := get(name)
+		l.Issues++
+		l.Scope += effort.IssueEffort(issue)
+		l.Remaining += effort.RemainingEffort(issue)
+		if !issue.IsDone() {
+			l.Open++
+		}
+	}
+	if l, ok := byName[Unassigned]; ok {
+		l.Capacity = 0
+	}
+	loads := make([]Load, 0, len(byName))
+	for _, l := range byName {
+		loads = append(loads, *l)
+	}
+	sort.Slice(loads, func(i, j int) bool {
+		if (loads[i].Assignee == Unassigned) != (loads[j].Assignee == Unassigned) {
+			return loads[j].Assignee == Unassigned
+		}
+		return loads[i].Assignee < loads[j].Assignee
+	})
+	return loads
+}
+
+// activeSprint returns the only active sprint of the cached issues.
+func activeSprint(issues []jira.JiraIssueWithSprints) (string, error) {
+	seen := map[string]bool{}
+	var names []string
+	for _, issue := range issues {
+		for _, name := range issue.ActiveSprints() {
+			name = jira.CanonicalSprint(name)
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	switch len(names) {
+	case 0:
+		return "", fmt.Errorf("no cached issue is in an active sprint; pass --sprint")
+	case 1:
+		return names[0], nil
+	}
+	sort.Strings(names)
+	return "", fmt.Errorf("several sprints are active (%s); pass --sprint", strings.Join(names, ", "))
+}
+
+// parseOverrides reads --capacity NAME=VALUE settings over the config.
+func parseOverrides(list []string, capacity config.Capacity) (config.Capacity, error) {
+	people := map[string]float64{}
+	for name, v := range capacity.People {
+		people[name] = v
+	}
+	for _, item := range list {
+		name, value, ok := strings.Cut(item, "=")
+		v, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if !ok || err != nil || v < 0 || strings.TrimSpace(name) == "" {
+			return capacity, fmt.Errorf("invalid --capacity %q (expected NAME=VALUE)", item)
+		}
+		people[strings.TrimSpace(name)] = v
+	}
+	capacity.People = people
+	return capacity, nil
+}
+
+func Main(args []string) {
+	fs := flag.NewFlagSet("capacity", flag.ExitOnError)
+	cacheFlags := cli.AddCacheFlags(fs)
+	sprint := fs.String("sprint", "", "Sprint to plan (default: the active sprint, when there is only one)")
+	effortStr := fs.String("effort", "points", "Effort source, in the unit of the configured capacity (points, time, count)")
+	remaining := fs.Bool("remaining", false, "Compare the remaining effort of open issues with capacity instead of the whole assigned scope")
+	defaultCapacity := fs.Float64("default-capacity", cli.Settings().Capacity.Default, "Capacity of people without their own (default from capacity.default in the config file)")
+	var overrides tools.StringList
+	fs.Var(&overrides, "capacity", "Capacity of one person as NAME=VALUE, over the config file (comma separated or repeated)")
+	fail := fs.Bool("fail", false, "Exit with status 9 when anyone is overallocated")
+	var renderOpts render.Options
+	render.AddFlags(fs, &renderOpts)
+	fs.Parse(args)
+
+	effort, err := jira.ParseEffortSource(*effortStr)
+	if err != nil {
+		cli.Fatal(cli.WithCode(cli.ExitUsage, err))
+	}
+	capacity, err := parseOverrides(overrides, cli.Settings().Capacity)
+	if err != nil {
+		cli.Fatal(cli.WithCode(cli.ExitUsage, err))
+	}
+	capacity.Default = *defaultCapacity
+	if capacity.Default == 0 && len(capacity.People) == 0 {
+		cli.Infof("no capacity configured; set capacity: in the config file or pass --capacity and --default-capacity")
+	}
+
+	store, err := cacheFlags.Open()
+	if err != nil {
+		cli.Fatal(err)
+	}
+	defer store.Close()
+	renderOpts.SetSource(store)
+
+	issues := jira.LoadIssues(store, cacheFlags.Project)
+	if *sprint == "" {
+		if *sprint, err = activeSprint(issues); err != nil {
+			cli.Fatal(cli.WithCode(cli.ExitUsage, err))
+		}
+	}
+	loads := Loads(issues, *sprint, effort, capacity)
+	var totalLoad, totalCapacity, unassigned float64
+	var over []string
+	count, people := 0, 0
+	for _, l := range loads {
+		count += l.Issues
+		if l.Assignee == Unassigned {
+			unassigned = l.load(*remaining)
+			continue
+		}
+		people++
+		totalLoad += l.load(*remaining)
+		totalCapacity += l.Capacity
+		if l.Over(*remaining) {
+			over = append(over, l.Assignee)
+		}
+	}
+	if count == 0 {
+		cli.Fatalf(cli.ExitNoData, "no cached issues are in sprint %q", *sprint)
+	}
+	measure := "scope"
+	if *remaining {
+		measure = "remaining"
+	}
+	cli.Infof("sprint %q: %.1f of %.1f capacity assigned (%s by %s); %d of %d people overallocated, %.1f unassigned",
+		*sprint, totalLoad, totalCapacity, measure, *effortStr, len(over), people, unassigned)
+	renderOpts.Title = fmt.Sprintf("Capacity: %s", *sprint)
+	renderOpts.AddNote("%.1f of %.1f capacity assigned (%s by %s)", totalLoad, totalCapacity, measure, *effortStr)
+	renderOpts.AddNote("%d of %d people overallocated, %.1f unassigned", len(over), people, unassigned)
+
+	table := render.NewTable("assignee", "capacity", "issues", "open_issues", "scope", "remaining", "utilization", "status")
+	table.SetNumeric("capacity", "scope", "remaining")
+	for _, l := range loads {
+		utilization := ""
+		if l.Capacity > 0 {
+			utilization = fmt.Sprintf("%.0f%%", 100*l.load(*remaining)/l.Capacity)
+		}
+		status := "ok"
+		switch {
+		case l.Assignee == Unassigned:
+			status = "unassigned"
+		case l.Over(*remaining):
+			status = "over"
+		case l.load(*remaining) < l.Capacity:
+			status = "under"
+		}
+		table.Append(
+			l.Assignee,
+			fmt.Sprintf("%.1f", l.Capacity),
+			strconv.Itoa(l.Issues),
+			strconv.Itoa(l.Open),
+			fmt.Sprintf("%.1f", l.Scope),
+			fmt.Sprintf("%.1f", l.Remaining),
+			utilization,
+			status,
+		)
+	}
+	if err := renderOpts.Write(table); err != nil {
+		cli.Fatal(err)
+	}
+	if *fail && len(over) > 0 {
+		cli.Fatalf(cli.ExitFindings, "%d people overallocated: %s", len(over), strings.Join(over, ", "))
+	}
+}
@@ -0,0 +1,95 @@
+package classify
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/jira"
+	"github.com/jctanner/rhoai-jira/internal/render"
+	"github.com/jctanner/rhoai-jira/internal/tools"
+)
+
+func Main(args []string) {
+	fs := flag.NewFlagSet("classify", flag.ExitOnError)
+	cacheFlags := cli.AddCacheFlags(fs)
+	rulesPath := fs.String("rules", "", "JSON classification rules file (required)")
+	fieldsConfig := fs.String("fields-config", cli.FieldsConfig(), "JSON file mapping custom fields to named extractors")
+	summary := fs.Bool("summary", false, "Print issue counts per category instead of per issue")
+	var only tools.StringList
+	fs.Var(&only, "category", "Only include issues tagged with this category (repeatable)")
+	var renderOpts render.Options
+	render.AddFlags(fs, &renderOpts)
+	fs.Parse(args)
+
+	store, err := cacheFlags.Open()
+	if err != nil {
+		cli.Fatal(err)
+	}
+	defer store.Close()
+	renderOpts.SetSource(store)
+
+	if *rulesPath == "" {
+		cli.Fatalf(cli.ExitUsage, "--rules must be provided.")
+	}
+	extractors, err := jira.LoadExtractors(*fieldsConfig)
+	if err != nil {
+		cli.Fatal(err)
+	}
+	classifier, err := jira.LoadClassifier(*rulesPath, extractors)
+	if err != nil {
+		cli.Fatal(err)
+	}
+
+	issues := jira.LoadIssues(store, cacheFlags.Project)
+	classifier.Apply(issues)
+
+	wanted := func(categories []string) bool {
+		if len(only) == 0 {
+			return len(categories) > 0
+		}
+		for _, c := range categories {
+			if tools.ItemInList(only, c) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if *summary {
+		counts := map[string]int{}
+		for _, issue := range issues {
+			for _, c := range issue.Fields.Categories {
+				if len(only) == 0 || tools.ItemInList(only, c) {
+					counts[c]++
+				}
+			}
+		}
+		var categories []string
+		for c := range counts {
+			categories = append(categories, c)
+		}
+		sort.Strings(categories)
+		table := render.NewTable("category", "issues")
+		for _, c := range categories {
+			table.Append(c, fmt.Sprintf("%d", counts[c]))
+		}
+		if err := renderOpts.Write(table); err != nil {
+			cli.Fatal(err)
+		}
+		return
+	}
+
+	table := render.NewTable("key", "type", "status", "categories", "summary")
+	for _, issue := range issues {
+		if !wanted(issue.Fields.Categories) {
+			continue
+		}
+		table.Append(issue.Key, issue.Fields.IssueType.Name, issue.Fields.Status.Name, strings.Join(issue.Fields.Categories, ","), issue.Fields.Summary)
+	}
+	if err := renderOpts.Write(table); err != nil {
+		cli.Fatal(err)
+	}
+}
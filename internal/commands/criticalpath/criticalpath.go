@@ -0,0 +1,204 @@
+package criticalpath
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/jira"
+	"github.com/jctanner/rhoai-jira/internal/render"
+)
+
+type PathNode struct {
+	Key       string
+	Summary   string
+	Status    string
+	Assignee  string
+	Remaining float64
+	Cached    bool
+	Done      bool
+}
+
+type Graph struct {
+	Nodes    map[string]*PathNode
+	Blockers map[string][]string
+}
+
+func buildGraph(issues []jira.JiraIssueWithSprints, effort jira.EffortSource) *Graph {
+	g := &Graph{
+		Nodes:    make(map[string]*PathNode),
+		Blockers: make(map[string][]string),
+	}
+
+	for _, issue := range issues {
+		g.Nodes[issue.Key] = &PathNode{
+			Key:       issue.Key,
+			Summary:   issue.Fields.Summary,
+			Status:    issue.Fields.Status.Name,
+			Assignee:  issue.AssigneeID(),
+			Remaining: effort.RemainingEffort(issue),
+			Cached:    true,
+			Done:      issue.IsDone(),
+		}
+	}
+
+	addEdge := func(blocker jira.LinkedIssue, blocked string) {
+		if _, ok := g.Nodes[blocker.Key]; !ok {
+			// Not mirrored locally; trust the status embedded in the link
+			g.Nodes[blocker.Key] = &PathNode{
+				Key:     blocker.Key,
+				Summary: blocker.Fields.Summary,
+				Status:  blocker.Fields.Status.Name,
+				Done:    blocker.Fields.Status.IsDone(),
+			}
+		}
+		for _, existing := range g.Blockers[blocked] {
+			if existing == blocker.Key {
+				return
+			}
+		}
+		g.Blockers[blocked] = append(g.Blockers[blocked], blocker.Key)
+	}
+
+	for _, issue := range issues {
+		for _, blocker := range issue.Blockers() {
+			addEdge(blocker, issue.Key)
+		}
+		// Links are usually only present on one side when the other issue
+		// has not been fetched recently, so record both directions.
+		for _, blocked := range issue.Blocks() {
+			var self jira.LinkedIssue
+			self.Key = issue.Key
+			self.Fields.Summary = issue.Fields.Summary
+			self.Fields.Status = issue.Fields.Status
+			addEdge(self, blocked.Key)
+		}
+	}
+
+	return g
+}
+
+// longestPaths computes, for every open issue, the heaviest chain of open
+// blockers ending at that issue. Cycles are broken at the first revisit.
+func (g *Graph) longestPaths() (map[string]float64, map[string]string) {
+	dist := make(map[string]float64)
+	prev := make(map[string]string)
+	state := make(map[string]int) // 0=unvisited 1=visiting 2=done
+
+	var visit func(key string) float64
+	visit = func(key string) float64 {
+		switch state[key] {
+		case 1:
+			cli.Warnf("dependency cycle detected at %s", key)
+			return 0
+		case 2:
+			return dist[key]
+		}
+		state[key] = 1
+
+		node := g.Nodes[key]
+		best := 0.0
+		bestKey := ""
+		for _, blocker := range g.Blockers[key] {
+			if g.Nodes[blocker] == nil || g.Nodes[blocker].Done {
+				continue
+			}
+			d := visit(blocker)
+			if d > best || (d == best && bestKey == "") {
+				best = d
+				bestKey = blocker
+			}
+		}
+
+		dist[key] = node.Remaining + best
+		if bestKey != "" {
+			prev[key] = bestKey
+		}
+		state[key] = 2
+		return dist[key]
+	}
+
+	keys := make([]string, 0, len(g.Nodes))
+	for key := range g.Nodes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if !g.Nodes[key].Done {
+			visit(key)
+		}
+	}
+	return dist, prev
+}
+
+func Main(args []string) {
+	fs := flag.NewFlagSet("criticalpath", flag.ExitOnError)
+	cacheFlags := cli.AddCacheFlags(fs)
+	epic := fs.String("epic", "", "Target epic key")
+	fixVersion := fs.String("fix-version", "", "Target fix version name")
+	effortStr := fs.String("effort", "time", "Effort source used to weigh remaining work (points, time, count)")
+	var renderOpts render.Options
+	render.AddFlags(fs, &renderOpts)
+	fs.Parse(args)
+
+	store, err := cacheFlags.Open()
+	if err != nil {
+		cli.Fatal(err)
+	}
+	defer store.Close()
+	renderOpts.SetSource(store)
+
+	if (*epic == "") == (*fixVersion == "") {
+		cli.Fatalf(cli.ExitUsage, "Exactly one of --epic or --fix-version must be provided.")
+	}
+	effort, err := jira.ParseEffortSource(*effortStr)
+	if err != nil {
+		cli.Fatal(err)
+	}
+
+	issues := jira.LoadLinked(store, jira.LoadIssues(store, cacheFlags.Project))
+	graph := buildGraph(issues, effort)
+	dist, prev := graph.longestPaths()
+
+	var target string
+	for _, issue := range issues {
+		inTarget := (*epic != "" && issue.EpicKey() == *epic) || (*fixVersion != "" && issue.HasFixVersion(*fixVersion))
+		if !inTarget || issue.IsDone() {
+			continue
+		}
+		if target == "" || dist[issue.Key] > dist[target] || (dist[issue.Key] == dist[target] && issue.Key < target) {
+			target = issue.Key
+		}
+	}
+	if target == "" {
+		cli.Infof("no open issues found for the target")
+		return
+	}
+
+	var chain []string
+	for key := target; key != ""; key = prev[key] {
+		chain = append([]string{key}, chain...)
+	}
+
+	table := render.NewTable("step", "key", "status", "assignee", "remaining", "cumulative", "cached", "summary")
+	table.SetNumeric("remaining", "cumulative")
+	cumulative := 0.0
+	for i, key := range chain {
+		node := graph.Nodes[key]
+		cumulative += node.Remaining
+		table.Append(
+			fmt.Sprintf("%d", i+1),
+			node.Key,
+			node.Status,
+			node.Assignee,
+			fmt.Sprintf("%.1f", node.Remaining),
+			fmt.Sprintf("%.1f", cumulative),
+			fmt.Sprintf("%t", node.Cached),
+			node.Summary,
+		)
+	}
+	if err := renderOpts.Write(table); err != nil {
+		cli.Fatal(err)
+	}
+}
@@ -0,0 +1,154 @@
+package cve
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/jira"
+	"github.com/jctanner/rhoai-jira/internal/render"
+)
+
+func parseSLA(spec string) (map[string]int, error) {
+	sla := make(map[string]int)
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, days, ok := strings.Cut(part, "=")
+		n, err := strconv.Atoi(strings.TrimSpace(days))
+		if !ok || err != nil {
+			return nil, fmt.Errorf("invalid SLA entry %q (expected Severity=days)", part)
+		}
+		sla[strings.ToLower(strings.TrimSpace(name))] = n
+	}
+	return sla, nil
+}
+
+type SeverityTotals struct {
+	Severity   string
+	Open       int
+	Breached   int
+	OldestDays int
+}
+
+func Main(args []string) {
+	fs := flag.NewFlagSet("cve", flag.ExitOnError)
+	cacheFlags := cli.AddCacheFlags(fs)
+	fieldsConfig := fs.String("fields-config", cli.FieldsConfig(), "JSON file mapping custom fields to named extractors")
+	severityField := fs.String("severity-field", "priority", "Extracted field holding the severity")
+	slaSpec := fs.String("sla", "Blocker=7,Critical=14,Major=60,Normal=90,Minor=180", "Days allowed to fix per severity")
+	includeResolved := fs.Bool("include-resolved", false, "Include resolved security issues")
+	summary := fs.Bool("summary", false, "Print totals per severity instead of per issue")
+	var renderOpts render.Options
+	render.AddFlags(fs, &renderOpts)
+	fs.Parse(args)
+
+	store, err := cacheFlags.Open()
+	if err != nil {
+		cli.Fatal(err)
+	}
+	defer store.Close()
+	renderOpts.SetSource(store)
+
+	sla, err := parseSLA(*slaSpec)
+	if err != nil {
+		cli.Fatal(err)
+	}
+	extractors, err := jira.LoadExtractors(*fieldsConfig)
+	if err != nil {
+		cli.Fatal(err)
+	}
+	severityExtractor, ok := extractors.Lookup(*severityField)
+	if !ok {
+		cli.Fatalf(cli.ExitUsage, "unknown --severity-field %q", *severityField)
+	}
+
+	now := time.Now()
+	totals := make(map[string]*SeverityTotals)
+	table := render.NewTable("key", "cves", "severity", "status", "age_days", "sla_days", "breached", "fix_versions", "assignee", "summary")
+	for _, issue := range jira.LoadIssues(store, cacheFlags.Project) {
+		cves := jira.FindCVEs(issue)
+		if len(cves) == 0 {
+			continue
+		}
+		if issue.IsDone() && !*includeResolved {
+			continue
+		}
+
+		severity := "Undefined"
+		if value, ok := severityExtractor.Extract(issue.Fields); ok {
+			severity = value.Text
+		}
+
+		created, err := issue.CreatedTime()
+		if err != nil {
+			cli.Warnf("could not parse created time for %s: %v", issue.Key, err)
+			continue
+		}
+		end := now
+		if resolved, err := jira.ParseJiraTime(issue.Fields.ResolutionDate); err == nil {
+			end = resolved
+		}
+		age := int(end.Sub(created).Hours() / 24)
+
+		slaDays, hasSLA := sla[strings.ToLower(severity)]
+		breached := hasSLA && age > slaDays
+		slaText := ""
+		if hasSLA {
+			slaText = fmt.Sprintf("%d", slaDays)
+		}
+
+		var versions []string
+		for _, v := range issue.Fields.FixVersions {
+			versions = append(versions, v.Name)
+		}
+
+		table.Append(
+			issue.Key,
+			strings.Join(cves, " "),
+			severity,
+			issue.Fields.Status.Name,
+			fmt.Sprintf("%d", age),
+			slaText,
+			fmt.Sprintf("%t", breached),
+			strings.Join(versions, ","),
+			issue.AssigneeID(),
+			issue.Fields.Summary,
+		)
+
+		t, ok := totals[severity]
+		if !ok {
+			t = &SeverityTotals{Severity: severity}
+			totals[severity] = t
+		}
+		t.Open++
+		if breached {
+			t.Breached++
+		}
+		if age > t.OldestDays {
+			t.OldestDays = age
+		}
+	}
+
+	if *summary {
+		var rows []*SeverityTotals
+		for _, t := range totals {
+			rows = append(rows, t)
+		}
+		sort.Slice(rows, func(i, j int) bool { return rows[i].Severity < rows[j].Severity })
+		table = render.NewTable("severity", "issues", "breached", "oldest_days")
+		for _, t := range rows {
+			table.Append(t.Severity, fmt.Sprintf("%d", t.Open), fmt.Sprintf("%d", t.Breached), fmt.Sprintf("%d", t.OldestDays))
+		}
+	}
+
+	if err := renderOpts.Write(table); err != nil {
+		cli.Fatal(err)
+	}
+}
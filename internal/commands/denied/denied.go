@@ -0,0 +1,213 @@
+package denied
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/jira"
+	"github.com/jctanner/rhoai-jira/internal/render"
+)
+
+type GroupCounts struct {
+	Group    string
+	Order    int
+	Cached   int
+	Denied   int
+	EraStart time.Time
+	EraEnd   time.Time
+}
+
+func (g *GroupCounts) observe(t time.Time) {
+	if t.IsZero() {
+		return
+	}
+	if g.EraStart.IsZero() || t.Before(g.EraStart) {
+		g.EraStart = t
+	}
+	if t.After(g.EraEnd) {
+		g.EraEnd = t
+	}
+}
+
+func keyNumber(key string) int {
+	_, num, _ := strings.Cut(key, "-")
+	n, _ := strconv.Atoi(num)
+	return n
+}
+
+func quarter(t time.Time) string {
+	if t.IsZero() {
+		return "unknown"
+	}
+	return fmt.Sprintf("%d-Q%d", t.Year(), (int(t.Month())-1)/3+1)
+}
+
+// inferCreated estimates when a denied issue was created from the nearest
+// cached issue numbers around it; keys are allocated sequentially.
+func inferCreated(number int, cachedNumbers []int, created map[int]time.Time) time.Time {
+	idx := sort.SearchInts(cachedNumbers, number)
+	var lower, upper time.Time
+	if idx > 0 {
+		lower = created[cachedNumbers[idx-1]]
+	}
+	if idx < len(cachedNumbers) {
+		upper = created[cachedNumbers[idx]]
+	}
+	switch {
+	case lower.IsZero():
+		return upper
+	case upper.IsZero():
+		return lower
+	}
+	return lower.Add(upper.Sub(lower) / 2)
+}
+
+func sampleKeys(keys []string, n int) []string {
+	if n <= 0 || len(keys) == 0 {
+		return nil
+	}
+	if n >= len(keys) {
+		return keys
+	}
+	var sample []string
+	step := float64(len(keys)) / float64(n)
+	for i := 0; i < n; i++ {
+		sample = append(sample, keys[int(float64(i)*step)])
+	}
+	return sample
+}
+
+func Main(args []string) {
+	fs := flag.NewFlagSet("denied", flag.ExitOnError)
+	cacheFlags := cli.AddCacheFlags(fs)
+	groupBy := fs.String("group-by", "range", "Group denied issues by number range or creation era (range, era)")
+	rangeSize := fs.Int("range-size", 1000, "Issue numbers per range when grouping by range")
+	retrySample := fs.Int("retry-sample", 0, "Retry this many denied issues with --token to see if they are readable")
+	token := fs.String("token", "", "Alternate Jira token for --retry-sample (or JIRA_ALT_TOKEN env var)")
+	baseURL := fs.String("base-url", cli.BaseURL(), "Base URL used for --retry-sample")
+	var renderOpts render.Options
+	render.AddFlags(fs, &renderOpts)
+	fs.Parse(args)
+
+	store, err := cacheFlags.Open()
+	if err != nil {
+		cli.Fatal(err)
+	}
+	defer store.Close()
+	renderOpts.SetSource(store)
+
+	if cacheFlags.Project == "" {
+		cli.Fatalf(cli.ExitUsage, "--project must be provided.")
+	}
+	if *groupBy != "range" && *groupBy != "era" {
+		cli.Fatalf(cli.ExitUsage, "invalid --group-by %q (expected range or era)", *groupBy)
+	}
+	if *rangeSize <= 0 {
+		cli.Fatalf(cli.ExitUsage, "--range-size must be positive")
+	}
+
+	created := make(map[int]time.Time)
+	var cachedNumbers []int
+	for _, issue := range jira.LoadIssues(store, cacheFlags.Project) {
+		n := keyNumber(issue.Key)
+		cachedNumbers = append(cachedNumbers, n)
+		if t, err := issue.CreatedTime(); err == nil {
+			created[n] = t
+		}
+	}
+	sort.Ints(cachedNumbers)
+
+	deniedKeys := store.DeniedKeys(cacheFlags.Project)
+	sort.Slice(deniedKeys, func(i, j int) bool { return keyNumber(deniedKeys[i]) < keyNumber(deniedKeys[j]) })
+
+	groups := make(map[string]*GroupCounts)
+	group := func(number int, era time.Time) *GroupCounts {
+		name, order := quarter(era), 0
+		if *groupBy == "range" {
+			start := (number / *rangeSize) * *rangeSize
+			name, order = fmt.Sprintf("%d-%d", start, start+*rangeSize-1), start
+		} else if !era.IsZero() {
+			order = era.Year()*10 + (int(era.Month())-1)/3
+		}
+		g, ok := groups[name]
+		if !ok {
+			g = &GroupCounts{Group: name, Order: order}
+			groups[name] = g
+		}
+		return g
+	}
+
+	for _, n := range cachedNumbers {
+		g := group(n, created[n])
+		g.Cached++
+		g.observe(created[n])
+	}
+	for _, key := range deniedKeys {
+		n := keyNumber(key)
+		era := inferCreated(n, cachedNumbers, created)
+		g := group(n, era)
+		g.Denied++
+		g.observe(era)
+	}
+
+	var rows []*GroupCounts
+	for _, g := range groups {
+		rows = append(rows, g)
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].Order < rows[j].Order })
+
+	table := render.NewTable(*groupBy, "cached", "denied", "denied_pct", "era_start", "era_end")
+	table.SetNumeric("denied_pct")
+	for _, g := range rows {
+		pct := 0.0
+		if total := g.Cached + g.Denied; total > 0 {
+			pct = 100 * float64(g.Denied) / float64(total)
+		}
+		eraStart, eraEnd := "", ""
+		if !g.EraStart.IsZero() {
+			eraStart = g.EraStart.Format("2006-01-02")
+			eraEnd = g.EraEnd.Format("2006-01-02")
+		}
+		table.Append(g.Group, fmt.Sprintf("%d", g.Cached), fmt.Sprintf("%d", g.Denied), fmt.Sprintf("%.1f", pct), eraStart, eraEnd)
+	}
+	if err := renderOpts.Write(table); err != nil {
+		cli.Fatal(err)
+	}
+
+	total := len(cachedNumbers) + len(deniedKeys)
+	if total > 0 {
+		cli.Infof("denied %d of %d known issues (%.1f%%)", len(deniedKeys), total, 100*float64(len(deniedKeys))/float64(total))
+	}
+
+	if *retrySample > 0 {
+		if *token == "" {
+			*token = os.Getenv("JIRA_ALT_TOKEN")
+		}
+		if *token == "" {
+			cli.Fatalf(cli.ExitUsage, "--retry-sample needs an alternate token via --token or JIRA_ALT_TOKEN.")
+		}
+		client := jira.NewClient(*baseURL, *token)
+		readable := 0
+		sample := sampleKeys(deniedKeys, *retrySample)
+		for _, key := range sample {
+			_, _, err := client.FetchIssueWithChangelog(context.Background(), key)
+			switch {
+			case err == nil:
+				readable++
+				cli.Infof("%s: readable with alternate token", key)
+			case jira.IsStatus(err, 403):
+				cli.Infof("%s: still denied", key)
+			default:
+				cli.Warnf("%s: %v", key, err)
+			}
+		}
+		cli.Infof("alternate token can read %d of %d sampled denied issues", readable, len(sample))
+	}
+}
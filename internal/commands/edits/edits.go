@@ -0,0 +1,104 @@
+package edits
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/jira"
+	"github.com/jctanner/rhoai-jira/internal/render"
+	"github.com/jctanner/rhoai-jira/internal/tools"
+)
+
+var textFields = []string{"summary", "description"}
+
+func Main(args []string) {
+	fs := flag.NewFlagSet("show-edits", flag.ExitOnError)
+	cacheFlags := cli.AddCacheFlags(fs)
+	field := fs.String("field", "", "Only show edits to this field (summary or description)")
+	context := fs.Int("context", 3, "Lines of context around each change")
+	var snapshots tools.StringList
+	fs.Var(&snapshots, "snapshots", "Snapshot directory written by rollforward to include as a version (repeatable)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: %s [flags] KEY\n\n", fs.Name())
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		cli.Fatalf(cli.ExitUsage, "exactly one issue key must be provided.")
+	}
+	key := strings.ToUpper(fs.Arg(0))
+	fields := textFields
+	if *field != "" {
+		fields = []string{*field}
+	}
+
+	store, err := cacheFlags.Open()
+	if err != nil {
+		cli.Fatal(err)
+	}
+	defer store.Close()
+
+	var current *jira.JiraIssueWithSprints
+	issue, err := store.ReadIssue(key)
+	if errors.Is(err, jira.ErrCorruptCache) {
+		cli.Fatal(err)
+	}
+	if err == nil {
+		current = &issue
+	}
+	changelog, err := store.ReadChangelog(key)
+	if err != nil && current == nil {
+		cli.Fatalf(cli.ExitNoData, "%s is not in the cache", key)
+	}
+	if len(changelog.PersistedFields) > 0 {
+		cli.Warnf("changelog of %s only kept %s; edits to other fields are not recorded", key, strings.Join(changelog.PersistedFields, ", "))
+	}
+
+	var snapshotIssues []jira.JiraIssueWithSprints
+	for _, dir := range snapshots {
+		if issue, err := jira.GetIssueFromCache(dir, key); err == nil {
+			snapshotIssues = append(snapshotIssues, issue)
+		}
+	}
+
+	for _, f := range fields {
+		var extra []jira.TextVersion
+		for i, snap := range snapshotIssues {
+			at, err := snap.UpdatedTime()
+			if err != nil {
+				continue
+			}
+			text := snap.Fields.Summary
+			if f == "description" {
+				text = snap.Fields.Description
+			}
+			extra = append(extra, jira.TextVersion{At: at, Text: text, Source: "snapshot " + snapshots[i]})
+		}
+
+		versions := jira.TextHistory(current, changelog, f, extra)
+		if len(versions) <= 1 {
+			fmt.Printf("%s %s: no edits recorded\n\n", key, f)
+			continue
+		}
+		fmt.Printf("%s %s: %d edit(s)\n\n", key, f, len(versions)-1)
+		for i := 1; i < len(versions); i++ {
+			prev, next := versions[i-1], versions[i]
+			by := next.Author
+			if by == "" {
+				by = next.Source
+			}
+			fmt.Printf("%s edited by %s\n", next.At.Format("2006-01-02 15:04"), by)
+			fmt.Print(render.UnifiedDiff(
+				fmt.Sprintf("%s/%s\t%s", key, f, prev.At.Format("2006-01-02 15:04")),
+				fmt.Sprintf("%s/%s\t%s", key, f, next.At.Format("2006-01-02 15:04")),
+				prev.Text, next.Text, *context,
+			))
+			fmt.Println()
+		}
+	}
+}
@@ -0,0 +1,210 @@
+// Package epics rolls the cached children of every epic up into one row:
+// how many there are and are done, their effort, statuses and the span of
+// activity on them.
+package epics
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/jira"
+	"github.com/jctanner/rhoai-jira/internal/render"
+	"github.com/jctanner/rhoai-jira/internal/tools"
+)
+
+// Rollup is the progress of one epic.
+type Rollup struct {
+	Key     string
+	Summary string
+	Status  string
+	// Cached is false for epics only known from the Epic Link of a child.
+	Cached   bool
+	Children int
+	Done     int
+	Effort   float64
+	// DoneEffort is the effort of the children that are done.
+	DoneEffort float64
+	// Statuses counts the children in each status.
+	Statuses map[string]int
+	// FirstActivity and LastActivity are the earliest creation and the
+	// latest update among the epic and its children.
+	FirstActivity time.Time
+	LastActivity  time.Time
+}
+
+// Progress is the share of the effort done, or of the children when none
+// is estimated.
+func (r Rollup) Progress() float64 {
+	if r.Effort > 0 {
+		return r.DoneEffort / r.Effort
+	}
+	if r.Children > 0 {
+		return float64(r.Done) / float64(r.Children)
+	}
+	return 0
+}
+
+// StatusSummary lists the statuses of the children, most common first.
+func (r Rollup) StatusSummary() string {
+	names := make([]string, 0, len(r.Statuses))
+	for name := range r.Statuses {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if r.Statuses[names[i]] != r.Statuses[names[j]] {
+			return r.Statuses[names[i]] > r.Statuses[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf("%s %d", name, r.Statuses[name])
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (r *Rollup) observe(issue jira.JiraIssueWithSprints) {
+	if created, err := issue.CreatedTime(); err == nil && (r.FirstActivity.IsZero() || created.Before(r.FirstActivity)) {
+		r.FirstActivity = created
+	}
+	if updated, err := issue.UpdatedTime(); err == nil && updated.After(r.LastActivity) {
+		r.LastActivity = updated
+	}
+}
+
+// Rollups rolls up the children of every epic among issues. An issue is a
+// child of an epic through its Epic Link, or through its parent when the
+// parent is a cached epic; epics missing from the cache but named by an
+// Epic Link get a row of their own.
+func Rollups(issues []jira.JiraIssueWithSprints, effort jira.EffortSource) []Rollup {
+	byKey := map[string]*Rollup{}
+	for _, issue := range issues {
+		if issue.Fields.IssueType.Name != "Epic" {
+			continue
+		}
+		r := &Rollup{Key: issue.Key, Summary: issue.Fields.Summary, Status: issue.Fields.Status.Name, Cached: true, Statuses: map[string]int{}}
+		r.observe(issue)
+		byKey[issue.Key] = r
+	}
+	for _, issue := range issues {
+		if issue.Fields.IssueType.Name == "Epic" {
+			continue
+		}
+		epic := issue.Fields.EpicLink
+		if epic == "" && byKey[issue.Fields.Parent.Key] != nil {
+			epic = issue.Fields.Parent.Key
+		}
+		if epic == "" {
+			continue
+		}
+		r := byKey[epic]
+		if r == nil {
+			r = &Rollup{Key: epic, Statuses: map[string]int{}}
+			byKey[epic] = r
+		}
+		e := effort.IssueEffort(issue)
+		r.Children++
+		r.Effort += e
+		if issue.IsDone() {
+			r.Done++
+			r.DoneEffort += e
+		}
+		r.Statuses[issue.Fields.Status.Name]++
+		r.observe(issue)
+	}
+	rollups := make([]Rollup, 0, len(byKey))
+	for _, r := range byKey {
+		rollups = append(rollups, *r)
+	}
+	sort.Slice(rollups, func(i, j int) bool {
+		return jira.LessKey(rollups[i].Key, rollups[j].Key)
+	})
+	return rollups
+}
+
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format("2006-01-02")
+}
+
+func Main(args []string) {
+	fs := flag.NewFlagSet("epics", flag.ExitOnError)
+	cacheFlags := cli.AddCacheFlags(fs)
+	effortStr := fs.String("effort", "points", "Effort source (points, time, count)")
+	var only tools.StringList
+	fs.Var(&only, "epic", "Only these epics (comma separated or repeated)")
+	open := fs.Bool("open", false, "Leave out epics that are done")
+	empty := fs.Bool("empty", false, "Include epics without cached children")
+	var renderOpts render.Options
+	render.AddFlags(fs, &renderOpts)
+	fs.Parse(args)
+
+	effort, err := jira.ParseEffortSource(*effortStr)
+	if err != nil {
+		cli.Fatal(cli.WithCode(cli.ExitUsage, err))
+	}
+	store, err := cacheFlags.Open()
+	if err != nil {
+		cli.Fatal(err)
+	}
+	defer store.Close()
+	renderOpts.SetSource(store)
+
+	issues := jira.LoadIssues(store, cacheFlags.Project)
+	doneEpics := map[string]bool{}
+	for _, issue := range issues {
+		if issue.Fields.IssueType.Name == "Epic" && issue.IsDone() {
+			doneEpics[issue.Key] = true
+		}
+	}
+	wanted := map[string]bool{}
+	for _, key := range only {
+		wanted[strings.ToUpper(key)] = true
+	}
+
+	var rollups []Rollup
+	for _, r := range Rollups(issues, effort) {
+		switch {
+		case len(wanted) > 0 && !wanted[r.Key]:
+		case *open && doneEpics[r.Key]:
+		case !*empty && r.Children == 0:
+		default:
+			rollups = append(rollups, r)
+		}
+	}
+	if len(rollups) == 0 {
+		cli.Fatalf(cli.ExitNoData, "no epics with children in the cache")
+	}
+
+	column := effort.ColumnName()
+	table := render.NewTable("epic", "summary", "status", "children", "done", column, column+"_done", "progress", "statuses", "first_activity", "last_activity")
+	table.SetNumeric(column, column+"_done", "progress")
+	for _, r := range rollups {
+		summary := r.Summary
+		if !r.Cached {
+			summary = "(not cached)"
+		}
+		table.Append(
+			r.Key,
+			summary,
+			r.Status,
+			fmt.Sprintf("%d", r.Children),
+			fmt.Sprintf("%d", r.Done),
+			fmt.Sprintf("%.1f", r.Effort),
+			fmt.Sprintf("%.1f", r.DoneEffort),
+			fmt.Sprintf("%.2f", r.Progress()),
+			r.StatusSummary(),
+			formatDate(r.FirstActivity),
+			formatDate(r.LastActivity),
+		)
+	}
+	if err := renderOpts.Write(table); err != nil {
+		cli.Fatal(err)
+	}
+}
@@ -0,0 +1,72 @@
+package escalations
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/jira"
+	"github.com/jctanner/rhoai-jira/internal/render"
+)
+
+func Main(args []string) {
+	fs := flag.NewFlagSet("escalations", flag.ExitOnError)
+	cacheFlags := cli.AddCacheFlags(fs)
+	staleDays := fs.Int("stale-days", 7, "Flag blockers not updated for this many days")
+	var renderOpts render.Options
+	render.AddFlags(fs, &renderOpts)
+	fs.Parse(args)
+
+	store, err := cacheFlags.Open()
+	if err != nil {
+		cli.Fatal(err)
+	}
+	defer store.Close()
+	renderOpts.SetSource(store)
+
+	// Blockers can live in other projects, so the whole cache is read and
+	// --project narrows the blocked issues afterwards.
+	issues := jira.LoadLinked(store, jira.LoadIssues(store, ""))
+	escalations, uncached := jira.FindEscalations(issues, time.Duration(*staleDays)*24*time.Hour, time.Now())
+	if uncached > 0 {
+		cli.Infof("%d blockers of active sprint work are not in the cache and were skipped", uncached)
+	}
+
+	table := render.NewTable("rank", "key", "summary", "status", "assignee", "days_stale", "blocked", "sprints", "priority", "score")
+	rank := 0
+	for _, e := range escalations {
+		if cacheFlags.Project != "" && !blocksProject(e, cacheFlags.Project) {
+			continue
+		}
+		rank++
+		table.Append(
+			strconv.Itoa(rank),
+			e.Key,
+			e.Summary,
+			e.Status,
+			e.Assignee,
+			strconv.Itoa(e.DaysStale),
+			strings.Join(e.Blocked, " "),
+			strings.Join(e.Sprints, "; "),
+			e.Priority,
+			fmt.Sprintf("%.0f", e.Score),
+		)
+	}
+	cli.Infof("%d stale blockers of active sprint work", rank)
+	if err := renderOpts.Write(table); err != nil {
+		cli.Fatal(err)
+	}
+}
+
+func blocksProject(e jira.Escalation, project string) bool {
+	prefix := strings.ToUpper(project) + "-"
+	for _, key := range e.Blocked {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,128 @@
+package estimates
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/jira"
+	"github.com/jctanner/rhoai-jira/internal/render"
+)
+
+type EstimateTotals struct {
+	Group         string
+	Issues        int
+	EstimateHours float64
+	LoggedHours   float64
+}
+
+func (t EstimateTotals) Ratio() float64 {
+	if t.EstimateHours == 0 {
+		return 0
+	}
+	return t.LoggedHours / t.EstimateHours
+}
+
+func groupFor(issue jira.JiraIssueWithSprints, groupBy string) string {
+	switch groupBy {
+	case "epic":
+		if epic := issue.EpicKey(); epic != "" {
+			return epic
+		}
+		return "(no epic)"
+	case "assignee":
+		if assignee := issue.AssigneeID(); assignee != "" {
+			return assignee
+		}
+		return "(unassigned)"
+	default:
+		return issue.Key
+	}
+}
+
+// loggedHours prefers the cached worklogs and falls back to the timespent field.
+func loggedHours(dir string, issue jira.JiraIssueWithSprints) float64 {
+	if worklogs, err := jira.GetIssueWorklogsFromCache(dir, issue.Key); err == nil {
+		return worklogs.TotalHours()
+	}
+	if issue.Fields.TimeSpent != nil {
+		return float64(*issue.Fields.TimeSpent) / 3600
+	}
+	return 0
+}
+
+func Main(args []string) {
+	fs := flag.NewFlagSet("estimates", flag.ExitOnError)
+	dir := fs.String("dir", "issues", "Directory containing cached issues")
+	project := fs.String("project", "", "Filter on a specific project")
+	groupBy := fs.String("group-by", "issue", "Group results by issue, epic or assignee")
+	threshold := fs.Float64("threshold", 1.5, "Logged/estimate ratio at or above which a group is flagged as underestimated")
+	minIssues := fs.Int("min-issues", 3, "Minimum estimated issues in a group before it can be flagged")
+	var renderOpts render.Options
+	render.AddFlags(fs, &renderOpts)
+	fs.Parse(args)
+
+	if *groupBy != "issue" && *groupBy != "epic" && *groupBy != "assignee" {
+		cli.Fatalf(cli.ExitUsage, "invalid --group-by %q (expected issue, epic or assignee)", *groupBy)
+	}
+
+	totals := make(map[string]*EstimateTotals)
+	version, _ := jira.CacheVersion(*dir)
+	renderOpts.SetCacheVersion(version)
+	for _, issue := range jira.LoadCachedIssues(*dir, *project) {
+		estimate := 0.0
+		if issue.Fields.TimeOriginalEstimate != nil {
+			estimate = float64(*issue.Fields.TimeOriginalEstimate) / 3600
+		}
+		logged := loggedHours(*dir, issue)
+		if estimate == 0 && logged == 0 {
+			continue
+		}
+
+		group := groupFor(issue, *groupBy)
+		t, ok := totals[group]
+		if !ok {
+			t = &EstimateTotals{Group: group}
+			totals[group] = t
+		}
+		t.Issues++
+		t.EstimateHours += estimate
+		t.LoggedHours += logged
+	}
+
+	var rows []*EstimateTotals
+	for _, t := range totals {
+		rows = append(rows, t)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Ratio() == rows[j].Ratio() {
+			return rows[i].Group < rows[j].Group
+		}
+		return rows[i].Ratio() > rows[j].Ratio()
+	})
+
+	table := render.NewTable(*groupBy, "issues", "estimate_hours", "logged_hours", "ratio", "flag")
+	table.SetNumeric("estimate_hours", "logged_hours", "ratio")
+	for _, t := range rows {
+		flagged := ""
+		minCount := *minIssues
+		if *groupBy == "issue" {
+			minCount = 1
+		}
+		if t.EstimateHours > 0 && t.Issues >= minCount && t.Ratio() >= *threshold {
+			flagged = "underestimated"
+		}
+		table.Append(
+			t.Group,
+			fmt.Sprintf("%d", t.Issues),
+			fmt.Sprintf("%.1f", t.EstimateHours),
+			fmt.Sprintf("%.1f", t.LoggedHours),
+			fmt.Sprintf("%.2f", t.Ratio()),
+			flagged,
+		)
+	}
+	if err := renderOpts.Write(table); err != nil {
+		cli.Fatal(err)
+	}
+}
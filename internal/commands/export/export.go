@@ -0,0 +1,349 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/jira"
+	"github.com/jctanner/rhoai-jira/internal/parquet"
+	"github.com/jctanner/rhoai-jira/internal/query"
+	"github.com/jctanner/rhoai-jira/internal/render"
+	"github.com/jctanner/rhoai-jira/internal/tools"
+)
+
+func Main(args []string) {
+	kind := "issues"
+	if len(args) > 0 && (args[0] == "issues" || args[0] == "events") {
+		kind, args = args[0], args[1:]
+	}
+
+	fs := flag.NewFlagSet("export "+kind, flag.ExitOnError)
+	cacheFlags := cli.AddCacheFlags(fs)
+	format := fs.String("format", "ndjson", "Output format: ndjson (one record per line), json (a single array) or parquet (typed columns; --compress sets the page codec)")
+	since := fs.String("since", "", "Only issues updated, or events made, on or after this date (2025-01-31 or -7d)")
+	fieldsConfig := fs.String("fields-config", cli.FieldsConfig(), "JSON file mapping custom fields to named extractors, exported as extra issue fields")
+	var fields tools.StringList
+	rowGroupRows := fs.Int("row-group-rows", 50000, "Rows per Parquet row group")
+	fs.Var(&fields, "fields", "Comma-separated fields to export (default all)")
+	anonymize := fs.String("anonymize", "", "Replace users with pseudonyms kept in this mapping file (created if missing; reuse it so exports agree, and do not share it) and redact description and comment text")
+	var userFields, redactFields tools.StringList
+	fs.Var(&userFields, "user-field", "With --anonymize, also pseudonymize this changelog field or issue column holding users (repeatable)")
+	fs.Var(&redactFields, "redact-field", "With --anonymize, also redact the text of this changelog field or issue column (repeatable)")
+	var renderOpts render.Options
+	render.AddExportFlags(fs, &renderOpts)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: export [issues|events] [flags]\n\n")
+		fmt.Fprintf(fs.Output(), "Streams the cache as NDJSON, JSON or Parquet: issues with their fields\nflattened, or changelog events with one record per changed field.\n\n")
+		fmt.Fprintf(fs.Output(), "--anonymize makes a dataset to share: users become pseudonyms, kept in a\nmapping file so repeated exports agree, and description and comment text\nis redacted; keys, dates, statuses, points and transitions are kept.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *format != "ndjson" && *format != "json" && *format != "parquet" {
+		cli.Fatalf(cli.ExitUsage, "--format must be ndjson, json or parquet")
+	}
+	if *format != "ndjson" && renderOpts.Chunk.Chunked() {
+		cli.Fatalf(cli.ExitUsage, "chunked output needs --format ndjson")
+	}
+	if renderOpts.Provenance == render.ProvenanceComment {
+		cli.Fatalf(cli.ExitUsage, "--provenance comment is only supported for CSV output")
+	}
+	if *anonymize == "" && (len(userFields) > 0 || len(redactFields) > 0) {
+		cli.Fatalf(cli.ExitUsage, "--user-field and --redact-field need --anonymize")
+	}
+	var sinceTime time.Time
+	if *since != "" {
+		t, err := query.ParseDate(*since, time.Now())
+		if err != nil {
+			cli.Fatal(cli.WithCode(cli.ExitUsage, err))
+		}
+		sinceTime = t
+	}
+
+	store, err := cacheFlags.Open()
+	if err != nil {
+		cli.Fatal(err)
+	}
+	defer store.Close()
+	renderOpts.SetSource(store)
+	extractors, err := jira.LoadExtractors(*fieldsConfig)
+	if err != nil {
+		cli.Fatal(err)
+	}
+
+	schema := jira.EventSchema
+	if kind == "issues" {
+		schema = jira.IssueSchema(extractors)
+	}
+	columns := schema
+	if len(fields) > 0 {
+		columns = nil
+		for _, name := range fields {
+			c, ok := findColumn(schema, name)
+			if !ok {
+				cli.Fatalf(cli.ExitUsage, "unknown field %q (have %s)", name, strings.Join(columnNames(schema), ", "))
+			}
+			columns = append(columns, c)
+		}
+	}
+
+	var anonymizer *jira.Anonymizer
+	if *anonymize != "" {
+		if anonymizer, err = jira.LoadAnonymizer(*anonymize, userFields, redactFields); err != nil {
+			cli.Fatal(err)
+		}
+	}
+
+	out, err := newWriter(renderOpts, *format, columns, *rowGroupRows)
+	if err != nil {
+		cli.Fatal(err)
+	}
+	issues := 0
+	var coverage jira.ChangelogCoverage
+	for _, key := range tools.SortNumerically(store.IssueKeys(cacheFlags.Project)) {
+		issue, err := store.ReadIssue(key)
+		if err != nil {
+			cli.Warnf("skipping %s: %v", key, err)
+			continue
+		}
+		if !sinceTime.IsZero() {
+			if updated, err := issue.UpdatedTime(); err != nil || updated.Before(sinceTime) {
+				continue
+			}
+		}
+		issues++
+		if kind == "issues" {
+			record := jira.IssueValues(issue, extractors)
+			if anonymizer != nil {
+				anonymizer.Issue(record)
+			}
+			if err := out.write(record); err != nil {
+				cli.Fatal(err)
+			}
+			continue
+		}
+		changelog, err := store.ReadChangelog(key)
+		coverage.Add(err)
+		if err != nil {
+			continue
+		}
+		for _, e := range jira.FieldChanges(key, changelog) {
+			if e.At.Before(sinceTime) {
+				continue
+			}
+			record := jira.EventValues(e)
+			if anonymizer != nil {
+				anonymizer.Event(record)
+			}
+			if err := out.write(record); err != nil {
+				cli.Fatal(err)
+			}
+		}
+	}
+	if err := out.close(); err != nil {
+		cli.Fatal(err)
+	}
+	cli.Infof("exported %d records from %d issues", out.records, issues)
+	if anonymizer != nil {
+		if err := anonymizer.Save(); err != nil {
+			cli.Fatal(err)
+		}
+		cli.Infof("anonymized %d users; their names are in %s", anonymizer.Len(), *anonymize)
+	}
+	if kind == "events" {
+		coverage.Log()
+	}
+}
+
+func findColumn(columns []jira.Column, name string) (jira.Column, bool) {
+	for _, c := range columns {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	return jira.Column{}, false
+}
+
+func columnNames(columns []jira.Column) []string {
+	names := make([]string, len(columns))
+	for i, c := range columns {
+		names[i] = c.Name
+	}
+	return names
+}
+
+// encodeRecord writes the record as a JSON object with its fields in
+// column order, which encoding/json does not keep for maps, and times as
+// RFC 3339 strings.
+func encodeRecord(columns []jira.Column, record map[string]interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, c := range columns {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name := c.Name
+		value := record[name]
+		if t, ok := value.(time.Time); ok {
+			value = t.Format(time.RFC3339)
+		}
+		k, _ := json.Marshal(name)
+		v, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("encode %s: %w", name, err)
+		}
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// writer streams records to the output file, its chunks or stdout.
+type writer struct {
+	opts    render.Options
+	format  string
+	columns []jira.Column
+	chunks  *render.ChunkWriter
+	parquet *parquet.Writer
+	w       io.WriteCloser
+	path    string
+	records int
+}
+
+func newWriter(opts render.Options, format string, columns []jira.Column, rowGroupRows int) (*writer, error) {
+	out := &writer{opts: opts, format: format, columns: columns}
+	if opts.Chunk.Chunked() {
+		if opts.Out == "" {
+			return nil, cli.WithCode(cli.ExitUsage, fmt.Errorf("--chunk-rows and --chunk-bytes need --out"))
+		}
+		path, err := render.OutputPath(opts.Out)
+		if err != nil {
+			return nil, err
+		}
+		if out.chunks, err = opts.NewChunkWriter(path, nil); err != nil {
+			return nil, err
+		}
+		return out, nil
+	}
+	if format == "parquet" {
+		return out, out.openParquet(rowGroupRows)
+	}
+	w, path, err := opts.Create()
+	if err != nil {
+		return nil, err
+	}
+	out.w, out.path = w, path
+	if format == "json" {
+		_, err = io.WriteString(w, "[")
+	}
+	return out, err
+}
+
+// parquetCodecs are the Parquet ids of the --compress formats.
+var parquetCodecs = map[string]int32{"gzip": 2, "zstd": 6}
+
+// openParquet creates the output file uncompressed, as Parquet compresses
+// its pages itself with the --compress codec.
+func (o *writer) openParquet(rowGroupRows int) error {
+	pqOpts := parquet.Options{RowGroupRows: rowGroupRows, CreatedBy: "rhoai-jira export"}
+	comp, err := render.LookupCompressor(o.opts.Chunk.Compress)
+	if err != nil {
+		return err
+	}
+	if comp != nil {
+		id, ok := parquetCodecs[o.opts.Chunk.Compress]
+		if !ok {
+			return cli.WithCode(cli.ExitUsage, fmt.Errorf("--compress %s is not a Parquet codec", o.opts.Chunk.Compress))
+		}
+		pqOpts.Codec = &parquet.Codec{ID: id, New: comp.New}
+	}
+	fileOpts := o.opts
+	fileOpts.Chunk.Compress = "none"
+	w, path, err := fileOpts.Create()
+	if err != nil {
+		return err
+	}
+	o.w, o.path = w, path
+
+	columns := make([]parquet.Column, len(o.columns))
+	for i, c := range o.columns {
+		columns[i] = parquet.Column{Name: c.Name, Type: parquetTypes[c.Type]}
+	}
+	o.parquet, err = parquet.NewWriter(w, columns, pqOpts)
+	if err != nil {
+		w.Close()
+	}
+	return err
+}
+
+var parquetTypes = map[jira.ColumnType]parquet.Type{
+	jira.ColumnString:  parquet.String,
+	jira.ColumnInt:     parquet.Int64,
+	jira.ColumnFloat:   parquet.Double,
+	jira.ColumnTime:    parquet.Timestamp,
+	jira.ColumnStrings: parquet.StringList,
+}
+
+func (o *writer) write(record map[string]interface{}) error {
+	o.records++
+	if o.parquet != nil {
+		row := make([]interface{}, len(o.columns))
+		for i, c := range o.columns {
+			row[i] = record[c.Name]
+		}
+		return o.parquet.Write(row)
+	}
+	data, err := encodeRecord(o.columns, record)
+	if err != nil {
+		return err
+	}
+	if o.chunks != nil {
+		return o.chunks.WriteRecord(append(data, '\n'))
+	}
+	if o.format == "json" {
+		sep := ",\n"
+		if o.records == 1 {
+			sep = "\n"
+		}
+		if _, err := io.WriteString(o.w, sep); err != nil {
+			return err
+		}
+		_, err = o.w.Write(data)
+		return err
+	}
+	_, err = o.w.Write(append(data, '\n'))
+	return err
+}
+
+func (o *writer) close() error {
+	if o.chunks != nil {
+		return o.chunks.Close()
+	}
+	if o.parquet != nil {
+		if err := o.parquet.Close(); err != nil {
+			o.w.Close()
+			return err
+		}
+	}
+	if o.format == "json" {
+		if _, err := io.WriteString(o.w, "\n]\n"); err != nil {
+			o.w.Close()
+			return err
+		}
+	}
+	if err := o.w.Close(); err != nil {
+		return err
+	}
+	if o.path != "" && o.opts.Provenance == render.ProvenanceSidecar {
+		return o.opts.WriteSidecar(o.path, o.records)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package fetch
+
+import (
+	"context"
+	"runtime/debug"
+	"time"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+)
+
+// Retry delays after a failed cycle start at minRetry and double up to the
+// regular interval.
+const minRetry = time.Minute
+
+// runDaemon repeats cycle every interval until ctx is cancelled. Failed
+// cycles are retried sooner with exponential backoff, and a panicking cycle
+// is logged and treated as a failure rather than stopping the daemon.
+func runDaemon(ctx context.Context, interval time.Duration, cycle func(context.Context) int) {
+	if interval <= 0 {
+		interval = 15 * time.Minute
+	}
+	retry := minRetry
+	for {
+		started := time.Now()
+		code := safeCycle(ctx, cycle)
+		if ctx.Err() != nil {
+			cli.Infof("daemon stopping")
+			return
+		}
+
+		wait := interval - time.Since(started)
+		switch code {
+		case cli.ExitOK, cli.ExitPartialSync:
+			retry = minRetry
+		default:
+			cli.Warnf("sync cycle failed (exit code %d), retrying in %s", code, retry)
+			if retry < wait {
+				wait = retry
+			}
+			retry *= 2
+			if retry > interval {
+				retry = interval
+			}
+		}
+		if wait < 0 {
+			wait = 0
+		}
+		cli.Infof("next sync in %s", wait.Round(time.Second))
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			cli.Infof("daemon stopping")
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+func safeCycle(ctx context.Context, cycle func(context.Context) int) (code int) {
+	defer func() {
+		if r := recover(); r != nil {
+			cli.Warnf("sync cycle panicked: %v\n%s", r, debug.Stack())
+			code = cli.ExitFailure
+		}
+	}()
+	return cycle(ctx)
+}
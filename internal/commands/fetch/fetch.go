@@ -0,0 +1,458 @@
+package fetch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/jira"
+	"github.com/jctanner/rhoai-jira/internal/notify"
+	"github.com/jctanner/rhoai-jira/internal/render"
+	"github.com/jctanner/rhoai-jira/internal/tools"
+)
+
+func Main(args []string) {
+	fs := flag.NewFlagSet("fetch", flag.ExitOnError)
+	var projects tools.StringList
+	fs.Var(&projects, "project", "Jira project key (e.g., ABC); comma separated or repeated to sync several projects in one run")
+	baseURL := fs.String("base-url", cli.BaseURL(), "Jira base URL")
+	lookbackHours := fs.Int("lookback-hours", 0, "How many hours to look back from the last known updated timestamp (default: derived from observed index lag and clock skew)")
+	forceUpdate := fs.Bool("force-update", false, "force refetch -every- issue")
+	smartUpdate := fs.Bool("smart-update", false, "force refetch some* issues")
+	sprintUpdate := fs.String("sprint", "", "refetch issues in a specific sprint")
+	jql := fs.String("jql", "", "mirror the issues matching this JQL instead of a whole project")
+	discover := fs.String("discover-projects", "", "sync every visible project whose key matches this glob (e.g. \"RHOAI*\")")
+	compact := fs.Bool("compact", false, "write compact (non-indented) JSON")
+	compress := fs.Bool("compress", cli.Settings().Compress, "write the files of each issue zstd-compressed as {KEY}.json.zst (default from compress: in the config file)")
+	writeBatch := fs.Int("write-batch", 0, "batch this many cache writes per manifest journal and directory fsync (0 writes synchronously)")
+	recordDiffs := fs.Bool("record-diffs", false, "append what changed in each refetched issue, field by field, to diffs/{KEY}.jsonl under the cache")
+	comments := fs.Bool("comments", false, "also fetch comments into {KEY}.comments.json")
+	worklogs := fs.Bool("worklogs", false, "also fetch the worklogs of issues with logged time into {KEY}.worklogs.json")
+	watchers := fs.Bool("watchers", false, "also fetch the watchers of watched issues into {KEY}.watchers.json")
+	attachments := fs.Bool("attachments", false, "also download attachments into attachments/{KEY}/ under the cache")
+	attachmentsDir := fs.String("attachments-dir", "", "directory for --attachments (default: attachments/ in the cache)")
+	attachmentMaxMB := fs.Int64("attachment-max-mb", 0, "skip attachments larger than this many megabytes (0 for no cap)")
+	changelogs := fs.String("changelog-fields", "", "only persist changelog entries for these comma separated fields (\"default\" for the fields the reports use)")
+	changelogsOnly := fs.Bool("changelogs-only", false, "only fetch the cached issues that have no {KEY}.changelog.json, backfilling their changelogs")
+	incremental := fs.Bool("incremental-changelog", false, "fetch only changelog entries newer than those cached, through the paginated changelog endpoint, appending them to {KEY}.changelog.json")
+	reconcile := fs.Bool("reconcile", false, "check the cached issues the project search no longer lists, tombstoning those deleted or moved to another project")
+	retryDenied := fs.Bool("retry-denied", false, "ask Jira again for every issue marked denied, in case permissions changed")
+	retryDeniedDays := fs.Int("retry-denied-after", cli.Settings().Denied.RetryAfterDays, "ask Jira again for issues last denied at least this many days ago (0 never; default from denied.retry_after_days in the config file)")
+	cacheSpec := fs.String("cache", cli.CacheSpec("issues"), "cache backend: a directory, dir:PATH or sqlite:FILE")
+	wait := fs.Duration("wait", 0, "wait this long for reports or another fetch using the cache to finish instead of failing at once")
+	auth := cli.AddAuthFlags(fs)
+	apiVersion := fs.String("api-version", jira.DefaultAPIVersion, "Jira REST API version: 2, 3 (Jira Cloud) or auto to ask the server (default: 3 for *.atlassian.net, otherwise 2)")
+	adfFormat := fs.String("adf-format", jira.DefaultADFFormat, "convert API v3 rich text (Atlassian Document Format) to markdown or text when caching")
+	requestTimeout := fs.Duration("request-timeout", jira.DefaultRequestTimeout, "give up on a single Jira request after this long (0 for no limit)")
+	resume := fs.Bool("resume", false, "continue from the checkpoint an interrupted sync left behind instead of starting over")
+	daemon := fs.Bool("daemon", false, "keep running, repeating the sync every --interval")
+	interval := fs.Duration("interval", 15*time.Minute, "time between syncs in --daemon mode")
+	escalations := fs.String("escalations", "", "after each sync, write stale blockers of active sprint work to this JSON file")
+	escalationDays := fs.Int("escalation-days", 7, "blockers not updated for this many days are escalated")
+	var webhooks tools.StringList
+	fs.Var(&webhooks, "webhook", "POST change events detected during sync to this URL (repeatable, secret via WEBHOOK_SECRET)")
+	noNotify := fs.Bool("no-notify", false, "do not post the chat notifications configured under notify:")
+	progressMode := fs.String("progress", ProgressAuto, "report progress as a bar on the terminal (bar), as a log line every --progress-interval (log), not at all (none), or a bar when stderr is a terminal (auto)")
+	progressInterval := fs.Duration("progress-interval", 30*time.Second, "time between progress log lines")
+	summary := fs.String("summary", "", "write a JSON summary of each run to this file (default: next to the cache, as issues.summary.json; none to skip)")
+	fs.Parse(args)
+
+	if len(projects) == 0 && *jql == "" && *discover == "" {
+		projects = cli.Settings().Projects
+	}
+	if len(projects) == 0 && *jql == "" && *discover == "" {
+		cli.Fatalf(cli.ExitUsage, "One of --project, --jql or --discover-projects must be provided.")
+	}
+	if *jql != "" && (*discover != "" || len(projects) > 1) {
+		cli.Fatalf(cli.ExitUsage, "--jql cannot be combined with several projects or --discover-projects.")
+	}
+	if *changelogsOnly && *jql != "" {
+		cli.Fatalf(cli.ExitUsage, "--changelogs-only cannot be combined with --jql.")
+	}
+	if *reconcile && *jql != "" {
+		cli.Fatalf(cli.ExitUsage, "--reconcile cannot be combined with --jql.")
+	}
+	if *apiVersion != "" && !tools.ItemInList(jira.APIVersions, *apiVersion) {
+		cli.Fatalf(cli.ExitUsage, "invalid --api-version %q (expected %s)", *apiVersion, strings.Join(jira.APIVersions, ", "))
+	}
+	if !tools.ItemInList(progressModes, *progressMode) {
+		cli.Fatalf(cli.ExitUsage, "invalid --progress %q (expected %s)", *progressMode, strings.Join(progressModes, ", "))
+	}
+	if !tools.ItemInList(jira.ADFFormats, *adfFormat) {
+		cli.Fatalf(cli.ExitUsage, "invalid --adf-format %q (expected %s)", *adfFormat, strings.Join(jira.ADFFormats, ", "))
+	}
+	authenticator, err := auth.Authenticator()
+	if err != nil {
+		cli.Fatal(err)
+	}
+
+	store, err := jira.OpenStore(*cacheSpec)
+	if err != nil {
+		cli.Fatal(err)
+	}
+	if dirStore, ok := store.(*jira.DirStore); ok {
+		dirStore.Compact = *compact
+		dirStore.Compress = *compress
+		dirStore.RecordDiffs = *recordDiffs
+		if *writeBatch > 0 {
+			dirStore.Writer = jira.NewBatchWriter(dirStore.Dir, *writeBatch)
+		}
+	}
+	if _, ok := store.(*jira.DirStore); !ok && *recordDiffs {
+		cli.Warnf("--record-diffs is only supported by directory caches; ignoring it")
+	}
+	client := jira.NewClient(*baseURL, "")
+	client.Auth = authenticator
+	client.RequestTimeout = *requestTimeout
+	client.APIVersion = *apiVersion
+	client.ADFFormat = *adfFormat
+	prog := newProgress(*progressMode, *progressInterval, client.Requests)
+	summaryPath := *summary
+	switch summaryPath {
+	case "":
+		summaryPath = SummaryPath(store)
+	case "none":
+		summaryPath = ""
+	}
+
+	autoLookback := true
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "lookback-hours" {
+			autoLookback = false
+		}
+	})
+
+	opts := jira.SyncOptions{
+		Lookback:             time.Duration(*lookbackHours) * time.Hour,
+		AutoLookback:         autoLookback,
+		ForceUpdate:          *forceUpdate,
+		SmartUpdate:          *smartUpdate,
+		Sprint:               *sprintUpdate,
+		JQL:                  *jql,
+		ChangelogFields:      jira.ParseChangelogFields(*changelogs),
+		IncrementalChangelog: *incremental,
+		Comments:             *comments,
+		Worklogs:             *worklogs,
+		Watchers:             *watchers,
+		Resume:               *resume,
+		Reconcile:            *reconcile,
+		RetryDenied:          jira.DeniedPolicy{All: *retryDenied, After: time.Duration(*retryDeniedDays) * 24 * time.Hour},
+		Progress: func(p jira.SyncProgress) {
+			prog.Update(p)
+			if p.Err == nil {
+				return
+			}
+			var tombstoned *jira.TombstonedError
+			if errors.As(p.Err, &tombstoned) {
+				cli.Infof("tombstoned %s: %v", p.Key, tombstoned.Err)
+				return
+			}
+			slog.Warn("error processing issue", "key", p.Key, "phase", p.Phase, "err", p.Err)
+			if jira.IsStatus(p.Err, 403) {
+				cli.Infof("marked %s as denied", p.Key)
+			}
+		},
+	}
+
+	if *attachments {
+		opts.Attachments = &jira.AttachmentOptions{Dir: *attachmentsDir, MaxSize: *attachmentMaxMB << 20}
+		if opts.Attachments.Dir == "" {
+			opts.Attachments.Dir = jira.DefaultAttachmentsDir(store)
+		}
+	}
+
+	var notifier *notify.Notifier
+	if !*noNotify {
+		notifier, err = newNotifier(*baseURL)
+		if err != nil {
+			cli.Fatal(err)
+		}
+	}
+	if len(webhooks) > 0 || notifier != nil {
+		var emitter *jira.WebhookEmitter
+		if len(webhooks) > 0 {
+			emitter = &jira.WebhookEmitter{Endpoints: webhooks, Secret: os.Getenv("WEBHOOK_SECRET")}
+		}
+		opts.OnChange = func(events []jira.ChangeEvent) {
+			if notifier != nil {
+				notifier.Collect(events)
+			}
+			if emitter == nil {
+				return
+			}
+			if err := emitter.Emit(context.Background(), events); err != nil {
+				cli.Warnf("webhook delivery failed: %v", err)
+			}
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		// A second signal kills the process the usual way.
+		stop()
+		cli.Infof("interrupted; saving a checkpoint (interrupt again to quit immediately)")
+	}()
+
+	f := &fetcher{
+		client:         client,
+		store:          store,
+		opts:           opts,
+		projects:       projects,
+		jql:            *jql,
+		discover:       *discover,
+		changelogsOnly: *changelogsOnly,
+		escalations:    *escalations,
+		escalationDays: *escalationDays,
+		notifier:       notifier,
+		progress:       prog,
+		summary:        summaryPath,
+		wait:           *wait,
+	}
+	exitCode := cli.ExitOK
+	if *daemon {
+		runDaemon(ctx, *interval, f.cycle)
+	} else {
+		exitCode = f.cycle(ctx)
+	}
+
+	if closeErr := store.Close(); closeErr != nil {
+		cli.Warnf("failed to flush cache writes: %v", closeErr)
+		if exitCode == cli.ExitOK {
+			exitCode = cli.ExitFailure
+		}
+	}
+	os.Exit(exitCode)
+}
+
+// fetcher runs one sync cycle over the selected projects or JQL query.
+type fetcher struct {
+	client   *jira.Client
+	store    jira.Store
+	opts     jira.SyncOptions
+	projects []string
+	jql      string
+	discover string
+	// changelogsOnly backfills missing changelogs instead of syncing.
+	changelogsOnly bool
+
+	escalations    string
+	escalationDays int
+	notifier       *notify.Notifier
+
+	progress *progress
+	// summary is where the JSON summary of each cycle goes, or "".
+	summary string
+	// wait is how long a cycle waits for the cache lock.
+	wait time.Duration
+}
+
+// cycle syncs every selected project once, records the outcome of each in
+// the sync state and returns the exit code the run deserves. Projects are
+// synced one after another through the same client so they share its rate
+// limiting instead of competing for it, while each keeps its own
+// high-water mark in the sync state.
+func (f *fetcher) cycle(ctx context.Context) int {
+	// Reports wait for the whole cycle, not single issues, so they never
+	// see some projects synced and others not.
+	if err := jira.LockStore(f.store, true, f.wait); err != nil {
+		err = cli.LockError(err)
+		cli.Warnf("sync skipped: %v", err)
+		return cli.ExitCode(err)
+	}
+	defer func() {
+		if err := jira.UnlockStore(f.store); err != nil {
+			cli.Warnf("failed to unlock the cache: %v", err)
+		}
+	}()
+	f.progress.Begin()
+	projects := f.selectedProjects()
+	if f.discover != "" {
+		discovered, err := f.client.DiscoverProjects(ctx, f.discover)
+		if err != nil {
+			cli.Warnf("project discovery failed: %v", err)
+			return cli.ExitCode(err)
+		}
+		cli.Infof("discovered %d projects matching %q: %s", len(discovered), f.discover, strings.Join(discovered, ", "))
+		projects = appendNew(projects, discovered...)
+	}
+	if f.jql != "" {
+		projects = projects[:min(len(projects), 1)]
+		if len(projects) == 0 {
+			projects = []string{""}
+		}
+	}
+
+	exitCode := cli.ExitOK
+	var failed []string
+	var summaries []ProjectSyncSummary
+	for _, p := range projects {
+		if ctx.Err() != nil {
+			return cli.ExitInterrupted
+		}
+		opts := f.opts
+		opts.Project = p
+		label, stateKey := p, strings.ToUpper(p)
+		sync := jira.SyncProject
+		switch {
+		case f.jql != "":
+			label, stateKey = "--jql", "jql"
+			sync = jira.SyncJQL
+		case f.changelogsOnly:
+			sync = jira.BackfillChangelogs
+		}
+		started := time.Now()
+		f.progress.Start(label)
+		result, err := sync(ctx, f.client, f.store, opts)
+		f.progress.Finish()
+		summaries = append(summaries, projectSummary(label, time.Since(started), result, err))
+		if result.HighestKey != "" {
+			cli.Infof("Latest issue found: %s", result.HighestKey)
+		}
+		if result.Missed > 0 {
+			cli.Warnf("search index missed %d updated issues", result.Missed)
+		}
+		if f.jql == "" && !f.changelogsOnly {
+			cli.Infof("lookback window: %s", result.Lookback)
+		}
+		cli.Infof("sync of %s finished: fetched=%d denied=%d failed=%d skipped=%d comments=%d worklogs=%d watchers=%d attachments=%d", label, result.Fetched, result.Denied, result.Failed, result.Skipped, result.Comments, result.Worklogs, result.Watchers, result.Attachments)
+		if result.Deleted > 0 || result.Moved > 0 {
+			cli.Infof("tombstoned %d deleted and %d moved issues of %s", result.Deleted, result.Moved, label)
+		}
+		if result.Recovered > 0 {
+			cli.Infof("%d issues of %s marked denied are readable again", result.Recovered, label)
+		}
+		if err != nil {
+			cli.Warnf("sync of %s failed: %v", label, err)
+			failed = append(failed, label)
+			if exitCode == cli.ExitOK || exitCode == cli.ExitPartialSync {
+				exitCode = cli.ExitCode(err)
+			}
+		} else if result.Failed > 0 && exitCode == cli.ExitOK {
+			exitCode = cli.ExitPartialSync
+		}
+		if f.changelogsOnly {
+			// A backfill is not a sync: the next one still has to look
+			// for updates since the last.
+			continue
+		}
+		if stateErr := jira.RecordSyncRun(f.store, stateKey, started, err); stateErr != nil {
+			cli.Warnf("failed to record sync state: %v", stateErr)
+		}
+	}
+
+	if len(projects) > 1 {
+		cli.Infof("synced %d of %d projects", len(projects)-len(failed), len(projects))
+		if len(failed) > 0 {
+			cli.Warnf("failed projects: %s", strings.Join(failed, ", "))
+		}
+	}
+
+	if flusher, ok := f.store.(interface{ Flush() error }); ok {
+		if err := flusher.Flush(); err != nil {
+			cli.Warnf("failed to flush cache writes: %v", err)
+			if exitCode == cli.ExitOK {
+				exitCode = cli.ExitFailure
+			}
+		}
+	}
+	if f.notifier != nil {
+		title := "Jira sync of " + strings.Join(projects, ", ")
+		if f.jql != "" {
+			title = "Jira sync of " + f.jql
+		}
+		if err := f.notifier.Flush(context.Background(), title); err != nil {
+			cli.Warnf("notification failed: %v", err)
+		}
+	}
+	if f.escalations != "" {
+		if err := f.writeEscalations(); err != nil {
+			cli.Warnf("failed to write escalations: %v", err)
+			if exitCode == cli.ExitOK {
+				exitCode = cli.ExitFailure
+			}
+		}
+	}
+	if f.summary != "" {
+		summary := f.progress.Summary(summaries, exitCode)
+		if err := writeSummary(f.summary, summary); err != nil {
+			cli.Warnf("failed to write sync summary: %v", err)
+		} else {
+			cli.Infof("%d requests in %.0fs (%.1f/s); summary written to %s", summary.Requests, summary.Seconds, summary.RequestRate, f.summary)
+		}
+	}
+	return exitCode
+}
+
+// newNotifier returns the notifier of the chat webhooks in the config
+// file, or nil when none are configured.
+func newNotifier(baseURL string) (*notify.Notifier, error) {
+	settings := cli.Settings()
+	var urls []string
+	for _, ref := range append(append([]string(nil), settings.Notify.Slack...), settings.Notify.GoogleChat...) {
+		url, err := settings.ResolveSecret(ref)
+		if err != nil {
+			return nil, fmt.Errorf("notify: %w", err)
+		}
+		if url != "" {
+			urls = append(urls, url)
+		}
+	}
+	if len(urls) == 0 {
+		return nil, nil
+	}
+	return &notify.Notifier{Webhooks: urls, Sprints: settings.Notify.Sprints, BaseURL: baseURL}, nil
+}
+
+// writeEscalations lists the stale blockers of active sprint work in the
+// freshly synced cache, most urgent first.
+func (f *fetcher) writeEscalations() error {
+	stale := time.Duration(f.escalationDays) * 24 * time.Hour
+	escalations, _ := jira.FindEscalations(jira.LoadIssues(f.store, ""), stale, time.Now())
+	if escalations == nil {
+		escalations = []jira.Escalation{}
+	}
+	data, err := json.MarshalIndent(escalations, "", "  ")
+	if err != nil {
+		return err
+	}
+	path, err := render.OutputPath(f.escalations)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return err
+	}
+	cli.Infof("wrote %d escalations to %s", len(escalations), path)
+	return nil
+}
+
+// selectedProjects returns the --project keys in upper case without
+// duplicates.
+func (f *fetcher) selectedProjects() []string {
+	var keys []string
+	for _, p := range f.projects {
+		keys = appendNew(keys, strings.ToUpper(p))
+	}
+	return keys
+}
+
+func appendNew(keys []string, add ...string) []string {
+	for _, k := range add {
+		if !slices.Contains(keys, k) {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
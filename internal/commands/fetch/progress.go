@@ -0,0 +1,276 @@
+package fetch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/jira"
+)
+
+// Progress modes for the --progress flag.
+const (
+	ProgressAuto = "auto"
+	ProgressBar  = "bar"
+	ProgressLog  = "log"
+	ProgressNone = "none"
+)
+
+var progressModes = []string{ProgressAuto, ProgressBar, ProgressLog, ProgressNone}
+
+// progressBarWidth is the number of cells of the bar.
+const progressBarWidth = 30
+
+// progress follows a sync issue by issue: how far the current phase is,
+// the outcome of every issue, the request rate and when the phase should
+// end. It draws a bar on the status line of a terminal, or logs a line
+// every interval otherwise.
+type progress struct {
+	mode     string
+	interval time.Duration
+	requests func() int64
+
+	mu         sync.Mutex
+	started    time.Time
+	baseline   int64
+	label      string
+	phase      string
+	phaseStart time.Time
+	done       int
+	total      int
+	counts     progressCounts
+	lastLog    time.Time
+}
+
+type progressCounts struct {
+	Fetched    int `json:"fetched"`
+	Denied     int `json:"denied"`
+	Tombstoned int `json:"tombstoned"`
+	Failed     int `json:"failed"`
+}
+
+func newProgress(mode string, interval time.Duration, requests func() int64) *progress {
+	if mode == ProgressAuto {
+		mode = ProgressLog
+		if cli.StatusAvailable() {
+			mode = ProgressBar
+		}
+	}
+	if mode == ProgressBar && !cli.StatusAvailable() {
+		cli.Infof("stderr is not a terminal; logging progress instead of drawing a bar")
+		mode = ProgressLog
+	}
+	p := &progress{mode: mode, interval: interval, requests: requests}
+	p.Begin()
+	return p
+}
+
+// Begin starts counting a new sync cycle.
+func (p *progress) Begin() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.started, p.lastLog = time.Now(), time.Now()
+	p.counts = progressCounts{}
+	if p.requests != nil {
+		p.baseline = p.requests()
+	}
+}
+
+// sent is the number of requests made since the cycle began.
+func (p *progress) sent() int64 {
+	if p.requests == nil {
+		return 0
+	}
+	return p.requests() - p.baseline
+}
+
+// Start begins the sync of a project, or of the --jql query.
+func (p *progress) Start(label string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.label, p.phase, p.done, p.total = label, "", 0, 0
+}
+
+// Update records one processed issue.
+func (p *progress) Update(sp jira.SyncProgress) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	if sp.Phase != p.phase {
+		p.phase, p.phaseStart = sp.Phase, now
+	}
+	p.done, p.total = sp.Done, sp.Total
+	var tombstoned *jira.TombstonedError
+	switch {
+	case sp.Err == nil:
+		p.counts.Fetched++
+	case errors.As(sp.Err, &tombstoned):
+		p.counts.Tombstoned++
+	case jira.IsStatus(sp.Err, 403):
+		p.counts.Denied++
+	default:
+		p.counts.Failed++
+	}
+	switch p.mode {
+	case ProgressBar:
+		cli.SetStatus(p.line(now, true))
+	case ProgressLog:
+		if now.Sub(p.lastLog) >= p.interval {
+			p.lastLog = now
+			cli.Infof("progress: %s", p.line(now, false))
+		}
+	}
+}
+
+// Finish removes the bar before the closing log lines.
+func (p *progress) Finish() {
+	if p.mode == ProgressBar {
+		cli.SetStatus("")
+	}
+}
+
+// rate is the number of requests per second since the cycle began.
+func (p *progress) rate(now time.Time) float64 {
+	elapsed := now.Sub(p.started).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(p.sent()) / elapsed
+}
+
+// eta extrapolates the time the current phase has taken so far to the
+// issues left in it; false until there is something to extrapolate.
+func (p *progress) eta(now time.Time) (time.Duration, bool) {
+	if p.done == 0 || p.total <= p.done {
+		return 0, p.total > 0 && p.done >= p.total
+	}
+	perIssue := now.Sub(p.phaseStart) / time.Duration(p.done)
+	return perIssue * time.Duration(p.total-p.done), true
+}
+
+func (p *progress) line(now time.Time, bar bool) string {
+	var b strings.Builder
+	if p.total > 0 {
+		ratio := float64(p.done) / float64(p.total)
+		if bar {
+			filled := min(int(ratio*progressBarWidth), progressBarWidth)
+			fmt.Fprintf(&b, "[%s%s] ", strings.Repeat("#", filled), strings.Repeat(".", progressBarWidth-filled))
+		}
+		fmt.Fprintf(&b, "%3.0f%% ", 100*ratio)
+	}
+	if p.label != "" {
+		b.WriteString(p.label + " ")
+	}
+	fmt.Fprintf(&b, "%s %d/%d", p.phase, p.done, p.total)
+	fmt.Fprintf(&b, " fetched=%d denied=%d failed=%d", p.counts.Fetched, p.counts.Denied, p.counts.Failed)
+	if p.counts.Tombstoned > 0 {
+		fmt.Fprintf(&b, " tombstoned=%d", p.counts.Tombstoned)
+	}
+	fmt.Fprintf(&b, " %.1f req/s", p.rate(now))
+	if eta, ok := p.eta(now); ok {
+		fmt.Fprintf(&b, " ETA %s", eta.Round(time.Second))
+	}
+	return b.String()
+}
+
+// SyncSummary is what fetch writes next to the cache when a run ends.
+type SyncSummary struct {
+	Started  string  `json:"started"`
+	Finished string  `json:"finished"`
+	Seconds  float64 `json:"seconds"`
+	Requests int64   `json:"requests"`
+	// RequestRate is in requests per second.
+	RequestRate float64              `json:"requestRate"`
+	Issues      progressCounts       `json:"issues"`
+	Projects    []ProjectSyncSummary `json:"projects"`
+	ExitCode    int                  `json:"exitCode"`
+}
+
+// ProjectSyncSummary is the outcome of the sync of one project, or of the
+// --jql query.
+type ProjectSyncSummary struct {
+	Project     string  `json:"project"`
+	Seconds     float64 `json:"seconds"`
+	Fetched     int     `json:"fetched"`
+	Denied      int     `json:"denied"`
+	Failed      int     `json:"failed"`
+	Skipped     int     `json:"skipped"`
+	Recovered   int     `json:"recovered,omitempty"`
+	Deleted     int     `json:"deleted,omitempty"`
+	Moved       int     `json:"moved,omitempty"`
+	Missed      int     `json:"missed,omitempty"`
+	Comments    int     `json:"comments,omitempty"`
+	Worklogs    int     `json:"worklogs,omitempty"`
+	Watchers    int     `json:"watchers,omitempty"`
+	Attachments int     `json:"attachments,omitempty"`
+	HighestKey  string  `json:"highestKey,omitempty"`
+	Error       string  `json:"error,omitempty"`
+}
+
+func projectSummary(label string, d time.Duration, result jira.SyncResult, err error) ProjectSyncSummary {
+	s := ProjectSyncSummary{
+		Project:     label,
+		Seconds:     d.Seconds(),
+		Fetched:     result.Fetched,
+		Denied:      result.Denied,
+		Failed:      result.Failed,
+		Skipped:     result.Skipped,
+		Recovered:   result.Recovered,
+		Deleted:     result.Deleted,
+		Moved:       result.Moved,
+		Missed:      result.Missed,
+		Comments:    result.Comments,
+		Worklogs:    result.Worklogs,
+		Watchers:    result.Watchers,
+		Attachments: result.Attachments,
+		HighestKey:  result.HighestKey,
+	}
+	if err != nil {
+		s.Error = err.Error()
+	}
+	return s
+}
+
+// Summary totals the run so far.
+func (p *progress) Summary(projects []ProjectSyncSummary, exitCode int) SyncSummary {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	return SyncSummary{
+		Started:     p.started.UTC().Format(time.RFC3339),
+		Finished:    now.UTC().Format(time.RFC3339),
+		Seconds:     now.Sub(p.started).Seconds(),
+		RequestRate: p.rate(now),
+		Issues:      p.counts,
+		Requests:    p.sent(),
+		Projects:    projects,
+		ExitCode:    exitCode,
+	}
+}
+
+// SummaryPath places the summary of a cache next to it: issues.summary.json
+// beside an issues/ directory or cache.db.summary.json beside a SQLite
+// file. Other stores have no default place for one.
+func SummaryPath(store jira.Store) string {
+	switch s := store.(type) {
+	case *jira.DirStore:
+		return filepath.Clean(s.Dir) + ".summary.json"
+	case *jira.SQLiteStore:
+		return s.Path + ".summary.json"
+	}
+	return ""
+}
+
+func writeSummary(path string, s SyncSummary) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
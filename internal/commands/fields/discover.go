@@ -0,0 +1,69 @@
+package fields
+
+import (
+	"context"
+	"flag"
+	"strconv"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/jira"
+	"github.com/jctanner/rhoai-jira/internal/render"
+)
+
+// discover lists the fields of the Jira instance and saves the name to id
+// mapping in the cache, where every command picks it up.
+func discover(args []string) {
+	fs := flag.NewFlagSet("fields discover", flag.ExitOnError)
+	cacheFlags := cli.AddCacheFlags(fs)
+	baseURL := fs.String("base-url", cli.BaseURL(), "Jira base URL")
+	all := fs.Bool("all", false, "List system fields too, not only custom fields")
+	auth := cli.AddAuthFlags(fs)
+	var renderOpts render.Options
+	render.AddFlags(fs, &renderOpts)
+	fs.Parse(args)
+
+	authenticator, err := auth.Authenticator()
+	if err != nil {
+		cli.Fatal(err)
+	}
+	client := jira.NewClient(*baseURL, "")
+	client.Auth = authenticator
+	m, err := client.DiscoverFields(context.Background())
+	if err != nil {
+		cli.Fatal(err)
+	}
+
+	store, err := cacheFlags.OpenForWriting()
+	if err != nil {
+		cli.Fatal(err)
+	}
+	defer store.Close()
+	if err := store.SaveFieldMap(m); err != nil {
+		cli.Fatal(err)
+	}
+
+	sprint, points, epic := m.CustomFields()
+	roles := map[string]string{sprint.ID: "sprint", points.ID: "story_points", epic.ID: "epic_link"}
+	delete(roles, "")
+	for _, role := range []struct{ name, id string }{{"sprint", sprint.ID}, {"story points", points.ID}, {"epic link", epic.ID}} {
+		if role.id == "" {
+			cli.Warnf("no %s field found; the default id stays in use", role.name)
+		}
+	}
+	cli.Infof("saved %d fields to the cache: sprint=%s story_points=%s epic_link=%s", len(m.Fields), sprint.ID, points.ID, epic.ID)
+
+	table := render.NewTable("id", "name", "custom", "type", "schema", "role")
+	for _, f := range m.Fields {
+		if !f.Custom && !*all {
+			continue
+		}
+		typ := f.Schema.Type
+		if f.Schema.Items != "" {
+			typ += "<" + f.Schema.Items + ">"
+		}
+		table.Append(f.ID, f.Name, strconv.FormatBool(f.Custom), typ, f.Schema.Custom, roles[f.ID])
+	}
+	if err := renderOpts.Write(table); err != nil {
+		cli.Fatal(err)
+	}
+}
@@ -0,0 +1,139 @@
+package fields
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/jira"
+	"github.com/jctanner/rhoai-jira/internal/render"
+	"github.com/jctanner/rhoai-jira/internal/tools"
+)
+
+type GroupTotals struct {
+	Group  string
+	Issues int
+	Effort float64
+}
+
+func Main(args []string) {
+	if len(args) > 0 && args[0] == "discover" {
+		discover(args[1:])
+		return
+	}
+	fs := flag.NewFlagSet("fields", flag.ExitOnError)
+	cacheFlags := cli.AddCacheFlags(fs)
+	fieldsConfig := fs.String("fields-config", cli.FieldsConfig(), "JSON file mapping custom fields to named extractors")
+	rulesPath := fs.String("rules", "", "JSON classification rules file; exposes the category field")
+	groupBy := fs.String("group-by", "status", "Extracted field to group by")
+	effortStr := fs.String("effort", "points", "Effort source for the effort column (points, time, count)")
+	listFields := fs.Bool("list-fields", false, "List the available field names and exit")
+	var where tools.StringList
+	fs.Var(&where, "where", `Filter such as 'Team=Platform' or 'RICE>=10' (repeatable, ANDed)`)
+	var renderOpts render.Options
+	render.AddFlags(fs, &renderOpts)
+	render.AddCacheFlag(fs, &renderOpts)
+	fs.Parse(args)
+
+	store, err := cacheFlags.Open()
+	if err != nil {
+		cli.Fatal(err)
+	}
+	defer store.Close()
+
+	extractors, err := jira.LoadExtractors(*fieldsConfig)
+	if err != nil {
+		cli.Fatal(err)
+	}
+	if *listFields {
+		for _, name := range extractors.Names() {
+			fe, _ := extractors.Lookup(name)
+			fmt.Printf("%s\t%s\t%s\n", fe.Name, fe.Field, fe.Type)
+		}
+		return
+	}
+
+	groupExtractor, ok := extractors.Lookup(*groupBy)
+	if !ok {
+		cli.Fatalf(cli.ExitUsage, "unknown --group-by field %q", *groupBy)
+	}
+	effort, err := jira.ParseEffortSource(*effortStr)
+	if err != nil {
+		cli.Fatal(err)
+	}
+
+	var conditions []jira.Condition
+	for _, expr := range where {
+		c, err := jira.ParseCondition(expr)
+		if err != nil {
+			cli.Fatal(err)
+		}
+		if _, ok := extractors.Lookup(c.Name); !ok {
+			cli.Fatalf(cli.ExitUsage, "unknown --where field %q", c.Name)
+		}
+		conditions = append(conditions, c)
+	}
+
+	version, _ := store.Version()
+	renderOpts.SetCacheVersion(version)
+	if table, ok := renderOpts.LoadCached("field_report", version); ok {
+		if err := renderOpts.Write(table); err != nil {
+			cli.Fatal(err)
+		}
+		return
+	}
+
+	issues := jira.LoadIssues(store, cacheFlags.Project)
+	if *rulesPath != "" {
+		classifier, err := jira.LoadClassifier(*rulesPath, extractors)
+		if err != nil {
+			cli.Fatal(err)
+		}
+		classifier.Apply(issues)
+	}
+
+	totals := make(map[string]*GroupTotals)
+	for _, issue := range issues {
+		matched := true
+		for _, c := range conditions {
+			if ok, _ := extractors.MatchIssue(c, issue.Fields); !ok {
+				matched = false
+				break
+			}
+		}
+		if !matched {
+			continue
+		}
+
+		group := "(none)"
+		if value, ok := groupExtractor.Extract(issue.Fields); ok {
+			group = value.Text
+		}
+		t, ok := totals[group]
+		if !ok {
+			t = &GroupTotals{Group: group}
+			totals[group] = t
+		}
+		t.Issues++
+		t.Effort += effort.IssueEffort(issue)
+	}
+
+	var rows []*GroupTotals
+	for _, t := range totals {
+		rows = append(rows, t)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		return rows[i].Group < rows[j].Group
+	})
+
+	table := render.NewTable(groupExtractor.Name, "issues", effort.ColumnName())
+	table.SetNumeric(effort.ColumnName())
+	for _, t := range rows {
+		table.Append(t.Group, fmt.Sprintf("%d", t.Issues), fmt.Sprintf("%.1f", t.Effort))
+	}
+	renderOpts.StoreCached("field_report", version, table)
+	if err := renderOpts.Write(table); err != nil {
+		cli.Fatal(err)
+	}
+}
@@ -0,0 +1,249 @@
+// Package forecast estimates when a backlog will be finished by Monte Carlo
+// simulation: each trial draws weeks at random from the weekly throughput
+// of the sample window, as counted from the resolutions in the cache, until
+// the backlog is used up, and the percentiles of the weeks it took are the
+// forecast.
+package forecast
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/events"
+	"github.com/jctanner/rhoai-jira/internal/jira"
+	"github.com/jctanner/rhoai-jira/internal/query"
+	"github.com/jctanner/rhoai-jira/internal/render"
+	"github.com/jctanner/rhoai-jira/internal/tools"
+)
+
+// maxWeeks bounds a trial when the sample holds mostly empty weeks.
+const maxWeeks = 520
+
+// ResolvedAt is when an issue that is resolved now was last resolved: the
+// last resolution event of its changelog, or its resolution date when the
+// changelog holds none. Issues resolved and reopened count once, when
+// they are resolved for good.
+func ResolvedAt(issue jira.JiraIssueWithSprints, changelog jira.Changelog) (time.Time, bool) {
+	if issue.Fields.Resolution == nil {
+		return time.Time{}, false
+	}
+	var at time.Time
+	for _, e := range events.Filter(events.Normalize(issue.Key, changelog), events.ResolutionChanged) {
+		if e.To != "" {
+			at = e.At
+		}
+	}
+	if !at.IsZero() {
+		return at, true
+	}
+	resolved, err := issue.ResolvedTime()
+	return resolved, err == nil
+}
+
+// weekStart is the Monday starting the week of t.
+func weekStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day()-(int(t.Weekday())+6)%7, 0, 0, 0, 0, t.Location())
+}
+
+// Throughput counts the resolutions in each of the weeks complete weeks
+// before the week of until, oldest first.
+func Throughput(resolved []time.Time, until time.Time, weeks int) (start time.Time, counts []int) {
+	end := weekStart(until)
+	start = end.AddDate(0, 0, -7*weeks)
+	counts = make([]int, weeks)
+	for _, at := range resolved {
+		at = at.In(until.Location())
+		if at.Before(start) || !at.Before(end) {
+			continue
+		}
+		// Weeks are counted by date, as they span daylight saving changes.
+		for i := range counts {
+			if at.Before(start.AddDate(0, 0, 7*(i+1))) {
+				counts[i]++
+				break
+			}
+		}
+	}
+	return start, counts
+}
+
+// Simulate runs trials, each drawing weeks from throughput until backlog
+// issues are resolved, and returns the weeks every trial took, sorted.
+// Trials give up after maxWeeks.
+func Simulate(backlog int, throughput []int, trials int, r *rand.Rand) []int {
+	weeks := make([]int, trials)
+	for t := range weeks {
+		left, n := backlog, 0
+		for left > 0 && n < maxWeeks {
+			left -= throughput[r.Intn(len(throughput))]
+			n++
+		}
+		weeks[t] = n
+	}
+	sort.Ints(weeks)
+	return weeks
+}
+
+// Percentile returns the weeks within which p percent of the sorted trials
+// finished.
+func Percentile(sorted []int, p float64) int {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(float64(len(sorted))*p/100+0.5) - 1
+	return sorted[min(max(i, 0), len(sorted)-1)]
+}
+
+func Main(args []string) {
+	fs := flag.NewFlagSet("forecast", flag.ExitOnError)
+	cacheFlags := cli.AddCacheFlags(fs)
+	remaining := fs.Int("remaining", 0, "Number of issues left to do")
+	epic := fs.String("epic", "", "Forecast the open issues of this epic")
+	backlogQuery := fs.String("backlog", "", "Forecast the open issues matching this JQL-lite query")
+	throughputQuery := fs.String("query", "", "Only count the resolutions of issues matching this JQL-lite query, such as the team's")
+	window := fs.Int("window", 12, "Number of complete weeks to sample throughput from")
+	until := fs.String("until", "now()", "End of the sample window (2025-06-30 or -4w); forecasts start from it")
+	trials := fs.Int("trials", 10000, "Number of simulations")
+	seed := fs.Int64("seed", 0, "Random seed (0 for a random one)")
+	var percentiles tools.StringList
+	fs.Var(&percentiles, "percentile", "Percentiles to report (default 50,85,95)")
+	var renderOpts render.Options
+	render.AddFlags(fs, &renderOpts)
+	fs.Parse(args)
+
+	set := 0
+	for _, given := range []bool{*remaining > 0, *epic != "", *backlogQuery != ""} {
+		if given {
+			set++
+		}
+	}
+	if set != 1 {
+		cli.Fatalf(cli.ExitUsage, "Exactly one of --remaining, --epic or --backlog must be provided.")
+	}
+	if *window <= 0 || *trials <= 0 {
+		cli.Fatalf(cli.ExitUsage, "--window and --trials must be positive")
+	}
+	if len(percentiles) == 0 {
+		percentiles = tools.StringList{"50", "85", "95"}
+	}
+	var levels []float64
+	for _, p := range percentiles {
+		v, err := strconv.ParseFloat(strings.TrimSuffix(p, "%"), 64)
+		if err != nil || v <= 0 || v > 100 {
+			cli.Fatalf(cli.ExitUsage, "invalid --percentile %q", p)
+		}
+		levels = append(levels, v)
+	}
+	sort.Float64s(levels)
+	untilTime, err := query.ParseDate(*until, time.Now())
+	if err != nil {
+		cli.Fatal(cli.WithCode(cli.ExitUsage, err))
+	}
+	var sample, backlogFilter *query.Query
+	if *throughputQuery != "" {
+		if sample, err = query.Parse(*throughputQuery); err != nil {
+			cli.Fatal(cli.WithCode(cli.ExitUsage, err))
+		}
+	}
+	if *backlogQuery != "" {
+		if backlogFilter, err = query.Parse(*backlogQuery); err != nil {
+			cli.Fatal(cli.WithCode(cli.ExitUsage, err))
+		}
+	}
+
+	store, err := cacheFlags.Open()
+	if err != nil {
+		cli.Fatal(err)
+	}
+	defer store.Close()
+	renderOpts.SetSource(store)
+
+	issues := jira.LoadIssues(store, cacheFlags.Project)
+	backlog, scope := *remaining, fmt.Sprintf("%d issues", *remaining)
+	if *remaining == 0 {
+		var candidates []jira.JiraIssueWithSprints
+		var what string
+		if backlogFilter != nil {
+			candidates, what = backlogFilter.Filter(issues), fmt.Sprintf("open issues matching %q", *backlogQuery)
+		} else {
+			key := strings.ToUpper(*epic)
+			for _, issue := range issues {
+				if issue.EpicKey() == key {
+					candidates = append(candidates, issue)
+				}
+			}
+			what = "open issues of epic " + key
+		}
+		for _, issue := range candidates {
+			if !issue.IsDone() {
+				backlog++
+			}
+		}
+		if backlog == 0 {
+			cli.Fatalf(cli.ExitNoData, "no %s in the cache", what)
+		}
+		scope = fmt.Sprintf("%d %s", backlog, what)
+	}
+
+	sampled := issues
+	if sample != nil {
+		sampled = sample.Filter(issues)
+	}
+	var resolved []time.Time
+	var coverage jira.ChangelogCoverage
+	for _, issue := range sampled {
+		if issue.Fields.Resolution == nil {
+			continue
+		}
+		changelog, err := store.ReadChangelog(issue.Key)
+		coverage.Add(err)
+		if at, ok := ResolvedAt(issue, changelog); ok {
+			resolved = append(resolved, at)
+		}
+	}
+	if coverage.Issues > 0 {
+		coverage.Log()
+	}
+	start, throughput := Throughput(resolved, untilTime, *window)
+	total := 0
+	for _, n := range throughput {
+		total += n
+	}
+	if total == 0 {
+		cli.Fatalf(cli.ExitNoData, "no issues were resolved in the %d weeks from %s; move the window with --until", *window, start.Format("2006-01-02"))
+	}
+	counts := make([]string, len(throughput))
+	for i, n := range throughput {
+		counts[i] = strconv.Itoa(n)
+	}
+	mean := float64(total) / float64(len(throughput))
+	cli.Infof("throughput of the %d weeks from %s: %s (%.1f a week)", *window, start.Format("2006-01-02"), strings.Join(counts, " "), mean)
+
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+	weeks := Simulate(backlog, throughput, *trials, rand.New(rand.NewSource(*seed)))
+	if weeks[len(weeks)-1] >= maxWeeks {
+		cli.Warnf("some trials did not finish within %d weeks", maxWeeks)
+	}
+
+	renderOpts.Title = "Forecast: " + scope
+	renderOpts.AddNote("%d trials drawing from the throughput of the %d weeks from %s: %.1f issues a week", *trials, *window, start.Format("2006-01-02"), mean)
+	table := render.NewTable("percentile", "weeks", "completion_date")
+	table.SetNumeric("percentile")
+	for _, p := range levels {
+		w := Percentile(weeks, p)
+		date := untilTime.AddDate(0, 0, 7*w)
+		cli.Infof("%s: %g%% chance of finishing within %d weeks, by %s", scope, p, w, date.Format("2006-01-02"))
+		table.Append(fmt.Sprintf("%g", p), strconv.Itoa(w), date.Format("2006-01-02"))
+	}
+	if err := renderOpts.Write(table); err != nil {
+		cli.Fatal(err)
+	}
+}
@@ -0,0 +1,130 @@
+package handoffs
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/jira"
+	"github.com/jctanner/rhoai-jira/internal/render"
+)
+
+type issueHandoffs struct {
+	issue    jira.JiraIssueWithSprints
+	chain    []string
+	handoffs []jira.Handoff
+}
+
+func Main(args []string) {
+	fs := flag.NewFlagSet("handoffs", flag.ExitOnError)
+	cacheFlags := cli.AddCacheFlags(fs)
+	view := fs.String("view", "issues", "Output: issues (handoffs per issue), pairs (most common from/to handoffs) or summary")
+	minHandoffs := fs.Int("min-handoffs", 3, "Issues with at least this many handoffs count as excessive; the issues view lists only those")
+	storyType := fs.String("type", "Story", "Issue type whose completed issues give the average handoffs")
+	var renderOpts render.Options
+	render.AddFlags(fs, &renderOpts)
+	fs.Parse(args)
+
+	store, err := cacheFlags.Open()
+	if err != nil {
+		cli.Fatal(err)
+	}
+	defer store.Close()
+	renderOpts.SetSource(store)
+
+	issues := jira.LoadIssues(store, cacheFlags.Project)
+	if len(issues) == 0 {
+		cli.Fatalf(cli.ExitNoData, "no cached issues")
+	}
+	var all []issueHandoffs
+	var handoffs []jira.Handoff
+	var coverage jira.ChangelogCoverage
+	for _, issue := range issues {
+		changelog, err := store.ReadChangelog(issue.Key)
+		coverage.Add(err)
+		if err != nil {
+			continue
+		}
+		chain, h := jira.AssigneeChain(changelog)
+		all = append(all, issueHandoffs{issue: issue, chain: chain, handoffs: h})
+		handoffs = append(handoffs, h...)
+	}
+
+	completed, completedHandoffs, excessive := 0, 0, 0
+	for _, ih := range all {
+		if len(ih.handoffs) >= *minHandoffs {
+			excessive++
+		}
+		if strings.EqualFold(ih.issue.Fields.IssueType.Name, *storyType) && ih.issue.IsDone() {
+			completed++
+			completedHandoffs += len(ih.handoffs)
+		}
+	}
+	average := 0.0
+	if completed > 0 {
+		average = float64(completedHandoffs) / float64(completed)
+	}
+	coverage.Log()
+	cli.Infof("%d handoffs across %d issues; %.2f per completed %s; %d issues with %d or more", len(handoffs), len(all), average, *storyType, excessive, *minHandoffs)
+
+	var table *render.Table
+	switch *view {
+	case "issues":
+		sort.SliceStable(all, func(i, j int) bool { return len(all[i].handoffs) > len(all[j].handoffs) })
+		table = render.NewTable("key", "type", "status", "handoffs", "assignees", "current", "chain")
+		for _, ih := range all {
+			if len(ih.handoffs) < *minHandoffs {
+				continue
+			}
+			table.Append(
+				ih.issue.Key,
+				ih.issue.Fields.IssueType.Name,
+				ih.issue.Fields.Status.Name,
+				strconv.Itoa(len(ih.handoffs)),
+				strconv.Itoa(distinct(ih.chain)),
+				ih.issue.AssigneeID(),
+				strings.Join(ih.chain, " > "),
+			)
+		}
+	case "pairs":
+		table = render.NewTable("from", "to", "handoffs")
+		for _, p := range jira.CountHandoffPairs(handoffs) {
+			table.Append(p.From, p.To, strconv.Itoa(p.Count))
+		}
+	case "summary":
+		table = render.NewTable("issues", "handoffs", "issues_with_handoffs", "excessive", "excessive_pct", "completed", "avg_handoffs_per_completed")
+		table.SetNumeric("excessive_pct", "avg_handoffs_per_completed")
+		withHandoffs := 0
+		for _, ih := range all {
+			if len(ih.handoffs) > 0 {
+				withHandoffs++
+			}
+		}
+		table.Append(
+			strconv.Itoa(len(all)),
+			strconv.Itoa(len(handoffs)),
+			strconv.Itoa(withHandoffs),
+			strconv.Itoa(excessive),
+			fmt.Sprintf("%.1f", 100*float64(excessive)/float64(len(all))),
+			strconv.Itoa(completed),
+			fmt.Sprintf("%.2f", average),
+		)
+	default:
+		cli.Fatalf(cli.ExitUsage, "--view must be issues, pairs or summary")
+	}
+	if err := renderOpts.Write(table); err != nil {
+		cli.Fatal(err)
+	}
+}
+
+// distinct counts the different people in an assignee chain.
+func distinct(chain []string) int {
+	seen := map[string]bool{}
+	for _, name := range chain {
+		seen[name] = true
+	}
+	return len(seen)
+}
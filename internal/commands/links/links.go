@@ -0,0 +1,212 @@
+package links
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/jira"
+	"github.com/jctanner/rhoai-jira/internal/render"
+	"github.com/jctanner/rhoai-jira/internal/tools"
+)
+
+func Main(args []string) {
+	fs := flag.NewFlagSet("links", flag.ExitOnError)
+	cacheFlags := cli.AddCacheFlags(fs)
+	format := fs.String("format", "dot", "Output format: dot (Graphviz), graphml or json")
+	var types, epics tools.StringList
+	fs.Var(&types, "types", "Comma-separated relationships to keep, such as blocks,clones,epic,parent (default all)")
+	fs.Var(&epics, "epic", "Comma-separated epics whose issues and their direct relationships are kept (default all)")
+	cluster := fs.Bool("cluster", true, "Group the issues of each epic in a DOT cluster")
+	var renderOpts render.Options
+	fs.StringVar(&renderOpts.Out, "out", "", "Output file (omit to print to stdout)")
+	fs.Parse(args)
+
+	if *format != "dot" && *format != "graphml" && *format != "json" {
+		cli.Fatalf(cli.ExitUsage, "--format must be dot, graphml or json")
+	}
+
+	store, err := cacheFlags.Open()
+	if err != nil {
+		cli.Fatal(err)
+	}
+	defer store.Close()
+
+	issues := jira.LoadIssues(store, cacheFlags.Project)
+	if len(issues) == 0 {
+		cli.Fatalf(cli.ExitNoData, "no cached issues")
+	}
+	graph := jira.BuildLinkGraph(issues, jira.LinkGraphOptions{Types: types, Epics: epics})
+	cli.Infof("%d issues and %d relationships", len(graph.Nodes), len(graph.Edges))
+
+	w, _, err := renderOpts.Create()
+	if err != nil {
+		cli.Fatal(err)
+	}
+	switch *format {
+	case "dot":
+		err = writeDOT(w, graph, *cluster)
+	case "graphml":
+		err = writeGraphML(w, graph)
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(graph)
+	}
+	if closeErr := w.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		cli.Fatal(err)
+	}
+}
+
+// edgeStyle draws blocking links heavy and hierarchy edges light.
+func edgeStyle(e jira.GraphEdge) string {
+	switch {
+	case e.Relation == jira.RelationEpic || e.Relation == jira.RelationParent:
+		return `style=dotted, color="gray50"`
+	case strings.HasPrefix(e.Relation, "blocks") || strings.HasPrefix(e.Relation, "depends"):
+		return `color="firebrick", penwidth=2`
+	}
+	return `color="gray30"`
+}
+
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
+
+func dotNode(w io.Writer, indent string, n jira.GraphNode) {
+	label := n.Key
+	if n.Summary != "" {
+		label += "\n" + truncate(n.Summary, 40)
+	}
+	attrs := fmt.Sprintf("label=%q", label)
+	if !n.Cached {
+		attrs += ", style=dashed"
+	} else if n.Type == "Epic" {
+		attrs += `, style=filled, fillcolor="lightsteelblue"`
+	}
+	fmt.Fprintf(w, "%s%q [%s];\n", indent, n.Key, attrs)
+}
+
+// writeDOT writes the graph for Graphviz. With cluster set, the issues of
+// each epic are drawn together in a box named after the epic.
+func writeDOT(w io.Writer, g *jira.LinkGraph, cluster bool) error {
+	fmt.Fprintln(w, "digraph links {")
+	fmt.Fprintln(w, "  rankdir=LR;")
+	fmt.Fprintln(w, `  node [shape=box, fontsize=10];`)
+
+	groups := map[string][]jira.GraphNode{}
+	for _, n := range g.Nodes {
+		epic := ""
+		if cluster && n.Type != "Epic" {
+			epic = n.Epic
+		}
+		groups[epic] = append(groups[epic], n)
+	}
+	var epics []string
+	for epic := range groups {
+		if epic != "" {
+			epics = append(epics, epic)
+		}
+	}
+	sort.Strings(epics)
+	for i, epic := range epics {
+		fmt.Fprintf(w, "  subgraph cluster_%d {\n", i)
+		fmt.Fprintf(w, "    label=%q;\n", epic)
+		for _, n := range groups[epic] {
+			dotNode(w, "    ", n)
+		}
+		fmt.Fprintln(w, "  }")
+	}
+	for _, n := range groups[""] {
+		dotNode(w, "  ", n)
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(w, "  %q -> %q [label=%q, %s];\n", e.From, e.To, e.Relation, edgeStyle(e))
+	}
+	_, err := fmt.Fprintln(w, "}")
+	return err
+}
+
+type graphML struct {
+	XMLName xml.Name     `xml:"graphml"`
+	NS      string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   struct {
+		ID          string        `xml:"id,attr"`
+		EdgeDefault string        `xml:"edgedefault,attr"`
+		Nodes       []graphMLItem `xml:"node"`
+		Edges       []graphMLItem `xml:"edge"`
+	} `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr"`
+	Type string `xml:"attr.type,attr"`
+}
+
+type graphMLItem struct {
+	ID     string        `xml:"id,attr,omitempty"`
+	Source string        `xml:"source,attr,omitempty"`
+	Target string        `xml:"target,attr,omitempty"`
+	Data   []graphMLData `xml:"data"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+// writeGraphML writes the graph for yEd, Gephi or networkx, with the
+// issue and link details as attributes.
+func writeGraphML(w io.Writer, g *jira.LinkGraph) error {
+	doc := graphML{NS: "http://graphml.graphdrawing.org/xmlns"}
+	for _, name := range []string{"summary", "type", "status", "epic"} {
+		doc.Keys = append(doc.Keys, graphMLKey{ID: name, For: "node", Name: name, Type: "string"})
+	}
+	doc.Keys = append(doc.Keys,
+		graphMLKey{ID: "cached", For: "node", Name: "cached", Type: "boolean"},
+		graphMLKey{ID: "linktype", For: "edge", Name: "type", Type: "string"},
+		graphMLKey{ID: "relation", For: "edge", Name: "relation", Type: "string"},
+	)
+	doc.Graph.ID = "links"
+	doc.Graph.EdgeDefault = "directed"
+	for _, n := range g.Nodes {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, graphMLItem{ID: n.Key, Data: []graphMLData{
+			{"summary", n.Summary},
+			{"type", n.Type},
+			{"status", n.Status},
+			{"epic", n.Epic},
+			{"cached", fmt.Sprint(n.Cached)},
+		}})
+	}
+	for _, e := range g.Edges {
+		doc.Graph.Edges = append(doc.Graph.Edges, graphMLItem{Source: e.From, Target: e.To, Data: []graphMLData{
+			{"linktype", e.Type},
+			{"relation", e.Relation},
+		}})
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,129 @@
+package list
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/jira"
+	"github.com/jctanner/rhoai-jira/internal/query"
+	"github.com/jctanner/rhoai-jira/internal/render"
+)
+
+func Main(args []string) {
+	if len(args) > 0 && metadataKinds[args[0]] {
+		listMetadata(args[0], args[1:])
+		return
+	}
+
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	cacheFlags := cli.AddCacheFlags(fs)
+	rulesPath := fs.String("rules", "", "JSON classification rules file; enables the category field")
+	keysOnly := fs.Bool("keys-only", false, "Print only matching issue keys")
+	records := fs.Bool("records", false, "Print each matching issue as its cached JSON record, one per line, for jq")
+	var renderOpts render.Options
+	render.AddFlags(fs, &renderOpts)
+	render.AddCacheFlag(fs, &renderOpts)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: %s [flags] '<jql-lite>'\n\n", fs.Name())
+		fmt.Fprintf(fs.Output(), "example: %s 'project = RHOAIENG AND status IN (\"In Progress\", Review) AND updated >= -7d ORDER BY updated DESC'\n", fs.Name())
+		fmt.Fprintf(fs.Output(), "         %s --records 'status = \"In Progress\" AND sprint ~ \"2025-Q1\" AND points >= 5'\n\n", fs.Name())
+		fmt.Fprintf(fs.Output(), "       %s boards|versions|components|statuses|sprints [flags]\n\n", fs.Name())
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *records && *keysOnly {
+		cli.Fatalf(cli.ExitUsage, "--records and --keys-only cannot be combined")
+	}
+
+	store, err := cacheFlags.Open()
+	if err != nil {
+		cli.Fatal(err)
+	}
+	defer store.Close()
+
+	q, err := query.Parse(strings.Join(fs.Args(), " "))
+	if err != nil {
+		cli.Fatal(cli.WithCode(cli.ExitUsage, fmt.Errorf("invalid query: %w", err)))
+	}
+
+	matching := func() []jira.JiraIssueWithSprints {
+		issues := jira.LoadIssues(store, cacheFlags.Project)
+		if *rulesPath != "" {
+			classifier, err := jira.LoadClassifier(*rulesPath, nil)
+			if err != nil {
+				cli.Fatal(err)
+			}
+			classifier.Apply(issues)
+		}
+		return q.Filter(issues)
+	}
+	if *records {
+		if err := writeRecords(renderOpts, matching()); err != nil {
+			cli.Fatal(err)
+		}
+		return
+	}
+
+	version, _ := store.Version()
+	renderOpts.SetCacheVersion(version)
+	table, cached := renderOpts.LoadCached("query", version)
+	if !cached {
+		table = render.NewTable("key", "type", "status", "assignee", "updated", "summary")
+		for _, issue := range matching() {
+			table.Append(
+				issue.Key,
+				issue.Fields.IssueType.Name,
+				issue.Fields.Status.Name,
+				issue.AssigneeID(),
+				issue.Fields.Updated,
+				issue.Fields.Summary,
+			)
+		}
+		renderOpts.StoreCached("query", version, table)
+	}
+
+	if *keysOnly {
+		if err := renderOpts.Apply(table); err != nil {
+			cli.Fatal(err)
+		}
+		for _, row := range table.Rows {
+			fmt.Println(row[0])
+		}
+		return
+	}
+
+	if err := renderOpts.Write(table); err != nil {
+		cli.Fatal(err)
+	}
+}
+
+// record is an issue as cached, with every field Jira returned, plus the
+// categories a classifier assigned.
+type record struct {
+	Key        string                     `json:"key"`
+	Fields     map[string]json.RawMessage `json:"fields"`
+	Fetched    string                     `json:"fetched,omitempty"`
+	Categories []string                   `json:"categories,omitempty"`
+}
+
+// writeRecords prints the issues as NDJSON to --out or stdout.
+func writeRecords(opts render.Options, issues []jira.JiraIssueWithSprints) error {
+	w, _, err := opts.Create()
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	for _, issue := range issues {
+		rec := record{Key: issue.Key, Fields: issue.Fields.Raw, Fetched: issue.Fetched, Categories: issue.Fields.Categories}
+		if err = enc.Encode(rec); err != nil {
+			break
+		}
+	}
+	if closeErr := w.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,115 @@
+package list
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/jira"
+	"github.com/jctanner/rhoai-jira/internal/render"
+)
+
+// metadataKinds are the "list <kind>" forms that print distinct field values
+// from the cache instead of running a query.
+var metadataKinds = map[string]bool{
+	"boards":     true,
+	"versions":   true,
+	"components": true,
+	"statuses":   true,
+	"sprints":    true,
+}
+
+// indexedKinds only need the sprints and statuses of issues, which the
+// index of a directory cache holds.
+var indexedKinds = map[string]bool{
+	"boards":   true,
+	"statuses": true,
+	"sprints":  true,
+}
+
+// loadMetadataIssues reads the issues a metadata kind is listed from,
+// from the cache index when it holds all that is needed.
+func loadMetadataIssues(store jira.Store, kind, project string) []jira.JiraIssueWithSprints {
+	if dirStore, ok := store.(*jira.DirStore); ok && indexedKinds[kind] && !dirStore.IncludeArchived {
+		index, err := dirStore.Index()
+		if err == nil {
+			return index.Summaries(project)
+		}
+		cli.Warnf("cache index: %v", err)
+	}
+	return jira.LoadIssues(store, project)
+}
+
+func listMetadata(kind string, args []string) {
+	fs := flag.NewFlagSet("list "+kind, flag.ExitOnError)
+	cacheFlags := cli.AddCacheFlags(fs)
+	var renderOpts render.Options
+	render.AddFlags(fs, &renderOpts)
+	fs.Parse(args)
+
+	store, err := cacheFlags.Open()
+	if err != nil {
+		cli.Fatal(err)
+	}
+	defer store.Close()
+	renderOpts.SetSource(store)
+	issues := loadMetadataIssues(store, kind, cacheFlags.Project)
+
+	var table *render.Table
+	switch kind {
+	case "boards":
+		table = render.NewTable("board_id", "issues", "sprints", "active_sprints", "latest_sprint")
+		for _, b := range jira.CachedBoards(issues) {
+			latest := ""
+			if len(b.Sprints) > 0 {
+				latest = b.Sprints[len(b.Sprints)-1]
+			}
+			table.Append(strconv.Itoa(b.ID), strconv.Itoa(b.Issues), strconv.Itoa(len(b.Sprints)), strings.Join(b.Active, "; "), latest)
+		}
+	case "versions":
+		table = valueTable(jira.CachedVersions(issues), "project", "version", "release")
+	case "components":
+		table = valueTable(jira.CachedComponents(issues), "project", "component", "")
+	case "statuses":
+		table = valueTable(jira.CachedStatuses(issues), "", "status", "category")
+	case "sprints":
+		table = valueTable(jira.CachedSprints(issues), "", "sprint", "state")
+	default:
+		cli.Fatalf(cli.ExitUsage, "unknown list kind %q", kind)
+	}
+
+	if err := renderOpts.Write(table); err != nil {
+		cli.Fatal(err)
+	}
+}
+
+// valueTable builds a table of metadata values; empty column names are left
+// out.
+func valueTable(values []jira.MetadataValue, projectCol, nameCol, detailCol string) *render.Table {
+	var headers []string
+	if projectCol != "" {
+		headers = append(headers, projectCol)
+	}
+	headers = append(headers, nameCol)
+	if detailCol != "" {
+		headers = append(headers, detailCol)
+	}
+	headers = append(headers, "issues")
+
+	table := render.NewTable(headers...)
+	for _, v := range values {
+		var row []string
+		if projectCol != "" {
+			row = append(row, v.Project)
+		}
+		row = append(row, v.Name)
+		if detailCol != "" {
+			row = append(row, v.Detail)
+		}
+		row = append(row, fmt.Sprintf("%d", v.Issues))
+		table.Append(row...)
+	}
+	return table
+}
@@ -0,0 +1,231 @@
+package live
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jctanner/rhoai-jira/internal/jira"
+)
+
+// Move is a status transition made on an issue in the sprint.
+type Move struct {
+	Key     string    `json:"key"`
+	Summary string    `json:"summary"`
+	From    string    `json:"from"`
+	To      string    `json:"to"`
+	By      string    `json:"by,omitempty"`
+	At      time.Time `json:"at"`
+}
+
+// Blocker is an unresolved issue holding up an open issue in the sprint.
+type Blocker struct {
+	Key       string    `json:"key"`
+	Summary   string    `json:"summary"`
+	BlockedBy string    `json:"blockedBy"`
+	Status    string    `json:"status"`
+	Since     time.Time `json:"since,omitempty"`
+	New       bool      `json:"new"`
+}
+
+// Board is one refresh of the sprint room view.
+type Board struct {
+	Sprint      string    `json:"sprint"`
+	State       string    `json:"state,omitempty"`
+	Start       time.Time `json:"start,omitempty"`
+	End         time.Time `json:"end,omitempty"`
+	Effort      string    `json:"effort"`
+	Issues      int       `json:"issues"`
+	Open        int       `json:"open"`
+	Scope       float64   `json:"scope"`
+	Remaining   float64   `json:"remaining"`
+	MovedToday  []Move    `json:"movedToday"`
+	Blockers    []Blocker `json:"blockers"`
+	LastSync    string    `json:"lastSync,omitempty"`
+	SyncError   string    `json:"syncError,omitempty"`
+	RefreshedAt time.Time `json:"refreshedAt"`
+}
+
+// NewBlockers counts the blockers added within the blocker window.
+func (b *Board) NewBlockers() int {
+	n := 0
+	for _, bl := range b.Blockers {
+		if bl.New {
+			n++
+		}
+	}
+	return n
+}
+
+// DaysLeft is the number of calendar days until the sprint ends.
+func (b *Board) DaysLeft() int {
+	if b.End.IsZero() || b.RefreshedAt.After(b.End) {
+		return 0
+	}
+	return int(b.End.Sub(b.RefreshedAt).Hours()/24) + 1
+}
+
+func parseSprintTime(s string) time.Time {
+	for _, layout := range []string{time.RFC3339Nano, jira.JiraTimeLayout, "2006-01-02T15:04:05.000Z0700"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// activeSprint picks the active sprint with the most cached issues, breaking
+// ties by the most recent start.
+func activeSprint(issues []jira.JiraIssueWithSprints) (jira.Sprint, bool) {
+	counts := map[string]int{}
+	sprints := map[string]jira.Sprint{}
+	for _, issue := range issues {
+		for _, s := range issue.Fields.Sprints {
+			if !strings.EqualFold(s.State, "active") {
+				continue
+			}
+			counts[s.Name]++
+			sprints[s.Name] = s
+		}
+	}
+	var best jira.Sprint
+	found := false
+	for name, s := range sprints {
+		if !found || counts[name] > counts[best.Name] ||
+			counts[name] == counts[best.Name] && parseSprintTime(s.StartDate).After(parseSprintTime(best.StartDate)) {
+			best, found = s, true
+		}
+	}
+	return best, found
+}
+
+// findSprint returns the sprint with the given name as seen on any issue.
+func findSprint(issues []jira.JiraIssueWithSprints, name string) (jira.Sprint, bool) {
+	for _, issue := range issues {
+		for _, s := range issue.Fields.Sprints {
+			if s.Name == name {
+				return s, true
+			}
+		}
+	}
+	return jira.Sprint{}, false
+}
+
+func inSprint(issue jira.JiraIssueWithSprints, name string) bool {
+	for _, s := range issue.Fields.Sprints {
+		if s.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// linkAdded finds when a link to blocker was added according to the
+// changelog, which records links as "Link" items naming the other issue.
+func linkAdded(changelog jira.Changelog, blocker string) time.Time {
+	var added time.Time
+	for _, h := range changelog.Histories {
+		for _, item := range h.Items {
+			if !strings.EqualFold(item.Field, "Link") || !strings.Contains(item.ToString, blocker) {
+				continue
+			}
+			if at, err := jira.ParseJiraTime(h.Created); err == nil && at.After(added) {
+				added = at
+			}
+		}
+	}
+	return added
+}
+
+// BuildBoard computes the view of the named sprint (or the active sprint when
+// sprint is "active") from the cache.
+func BuildBoard(store jira.Store, project, sprint string, effort jira.EffortSource, blockerWindow time.Duration, now time.Time) (*Board, error) {
+	issues := jira.LoadIssues(store, project)
+	var s jira.Sprint
+	var ok bool
+	if sprint == "active" {
+		s, ok = activeSprint(issues)
+		if !ok {
+			return nil, fmt.Errorf("no active sprint among the cached issues")
+		}
+	} else if s, ok = findSprint(issues, sprint); !ok {
+		return nil, fmt.Errorf("no cached issues are in sprint %q", sprint)
+	}
+
+	board := &Board{
+		Sprint:      s.Name,
+		State:       s.State,
+		Start:       parseSprintTime(s.StartDate),
+		End:         parseSprintTime(s.EndDate),
+		Effort:      effort.ColumnName(),
+		RefreshedAt: now,
+	}
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	for _, issue := range issues {
+		if !inSprint(issue, s.Name) {
+			continue
+		}
+		board.Issues++
+		board.Scope += effort.IssueEffort(issue)
+		board.Remaining += effort.RemainingEffort(issue)
+
+		changelog, _ := store.ReadChangelog(issue.Key)
+		for _, h := range changelog.Histories {
+			at, err := jira.ParseJiraTime(h.Created)
+			if err != nil || at.Before(midnight) {
+				continue
+			}
+			for _, item := range h.Items {
+				if item.Field != "status" {
+					continue
+				}
+				move := Move{Key: issue.Key, Summary: issue.Fields.Summary, From: item.FromString, To: item.ToString, At: at}
+				if h.Author != nil {
+					move.By = h.Author.DisplayName
+				}
+				board.MovedToday = append(board.MovedToday, move)
+			}
+		}
+
+		if issue.IsDone() {
+			continue
+		}
+		board.Open++
+		for _, blocker := range issue.Blockers() {
+			if blocker.Fields.Status.IsDone() {
+				continue
+			}
+			since := linkAdded(changelog, blocker.Key)
+			board.Blockers = append(board.Blockers, Blocker{
+				Key:       issue.Key,
+				Summary:   issue.Fields.Summary,
+				BlockedBy: blocker.Key,
+				Status:    blocker.Fields.Status.Name,
+				Since:     since,
+				New:       !since.IsZero() && now.Sub(since) <= blockerWindow,
+			})
+		}
+	}
+
+	sort.Slice(board.MovedToday, func(i, j int) bool {
+		return board.MovedToday[i].At.After(board.MovedToday[j].At)
+	})
+	sort.SliceStable(board.Blockers, func(i, j int) bool {
+		a, b := board.Blockers[i], board.Blockers[j]
+		if a.New != b.New {
+			return a.New
+		}
+		return a.Since.After(b.Since)
+	})
+
+	if project != "" {
+		if state, err := store.ReadSyncState(strings.ToUpper(project)); err == nil {
+			board.LastSync = state.LastSuccess
+			if state.ConsecutiveFailures > 0 {
+				board.SyncError = state.LastError
+			}
+		}
+	}
+	return board, nil
+}
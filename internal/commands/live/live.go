@@ -0,0 +1,189 @@
+package live
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/jira"
+)
+
+func Main(args []string) {
+	fs := flag.NewFlagSet("live", flag.ExitOnError)
+	cacheFlags := cli.AddCacheFlags(fs)
+	sprint := fs.String("sprint", "active", "Sprint to show, or \"active\" for the active sprint in the cache")
+	effortStr := fs.String("effort", "points", "Effort source (points, time, count)")
+	refresh := fs.Duration("refresh", 30*time.Second, "How often the view is recomputed from the cache")
+	blockerWindow := fs.Duration("blocker-window", 24*time.Hour, "Blockers linked within this long are shown as new")
+	httpAddr := fs.String("http", "", "Serve the view as a web page on this address (e.g. :8080) instead of the terminal")
+	baseURL := fs.String("base-url", "", "Keep the cache synced from this Jira with fetch --daemon (credentials come from JIRA_TOKEN etc.)")
+	interval := fs.Duration("interval", 15*time.Minute, "Time between syncs when --base-url is set")
+	syncLog := fs.String("sync-log", "", "Append the log of the background sync to this file")
+	width := fs.Int("width", 100, "Terminal width of the text view")
+	fs.Parse(args)
+
+	effort, err := jira.ParseEffortSource(*effortStr)
+	if err != nil {
+		cli.Fatal(err)
+	}
+	if *baseURL != "" && cacheFlags.Project == "" {
+		cli.Fatalf(cli.ExitUsage, "--project must be provided to sync with --base-url.")
+	}
+	store, err := cacheFlags.OpenUnlocked()
+	if err != nil {
+		cli.Fatal(err)
+	}
+	defer store.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if *baseURL != "" {
+		syncer, err := startSync(ctx, cacheFlags, *baseURL, *interval, *syncLog)
+		if err != nil {
+			cli.Fatal(err)
+		}
+		defer syncer.Wait()
+	}
+
+	// The cache lock is only held while a board is built, so the sync can
+	// update the cache in between; while it does, the last board stays up.
+	var last *Board
+	build := func() (*Board, error) {
+		if err := cacheFlags.Lock(store); err != nil {
+			if last != nil {
+				return last, nil
+			}
+			return nil, err
+		}
+		defer jira.UnlockStore(store)
+		board, err := BuildBoard(store, cacheFlags.Project, *sprint, effort, *blockerWindow, time.Now())
+		if err == nil {
+			last = board
+		}
+		return board, err
+	}
+	if *httpAddr != "" {
+		err = serve(ctx, *httpAddr, *refresh, build)
+	} else {
+		err = watch(ctx, os.Stdout, *refresh, *width, build)
+	}
+	if err != nil && !errors.Is(err, context.Canceled) {
+		cli.Fatal(err)
+	}
+}
+
+// startSync runs fetch --daemon against the same cache in the background.
+// The child inherits the environment, so credentials never appear in its
+// arguments.
+func startSync(ctx context.Context, cacheFlags *cli.CacheFlags, baseURL string, interval time.Duration, logPath string) (*exec.Cmd, error) {
+	self, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("locate rhoai-jira binary: %w", err)
+	}
+	cmd := exec.CommandContext(ctx, self, "fetch",
+		"-project", cacheFlags.Project,
+		"-base-url", baseURL,
+		"-cache", cacheFlags.Spec(),
+		"-daemon",
+		"-interval", interval.String(),
+	)
+	cmd.Cancel = func() error { return cmd.Process.Signal(os.Interrupt) }
+	cmd.Stdout = io.Discard
+	cmd.Stderr = io.Discard
+	if logPath != "" {
+		f, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		cmd.Stdout, cmd.Stderr = f, f
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("start background sync: %w", err)
+	}
+	return cmd, nil
+}
+
+// watch redraws the text view every refresh until ctx is cancelled.
+func watch(ctx context.Context, w io.Writer, refresh time.Duration, width int, build func() (*Board, error)) error {
+	ticker := time.NewTicker(refresh)
+	defer ticker.Stop()
+	for {
+		board, err := build()
+		io.WriteString(w, clearScreen)
+		if err != nil {
+			fmt.Fprintf(w, "%s\n\nwaiting for the cache (refreshing every %s)\n", err, refresh)
+		} else {
+			board.WriteText(w, width)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// serve publishes the view as a page and as JSON on /board.json. Boards are
+// rebuilt at most once per refresh interval however many screens poll.
+func serve(ctx context.Context, addr string, refresh time.Duration, build func() (*Board, error)) error {
+	var (
+		mu     sync.Mutex
+		cached *Board
+	)
+	current := func() (*Board, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if cached != nil && time.Since(cached.RefreshedAt) < refresh {
+			return cached, nil
+		}
+		board, err := build()
+		if err != nil {
+			return nil, err
+		}
+		cached = board
+		return board, nil
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		board, err := current()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		board.WriteHTML(w, int(refresh.Seconds()))
+	})
+	mux.HandleFunc("/board.json", func(w http.ResponseWriter, r *http.Request) {
+		board, err := current()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(board)
+	})
+
+	server := &http.Server{Addr: addr, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+	cli.Infof("serving sprint room on %s", addr)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,116 @@
+package live
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+)
+
+// clearScreen moves the cursor home and clears the terminal.
+const clearScreen = "\033[H\033[2J"
+
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
+
+// WriteText renders the board for a terminal.
+func (b *Board) WriteText(w io.Writer, width int) {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "SPRINT ROOM  %s", b.Sprint)
+	if !b.End.IsZero() {
+		fmt.Fprintf(&sb, "  (ends %s, %d days left)", b.End.Format("Mon Jan 2"), b.DaysLeft())
+	}
+	sb.WriteString("\n")
+	sb.WriteString(strings.Repeat("=", width) + "\n\n")
+
+	done := b.Scope - b.Remaining
+	fmt.Fprintf(&sb, "  remaining %s: %.1f of %.1f    open issues: %d of %d\n", b.Effort, b.Remaining, b.Scope, b.Open, b.Issues)
+	if b.Scope > 0 {
+		bar := width - 10
+		filled := int(float64(bar) * done / b.Scope)
+		fmt.Fprintf(&sb, "  [%s%s] %3.0f%%\n", strings.Repeat("#", filled), strings.Repeat(".", bar-filled), 100*done/b.Scope)
+	}
+
+	fmt.Fprintf(&sb, "\nMOVED TODAY (%d)\n", len(b.MovedToday))
+	for _, m := range b.MovedToday {
+		line := fmt.Sprintf("  %s %-14s %s -> %s  %s", m.At.Local().Format("15:04"), m.Key, m.From, m.To, m.Summary)
+		sb.WriteString(truncate(line, width) + "\n")
+	}
+
+	fmt.Fprintf(&sb, "\nBLOCKERS (%d, %d new)\n", len(b.Blockers), b.NewBlockers())
+	for _, bl := range b.Blockers {
+		mark := "   "
+		if bl.New {
+			mark = "NEW"
+		}
+		line := fmt.Sprintf("  %s %-14s blocked by %s (%s)  %s", mark, bl.Key, bl.BlockedBy, bl.Status, bl.Summary)
+		sb.WriteString(truncate(line, width) + "\n")
+	}
+
+	sb.WriteString("\n")
+	if b.LastSync != "" {
+		fmt.Fprintf(&sb, "last sync %s  ", b.LastSync)
+	}
+	fmt.Fprintf(&sb, "refreshed %s\n", b.RefreshedAt.Format("15:04:05"))
+	if b.SyncError != "" {
+		sb.WriteString(truncate("sync failing: "+b.SyncError, width) + "\n")
+	}
+	io.WriteString(w, sb.String())
+}
+
+var pageTemplate = template.Must(template.New("live").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="{{.Refresh}}">
+<title>{{.Board.Sprint}}</title>
+<style>
+body { font-family: sans-serif; background: #111; color: #eee; margin: 2em; }
+h1 { margin-bottom: 0; }
+.big { font-size: 4em; font-weight: bold; }
+.bar { background: #333; height: 1.5em; width: 100%; }
+.bar div { background: #2ca02c; height: 100%; }
+table { border-collapse: collapse; width: 100%; }
+td { padding: 0.2em 0.6em; border-bottom: 1px solid #333; }
+.new { color: #ff7f0e; font-weight: bold; }
+.muted { color: #888; }
+</style>
+</head>
+<body>
+{{with .Board}}
+<h1>{{.Sprint}}</h1>
+<p class="muted">{{if not .End.IsZero}}ends {{.End.Format "Mon Jan 2"}}, {{.DaysLeft}} days left{{end}}</p>
+<p><span class="big">{{printf "%.1f" .Remaining}}</span> of {{printf "%.1f" .Scope}} {{.Effort}} remaining, {{.Open}} of {{.Issues}} issues open</p>
+{{if gt .Scope 0.0}}<div class="bar"><div style="width: {{$.Percent}}%"></div></div>{{end}}
+<h2>Moved today ({{len .MovedToday}})</h2>
+<table>
+{{range .MovedToday}}<tr><td>{{.At.Local.Format "15:04"}}</td><td>{{.Key}}</td><td>{{.From}} &rarr; {{.To}}</td><td>{{.Summary}}</td><td class="muted">{{.By}}</td></tr>
+{{end}}</table>
+<h2>Blockers ({{len .Blockers}}, {{.NewBlockers}} new)</h2>
+<table>
+{{range .Blockers}}<tr><td>{{if .New}}<span class="new">NEW</span>{{end}}</td><td>{{.Key}}</td><td>blocked by {{.BlockedBy}} ({{.Status}})</td><td>{{.Summary}}</td></tr>
+{{end}}</table>
+<p class="muted">{{if .LastSync}}last sync {{.LastSync}} &middot; {{end}}refreshed {{.RefreshedAt.Format "15:04:05"}}</p>
+{{if .SyncError}}<p class="new">sync failing: {{.SyncError}}</p>{{end}}
+{{end}}
+</body>
+</html>
+`))
+
+// WriteHTML renders the board as a self-refreshing page.
+func (b *Board) WriteHTML(w io.Writer, refreshSeconds int) error {
+	percent := 0.0
+	if b.Scope > 0 {
+		percent = 100 * (b.Scope - b.Remaining) / b.Scope
+	}
+	return pageTemplate.Execute(w, struct {
+		Board   *Board
+		Refresh int
+		Percent string
+	}{b, refreshSeconds, fmt.Sprintf("%.0f", percent)})
+}
@@ -0,0 +1,304 @@
+// Package plan turns the cache into a planning aid: "plan simulate" replays
+// the velocity of past sprints against a candidate scope to tell how likely
+// it is to be finished and where to cut it.
+package plan
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/commands/burndown"
+	"github.com/jctanner/rhoai-jira/internal/commands/sprintreport"
+	"github.com/jctanner/rhoai-jira/internal/jira"
+	"github.com/jctanner/rhoai-jira/internal/query"
+	"github.com/jctanner/rhoai-jira/internal/render"
+	"github.com/jctanner/rhoai-jira/internal/tools"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: %s <command> [flags]\n\n", "plan")
+	fmt.Fprintln(os.Stderr, "commands:")
+	fmt.Fprintln(os.Stderr, "  simulate   completion probability and cut lines of a candidate sprint scope")
+}
+
+func Main(args []string) {
+	if len(args) < 1 {
+		usage()
+		os.Exit(cli.ExitUsage)
+	}
+	switch args[0] {
+	case "simulate":
+		simulate(args[1:])
+	default:
+		usage()
+		os.Exit(cli.ExitUsage)
+	}
+}
+
+// Velocity is the effort completed in one past sprint.
+type Velocity struct {
+	Sprint    string
+	End       time.Time
+	Completed float64
+}
+
+// closedSprints lists the closed sprints of the cached issues, most
+// recently ended first.
+func closedSprints(issues []jira.JiraIssueWithSprints) []string {
+	ends := map[string]time.Time{}
+	for _, issue := range issues {
+		for _, s := range issue.Fields.Sprints {
+			if !strings.EqualFold(s.State, "closed") {
+				continue
+			}
+			end := s.EndDate
+			if s.CompleteDate != nil {
+				end = *s.CompleteDate
+			}
+			t, err := time.Parse(time.RFC3339, end)
+			if err != nil {
+				t, _ = jira.ParseJiraTime(end)
+			}
+			if known, ok := ends[s.Name]; !ok || t.After(known) {
+				ends[s.Name] = t
+			}
+		}
+	}
+	names := make([]string, 0, len(ends))
+	for name := range ends {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if !ends[names[i]].Equal(ends[names[j]]) {
+			return ends[names[i]].After(ends[names[j]])
+		}
+		return names[i] > names[j]
+	})
+	return names
+}
+
+// History measures the effort completed in up to n of the most recent
+// closed sprints, the way sprint-report counts it.
+func History(store jira.Store, project string, effort jira.EffortSource, n int) []Velocity {
+	var history []Velocity
+	for _, sprint := range closedSprints(jira.LoadIssues(store, project)) {
+		if len(history) == n {
+			break
+		}
+		tracked, _ := burndown.Load(store, project, sprint)
+		start, end, ok := burndown.SprintWindow(tracked, sprint)
+		if !ok {
+			cli.Warnf("skipping sprint %q: could not determine its dates", sprint)
+			continue
+		}
+		v := Velocity{Sprint: sprint, End: end}
+		for _, e := range sprintreport.Report(tracked, sprint, effort, start, end, 0) {
+			if e.Category == sprintreport.Completed {
+				v.Completed += e.Effort
+			}
+		}
+		history = append(history, v)
+	}
+	return history
+}
+
+// Item is an issue of the candidate scope in priority order.
+type Item struct {
+	Key     string
+	Effort  float64
+	Summary string
+	// Cumulative is the effort of this and every higher priority item.
+	Cumulative float64
+	// Probability is the share of trials that finished this item.
+	Probability float64
+}
+
+// Simulate runs trials sprints, each delivering the velocity of a randomly
+// drawn past sprint scaled by factor and capped at limit when positive,
+// and sets the probability of every item being finished. Items are
+// finished in order, so the probability falls along the list.
+func Simulate(items []Item, history []Velocity, factor, limit float64, trials int, r *rand.Rand) {
+	cumulative := 0.0
+	for i := range items {
+		cumulative += items[i].Effort
+		items[i].Cumulative = cumulative
+	}
+	if len(history) == 0 || trials <= 0 {
+		return
+	}
+	finished := make([]int, len(items))
+	for t := 0; t < trials; t++ {
+		delivered := history[r.Intn(len(history))].Completed * factor
+		if limit > 0 && delivered > limit {
+			delivered = limit
+		}
+		for i := range items {
+			if items[i].Cumulative > delivered {
+				break
+			}
+			finished[i]++
+		}
+	}
+	for i := range items {
+		items[i].Probability = float64(finished[i]) / float64(trials)
+	}
+}
+
+// CutLine returns the number of leading items finished with at least the
+// given probability.
+func CutLine(items []Item, confidence float64) int {
+	n := 0
+	for i, item := range items {
+		if item.Probability >= confidence {
+			n = i + 1
+		}
+	}
+	return n
+}
+
+func simulate(args []string) {
+	fs := flag.NewFlagSet("plan simulate", flag.ExitOnError)
+	cacheFlags := cli.AddCacheFlags(fs)
+	var keys tools.StringList
+	fs.Var(&keys, "keys", "Candidate scope in priority order (comma separated or repeated)")
+	queryStr := fs.String("query", "", "Candidate scope as a JQL-lite query; ORDER BY sets the priority order")
+	effortStr := fs.String("effort", "points", "Effort source (points, time, count)")
+	sprints := fs.Int("history", 6, "Number of recent closed sprints to draw velocity from")
+	factor := fs.Float64("capacity", 1, "Share of the usual capacity available, e.g. 0.8 with a fifth of the team away")
+	trials := fs.Int("trials", 10000, "Number of simulated sprints")
+	seed := fs.Int64("seed", 0, "Random seed (0 for a random one)")
+	var confidences tools.StringList
+	fs.Var(&confidences, "confidence", "Cut line confidence levels in percent (default 85,50)")
+	var renderOpts render.Options
+	render.AddFlags(fs, &renderOpts)
+	fs.Parse(args)
+
+	if (len(keys) == 0) == (*queryStr == "") {
+		cli.Fatalf(cli.ExitUsage, "Exactly one of --keys or --query must be provided.")
+	}
+	effort, err := jira.ParseEffortSource(*effortStr)
+	if err != nil {
+		cli.Fatal(cli.WithCode(cli.ExitUsage, err))
+	}
+	if *factor <= 0 {
+		cli.Fatalf(cli.ExitUsage, "--capacity must be positive")
+	}
+	if len(confidences) == 0 {
+		confidences = tools.StringList{"85", "50"}
+	}
+	var levels []float64
+	for _, c := range confidences {
+		v, err := strconv.ParseFloat(strings.TrimSuffix(c, "%"), 64)
+		if err != nil || v <= 0 || v > 100 {
+			cli.Fatalf(cli.ExitUsage, "invalid --confidence %q", c)
+		}
+		levels = append(levels, v)
+	}
+	sort.Sort(sort.Reverse(sort.Float64Slice(levels)))
+
+	store, err := cacheFlags.Open()
+	if err != nil {
+		cli.Fatal(err)
+	}
+	defer store.Close()
+	renderOpts.SetSource(store)
+
+	var scope []jira.JiraIssueWithSprints
+	if *queryStr != "" {
+		q, err := query.Parse(*queryStr)
+		if err != nil {
+			cli.Fatal(cli.WithCode(cli.ExitUsage, err))
+		}
+		scope = q.Filter(jira.LoadIssues(store, cacheFlags.Project))
+		q.Sort(scope)
+	} else {
+		for _, key := range keys {
+			issue, err := store.ReadIssue(strings.ToUpper(key))
+			if err != nil {
+				cli.Fatal(cli.WithCode(cli.ExitNoData, err))
+			}
+			scope = append(scope, issue)
+		}
+	}
+	var items []Item
+	done, unestimated := 0, 0
+	for _, issue := range scope {
+		if issue.IsDone() {
+			done++
+			continue
+		}
+		e := effort.IssueEffort(issue)
+		if e == 0 {
+			unestimated++
+		}
+		items = append(items, Item{Key: issue.Key, Effort: e, Summary: issue.Fields.Summary})
+	}
+	if done > 0 {
+		cli.Infof("left out %d issues of the scope that are already done", done)
+	}
+	if len(items) == 0 {
+		cli.Fatalf(cli.ExitNoData, "the candidate scope holds no open issues")
+	}
+	if unestimated > 0 {
+		cli.Warnf("%d issues of the scope have no %s and count as free", unestimated, effort.ColumnName())
+	}
+
+	history := History(store, cacheFlags.Project, effort, *sprints)
+	if len(history) == 0 {
+		cli.Fatalf(cli.ExitNoData, "no closed sprints in the cache to draw velocity from")
+	}
+	var parts []string
+	for _, v := range history {
+		parts = append(parts, fmt.Sprintf("%s %.1f", v.Sprint, v.Completed))
+	}
+	cli.Infof("velocity of the last %d sprints (%s): %s", len(history), effort.ColumnName(), strings.Join(parts, ", "))
+	limit := cli.Settings().Capacity.Team()
+	if limit > 0 {
+		cli.Infof("capped at the configured team capacity of %.1f", limit)
+	}
+
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+	Simulate(items, history, *factor, limit, *trials, rand.New(rand.NewSource(*seed)))
+
+	last := items[len(items)-1]
+	cli.Infof("scope of %d issues, %.1f %s: %.0f%% chance of finishing all of it", len(items), last.Cumulative, effort.ColumnName(), 100*last.Probability)
+	cuts := map[int][]string{}
+	for _, level := range levels {
+		n := CutLine(items, level/100)
+		label := fmt.Sprintf("%g%% line", level)
+		if n == 0 {
+			cli.Infof("%s: not even the first issue", label)
+			continue
+		}
+		cli.Infof("%s: the first %d issues, %.1f %s, ending at %s", label, n, items[n-1].Cumulative, effort.ColumnName(), items[n-1].Key)
+		if n < len(items) {
+			cuts[n-1] = append(cuts[n-1], label)
+		}
+	}
+
+	table := render.NewTable("rank", "key", effort.ColumnName(), "cumulative", "probability", "cut", "summary")
+	table.SetNumeric(effort.ColumnName(), "cumulative", "probability")
+	for i, item := range items {
+		table.Append(
+			strconv.Itoa(i+1),
+			item.Key,
+			fmt.Sprintf("%.1f", item.Effort),
+			fmt.Sprintf("%.1f", item.Cumulative),
+			fmt.Sprintf("%.2f", item.Probability),
+			strings.Join(cuts[i], "; "),
+			item.Summary,
+		)
+	}
+	if err := renderOpts.Write(table); err != nil {
+		cli.Fatal(err)
+	}
+}
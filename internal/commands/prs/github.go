@@ -0,0 +1,140 @@
+package prs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/jctanner/rhoai-jira/internal/jira"
+)
+
+// DefaultGitHubAPI is the REST API of github.com.
+const DefaultGitHubAPI = "https://api.github.com"
+
+// States of a pull request.
+const (
+	StateOpen     = "open"
+	StateDraft    = "draft"
+	StateMerged   = "merged"
+	StateClosed   = "closed"
+	StateNotFound = "not_found"
+)
+
+// Pull is what GitHub says about a pull request.
+type Pull struct {
+	Title    string `json:"title"`
+	State    string `json:"state"`
+	Draft    bool   `json:"draft"`
+	Merged   bool   `json:"merged"`
+	MergedAt string `json:"merged_at"`
+	User     struct {
+		Login string `json:"login"`
+	} `json:"user"`
+}
+
+// Status is StateMerged, StateClosed for pull requests closed without
+// merging, StateDraft, StateOpen or StateNotFound.
+func (p Pull) Status() string {
+	switch {
+	case p.State == StateNotFound:
+		return StateNotFound
+	case p.Merged || p.MergedAt != "":
+		return StateMerged
+	case p.State == "closed":
+		return StateClosed
+	case p.Draft:
+		return StateDraft
+	}
+	return StateOpen
+}
+
+// errRateLimited stops the lookups once GitHub refuses them; the rest of
+// the report is still written, without states.
+var errRateLimited = errors.New("GitHub refused the request (rate limited or not authorized); set github.token in the config file or GITHUB_TOKEN")
+
+// gitHub looks up the pull requests of one GitHub server, each once.
+type gitHub struct {
+	apiURL string
+	// server is the host whose pull requests the API serves.
+	server string
+	token  string
+	client *http.Client
+	pulls  map[string]*Pull
+	// refused is set once GitHub refuses a request.
+	refused error
+}
+
+func newGitHub(apiURL, token string, client *http.Client) (*gitHub, error) {
+	apiURL = strings.TrimRight(apiURL, "/")
+	u, err := url.Parse(apiURL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid GitHub API URL %q", apiURL)
+	}
+	// GitHub Enterprise serves its API under /api/v3 of the same host;
+	// github.com from a host of its own.
+	server := strings.ToLower(u.Host)
+	if apiURL == DefaultGitHubAPI {
+		server = "github.com"
+	}
+	return &gitHub{apiURL: apiURL, server: server, token: token, client: client, pulls: map[string]*Pull{}}, nil
+}
+
+// Lookup returns what GitHub says about a pull request, or nil for pull
+// requests of other servers and once GitHub refused a request. Pull
+// requests GitHub does not know have the StateNotFound state.
+func (g *gitHub) Lookup(ctx context.Context, pr jira.PullRequest) (*Pull, error) {
+	if pr.Kind != jira.PullRequestGitHub || pr.Server != g.server || g.refused != nil {
+		return nil, nil
+	}
+	if p, ok := g.pulls[pr.URL()]; ok {
+		return p, nil
+	}
+	p, err := g.fetch(ctx, pr)
+	if errors.Is(err, errRateLimited) {
+		g.refused = err
+	}
+	if err != nil {
+		return nil, err
+	}
+	g.pulls[pr.URL()] = p
+	return p, nil
+}
+
+func (g *gitHub) fetch(ctx context.Context, pr jira.PullRequest) (*Pull, error) {
+	endpoint := fmt.Sprintf("%s/repos/%s/pulls/%d", g.apiURL, pr.Repo, pr.Number)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if g.token != "" {
+		req.Header.Set("Authorization", "Bearer "+g.token)
+	}
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", pr.URL(), err)
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		// Deleted, or in a private repository the token cannot see.
+		return &Pull{State: StateNotFound}, nil
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusTooManyRequests:
+		return nil, errRateLimited
+	default:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("%s: GitHub returned %s: %s", pr.URL(), resp.Status, strings.TrimSpace(string(body)))
+	}
+	var p Pull
+	if err := json.NewDecoder(resp.Body).Decode(&p); err != nil {
+		return nil, fmt.Errorf("%s: %w", pr.URL(), err)
+	}
+	return &p, nil
+}
@@ -0,0 +1,221 @@
+// Package prs maps cached issues to the GitHub pull requests and GitLab
+// merge requests they link to, from development fields, descriptions and
+// comments, and with --github asks GitHub which of them were merged, to
+// tell which sprint items actually shipped code.
+package prs
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/jira"
+	"github.com/jctanner/rhoai-jira/internal/query"
+	"github.com/jctanner/rhoai-jira/internal/render"
+)
+
+// Link is one issue to pull request mapping; PR is nil for issues listed
+// with --unlinked that link none.
+type Link struct {
+	Issue jira.JiraIssueWithSprints
+	PR    *jira.PullRequest
+	// Pull is what GitHub says about PR, when it was looked up.
+	Pull *Pull
+}
+
+// Links maps each issue to its pull requests. Comments are read from the
+// store when cached; keepUnlinked lists issues without any as well.
+func Links(store jira.Store, issues []jira.JiraIssueWithSprints, keepUnlinked bool) (links []Link, noComments int) {
+	for _, issue := range issues {
+		comments, err := store.ReadComments(issue.Key)
+		if err != nil {
+			if !jira.IsNotCached(err) {
+				cli.Warnf("%s: %v (see cache verify)", issue.Key, err)
+			}
+			noComments++
+		}
+		prs := issue.PullRequests(comments.Comments)
+		if len(prs) == 0 && keepUnlinked {
+			links = append(links, Link{Issue: issue})
+		}
+		for i := range prs {
+			links = append(links, Link{Issue: issue, PR: &prs[i]})
+		}
+	}
+	return links, noComments
+}
+
+// githubSettings returns the GitHub API URL and token of the config file,
+// defaulting to github.com and $GITHUB_TOKEN.
+func githubSettings() (apiURL, token string, err error) {
+	settings := cli.Settings()
+	apiURL = settings.GitHub.APIURL
+	if apiURL == "" {
+		apiURL = DefaultGitHubAPI
+	}
+	ref := settings.GitHub.Token
+	if ref == "" {
+		ref = "env:GITHUB_TOKEN"
+	}
+	token, err = settings.ResolveSecret(ref)
+	return apiURL, token, err
+}
+
+func Main(args []string) {
+	fs := flag.NewFlagSet("prs", flag.ExitOnError)
+	cacheFlags := cli.AddCacheFlags(fs)
+	sprint := fs.String("sprint", "", "Only issues in this sprint")
+	queryStr := fs.String("query", "", "Only issues matching this JQL-lite query")
+	unlinked := fs.Bool("unlinked", false, "Also list issues that link no pull request (default with --sprint)")
+	github := fs.Bool("github", false, "Look up the state of GitHub pull requests (token from github.token in the config file or GITHUB_TOKEN)")
+	var renderOpts render.Options
+	render.AddFlags(fs, &renderOpts)
+	fs.Parse(args)
+
+	var q *query.Query
+	var err error
+	if *queryStr != "" {
+		if q, err = query.Parse(*queryStr); err != nil {
+			cli.Fatal(cli.WithCode(cli.ExitUsage, err))
+		}
+	}
+	keepUnlinked := *unlinked
+	if *sprint != "" {
+		keepUnlinked = true
+		fs.Visit(func(f *flag.Flag) {
+			if f.Name == "unlinked" {
+				keepUnlinked = *unlinked
+			}
+		})
+	}
+	var gh *gitHub
+	if *github {
+		apiURL, token, err := githubSettings()
+		if err != nil {
+			cli.Fatal(err)
+		}
+		if gh, err = newGitHub(apiURL, token, jira.DefaultHTTPClient); err != nil {
+			cli.Fatal(cli.WithCode(cli.ExitUsage, err))
+		}
+	}
+
+	store, err := cacheFlags.Open()
+	if err != nil {
+		cli.Fatal(err)
+	}
+	defer store.Close()
+	renderOpts.SetSource(store)
+
+	issues := jira.LoadIssues(store, cacheFlags.Project)
+	if *sprint != "" {
+		var inSprint []jira.JiraIssueWithSprints
+		for _, issue := range issues {
+			if issue.InSprint(*sprint) {
+				inSprint = append(inSprint, issue)
+			}
+		}
+		issues = inSprint
+	}
+	if q != nil {
+		issues = q.Filter(issues)
+	}
+	if len(issues) == 0 {
+		if *sprint != "" {
+			cli.Fatalf(cli.ExitNoData, "no cached issues are in sprint %q", *sprint)
+		}
+		cli.Fatalf(cli.ExitNoData, "no cached issues to search")
+	}
+
+	links, noComments := Links(store, issues, keepUnlinked)
+	if noComments > 0 {
+		cli.Infof("%d of %d issues have no cached comments; only their fields, description and embedded comments were searched", noComments, len(issues))
+	}
+
+	if gh != nil {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		elsewhere := 0
+		for i := range links {
+			if links[i].PR == nil {
+				continue
+			}
+			if pr := links[i].PR; pr.Kind != jira.PullRequestGitHub || pr.Server != gh.server {
+				elsewhere++
+				continue
+			}
+			pull, err := gh.Lookup(ctx, *links[i].PR)
+			if err != nil {
+				cli.Warnf("%s: %v", links[i].Issue.Key, err)
+				if ctx.Err() != nil {
+					break
+				}
+				continue
+			}
+			links[i].Pull = pull
+		}
+		if gh.refused != nil {
+			cli.Warnf("stopped looking up pull requests: %v", gh.refused)
+		}
+		if elsewhere > 0 {
+			cli.Infof("%d links are to pull requests not on %s and were not looked up", elsewhere, gh.server)
+		}
+	}
+
+	linked, merged := map[string]bool{}, map[string]bool{}
+	prs := map[string]bool{}
+	for _, l := range links {
+		if l.PR == nil {
+			continue
+		}
+		linked[l.Issue.Key] = true
+		prs[l.PR.URL()] = true
+		if l.Pull != nil && l.Pull.Status() == StateMerged {
+			merged[l.Issue.Key] = true
+		}
+	}
+	if len(prs) == 0 && !keepUnlinked {
+		cli.Fatalf(cli.ExitNoData, "none of the %d issues links a pull request", len(issues))
+	}
+	scope := "issues"
+	renderOpts.Title = "Pull requests"
+	if *sprint != "" {
+		scope = fmt.Sprintf("issues of sprint %q", *sprint)
+		renderOpts.Title += ": " + *sprint
+	}
+	cli.Infof("%d of %d %s link %d pull requests", len(linked), len(issues), scope, len(prs))
+	renderOpts.AddNote("%d of %d issues link %d pull requests", len(linked), len(issues), len(prs))
+	if gh != nil {
+		cli.Infof("%d of %d %s have a merged pull request", len(merged), len(issues), scope)
+		renderOpts.AddNote("%d of %d issues have a merged pull request", len(merged), len(issues))
+	}
+
+	headers := []string{"key", "summary", "status", "assignee", "pr", "kind", "repo", "number", "source"}
+	if gh != nil {
+		headers = append(headers, "pr_state", "pr_title", "pr_author", "merged_at")
+	}
+	table := render.NewTable(headers...)
+	for _, l := range links {
+		row := []string{l.Issue.Key, l.Issue.Fields.Summary, l.Issue.Fields.Status.Name, l.Issue.AssigneeID()}
+		if l.PR != nil {
+			row = append(row, l.PR.URL(), l.PR.Kind, l.PR.Repo, strconv.Itoa(l.PR.Number), l.PR.Source)
+		} else {
+			row = append(row, "", "", "", "", "")
+		}
+		if gh != nil {
+			if l.Pull != nil {
+				row = append(row, l.Pull.Status(), l.Pull.Title, l.Pull.User.Login, l.Pull.MergedAt)
+			} else {
+				row = append(row, "", "", "", "")
+			}
+		}
+		table.Append(row...)
+	}
+	if err := renderOpts.Write(table); err != nil {
+		cli.Fatal(err)
+	}
+}
@@ -0,0 +1,261 @@
+// Package quality computes resolution quality metrics from the changelogs
+// of cached issues, per component and quarter: how many resolved issues
+// were reopened, how long resolution took, and how often fix versions
+// slipped.
+package quality
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/events"
+	"github.com/jctanner/rhoai-jira/internal/jira"
+	"github.com/jctanner/rhoai-jira/internal/query"
+	"github.com/jctanner/rhoai-jira/internal/render"
+)
+
+// Quarter labels a time as 2025-Q1, in the local time zone.
+func Quarter(t time.Time) string {
+	t = t.Local()
+	return fmt.Sprintf("%d-Q%d", t.Year(), (int(t.Month())-1)/3+1)
+}
+
+// Resolution is what the changelog of one issue says about its quality.
+type Resolution struct {
+	// FirstResolved is when the issue first reached a done status; zero
+	// when it never did.
+	FirstResolved time.Time
+	// Resolved is when the issue last reached a done status; zero unless
+	// it is done now.
+	Resolved time.Time
+	// Reopens are the times the issue left a done status for another.
+	Reopens []time.Time
+	// Slips are the times a fix version was taken off the issue.
+	Slips []time.Time
+}
+
+// Analyze replays the status and fix version changes of an issue. isDone
+// tells whether a status name is in the done category.
+func Analyze(issue jira.JiraIssueWithSprints, changelog jira.Changelog, isDone func(string) bool, now time.Time) Resolution {
+	var r Resolution
+	periods := jira.StatusPeriods(issue, changelog, now)
+	for i, p := range periods {
+		if !isDone(p.Status) {
+			if i > 0 && isDone(periods[i-1].Status) {
+				r.Reopens = append(r.Reopens, p.From)
+			}
+			continue
+		}
+		if r.FirstResolved.IsZero() {
+			r.FirstResolved = p.From
+		}
+		if i == len(periods)-1 {
+			r.Resolved = p.From
+		}
+	}
+	if len(periods) == 1 && isDone(periods[0].Status) {
+		// Created resolved, or moved without a recorded transition.
+		if resolved, err := issue.ResolvedTime(); err == nil {
+			r.FirstResolved, r.Resolved = resolved, resolved
+		}
+	}
+	for _, e := range events.Filter(events.Normalize(issue.Key, changelog), events.FixVersionRemoved, events.FixVersionAdded) {
+		if e.From != "" {
+			r.Slips = append(r.Slips, e.At)
+		}
+	}
+	return r
+}
+
+// Row holds the metrics of one component in one quarter. Resolved and
+// Reopened follow the issues first resolved in the quarter; durations
+// those last resolved in it; slips the fix version changes made in it.
+type Row struct {
+	Component string
+	Quarter   string
+	Resolved  int
+	Reopened  int
+	Reopens   int
+	Durations []time.Duration
+	Slips     int
+	Slipped   map[string]bool
+}
+
+func (r *Row) ReopenRate() float64 {
+	if r.Resolved == 0 {
+		return 0
+	}
+	return float64(r.Reopened) / float64(r.Resolved)
+}
+
+func days(d time.Duration) string {
+	return fmt.Sprintf("%.1f", d.Hours()/24)
+}
+
+func componentsOf(issue jira.JiraIssueWithSprints) []string {
+	if names := issue.ComponentNames(); len(names) > 0 {
+		return names
+	}
+	return []string{"(no component)"}
+}
+
+// doneStatuses maps the status names of the cached issues to whether they
+// are in the done category; names only seen in changelogs fall back to
+// the usual terminal names.
+func doneStatuses(issues []jira.JiraIssueWithSprints) func(string) bool {
+	category := map[string]string{}
+	for _, v := range jira.CachedStatuses(issues) {
+		category[v.Name] = v.Detail
+	}
+	return func(name string) bool {
+		s := jira.Status{Name: name}
+		s.StatusCategory.Key = category[name]
+		return s.IsDone()
+	}
+}
+
+func Main(args []string) {
+	fs := flag.NewFlagSet("quality", flag.ExitOnError)
+	cacheFlags := cli.AddCacheFlags(fs)
+	queryStr := fs.String("query", "", "Only issues matching this JQL-lite query")
+	since := fs.String("since", "", "Only quarters from this date on (2025-01-01 or -365d)")
+	var renderOpts render.Options
+	render.AddFlags(fs, &renderOpts)
+	fs.Parse(args)
+
+	now := time.Now()
+	var from time.Time
+	var err error
+	if *since != "" {
+		if from, err = query.ParseDate(*since, now); err != nil {
+			cli.Fatal(cli.WithCode(cli.ExitUsage, err))
+		}
+	}
+	var q *query.Query
+	if *queryStr != "" {
+		if q, err = query.Parse(*queryStr); err != nil {
+			cli.Fatal(cli.WithCode(cli.ExitUsage, err))
+		}
+	}
+
+	store, err := cacheFlags.Open()
+	if err != nil {
+		cli.Fatal(err)
+	}
+	defer store.Close()
+	renderOpts.SetSource(store)
+
+	issues := jira.LoadIssues(store, cacheFlags.Project)
+	isDone := doneStatuses(issues)
+	if q != nil {
+		issues = q.Filter(issues)
+	}
+
+	rows := map[[2]string]*Row{}
+	rowFor := func(component string, at time.Time) *Row {
+		if at.Before(from) {
+			return nil
+		}
+		k := [2]string{component, Quarter(at)}
+		if rows[k] == nil {
+			rows[k] = &Row{Component: component, Quarter: k[1], Slipped: map[string]bool{}}
+		}
+		return rows[k]
+	}
+	var coverage jira.ChangelogCoverage
+	noFixVersions := 0
+	for _, issue := range issues {
+		changelog, err := store.ReadChangelog(issue.Key)
+		coverage.Add(err)
+		if err != nil {
+			continue
+		}
+		if len(changelog.PersistedFields) > 0 && !contains(changelog.PersistedFields, "Fix Version") {
+			noFixVersions++
+		}
+		r := Analyze(issue, changelog, isDone, now)
+		created, createdErr := issue.CreatedTime()
+		for _, component := range componentsOf(issue) {
+			if !r.FirstResolved.IsZero() {
+				if row := rowFor(component, r.FirstResolved); row != nil {
+					row.Resolved++
+					if len(r.Reopens) > 0 {
+						row.Reopened++
+						row.Reopens += len(r.Reopens)
+					}
+				}
+			}
+			if !r.Resolved.IsZero() && createdErr == nil {
+				if row := rowFor(component, r.Resolved); row != nil {
+					row.Durations = append(row.Durations, r.Resolved.Sub(created))
+				}
+			}
+			for _, at := range r.Slips {
+				if row := rowFor(component, at); row != nil {
+					row.Slips++
+					row.Slipped[issue.Key] = true
+				}
+			}
+		}
+	}
+	coverage.Log()
+	if noFixVersions > 0 {
+		cli.Infof("%d changelogs were saved without Fix Version changes; their slips are not counted", noFixVersions)
+	}
+	if len(rows) == 0 {
+		cli.Fatalf(cli.ExitNoData, "no resolutions or fix version changes in the cached changelogs")
+	}
+
+	sorted := make([]*Row, 0, len(rows))
+	for _, r := range rows {
+		sorted = append(sorted, r)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Component != sorted[j].Component {
+			return sorted[i].Component < sorted[j].Component
+		}
+		return sorted[i].Quarter < sorted[j].Quarter
+	})
+	table := render.NewTable("component", "quarter", "resolved", "reopened", "reopens", "reopen_rate", "mean_days_to_resolution", "median_days_to_resolution", "fix_version_slips", "slipped_issues")
+	table.SetNumeric("reopen_rate", "mean_days_to_resolution", "median_days_to_resolution")
+	for _, r := range sorted {
+		mean, median := "", ""
+		if n := len(r.Durations); n > 0 {
+			sort.Slice(r.Durations, func(i, j int) bool { return r.Durations[i] < r.Durations[j] })
+			var total time.Duration
+			for _, d := range r.Durations {
+				total += d
+			}
+			mean = days(total / time.Duration(n))
+			median = days(r.Durations[(n-1)/2])
+		}
+		table.Append(
+			r.Component,
+			r.Quarter,
+			fmt.Sprintf("%d", r.Resolved),
+			fmt.Sprintf("%d", r.Reopened),
+			fmt.Sprintf("%d", r.Reopens),
+			fmt.Sprintf("%.2f", r.ReopenRate()),
+			mean,
+			median,
+			fmt.Sprintf("%d", r.Slips),
+			fmt.Sprintf("%d", len(r.Slipped)),
+		)
+	}
+	if err := renderOpts.Write(table); err != nil {
+		cli.Fatal(err)
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
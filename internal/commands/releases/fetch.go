@@ -0,0 +1,81 @@
+package releases
+
+import (
+	"context"
+	"flag"
+	"sort"
+	"strings"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/jira"
+)
+
+// fetch reads the versions of projects from Jira and saves them in the
+// cache, where the report picks up their start and release dates. Without
+// --project the versions of every project with cached issues are fetched.
+func fetch(args []string) {
+	fs := flag.NewFlagSet("releases fetch", flag.ExitOnError)
+	cacheFlags := cli.AddCacheFlags(fs)
+	baseURL := fs.String("base-url", cli.BaseURL(), "Jira base URL")
+	auth := cli.AddAuthFlags(fs)
+	fs.Parse(args)
+
+	authenticator, err := auth.Authenticator()
+	if err != nil {
+		cli.Fatal(err)
+	}
+	store, err := cacheFlags.OpenForWriting()
+	if err != nil {
+		cli.Fatal(err)
+	}
+	defer store.Close()
+
+	var projects []string
+	if cacheFlags.Project != "" {
+		projects = []string{strings.ToUpper(cacheFlags.Project)}
+	} else {
+		seen := map[string]bool{}
+		for _, issue := range jira.LoadIssues(store, "") {
+			if key := issue.Fields.Project.Key; key != "" && !seen[key] {
+				seen[key] = true
+				projects = append(projects, key)
+			}
+		}
+		sort.Strings(projects)
+	}
+	if len(projects) == 0 {
+		cli.Fatalf(cli.ExitNoData, "no cached issues to take projects from; pass --project")
+	}
+
+	client := jira.NewClient(*baseURL, "")
+	client.Auth = authenticator
+	ctx := context.Background()
+	m, err := store.ReadVersions()
+	if err != nil {
+		cli.Fatal(err)
+	}
+	fetched := 0
+	for _, project := range projects {
+		versions, err := client.FetchVersions(ctx, project)
+		if err != nil {
+			cli.Warnf("skipping project %s: %v", project, err)
+			continue
+		}
+		m.Put(versions)
+		fetched++
+		released := 0
+		for _, v := range versions.Versions {
+			if v.Released {
+				released++
+			}
+		}
+		cli.Infof("project %s: %d versions, %d released", project, len(versions.Versions), released)
+	}
+	if fetched == 0 {
+		cli.Fatalf(cli.ExitFailure, "no project versions could be fetched")
+	}
+	if err := store.SaveVersions(m); err != nil {
+		cli.Fatal(err)
+	}
+	cli.Infof("saved the versions of %d of %d projects to the cache", fetched, len(projects))
+}
@@ -0,0 +1,464 @@
+// Package releases reports on the fix versions of a project: how many
+// issues each targets, how many of them are done, and how its scope moved
+// as issues were given the version or taken out of it, from the Fix
+// Version changes in their changelogs. The versions themselves, with their
+// start and release dates, come from "releases fetch".
+package releases
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/commands/burndown"
+	"github.com/jctanner/rhoai-jira/internal/events"
+	"github.com/jctanner/rhoai-jira/internal/jira"
+	"github.com/jctanner/rhoai-jira/internal/query"
+	"github.com/jctanner/rhoai-jira/internal/render"
+	"github.com/jctanner/rhoai-jira/internal/tools"
+)
+
+// Release is a fix version of a project and the issues that target it now
+// or did at some point.
+type Release struct {
+	Project string
+	Version jira.Version
+	// Fetched is whether the version came from "releases fetch", as
+	// opposed to being seen on the fix versions of cached issues.
+	Fetched bool
+	Members []*Member
+}
+
+// Member is an issue that targets a release now or did at some point.
+type Member struct {
+	burndown.Tracked
+	// Changes are the Fix Version changes adding the issue to the release
+	// or removing it, in the order they happened.
+	Changes []events.Event
+	// Current is whether the issue targets the release now.
+	Current bool
+}
+
+// InAt reports whether the issue targeted the release at a time: the
+// first change after it tells, and with none the issue is as it is now.
+func (m *Member) InAt(at time.Time) bool {
+	for _, e := range m.Changes {
+		if e.At.After(at) {
+			return e.Kind == events.FixVersionRemoved
+		}
+	}
+	return m.Current
+}
+
+// Start is when the release started, from its start date; false when it
+// has none.
+func (r *Release) Start() (time.Time, bool) {
+	return r.Version.StartTime()
+}
+
+// Change is an issue added to a release or removed from it.
+type Change struct {
+	Key    string
+	At     time.Time
+	Added  bool
+	Effort float64
+}
+
+// ChangesBetween lists the issues added to the release or removed from it
+// after from and until to, in the order they happened.
+func (r *Release) ChangesBetween(effort jira.EffortSource, from, to time.Time) []Change {
+	var out []Change
+	for _, m := range r.Members {
+		for _, e := range m.Changes {
+			if !e.At.After(from) || e.At.After(to) {
+				continue
+			}
+			out = append(out, Change{
+				Key:    m.Issue.Key,
+				At:     e.At,
+				Added:  e.Kind == events.FixVersionAdded,
+				Effort: effort.EffortAt(m.Issue, m.Changelog, e.At),
+			})
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].At.Before(out[j].At) })
+	return out
+}
+
+// Scope is the issues of a release at a time and how many of them were
+// done.
+type Scope struct {
+	Issues     int
+	Effort     float64
+	Done       int
+	DoneEffort float64
+}
+
+// DonePct is the done issues as a percentage of the issues.
+func (s Scope) DonePct() float64 {
+	if s.Issues == 0 {
+		return 0
+	}
+	return 100 * float64(s.Done) / float64(s.Issues)
+}
+
+// ScopeAt replays the changelogs of the members to find the scope of the
+// release at a time. Issues not created yet are left out.
+func (r *Release) ScopeAt(effort jira.EffortSource, at time.Time) Scope {
+	var s Scope
+	for _, m := range r.Members {
+		if !m.InAt(at) {
+			continue
+		}
+		load, ok := jira.LoadAt(m.Issue, m.Changelog, effort, at)
+		if !ok {
+			continue
+		}
+		s.Issues++
+		s.Effort += load.Effort
+		if !load.Open {
+			s.Done++
+			s.DoneEffort += load.Effort
+		}
+	}
+	return s
+}
+
+// CurrentScope is the scope of the release as the cached issues are now.
+func (r *Release) CurrentScope(effort jira.EffortSource) Scope {
+	var s Scope
+	for _, m := range r.Members {
+		if !m.Current {
+			continue
+		}
+		e := effort.IssueEffort(m.Issue)
+		s.Issues++
+		s.Effort += e
+		if m.Issue.IsDone() {
+			s.Done++
+			s.DoneEffort += e
+		}
+	}
+	return s
+}
+
+func releaseKey(project, name string) string {
+	return strings.ToUpper(project) + "\x00" + strings.ToLower(name)
+}
+
+// Collect groups the tracked issues into the releases they target now or
+// did at some point. Fetched versions are listed even without issues;
+// versions only seen on issues take what the issues carry of them.
+func Collect(versions jira.VersionMap, project string, tracked []burndown.Tracked) []*Release {
+	releases := map[string]*Release{}
+	var order []*Release
+	add := func(project string, v jira.Version, fetched bool) *Release {
+		key := releaseKey(project, v.Name)
+		if r, ok := releases[key]; ok {
+			return r
+		}
+		r := &Release{Project: project, Version: v, Fetched: fetched}
+		releases[key] = r
+		order = append(order, r)
+		return r
+	}
+	for _, p := range versions.Projects {
+		if project != "" && !strings.EqualFold(p.Project, project) {
+			continue
+		}
+		for _, v := range p.Versions {
+			add(p.Project, v, true)
+		}
+	}
+
+	for _, t := range tracked {
+		issueProject := t.Issue.Fields.Project.Key
+		members := map[*Release]*Member{}
+		member := func(r *Release) *Member {
+			m, ok := members[r]
+			if !ok {
+				m = &Member{Tracked: t}
+				members[r] = m
+				r.Members = append(r.Members, m)
+			}
+			return m
+		}
+		for _, v := range t.Issue.Fields.FixVersions {
+			member(add(issueProject, v, false)).Current = true
+		}
+		for _, e := range events.Filter(events.Normalize(t.Issue.Key, t.Changelog), events.FixVersionAdded, events.FixVersionRemoved) {
+			name := e.To
+			if e.Kind == events.FixVersionRemoved {
+				name = e.From
+			}
+			if name == "" {
+				continue
+			}
+			r := add(issueProject, jira.Version{Name: name}, false)
+			m := member(r)
+			m.Changes = append(m.Changes, e)
+		}
+	}
+	return order
+}
+
+func formatEffort(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func formatDate(t time.Time, ok bool) string {
+	if !ok {
+		return ""
+	}
+	return t.Format("2006-01-02")
+}
+
+func Main(args []string) {
+	if len(args) > 0 && args[0] == "fetch" {
+		fetch(args[1:])
+		return
+	}
+	fs := flag.NewFlagSet("releases", flag.ExitOnError)
+	cacheFlags := cli.AddCacheFlags(fs)
+	version := fs.String("version", "", "Show how the scope of this fix version moved over time instead of one row per version")
+	intervalStr := fs.String("interval", "weekly", "Time between rows of the --version timeline (daily, weekly)")
+	sinceStr := fs.String("since", "", "Start the --version timeline on this date YYYY-MM-DD (default: the version's start date)")
+	effortStr := fs.String("effort", "points", "Effort source (points, time, count)")
+	queryStr := fs.String("query", "", "Only issues matching this JQL-lite query")
+	unreleased := fs.Bool("unreleased", false, "Only versions not released yet")
+	archivedVersions := fs.Bool("archived-versions", false, "Include archived versions")
+	var renderOpts render.Options
+	render.AddFlags(fs, &renderOpts)
+	fs.Parse(args)
+
+	effort, err := jira.ParseEffortSource(*effortStr)
+	if err != nil {
+		cli.Fatal(cli.WithCode(cli.ExitUsage, err))
+	}
+	var step time.Duration
+	switch *intervalStr {
+	case "daily":
+		step = 24 * time.Hour
+	case "weekly":
+		step = 7 * 24 * time.Hour
+	default:
+		cli.Fatalf(cli.ExitUsage, "--interval must be daily or weekly")
+	}
+	var since time.Time
+	if *sinceStr != "" {
+		if since, err = time.ParseInLocation("2006-01-02", *sinceStr, time.Local); err != nil {
+			cli.Fatalf(cli.ExitUsage, "invalid --since %q", *sinceStr)
+		}
+	}
+	var q *query.Query
+	if *queryStr != "" {
+		if q, err = query.Parse(*queryStr); err != nil {
+			cli.Fatal(cli.WithCode(cli.ExitUsage, err))
+		}
+	}
+
+	store, err := cacheFlags.Open()
+	if err != nil {
+		cli.Fatal(err)
+	}
+	defer store.Close()
+	renderOpts.SetSource(store)
+
+	versions, err := store.ReadVersions()
+	if err != nil {
+		cli.Fatal(err)
+	}
+	if len(versions.Projects) == 0 {
+		cli.Infof("no project versions in the cache; run releases fetch for their start and release dates")
+	}
+	issues := jira.LoadIssues(store, cacheFlags.Project)
+	if q != nil {
+		issues = q.Filter(issues)
+	}
+	var tracked []burndown.Tracked
+	var coverage jira.ChangelogCoverage
+	for _, issue := range issues {
+		created, err := issue.CreatedTime()
+		if err != nil {
+			continue
+		}
+		changelog, err := store.ReadChangelog(issue.Key)
+		tracked = append(tracked, burndown.Tracked{Issue: issue, Changelog: changelog, Created: created})
+		coverage.Add(err)
+	}
+	coverage.Log()
+
+	var releases []*Release
+	for _, r := range Collect(versions, cacheFlags.Project, tracked) {
+		if *version != "" && !strings.EqualFold(r.Version.Name, *version) {
+			continue
+		}
+		if *unreleased && r.Version.Released {
+			continue
+		}
+		if r.Version.Archived && !*archivedVersions && *version == "" {
+			continue
+		}
+		if !r.Fetched && len(r.Members) == 0 {
+			continue
+		}
+		for _, m := range r.Members {
+			sort.SliceStable(m.Changes, func(i, j int) bool { return m.Changes[i].At.Before(m.Changes[j].At) })
+		}
+		releases = append(releases, r)
+	}
+
+	if *version != "" {
+		switch len(releases) {
+		case 0:
+			cli.Fatalf(cli.ExitNoData, "no cached issue ever targeted fix version %q", *version)
+		case 1:
+			writeTimeline(releases[0], effort, since, step, renderOpts)
+		default:
+			var projects []string
+			for _, r := range releases {
+				projects = append(projects, r.Project)
+			}
+			cli.Fatalf(cli.ExitUsage, "fix version %q is in projects %s; pass --project", *version, strings.Join(projects, ", "))
+		}
+		return
+	}
+	if len(releases) == 0 {
+		cli.Fatalf(cli.ExitNoData, "no fix versions in the cache")
+	}
+	writeSummary(releases, effort, renderOpts)
+}
+
+// writeSummary writes one row per release in the order the project sorts
+// its versions, with the changes to its scope since it started.
+func writeSummary(releases []*Release, effort jira.EffortSource, renderOpts render.Options) {
+	column := effort.ColumnName()
+	table := render.NewTable("project", "version", "released", "start_date", "release_date", "issues", "done", "done_pct", column, "done_"+column, "added", "removed", "added_"+column, "removed_"+column)
+	table.SetNumeric("done_pct", column, "done_"+column, "added_"+column, "removed_"+column)
+	undated := 0
+	var total Scope
+	for _, r := range releases {
+		s := r.CurrentScope(effort)
+		total.Issues += s.Issues
+		total.Done += s.Done
+		start, started := r.Start()
+		release, ok := r.Version.ReleaseTime()
+		row := []string{
+			r.Project, r.Version.Name, strconv.FormatBool(r.Version.Released),
+			formatDate(start, started), formatDate(release, ok),
+			strconv.Itoa(s.Issues), strconv.Itoa(s.Done), fmt.Sprintf("%.1f", s.DonePct()),
+			formatEffort(s.Effort), formatEffort(s.DoneEffort),
+		}
+		if !started {
+			// Without a start date every issue was added at some point;
+			// there is no scope to tell changes from.
+			undated++
+			table.Append(append(row, "", "", "", "")...)
+			continue
+		}
+		var added, removed int
+		var addedEffort, removedEffort float64
+		for _, c := range r.ChangesBetween(effort, start, time.Now()) {
+			if c.Added {
+				added++
+				addedEffort += c.Effort
+			} else {
+				removed++
+				removedEffort += c.Effort
+			}
+		}
+		table.Append(append(row, strconv.Itoa(added), strconv.Itoa(removed), formatEffort(addedEffort), formatEffort(removedEffort))...)
+	}
+	cli.Infof("%d fix versions with %d issues, %d done", len(releases), total.Issues, total.Done)
+	renderOpts.Title = "Releases"
+	renderOpts.AddNote("%d fix versions, %d issues, %.1f%% done", len(releases), total.Issues, total.DonePct())
+	renderOpts.AddNote("added and removed count the Fix Version changes made after the version's start date")
+	if undated > 0 {
+		renderOpts.AddNote("%d versions have no start date, so no scope changes", undated)
+	}
+	if err := renderOpts.Write(table); err != nil {
+		cli.Fatal(err)
+	}
+}
+
+// writeTimeline writes the scope of one release at the end of each
+// interval from its start until it was released, or until now, with the
+// issues added and removed during the interval.
+func writeTimeline(r *Release, effort jira.EffortSource, since time.Time, step time.Duration, renderOpts render.Options) {
+	start := since
+	if start.IsZero() {
+		var ok bool
+		if start, ok = r.Start(); !ok {
+			// Without a start date, begin when the first issue was given
+			// the version.
+			for _, c := range r.ChangesBetween(effort, time.Time{}, time.Now()) {
+				start = c.At
+				break
+			}
+			if start.IsZero() {
+				cli.Fatalf(cli.ExitNoData, "fix version %q has no start date and no issue changelog adds it; pass --since", r.Version.Name)
+			}
+			cli.Infof("fix version %q has no start date; starting when the first issue was given it", r.Version.Name)
+		}
+	}
+	start = time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.Local)
+	end := time.Now()
+	if release, ok := r.Version.ReleaseTime(); ok && r.Version.Released && release.Before(end) {
+		end = release
+	}
+	if end.Before(start) {
+		cli.Fatalf(cli.ExitNoData, "fix version %q starts after it ends", r.Version.Name)
+	}
+
+	column := effort.ColumnName()
+	table := render.NewTable("date", "issues", column, "done", "done_"+column, "done_pct", "added", "removed", "added_keys", "removed_keys")
+	table.SetNumeric(column, "done_"+column, "done_pct")
+	var totalAdded, totalRemoved int
+	prev := start.Add(-time.Nanosecond)
+	for day := start; !day.After(end); day = day.Add(step) {
+		at := day.Add(step - time.Nanosecond)
+		if at.After(end) {
+			at = end
+		}
+		s := r.ScopeAt(effort, at)
+		var added, removed []string
+		for _, c := range r.ChangesBetween(effort, prev, at) {
+			if c.Added {
+				added = append(added, c.Key)
+			} else {
+				removed = append(removed, c.Key)
+			}
+		}
+		totalAdded += len(added)
+		totalRemoved += len(removed)
+		table.Append(day.Format("2006-01-02"), strconv.Itoa(s.Issues), formatEffort(s.Effort),
+			strconv.Itoa(s.Done), formatEffort(s.DoneEffort), fmt.Sprintf("%.1f", s.DonePct()),
+			strconv.Itoa(len(added)), strconv.Itoa(len(removed)),
+			strings.Join(tools.SortNumerically(dedupe(added)), " "), strings.Join(tools.SortNumerically(dedupe(removed)), " "))
+		prev = at
+	}
+	renderOpts.Title = fmt.Sprintf("Release %s %s", r.Project, r.Version.Name)
+	renderOpts.AddNote("from %s to %s: %d issues added, %d removed", start.Format("2006-01-02"), end.Format("2006-01-02"), totalAdded, totalRemoved)
+	if r.Version.Released {
+		renderOpts.AddNote("released %s", r.Version.ReleaseDate)
+	}
+	if err := renderOpts.Write(table); err != nil {
+		cli.Fatal(err)
+	}
+}
+
+func dedupe(keys []string) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, k := range keys {
+		if !seen[k] {
+			seen[k] = true
+			out = append(out, k)
+		}
+	}
+	return out
+}
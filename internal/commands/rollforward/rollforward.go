@@ -0,0 +1,89 @@
+package rollforward
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/jira"
+)
+
+func parseAt(value string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid --at %q (expected YYYY-MM-DD, \"YYYY-MM-DD HH:MM\" or RFC3339)", value)
+}
+
+func Main(args []string) {
+	fs := flag.NewFlagSet("rollforward", flag.ExitOnError)
+	dir := fs.String("dir", "issues", "Directory containing cached changelogs")
+	project := fs.String("project", "", "Filter on a specific project")
+	atStr := fs.String("at", "", "Point in time to reconstruct (e.g. 2025-01-01)")
+	out := fs.String("out", "", "Snapshot directory to write (required)")
+	withChangelogs := fs.Bool("with-changelogs", true, "Also write changelogs truncated at --at so trackers can run on the snapshot")
+	fs.Parse(args)
+
+	if *atStr == "" || *out == "" {
+		cli.Fatalf(cli.ExitUsage, "Both --at and --out must be provided.")
+	}
+	at, err := parseAt(*atStr)
+	if err != nil {
+		cli.Fatal(err)
+	}
+	if abs, _ := filepath.Abs(*out); abs != "" {
+		if src, _ := filepath.Abs(*dir); src == abs {
+			cli.Fatalf(cli.ExitUsage, "--out must differ from --dir")
+		}
+	}
+	if err := os.MkdirAll(*out, 0755); err != nil {
+		cli.Fatal(fmt.Errorf("failed to create snapshot directory: %w", err))
+	}
+
+	written, skipped := 0, 0
+	for _, key := range jira.GetAllChangelogKeys(*dir, *project) {
+		changelog, err := jira.GetIssueChangelogFromCache(*dir, key)
+		if err != nil {
+			cli.Warnf("skipping %s: %v", key, err)
+			continue
+		}
+
+		var current *jira.JiraIssueWithSprints
+		if issue, err := jira.GetIssueFromCache(*dir, key); err == nil {
+			current = &issue
+		}
+
+		snap, ok := jira.SnapshotIssue(key, current, changelog, at)
+		if !ok {
+			skipped++
+			continue
+		}
+
+		data, err := json.MarshalIndent(snap.IssueJSON(), "", "  ")
+		if err != nil {
+			cli.Fatal(fmt.Errorf("marshal %s: %w", key, err))
+		}
+		if err := os.WriteFile(filepath.Join(*out, key+".json"), data, 0644); err != nil {
+			cli.Fatal(fmt.Errorf("write %s: %w", key, err))
+		}
+
+		if *withChangelogs {
+			data, err := json.MarshalIndent(jira.ChangelogUntil(changelog, at), "", "  ")
+			if err != nil {
+				cli.Fatal(fmt.Errorf("marshal changelog %s: %w", key, err))
+			}
+			if err := os.WriteFile(filepath.Join(*out, key+".changelog.json"), data, 0644); err != nil {
+				cli.Fatal(fmt.Errorf("write changelog %s: %w", key, err))
+			}
+		}
+		written++
+	}
+
+	cli.Infof("wrote %d issues as of %s to %s (%d not yet created)", written, at.Format(time.RFC3339), *out, skipped)
+}
@@ -0,0 +1,442 @@
+// Package rpc serves the cache and the reports as JSON-RPC 2.0 over stdin
+// and stdout, one message per line, so notebooks and other non-Go tooling
+// can drive rhoai-jira without parsing the cache themselves.
+//
+// Methods:
+//
+//	query     {"query", "cache", "project", "limit", "fields"} -> {"total", "issues"}
+//	issue     {"key", "cache"} -> flattened issue
+//	changelog {"key", "cache"} -> changelog events
+//	sync      {"project", "jql", "cache", "args"} -> {"exit_code"}
+//	report    {"command", "args"} -> {"columns", "rows"}
+//	commands  {} -> names of the methods
+//
+// sync and report run the binary itself, as pipeline steps do, so a
+// failing report cannot take the server down with it.
+package rpc
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/jira"
+	"github.com/jctanner/rhoai-jira/internal/query"
+)
+
+// Error codes defined by JSON-RPC 2.0, and ErrCommand for a sync or report
+// that exited non-zero; its data holds the exit code and the last lines the
+// command logged.
+const (
+	ErrParse          = -32700
+	ErrInvalidRequest = -32600
+	ErrMethodNotFound = -32601
+	ErrInvalidParams  = -32602
+	ErrInternal       = -32603
+	ErrCommand        = -32000
+)
+
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// Error is a JSON-RPC error object.
+type Error struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+func (e *Error) Error() string { return e.Message }
+
+func invalidParams(format string, args ...interface{}) *Error {
+	return &Error{Code: ErrInvalidParams, Message: fmt.Sprintf(format, args...)}
+}
+
+// commands run by report that never exit or would read stdin.
+var refused = map[string]bool{"rpc": true, "server": true, "live": true}
+
+type server struct {
+	ctx   context.Context
+	self  string
+	cache string
+	// wait is how long a method waits for a fetch writing the cache.
+	wait    time.Duration
+	methods map[string]func(json.RawMessage) (interface{}, error)
+}
+
+func Main(args []string) {
+	fs := flag.NewFlagSet("rpc", flag.ExitOnError)
+	cache := fs.String("cache", cli.CacheSpec("issues"), "Default cache backend for methods that do not name one")
+	wait := fs.Duration("wait", 10*time.Second, "How long methods wait for a fetch writing the cache to finish before failing")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: %s [flags]\n\nReads JSON-RPC 2.0 requests from stdin, one per line, and writes the\nresponses to stdout. Methods: query, issue, changelog, sync, report, commands.\n\n", fs.Name())
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	self, err := os.Executable()
+	if err != nil {
+		cli.Fatal(fmt.Errorf("locate rhoai-jira binary: %w", err))
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	s := &server{ctx: ctx, self: self, cache: *cache, wait: *wait}
+	s.methods = map[string]func(json.RawMessage) (interface{}, error){
+		"query":     s.query,
+		"issue":     s.issue,
+		"changelog": s.changelog,
+		"sync":      s.sync,
+		"report":    s.report,
+		"commands":  s.commands,
+	}
+	cli.Infof("serving JSON-RPC on stdin/stdout (cache %s)", s.cache)
+	if err := s.serve(os.Stdin, os.Stdout); err != nil {
+		cli.Fatal(err)
+	}
+}
+
+// serve answers requests until in is exhausted. Batches are answered with
+// an array; notifications, requests without an id, are not answered.
+func (s *server) serve(in io.Reader, out io.Writer) error {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	enc := json.NewEncoder(out)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var reply interface{}
+		if line[0] == '[' {
+			var batch []json.RawMessage
+			if err := json.Unmarshal(line, &batch); err != nil {
+				reply = response{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &Error{Code: ErrParse, Message: err.Error()}}
+			} else if len(batch) == 0 {
+				reply = response{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &Error{Code: ErrInvalidRequest, Message: "empty batch"}}
+			} else {
+				var replies []response
+				for _, raw := range batch {
+					if r, ok := s.handle(raw); ok {
+						replies = append(replies, r)
+					}
+				}
+				if len(replies) > 0 {
+					reply = replies
+				}
+			}
+		} else if r, ok := s.handle(line); ok {
+			reply = r
+		}
+		if reply == nil {
+			continue
+		}
+		if err := enc.Encode(reply); err != nil {
+			return err
+		}
+		if s.ctx.Err() != nil {
+			return s.ctx.Err()
+		}
+	}
+	return scanner.Err()
+}
+
+func (s *server) handle(raw json.RawMessage) (response, bool) {
+	resp := response{JSONRPC: "2.0", ID: json.RawMessage("null")}
+	var req request
+	if err := json.Unmarshal(raw, &req); err != nil {
+		var syntax *json.SyntaxError
+		code := ErrInvalidRequest
+		if errors.As(err, &syntax) {
+			code = ErrParse
+		}
+		resp.Error = &Error{Code: code, Message: err.Error()}
+		return resp, true
+	}
+	if len(req.ID) > 0 {
+		resp.ID = req.ID
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		resp.Error = &Error{Code: ErrInvalidRequest, Message: `expected "jsonrpc": "2.0" and a method`}
+		return resp, true
+	}
+	method, ok := s.methods[req.Method]
+	if !ok {
+		resp.Error = &Error{Code: ErrMethodNotFound, Message: fmt.Sprintf("unknown method %q", req.Method)}
+		return resp, len(req.ID) > 0
+	}
+	result, err := method(req.Params)
+	if err != nil {
+		var rpcErr *Error
+		if !errors.As(err, &rpcErr) {
+			rpcErr = &Error{Code: ErrInternal, Message: err.Error()}
+		}
+		resp.Error = rpcErr
+		cli.Warnf("%s: %v", req.Method, err)
+	} else {
+		resp.Result = result
+	}
+	return resp, len(req.ID) > 0
+}
+
+// decode reads params into v; absent params leave v unchanged.
+func decode(params json.RawMessage, v interface{}) error {
+	if len(params) == 0 || string(params) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(params, v); err != nil {
+		return invalidParams("invalid params: %v", err)
+	}
+	return nil
+}
+
+func (s *server) open(cache string) (jira.Store, error) {
+	if cache == "" {
+		cache = s.cache
+	}
+	store, err := jira.OpenStore(cache)
+	if err != nil {
+		return nil, err
+	}
+	if err := jira.LockStore(store, false, s.wait); err != nil {
+		store.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+func (s *server) query(params json.RawMessage) (interface{}, error) {
+	var p struct {
+		Query   string   `json:"query"`
+		Cache   string   `json:"cache"`
+		Project string   `json:"project"`
+		Limit   int      `json:"limit"`
+		Fields  []string `json:"fields"`
+	}
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	q, err := query.Parse(p.Query)
+	if err != nil {
+		return nil, invalidParams("invalid query: %v", err)
+	}
+	store, err := s.open(p.Cache)
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+
+	issues := q.Filter(jira.LoadIssues(store, p.Project))
+	q.Sort(issues)
+	records := []map[string]interface{}{}
+	for i, issue := range issues {
+		if p.Limit > 0 && i == p.Limit {
+			break
+		}
+		record := jira.FlattenIssue(issue, nil)
+		if len(p.Fields) > 0 {
+			selected := map[string]interface{}{}
+			for _, name := range p.Fields {
+				if value, ok := record[name]; ok {
+					selected[name] = value
+				}
+			}
+			record = selected
+		}
+		records = append(records, record)
+	}
+	return map[string]interface{}{"total": len(issues), "issues": records}, nil
+}
+
+type keyParams struct {
+	Key   string `json:"key"`
+	Cache string `json:"cache"`
+}
+
+func (s *server) keyParams(params json.RawMessage) (keyParams, error) {
+	var p keyParams
+	if err := decode(params, &p); err != nil {
+		return p, err
+	}
+	if p.Key == "" {
+		return p, invalidParams("key is required")
+	}
+	p.Key = strings.ToUpper(p.Key)
+	return p, nil
+}
+
+func (s *server) issue(params json.RawMessage) (interface{}, error) {
+	p, err := s.keyParams(params)
+	if err != nil {
+		return nil, err
+	}
+	store, err := s.open(p.Cache)
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+	issue, err := store.ReadIssue(p.Key)
+	if err != nil {
+		return nil, &Error{Code: ErrCommand, Message: fmt.Sprintf("no cached issue %s: %v", p.Key, err)}
+	}
+	return jira.FlattenIssue(issue, nil), nil
+}
+
+func (s *server) changelog(params json.RawMessage) (interface{}, error) {
+	p, err := s.keyParams(params)
+	if err != nil {
+		return nil, err
+	}
+	store, err := s.open(p.Cache)
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+	changelog, err := store.ReadChangelog(p.Key)
+	if err != nil {
+		return nil, &Error{Code: ErrCommand, Message: fmt.Sprintf("no cached changelog for %s: %v", p.Key, err)}
+	}
+	events := jira.FieldChanges(p.Key, changelog)
+	if events == nil {
+		events = []jira.FieldChange{}
+	}
+	return events, nil
+}
+
+// sync runs fetch for a project or a JQL query; extra args are passed on
+// as fetch flags.
+func (s *server) sync(params json.RawMessage) (interface{}, error) {
+	var p struct {
+		Project string   `json:"project"`
+		JQL     string   `json:"jql"`
+		Cache   string   `json:"cache"`
+		Args    []string `json:"args"`
+	}
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	if (p.Project == "") == (p.JQL == "") {
+		return nil, invalidParams("exactly one of project and jql is required")
+	}
+	args := []string{"fetch"}
+	if p.Project != "" {
+		args = append(args, "-project", p.Project)
+	} else {
+		args = append(args, "-jql", p.JQL)
+	}
+	if p.Cache == "" {
+		p.Cache = s.cache
+	}
+	args = append(append(args, "-cache", p.Cache), p.Args...)
+	if _, err := s.run(args); err != nil {
+		return nil, err
+	}
+	return map[string]int{"exit_code": cli.ExitOK}, nil
+}
+
+// report runs a command and returns the table it wrote as columns and rows
+// of strings.
+func (s *server) report(params json.RawMessage) (interface{}, error) {
+	var p struct {
+		Command string   `json:"command"`
+		Args    []string `json:"args"`
+	}
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	if p.Command == "" {
+		return nil, invalidParams("command is required")
+	}
+	if refused[p.Command] {
+		return nil, invalidParams("%s cannot be run as a report", p.Command)
+	}
+	for _, arg := range p.Args {
+		if name, _, _ := strings.Cut(strings.TrimLeft(arg, "-"), "="); strings.HasPrefix(arg, "-") && name == "out" {
+			return nil, invalidParams("--out is not supported; the table is returned")
+		}
+	}
+	stdout, err := s.run(append([]string{p.Command}, p.Args...))
+	if err != nil {
+		return nil, err
+	}
+	r := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(stdout, []byte("\xef\xbb\xbf"))))
+	r.Comment = '#'
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, &Error{Code: ErrCommand, Message: fmt.Sprintf("%s did not write a table: %v", p.Command, err)}
+	}
+	result := map[string]interface{}{"columns": []string{}, "rows": [][]string{}}
+	if len(records) > 0 {
+		result["columns"] = records[0]
+		result["rows"] = records[1:]
+	}
+	return result, nil
+}
+
+func (s *server) commands(json.RawMessage) (interface{}, error) {
+	return []string{"query", "issue", "changelog", "sync", "report", "commands"}, nil
+}
+
+// run executes the binary with args. Its log goes to stderr as it is
+// written; a non-zero exit becomes an ErrCommand error carrying the exit
+// code and the tail of the log.
+func (s *server) run(args []string) ([]byte, error) {
+	var stdout bytes.Buffer
+	var stderr tail
+	cmd := exec.CommandContext(s.ctx, s.self, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = io.MultiWriter(os.Stderr, &stderr)
+	if err := cmd.Run(); err != nil {
+		return nil, &Error{
+			Code:    ErrCommand,
+			Message: fmt.Sprintf("%s failed: %v", args[0], err),
+			Data:    map[string]interface{}{"exit_code": cli.ExitCode(err), "log": stderr.lines()},
+		}
+	}
+	return stdout.Bytes(), nil
+}
+
+// tail keeps the last lines written to it.
+type tail struct{ buf []byte }
+
+const tailLines = 20
+
+func (t *tail) Write(p []byte) (int, error) {
+	t.buf = append(t.buf, p...)
+	if len(t.buf) > 64*1024 {
+		t.buf = t.buf[len(t.buf)-32*1024:]
+	}
+	return len(p), nil
+}
+
+func (t *tail) lines() []string {
+	lines := strings.Split(strings.TrimRight(string(t.buf), "\n"), "\n")
+	if len(lines) > tailLines {
+		lines = lines[len(lines)-tailLines:]
+	}
+	return lines
+}
@@ -0,0 +1,80 @@
+package run
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/pipeline"
+	"github.com/jctanner/rhoai-jira/internal/render"
+)
+
+func Main(args []string) {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "Print the commands the pipeline would run")
+	var renderOpts render.Options
+	render.AddFlags(fs, &renderOpts)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: %s [flags] pipeline.yaml\n\n", fs.Name())
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(cli.ExitUsage)
+	}
+
+	p, err := pipeline.Load(fs.Arg(0))
+	if err != nil {
+		cli.Fatal(err)
+	}
+
+	if *dryRun {
+		for _, step := range p.Steps {
+			projects := step.Projects
+			if len(projects) == 0 {
+				projects = []string{""}
+			}
+			for _, project := range projects {
+				fmt.Printf("%s: %s\n", step.Name, strings.Join(step.CommandLine(project), " "))
+			}
+		}
+		return
+	}
+
+	self, err := os.Executable()
+	if err != nil {
+		cli.Fatal(fmt.Errorf("locate rhoai-jira binary: %w", err))
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	runner := &pipeline.Runner{Executable: self, Stdout: os.Stdout, Stderr: os.Stderr, Logf: cli.Infof}
+	results := runner.Run(ctx, p)
+
+	table := render.NewTable("step", "project", "status", "duration", "error")
+	for _, r := range results {
+		errText := ""
+		if r.Err != nil {
+			errText = r.Err.Error()
+		}
+		table.Append(r.Step, r.Project, r.Status, r.Duration.Round(time.Millisecond).String(), errText)
+	}
+	if renderOpts.Out == "" {
+		// Steps may write to stdout, so the summary goes to stderr.
+		renderOpts.CSV.Write(os.Stderr, table)
+	} else if err := renderOpts.Write(table); err != nil {
+		cli.Fatal(err)
+	}
+	// The first failed step's exit code is passed on.
+	for _, r := range results {
+		if r.Status == pipeline.StatusFailed {
+			os.Exit(cli.ExitCode(r.Err))
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package seasonality
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/jira"
+	"github.com/jctanner/rhoai-jira/internal/render"
+)
+
+const noComponent = "(none)"
+
+var weekdays = []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday, time.Sunday}
+
+// Series counts the events of one kind for one component.
+type Series struct {
+	Component string
+	Event     string
+	Buckets   []int
+	Total     int
+	// PerHour counts events in each calendar hour, used to spot floods.
+	PerHour map[time.Time]int
+}
+
+func bucketLabels(by string) ([]string, error) {
+	var labels []string
+	switch by {
+	case "weekday":
+		for _, d := range weekdays {
+			labels = append(labels, d.String()[:3])
+		}
+	case "hour":
+		for h := 0; h < 24; h++ {
+			labels = append(labels, fmt.Sprintf("%02d", h))
+		}
+	case "weekday-hour":
+		for _, d := range weekdays {
+			for h := 0; h < 24; h++ {
+				labels = append(labels, fmt.Sprintf("%s %02d", d.String()[:3], h))
+			}
+		}
+	default:
+		return nil, fmt.Errorf("invalid --by %q (expected weekday, hour or weekday-hour)", by)
+	}
+	return labels, nil
+}
+
+func bucketIndex(by string, t time.Time) int {
+	day := (int(t.Weekday()) + 6) % 7 // Monday first
+	switch by {
+	case "weekday":
+		return day
+	case "hour":
+		return t.Hour()
+	default:
+		return day*24 + t.Hour()
+	}
+}
+
+func Main(args []string) {
+	fs := flag.NewFlagSet("seasonality", flag.ExitOnError)
+	cacheFlags := cli.AddCacheFlags(fs)
+	by := fs.String("by", "weekday", "Bucket events by weekday, hour or weekday-hour")
+	event := fs.String("event", "both", "Count created, resolved or both")
+	tz := fs.String("tz", "UTC", "Time zone used for weekdays and hours (e.g. Europe/Prague, Local)")
+	perComponent := fs.Bool("per-component", true, "Report each component separately (false for one row per event)")
+	var renderOpts render.Options
+	render.AddFlags(fs, &renderOpts)
+	fs.Parse(args)
+
+	store, err := cacheFlags.Open()
+	if err != nil {
+		cli.Fatal(err)
+	}
+	defer store.Close()
+	renderOpts.SetSource(store)
+
+	labels, err := bucketLabels(*by)
+	if err != nil {
+		cli.Fatal(err)
+	}
+	loc, err := time.LoadLocation(*tz)
+	if err != nil {
+		cli.Fatalf(cli.ExitUsage, "invalid --tz: %v", err)
+	}
+	var events []string
+	switch *event {
+	case "both":
+		events = []string{"created", "resolved"}
+	case "created", "resolved":
+		events = []string{*event}
+	default:
+		cli.Fatalf(cli.ExitUsage, "invalid --event %q (expected created, resolved or both)", *event)
+	}
+
+	series := make(map[string]*Series)
+	add := func(component, ev string, t time.Time) {
+		id := component + "\x00" + ev
+		s, ok := series[id]
+		if !ok {
+			s = &Series{Component: component, Event: ev, Buckets: make([]int, len(labels)), PerHour: make(map[time.Time]int)}
+			series[id] = s
+		}
+		t = t.In(loc)
+		s.Buckets[bucketIndex(*by, t)]++
+		s.Total++
+		s.PerHour[t.Truncate(time.Hour)]++
+	}
+
+	for _, issue := range jira.LoadIssues(store, cacheFlags.Project) {
+		components := []string{"(all)"}
+		if *perComponent {
+			components = issue.ComponentNames()
+			if len(components) == 0 {
+				components = []string{noComponent}
+			}
+		}
+		for _, ev := range events {
+			var t time.Time
+			var err error
+			if ev == "created" {
+				t, err = issue.CreatedTime()
+			} else {
+				t, err = issue.ResolvedTime()
+			}
+			if err != nil {
+				continue
+			}
+			for _, c := range components {
+				add(c, ev, t)
+			}
+		}
+	}
+
+	var ordered []*Series
+	for _, s := range series {
+		ordered = append(ordered, s)
+	}
+	sort.Slice(ordered, func(i, j int) bool {
+		if ordered[i].Component != ordered[j].Component {
+			return ordered[i].Component < ordered[j].Component
+		}
+		return ordered[i].Event < ordered[j].Event
+	})
+
+	headers := append([]string{"component", "event"}, labels...)
+	headers = append(headers, "total", "peak_hour", "peak_count")
+	table := render.NewTable(headers...)
+	for _, s := range ordered {
+		row := []string{s.Component, s.Event}
+		for _, c := range s.Buckets {
+			row = append(row, fmt.Sprintf("%d", c))
+		}
+
+		var peak time.Time
+		peakCount := 0
+		for hour, c := range s.PerHour {
+			if c > peakCount || (c == peakCount && hour.Before(peak)) {
+				peak, peakCount = hour, c
+			}
+		}
+		row = append(row, fmt.Sprintf("%d", s.Total), peak.Format("2006-01-02 15:00"), fmt.Sprintf("%d", peakCount))
+		table.Append(row...)
+	}
+	if err := renderOpts.Write(table); err != nil {
+		cli.Fatal(err)
+	}
+}
@@ -0,0 +1,189 @@
+package server
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/jira"
+)
+
+func parseSprintTime(s string) time.Time {
+	for _, layout := range []string{time.RFC3339Nano, jira.JiraTimeLayout, "2006-01-02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// SprintSummary is a sprint as listed on the dashboard front page.
+type SprintSummary struct {
+	Name      string
+	State     string
+	Start     time.Time
+	End       time.Time
+	Issues    int
+	Open      int
+	Scope     float64
+	Remaining float64
+}
+
+// AssigneeLoad is the share of a sprint assigned to one person.
+type AssigneeLoad struct {
+	Assignee  string
+	Issues    int
+	Open      int
+	Scope     float64
+	Remaining float64
+}
+
+// snapshot is the cache as last read, reloaded when its version changes.
+type snapshot struct {
+	version string
+	issues  []jira.JiraIssueWithSprints
+}
+
+// dataset serves the cached issues to the handlers, rereading the store
+// only after a sync has changed it.
+type dataset struct {
+	store   jira.Store
+	project string
+	// lock takes the shared cache lock, held only while the store is
+	// read so fetch can update it between page loads.
+	lock func(jira.Store) error
+
+	mu      sync.Mutex
+	current *snapshot
+}
+
+func (d *dataset) load() *snapshot {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.lock(d.store); err != nil {
+		cli.Warnf("%v; serving the issues read before", err)
+		if d.current == nil {
+			return &snapshot{}
+		}
+		return d.current
+	}
+	defer jira.UnlockStore(d.store)
+	version, err := d.store.Version()
+	if d.current != nil && err == nil && version == d.current.version {
+		return d.current
+	}
+	d.current = &snapshot{version: version, issues: jira.LoadIssues(d.store, d.project)}
+	return d.current
+}
+
+// scoped narrows the snapshot to the issues a scope may see.
+func (s *snapshot) scoped(sc *scope) *snapshot {
+	return &snapshot{version: s.version, issues: sc.filter(s.issues)}
+}
+
+func inSprint(issue jira.JiraIssueWithSprints, name string) bool {
+	for _, s := range issue.Fields.Sprints {
+		if s.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// stateRank orders active sprints first, then future, then closed.
+func stateRank(state string) int {
+	switch strings.ToLower(state) {
+	case "active":
+		return 0
+	case "future":
+		return 1
+	}
+	return 2
+}
+
+// sprints summarises every sprint seen on the cached issues.
+func (s *snapshot) sprints(effort jira.EffortSource) []SprintSummary {
+	byName := map[string]*SprintSummary{}
+	for _, issue := range s.issues {
+		for _, sp := range issue.Fields.Sprints {
+			sum, ok := byName[sp.Name]
+			if !ok {
+				sum = &SprintSummary{Name: sp.Name, State: sp.State, Start: parseSprintTime(sp.StartDate), End: parseSprintTime(sp.EndDate)}
+				byName[sp.Name] = sum
+			}
+			sum.Issues++
+			sum.Scope += effort.IssueEffort(issue)
+			sum.Remaining += effort.RemainingEffort(issue)
+			if !issue.IsDone() {
+				sum.Open++
+			}
+		}
+	}
+	var list []SprintSummary
+	for _, sum := range byName {
+		list = append(list, *sum)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if ri, rj := stateRank(list[i].State), stateRank(list[j].State); ri != rj {
+			return ri < rj
+		}
+		if !list[i].End.Equal(list[j].End) {
+			return list[i].End.After(list[j].End)
+		}
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
+
+// sprintIssues returns the cached issues in a sprint, open ones first.
+func (s *snapshot) sprintIssues(name string) []jira.JiraIssueWithSprints {
+	var issues []jira.JiraIssueWithSprints
+	for _, issue := range s.issues {
+		if inSprint(issue, name) {
+			issues = append(issues, issue)
+		}
+	}
+	sort.SliceStable(issues, func(i, j int) bool {
+		if di, dj := issues[i].IsDone(), issues[j].IsDone(); di != dj {
+			return dj
+		}
+		return issues[i].Key < issues[j].Key
+	})
+	return issues
+}
+
+// assigneeLoad totals the sprint's issues per assignee, most remaining
+// effort first.
+func assigneeLoad(issues []jira.JiraIssueWithSprints, effort jira.EffortSource) []AssigneeLoad {
+	byName := map[string]*AssigneeLoad{}
+	for _, issue := range issues {
+		name := issue.AssigneeID()
+		if name == "" {
+			name = "(unassigned)"
+		}
+		load, ok := byName[name]
+		if !ok {
+			load = &AssigneeLoad{Assignee: name}
+			byName[name] = load
+		}
+		load.Issues++
+		load.Scope += effort.IssueEffort(issue)
+		load.Remaining += effort.RemainingEffort(issue)
+		if !issue.IsDone() {
+			load.Open++
+		}
+	}
+	var loads []AssigneeLoad
+	for _, load := range byName {
+		loads = append(loads, *load)
+	}
+	sort.Slice(loads, func(i, j int) bool {
+		if loads[i].Remaining != loads[j].Remaining {
+			return loads[i].Remaining > loads[j].Remaining
+		}
+		return loads[i].Assignee < loads[j].Assignee
+	})
+	return loads
+}
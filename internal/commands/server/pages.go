@@ -0,0 +1,70 @@
+package server
+
+import "html/template"
+
+var pages = template.Must(template.New("pages").Parse(`
+{{define "header"}}<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}} - rhoai-jira</title>
+<style>
+body { font-family: sans-serif; margin: 0; color: #222; }
+nav { background: #1f3b57; padding: 0.6em 2em; }
+nav a { color: #fff; margin-right: 1.5em; text-decoration: none; }
+nav form { display: inline; }
+nav input[type=text] { width: 40em; }
+main { margin: 1.5em 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { padding: 0.25em 0.8em; border-bottom: 1px solid #ddd; text-align: left; }
+td.num { text-align: right; }
+tr.done td { color: #999; }
+.state { font-size: 0.8em; text-transform: uppercase; color: #666; }
+.active { color: #2ca02c; font-weight: bold; }
+.error { color: #d62728; }
+</style>
+</head>
+<body>
+<nav><a href="/">Sprints</a><form action="/search"><input type="text" name="q" value="{{.Query}}" placeholder="project = RHOAIENG AND status = &quot;In Progress&quot;"> <input type="submit" value="Search"></form></nav>
+<main>
+{{end}}
+
+{{define "footer"}}</main>
+</body>
+</html>
+{{end}}
+
+{{define "issues"}}<table>
+<tr><th>key</th><th>type</th><th>status</th><th>assignee</th><th>{{$.Effort}}</th><th>updated</th><th>summary</th></tr>
+{{range .Issues}}<tr{{if .Done}} class="done"{{end}}><td><a href="{{$.BaseURL}}/browse/{{.Key}}">{{.Key}}</a></td><td>{{.Type}}</td><td>{{.Status}}</td><td>{{.Assignee}}</td><td class="num">{{printf "%.1f" .Effort}}</td><td>{{.Updated}}</td><td>{{.Summary}}</td></tr>
+{{end}}</table>
+{{end}}
+
+{{define "index"}}{{template "header" .}}
+<h1>Sprints</h1>
+<p>{{.Cached}} cached issues</p>
+<table>
+<tr><th>sprint</th><th>state</th><th>start</th><th>end</th><th>issues</th><th>open</th><th>{{.Effort}}</th><th>remaining</th></tr>
+{{range .Sprints}}<tr><td><a href="/sprint?name={{.Name}}">{{.Name}}</a></td><td class="state{{if eq .State "active"}} active{{end}}">{{.State}}</td><td>{{if not .Start.IsZero}}{{.Start.Format "2006-01-02"}}{{end}}</td><td>{{if not .End.IsZero}}{{.End.Format "2006-01-02"}}{{end}}</td><td class="num">{{.Issues}}</td><td class="num">{{.Open}}</td><td class="num">{{printf "%.1f" .Scope}}</td><td class="num">{{printf "%.1f" .Remaining}}</td></tr>
+{{end}}</table>
+{{template "footer" .}}{{end}}
+
+{{define "sprint"}}{{template "header" .}}
+<h1>{{.Sprint}}</h1>
+<img src="/burndown.svg?sprint={{.Sprint}}" alt="burndown of {{.Sprint}}">
+{{if .Load}}<h2>Load per assignee</h2>
+<table>
+<tr><th>assignee</th><th>issues</th><th>open</th><th>{{.Effort}}</th><th>remaining</th></tr>
+{{range .Load}}<tr><td>{{.Assignee}}</td><td class="num">{{.Issues}}</td><td class="num">{{.Open}}</td><td class="num">{{printf "%.1f" .Scope}}</td><td class="num">{{printf "%.1f" .Remaining}}</td></tr>
+{{end}}</table>{{end}}
+<h2>Issues ({{len .Issues}})</h2>
+{{template "issues" .}}
+{{template "footer" .}}{{end}}
+
+{{define "search"}}{{template "header" .}}
+<h1>Search</h1>
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+{{if .Issues}}<p>{{.Total}} matching issues{{if gt .Total (len .Issues)}}, showing the first {{len .Issues}}{{end}}</p>
+{{template "issues" .}}{{else if .Query}}{{if not .Error}}<p>No matching issues.</p>{{end}}{{end}}
+{{template "footer" .}}{{end}}
+`))
@@ -0,0 +1,152 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/config"
+	"github.com/jctanner/rhoai-jira/internal/jira"
+)
+
+// scope is the part of the cache a caller may see: issues of some
+// projects and some of their fields. The zero scope sees everything.
+type scope struct {
+	name     string
+	projects map[string]bool
+	fields   map[string]bool
+}
+
+func (s *scope) allowsIssue(issue jira.JiraIssueWithSprints) bool {
+	if s.projects == nil {
+		return true
+	}
+	project, _, _ := strings.Cut(issue.Key, "-")
+	return s.projects[project]
+}
+
+// allowsField reports whether a field of the flattened issue, as named by
+// jira.IssueSchema, may be shown. The key always may.
+func (s *scope) allowsField(name string) bool {
+	return s.fields == nil || name == "key" || s.fields[strings.ToLower(name)]
+}
+
+func (s *scope) filter(issues []jira.JiraIssueWithSprints) []jira.JiraIssueWithSprints {
+	if s.projects == nil {
+		return issues
+	}
+	var kept []jira.JiraIssueWithSprints
+	for _, issue := range issues {
+		if s.allowsIssue(issue) {
+			kept = append(kept, issue)
+		}
+	}
+	return kept
+}
+
+// credential is a configured API token, kept as a hash so lookups compare
+// fixed-size values in constant time.
+type credential struct {
+	hash  [32]byte
+	scope *scope
+}
+
+// loadCredentials resolves the tokens of the server config. A token that
+// resolves to nothing, such as an unset environment variable, is an
+// error rather than a token everyone could match.
+func loadCredentials(c *config.Config) ([]credential, error) {
+	var creds []credential
+	for _, t := range c.Server.Tokens {
+		secret, err := c.ResolveSecret(t.Token)
+		if err != nil {
+			return nil, fmt.Errorf("server token %s: %w", t.Name, err)
+		}
+		if secret == "" {
+			return nil, fmt.Errorf("server token %s is empty", t.Name)
+		}
+		sc := &scope{name: t.Name}
+		if len(t.Projects) > 0 {
+			sc.projects = map[string]bool{}
+			for _, p := range t.Projects {
+				sc.projects[strings.ToUpper(p)] = true
+			}
+		}
+		if len(t.Fields) > 0 {
+			sc.fields = map[string]bool{}
+			for _, f := range t.Fields {
+				sc.fields[strings.ToLower(f)] = true
+			}
+		}
+		creds = append(creds, credential{hash: sha256.Sum256([]byte(secret)), scope: sc})
+	}
+	return creds, nil
+}
+
+type scopeKey struct{}
+
+func scopeOf(r *http.Request) *scope {
+	if sc, ok := r.Context().Value(scopeKey{}).(*scope); ok {
+		return sc
+	}
+	return &scope{}
+}
+
+// tokenCookie keeps the token of a browser session after it was given
+// once as ?token=.
+const tokenCookie = "rhoai_jira_token"
+
+// requestToken finds the token of a request: a Bearer Authorization
+// header, the ?token= parameter or the session cookie.
+func requestToken(r *http.Request) (token string, fromQuery bool) {
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(auth, "Bearer ")), false
+	}
+	if token := r.URL.Query().Get("token"); token != "" {
+		return token, true
+	}
+	if c, err := r.Cookie(tokenCookie); err == nil {
+		return c.Value, false
+	}
+	return "", false
+}
+
+// authenticate requires one of the configured tokens and attaches its
+// scope to the request. Without configured tokens everything is open.
+func (s *server) authenticate(next http.Handler) http.Handler {
+	if len(s.credentials) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, fromQuery := requestToken(r)
+		hash := sha256.Sum256([]byte(token))
+		var match *scope
+		for _, c := range s.credentials {
+			if subtle.ConstantTimeCompare(hash[:], c.hash[:]) == 1 {
+				match = c.scope
+			}
+		}
+		if token == "" || match == nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="rhoai-jira"`)
+			http.Error(w, "a valid API token is required", http.StatusUnauthorized)
+			return
+		}
+		if fromQuery {
+			// Move the token into a cookie so it leaves the address bar
+			// and the server logs of later requests.
+			http.SetCookie(w, &http.Cookie{Name: tokenCookie, Value: token, Path: "/", HttpOnly: true, SameSite: http.SameSiteStrictMode, Expires: time.Now().Add(12 * time.Hour)})
+			q := r.URL.Query()
+			q.Del("token")
+			u := *r.URL
+			u.RawQuery = q.Encode()
+			http.Redirect(w, r, u.RequestURI(), http.StatusSeeOther)
+			return
+		}
+		cli.Infof("%s %s (%s)", r.Method, r.URL.Path, match.name)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), scopeKey{}, match)))
+	})
+}
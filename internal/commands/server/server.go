@@ -0,0 +1,304 @@
+// Package server serves an HTML dashboard over the local cache: the
+// sprints, their contents, burndown and per-assignee load, and issue
+// search, for teammates who do not use the command line. The same issues
+// are served as JSON under /api. API tokens in the config file limit each
+// consumer to some projects and fields.
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/commands/burndown"
+	"github.com/jctanner/rhoai-jira/internal/jira"
+	"github.com/jctanner/rhoai-jira/internal/query"
+)
+
+type server struct {
+	data        *dataset
+	effort      jira.EffortSource
+	baseURL     string
+	searchLimit int
+	credentials []credential
+}
+
+func Main(args []string) {
+	fs := flag.NewFlagSet("server", flag.ExitOnError)
+	cacheFlags := cli.AddCacheFlags(fs)
+	addr := fs.String("addr", ":8080", "Address to serve the dashboard on")
+	effortStr := fs.String("effort", "points", "Effort source (points, time, count)")
+	baseURL := fs.String("base-url", cli.BaseURL(), "Jira base URL issue keys link to")
+	searchLimit := fs.Int("search-limit", 200, "Maximum number of search results shown")
+	fs.Parse(args)
+
+	effort, err := jira.ParseEffortSource(*effortStr)
+	if err != nil {
+		cli.Fatal(cli.WithCode(cli.ExitUsage, err))
+	}
+	credentials, err := loadCredentials(cli.Settings())
+	if err != nil {
+		cli.Fatal(err)
+	}
+	store, err := cacheFlags.OpenUnlocked()
+	if err != nil {
+		cli.Fatal(err)
+	}
+	defer store.Close()
+
+	s := &server{
+		credentials: credentials,
+		data:        &dataset{store: store, project: cacheFlags.Project, lock: cacheFlags.Lock},
+		effort:      effort,
+		baseURL:     strings.TrimRight(*baseURL, "/"),
+		searchLimit: *searchLimit,
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := s.serve(ctx, *addr); err != nil && !errors.Is(err, context.Canceled) {
+		cli.Fatal(err)
+	}
+}
+
+func (s *server) serve(ctx context.Context, addr string) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.index)
+	mux.HandleFunc("/sprint", s.sprint)
+	mux.HandleFunc("/burndown.svg", s.burndownChart)
+	mux.HandleFunc("/search", s.search)
+	mux.HandleFunc("/api/issues", s.apiIssues)
+	mux.HandleFunc("/api/issue", s.apiIssue)
+
+	server := &http.Server{Addr: addr, Handler: s.authenticate(mux)}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+	if len(s.credentials) > 0 {
+		cli.Infof("serving dashboard on %s to %d API tokens", addr, len(s.credentials))
+	} else {
+		cli.Infof("serving dashboard on %s", addr)
+	}
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return ctx.Err()
+}
+
+func (s *server) render(w http.ResponseWriter, name string, data map[string]interface{}) {
+	data["BaseURL"] = s.baseURL
+	data["Effort"] = s.effort.ColumnName()
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := pages.ExecuteTemplate(w, name, data); err != nil {
+		cli.Warnf("render %s: %v", name, err)
+	}
+}
+
+func (s *server) index(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	snap := s.data.load().scoped(scopeOf(r))
+	s.render(w, "index", map[string]interface{}{
+		"Title":   "Sprints",
+		"Sprints": snap.sprints(s.effort),
+		"Cached":  len(snap.issues),
+	})
+}
+
+func (s *server) sprint(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("name")
+	sc := scopeOf(r)
+	issues := s.data.load().scoped(sc).sprintIssues(name)
+	if len(issues) == 0 {
+		http.Error(w, "no cached issues are in sprint "+name, http.StatusNotFound)
+		return
+	}
+	rows := make([]issueRow, 0, len(issues))
+	for _, issue := range issues {
+		rows = append(rows, s.row(sc, issue))
+	}
+	var load []AssigneeLoad
+	if sc.allowsField("assignee") {
+		load = assigneeLoad(issues, s.effort)
+	}
+	s.render(w, "sprint", map[string]interface{}{
+		"Title":  name,
+		"Sprint": name,
+		"Issues": rows,
+		"Load":   load,
+	})
+}
+
+func (s *server) burndownChart(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("sprint")
+	var tracked []burndown.Tracked
+	sc := scopeOf(r)
+	all, _ := burndown.Load(s.data.store, s.data.project, name)
+	for _, t := range all {
+		if sc.allowsIssue(t.Issue) {
+			tracked = append(tracked, t)
+		}
+	}
+	start, end, ok := burndown.SprintWindow(tracked, name)
+	if !ok {
+		http.Error(w, "sprint dates unknown", http.StatusNotFound)
+		return
+	}
+	days, _, _ := burndown.Burndown(tracked, name, s.effort, start, end, time.Now())
+	chart := burndown.Chart(name, days)
+	chart.Annotations = burndown.Annotations(tracked, name, days, burndown.AnnotationOptions{
+		Effort:         s.effort,
+		ScopeThreshold: burndown.DefaultScopeThreshold,
+		Holidays:       cli.Holidays(),
+	})
+	chart.Width, chart.Height = 720, 320
+	w.Header().Set("Content-Type", "image/svg+xml")
+	if err := chart.WriteSVG(w); err != nil {
+		cli.Warnf("burndown %s: %v", name, err)
+	}
+}
+
+func (s *server) search(w http.ResponseWriter, r *http.Request) {
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	data := map[string]interface{}{"Title": "Search", "Query": q}
+	if q != "" {
+		parsed, err := query.Parse(q)
+		if err != nil {
+			data["Error"] = err.Error()
+		} else {
+			matches := parsed.Filter(s.data.load().scoped(scopeOf(r)).issues)
+			data["Total"] = len(matches)
+			if len(matches) > s.searchLimit {
+				matches = matches[:s.searchLimit]
+			}
+			rows := make([]issueRow, 0, len(matches))
+			for _, issue := range matches {
+				rows = append(rows, s.row(scopeOf(r), issue))
+			}
+			data["Issues"] = rows
+		}
+	}
+	s.render(w, "search", data)
+}
+
+// issueRow is an issue as shown in the dashboard tables.
+type issueRow struct {
+	Key      string
+	Type     string
+	Status   string
+	Assignee string
+	Effort   float64
+	Done     bool
+	Summary  string
+	Updated  string
+}
+
+// row shows the fields of an issue the scope allows, leaving the others
+// blank.
+func (s *server) row(sc *scope, issue jira.JiraIssueWithSprints) issueRow {
+	row := issueRow{Key: issue.Key, Done: issue.IsDone()}
+	if sc.allowsField("type") {
+		row.Type = issue.Fields.IssueType.Name
+	}
+	if sc.allowsField("status") {
+		row.Status = issue.Fields.Status.Name
+	}
+	if sc.allowsField("assignee") {
+		row.Assignee = issue.AssigneeID()
+	}
+	if sc.allowsField(effortField(s.effort)) {
+		row.Effort = s.effort.IssueEffort(issue)
+	}
+	if sc.allowsField("summary") {
+		row.Summary = issue.Fields.Summary
+	}
+	if sc.allowsField("updated") {
+		row.Updated = issue.Fields.Updated
+		if t, err := issue.UpdatedTime(); err == nil {
+			row.Updated = t.Format("2006-01-02")
+		}
+	}
+	return row
+}
+
+// effortField is the exported field an effort source reads.
+func effortField(effort jira.EffortSource) string {
+	switch effort {
+	case jira.EffortPoints:
+		return "story_points"
+	case jira.EffortTime:
+		return "original_estimate"
+	}
+	return "key"
+}
+
+// apiIssues serves the issues matching ?q= (JQL-lite, all when empty) as
+// flattened JSON records holding the fields the scope allows.
+func (s *server) apiIssues(w http.ResponseWriter, r *http.Request) {
+	sc := scopeOf(r)
+	issues := s.data.load().scoped(sc).issues
+	if q := strings.TrimSpace(r.URL.Query().Get("q")); q != "" {
+		parsed, err := query.Parse(q)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		issues = parsed.Filter(issues)
+	}
+	limit := s.searchLimit
+	if n, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && n > 0 {
+		limit = n
+	}
+	records := []map[string]interface{}{}
+	for i, issue := range issues {
+		if i == limit {
+			break
+		}
+		records = append(records, s.record(sc, issue))
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"total": len(issues), "issues": records})
+}
+
+// apiIssue serves the issue named by ?key=.
+func (s *server) apiIssue(w http.ResponseWriter, r *http.Request) {
+	key := strings.ToUpper(r.URL.Query().Get("key"))
+	sc := scopeOf(r)
+	for _, issue := range s.data.load().scoped(sc).issues {
+		if issue.Key == key {
+			writeJSON(w, http.StatusOK, s.record(sc, issue))
+			return
+		}
+	}
+	writeJSON(w, http.StatusNotFound, map[string]string{"error": "no cached issue " + key})
+}
+
+func (s *server) record(sc *scope, issue jira.JiraIssueWithSprints) map[string]interface{} {
+	record := jira.FlattenIssue(issue, nil)
+	for name := range record {
+		if !sc.allowsField(name) {
+			delete(record, name)
+		}
+	}
+	return record
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		cli.Warnf("encode response: %v", err)
+	}
+}
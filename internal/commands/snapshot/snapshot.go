@@ -0,0 +1,233 @@
+// Package snapshot reconstructs the issues of a sprint as they were at one
+// point in time, from their changelogs: status, effort and assignee, so
+// the sprint on day 1 can be compared with the sprint on day 10.
+package snapshot
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/commands/burndown"
+	"github.com/jctanner/rhoai-jira/internal/jira"
+	"github.com/jctanner/rhoai-jira/internal/render"
+	"github.com/jctanner/rhoai-jira/internal/tools"
+)
+
+// Issue is the state of one issue at the time of the snapshot.
+type Issue struct {
+	Key      string  `json:"key"`
+	Summary  string  `json:"summary"`
+	Type     string  `json:"type"`
+	Status   string  `json:"status"`
+	Assignee string  `json:"assignee"`
+	Effort   float64 `json:"effort"`
+	Done     bool    `json:"done"`
+	// InSprint is false for issues listed with --all that were not in
+	// the sprint at the time.
+	InSprint bool `json:"inSprint"`
+}
+
+// Snapshot is a sprint at one point in time.
+type Snapshot struct {
+	Sprint string    `json:"sprint"`
+	At     time.Time `json:"at"`
+	// EffortUnit names what Effort counts: points, hours or issues.
+	EffortUnit string  `json:"effortUnit"`
+	Issues     []Issue `json:"issues"`
+}
+
+// IssueAt replays the changelog of a tracked issue up to at. It returns
+// false when the issue did not exist yet.
+func IssueAt(t burndown.Tracked, sprint string, effort jira.EffortSource, at time.Time) (Issue, bool) {
+	load, ok := jira.LoadAt(t.Issue, t.Changelog, effort, at)
+	if !ok {
+		return Issue{}, false
+	}
+	state := burndown.StateAt(t, sprint, effort, at)
+	issue := Issue{
+		Key:      t.Issue.Key,
+		Summary:  valueAt(t, "summary", at, t.Issue.Fields.Summary),
+		Type:     valueAt(t, "issuetype", at, t.Issue.Fields.IssueType.Name),
+		Status:   valueAt(t, "status", at, t.Issue.Fields.Status.Name),
+		Assignee: load.Assignee,
+		Effort:   load.Effort,
+		Done:     !load.Open,
+		InSprint: state.InSprint,
+	}
+	return issue, true
+}
+
+// valueAt is the value of a field at a time, its current value when the
+// changelog does not say.
+func valueAt(t burndown.Tracked, field string, at time.Time, current string) string {
+	if value, ok := jira.ValueAt(t.Changelog, field, at); ok {
+		return value
+	}
+	return current
+}
+
+// Take reconstructs the issues in the sprint at at, or with all every
+// tracked issue that existed then, ordered by key.
+func Take(tracked []burndown.Tracked, sprint string, effort jira.EffortSource, at time.Time, all bool) []Issue {
+	var issues []Issue
+	for _, t := range tracked {
+		issue, ok := IssueAt(t, sprint, effort, at)
+		if ok && (issue.InSprint || all) {
+			issues = append(issues, issue)
+		}
+	}
+	order := map[string]int{}
+	keys := make([]string, len(issues))
+	for i, issue := range issues {
+		keys[i] = issue.Key
+	}
+	for i, key := range tools.SortNumerically(keys) {
+		order[key] = i
+	}
+	sort.Slice(issues, func(i, j int) bool { return order[issues[i].Key] < order[issues[j].Key] })
+	return issues
+}
+
+func parseAt(value string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			if layout == "2006-01-02" {
+				// A date means the state at the end of that day.
+				t = t.Add(24*time.Hour - time.Nanosecond)
+			}
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid --at %q (expected YYYY-MM-DD, \"YYYY-MM-DD HH:MM\" or RFC3339)", value)
+}
+
+// dayEnd is the end of day n of a sprint, counting its first day as 1.
+func dayEnd(start time.Time, n int) time.Time {
+	start = start.Local()
+	first := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.Local)
+	return first.AddDate(0, 0, n).Add(-time.Nanosecond)
+}
+
+func Main(args []string) {
+	fs := flag.NewFlagSet("snapshot", flag.ExitOnError)
+	cacheFlags := cli.AddCacheFlags(fs)
+	sprint := fs.String("sprint", "", "Sprint to reconstruct (required)")
+	atStr := fs.String("at", "", "Point in time: YYYY-MM-DD (end of that day), \"YYYY-MM-DD HH:MM\" or RFC3339 (default: now)")
+	day := fs.Int("day", 0, "Point in time as the end of this day of the sprint, 1 being its first day")
+	effortStr := fs.String("effort", "points", "Effort source (points, time, count)")
+	all := fs.Bool("all", false, "Also list issues that were in the sprint at another time, with in_sprint false")
+	var renderOpts render.Options
+	render.AddFlags(fs, &renderOpts)
+	fs.Parse(args)
+
+	if *sprint == "" {
+		cli.Fatalf(cli.ExitUsage, "--sprint must be provided.")
+	}
+	if *atStr != "" && *day != 0 {
+		cli.Fatalf(cli.ExitUsage, "--at and --day are mutually exclusive")
+	}
+	if *day < 0 {
+		cli.Fatalf(cli.ExitUsage, "--day must be 1 or more")
+	}
+	effort, err := jira.ParseEffortSource(*effortStr)
+	if err != nil {
+		cli.Fatal(cli.WithCode(cli.ExitUsage, err))
+	}
+	at := time.Now()
+	if *atStr != "" {
+		if at, err = parseAt(*atStr); err != nil {
+			cli.Fatal(cli.WithCode(cli.ExitUsage, err))
+		}
+	}
+
+	store, err := cacheFlags.Open()
+	if err != nil {
+		cli.Fatal(err)
+	}
+	defer store.Close()
+	renderOpts.SetSource(store)
+
+	tracked, coverage := burndown.Load(store, cacheFlags.Project, *sprint)
+	if len(tracked) == 0 {
+		cli.Fatalf(cli.ExitNoData, "no cached issues were ever in sprint %q", *sprint)
+	}
+	coverage.Log()
+	if *day > 0 {
+		start, _, ok := burndown.SprintWindow(tracked, *sprint)
+		if !ok {
+			cli.Fatalf(cli.ExitNoData, "could not determine the start of sprint %q; pass --at", *sprint)
+		}
+		at = dayEnd(start, *day)
+	}
+	if now := time.Now(); at.After(now) {
+		at = now
+	}
+
+	snap := Snapshot{
+		Sprint:     *sprint,
+		At:         at,
+		EffortUnit: effort.ColumnName(),
+		Issues:     Take(tracked, *sprint, effort, at, *all),
+	}
+	members, done := 0, 0
+	var scope, completed float64
+	for _, issue := range snap.Issues {
+		if !issue.InSprint {
+			continue
+		}
+		members++
+		scope += issue.Effort
+		if issue.Done {
+			done++
+			completed += issue.Effort
+		}
+	}
+	stamp := at.Format("2006-01-02 15:04 MST")
+	cli.Infof("sprint %q at %s: %d issues, %d done; %.1f of %.1f %s completed", *sprint, stamp, members, done, completed, scope, effort.ColumnName())
+	if members == 0 && !*all {
+		cli.Fatalf(cli.ExitNoData, "no cached issues were in sprint %q at %s", *sprint, stamp)
+	}
+
+	// JSON output is the whole snapshot, typed, rather than the table.
+	if renderOpts.OutputFormat() == render.FormatJSON {
+		w, _, err := renderOpts.Create()
+		if err != nil {
+			cli.Fatal(err)
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(snap)
+		if closeErr := w.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			cli.Fatal(err)
+		}
+		return
+	}
+
+	renderOpts.Title = fmt.Sprintf("Snapshot: %s at %s", *sprint, stamp)
+	renderOpts.AddNote("%d issues, %d done; %.1f of %.1f %s completed", members, done, completed, scope, effort.ColumnName())
+	table := render.NewTable("key", "type", "status", "assignee", effort.ColumnName(), "done", "in_sprint", "summary")
+	table.SetNumeric(effort.ColumnName())
+	for _, issue := range snap.Issues {
+		table.Append(
+			issue.Key,
+			issue.Type,
+			issue.Status,
+			issue.Assignee,
+			fmt.Sprintf("%.1f", issue.Effort),
+			strconv.FormatBool(issue.Done),
+			strconv.FormatBool(issue.InSprint),
+			issue.Summary,
+		)
+	}
+	if err := renderOpts.Write(table); err != nil {
+		cli.Fatal(err)
+	}
+}
@@ -0,0 +1,314 @@
+// Package sprintreport breaks a sprint down the way a retrospective looks
+// at it: what was committed at the start, what was added and removed
+// along the way, what was completed and what spilled over.
+package sprintreport
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/commands/burndown"
+	"github.com/jctanner/rhoai-jira/internal/events"
+	"github.com/jctanner/rhoai-jira/internal/jira"
+	"github.com/jctanner/rhoai-jira/internal/render"
+)
+
+// Categories of the report, in the order they are listed. An issue can be
+// in several: committed and completed, added and spilled over.
+const (
+	Committed = "committed"
+	Added     = "added"
+	Removed   = "removed"
+	Completed = "completed"
+	Spilled   = "spilled"
+)
+
+var categories = []string{Committed, Added, Removed, Completed, Spilled}
+
+// Entry is an issue in one category of the report.
+type Entry struct {
+	Category string
+	Key      string
+	// At is when the issue entered the category: the sprint start, the
+	// change that added or removed it, its resolution, or the sprint end.
+	At     time.Time
+	Effort float64
+	Status string
+	// Next lists the sprints a spilled issue moved on to.
+	Next []string
+	// Subtasks lists the subtasks rolled up into the issue.
+	Subtasks []string
+	Summary  string
+}
+
+// transition is a change of the sprint field adding an issue to the
+// sprint or removing it.
+type transition struct {
+	at    time.Time
+	added bool
+}
+
+// transitions lists the changes adding the issue to the sprint or
+// removing it between start and end.
+func transitions(t burndown.Tracked, sprint string, start, end time.Time) []transition {
+	var out []transition
+	changes := events.Filter(events.Normalize(t.Issue.Key, t.Changelog), events.SprintAdded, events.SprintRemoved)
+	for _, e := range events.Between(changes, start, end) {
+		if jira.SameSprint(e.Sprint, sprint) {
+			out = append(out, transition{at: e.At, added: e.Kind == events.SprintAdded})
+		}
+	}
+	return out
+}
+
+// completedAt finds when the issue was resolved, or moved to a done
+// status, between start and end. A story with subtasks rolled up into it
+// is completed with the last of them.
+func completedAt(t burndown.Tracked, start, end time.Time) (time.Time, bool) {
+	var last time.Time
+	for _, sub := range t.Subtasks {
+		if at, ok := completedAt(sub, start, end); ok && at.After(last) {
+			last = at
+		}
+	}
+	if !last.IsZero() {
+		return last, true
+	}
+	var found time.Time
+	for _, e := range events.Between(events.Normalize(t.Issue.Key, t.Changelog), start, end) {
+		switch {
+		case e.Kind == events.ResolutionChanged && e.To != "":
+			found = e.At
+		case e.Kind == events.StatusChanged && jira.Status{Name: e.To}.IsDone():
+			found = e.At
+		}
+	}
+	if !found.IsZero() {
+		return found, true
+	}
+	if resolved, err := t.Issue.ResolvedTime(); err == nil && resolved.After(start) && !resolved.After(end) {
+		return resolved, true
+	}
+	return time.Time{}, false
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Report places the tracked issues of a sprint in the categories, judged
+// by their changelogs at start and end. Issues in the sprint within grace
+// of the start count as committed.
+func Report(tracked []burndown.Tracked, sprint string, effort jira.EffortSource, start, end time.Time, grace time.Duration) []Entry {
+	committedBy := start.Add(grace)
+	var entries []Entry
+	for _, t := range tracked {
+		add := func(category string, at time.Time, state burndown.IssueState) {
+			var subtasks []string
+			for _, sub := range t.Subtasks {
+				subtasks = append(subtasks, sub.Issue.Key)
+			}
+			entries = append(entries, Entry{
+				Category: category,
+				Key:      t.Issue.Key,
+				At:       at,
+				Effort:   state.Effort,
+				Status:   statusAt(t, at),
+				Subtasks: subtasks,
+				Summary:  t.Issue.Fields.Summary,
+			})
+		}
+		atStart := burndown.StateAt(t, sprint, effort, committedBy)
+		atEnd := burndown.StateAt(t, sprint, effort, end)
+		if atStart.InSprint {
+			add(Committed, start, atStart)
+		}
+		// An issue added, removed and added again is added once.
+		added := atStart.InSprint
+		var lastRemoved time.Time
+		for _, tr := range transitions(t, sprint, committedBy, end) {
+			switch {
+			case tr.added && !added:
+				add(Added, tr.at, burndown.StateAt(t, sprint, effort, tr.at))
+				added = true
+			case !tr.added:
+				lastRemoved = tr.at
+			}
+		}
+		if !atEnd.InSprint {
+			if !lastRemoved.IsZero() {
+				add(Removed, lastRemoved, burndown.StateAt(t, sprint, effort, lastRemoved.Add(-time.Millisecond)))
+			}
+			continue
+		}
+		if atEnd.Done {
+			if at, ok := completedAt(t, start, end); ok {
+				add(Completed, at, atEnd)
+			}
+			continue
+		}
+		add(Spilled, end, atEnd)
+		entries[len(entries)-1].Next = nextSprints(t, sprint)
+	}
+	order := map[string]int{}
+	for i, c := range categories {
+		order[c] = i
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.Category != b.Category {
+			return order[a.Category] < order[b.Category]
+		}
+		if !a.At.Equal(b.At) {
+			return a.At.Before(b.At)
+		}
+		return a.Key < b.Key
+	})
+	return entries
+}
+
+// statusAt is the status of the issue at a time, its current status when
+// the changelog does not say, rolled up from its subtasks when it has them.
+func statusAt(t burndown.Tracked, at time.Time) string {
+	status := t.Issue.Fields.Status.Name
+	if value, ok := jira.ValueAt(t.Changelog, "status", at); ok {
+		status = value
+	}
+	if len(t.Subtasks) == 0 {
+		return status
+	}
+	var subtasks []jira.Status
+	for _, sub := range t.Subtasks {
+		if !at.Before(sub.Created) {
+			subtasks = append(subtasks, jira.Status{Name: statusAt(sub, at)})
+		}
+	}
+	return jira.RollupStatus(jira.Status{Name: status}, subtasks).Name
+}
+
+// nextSprints lists the sprints other than the reported one the issue is
+// in now.
+func nextSprints(t burndown.Tracked, sprint string) []string {
+	var next []string
+	for _, s := range t.Issue.Fields.Sprints {
+		name := jira.CanonicalSprint(s.Name)
+		if !jira.SameSprint(name, sprint) && !contains(next, name) {
+			next = append(next, name)
+		}
+	}
+	return next
+}
+
+func Main(args []string) {
+	fs := flag.NewFlagSet("sprint-report", flag.ExitOnError)
+	cacheFlags := cli.AddCacheFlags(fs)
+	sprint := fs.String("sprint", "", "Sprint name to report on (required)")
+	effortStr := fs.String("effort", "points", "Effort source (points, time, count)")
+	startStr := fs.String("start", "", "Sprint start date YYYY-MM-DD (default: from the sprint)")
+	endStr := fs.String("end", "", "Sprint end date YYYY-MM-DD (default: from the sprint)")
+	grace := fs.Duration("grace", 0, "Count issues added this long after the start as committed (e.g. 2h for planning running late)")
+	rollup := fs.Bool("rollup-subtasks", false, "Roll the status and effort of subtasks up into their story instead of reporting them on their own")
+	var renderOpts render.Options
+	render.AddFlags(fs, &renderOpts)
+	fs.Parse(args)
+
+	if *sprint == "" {
+		cli.Fatalf(cli.ExitUsage, "--sprint must be provided.")
+	}
+	effort, err := jira.ParseEffortSource(*effortStr)
+	if err != nil {
+		cli.Fatal(cli.WithCode(cli.ExitUsage, err))
+	}
+	store, err := cacheFlags.Open()
+	if err != nil {
+		cli.Fatal(err)
+	}
+	defer store.Close()
+	renderOpts.SetSource(store)
+
+	load := burndown.Load
+	if *rollup {
+		load = burndown.LoadRollup
+	}
+	tracked, coverage := load(store, cacheFlags.Project, *sprint)
+	if len(tracked) == 0 {
+		cli.Fatalf(cli.ExitNoData, "no cached issues were ever in sprint %q", *sprint)
+	}
+	coverage.Log()
+
+	start, end, _ := burndown.SprintWindow(tracked, *sprint)
+	if *startStr != "" {
+		if start, err = time.Parse("2006-01-02", *startStr); err != nil {
+			cli.Fatalf(cli.ExitUsage, "invalid --start %q", *startStr)
+		}
+	}
+	if *endStr != "" {
+		if end, err = time.Parse("2006-01-02", *endStr); err != nil {
+			cli.Fatalf(cli.ExitUsage, "invalid --end %q", *endStr)
+		}
+		end = end.Add(24*time.Hour - time.Nanosecond)
+	}
+	if start.IsZero() || end.IsZero() {
+		cli.Fatalf(cli.ExitNoData, "could not determine the dates of sprint %q; pass --start and --end", *sprint)
+	}
+	if now := time.Now(); end.After(now) {
+		cli.Infof("sprint %q is still running; reporting up to now", *sprint)
+		end = now
+	}
+
+	entries := Report(tracked, *sprint, effort, start, end, *grace)
+	counts := map[string]int{}
+	totals := map[string]float64{}
+	for _, e := range entries {
+		counts[e.Category]++
+		totals[e.Category] += e.Effort
+	}
+	var parts []string
+	for _, c := range categories {
+		parts = append(parts, fmt.Sprintf("%s %d (%.1f)", c, counts[c], totals[c]))
+	}
+	cli.Infof("sprint %q, %s to %s, %s: %s", *sprint, start.Format("2006-01-02"), end.Format("2006-01-02"), effort.ColumnName(), strings.Join(parts, ", "))
+	renderOpts.Title = fmt.Sprintf("Sprint report: %s", *sprint)
+	renderOpts.AddNote("%s to %s, %s: %s", start.Format("2006-01-02"), end.Format("2006-01-02"), effort.ColumnName(), strings.Join(parts, ", "))
+	if totals[Committed] > 0 {
+		cli.Infof("completed %.1f %s against %.1f committed (%.0f%%)", totals[Completed], effort.ColumnName(), totals[Committed], 100*totals[Completed]/totals[Committed])
+		renderOpts.AddNote("completed %.1f %s against %.1f committed (%.0f%%)", totals[Completed], effort.ColumnName(), totals[Committed], 100*totals[Completed]/totals[Committed])
+	}
+	if renderOpts.HTML() {
+		days, _, _ := burndown.Burndown(tracked, *sprint, effort, start, end, time.Now())
+		renderOpts.AddChart(burndown.Chart(*sprint, days))
+	}
+
+	headers := []string{"category", "key", "at", effort.ColumnName(), "status", "next_sprints", "summary"}
+	if *rollup {
+		headers = append(headers[:6], "subtasks", "summary")
+	}
+	table := render.NewTable(headers...)
+	table.SetNumeric(effort.ColumnName())
+	for _, e := range entries {
+		row := []string{
+			e.Category,
+			e.Key,
+			e.At.UTC().Format(time.RFC3339),
+			fmt.Sprintf("%.1f", e.Effort),
+			e.Status,
+			strings.Join(e.Next, "; "),
+		}
+		if *rollup {
+			row = append(row, strings.Join(e.Subtasks, " "))
+		}
+		table.Append(append(row, e.Summary)...)
+	}
+	if err := renderOpts.Write(table); err != nil {
+		cli.Fatal(err)
+	}
+}
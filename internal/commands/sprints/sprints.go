@@ -0,0 +1,33 @@
+package sprints
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/jira"
+	"github.com/jctanner/rhoai-jira/internal/tools"
+)
+
+func Main(args []string) {
+	fs := flag.NewFlagSet("sprints", flag.ExitOnError)
+	dir := fs.String("dir", "issues", "Directory containing cached issues")
+	sprintFilter := fs.String("sprint-filter", "", "If set, only include this sprint in output")
+	fs.Parse(args)
+
+	index, err := jira.UpdateCacheIndex(*dir, false)
+	if err != nil {
+		cli.Fatal(err)
+	}
+	var matchedKeys []string
+	for key, entry := range index.Issues {
+		if entry.InSprint(*sprintFilter) {
+			matchedKeys = append(matchedKeys, key)
+		}
+	}
+
+	matchedKeys = tools.SortNumerically(matchedKeys)
+	for ix, key := range matchedKeys {
+		fmt.Printf("%d,%s\n", ix, key)
+	}
+}
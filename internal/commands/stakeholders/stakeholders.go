@@ -0,0 +1,291 @@
+// Package stakeholders lists who watches and who is @mentioned across the
+// issues of a sprint or an epic, from the watchers saved by fetch
+// --watchers and the mentions in descriptions and comments, to find the
+// people to keep informed when work is handed over between teams.
+package stakeholders
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/jira"
+	"github.com/jctanner/rhoai-jira/internal/query"
+	"github.com/jctanner/rhoai-jira/internal/render"
+	"github.com/jctanner/rhoai-jira/internal/tools"
+)
+
+// Involvement is how one person is involved in one issue.
+type Involvement struct {
+	Watching bool
+	Assigned bool
+	// Mentions counts the description and comments mentioning the person.
+	Mentions int
+}
+
+// Person is one stakeholder across the issues of the report.
+type Person struct {
+	ID          string
+	DisplayName string
+	Issues      map[string]*Involvement
+}
+
+func (p *Person) count(f func(*Involvement) bool) int {
+	n := 0
+	for _, in := range p.Issues {
+		if f(in) {
+			n++
+		}
+	}
+	return n
+}
+
+// Watching counts the issues the person watches.
+func (p *Person) Watching() int {
+	return p.count(func(in *Involvement) bool { return in.Watching })
+}
+
+// Mentioned counts the issues the person is mentioned in.
+func (p *Person) Mentioned() int {
+	return p.count(func(in *Involvement) bool { return in.Mentions > 0 })
+}
+
+// Assigned counts the issues assigned to the person.
+func (p *Person) Assigned() int {
+	return p.count(func(in *Involvement) bool { return in.Assigned })
+}
+
+// Mentions counts every mention of the person.
+func (p *Person) Mentions() int {
+	n := 0
+	for _, in := range p.Issues {
+		n += in.Mentions
+	}
+	return n
+}
+
+// Keys lists the issues the person watches or is mentioned in.
+func (p *Person) Keys() []string {
+	var keys []string
+	for key, in := range p.Issues {
+		if in.Watching || in.Mentions > 0 {
+			keys = append(keys, key)
+		}
+	}
+	return tools.SortNumerically(keys)
+}
+
+// Coverage counts the issues whose watchers or comments are not cached.
+type Coverage struct {
+	Issues     int
+	NoWatchers int
+	NoComments int
+}
+
+// Collect gathers the watchers, mentions and assignees of issues by
+// person. Assignees are only recorded alongside watching or mentions, as
+// the people of interest are the others.
+func Collect(store jira.Store, issues []jira.JiraIssueWithSprints) (map[string]*Person, Coverage) {
+	people := map[string]*Person{}
+	involve := func(id, key string) *Involvement {
+		p, ok := people[id]
+		if !ok {
+			p = &Person{ID: id, Issues: map[string]*Involvement{}}
+			people[id] = p
+		}
+		in, ok := p.Issues[key]
+		if !ok {
+			in = &Involvement{}
+			p.Issues[key] = in
+		}
+		return in
+	}
+	names := map[string]string{}
+	name := func(u *jira.User) {
+		if u != nil && u.DisplayName != "" {
+			names[u.ID()] = u.DisplayName
+		}
+	}
+
+	coverage := Coverage{Issues: len(issues)}
+	for _, issue := range issues {
+		name(issue.Fields.Assignee)
+		watchers, err := store.ReadWatchers(issue.Key)
+		if err != nil {
+			if !jira.IsNotCached(err) {
+				cli.Warnf("%s: %v (see cache verify)", issue.Key, err)
+			}
+			if count, ok := issue.WatchCount(); !ok || count > 0 {
+				coverage.NoWatchers++
+			}
+		}
+		for i := range watchers.Watchers {
+			w := &watchers.Watchers[i]
+			name(w)
+			involve(w.ID(), issue.Key).Watching = true
+		}
+		comments, err := store.ReadComments(issue.Key)
+		if err != nil {
+			if !jira.IsNotCached(err) {
+				cli.Warnf("%s: %v (see cache verify)", issue.Key, err)
+			}
+			coverage.NoComments++
+		}
+		for _, c := range comments.Comments {
+			name(c.Author)
+		}
+		for _, m := range issue.Mentions(comments.Comments) {
+			involve(m.User, issue.Key).Mentions++
+		}
+	}
+	for _, issue := range issues {
+		if id := issue.AssigneeID(); id != "" {
+			if p, ok := people[id]; ok {
+				if in, ok := p.Issues[issue.Key]; ok {
+					in.Assigned = true
+				}
+			}
+		}
+	}
+	for id, p := range people {
+		p.DisplayName = names[id]
+	}
+	return people, coverage
+}
+
+// inScope selects the issues of an epic: the epic, its children and their
+// subtasks.
+func inScope(issues []jira.JiraIssueWithSprints, epic string) []jira.JiraIssueWithSprints {
+	selected := map[string]bool{}
+	for _, issue := range issues {
+		if issue.Key == epic || issue.EpicKey() == epic {
+			selected[issue.Key] = true
+		}
+	}
+	var out []jira.JiraIssueWithSprints
+	for _, issue := range issues {
+		if selected[issue.Key] || selected[issue.Fields.Parent.Key] {
+			out = append(out, issue)
+		}
+	}
+	return out
+}
+
+func Main(args []string) {
+	fs := flag.NewFlagSet("stakeholders", flag.ExitOnError)
+	cacheFlags := cli.AddCacheFlags(fs)
+	sprint := fs.String("sprint", "", "Issues in this sprint")
+	epic := fs.String("epic", "", "Issues of this epic, with the epic itself and subtasks")
+	queryStr := fs.String("query", "", "Only issues matching this JQL-lite query")
+	byIssue := fs.Bool("by-issue", false, "One row per issue and person instead of one per person")
+	var renderOpts render.Options
+	render.AddFlags(fs, &renderOpts)
+	fs.Parse(args)
+
+	if (*sprint == "") == (*epic == "") {
+		cli.Fatalf(cli.ExitUsage, "Exactly one of --sprint or --epic must be provided.")
+	}
+	var q *query.Query
+	var err error
+	if *queryStr != "" {
+		if q, err = query.Parse(*queryStr); err != nil {
+			cli.Fatal(cli.WithCode(cli.ExitUsage, err))
+		}
+	}
+
+	store, err := cacheFlags.Open()
+	if err != nil {
+		cli.Fatal(err)
+	}
+	defer store.Close()
+	renderOpts.SetSource(store)
+
+	issues := jira.LoadIssues(store, cacheFlags.Project)
+	var scope string
+	if *sprint != "" {
+		var inSprint []jira.JiraIssueWithSprints
+		for _, issue := range issues {
+			if issue.InSprint(*sprint) {
+				inSprint = append(inSprint, issue)
+			}
+		}
+		issues, scope = inSprint, fmt.Sprintf("sprint %q", *sprint)
+	} else {
+		key := strings.ToUpper(*epic)
+		issues, scope = inScope(issues, key), "epic "+key
+	}
+	if q != nil {
+		issues = q.Filter(issues)
+	}
+	if len(issues) == 0 {
+		cli.Fatalf(cli.ExitNoData, "no cached issues of %s", scope)
+	}
+
+	people, coverage := Collect(store, issues)
+	if coverage.NoWatchers > 0 {
+		cli.Warnf("%d of %d issues may have watchers that are not cached; run fetch --watchers", coverage.NoWatchers, coverage.Issues)
+	}
+	if coverage.NoComments > 0 {
+		cli.Infof("%d of %d issues have no cached comments; only their descriptions and embedded comments were searched for mentions", coverage.NoComments, coverage.Issues)
+	}
+	if len(people) == 0 {
+		cli.Fatalf(cli.ExitNoData, "nobody watches or is mentioned in the %d issues of %s", len(issues), scope)
+	}
+
+	sorted := make([]*Person, 0, len(people))
+	for _, p := range people {
+		sorted = append(sorted, p)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if n, m := len(a.Keys()), len(b.Keys()); n != m {
+			return n > m
+		}
+		return a.ID < b.ID
+	})
+	watchers, mentioned := 0, 0
+	for _, p := range sorted {
+		if p.Watching() > 0 {
+			watchers++
+		}
+		if p.Mentioned() > 0 {
+			mentioned++
+		}
+	}
+	cli.Infof("%d people watch and %d are mentioned across %d issues of %s", watchers, mentioned, len(issues), scope)
+	renderOpts.Title = "Stakeholders: " + scope
+	renderOpts.AddNote("%d people watch and %d are mentioned across %d issues", watchers, mentioned, len(issues))
+
+	var table *render.Table
+	if *byIssue {
+		byKey := map[string]jira.JiraIssueWithSprints{}
+		keys := make([]string, 0, len(issues))
+		for _, issue := range issues {
+			byKey[issue.Key] = issue
+			keys = append(keys, issue.Key)
+		}
+		table = render.NewTable("key", "summary", "person", "display_name", "watching", "mentions", "assigned")
+		for _, key := range tools.SortNumerically(keys) {
+			for _, p := range sorted {
+				in, ok := p.Issues[key]
+				if !ok || (!in.Watching && in.Mentions == 0) {
+					continue
+				}
+				table.Append(key, byKey[key].Fields.Summary, p.ID, p.DisplayName,
+					strconv.FormatBool(in.Watching), strconv.Itoa(in.Mentions), strconv.FormatBool(in.Assigned))
+			}
+		}
+	} else {
+		table = render.NewTable("person", "display_name", "watching", "mentioned", "mentions", "assigned", "issues")
+		for _, p := range sorted {
+			table.Append(p.ID, p.DisplayName, strconv.Itoa(p.Watching()), strconv.Itoa(p.Mentioned()),
+				strconv.Itoa(p.Mentions()), strconv.Itoa(p.Assigned()), strings.Join(p.Keys(), " "))
+		}
+	}
+	if err := renderOpts.Write(table); err != nil {
+		cli.Fatal(err)
+	}
+}
@@ -0,0 +1,238 @@
+// Package stale flags open issues that need attention: no update for too
+// long, too long in one status, or unassigned in an active sprint. With
+// --fail it exits with ExitFindings when there are any, to gate CI.
+package stale
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/jira"
+	"github.com/jctanner/rhoai-jira/internal/render"
+	"github.com/jctanner/rhoai-jira/internal/tools"
+)
+
+// The checks an issue can fail.
+const (
+	CheckNoUpdate   = "no_update"
+	CheckInStatus   = "in_status"
+	CheckUnassigned = "unassigned_in_active_sprint"
+)
+
+// DefaultDays is how long an open issue may go without an update when the
+// config file does not say.
+const DefaultDays = 30
+
+// DefaultStatuses are the days an issue may stay in a status when the
+// config file does not say.
+var DefaultStatuses = map[string]int{"In Progress": 14, "Review": 7}
+
+// Thresholds decide when an issue is stale.
+type Thresholds struct {
+	// Days without an update; zero turns the check off.
+	Days int
+	// Statuses are the days an issue may stay in each status.
+	Statuses map[string]int
+}
+
+// Finding is one check an open issue failed.
+type Finding struct {
+	Key      string `json:"key"`
+	Summary  string `json:"summary"`
+	Status   string `json:"status"`
+	Assignee string `json:"assignee"`
+	Check    string `json:"check"`
+	// Days is how long the issue went without an update or stayed in
+	// its status; Threshold is the limit it passed.
+	Days      int      `json:"days"`
+	Threshold int      `json:"threshold"`
+	Sprints   []string `json:"sprints,omitempty"`
+}
+
+// inStatusSince is when the issue entered its current status, from its
+// changelog, or its creation when it never moved.
+func inStatusSince(issue jira.JiraIssueWithSprints, changelog jira.Changelog, now time.Time) (time.Time, bool) {
+	periods := jira.StatusPeriods(issue, changelog, now)
+	if len(periods) == 0 {
+		return time.Time{}, false
+	}
+	return periods[len(periods)-1].From, true
+}
+
+func days(d time.Duration) int {
+	return int(d.Hours() / 24)
+}
+
+// Check runs the checks on an open issue. changelog may be empty, in
+// which case time in status counts from the last update, the latest the
+// status can have changed.
+func Check(issue jira.JiraIssueWithSprints, changelog jira.Changelog, hasChangelog bool, t Thresholds, now time.Time) []Finding {
+	if issue.IsDone() {
+		return nil
+	}
+	base := Finding{
+		Key:      issue.Key,
+		Summary:  issue.Fields.Summary,
+		Status:   issue.Fields.Status.Name,
+		Assignee: issue.AssigneeID(),
+		Sprints:  issue.ActiveSprints(),
+	}
+	var findings []Finding
+	updated, err := issue.UpdatedTime()
+	if err == nil && t.Days > 0 {
+		if d := days(now.Sub(updated)); d >= t.Days {
+			f := base
+			f.Check, f.Days, f.Threshold = CheckNoUpdate, d, t.Days
+			findings = append(findings, f)
+		}
+	}
+	if limit, ok := t.Statuses[base.Status]; ok {
+		since, found := updated, err == nil
+		if hasChangelog {
+			since, found = inStatusSince(issue, changelog, now)
+		}
+		if d := days(now.Sub(since)); found && d >= limit {
+			f := base
+			f.Check, f.Days, f.Threshold = CheckInStatus, d, limit
+			findings = append(findings, f)
+		}
+	}
+	if base.Assignee == "" && len(base.Sprints) > 0 {
+		f := base
+		f.Check = CheckUnassigned
+		findings = append(findings, f)
+	}
+	return findings
+}
+
+// parseStatusThresholds reads --status values such as "In Progress=14".
+func parseStatusThresholds(values []string) (map[string]int, error) {
+	statuses := map[string]int{}
+	for _, v := range values {
+		name, n, ok := strings.Cut(v, "=")
+		d, err := strconv.Atoi(strings.TrimSpace(n))
+		if !ok || err != nil || d <= 0 || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("invalid --status %q (expected STATUS=DAYS)", v)
+		}
+		statuses[strings.TrimSpace(name)] = d
+	}
+	return statuses, nil
+}
+
+func Main(args []string) {
+	fs := flag.NewFlagSet("stale", flag.ExitOnError)
+	cacheFlags := cli.AddCacheFlags(fs)
+	settings := cli.Settings().Stale
+	defaultDays := settings.Days
+	if defaultDays == 0 {
+		defaultDays = DefaultDays
+	}
+	noUpdate := fs.Int("days", defaultDays, "Flag open issues without an update for this many days (0 to turn off; default from stale.days in the config file)")
+	var statusFlags tools.StringList
+	fs.Var(&statusFlags, "status", "Flag issues in STATUS for more than DAYS, as STATUS=DAYS (repeated; replaces stale.statuses of the config file, default In Progress=14 and Review=7)")
+	var checks tools.StringList
+	fs.Var(&checks, "check", "Only these checks: no_update, in_status, unassigned_in_active_sprint (comma separated or repeated)")
+	fail := fs.Bool("fail", false, "Exit with code 9 when anything is flagged, for CI gating")
+	var renderOpts render.Options
+	render.AddFlags(fs, &renderOpts)
+	fs.Parse(args)
+
+	for _, c := range checks {
+		if c != CheckNoUpdate && c != CheckInStatus && c != CheckUnassigned {
+			cli.Fatalf(cli.ExitUsage, "unknown --check %q", c)
+		}
+	}
+	thresholds := Thresholds{Days: *noUpdate, Statuses: DefaultStatuses}
+	if len(settings.Statuses) > 0 {
+		thresholds.Statuses = settings.Statuses
+	}
+	if len(statusFlags) > 0 {
+		statuses, err := parseStatusThresholds(statusFlags)
+		if err != nil {
+			cli.Fatal(cli.WithCode(cli.ExitUsage, err))
+		}
+		thresholds.Statuses = statuses
+	}
+
+	store, err := cacheFlags.Open()
+	if err != nil {
+		cli.Fatal(err)
+	}
+	defer store.Close()
+	renderOpts.SetSource(store)
+
+	now := time.Now()
+	findings := []Finding{}
+	var coverage jira.ChangelogCoverage
+	for _, issue := range jira.LoadIssues(store, cacheFlags.Project) {
+		if issue.IsDone() {
+			continue
+		}
+		var changelog jira.Changelog
+		hasChangelog := false
+		if _, ok := thresholds.Statuses[issue.Fields.Status.Name]; ok {
+			changelog, err = store.ReadChangelog(issue.Key)
+			coverage.Add(err)
+			hasChangelog = err == nil
+		}
+		for _, f := range Check(issue, changelog, hasChangelog, thresholds, now) {
+			if len(checks) == 0 || tools.ItemInList(checks, f.Check) {
+				findings = append(findings, f)
+			}
+		}
+	}
+	if coverage.Issues > 0 {
+		coverage.Log()
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].Check != findings[j].Check {
+			return findings[i].Check < findings[j].Check
+		}
+		return findings[i].Days > findings[j].Days
+	})
+	cli.Infof("%d findings", len(findings))
+
+	// JSON output lists the findings themselves, with their sprints as
+	// arrays, rather than the table.
+	if renderOpts.OutputFormat() == render.FormatJSON {
+		w, _, err := renderOpts.Create()
+		if err != nil {
+			cli.Fatal(err)
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(findings)
+		if closeErr := w.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			cli.Fatal(err)
+		}
+	} else {
+		table := render.NewTable("key", "summary", "status", "assignee", "check", "days", "threshold", "sprints")
+		for _, f := range findings {
+			table.Append(
+				f.Key,
+				f.Summary,
+				f.Status,
+				f.Assignee,
+				f.Check,
+				strconv.Itoa(f.Days),
+				strconv.Itoa(f.Threshold),
+				strings.Join(f.Sprints, "; "),
+			)
+		}
+		if err := renderOpts.Write(table); err != nil {
+			cli.Fatal(err)
+		}
+	}
+	if *fail && len(findings) > 0 {
+		cli.Fatalf(cli.ExitFindings, "%d stale issues", len(findings))
+	}
+}
@@ -0,0 +1,131 @@
+package stats
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/jira"
+	"github.com/jctanner/rhoai-jira/internal/render"
+	"github.com/jctanner/rhoai-jira/internal/tools"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: stats fields [flags]\n")
+}
+
+func Main(args []string) {
+	if len(args) < 1 {
+		usage()
+		os.Exit(cli.ExitUsage)
+	}
+	switch args[0] {
+	case "fields":
+		fieldStats(args[1:])
+	default:
+		usage()
+		os.Exit(cli.ExitUsage)
+	}
+}
+
+func formatNumber(n float64) string {
+	return strconv.FormatFloat(n, 'f', -1, 64)
+}
+
+func fieldStats(args []string) {
+	fs := flag.NewFlagSet("stats fields", flag.ExitOnError)
+	cacheFlags := cli.AddCacheFlags(fs)
+	fieldsConfig := fs.String("fields-config", cli.FieldsConfig(), "JSON file mapping custom fields to named extractors")
+	var fields tools.StringList
+	fs.Var(&fields, "field", "Field to summarise: an extractor name or a raw field id such as duedate (repeatable; default every field in the cache)")
+	sample := fs.Int("sample", 0, "Summarise a random sample of this many issues (0 for all)")
+	seed := fs.Int64("seed", 1, "Random seed for --sample")
+	top := fs.Int("top", 5, "Number of top values listed per field")
+	values := fs.Bool("values", false, "Output the full value distribution instead of one summary row per field")
+	var renderOpts render.Options
+	render.AddFlags(fs, &renderOpts)
+	fs.Parse(args)
+
+	// The store is opened first so its field map applies to the extractors.
+	store, err := cacheFlags.Open()
+	if err != nil {
+		cli.Fatal(err)
+	}
+	defer store.Close()
+	renderOpts.SetSource(store)
+	extractors, err := jira.LoadExtractors(*fieldsConfig)
+	if err != nil {
+		cli.Fatal(err)
+	}
+
+	issues := jira.LoadIssues(store, cacheFlags.Project)
+	if len(issues) == 0 {
+		cli.Fatalf(cli.ExitNoData, "no cached issues")
+	}
+	if *sample > 0 && *sample < len(issues) {
+		r := rand.New(rand.NewSource(*seed))
+		r.Shuffle(len(issues), func(i, j int) { issues[i], issues[j] = issues[j], issues[i] })
+		issues = issues[:*sample]
+		cli.Infof("sampled %d issues", len(issues))
+	}
+
+	var selected []jira.FieldExtractor
+	if len(fields) == 0 {
+		fields = jira.RawFieldNames(issues)
+	}
+	for _, name := range fields {
+		fe, ok := extractors.Lookup(name)
+		if !ok {
+			// Any raw field can be summarised by its id.
+			fe = jira.FieldExtractor{Name: name, Field: name, Type: jira.ExtractOption}
+		}
+		selected = append(selected, fe)
+	}
+
+	var table *render.Table
+	if *values {
+		// Values are whatever the field holds, versions included, so only
+		// the percentage is a number.
+		table = render.NewTable("field", "value", "issues", "pct")
+		table.SetNumeric("pct")
+		for _, fe := range selected {
+			s := jira.ComputeFieldStats(fe, issues)
+			for _, v := range s.Distribution {
+				table.Append(s.Name, v.Value, strconv.Itoa(v.Count), fmt.Sprintf("%.1f", 100*float64(v.Count)/float64(s.Issues)))
+			}
+		}
+	} else {
+		table = render.NewTable("field", "id", "issues", "present", "null_pct", "cardinality", "values_per_issue", "min", "median", "mean", "max", "top_values")
+		table.SetNumeric("null_pct", "values_per_issue", "min", "median", "mean", "max")
+		for _, fe := range selected {
+			s := jira.ComputeFieldStats(fe, issues)
+			perIssue := 0.0
+			if s.Present > 0 {
+				perIssue = float64(s.Values) / float64(s.Present)
+			}
+			min, median, mean, max := "", "", "", ""
+			if s.Numeric {
+				min, median, max = formatNumber(s.Min), formatNumber(s.Median), formatNumber(s.Max)
+				mean = fmt.Sprintf("%.2f", s.Mean)
+			}
+			var topValues []string
+			for i, v := range s.Distribution {
+				if i == *top {
+					break
+				}
+				topValues = append(topValues, fmt.Sprintf("%s (%d)", v.Value, v.Count))
+			}
+			table.Append(s.Name, s.Field, strconv.Itoa(s.Issues), strconv.Itoa(s.Present),
+				fmt.Sprintf("%.1f", 100*s.NullRate()), strconv.Itoa(s.Cardinality()),
+				fmt.Sprintf("%.2f", perIssue), min, median, mean, max, strings.Join(topValues, "; "))
+		}
+	}
+
+	if err := renderOpts.Write(table); err != nil {
+		cli.Fatal(err)
+	}
+}
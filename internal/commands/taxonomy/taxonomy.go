@@ -0,0 +1,111 @@
+package taxonomy
+
+import (
+	"context"
+	"flag"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/jira"
+	"github.com/jctanner/rhoai-jira/internal/render"
+)
+
+func Main(args []string) {
+	fs := flag.NewFlagSet("taxonomy", flag.ExitOnError)
+	cacheFlags := cli.AddCacheFlags(fs)
+	maxDistance := fs.Int("max-distance", 1, "Largest edit distance between names reported as typo variants")
+	minLength := fs.Int("min-length", 5, "Shortest name compared for typos (casing and separator variants are always compared)")
+	staleDays := fs.Int("stale-days", 180, "Report components whose issues were all last updated this many days ago (0 to disable)")
+	kinds := fs.String("kinds", "", "Comma separated finding kinds to report (default: all)")
+	baseURL := fs.String("base-url", "", "Jira to list defined components from, to report components no issue uses")
+	auth := cli.AddAuthFlags(fs)
+	var renderOpts render.Options
+	render.AddFlags(fs, &renderOpts)
+	fs.Parse(args)
+
+	store, err := cacheFlags.Open()
+	if err != nil {
+		cli.Fatal(err)
+	}
+	defer store.Close()
+	renderOpts.SetSource(store)
+	issues := jira.LoadIssues(store, cacheFlags.Project)
+	if len(issues) == 0 {
+		cli.Fatal(cli.ErrNoData)
+	}
+
+	opts := jira.TaxonomyOptions{
+		MaxDistance: *maxDistance,
+		MinLength:   *minLength,
+		StaleAfter:  time.Duration(*staleDays) * 24 * time.Hour,
+		Now:         time.Now(),
+	}
+	if *baseURL != "" {
+		authenticator, err := auth.Authenticator()
+		if err != nil {
+			cli.Fatal(err)
+		}
+		client := jira.NewClient(*baseURL, "")
+		client.Auth = authenticator
+		opts.Defined = map[string][]string{}
+		for _, project := range projectKeys(issues, cacheFlags.Project) {
+			names, err := client.ProjectComponents(context.Background(), project)
+			if err != nil {
+				cli.Fatal(err)
+			}
+			opts.Defined[project] = names
+		}
+	}
+
+	wanted := map[string]bool{}
+	for _, k := range strings.Split(*kinds, ",") {
+		if k = strings.TrimSpace(k); k != "" {
+			wanted[k] = true
+		}
+	}
+
+	table := render.NewTable("kind", "project", "name", "issues", "suggestion", "suggestion_issues", "reason")
+	counts := map[string]int{}
+	for _, f := range jira.AuditTaxonomy(issues, opts) {
+		if len(wanted) > 0 && !wanted[f.Kind] {
+			continue
+		}
+		counts[f.Kind]++
+		suggestionIssues := ""
+		if f.Suggestion != "" {
+			suggestionIssues = strconv.Itoa(f.SuggestionIssues)
+		}
+		table.Append(f.Kind, f.Project, f.Name, strconv.Itoa(f.Issues), f.Suggestion, suggestionIssues, f.Reason)
+	}
+	var summary []string
+	for kind, n := range counts {
+		summary = append(summary, kind+"="+strconv.Itoa(n))
+	}
+	sort.Strings(summary)
+	cli.Infof("taxonomy audit of %d issues: %s", len(issues), strings.Join(summary, " "))
+
+	if err := renderOpts.Write(table); err != nil {
+		cli.Fatal(err)
+	}
+}
+
+// projectKeys lists the projects to audit: the --project filter, or every
+// project in the cache.
+func projectKeys(issues []jira.JiraIssueWithSprints, filter string) []string {
+	if filter != "" {
+		return []string{strings.ToUpper(filter)}
+	}
+	seen := map[string]bool{}
+	var keys []string
+	for _, issue := range issues {
+		if p := issue.Fields.Project.Key; p != "" && !seen[p] {
+			seen[p] = true
+			keys = append(keys, p)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
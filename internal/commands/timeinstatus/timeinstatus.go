@@ -0,0 +1,245 @@
+// Package timeinstatus reports how long issues spent in each status, from
+// the status transitions in their changelogs, in calendar hours or in
+// business hours that leave out nights, weekends and holidays.
+package timeinstatus
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/config"
+	"github.com/jctanner/rhoai-jira/internal/jira"
+	"github.com/jctanner/rhoai-jira/internal/query"
+	"github.com/jctanner/rhoai-jira/internal/render"
+)
+
+// Workday is the part of a weekday counted as business hours, in the
+// local time zone.
+type Workday struct {
+	Start    time.Duration
+	End      time.Duration
+	Holidays map[string]bool
+}
+
+// ParseWorkday parses a span of the day such as "09:00-17:00".
+func ParseWorkday(spec string) (Workday, error) {
+	from, to, ok := strings.Cut(spec, "-")
+	if !ok {
+		return Workday{}, fmt.Errorf("invalid workday %q (expected HH:MM-HH:MM)", spec)
+	}
+	clock := func(s string) (time.Duration, error) {
+		t, err := time.Parse("15:04", strings.TrimSpace(s))
+		if err != nil {
+			return 0, fmt.Errorf("invalid workday %q (expected HH:MM-HH:MM)", spec)
+		}
+		return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+	}
+	start, err := clock(from)
+	if err != nil {
+		return Workday{}, err
+	}
+	end, err := clock(to)
+	if err != nil {
+		return Workday{}, err
+	}
+	if end <= start {
+		return Workday{}, fmt.Errorf("invalid workday %q: it must end after it starts", spec)
+	}
+	return Workday{Start: start, End: end}, nil
+}
+
+// Between is the business time from from to to.
+func (w Workday) Between(from, to time.Time) time.Duration {
+	from, to = from.Local(), to.Local()
+	var total time.Duration
+	day := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.Local)
+	for ; day.Before(to); day = day.AddDate(0, 0, 1) {
+		if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday || w.Holidays[day.Format("2006-01-02")] {
+			continue
+		}
+		start, end := day.Add(w.Start), day.Add(w.End)
+		if from.After(start) {
+			start = from
+		}
+		if to.Before(end) {
+			end = to
+		}
+		if end.After(start) {
+			total += end.Sub(start)
+		}
+	}
+	return total
+}
+
+// Entry is the time one issue spent in one status.
+type Entry struct {
+	Key    string
+	Status string
+	// Visits is how many times the issue entered the status.
+	Visits int
+	Time   time.Duration
+}
+
+// TimeInStatus totals the status periods of an issue per status, in the
+// order the statuses were first entered. A nil measure counts calendar
+// time.
+func TimeInStatus(key string, periods []jira.StatusPeriod, measure func(from, to time.Time) time.Duration) []Entry {
+	var entries []Entry
+	index := map[string]int{}
+	for _, p := range periods {
+		d := p.To.Sub(p.From)
+		if measure != nil {
+			d = measure(p.From, p.To)
+		}
+		i, ok := index[p.Status]
+		if !ok {
+			i = len(entries)
+			index[p.Status] = i
+			entries = append(entries, Entry{Key: key, Status: p.Status})
+		}
+		entries[i].Visits++
+		entries[i].Time += d
+	}
+	return entries
+}
+
+func hours(d time.Duration) string {
+	return fmt.Sprintf("%.1f", d.Hours())
+}
+
+// quantile is the q quantile of sorted durations, by nearest rank.
+func quantile(sorted []time.Duration, q float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(math.Ceil(q*float64(len(sorted)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	return sorted[i]
+}
+
+// summaryTable aggregates the entries per status for SLO reviews.
+func summaryTable(entries []Entry, unit string) *render.Table {
+	byStatus := map[string][]time.Duration{}
+	var order []string
+	for _, e := range entries {
+		if _, ok := byStatus[e.Status]; !ok {
+			order = append(order, e.Status)
+		}
+		byStatus[e.Status] = append(byStatus[e.Status], e.Time)
+	}
+	sort.Strings(order)
+	table := render.NewTable("status", "issues", "total_"+unit, "mean_"+unit, "median_"+unit, "p85_"+unit, "max_"+unit)
+	table.SetNumeric("total_"+unit, "mean_"+unit, "median_"+unit, "p85_"+unit, "max_"+unit)
+	for _, status := range order {
+		times := byStatus[status]
+		sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+		var total time.Duration
+		for _, d := range times {
+			total += d
+		}
+		table.Append(
+			status,
+			fmt.Sprintf("%d", len(times)),
+			hours(total),
+			hours(total/time.Duration(len(times))),
+			hours(quantile(times, 0.5)),
+			hours(quantile(times, 0.85)),
+			hours(times[len(times)-1]),
+		)
+	}
+	return table
+}
+
+func holidaySet(holidays []config.Holiday) map[string]bool {
+	set := map[string]bool{}
+	for _, h := range holidays {
+		set[h.Date.Format("2006-01-02")] = true
+	}
+	return set
+}
+
+func Main(args []string) {
+	fs := flag.NewFlagSet("time-in-status", flag.ExitOnError)
+	cacheFlags := cli.AddCacheFlags(fs)
+	sprint := fs.String("sprint", "", "Only issues in this sprint")
+	queryStr := fs.String("query", "", "Only issues matching this JQL-lite query")
+	business := fs.Bool("business", false, "Count business hours only: the workday on weekdays that are not holidays (from the config file)")
+	workdayStr := fs.String("workday", "09:00-17:00", "Business hours of a day in the local time zone, with --business")
+	summary := fs.Bool("summary", false, "One row per status with the total, mean, median, 85th percentile and longest time instead of one per issue")
+	var renderOpts render.Options
+	render.AddFlags(fs, &renderOpts)
+	fs.Parse(args)
+
+	var q *query.Query
+	if *queryStr != "" {
+		var err error
+		if q, err = query.Parse(*queryStr); err != nil {
+			cli.Fatal(cli.WithCode(cli.ExitUsage, err))
+		}
+	}
+	var measure func(from, to time.Time) time.Duration
+	unit := "hours"
+	if *business {
+		workday, err := ParseWorkday(*workdayStr)
+		if err != nil {
+			cli.Fatal(cli.WithCode(cli.ExitUsage, err))
+		}
+		workday.Holidays = holidaySet(cli.Holidays())
+		measure = workday.Between
+		unit = "business_hours"
+	}
+
+	store, err := cacheFlags.Open()
+	if err != nil {
+		cli.Fatal(err)
+	}
+	defer store.Close()
+	renderOpts.SetSource(store)
+
+	issues := jira.LoadIssues(store, cacheFlags.Project)
+	if q != nil {
+		issues = q.Filter(issues)
+	}
+	now := time.Now()
+	var entries []Entry
+	var coverage jira.ChangelogCoverage
+	for _, issue := range issues {
+		if *sprint != "" && !issue.InSprint(*sprint) {
+			continue
+		}
+		changelog, err := store.ReadChangelog(issue.Key)
+		coverage.Add(err)
+		if err != nil {
+			// Without the changelog the whole life of the issue would
+			// count against its current status.
+			continue
+		}
+		entries = append(entries, TimeInStatus(issue.Key, jira.StatusPeriods(issue, changelog, now), measure)...)
+	}
+	coverage.Log()
+	if len(entries) == 0 {
+		cli.Fatalf(cli.ExitNoData, "no cached issues with a changelog match")
+	}
+	cli.Infof("time in status of %d issues in %s", coverage.Covered, strings.ReplaceAll(unit, "_", " "))
+
+	var table *render.Table
+	if *summary {
+		table = summaryTable(entries, unit)
+	} else {
+		table = render.NewTable("key", "status", "visits", unit)
+		table.SetNumeric(unit)
+		for _, e := range entries {
+			table.Append(e.Key, e.Status, fmt.Sprintf("%d", e.Visits), hours(e.Time))
+		}
+	}
+	if err := renderOpts.Write(table); err != nil {
+		cli.Fatal(err)
+	}
+}
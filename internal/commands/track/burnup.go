@@ -0,0 +1,127 @@
+package track
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jctanner/rhoai-jira/internal/jira"
+	"github.com/jctanner/rhoai-jira/internal/render"
+)
+
+// burnupRow is the scope and completed work of one sprint at the end of one
+// interval, with the issues added to the sprint after it started.
+type burnupRow struct {
+	Timestamp       string
+	Sprint          string
+	ScopeIssues     int
+	Scope           float64
+	CompletedIssues int
+	Completed       float64
+	Added           []string
+}
+
+// burnup replays the sprint windows interval by interval. An issue is in
+// scope for every interval it overlaps and completed once it reached a done
+// status before the end of the interval. Adds after the start of a sprint
+// are scope changes; sprints without a known start date start at their
+// first add.
+func burnup(sprintWindows map[SprintKey][]WindowSpan, sprintMeta map[SprintKey]SprintMeta, doneAt map[string]time.Time, starts map[string]time.Time, interval time.Duration, now time.Time) []burnupRow {
+	type key struct {
+		Timestamp string
+		Sprint    string
+	}
+	format := timeFormatFor(interval)
+
+	first := map[string]time.Time{}
+	for k, windows := range sprintWindows {
+		for _, w := range windows {
+			if f, ok := first[k.Sprint]; !ok || w.FromTime.Before(f) {
+				first[k.Sprint] = w.FromTime
+			}
+		}
+	}
+	startOf := func(sprint string) time.Time {
+		if start, ok := starts[sprint]; ok {
+			return start
+		}
+		return first[sprint]
+	}
+
+	rows := map[key]*burnupRow{}
+	row := func(t time.Time, sprint string) *burnupRow {
+		k := key{Timestamp: t.Format(format), Sprint: sprint}
+		if rows[k] == nil {
+			rows[k] = &burnupRow{Timestamp: k.Timestamp, Sprint: sprint}
+		}
+		return rows[k]
+	}
+
+	for k, windows := range sprintWindows {
+		meta := sprintMeta[k]
+		done, isDone := doneAt[k.IssueKey]
+		counted := map[string]bool{}
+		for _, w := range windows {
+			if start := startOf(k.Sprint); w.FromTime.After(start) {
+				r := row(w.FromTime.Truncate(interval), k.Sprint)
+				r.Added = append(r.Added, k.IssueKey)
+			}
+			end := now
+			if w.ToTime != nil {
+				end = *w.ToTime
+			}
+			for t := w.FromTime.Truncate(interval); !t.After(end); t = t.Add(interval) {
+				r := row(t, k.Sprint)
+				if counted[r.Timestamp] {
+					continue
+				}
+				counted[r.Timestamp] = true
+				r.ScopeIssues++
+				r.Scope += meta.Points
+				if isDone && done.Before(t.Add(interval)) {
+					r.CompletedIssues++
+					r.Completed += meta.Points
+				}
+			}
+		}
+	}
+
+	result := make([]burnupRow, 0, len(rows))
+	for _, r := range rows {
+		sort.Strings(r.Added)
+		result = append(result, *r)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Timestamp == result[j].Timestamp {
+			return result[i].Sprint < result[j].Sprint
+		}
+		return result[i].Timestamp < result[j].Timestamp
+	})
+	return result
+}
+
+// burnupTable lays the burnup out as CSV: the scope line, the completed
+// line and the scope changes of each interval.
+func burnupTable(rows []burnupRow, effort jira.EffortSource) *render.Table {
+	column := effort.ColumnName()
+	table := render.NewTable("timestamp", "sprint", "scope_issues", "scope_"+column, "completed_issues", "completed_"+column, "scope_change", "scope_added")
+	table.SetNumeric("scope_"+column, "completed_"+column)
+	for _, r := range rows {
+		change := ""
+		if len(r.Added) > 0 {
+			change = "yes"
+		}
+		table.Append(
+			r.Timestamp,
+			r.Sprint,
+			fmt.Sprintf("%d", r.ScopeIssues),
+			fmt.Sprintf("%.1f", r.Scope),
+			fmt.Sprintf("%d", r.CompletedIssues),
+			fmt.Sprintf("%.1f", r.Completed),
+			change,
+			strings.Join(r.Added, " "),
+		)
+	}
+	return table
+}
@@ -0,0 +1,41 @@
+package track
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/jctanner/rhoai-jira/internal/render"
+)
+
+// chartColors are assigned to the series of a chart in order.
+var chartColors = []string{"#1f77b4", "#ff7f0e", "#2ca02c", "#d62728", "#9467bd", "#8c564b", "#e377c2", "#7f7f7f", "#bcbd22", "#17becf"}
+
+// sprintCharts plots the named columns of a tracker or burnup table over
+// its timestamps, one chart per sprint. They are built from the table so
+// that results served from the result cache get them too.
+func sprintCharts(t *render.Table, title string, columns ...string) []*render.LineChart {
+	index := map[string]int{}
+	for i, h := range t.Headers {
+		index[h] = i
+	}
+	var charts []*render.LineChart
+	bySprint := map[string]*render.LineChart{}
+	for _, row := range t.Rows {
+		sprint := row[index["sprint"]]
+		chart := bySprint[sprint]
+		if chart == nil {
+			chart = &render.LineChart{Title: fmt.Sprintf("%s: %s", title, sprint)}
+			for i, c := range columns {
+				chart.Series = append(chart.Series, render.Series{Name: c, Color: chartColors[i%len(chartColors)]})
+			}
+			bySprint[sprint] = chart
+			charts = append(charts, chart)
+		}
+		chart.Labels = append(chart.Labels, row[index["timestamp"]])
+		for i, c := range columns {
+			v, _ := strconv.ParseFloat(row[index[c]], 64)
+			chart.Series[i].Values = append(chart.Series[i].Values, v)
+		}
+	}
+	return charts
+}
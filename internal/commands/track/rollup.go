@@ -0,0 +1,119 @@
+package track
+
+import (
+	"time"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/jira"
+	"github.com/jctanner/rhoai-jira/internal/tools"
+)
+
+// doneTime finds when an issue reached a done status for the last time,
+// from its changelog, or its resolution date when the changelog records no
+// status change. An issue reopened and closed again is done from the last
+// time it was closed.
+func doneTime(issue jira.JiraIssueWithSprints, changelog jira.Changelog) (time.Time, bool) {
+	var done time.Time
+	sawStatus := false
+	for _, h := range changelog.Histories {
+		t, err := time.Parse("2006-01-02T15:04:05.000-0700", h.Created)
+		if err != nil {
+			continue
+		}
+		for _, item := range h.Items {
+			if item.Field != "status" || item.ToString == "" {
+				continue
+			}
+			sawStatus = true
+			if !(jira.Status{Name: item.ToString}).IsDone() {
+				done = time.Time{}
+			} else if done.IsZero() {
+				done = t
+			}
+		}
+	}
+	if !sawStatus && issue.IsDone() {
+		if resolved, err := issue.ResolvedTime(); err == nil {
+			return resolved, true
+		}
+	}
+	return done, !done.IsZero()
+}
+
+// rollupSubtasks folds the subtasks of each story into the story for every
+// sprint the story was in: their windows there are dropped, the story's
+// points become theirs and its status is rolled up from theirs as they
+// were when the story entered the sprint, or when they were created if
+// that was later. A story is done once all its subtasks are. It returns
+// the number of subtasks folded.
+func rollupSubtasks(dir string, effort jira.EffortSource, subtasks map[string]jira.JiraIssueWithSprints, sprintWindows map[SprintKey][]WindowSpan, sprintMeta map[SprintKey]SprintMeta, doneAt map[string]time.Time) int {
+	children := map[string][]string{}
+	for key, issue := range subtasks {
+		children[issue.Fields.Parent.Key] = append(children[issue.Fields.Parent.Key], key)
+	}
+	var stories []SprintKey
+	for k := range sprintWindows {
+		if len(children[k.IssueKey]) > 0 {
+			stories = append(stories, k)
+		}
+	}
+
+	changelogs := map[string]jira.Changelog{}
+	changelog := func(key string) jira.Changelog {
+		c, ok := changelogs[key]
+		if !ok {
+			var err error
+			if c, err = jira.GetIssueChangelogFromCache(dir, key); err != nil && !jira.IsMissingChangelog(err) {
+				cli.Warnf("%s: %v (see cache verify)", key, err)
+			}
+			changelogs[key] = c
+		}
+		return c
+	}
+
+	folded := map[string]bool{}
+	for _, k := range stories {
+		entered := sprintWindows[k][0].FromTime
+		var efforts []float64
+		var statuses []jira.Status
+		done, allDone := time.Time{}, true
+		for _, key := range tools.SortNumerically(children[k.IssueKey]) {
+			sub := subtasks[key]
+			delete(sprintWindows, SprintKey{IssueKey: key, Sprint: k.Sprint})
+			delete(sprintMeta, SprintKey{IssueKey: key, Sprint: k.Sprint})
+			folded[key] = true
+
+			at := entered
+			if created, err := sub.CreatedTime(); err == nil && created.After(at) {
+				at = created
+			}
+			c := changelog(key)
+			efforts = append(efforts, effort.EffortAt(sub, c, at))
+			status := sub.Fields.Status.Name
+			if value, ok := jira.ValueAt(c, "status", at); ok {
+				status = value
+			}
+			statuses = append(statuses, jira.Status{Name: status})
+			if t, ok := doneTime(sub, c); ok {
+				if t.After(done) {
+					done = t
+				}
+			} else {
+				allDone = false
+			}
+		}
+
+		meta := sprintMeta[k]
+		if effort != jira.EffortCount {
+			meta.Points = jira.RollupEffort(meta.Points, efforts)
+		}
+		meta.Status = jira.RollupStatus(jira.Status{Name: meta.Status}, statuses).Name
+		sprintMeta[k] = meta
+		if allDone {
+			doneAt[k.IssueKey] = done
+		} else {
+			delete(doneAt, k.IssueKey)
+		}
+	}
+	return len(folded)
+}
@@ -0,0 +1,630 @@
+package track
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/jira"
+	"github.com/jctanner/rhoai-jira/internal/render"
+	"github.com/jctanner/rhoai-jira/internal/tools"
+)
+
+type SprintKey struct {
+	IssueKey string
+	Sprint   string
+}
+
+type WindowSpan struct {
+	FromTime time.Time
+	ToTime   *time.Time
+}
+
+type SprintMeta struct {
+	Points float64
+	Status string
+}
+
+type SprintEvent struct {
+	Time        time.Time
+	IssueKey    string
+	Sprint      string
+	IssueCount  int
+	PointsCount int
+}
+
+func parseInterval(interval string) (time.Duration, error) {
+	switch interval {
+	case "daily":
+		return 24 * time.Hour, nil
+	case "hourly":
+		return time.Hour, nil
+	case "minutely":
+		return time.Minute, nil
+	default:
+		return 0, fmt.Errorf("invalid interval: %s", interval)
+	}
+}
+
+func timeFormatFor(d time.Duration) string {
+	switch d {
+	case 24 * time.Hour:
+		return "2006-01-02"
+	case time.Hour:
+		return "2006-01-02 15:00"
+	case time.Minute:
+		return "2006-01-02 15:04"
+	default:
+		return time.RFC3339
+	}
+}
+
+func includes(list []string, target string) bool {
+	for _, item := range list {
+		if jira.SameSprint(strings.TrimSpace(item), target) {
+			return true
+		}
+	}
+	return false
+}
+
+// canonicalSprints splits a changelog sprint list into the canonical names
+// of its sprints, so renamed or team-prefixed sprints are tracked as one.
+func canonicalSprints(value string) []string {
+	names := strings.Split(value, ",")
+	for i, name := range names {
+		if name = strings.TrimSpace(name); name != "" {
+			names[i] = jira.CanonicalSprint(name)
+		}
+	}
+	return names
+}
+
+func hasSprintEvents(changelog jira.Changelog) bool {
+	for _, h := range changelog.Histories {
+		for _, item := range h.Items {
+			if item.Field == "Sprint" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sprintChangelog returns the changelog that describes an issue's sprint
+// membership: its own, its parent's for sub-tasks that never changed sprint
+// themselves, or one synthesized from the current sprint field, which is
+// also used when the changelog was never saved.
+func sprintChangelog(dir string, issue jira.JiraIssueWithSprints, coverage *jira.ChangelogCoverage) (jira.Changelog, error) {
+	changelog, err := jira.GetIssueChangelogFromCache(dir, issue.Key)
+	coverage.Add(err)
+	if err != nil && !jira.IsMissingChangelog(err) {
+		return changelog, err
+	}
+	if hasSprintEvents(changelog) {
+		return changelog, nil
+	}
+
+	if issue.Fields.Parent.Key != "" {
+		parentChangelog, err := jira.GetIssueChangelogFromCache(dir, issue.Fields.Parent.Key)
+		if err != nil && !jira.IsMissingChangelog(err) {
+			return changelog, err
+		}
+		if hasSprintEvents(parentChangelog) {
+			return parentChangelog, nil
+		}
+	}
+
+	if len(issue.Fields.Sprints) > 0 {
+		tmpChangelog, err := jira.ToChangelog(issue)
+		if err != nil {
+			fmt.Printf("ERROR: %s\n", err)
+		} else {
+			changelog = *tmpChangelog
+		}
+	}
+	return changelog, nil
+}
+
+func getIssueSprintChangelog(dir string, issueKey string, coverage *jira.ChangelogCoverage) (jira.Changelog, error) {
+	issue, err := jira.GetIssueFromCache(dir, issueKey)
+	if err != nil {
+		return jira.Changelog{}, err
+	}
+	return sprintChangelog(dir, issue, coverage)
+}
+
+func getIssueKeys(dir string, project string) []string {
+	if project != "" {
+		return jira.GetAllProjectIssueKeys(dir, project)
+	}
+	return jira.GetAllCachedIssueKeys(dir)
+}
+
+func process2(dir string, project string, out string, sprintFilter string, intervalStr string, debugLog bool) {
+	issueKeys := getIssueKeys(dir, project)
+	issueKeys = tools.SortNumerically(issueKeys)
+
+	//var sprintNames []string
+	var events []SprintEvent
+	var coverage jira.ChangelogCoverage
+
+	for _, issueKey := range issueKeys {
+		//fmt.Println(issueKey)
+
+		changelog, err := getIssueSprintChangelog(dir, issueKey, &coverage)
+		if err != nil {
+			continue
+		}
+
+		//activeSprint := ""
+		activeSprints := []string{}
+
+		for _, h := range changelog.Histories {
+			eventTime, err := time.Parse("2006-01-02T15:04:05.000-0700", h.Created)
+			if err != nil {
+				continue
+			}
+			for _, item := range h.Items {
+				switch item.Field {
+				case "Sprint":
+					//originSprints := strings.Split(item.FromString, ",")
+					//newSprints := strings.Split(item.ToString, ",")
+					//fmt.Printf("%s\n", item)
+
+					originSprints := canonicalSprints(item.FromString)
+					newSprints := canonicalSprints(item.ToString)
+
+					for _, sprintName := range originSprints {
+						if sprintName == "" {
+							continue
+						}
+						//events = append(events, []string{h.Created, sprintName, "1"})
+						if !tools.ItemInList(newSprints, sprintName) {
+							//events = append(events, []string{h.Created, sprintName, "-1"})
+							events = append(events, SprintEvent{
+								Time:       eventTime,
+								IssueKey:   issueKey,
+								Sprint:     sprintName,
+								IssueCount: 1,
+							})
+						}
+					}
+					for _, sprintName := range newSprints {
+						if sprintName == "" {
+							continue
+						}
+						if !tools.ItemInList(activeSprints, sprintName) {
+							activeSprints = append(activeSprints, sprintName)
+						}
+						if !tools.ItemInList(originSprints, sprintName) {
+							//events = append(events, []string{h.Created, sprintName, "1"})
+							events = append(events, SprintEvent{
+								Time:       eventTime,
+								IssueKey:   issueKey,
+								Sprint:     sprintName,
+								IssueCount: -1,
+							})
+						}
+					}
+
+					/*
+						case "Story Points":
+							if item.ToString != "" {
+								if pts, err := strconv.ParseFloat(item.ToString, 64); err == nil {
+									//storyPoints[issue.Key] = pts
+									//fmt.Printf("%s %d\n", item, pts)
+
+									delta = pts - currentSprintPoints
+									currentSprintPoints = pts
+
+									events = append(events, SprintEvent{
+										Time:        eventTime,
+										IssueKey:    issueKey,
+										Sprint:      sprintName,
+										PointsCount: delta,
+									})
+								}
+
+							}
+					*/
+
+					/*
+						case "status":
+							if item.ToString != "" {
+								//statuses[issue.Key] = item.ToString
+								fmt.Printf("%s\n", item)
+							}
+					*/
+				}
+			}
+		}
+
+	}
+
+	//sprintNames = tools.SortNumerically(sprintNames)
+
+	/*
+		if sprintFilter != "" {
+			events = tools.FilterByIndexValue(events, 1, sprintFilter)
+		}
+	*/
+
+	coverage.Log()
+	sort.SliceStable(events, func(i, j int) bool {
+		if !events[i].Time.Equal(events[j].Time) {
+			return events[i].Time.Before(events[j].Time)
+		}
+		return events[i].IssueKey < events[j].IssueKey
+	})
+
+	for _, event := range events {
+		fmt.Printf("%s %s %s %d %d\n", event.Time, event.Sprint, event.IssueKey, event.IssueCount, event.PointsCount)
+	}
+}
+
+// process writes the sprint tracker table. Issues are counted per status,
+// or per column of the board when one is given. With burnupMode it writes
+// the burnup of each sprint instead. With rollup, subtasks are counted in
+// their story rather than on their own.
+func process(dir string, project string, renderOpts render.Options, sprintFilter string, intervalStr string, effort jira.EffortSource, board *jira.BoardConfig, burnupMode bool, rollup bool, debugLog bool) {
+
+	intervalDur, err := parseInterval(intervalStr)
+	if err != nil {
+		cli.Fatalf(cli.ExitUsage, "invalid interval: %v", err)
+	}
+
+	cacheName := "sprint_tracker"
+	if burnupMode {
+		cacheName = "sprint_burnup"
+	}
+	if rollup {
+		cacheName += "_rollup"
+	}
+	version, _ := jira.CacheVersion(dir)
+	renderOpts.SetCacheVersion(version)
+	// Sprints are grouped by canonical name, so a result computed under
+	// other naming rules cannot be reused.
+	resultVersion := version
+	if version != "" {
+		resultVersion += jira.SprintRulesKey()
+	}
+	if sprintFilter != "" {
+		sprintFilter = jira.CanonicalSprint(sprintFilter)
+	}
+	write := func(table *render.Table) {
+		if renderOpts.HTML() {
+			renderOpts.Title = "Sprint tracker"
+			charts := sprintCharts(table, "Issues per status", table.Headers[4:]...)
+			if burnupMode {
+				renderOpts.Title = "Sprint burnup"
+				column := effort.ColumnName()
+				charts = sprintCharts(table, "Burnup", "scope_"+column, "completed_"+column)
+			}
+			if sprintFilter != "" {
+				renderOpts.Title += ": " + sprintFilter
+			}
+			for _, c := range charts {
+				renderOpts.AddChart(c)
+			}
+		}
+		if err := renderOpts.Write(table); err != nil {
+			cli.Fatal(err)
+		}
+	}
+	if table, ok := renderOpts.LoadCached(cacheName, resultVersion); ok {
+		write(table)
+		return
+	}
+
+	sprintWindows := make(map[SprintKey][]WindowSpan)
+	sprintMeta := make(map[SprintKey]SprintMeta)
+	storyPoints := make(map[string]float64)
+	statuses := make(map[string]string)
+	doneAt := make(map[string]time.Time)
+	sprintStarts := make(map[string]time.Time)
+	subtasks := make(map[string]jira.JiraIssueWithSprints)
+	var coverage jira.ChangelogCoverage
+
+	// The index tells which issues belong to other projects without
+	// reading them.
+	var index *jira.CacheIndex
+	if project != "" {
+		if index, err = jira.UpdateCacheIndex(dir, false); err != nil {
+			cli.Warnf("cache index: %v", err)
+		}
+	}
+
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		if !jira.IsIssueFile(filepath.Base(path)) {
+			return nil
+		}
+		if index != nil {
+			key := strings.TrimSuffix(jira.TrimCompressedSuffix(filepath.Base(path)), ".json")
+			if e, ok := index.Issues[key]; ok && e.Project != project {
+				return nil
+			}
+		}
+
+		// A damaged file is skipped rather than failing the whole
+		// report; cache verify finds and repairs them.
+		issueData, err := jira.ReadCacheFile(path)
+		if err != nil {
+			cli.Warnf("skipping %s: %v (see cache verify)", path, err)
+			return nil
+		}
+		var issue jira.JiraIssueWithSprints
+		if err := json.Unmarshal(issueData, &issue); err != nil {
+			cli.Warnf("skipping %s: parse json: %v (see cache verify)", path, err)
+			return nil
+		}
+		if project != "" && issue.Fields.Project.Key != project {
+			return nil
+		}
+
+		changelog, err := sprintChangelog(dir, issue, &coverage)
+		if err != nil {
+			cli.Warnf("skipping %s: %v (see cache verify)", issue.Key, err)
+			return nil
+		}
+
+		storyPoints[issue.Key] = effort.InitialEffort(issue, changelog)
+		if rollup && issue.IsSubtask() {
+			subtasks[issue.Key] = issue
+		}
+		for _, s := range issue.Fields.Sprints {
+			if start, ok := s.StartTime(); ok {
+				sprintStarts[jira.CanonicalSprint(s.Name)] = start
+			}
+		}
+
+		for _, h := range changelog.Histories {
+			t, err := time.Parse("2006-01-02T15:04:05.000-0700", h.Created)
+			if err != nil {
+				continue
+			}
+			for _, item := range h.Items {
+				switch item.Field {
+				case "Sprint":
+					originSprints := strings.Split(item.FromString, ",")
+					newSprints := strings.Split(item.ToString, ",")
+
+					if debugLog && (sprintFilter == "" || includes(originSprints, sprintFilter) || includes(newSprints, sprintFilter)) {
+						fmt.Printf("%s %s %s -> %s\n", h.Created, issue.Key, originSprints, newSprints)
+					}
+
+					for _, sprint := range originSprints {
+						sprint = jira.CanonicalSprint(strings.TrimSpace(sprint))
+						if sprint == "" || (sprintFilter != "" && sprint != sprintFilter) {
+							continue
+						}
+						k := SprintKey{IssueKey: issue.Key, Sprint: sprint}
+						if windows := sprintWindows[k]; len(windows) > 0 && windows[len(windows)-1].ToTime == nil {
+							windows[len(windows)-1].ToTime = &t
+							sprintWindows[k] = windows
+						}
+					}
+
+					for _, sprint := range newSprints {
+						sprint = jira.CanonicalSprint(strings.TrimSpace(sprint))
+						if sprint == "" || (sprintFilter != "" && sprint != sprintFilter) {
+							continue
+						}
+						k := SprintKey{IssueKey: issue.Key, Sprint: sprint}
+						if _, exists := sprintMeta[k]; !exists {
+							sprintMeta[k] = SprintMeta{
+								Points: storyPoints[issue.Key],
+								Status: statuses[issue.Key],
+							}
+						}
+						sprintWindows[k] = append(sprintWindows[k], WindowSpan{FromTime: t})
+					}
+				case effort.ChangelogField():
+					if pts, ok := effort.ParseChangelogValue(item); ok {
+						storyPoints[issue.Key] = pts
+					}
+				case "status":
+					if item.ToString != "" {
+						statuses[issue.Key] = item.ToString
+					}
+				}
+			}
+		}
+		if t, ok := doneTime(issue, changelog); ok {
+			doneAt[issue.Key] = t
+		}
+		return nil
+	})
+	if err != nil {
+		cli.Fatal(fmt.Errorf("error scanning files: %w", err))
+	}
+	coverage.Log()
+	if rollup {
+		n := rollupSubtasks(dir, effort, subtasks, sprintWindows, sprintMeta, doneAt)
+		cli.Infof("rolled %d subtasks up into their stories", n)
+	}
+
+	fmt.Println("-------------------------------------------------------------------------")
+	windowKeys := make([]SprintKey, 0, len(sprintWindows))
+	for skey := range sprintWindows {
+		windowKeys = append(windowKeys, skey)
+	}
+	sort.Slice(windowKeys, func(i, j int) bool {
+		if windowKeys[i].IssueKey != windowKeys[j].IssueKey {
+			return windowKeys[i].IssueKey < windowKeys[j].IssueKey
+		}
+		return windowKeys[i].Sprint < windowKeys[j].Sprint
+	})
+	for _, skey := range windowKeys {
+		for k, window := range sprintWindows[skey] {
+			fmt.Printf("%s %s %d %v\n", skey.IssueKey, skey.Sprint, k, window)
+		}
+	}
+	fmt.Println("-------------------------------------------------------------------------")
+
+	now := time.Now()
+	if burnupMode {
+		table := burnupTable(burnup(sprintWindows, sprintMeta, doneAt, sprintStarts, intervalDur, now), effort)
+		renderOpts.StoreCached(cacheName, resultVersion, table)
+		write(table)
+		return
+	}
+
+	type key struct {
+		Timestamp string
+		Sprint    string
+	}
+	counts := make(map[key]map[string]struct{})
+	totalPoints := make(map[key]float64)
+	statusCounts := make(map[key]map[string]int)
+
+	// column is where an issue in a status is counted: the status itself,
+	// or the board column showing it.
+	unmapped := map[string]bool{}
+	column := func(status string) string {
+		if board == nil {
+			return status
+		}
+		name, ok := board.ColumnOf(status)
+		if !ok && status != "" {
+			unmapped[status] = true
+		}
+		return name
+	}
+
+	for k, windows := range sprintWindows {
+		meta := sprintMeta[k]
+		seen := map[key]bool{}
+		for _, w := range windows {
+			end := now
+			if w.ToTime != nil {
+				end = *w.ToTime
+			}
+			for t := w.FromTime.Truncate(intervalDur); !t.After(end); t = t.Add(intervalDur) {
+				ts := t.Format(timeFormatFor(intervalDur))
+				kk := key{Timestamp: ts, Sprint: k.Sprint}
+				if counts[kk] == nil {
+					counts[kk] = map[string]struct{}{}
+				}
+				counts[kk][k.IssueKey] = struct{}{}
+				if !seen[kk] {
+					totalPoints[kk] += meta.Points
+					seen[kk] = true
+				}
+				if statusCounts[kk] == nil {
+					statusCounts[kk] = map[string]int{}
+				}
+				statusCounts[kk][column(meta.Status)]++
+			}
+		}
+	}
+
+	if len(unmapped) > 0 {
+		names := make([]string, 0, len(unmapped))
+		for status := range unmapped {
+			names = append(names, fmt.Sprintf("%q", status))
+		}
+		sort.Strings(names)
+		cli.Warnf("board %d has no column for %s; issues in them are not counted in any column", board.ID, strings.Join(names, ", "))
+	}
+
+	var keys []key
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Timestamp == keys[j].Timestamp {
+			return keys[i].Sprint < keys[j].Sprint
+		}
+		return keys[i].Timestamp < keys[j].Timestamp
+	})
+
+	statusesToTrack := []string{"Backlog", "In Progress", "Review", "Testing", "Resolved", "Closed"}
+	if board != nil {
+		statusesToTrack = board.ColumnNames()
+	}
+
+	headers := append([]string{"timestamp", "sprint", "issue_count", effort.ColumnName()}, statusesToTrack...)
+	table := render.NewTable(headers...)
+	table.SetNumeric(effort.ColumnName())
+	for _, k := range keys {
+		row := []string{
+			k.Timestamp,
+			k.Sprint,
+			fmt.Sprintf("%d", len(counts[k])),
+			fmt.Sprintf("%.1f", totalPoints[k]),
+		}
+		for _, s := range statusesToTrack {
+			row = append(row, fmt.Sprintf("%d", statusCounts[k][s]))
+		}
+		table.Append(row...)
+	}
+	renderOpts.StoreCached(cacheName, resultVersion, table)
+	write(table)
+}
+
+// loadBoard reads the column configuration of a board from the cache.
+func loadBoard(dir string, id int) *jira.BoardConfig {
+	store, err := jira.NewDirStore(dir)
+	if err != nil {
+		cli.Fatal(err)
+	}
+	boards, err := store.ReadBoards()
+	if err != nil {
+		cli.Fatal(err)
+	}
+	board, ok := boards.Board(id)
+	if !ok {
+		cli.Fatalf(cli.ExitNoData, "board %d is not in the cache; run boards fetch --board %d", id, id)
+	}
+	return &board
+}
+
+func Main(args []string) {
+	fs := flag.NewFlagSet("track", flag.ExitOnError)
+	dir := fs.String("dir", "issues", "Directory containing *.changelog.json files")
+	project := fs.String("project", "", "Filter on a specific project")
+	sprintFilter := fs.String("sprint-filter", "", "If set, only include this sprint in output")
+	intervalStr := fs.String("interval", "daily", "Time interval (daily, hourly, minutely)")
+	effortStr := fs.String("effort", "points", "Effort source for the points column (points, time, count)")
+	eventsMode := fs.Bool("events", false, "Print raw sprint membership events instead of the CSV report")
+	boardID := fs.Int("board", 0, "Count issues per column of this board (saved by boards fetch) instead of per status")
+	burnupMode := fs.Bool("burnup", false, "Write the scope and completed lines of each sprint, flagging issues added after it started, instead of the status counts")
+	rollup := fs.Bool("rollup-subtasks", false, "Count subtasks in their story, rolling their status and effort up into it, instead of on their own")
+	debugLog := fs.Bool("debug", false, "Show debug logging")
+	wait := fs.Duration("wait", 0, "Wait this long for a fetch writing the cache to finish instead of failing at once")
+	var renderOpts render.Options
+	render.AddFlags(fs, &renderOpts)
+	render.AddCacheFlag(fs, &renderOpts)
+	fs.Parse(args)
+
+	effort, err := jira.ParseEffortSource(*effortStr)
+	if err != nil {
+		cli.Fatal(err)
+	}
+	lock, err := jira.LockCache(*dir, false, *wait)
+	if err != nil {
+		cli.Fatal(cli.LockError(err))
+	}
+	defer lock.Unlock()
+
+	if *eventsMode {
+		process2(*dir, *project, renderOpts.Out, *sprintFilter, *intervalStr, *debugLog)
+		return
+	}
+	var board *jira.BoardConfig
+	if *boardID != 0 {
+		board = loadBoard(*dir, *boardID)
+	}
+	process(*dir, *project, renderOpts, *sprintFilter, *intervalStr, effort, board, *burnupMode, *rollup, *debugLog)
+
+}
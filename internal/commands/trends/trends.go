@@ -0,0 +1,206 @@
+// Package trends counts the issues created and resolved per label or
+// component in each week or day, with the open backlog at the end of it,
+// as CSV for trend charts.
+package trends
+
+import (
+	"flag"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/jira"
+	"github.com/jctanner/rhoai-jira/internal/query"
+	"github.com/jctanner/rhoai-jira/internal/render"
+	"github.com/jctanner/rhoai-jira/internal/tools"
+)
+
+// Point is the activity of one label or component in one bucket.
+type Point struct {
+	Bucket   time.Time
+	Value    string
+	Created  int
+	Resolved int
+	// Open is the backlog at the end of the bucket.
+	Open int
+}
+
+// Dimension returns the values an issue is grouped under.
+type Dimension func(jira.JiraIssueWithSprints) []string
+
+// Dimensions are the groupings of --by. Issues are grouped under their
+// labels and components as they are now, not as they were in the bucket.
+var Dimensions = map[string]Dimension{
+	"label":     func(i jira.JiraIssueWithSprints) []string { return i.Fields.Labels },
+	"component": func(i jira.JiraIssueWithSprints) []string { return i.ComponentNames() },
+}
+
+type span struct {
+	created  time.Time
+	resolved time.Time
+}
+
+// Trends counts, per value of dim and bucket from since to until, the
+// issues created and resolved in the bucket and open at its end.
+func Trends(issues []jira.JiraIssueWithSprints, dim Dimension, since, until time.Time, step func(time.Time) time.Time) []Point {
+	byValue := map[string][]span{}
+	for _, issue := range issues {
+		created, err := issue.CreatedTime()
+		if err != nil {
+			cli.Warnf("could not parse created time for %s: %v", issue.Key, err)
+			continue
+		}
+		s := span{created: created}
+		if resolved, err := issue.ResolvedTime(); err == nil {
+			s.resolved = resolved
+		}
+		for _, v := range dim(issue) {
+			byValue[v] = append(byValue[v], s)
+		}
+	}
+	values := make([]string, 0, len(byValue))
+	for v := range byValue {
+		values = append(values, v)
+	}
+	sort.Strings(values)
+
+	var points []Point
+	for start := since; start.Before(until); start = step(start) {
+		end := step(start)
+		if end.After(until) {
+			end = until
+		}
+		for _, v := range values {
+			p := Point{Bucket: start, Value: v}
+			for _, s := range byValue[v] {
+				if !s.created.Before(start) && s.created.Before(end) {
+					p.Created++
+				}
+				resolved := !s.resolved.IsZero()
+				if resolved && !s.resolved.Before(start) && s.resolved.Before(end) {
+					p.Resolved++
+				}
+				if s.created.Before(end) && (!resolved || !s.resolved.Before(end)) {
+					p.Open++
+				}
+			}
+			if p.Created > 0 || p.Resolved > 0 || p.Open > 0 {
+				points = append(points, p)
+			}
+		}
+	}
+	return points
+}
+
+// top returns the n values with the most activity, issues created plus
+// the open backlog summed over the buckets.
+func top(points []Point, n int) map[string]bool {
+	totals := map[string]int{}
+	for _, p := range points {
+		totals[p.Value] += p.Created + p.Open
+	}
+	values := make([]string, 0, len(totals))
+	for v := range totals {
+		values = append(values, v)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if totals[values[i]] != totals[values[j]] {
+			return totals[values[i]] > totals[values[j]]
+		}
+		return values[i] < values[j]
+	})
+	keep := map[string]bool{}
+	for i, v := range values {
+		if i == n {
+			break
+		}
+		keep[v] = true
+	}
+	return keep
+}
+
+func Main(args []string) {
+	fs := flag.NewFlagSet("trends", flag.ExitOnError)
+	cacheFlags := cli.AddCacheFlags(fs)
+	by := fs.String("by", "label", "Group by label or component")
+	since := fs.String("since", "-26w", "Start of the report (2025-01-31 or -26w)")
+	until := fs.String("until", "now()", "End of the report")
+	bucket := fs.String("bucket", "week", "Time bucket: day or week")
+	topN := fs.Int("top", 0, "Only the N labels or components with the most activity (0 for all)")
+	var values tools.StringList
+	fs.Var(&values, "value", "Only these labels or components (comma separated or repeated)")
+	var renderOpts render.Options
+	render.AddFlags(fs, &renderOpts)
+	fs.Parse(args)
+
+	dim, ok := Dimensions[*by]
+	if !ok {
+		cli.Fatalf(cli.ExitUsage, "--by must be label or component")
+	}
+	now := time.Now()
+	sinceTime, err := query.ParseDate(*since, now)
+	if err != nil {
+		cli.Fatal(cli.WithCode(cli.ExitUsage, err))
+	}
+	untilTime, err := query.ParseDate(*until, now)
+	if err != nil {
+		cli.Fatal(cli.WithCode(cli.ExitUsage, err))
+	}
+	var step func(time.Time) time.Time
+	format := "2006-01-02"
+	switch *bucket {
+	case "day":
+		sinceTime = time.Date(sinceTime.Year(), sinceTime.Month(), sinceTime.Day(), 0, 0, 0, 0, sinceTime.Location())
+		step = func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }
+	case "week":
+		// Weeks start on Monday.
+		sinceTime = time.Date(sinceTime.Year(), sinceTime.Month(), sinceTime.Day()-(int(sinceTime.Weekday())+6)%7, 0, 0, 0, 0, sinceTime.Location())
+		step = func(t time.Time) time.Time { return t.AddDate(0, 0, 7) }
+	default:
+		cli.Fatalf(cli.ExitUsage, "--bucket must be day or week")
+	}
+	if !sinceTime.Before(untilTime) {
+		cli.Fatalf(cli.ExitUsage, "--since must be before --until")
+	}
+
+	store, err := cacheFlags.Open()
+	if err != nil {
+		cli.Fatal(err)
+	}
+	defer store.Close()
+	renderOpts.SetSource(store)
+
+	issues := jira.LoadIssues(store, cacheFlags.Project)
+	if len(issues) == 0 {
+		cli.Fatalf(cli.ExitNoData, "no cached issues")
+	}
+	points := Trends(issues, dim, sinceTime, untilTime, step)
+	var keep map[string]bool
+	if *topN > 0 {
+		keep = top(points, *topN)
+	}
+
+	table := render.NewTable(*bucket, *by, "created", "resolved", "open")
+	seen := map[string]bool{}
+	for _, p := range points {
+		if keep != nil && !keep[p.Value] {
+			continue
+		}
+		if len(values) > 0 && !tools.ItemInList(values, p.Value) {
+			continue
+		}
+		seen[p.Value] = true
+		table.Append(
+			p.Bucket.Format(format),
+			p.Value,
+			strconv.Itoa(p.Created),
+			strconv.Itoa(p.Resolved),
+			strconv.Itoa(p.Open),
+		)
+	}
+	cli.Infof("%s trends of %d values from %s to %s", *by, len(seen), sinceTime.Format(format), untilTime.Format(format))
+	if err := renderOpts.Write(table); err != nil {
+		cli.Fatal(err)
+	}
+}
@@ -0,0 +1,411 @@
+// Package volatility reports how often and by how much story points change
+// on issues once they are in a sprint, per sprint or per team, from the
+// story points changes in their changelogs, to tell how much re-estimation
+// during a sprint moves its velocity.
+package volatility
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/commands/burndown"
+	"github.com/jctanner/rhoai-jira/internal/events"
+	"github.com/jctanner/rhoai-jira/internal/jira"
+	"github.com/jctanner/rhoai-jira/internal/query"
+	"github.com/jctanner/rhoai-jira/internal/render"
+	"github.com/jctanner/rhoai-jira/internal/tools"
+)
+
+// noTeam is the team of sprints no sprint rule gives one.
+const noTeam = "(no team)"
+
+// Change is a change of the story points of an issue made while it was in
+// a sprint.
+type Change struct {
+	Key    string
+	Sprint string
+	At     time.Time
+	Author string
+	// From and To are the points before and after; a cleared value is 0.
+	From float64
+	To   float64
+	// Started is whether the sprint had started, as opposed to the issue
+	// being re-estimated while the sprint was planned.
+	Started bool
+}
+
+// Delta is the points the change added, negative when it removed some.
+func (c Change) Delta() float64 {
+	return c.To - c.From
+}
+
+// Sprint is the story points changes of the issues of one sprint.
+type Sprint struct {
+	// Name is the canonical sprint name, Team the team its rule gives it.
+	Name string
+	Team string
+	// Start and End are the sprint dates, zero when no cached issue has
+	// them.
+	Start time.Time
+	End   time.Time
+	// Entry holds the points of each issue that was in the sprint when it
+	// entered it.
+	Entry   map[string]float64
+	Changes []Change
+}
+
+// Totals sums the changes of one or more sprints.
+type Totals struct {
+	Sprints int
+	Issues  int
+	// Entry is the points of the issues when they entered their sprints.
+	Entry   float64
+	Changed int
+	Changes int
+	Started int
+	Added   float64
+	Removed float64
+}
+
+// Net is the points added less the points removed.
+func (t Totals) Net() float64 {
+	return t.Added - t.Removed
+}
+
+// ChurnPct is the points added and removed as a percentage of the points
+// at entry.
+func (t Totals) ChurnPct() float64 {
+	if t.Entry == 0 {
+		return 0
+	}
+	return 100 * (t.Added + t.Removed) / t.Entry
+}
+
+func (t *Totals) add(s *Sprint) {
+	t.Sprints++
+	t.Issues += len(s.Entry)
+	for _, points := range s.Entry {
+		t.Entry += points
+	}
+	changed := map[string]bool{}
+	for _, c := range s.Changes {
+		changed[c.Key] = true
+		t.Changes++
+		if c.Started {
+			t.Started++
+		}
+		if d := c.Delta(); d > 0 {
+			t.Added += d
+		} else {
+			t.Removed -= d
+		}
+	}
+	t.Changed += len(changed)
+}
+
+// Totals sums the changes of the sprint.
+func (s *Sprint) Totals() Totals {
+	var t Totals
+	t.add(s)
+	return t
+}
+
+// entry is when an issue entered a sprint, under the name it had then.
+type entry struct {
+	name string
+	at   time.Time
+}
+
+// entered finds the sprints an issue was ever in by canonical name, and
+// when it first entered each: the sprints it was created in, or the first
+// time the changelog adds it, or its creation when the changelog does not
+// record its sprints.
+func entered(t burndown.Tracked) map[string]entry {
+	out := map[string]entry{}
+	enter := func(name string, at time.Time) {
+		canonical := jira.CanonicalSprint(name)
+		if _, ok := out[canonical]; !ok {
+			out[canonical] = entry{name: name, at: at}
+		}
+	}
+	value, ok := jira.ValueAt(t.Changelog, events.FieldSprint, t.Created)
+	if !ok {
+		for _, s := range t.Issue.Fields.Sprints {
+			enter(s.Name, t.Created)
+		}
+		return out
+	}
+	for _, name := range events.SplitList(value) {
+		enter(name, t.Created)
+	}
+	for _, e := range events.Filter(events.Normalize(t.Issue.Key, t.Changelog), events.SprintAdded) {
+		enter(e.Sprint, e.At)
+	}
+	return out
+}
+
+// sprintsAt returns the canonical names of the sprints an issue was in at
+// a time.
+func sprintsAt(t burndown.Tracked, at time.Time) []string {
+	var names []string
+	if value, ok := jira.ValueAt(t.Changelog, events.FieldSprint, at); ok {
+		for _, name := range events.SplitList(value) {
+			names = append(names, jira.CanonicalSprint(name))
+		}
+		return names
+	}
+	for _, s := range t.Issue.Fields.Sprints {
+		names = append(names, jira.CanonicalSprint(s.Name))
+	}
+	return names
+}
+
+// Collect gathers the story points of the tracked issues when they entered
+// each sprint, and the changes made while they were in it, until the
+// sprint ended. Sprints are keyed by canonical name.
+func Collect(tracked []burndown.Tracked) map[string]*Sprint {
+	sprints := map[string]*Sprint{}
+	for _, t := range tracked {
+		for canonical, e := range entered(t) {
+			s, ok := sprints[canonical]
+			if !ok {
+				s = &Sprint{Name: canonical, Team: jira.SprintTeam(e.name), Entry: map[string]float64{}}
+				sprints[canonical] = s
+			}
+			if s.Start.IsZero() {
+				s.Start, s.End, _ = burndown.SprintWindow([]burndown.Tracked{t}, canonical)
+			}
+			s.Entry[t.Issue.Key] = jira.EffortPoints.EffortAt(t.Issue, t.Changelog, e.at)
+		}
+	}
+	for _, t := range tracked {
+		for _, e := range events.Filter(events.Normalize(t.Issue.Key, t.Changelog), events.PointsChanged) {
+			from, to := points(e.FromValue), points(e.ToValue)
+			if from == to {
+				continue
+			}
+			for _, name := range sprintsAt(t, e.At) {
+				s := sprints[name]
+				if s == nil || (!s.End.IsZero() && e.At.After(s.End)) {
+					continue
+				}
+				s.Changes = append(s.Changes, Change{
+					Key:     t.Issue.Key,
+					Sprint:  name,
+					At:      e.At,
+					Author:  e.Author,
+					From:    from,
+					To:      to,
+					Started: !s.Start.IsZero() && !e.At.Before(s.Start),
+				})
+			}
+		}
+	}
+	return sprints
+}
+
+func points(v *float64) float64 {
+	if v == nil {
+		return 0
+	}
+	return *v
+}
+
+func formatPoints(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func totalsRow(t Totals) []string {
+	return []string{
+		strconv.Itoa(t.Issues),
+		formatPoints(t.Entry),
+		strconv.Itoa(t.Changed),
+		strconv.Itoa(t.Changes),
+		strconv.Itoa(t.Started),
+		formatPoints(t.Added),
+		formatPoints(t.Removed),
+		formatPoints(t.Net()),
+		fmt.Sprintf("%.1f", t.ChurnPct()),
+	}
+}
+
+var totalsHeaders = []string{"issues", "points_at_entry", "changed_issues", "changes", "after_start", "points_added", "points_removed", "net_change", "churn_pct"}
+
+// totalsNumeric are the totalsHeaders holding fractional numbers.
+var totalsNumeric = []string{"points_at_entry", "points_added", "points_removed", "net_change", "churn_pct"}
+
+func Main(args []string) {
+	fs := flag.NewFlagSet("volatility", flag.ExitOnError)
+	cacheFlags := cli.AddCacheFlags(fs)
+	sprint := fs.String("sprint", "", "Only this sprint")
+	team := fs.String("team", "", "Only the sprints of this team, as the config's sprint rules name it")
+	queryStr := fs.String("query", "", "Only issues matching this JQL-lite query")
+	by := fs.String("by", "sprint", "One row per sprint, per team, or per change (issue)")
+	startedOnly := fs.Bool("started", false, "Only count changes made after the sprint started, not while it was planned")
+	var renderOpts render.Options
+	render.AddFlags(fs, &renderOpts)
+	fs.Parse(args)
+
+	if *by != "sprint" && *by != "team" && *by != "issue" {
+		cli.Fatalf(cli.ExitUsage, "--by must be sprint, team or issue")
+	}
+	var q *query.Query
+	var err error
+	if *queryStr != "" {
+		if q, err = query.Parse(*queryStr); err != nil {
+			cli.Fatal(cli.WithCode(cli.ExitUsage, err))
+		}
+	}
+
+	store, err := cacheFlags.Open()
+	if err != nil {
+		cli.Fatal(err)
+	}
+	defer store.Close()
+	renderOpts.SetSource(store)
+
+	issues := jira.LoadIssues(store, cacheFlags.Project)
+	if q != nil {
+		issues = q.Filter(issues)
+	}
+	var tracked []burndown.Tracked
+	var coverage jira.ChangelogCoverage
+	for _, issue := range issues {
+		created, err := issue.CreatedTime()
+		if err != nil {
+			continue
+		}
+		changelog, err := store.ReadChangelog(issue.Key)
+		t := burndown.Tracked{Issue: issue, Changelog: changelog, Created: created}
+		if len(entered(t)) > 0 {
+			tracked = append(tracked, t)
+			coverage.Add(err)
+		}
+	}
+	if len(tracked) == 0 {
+		cli.Fatalf(cli.ExitNoData, "no cached issues were ever in a sprint")
+	}
+	coverage.Log()
+
+	var sprints []*Sprint
+	for _, s := range Collect(tracked) {
+		if *sprint != "" && !jira.SameSprint(s.Name, *sprint) {
+			continue
+		}
+		if *team != "" && s.Team != *team {
+			continue
+		}
+		if *startedOnly {
+			var changes []Change
+			for _, c := range s.Changes {
+				if c.Started {
+					changes = append(changes, c)
+				}
+			}
+			s.Changes = changes
+		}
+		sprints = append(sprints, s)
+	}
+	scope := "sprints"
+	switch {
+	case *sprint != "":
+		scope = fmt.Sprintf("sprint %q", *sprint)
+	case *team != "":
+		scope = fmt.Sprintf("sprints of team %q", *team)
+	}
+	if len(sprints) == 0 {
+		cli.Fatalf(cli.ExitNoData, "no cached issues were in the %s", scope)
+	}
+	// Sprints in the order they started; those without dates last.
+	sort.Slice(sprints, func(i, j int) bool {
+		a, b := sprints[i], sprints[j]
+		if a.Start.IsZero() != b.Start.IsZero() {
+			return b.Start.IsZero()
+		}
+		if !a.Start.Equal(b.Start) {
+			return a.Start.Before(b.Start)
+		}
+		return a.Name < b.Name
+	})
+
+	var all Totals
+	for _, s := range sprints {
+		all.add(s)
+	}
+	cli.Infof("%d story points changes on %d of %d issues across %d %s: +%s -%s points, %.1f%% churn",
+		all.Changes, all.Changed, all.Issues, all.Sprints, scope, formatPoints(all.Added), formatPoints(all.Removed), all.ChurnPct())
+	renderOpts.Title = "Story points volatility: " + scope
+	renderOpts.AddNote("%d changes on %d of %d issues in %d sprints, %d after the sprint started", all.Changes, all.Changed, all.Issues, all.Sprints, all.Started)
+	renderOpts.AddNote("%s points added and %s removed, %.1f%% of the %s points at entry", formatPoints(all.Added), formatPoints(all.Removed), all.ChurnPct(), formatPoints(all.Entry))
+
+	var table *render.Table
+	switch *by {
+	case "sprint":
+		table = render.NewTable(append([]string{"sprint", "team", "start"}, totalsHeaders...)...)
+		table.SetNumeric(totalsNumeric...)
+		for _, s := range sprints {
+			start := ""
+			if !s.Start.IsZero() {
+				start = s.Start.Format("2006-01-02")
+			}
+			table.Append(append([]string{s.Name, s.Team, start}, totalsRow(s.Totals())...)...)
+		}
+	case "team":
+		teams := map[string]*Totals{}
+		var names []string
+		for _, s := range sprints {
+			name := s.Team
+			if name == "" {
+				name = noTeam
+			}
+			if teams[name] == nil {
+				teams[name] = &Totals{}
+				names = append(names, name)
+			}
+			teams[name].add(s)
+		}
+		sort.Strings(names)
+		table = render.NewTable(append([]string{"team", "sprints"}, totalsHeaders...)...)
+		table.SetNumeric(totalsNumeric...)
+		for _, name := range names {
+			t := teams[name]
+			table.Append(append([]string{name, strconv.Itoa(t.Sprints)}, totalsRow(*t)...)...)
+		}
+	case "issue":
+		summaries := map[string]string{}
+		for _, t := range tracked {
+			summaries[t.Issue.Key] = t.Issue.Fields.Summary
+		}
+		table = render.NewTable("sprint", "team", "key", "changed_at", "author", "from", "to", "delta", "after_start", "summary")
+		table.SetNumeric("from", "to", "delta")
+		for _, s := range sprints {
+			order := map[string]int{}
+			keys := make([]string, 0, len(s.Entry))
+			for key := range s.Entry {
+				keys = append(keys, key)
+			}
+			for i, key := range tools.SortNumerically(keys) {
+				order[key] = i
+			}
+			changes := append([]Change(nil), s.Changes...)
+			sort.SliceStable(changes, func(i, j int) bool {
+				if a, b := order[changes[i].Key], order[changes[j].Key]; a != b {
+					return a < b
+				}
+				return changes[i].At.Before(changes[j].At)
+			})
+			for _, c := range changes {
+				table.Append(s.Name, s.Team, c.Key, c.At.Format(time.RFC3339), c.Author,
+					formatPoints(c.From), formatPoints(c.To), formatPoints(c.Delta()),
+					strconv.FormatBool(c.Started), summaries[c.Key])
+			}
+		}
+	}
+	if err := renderOpts.Write(table); err != nil {
+		cli.Fatal(err)
+	}
+}
@@ -0,0 +1,119 @@
+package workload
+
+import (
+	"flag"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/commands/burndown"
+	"github.com/jctanner/rhoai-jira/internal/jira"
+	"github.com/jctanner/rhoai-jira/internal/render"
+)
+
+// churn reports the assignee changes of the issues of a sprint made while
+// it ran, per issue or per person.
+func churn(args []string) {
+	fs := flag.NewFlagSet("workload churn", flag.ExitOnError)
+	cacheFlags := cli.AddCacheFlags(fs)
+	sprint := fs.String("sprint", "", "Sprint name (required)")
+	by := fs.String("by", "issue", "One row per issue or per assignee")
+	var renderOpts render.Options
+	render.AddFlags(fs, &renderOpts)
+	fs.Parse(args)
+
+	if *sprint == "" {
+		cli.Fatalf(cli.ExitUsage, "--sprint must be provided.")
+	}
+	if *by != "issue" && *by != "assignee" {
+		cli.Fatalf(cli.ExitUsage, "--by must be issue or assignee")
+	}
+	store, err := cacheFlags.Open()
+	if err != nil {
+		cli.Fatal(err)
+	}
+	defer store.Close()
+	renderOpts.SetSource(store)
+
+	tracked, coverage := burndown.Load(store, cacheFlags.Project, *sprint)
+	if len(tracked) == 0 {
+		cli.Fatalf(cli.ExitNoData, "no cached issues were ever in sprint %q", *sprint)
+	}
+	coverage.Log()
+	start, end, ok := burndown.SprintWindow(tracked, *sprint)
+	if !ok {
+		cli.Fatalf(cli.ExitNoData, "could not determine the dates of sprint %q", *sprint)
+	}
+	if now := time.Now(); end.After(now) {
+		end = now
+	}
+
+	type person struct{ in, out, assigned, unassigned int }
+	people := map[string]*person{}
+	get := func(name string) *person {
+		if people[name] == nil {
+			people[name] = &person{}
+		}
+		return people[name]
+	}
+	issueTable := render.NewTable("key", "reassignments", "changes", "assignees", "summary")
+	reassignments, churned := 0, 0
+	for _, t := range tracked {
+		var changes []jira.AssigneeTransition
+		for _, tr := range jira.AssigneeTransitions(t.Changelog) {
+			if !tr.At.Before(start) && !tr.At.After(end) {
+				changes = append(changes, tr)
+			}
+		}
+		if len(changes) == 0 {
+			continue
+		}
+		n := 0
+		chain := []string{displayUser(changes[0].From)}
+		for _, tr := range changes {
+			chain = append(chain, displayUser(tr.To))
+			switch {
+			case tr.Reassignment():
+				n++
+				get(tr.From).out++
+				get(tr.To).in++
+			case tr.From == "":
+				get(tr.To).assigned++
+			case tr.To == "":
+				get(tr.From).unassigned++
+			}
+		}
+		reassignments += n
+		if n > 0 {
+			churned++
+		}
+		issueTable.Append(t.Issue.Key, strconv.Itoa(n), strconv.Itoa(len(changes)), strings.Join(chain, " -> "), t.Issue.Fields.Summary)
+	}
+	cli.Infof("sprint %q: %d reassignments across %d of %d issues", *sprint, reassignments, churned, len(tracked))
+
+	table := issueTable
+	if *by == "assignee" {
+		names := make([]string, 0, len(people))
+		for name := range people {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		table = render.NewTable("assignee", "reassigned_in", "reassigned_out", "assigned", "unassigned")
+		for _, name := range names {
+			p := people[name]
+			table.Append(name, strconv.Itoa(p.in), strconv.Itoa(p.out), strconv.Itoa(p.assigned), strconv.Itoa(p.unassigned))
+		}
+	}
+	if err := renderOpts.Write(table); err != nil {
+		cli.Fatal(err)
+	}
+}
+
+func displayUser(name string) string {
+	if name == "" {
+		return "(unassigned)"
+	}
+	return name
+}
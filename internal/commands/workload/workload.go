@@ -0,0 +1,177 @@
+// Package workload reports how many open issues and how much effort each
+// person held over time, and with "churn" how often the issues of a
+// sprint changed hands.
+package workload
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/jira"
+	"github.com/jctanner/rhoai-jira/internal/query"
+	"github.com/jctanner/rhoai-jira/internal/render"
+	"github.com/jctanner/rhoai-jira/internal/tools"
+)
+
+// tracked is a cached issue with its changelog.
+type tracked struct {
+	issue     jira.JiraIssueWithSprints
+	changelog jira.Changelog
+}
+
+func load(store jira.Store, project string) ([]tracked, jira.ChangelogCoverage) {
+	var issues []tracked
+	var coverage jira.ChangelogCoverage
+	for _, issue := range jira.LoadIssues(store, project) {
+		changelog, err := store.ReadChangelog(issue.Key)
+		coverage.Add(err)
+		issues = append(issues, tracked{issue: issue, changelog: changelog})
+	}
+	return issues, coverage
+}
+
+// Point is the workload of one person at the end of a bucket.
+type Point struct {
+	Bucket   time.Time
+	Assignee string
+	Open     int
+	Effort   float64
+	// ReassignedIn and ReassignedOut count the issues handed to and
+	// taken from the person during the bucket.
+	ReassignedIn  int
+	ReassignedOut int
+}
+
+// Workload measures the open issues of every assignee at the end of each
+// bucket from since to until. Unassigned issues are left out.
+func Workload(issues []tracked, effort jira.EffortSource, since, until time.Time, step func(time.Time) time.Time) []Point {
+	var points []Point
+	for start := since; start.Before(until); start = step(start) {
+		end := step(start)
+		if end.After(until) {
+			end = until
+		}
+		byName := map[string]*Point{}
+		point := func(name string) *Point {
+			if byName[name] == nil {
+				byName[name] = &Point{Bucket: start, Assignee: name}
+			}
+			return byName[name]
+		}
+		for _, t := range issues {
+			if l, ok := jira.LoadAt(t.issue, t.changelog, effort, end); ok && l.Open && l.Assignee != "" {
+				p := point(l.Assignee)
+				p.Open++
+				p.Effort += l.Effort
+			}
+			for _, tr := range jira.AssigneeTransitions(t.changelog) {
+				if !tr.Reassignment() || tr.At.Before(start) || !tr.At.Before(end) {
+					continue
+				}
+				point(tr.To).ReassignedIn++
+				point(tr.From).ReassignedOut++
+			}
+		}
+		names := make([]string, 0, len(byName))
+		for name := range byName {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			points = append(points, *byName[name])
+		}
+	}
+	return points
+}
+
+func Main(args []string) {
+	if len(args) > 0 && args[0] == "churn" {
+		churn(args[1:])
+		return
+	}
+	fs := flag.NewFlagSet("workload", flag.ExitOnError)
+	cacheFlags := cli.AddCacheFlags(fs)
+	effortStr := fs.String("effort", "points", "Effort source (points, time, count)")
+	since := fs.String("since", "-12w", "Start of the report (2025-01-31 or -12w)")
+	until := fs.String("until", "now()", "End of the report")
+	bucket := fs.String("bucket", "week", "Time bucket: day or week")
+	var assignees tools.StringList
+	fs.Var(&assignees, "assignee", "Only these assignees (comma separated or repeated)")
+	var renderOpts render.Options
+	render.AddFlags(fs, &renderOpts)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: %s [flags]\n       %s churn --sprint NAME [flags]\n\n", fs.Name(), fs.Name())
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	effort, err := jira.ParseEffortSource(*effortStr)
+	if err != nil {
+		cli.Fatal(cli.WithCode(cli.ExitUsage, err))
+	}
+	now := time.Now()
+	sinceTime, err := query.ParseDate(*since, now)
+	if err != nil {
+		cli.Fatal(cli.WithCode(cli.ExitUsage, err))
+	}
+	untilTime, err := query.ParseDate(*until, now)
+	if err != nil {
+		cli.Fatal(cli.WithCode(cli.ExitUsage, err))
+	}
+	var step func(time.Time) time.Time
+	format := "2006-01-02"
+	switch *bucket {
+	case "day":
+		sinceTime = time.Date(sinceTime.Year(), sinceTime.Month(), sinceTime.Day(), 0, 0, 0, 0, sinceTime.Location())
+		step = func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }
+	case "week":
+		// Weeks start on Monday.
+		sinceTime = time.Date(sinceTime.Year(), sinceTime.Month(), sinceTime.Day()-(int(sinceTime.Weekday())+6)%7, 0, 0, 0, 0, sinceTime.Location())
+		step = func(t time.Time) time.Time { return t.AddDate(0, 0, 7) }
+	default:
+		cli.Fatalf(cli.ExitUsage, "--bucket must be day or week")
+	}
+	if !sinceTime.Before(untilTime) {
+		cli.Fatalf(cli.ExitUsage, "--since must be before --until")
+	}
+
+	store, err := cacheFlags.Open()
+	if err != nil {
+		cli.Fatal(err)
+	}
+	defer store.Close()
+	renderOpts.SetSource(store)
+
+	issues, coverage := load(store, cacheFlags.Project)
+	if len(issues) == 0 {
+		cli.Fatalf(cli.ExitNoData, "no cached issues")
+	}
+	coverage.Log()
+
+	points := Workload(issues, effort, sinceTime, untilTime, step)
+	table := render.NewTable(*bucket, "assignee", "open_issues", effort.ColumnName(), "reassigned_in", "reassigned_out")
+	table.SetNumeric(effort.ColumnName())
+	people := map[string]bool{}
+	for _, p := range points {
+		if len(assignees) > 0 && !tools.ItemInList(assignees, p.Assignee) {
+			continue
+		}
+		people[p.Assignee] = true
+		table.Append(
+			p.Bucket.Format(format),
+			p.Assignee,
+			strconv.Itoa(p.Open),
+			fmt.Sprintf("%.1f", p.Effort),
+			strconv.Itoa(p.ReassignedIn),
+			strconv.Itoa(p.ReassignedOut),
+		)
+	}
+	cli.Infof("workload of %d people from %s to %s", len(people), sinceTime.Format(format), untilTime.Format(format))
+	if err := renderOpts.Write(table); err != nil {
+		cli.Fatal(err)
+	}
+}
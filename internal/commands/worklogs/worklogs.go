@@ -0,0 +1,195 @@
+// Package worklogs totals the time logged on cached issues, from the
+// worklogs fetch --worklogs saves, per person, sprint, epic or any
+// combination of them, for a date range.
+package worklogs
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jctanner/rhoai-jira/internal/cli"
+	"github.com/jctanner/rhoai-jira/internal/jira"
+	"github.com/jctanner/rhoai-jira/internal/query"
+	"github.com/jctanner/rhoai-jira/internal/render"
+	"github.com/jctanner/rhoai-jira/internal/tools"
+)
+
+// Groupings are the values of --by.
+var Groupings = []string{"author", "sprint", "epic", "issue", "project", "month"}
+
+// WorklogSprint is the sprint of the issue that was running when the work
+// started, or "" when none was.
+func WorklogSprint(issue jira.JiraIssueWithSprints, started time.Time) string {
+	for _, s := range issue.Fields.Sprints {
+		start, ok := s.StartTime()
+		if !ok || started.Before(start) {
+			continue
+		}
+		if end, ok := s.EndTime(); ok && started.After(end) {
+			continue
+		}
+		return s.Name
+	}
+	return ""
+}
+
+func groupValue(issue jira.JiraIssueWithSprints, w jira.Worklog, started time.Time, by string) string {
+	switch by {
+	case "author":
+		if id := w.Author.ID(); id != "" {
+			return id
+		}
+		return "(unknown)"
+	case "sprint":
+		if sprint := WorklogSprint(issue, started); sprint != "" {
+			return sprint
+		}
+		return "(no sprint)"
+	case "epic":
+		if epic := issue.EpicKey(); epic != "" {
+			return epic
+		}
+		return "(no epic)"
+	case "project":
+		return issue.Fields.Project.Key
+	case "month":
+		return started.Local().Format("2006-01")
+	default:
+		return issue.Key
+	}
+}
+
+// Total is the time logged in one group.
+type Total struct {
+	Group    []string
+	Issues   int
+	Worklogs int
+	Seconds  int
+}
+
+func Main(args []string) {
+	fs := flag.NewFlagSet("worklogs", flag.ExitOnError)
+	cacheFlags := cli.AddCacheFlags(fs)
+	var by tools.StringList
+	fs.Var(&by, "by", "Group by author, sprint, epic, issue, project or month (comma separated or repeated; default author)")
+	since := fs.String("since", "", "Only work started on or after this date (2025-01-31 or -90d)")
+	until := fs.String("until", "", "Only work started before this date")
+	sprint := fs.String("sprint", "", "Only work started while the issue was in this sprint")
+	queryStr := fs.String("query", "", "Only issues matching this JQL-lite query")
+	var renderOpts render.Options
+	render.AddFlags(fs, &renderOpts)
+	fs.Parse(args)
+
+	if len(by) == 0 {
+		by = tools.StringList{"author"}
+	}
+	for _, b := range by {
+		if !tools.ItemInList(Groupings, b) {
+			cli.Fatalf(cli.ExitUsage, "invalid --by %q (expected %s)", b, strings.Join(Groupings, ", "))
+		}
+	}
+	now := time.Now()
+	var from, to time.Time
+	var err error
+	if *since != "" {
+		if from, err = query.ParseDate(*since, now); err != nil {
+			cli.Fatal(cli.WithCode(cli.ExitUsage, err))
+		}
+	}
+	if *until != "" {
+		if to, err = query.ParseDate(*until, now); err != nil {
+			cli.Fatal(cli.WithCode(cli.ExitUsage, err))
+		}
+	}
+	var q *query.Query
+	if *queryStr != "" {
+		if q, err = query.Parse(*queryStr); err != nil {
+			cli.Fatal(cli.WithCode(cli.ExitUsage, err))
+		}
+	}
+
+	store, err := cacheFlags.Open()
+	if err != nil {
+		cli.Fatal(err)
+	}
+	defer store.Close()
+	renderOpts.SetSource(store)
+
+	issues := jira.LoadIssues(store, cacheFlags.Project)
+	if q != nil {
+		issues = q.Filter(issues)
+	}
+	totals := map[string]*Total{}
+	counted := map[string]map[string]bool{}
+	missing := 0
+	for _, issue := range issues {
+		worklogs, err := store.ReadWorklogs(issue.Key)
+		if err != nil {
+			if !jira.IsNotCached(err) {
+				cli.Warnf("skipping the worklogs of %s: %v", issue.Key, err)
+			} else if issue.Fields.TimeSpent != nil && *issue.Fields.TimeSpent > 0 {
+				missing++
+			}
+			continue
+		}
+		for _, w := range worklogs.Worklogs {
+			started, err := w.StartedTime()
+			if err != nil {
+				continue
+			}
+			if (!from.IsZero() && started.Before(from)) || (!to.IsZero() && !started.Before(to)) {
+				continue
+			}
+			if *sprint != "" && WorklogSprint(issue, started) != *sprint {
+				continue
+			}
+			group := make([]string, len(by))
+			for i, b := range by {
+				group[i] = groupValue(issue, w, started, b)
+			}
+			id := strings.Join(group, "\x00")
+			t, ok := totals[id]
+			if !ok {
+				t = &Total{Group: group}
+				totals[id] = t
+				counted[id] = map[string]bool{}
+			}
+			if !counted[id][issue.Key] {
+				counted[id][issue.Key] = true
+				t.Issues++
+			}
+			t.Worklogs++
+			t.Seconds += w.TimeSpentSeconds
+		}
+	}
+	if missing > 0 {
+		cli.Warnf("%d issues with logged time have no cached worklogs (fetch them with fetch --worklogs)", missing)
+	}
+	if len(totals) == 0 {
+		cli.Fatalf(cli.ExitNoData, "no cached worklogs match")
+	}
+
+	rows := make([]*Total, 0, len(totals))
+	for _, t := range totals {
+		rows = append(rows, t)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		return strings.Join(rows[i].Group, "\x00") < strings.Join(rows[j].Group, "\x00")
+	})
+	table := render.NewTable(append(append([]string{}, by...), "issues", "worklogs", "hours")...)
+	table.SetNumeric("hours")
+	for _, t := range rows {
+		row := append(append([]string{}, t.Group...),
+			fmt.Sprintf("%d", t.Issues),
+			fmt.Sprintf("%d", t.Worklogs),
+			fmt.Sprintf("%.2f", float64(t.Seconds)/3600),
+		)
+		table.Append(row...)
+	}
+	if err := renderOpts.Write(table); err != nil {
+		cli.Fatal(err)
+	}
+}
@@ -0,0 +1,447 @@
+// Package config loads the optional rhoai-jira config file holding the
+// settings every command would otherwise need as flags.
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jctanner/rhoai-jira/internal/yaml"
+)
+
+// EnvVar names the config file when --config is not given.
+const EnvVar = "RHOAI_JIRA_CONFIG"
+
+// Config is the content of ~/.rhoai-jira.yaml, for example:
+//
+//	base_url: https://issues.redhat.com
+//	token: env:JIRA_TOKEN
+//	projects: [RHOAIENG, RHOAISTRAT]
+//	cache: sqlite:/data/jira.db
+//	output_dir: reports
+//	rate_limit:
+//	  requests_per_second: 5
+//	  burst: 10
+//	  max_attempts: 5
+//	http:
+//	  proxy: http://proxy.example.com:3128
+//	  ca_file: corp-ca.pem
+//	audit_log: api-audit.ndjson
+//	api_version: auto
+//	adf_format: markdown
+//	fields:
+//	  sprint: customfield_12310940
+//	  story_points: customfield_12310243
+//	  epic_link: customfield_12311140
+//	sprints:
+//	  - pattern: '^(?:RHOAI )?Sprint (\d+)(?: - .*)?$'
+//	    name: 'Sprint $1'
+//
+// Flags and environment variables take precedence over the file. Relative
+// paths are resolved against the directory holding the file.
+type Config struct {
+	BaseURL string `json:"base_url"`
+	Auth    string `json:"auth"`
+	Email   string `json:"email"`
+	// Token is a reference to the API token: "env:NAME", "file:PATH" or,
+	// discouraged, the token itself.
+	Token        string    `json:"token"`
+	Projects     []string  `json:"projects"`
+	Cache        string    `json:"cache"`
+	OutputDir    string    `json:"output_dir"`
+	FieldsConfig string    `json:"fields_config"`
+	RateLimit    RateLimit `json:"rate_limit"`
+	HTTP         HTTP      `json:"http"`
+	Fields       Fields    `json:"fields"`
+	// FetchMissing makes reports fetch issues missing from the cache.
+	FetchMissing bool `json:"fetch_missing"`
+	// Compress makes fetch write the files of each issue zstd-compressed
+	// in a directory cache.
+	Compress bool `json:"compress"`
+	// AuditLog is an NDJSON file recording every request made to Jira,
+	// read by the api-load report.
+	AuditLog string `json:"audit_log"`
+	// APIVersion is the Jira REST API version: 2, 3 or "auto" to ask the
+	// server; unset uses 3 for Atlassian Cloud sites and 2 otherwise.
+	APIVersion APIVersion `json:"api_version"`
+	// ADFFormat is what fetches convert the rich text API v3 returns as
+	// Atlassian Document Format to: "markdown", the default, or "text".
+	ADFFormat string `json:"adf_format"`
+	Server    Server `json:"server"`
+	// Holidays are "YYYY-MM-DD" dates, each optionally followed by a name,
+	// marked on charts.
+	Holidays []string `json:"holidays"`
+	// Capacity is what each person can take on in a sprint.
+	Capacity Capacity `json:"capacity"`
+	// Notify posts a summary of notable changes after each sync.
+	Notify Notify `json:"notify"`
+	// Stale holds the thresholds of the stale command.
+	Stale Stale `json:"stale"`
+	// Denied sets when fetch asks again for issues Jira refused to show.
+	Denied Denied `json:"denied"`
+	// Sprints name the sprints each team or board spells its own way, so
+	// reports group them as one.
+	Sprints []SprintName `json:"sprints"`
+	// GitHub is what the prs command looks pull requests up with.
+	GitHub GitHub `json:"github"`
+
+	// Path is the file the config was read from.
+	Path string `json:"-"`
+}
+
+// RateLimit paces the requests made to Jira. All requests of a process
+// share one token bucket, whatever command or worker makes them.
+type RateLimit struct {
+	// RequestsPerSecond is the average rate of requests; Burst how many
+	// may be sent back to back after a quiet spell.
+	RequestsPerSecond float64 `json:"requests_per_second"`
+	Burst             int     `json:"burst"`
+	// MinInterval is the least time between two requests, e.g. "500ms";
+	// it sets the rate when RequestsPerSecond is not set.
+	MinInterval string `json:"min_interval"`
+	// MaxAttempts is the most attempts at a request that Jira answers
+	// with 429 or a gateway error; zero keeps the default.
+	MaxAttempts int `json:"max_attempts"`
+}
+
+// HTTP configures the connection to Jira.
+type HTTP struct {
+	// Proxy is the URL of an HTTP proxy; unset uses HTTPS_PROXY.
+	Proxy string `json:"proxy"`
+	// CAFile is a PEM bundle of extra certificate authorities.
+	CAFile             string `json:"ca_file"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify"`
+}
+
+// APIVersion is a REST API version, written as a number or a string.
+type APIVersion string
+
+func (v *APIVersion) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*v = APIVersion(s)
+		return nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err != nil {
+		return fmt.Errorf("api_version must be 2, 3 or auto")
+	}
+	*v = APIVersion(n.String())
+	return nil
+}
+
+// Server configures the server command.
+type Server struct {
+	// Tokens, when any are set, are required to use the server; each
+	// sees only its projects and fields.
+	Tokens []APIToken `json:"tokens"`
+}
+
+// APIToken grants one consumer of the server access to part of the cache.
+type APIToken struct {
+	Name string `json:"name"`
+	// Token is a reference like the Jira token: "env:NAME", "file:PATH"
+	// or the token itself.
+	Token string `json:"token"`
+	// Projects and Fields limit what the token sees; empty means all.
+	Projects []string `json:"projects"`
+	Fields   []string `json:"fields"`
+}
+
+// SprintName gives the sprints whose names match Pattern, a regular
+// expression, the canonical name Name, which may refer to the groups of
+// the pattern as $1. An empty Name keeps the matched text. The first
+// matching entry applies.
+type SprintName struct {
+	Pattern string `json:"pattern"`
+	Name    string `json:"name"`
+	// Team is the team or board the sprints belong to.
+	Team string `json:"team"`
+}
+
+// Capacity is the effort each person can deliver in a sprint, in the unit
+// of the effort source the reports use (points or hours).
+type Capacity struct {
+	// Default applies to people who are not listed.
+	Default float64            `json:"default"`
+	People  map[string]float64 `json:"people"`
+}
+
+// Of returns the capacity of a person.
+func (c Capacity) Of(person string) float64 {
+	if v, ok := c.People[person]; ok {
+		return v
+	}
+	return c.Default
+}
+
+// Team sums the capacity of the listed people.
+func (c Capacity) Team() float64 {
+	total := 0.0
+	for _, v := range c.People {
+		total += v
+	}
+	return total
+}
+
+// Notify configures the chat notifications fetch posts after each sync.
+// Webhook URLs are secrets and take references like the token: "env:NAME",
+// "file:PATH" or the URL itself.
+type Notify struct {
+	Slack      []string `json:"slack"`
+	GoogleChat []string `json:"google_chat"`
+	// Sprints are the sprints whose scope changes are reported; empty
+	// reports every sprint change.
+	Sprints []string `json:"sprints"`
+}
+
+// Stale configures when the stale command flags an open issue.
+type Stale struct {
+	// Days without any update; zero keeps the default.
+	Days int `json:"days"`
+	// Statuses are the days an issue may stay in each status.
+	Statuses map[string]int `json:"statuses"`
+}
+
+// Denied configures the re-check of issues marked denied on a 403.
+type Denied struct {
+	// RetryAfterDays retries issues last denied at least this many days
+	// ago; zero never retries them.
+	RetryAfterDays int `json:"retry_after_days"`
+}
+
+// GitHub configures the lookups of the pull requests issues link to.
+type GitHub struct {
+	// Token is a reference like the Jira token: "env:NAME", "file:PATH"
+	// or the token itself; unset uses env:GITHUB_TOKEN.
+	Token string `json:"token"`
+	// APIURL is the REST API of a GitHub Enterprise instance; unset uses
+	// https://api.github.com.
+	APIURL string `json:"api_url"`
+}
+
+// Holiday is a day off marked on charts.
+type Holiday struct {
+	Date time.Time
+	Name string
+}
+
+// HolidayDates parses the holidays setting.
+func (c *Config) HolidayDates() ([]Holiday, error) {
+	var holidays []Holiday
+	for _, h := range c.Holidays {
+		date, name, _ := strings.Cut(strings.TrimSpace(h), " ")
+		d, err := time.Parse("2006-01-02", date)
+		if err != nil {
+			return nil, fmt.Errorf("invalid holiday %q (expected YYYY-MM-DD and an optional name)", h)
+		}
+		holidays = append(holidays, Holiday{Date: d, Name: strings.TrimSpace(name)})
+	}
+	return holidays, nil
+}
+
+// Fields are the custom field ids of the Jira instance.
+type Fields struct {
+	Sprint      string `json:"sprint"`
+	StoryPoints string `json:"story_points"`
+	EpicLink    string `json:"epic_link"`
+}
+
+// DefaultPath is ~/.rhoai-jira.yaml, or "" when the home directory is
+// unknown.
+func DefaultPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".rhoai-jira.yaml")
+}
+
+// Load reads the config file at path. An empty path falls back to
+// $RHOAI_JIRA_CONFIG and then ~/.rhoai-jira.yaml; only an explicitly named
+// file has to exist, otherwise a missing file yields an empty config.
+func Load(path string) (*Config, error) {
+	explicit := path != ""
+	if !explicit {
+		path = os.Getenv(EnvVar)
+		explicit = path != ""
+	}
+	if !explicit {
+		path = DefaultPath()
+	}
+	if path == "" {
+		return &Config{}, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && !explicit {
+		return &Config{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read config: %w", err)
+	}
+	var c Config
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("parse config %s: %w", path, err)
+	}
+	c.Path = path
+	if err := c.validate(); err != nil {
+		return nil, fmt.Errorf("config %s: %w", path, err)
+	}
+	return &c, nil
+}
+
+func (c *Config) validate() error {
+	if _, err := c.MinInterval(); err != nil {
+		return err
+	}
+	if c.RateLimit.RequestsPerSecond < 0 || c.RateLimit.Burst < 0 {
+		return fmt.Errorf("rate_limit.requests_per_second and burst must not be negative")
+	}
+	if c.RateLimit.MaxAttempts < 0 {
+		return fmt.Errorf("rate_limit.max_attempts must not be negative")
+	}
+	if _, err := c.HolidayDates(); err != nil {
+		return err
+	}
+	switch c.APIVersion {
+	case "", "2", "3", "auto":
+	default:
+		return fmt.Errorf("invalid api_version %q (expected 2, 3 or auto)", c.APIVersion)
+	}
+	switch c.ADFFormat {
+	case "", "markdown", "text":
+	default:
+		return fmt.Errorf("invalid adf_format %q (expected markdown or text)", c.ADFFormat)
+	}
+	if c.Capacity.Default < 0 {
+		return fmt.Errorf("capacity.default must not be negative")
+	}
+	for name, v := range c.Capacity.People {
+		if v < 0 {
+			return fmt.Errorf("capacity of %s must not be negative", name)
+		}
+	}
+	for i, s := range c.Sprints {
+		if s.Pattern == "" {
+			return fmt.Errorf("sprints[%d] needs a pattern", i)
+		}
+		if _, err := regexp.Compile(s.Pattern); err != nil {
+			return fmt.Errorf("sprints[%d]: invalid pattern: %v", i, err)
+		}
+	}
+	if c.Denied.RetryAfterDays < 0 {
+		return fmt.Errorf("denied.retry_after_days must not be negative")
+	}
+	if c.Stale.Days < 0 {
+		return fmt.Errorf("stale.days must not be negative")
+	}
+	for status, days := range c.Stale.Statuses {
+		if days <= 0 {
+			return fmt.Errorf("stale threshold of %s must be positive", status)
+		}
+	}
+	literal := isLiteral(c.Token) || isLiteral(c.GitHub.Token)
+	for i, t := range c.Server.Tokens {
+		if t.Name == "" || t.Token == "" {
+			return fmt.Errorf("server.tokens[%d] needs a name and a token", i)
+		}
+		literal = literal || isLiteral(t.Token)
+	}
+	if literal {
+		if info, err := os.Stat(c.Path); err == nil && info.Mode().Perm()&0o077 != 0 {
+			return fmt.Errorf("holds a literal token but is readable by others; use token: env:NAME or file:PATH, or chmod 600")
+		}
+	}
+	return nil
+}
+
+func isLiteral(ref string) bool {
+	return ref != "" && !strings.HasPrefix(ref, "env:") && !strings.HasPrefix(ref, "file:")
+}
+
+// Rate returns the requests a second and burst rate_limit sets, from
+// requests_per_second or else min_interval; a zero rate means unset.
+func (c *Config) Rate() (float64, int) {
+	rate := c.RateLimit.RequestsPerSecond
+	if interval, _ := c.MinInterval(); rate == 0 && interval > 0 {
+		rate = float64(time.Second) / float64(interval)
+	}
+	return rate, max(c.RateLimit.Burst, 1)
+}
+
+// MinInterval parses rate_limit.min_interval; zero means unset.
+func (c *Config) MinInterval() (time.Duration, error) {
+	if c.RateLimit.MinInterval == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(c.RateLimit.MinInterval)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid rate_limit.min_interval %q", c.RateLimit.MinInterval)
+	}
+	return d, nil
+}
+
+// ResolveToken dereferences the token setting.
+func (c *Config) ResolveToken() (string, error) {
+	return c.ResolveSecret(c.Token)
+}
+
+// ResolveSecret dereferences a secret setting: "env:NAME" reads the
+// environment, "file:PATH" a file, and anything else is the secret itself.
+func (c *Config) ResolveSecret(ref string) (string, error) {
+	switch {
+	case ref == "":
+		return "", nil
+	case strings.HasPrefix(ref, "env:"):
+		return os.Getenv(strings.TrimPrefix(ref, "env:")), nil
+	case strings.HasPrefix(ref, "file:"):
+		path := c.Resolve(strings.TrimPrefix(ref, "file:"))
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("read token file: %w", err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	default:
+		return ref, nil
+	}
+}
+
+// Resolve makes a path from the config relative to the config file's
+// directory, expanding a leading ~.
+func (c *Config) Resolve(path string) string {
+	if path == "" {
+		return ""
+	}
+	path = expandHome(path)
+	if filepath.IsAbs(path) || c.Path == "" {
+		return path
+	}
+	return filepath.Join(filepath.Dir(c.Path), path)
+}
+
+// CacheSpec returns the cache setting with any path resolved, keeping the
+// dir: or sqlite: prefix.
+func (c *Config) CacheSpec() string {
+	for _, prefix := range []string{"dir:", "sqlite:"} {
+		if rest, ok := strings.CutPrefix(c.Cache, prefix); ok {
+			return prefix + c.Resolve(rest)
+		}
+	}
+	return c.Resolve(c.Cache)
+}
+
+func expandHome(path string) string {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, rest)
+		}
+	}
+	return path
+}
@@ -0,0 +1,260 @@
+// Package events turns the raw changelog of an issue into a time-ordered
+// stream of typed events, so reports stop reimplementing the parsing of
+// history items: field names are matched regardless of case, sprint lists
+// are split into one event per sprint added or removed, numbers and
+// timestamps are parsed once, and histories logged at the same instant
+// keep Jira's order.
+package events
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jctanner/rhoai-jira/internal/jira"
+)
+
+// Kind is the type of an event.
+type Kind string
+
+const (
+	StatusChanged     Kind = "status_changed"
+	SprintAdded       Kind = "sprint_added"
+	SprintRemoved     Kind = "sprint_removed"
+	PointsChanged     Kind = "points_changed"
+	EstimateChanged   Kind = "estimate_changed"
+	AssigneeChanged   Kind = "assignee_changed"
+	ResolutionChanged Kind = "resolution_changed"
+	FixVersionAdded   Kind = "fix_version_added"
+	FixVersionRemoved Kind = "fix_version_removed"
+	RankChanged       Kind = "rank_changed"
+	LinkAdded         Kind = "link_added"
+	LinkRemoved       Kind = "link_removed"
+	// FieldChanged is a change of any other field.
+	FieldChanged Kind = "field_changed"
+)
+
+// Event is one change of an issue.
+type Event struct {
+	Kind Kind
+	Key  string
+	At   time.Time
+	// HistoryID is the id of the changelog history the change was part
+	// of; changes made together share it.
+	HistoryID string
+	Author    string
+	// Field is the field name as Jira logged it, and Canonical the same
+	// name spelled the one way the reports compare against.
+	Field     string
+	Canonical string
+	// From and To are the display values; FromID and ToID the raw ones,
+	// such as user names, status ids or seconds.
+	From   string
+	To     string
+	FromID string
+	ToID   string
+	// Sprint and SprintID name the sprint of sprint events.
+	Sprint   string
+	SprintID int
+	// FromValue and ToValue are the numbers of points and estimate
+	// changes, estimates in hours; nil when the value was cleared.
+	FromValue *float64
+	ToValue   *float64
+}
+
+// Canonical spellings of the fields the reports rely on.
+const (
+	FieldSprint     = "Sprint"
+	FieldStatus     = "status"
+	FieldAssignee   = "assignee"
+	FieldResolution = "resolution"
+	FieldFixVersion = "Fix Version"
+	FieldRank       = "Rank"
+	FieldLink       = "Link"
+	FieldEstimate   = "timeoriginalestimate"
+)
+
+// CanonicalField spells a changelog field name the way the constants above
+// and jira.StoryPointsName do; other names are returned as they are.
+func CanonicalField(field string) string {
+	for _, name := range []string{FieldSprint, FieldStatus, FieldAssignee, FieldResolution, FieldFixVersion, FieldRank, FieldLink, FieldEstimate, jira.StoryPointsName} {
+		if strings.EqualFold(field, name) {
+			return name
+		}
+	}
+	switch strings.ToLower(field) {
+	case "fixversions", "fix versions":
+		return FieldFixVersion
+	case "story point estimate":
+		return jira.StoryPointsName
+	}
+	return field
+}
+
+// Normalize converts the changelog of an issue into events in the order
+// they happened. Histories with an unparsable timestamp are left out.
+func Normalize(key string, changelog jira.Changelog) []Event {
+	changelog.Histories = append([]jira.HistoryEntry(nil), changelog.Histories...)
+	changelog.SortHistories()
+	var out []Event
+	for _, h := range changelog.Histories {
+		at, err := jira.ParseJiraTime(h.Created)
+		if err != nil {
+			continue
+		}
+		base := Event{Key: key, At: at, HistoryID: h.ID}
+		if h.Author != nil {
+			base.Author = h.Author.ID()
+		}
+		for _, item := range h.Items {
+			out = append(out, itemEvents(base, item)...)
+		}
+	}
+	return out
+}
+
+func itemEvents(e Event, item jira.HistoryItem) []Event {
+	e.Field = item.Field
+	e.Canonical = CanonicalField(item.Field)
+	e.From, e.To = item.FromString, item.ToString
+	e.FromID, e.ToID = item.From, item.To
+	switch e.Canonical {
+	case FieldSprint:
+		return sprintEvents(e, item)
+	case FieldStatus:
+		e.Kind = StatusChanged
+	case FieldAssignee:
+		e.Kind = AssigneeChanged
+	case FieldResolution:
+		e.Kind = ResolutionChanged
+	case FieldRank:
+		e.Kind = RankChanged
+	case FieldFixVersion:
+		e.Kind = FixVersionAdded
+		if e.To == "" {
+			e.Kind = FixVersionRemoved
+		}
+	case FieldLink:
+		e.Kind = LinkAdded
+		if e.To == "" {
+			e.Kind = LinkRemoved
+		}
+	case jira.StoryPointsName:
+		e.Kind = PointsChanged
+		e.FromValue, e.ToValue = number(e.From, 1), number(e.To, 1)
+	case FieldEstimate:
+		e.Kind = EstimateChanged
+		// Estimates are logged in seconds; the raw value is more reliable
+		// than the display one.
+		e.FromValue, e.ToValue = number(first(e.FromID, e.From), 3600), number(first(e.ToID, e.To), 3600)
+	default:
+		e.Kind = FieldChanged
+	}
+	return []Event{e}
+}
+
+// sprintEvents splits a change of the sprint list into one event per
+// sprint added or removed. Sprint ids are paired with names by position
+// when the item carries as many of each.
+func sprintEvents(e Event, item jira.HistoryItem) []Event {
+	before := sprintList(item.FromString, item.From)
+	after := sprintList(item.ToString, item.To)
+	var out []Event
+	for _, s := range after {
+		if !containsSprint(before, s.name) {
+			ev := e
+			ev.Kind, ev.Sprint, ev.SprintID = SprintAdded, s.name, s.id
+			out = append(out, ev)
+		}
+	}
+	for _, s := range before {
+		if !containsSprint(after, s.name) {
+			ev := e
+			ev.Kind, ev.Sprint, ev.SprintID = SprintRemoved, s.name, s.id
+			out = append(out, ev)
+		}
+	}
+	return out
+}
+
+type sprintRef struct {
+	name string
+	id   int
+}
+
+func sprintList(names, ids string) []sprintRef {
+	nameList := SplitList(names)
+	idList := SplitList(ids)
+	refs := make([]sprintRef, len(nameList))
+	for i, name := range nameList {
+		refs[i].name = name
+		if len(idList) == len(nameList) {
+			refs[i].id, _ = strconv.Atoi(idList[i])
+		}
+	}
+	return refs
+}
+
+func containsSprint(refs []sprintRef, name string) bool {
+	for _, r := range refs {
+		if r.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// SplitList splits a comma separated changelog value such as a sprint list
+// into its trimmed, non-empty parts.
+func SplitList(value string) []string {
+	var parts []string
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
+
+func first(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func number(s string, unit float64) *float64 {
+	v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return nil
+	}
+	v /= unit
+	return &v
+}
+
+// Filter keeps the events of the given kinds, in order.
+func Filter(events []Event, kinds ...Kind) []Event {
+	var out []Event
+	for _, e := range events {
+		for _, k := range kinds {
+			if e.Kind == k {
+				out = append(out, e)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// Between keeps the events after from and at or before to, in order.
+func Between(events []Event, from, to time.Time) []Event {
+	lo := sort.Search(len(events), func(i int) bool { return events[i].At.After(from) })
+	hi := sort.Search(len(events), func(i int) bool { return events[i].At.After(to) })
+	if hi < lo {
+		return nil
+	}
+	return events[lo:hi]
+}
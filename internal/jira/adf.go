@@ -0,0 +1,286 @@
+package jira
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Jira Cloud's API v3 returns descriptions, comments and other rich text
+// fields as Atlassian Document Format (ADF): a JSON tree of nodes rather
+// than the wiki markup strings of API v2. Fetches convert each document to
+// one of these formats before caching, so readers always see strings.
+const (
+	ADFMarkdown = "markdown"
+	ADFText     = "text"
+)
+
+// ADFFormats lists the accepted ADF conversion formats.
+var ADFFormats = []string{ADFMarkdown, ADFText}
+
+// IsADF reports whether a decoded JSON value is an ADF document.
+func IsADF(v interface{}) bool {
+	node, ok := v.(map[string]interface{})
+	if !ok || node["type"] != "doc" {
+		return false
+	}
+	_, ok = node["content"].([]interface{})
+	return ok
+}
+
+// ADFToText renders an ADF document as Markdown or, with ADFText, plain
+// text. Unknown nodes contribute the text they contain.
+func ADFToText(doc interface{}, format string) string {
+	r := adfRenderer{markdown: format != ADFText}
+	return strings.TrimSpace(r.blocks(adfContent(doc), "\n\n"))
+}
+
+// convertADF replaces every ADF document found in a decoded JSON value by
+// its text, in place where it can, and returns the result.
+func convertADF(v interface{}, format string) interface{} {
+	if IsADF(v) {
+		return ADFToText(v, format)
+	}
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			v[k] = convertADF(child, format)
+		}
+	case []interface{}:
+		for i, child := range v {
+			v[i] = convertADF(child, format)
+		}
+	}
+	return v
+}
+
+type adfRenderer struct {
+	markdown bool
+}
+
+func adfContent(node interface{}) []interface{} {
+	m, _ := node.(map[string]interface{})
+	content, _ := m["content"].([]interface{})
+	return content
+}
+
+func adfAttr(node map[string]interface{}, name string) string {
+	attrs, _ := node["attrs"].(map[string]interface{})
+	switch v := attrs[name].(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return ""
+}
+
+func (r adfRenderer) blocks(nodes []interface{}, sep string) string {
+	var parts []string
+	for _, n := range nodes {
+		if s := r.block(n); s != "" {
+			parts = append(parts, s)
+		}
+	}
+	return strings.Join(parts, sep)
+}
+
+func (r adfRenderer) block(n interface{}) string {
+	node, ok := n.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	switch node["type"] {
+	case "paragraph":
+		return r.inline(adfContent(node))
+	case "heading":
+		text := r.inline(adfContent(node))
+		if !r.markdown {
+			return text
+		}
+		level, _ := strconv.Atoi(adfAttr(node, "level"))
+		return strings.Repeat("#", max(level, 1)) + " " + text
+	case "bulletList", "orderedList":
+		return r.list(node)
+	case "codeBlock":
+		text := r.inline(adfContent(node))
+		if !r.markdown {
+			return text
+		}
+		return "```" + adfAttr(node, "language") + "\n" + text + "\n```"
+	case "blockquote":
+		text := r.blocks(adfContent(node), "\n\n")
+		if !r.markdown {
+			return text
+		}
+		return prefixLines(text, "> ", "> ")
+	case "rule":
+		return "---"
+	case "expand", "nestedExpand":
+		body := r.blocks(adfContent(node), "\n\n")
+		if title := adfAttr(node, "title"); title != "" {
+			return title + "\n\n" + body
+		}
+		return body
+	case "table":
+		return r.table(node)
+	case "mediaSingle", "mediaGroup":
+		var parts []string
+		for _, m := range adfContent(node) {
+			if media, ok := m.(map[string]interface{}); ok {
+				parts = append(parts, r.media(media))
+			}
+		}
+		return strings.Join(parts, "\n")
+	case "media":
+		return r.media(node)
+	}
+	if _, ok := node["content"]; ok {
+		return r.blocks(adfContent(node), "\n\n")
+	}
+	return r.inline([]interface{}{node})
+}
+
+func (r adfRenderer) list(node map[string]interface{}) string {
+	number := 0
+	if node["type"] == "orderedList" {
+		number = 1
+		if order, err := strconv.Atoi(adfAttr(node, "order")); err == nil {
+			number = order
+		}
+	}
+	var lines []string
+	for _, item := range adfContent(node) {
+		marker := "- "
+		if number > 0 {
+			marker = fmt.Sprintf("%d. ", number)
+			number++
+		}
+		text := r.blocks(adfContent(item), "\n")
+		lines = append(lines, prefixLines(text, marker, strings.Repeat(" ", len(marker))))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (r adfRenderer) table(node map[string]interface{}) string {
+	var lines []string
+	for i, row := range adfContent(node) {
+		var cells []string
+		header := false
+		for _, c := range adfContent(row) {
+			cell, _ := c.(map[string]interface{})
+			header = header || cell["type"] == "tableHeader"
+			text := r.blocks(adfContent(cell), " ")
+			cells = append(cells, strings.Join(strings.Fields(text), " "))
+		}
+		if !r.markdown {
+			lines = append(lines, strings.Join(cells, "\t"))
+			continue
+		}
+		lines = append(lines, "| "+strings.Join(cells, " | ")+" |")
+		if i == 0 {
+			if !header {
+				// Markdown tables need a header row; use an empty one.
+				lines = append([]string{"|" + strings.Repeat("  |", len(cells))}, lines...)
+			}
+			lines = append(lines, "|"+strings.Repeat(" --- |", len(cells)))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (r adfRenderer) media(node map[string]interface{}) string {
+	if alt := adfAttr(node, "alt"); alt != "" {
+		return "[attachment: " + alt + "]"
+	}
+	return "[attachment]"
+}
+
+func (r adfRenderer) inline(nodes []interface{}) string {
+	var b strings.Builder
+	for _, n := range nodes {
+		node, ok := n.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		switch node["type"] {
+		case "text":
+			text, _ := node["text"].(string)
+			b.WriteString(r.marks(text, node["marks"]))
+		case "hardBreak":
+			b.WriteString("\n")
+		case "mention":
+			text := adfAttr(node, "text")
+			if !strings.HasPrefix(text, "@") {
+				text = "@" + text
+			}
+			b.WriteString(text)
+		case "emoji":
+			if text := adfAttr(node, "text"); text != "" {
+				b.WriteString(text)
+			} else {
+				b.WriteString(adfAttr(node, "shortName"))
+			}
+		case "inlineCard", "blockCard", "embedCard":
+			b.WriteString(adfAttr(node, "url"))
+		case "status":
+			b.WriteString(adfAttr(node, "text"))
+		case "date":
+			if ms, err := strconv.ParseInt(adfAttr(node, "timestamp"), 10, 64); err == nil {
+				b.WriteString(time.UnixMilli(ms).UTC().Format("2006-01-02"))
+			}
+		default:
+			b.WriteString(r.inline(adfContent(node)))
+		}
+	}
+	return b.String()
+}
+
+// marks applies the formatting of a text node: in Markdown all of it, in
+// plain text only the targets of links.
+func (r adfRenderer) marks(text string, marks interface{}) string {
+	list, _ := marks.([]interface{})
+	for _, m := range list {
+		mark, _ := m.(map[string]interface{})
+		if mark["type"] == "link" {
+			href := adfAttr(mark, "href")
+			switch {
+			case href == "" || href == text:
+			case r.markdown:
+				text = "[" + text + "](" + href + ")"
+			default:
+				text += " (" + href + ")"
+			}
+			continue
+		}
+		if !r.markdown {
+			continue
+		}
+		switch mark["type"] {
+		case "strong":
+			text = "**" + text + "**"
+		case "em":
+			text = "*" + text + "*"
+		case "strike":
+			text = "~~" + text + "~~"
+		case "code":
+			text = "`" + text + "`"
+		}
+	}
+	return text
+}
+
+// prefixLines puts first before the first line of text and rest before
+// the others.
+func prefixLines(text, first, rest string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if i == 0 {
+			lines[i] = first + line
+		} else if line != "" {
+			lines[i] = rest + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,221 @@
+package jira
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Redacted replaces the text of redacted fields that had any.
+const Redacted = "[redacted]"
+
+// DefaultUserFields are the changelog fields and issue columns whose values
+// are users.
+var DefaultUserFields = []string{"assignee", "reporter", "creator"}
+
+// DefaultRedactFields are the changelog fields whose values are free text
+// bodies.
+var DefaultRedactFields = []string{"description", "environment", "comment"}
+
+var emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+
+// Anonymizer replaces users in exported records with pseudonyms and drops
+// free text, keeping keys, dates, statuses, points and transitions as they
+// are. Pseudonyms are numbered in the order users are first seen and kept
+// in a mapping file, so exports made with the same file agree on them. The
+// file holds the real names and must not be shared with the export.
+type Anonymizer struct {
+	// Users maps user names, account ids, display names and email
+	// addresses to pseudonyms; the ids and display names of one user share
+	// a pseudonym.
+	Users map[string]string `json:"users"`
+
+	path       string
+	next       int
+	userFields map[string]bool
+	redact     map[string]bool
+}
+
+// LoadAnonymizer reads the mapping file at path, or starts an empty one
+// when it does not exist yet. userFields and redactFields are added to the
+// defaults and compared case-insensitively.
+func LoadAnonymizer(path string, userFields, redactFields []string) (*Anonymizer, error) {
+	a := &Anonymizer{Users: map[string]string{}, path: path, userFields: map[string]bool{}, redact: map[string]bool{}}
+	for _, f := range append(append([]string{}, DefaultUserFields...), userFields...) {
+		a.userFields[strings.ToLower(f)] = true
+	}
+	for _, f := range append(append([]string{}, DefaultRedactFields...), redactFields...) {
+		a.redact[strings.ToLower(f)] = true
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return a, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read anonymization map: %w", err)
+	}
+	if err := json.Unmarshal(data, a); err != nil {
+		return nil, fmt.Errorf("parse anonymization map %s: %w", path, err)
+	}
+	if a.Users == nil {
+		a.Users = map[string]string{}
+	}
+	pseudonyms := map[string]bool{}
+	for _, p := range a.Users {
+		pseudonyms[p] = true
+	}
+	a.next = len(pseudonyms)
+	return a, nil
+}
+
+// Save writes the mapping file with the users seen so far.
+func (a *Anonymizer) Save() error {
+	data, err := json.MarshalIndent(a, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal anonymization map: %w", err)
+	}
+	if err := writeFileAtomic(a.path, append(data, '\n')); err != nil {
+		return fmt.Errorf("write %s: %w", a.path, err)
+	}
+	return nil
+}
+
+// Len is the number of pseudonyms handed out.
+func (a *Anonymizer) Len() int {
+	return a.next
+}
+
+// Pseudonym returns the pseudonym of a user, handing out the next one to a
+// user not seen before. Aliases, such as the display name next to a user
+// name in a changelog, get the same pseudonym. Empty names stay empty.
+func (a *Anonymizer) Pseudonym(user string, aliases ...string) string {
+	if user == "" {
+		return ""
+	}
+	p, ok := a.Users[user]
+	for _, alias := range aliases {
+		if ok {
+			break
+		}
+		p, ok = a.Users[alias]
+	}
+	if !ok {
+		a.next++
+		p = fmt.Sprintf("user-%04d", a.next)
+	}
+	a.Users[user] = p
+	for _, alias := range aliases {
+		if alias != "" {
+			a.Users[alias] = p
+		}
+	}
+	return p
+}
+
+// scrub replaces the email addresses and @mentions in a text kept in the
+// export with pseudonyms.
+func (a *Anonymizer) scrub(text string) string {
+	text = mentionPattern.ReplaceAllStringFunc(text, func(m string) string {
+		return "[~" + a.Pseudonym(mentionPattern.FindStringSubmatch(m)[1]) + "]"
+	})
+	return emailPattern.ReplaceAllStringFunc(text, func(email string) string {
+		return a.Pseudonym(email)
+	})
+}
+
+// scrubValue scrubs the strings and string lists of a record, leaving other
+// values as they are.
+func (a *Anonymizer) scrubValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return a.scrub(v)
+	case []string:
+		out := make([]string, len(v))
+		for i, s := range v {
+			out[i] = a.scrub(s)
+		}
+		return out
+	}
+	return value
+}
+
+func redact(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		if v == "" {
+			return v
+		}
+		return Redacted
+	case []string:
+		if len(v) == 0 {
+			return v
+		}
+		return []string{Redacted}
+	}
+	return value
+}
+
+// Issue anonymizes a record of IssueValues: user columns become pseudonyms,
+// redacted columns lose their text, and email addresses and @mentions in
+// the rest become pseudonyms.
+func (a *Anonymizer) Issue(record map[string]interface{}) {
+	// In a fixed order, so new users are numbered the same every time.
+	names := make([]string, 0, len(record))
+	for name := range record {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := record[name]
+		switch {
+		case a.redact[strings.ToLower(name)]:
+			record[name] = redact(value)
+		case a.userFields[strings.ToLower(name)]:
+			if s, ok := value.(string); ok {
+				record[name] = a.Pseudonym(s)
+			}
+		default:
+			record[name] = a.scrubValue(value)
+		}
+	}
+}
+
+// Event anonymizes a record of EventValues. The author becomes a
+// pseudonym, as do both sides of changes to user fields, the id and the
+// display name of each sharing one; changes to redacted fields keep the
+// change but lose the text.
+func (a *Anonymizer) Event(record map[string]interface{}) {
+	if s, ok := record["author"].(string); ok {
+		record["author"] = a.Pseudonym(s)
+	}
+	field, _ := record["field"].(string)
+	sides := [][2]string{{"fromId", "from"}, {"toId", "to"}}
+	switch {
+	case a.redact[strings.ToLower(field)]:
+		for _, side := range sides {
+			record[side[0]] = redact(record[side[0]])
+			record[side[1]] = redact(record[side[1]])
+		}
+	case a.userFields[strings.ToLower(field)]:
+		for _, side := range sides {
+			id, _ := record[side[0]].(string)
+			name, _ := record[side[1]].(string)
+			if id == "" {
+				record[side[1]] = a.Pseudonym(name)
+				continue
+			}
+			p := a.Pseudonym(id, name)
+			record[side[0]] = p
+			if name != "" {
+				record[side[1]] = p
+			}
+		}
+	default:
+		for _, name := range []string{"from", "to", "fromId", "toId"} {
+			record[name] = a.scrubValue(record[name])
+		}
+	}
+}
@@ -1,121 +1,41 @@
 package jira
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"io"
-	"log"
-	"net/http"
-	"net/url"
-	"os"
-	"path"
 	"time"
 )
 
-func DoGetWithRetry(url string, token string) ([]byte, error) {
-	var resp *http.Response
-	var err error
+// The functions in this file are shorthands for one-off calls with a
+// Bearer token; programs making many calls should share a Client.
 
-	for attempt := 1; attempt <= 5; attempt++ {
-		if attempt == 1 {
-			log.Printf("GET %s", url)
-		} else {
-			log.Printf("GET %s (attempt %d)", url, attempt)
-		}
-		req, reqErr := http.NewRequest("GET", url, nil)
-		if reqErr != nil {
-			return nil, fmt.Errorf("failed to create request: %w", reqErr)
-		}
-		req.Header.Set("Authorization", "Bearer "+token)
-		req.Header.Set("Accept", "application/json")
-
-		resp, err = http.DefaultClient.Do(req)
-		if err != nil {
-			return nil, fmt.Errorf("request error: %w", err)
-		}
-
-		if resp.StatusCode == 429 {
-			log.Printf("Rate limit exceeded. Sleeping %d seconds before retrying...", attempt)
-			resp.Body.Close()
-			time.Sleep(time.Duration(attempt) * time.Second)
-			continue
-		}
-
-		if resp.StatusCode == 404 {
-			resp.Body.Close()
-			return nil, fmt.Errorf("resource not found (404)")
-		}
-
-		if resp.StatusCode != 200 {
-			body, _ := io.ReadAll(resp.Body)
-			resp.Body.Close()
-			return nil, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(body))
-		}
-
-		body, readErr := io.ReadAll(resp.Body)
-		resp.Body.Close()
-		if readErr != nil {
-			return nil, fmt.Errorf("error reading response: %w", readErr)
-		}
-
-		time.Sleep(500 * time.Millisecond)
-		return body, nil
-	}
-
-	return nil, fmt.Errorf("exceeded retries for GET %s", url)
+func DoGetWithRetry(ctx context.Context, url string, token string) ([]byte, error) {
+	return NewClient("", token).Get(ctx, url)
 }
 
-func GetHighestIssueKey(baseURL, token, project string) string {
-	log.Println("Fetching latest issue key...")
-
-	url := fmt.Sprintf("%s/rest/api/2/search?jql=project=%s&maxResults=1&fields=key&orderBy=created%%20DESC", baseURL, project)
-	log.Println(url)
-
-	body, err := DoGetWithRetry(url, token)
-	if err != nil {
-		log.Fatalf("failed to fetch latest issue: %v", err)
-	}
-
-	log.Printf("Raw response:\n%s\n", string(body))
-
-	var result struct {
-		Issues []struct {
-			Key string `json:"key"`
-		} `json:"issues"`
-	}
-	if err := json.Unmarshal(body, &result); err != nil {
-		log.Fatalf("failed to parse response: %v", err)
-	}
-
-	if len(result.Issues) == 0 {
-		log.Fatalf("no issues found in project %s", project)
-	}
-
-	return result.Issues[0].Key
+func GetHighestIssueKey(ctx context.Context, baseURL, token, project string) (string, error) {
+	return NewClient(baseURL, token).HighestIssueKey(ctx, project)
 }
 
-func LookupSprintIDByName(baseURL, token, project, sprintName, sprintField string) (int, error) {
-	jql := fmt.Sprintf(`project = %s AND Sprint ~ "%s"`, project, sprintName)
-	reqURL := fmt.Sprintf(
-		`%s/rest/api/2/search?jql=%s&fields=key,%s&maxResults=20`,
-		baseURL,
-		url.QueryEscape(jql),
-		sprintField,
-	)
-
-	body, err := DoGetWithRetry(reqURL, token)
+func LookupSprintIDByName(ctx context.Context, baseURL, token, project, sprintName, sprintField string) (int, error) {
+	jql := JQL{}.Equals("project", project).Contains("Sprint", sprintName).String()
+	var issues []JiraIssueWithSprints
+	err := NewClient(baseURL, token).Search(ctx, jql, "key,"+sprintField, 20, func(page []json.RawMessage) (bool, error) {
+		for _, raw := range page {
+			var issue JiraIssueWithSprints
+			if err := json.Unmarshal(raw, &issue); err != nil {
+				return false, fmt.Errorf("parse error: %w", err)
+			}
+			issues = append(issues, issue)
+		}
+		return false, nil
+	})
 	if err != nil {
 		return 0, fmt.Errorf("Jira search failed: %w", err)
 	}
 
-	var result struct {
-		Issues []JiraIssueWithSprints `json:"issues"`
-	}
-	if err := json.Unmarshal(body, &result); err != nil {
-		return 0, fmt.Errorf("parse error: %w", err)
-	}
-
-	for _, issue := range result.Issues {
+	for _, issue := range issues {
 		/*
 			for _, sprintStr := range issue.Fields.Sprints {
 				sprint, err := ParseSprintString(sprintStr)
@@ -137,191 +57,40 @@ func LookupSprintIDByName(baseURL, token, project, sprintName, sprintField strin
 	return 0, fmt.Errorf("could not find sprint ID for name %q", sprintName)
 }
 
-func FetchAndSaveIssueWithChangelog(issueKey, baseURL, token, outputDir string) error {
-	url := fmt.Sprintf("%s/rest/api/2/issue/%s?expand=changelog", baseURL, issueKey)
-	body, err := DoGetWithRetry(url, token)
-	if err != nil {
-		return fmt.Errorf("fetch failed: %w", err)
-	}
-
-	var issueData map[string]interface{}
-	if err := json.Unmarshal(body, &issueData); err != nil {
-		return fmt.Errorf("parse json: %w", err)
-	}
-
-	changelog, ok := issueData["changelog"]
-	if ok {
-		changelogBytes, err := json.MarshalIndent(changelog, "", "  ")
-		if err != nil {
-			return fmt.Errorf("marshal changelog: %w", err)
-		}
-
-		changelogPath := path.Join(outputDir, fmt.Sprintf("%s.changelog.json", issueKey))
-		if err := os.WriteFile(changelogPath, changelogBytes, 0644); err != nil {
-			return fmt.Errorf("write changelog: %w", err)
-		}
-		log.Printf("saved %s", changelogPath)
-
-		delete(issueData, "changelog")
-	}
-
-	issueData["fetched"] = time.Now().UTC().Format(time.RFC3339)
-	strippedBytes, err := json.MarshalIndent(issueData, "", "  ")
+func FetchAndSaveIssueWithChangelog(ctx context.Context, issueKey, baseURL, token, outputDir string) error {
+	issueData, changelog, err := NewClient(baseURL, token).FetchIssueWithChangelog(ctx, issueKey)
 	if err != nil {
-		return fmt.Errorf("marshal issue without changelog: %w", err)
-	}
-
-	fullPath := path.Join(outputDir, fmt.Sprintf("%s.json", issueKey))
-	if err := os.WriteFile(fullPath, strippedBytes, 0644); err != nil {
-		return fmt.Errorf("write issue: %w", err)
+		return err
 	}
-	log.Printf("saved %s", fullPath)
-
-	return nil
+	store := &DirStore{Dir: outputDir}
+	return store.SaveIssue(issueKey, issueData, changelog)
 }
 
-func QueryUpdatedIssues(baseURL, token, project string, since time.Time) []UpdatedIssue {
-	var results []UpdatedIssue
-	startAt := 0
-	pageSize := 100
-	outputDir := "issues"
-	stopEarly := false
-
-	for {
-		jql := fmt.Sprintf("project = %s AND updated >= \"%s\" ORDER BY updated DESC", project, since.UTC().Format("2006-01-02 15:04"))
-		rawURL := fmt.Sprintf("%s/rest/api/2/search?jql=%s&fields=key,updated&startAt=%d&maxResults=%d", baseURL, url.QueryEscape(jql), startAt, pageSize)
-
-		body, err := DoGetWithRetry(rawURL, token)
-		if err != nil {
-			log.Fatalf("failed to query updated issues: %v", err)
-		}
-
-		var result struct {
-			StartAt    int `json:"startAt"`
-			MaxResults int `json:"maxResults"`
-			Total      int `json:"total"`
-			Issues     []struct {
-				Key    string `json:"key"`
-				Fields struct {
-					Updated string `json:"updated"`
-				} `json:"fields"`
-			} `json:"issues"`
-		}
-		if err := json.Unmarshal(body, &result); err != nil {
-			log.Fatalf("failed to parse updated issues response: %v", err)
-		}
-
-		log.Printf("Fetched %d issues (startAt=%d/%d)", len(result.Issues), result.StartAt, result.Total)
-
-		for _, issue := range result.Issues {
-			searchUpdatedTime, err := time.Parse("2006-01-02T15:04:05.000-0700", issue.Fields.Updated)
-			if err != nil {
-				log.Printf("could not parse updated time for %s: %v", issue.Key, err)
-				continue
-			}
-
-			diskPath := path.Join(outputDir, fmt.Sprintf("%s.json", issue.Key))
-			if data, err := os.ReadFile(diskPath); err == nil {
-				var obj map[string]interface{}
-				if err := json.Unmarshal(data, &obj); err == nil {
-					if fields, ok := obj["fields"].(map[string]interface{}); ok {
-						if diskUpdatedStr, ok := fields["updated"].(string); ok {
-							if diskUpdatedTime, err := time.Parse("2006-01-02T15:04:05.000-0700", diskUpdatedStr); err == nil {
-								log.Printf("%s: disk=%s vs search=%s", issue.Key, diskUpdatedTime, searchUpdatedTime)
-
-								if !searchUpdatedTime.After(diskUpdatedTime) {
-									log.Printf("Stopping early at %s: already up-to-date", issue.Key)
-									stopEarly = true
-									break
-								}
-							}
-						}
-					}
-				}
-			} else {
-				log.Printf("%s: not found on disk", issue.Key)
-			}
-
-			results = append(results, UpdatedIssue{
-				Key:         issue.Key,
-				UpdatedTime: searchUpdatedTime,
-			})
-		}
-
-		if stopEarly {
-			break
-		}
-
-		startAt += len(result.Issues)
-		if startAt >= result.Total || len(result.Issues) == 0 {
-			break
-		}
+// QueryUpdatedIssues lists the issues of a project updated since a time
+// that are newer in Jira than in the cache directory outputDir.
+func QueryUpdatedIssues(ctx context.Context, baseURL, token, project, outputDir string, since time.Time) ([]UpdatedIssue, error) {
+	store := &DirStore{Dir: outputDir}
+	jql := JQL{}.Equals("project", project).Since("updated", since).OrderBy("updated", Desc).String()
+	results, err := NewClient(baseURL, token).SearchIssueKeys(ctx, jql, func(key string, updated time.Time) bool {
+		onDisk, ok := store.IssueUpdated(key)
+		return ok && !updated.After(onDisk)
+	})
+	if err != nil {
+		return results, fmt.Errorf("failed to query updated issues: %w", err)
 	}
-
-	log.Printf("Total updated issues to refetch: %d", len(results))
-	return results
+	return results, nil
 }
 
-func GetIssuesInSprint(outputDir string, baseURL string, token string, project string, sprintName string) ([]UpdatedIssue, error) {
-	var results []UpdatedIssue
-	startAt := 0
-	pageSize := 100
-
-	sprintField := "customfield_12310940"
-	//sprintID, _ := lookupSprintIDByName(baseURL, token, project, sprintName, sprintField)
-	sprintID, err := LookupSprintIDFromDisk(outputDir, project, sprintName, sprintField)
+func GetIssuesInSprint(ctx context.Context, outputDir string, baseURL string, token string, project string, sprintName string) ([]UpdatedIssue, error) {
+	sprintID, err := LookupSprintIDFromDisk(outputDir, project, sprintName, SprintField)
 	if err != nil {
-		log.Fatalf("%s", err)
-		return results, err
+		return nil, err
 	}
-	log.Printf("%s -> %d", sprintName, sprintID)
 
-	//jql := fmt.Sprintf("project = %s AND Sprint = %d ORDER BY key ASC", project, sprintID)
-	jql := fmt.Sprintf(`project = %s AND Sprint = %d ORDER BY key ASC`, project, sprintID)
-
-	for {
-		escapedJQL := url.QueryEscape(jql)
-		reqURL := fmt.Sprintf("%s/rest/api/2/search?jql=%s&fields=key,updated&startAt=%d&maxResults=%d", baseURL, escapedJQL, startAt, pageSize)
-
-		body, err := DoGetWithRetry(reqURL, token)
-		if err != nil {
-			return nil, fmt.Errorf("fetch sprint issues: %w", err)
-		}
-
-		var result struct {
-			Issues []struct {
-				Key    string `json:"key"`
-				Fields struct {
-					Updated string `json:"updated"`
-				} `json:"fields"`
-			} `json:"issues"`
-			Total      int `json:"total"`
-			StartAt    int `json:"startAt"`
-			MaxResults int `json:"maxResults"`
-		}
-
-		if err := json.Unmarshal(body, &result); err != nil {
-			return nil, fmt.Errorf("unmarshal: %w", err)
-		}
-
-		for _, issue := range result.Issues {
-			parsedTime, err := time.Parse("2006-01-02T15:04:05.000-0700", issue.Fields.Updated)
-			if err != nil {
-				log.Printf("warning: could not parse updated time for %s: %v", issue.Key, err)
-				continue
-			}
-
-			results = append(results, UpdatedIssue{
-				Key:         issue.Key,
-				UpdatedTime: parsedTime,
-			})
-		}
-
-		startAt += len(result.Issues)
-		if startAt >= result.Total || len(result.Issues) == 0 {
-			break
-		}
+	jql := JQL{}.Equals("project", project).Equals("Sprint", sprintID).OrderBy("key", Asc).String()
+	results, err := NewClient(baseURL, token).SearchIssueKeys(ctx, jql, nil)
+	if err != nil {
+		return nil, fmt.Errorf("fetch sprint issues: %w", err)
 	}
-
 	return results, nil
 }
@@ -0,0 +1,386 @@
+package jira
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// The archive tier of a directory cache holds issues resolved long ago in
+// compressed bundles below archive/, one per project and year of
+// resolution, with an index of which bundle holds which issue. Archived
+// issues stay readable by key, but are left out of IssueKeys unless the
+// store includes the archive, so reports over the hot cache stay fast.
+// Bundles are zstd-compressed tar files; gzip-compressed bundles written
+// by earlier versions are still read, and are merged into the zstd bundle
+// of their project and year when more issues are archived there.
+const (
+	ArchiveDir       = "archive"
+	ArchiveIndexFile = "index.json"
+	BundleSuffix     = ".tar.zst"
+	// LegacyBundleSuffix names gzip-compressed bundles.
+	LegacyBundleSuffix = ".tar.gz"
+)
+
+// ArchiveEntry locates an archived issue.
+type ArchiveEntry struct {
+	Bundle   string   `json:"bundle"`
+	Resolved string   `json:"resolved"`
+	Updated  string   `json:"updated,omitempty"`
+	Files    []string `json:"files"`
+}
+
+// ArchiveIndex maps the keys of archived issues to their bundles.
+type ArchiveIndex struct {
+	Version int                     `json:"version"`
+	Issues  map[string]ArchiveEntry `json:"issues"`
+}
+
+// LoadArchiveIndex reads archive/index.json below dir; a cache without an
+// archive has an empty index.
+func LoadArchiveIndex(dir string) (*ArchiveIndex, error) {
+	x := &ArchiveIndex{Version: 1, Issues: map[string]ArchiveEntry{}}
+	name := filepath.Join(ArchiveDir, ArchiveIndexFile)
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if os.IsNotExist(err) {
+		return x, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, x); err != nil {
+		return nil, corruptEntry(name, err)
+	}
+	if x.Issues == nil {
+		x.Issues = map[string]ArchiveEntry{}
+	}
+	return x, nil
+}
+
+// Save writes the index below dir.
+func (x *ArchiveIndex) Save(dir string) error {
+	data, err := json.MarshalIndent(x, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal archive index: %w", err)
+	}
+	return writeFileAtomic(filepath.Join(dir, ArchiveDir, ArchiveIndexFile), data)
+}
+
+// Keys lists the archived issues of a project, or all of them.
+func (x *ArchiveIndex) Keys(project string) []string {
+	prefix := strings.ToUpper(project) + "-"
+	var keys []string
+	for key := range x.Issues {
+		if project == "" || strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// readBundle reads every file of a bundle into memory, decompressing it as
+// gzip when its name has the legacy suffix and as zstd otherwise.
+func readBundle(name string) (map[string][]byte, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var r io.Reader
+	if strings.HasSuffix(name, LegacyBundleSuffix) {
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", name, err)
+		}
+		r = zr
+	} else {
+		zr, err := zstd.NewReader(f)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", name, err)
+		}
+		defer zr.Close()
+		r = zr
+	}
+	files := map[string][]byte{}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", name, err)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", name, err)
+		}
+		files[hdr.Name] = data
+	}
+}
+
+// writeBundle replaces a bundle with the given files, sorted by name.
+func writeBundle(name string, files map[string][]byte) error {
+	tmp := name + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+	zw, err := zstd.NewWriter(f)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	tw := tar.NewWriter(zw)
+	names := make([]string, 0, len(files))
+	for n := range files {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	now := time.Now()
+	for _, n := range names {
+		hdr := &tar.Header{Name: n, Mode: 0644, Size: int64(len(files[n])), ModTime: now, Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			f.Close()
+			return err
+		}
+		if _, err := tw.Write(files[n]); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, name)
+}
+
+// archiveReader serves reads from the archive tier. Reports tend to read
+// the issues of one bundle together, so the last bundle read is kept in
+// memory.
+type archiveReader struct {
+	dir string
+
+	mu     sync.Mutex
+	index  *ArchiveIndex
+	err    error
+	loaded bool
+	bundle string
+	files  map[string][]byte
+}
+
+func (a *archiveReader) loadIndex() (*ArchiveIndex, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.loaded {
+		a.index, a.err = LoadArchiveIndex(a.dir)
+		a.loaded = true
+	}
+	return a.index, a.err
+}
+
+// readFile reads a cache file of an archived issue; it fails with
+// os.ErrNotExist for issues that are not archived.
+func (a *archiveReader) readFile(key, name string) ([]byte, error) {
+	index, err := a.loadIndex()
+	if err != nil {
+		return nil, err
+	}
+	entry, ok := index.Issues[key]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.bundle != entry.Bundle {
+		files, err := readBundle(filepath.Join(a.dir, ArchiveDir, entry.Bundle))
+		if err != nil {
+			return nil, err
+		}
+		a.bundle, a.files = entry.Bundle, files
+	}
+	data, ok := a.files[name]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return data, nil
+}
+
+// issueFileKey returns the issue a cache file such as KEY.changelog.json
+// belongs to.
+func issueFileKey(name string) string {
+	key, _, _ := strings.Cut(name, ".")
+	return key
+}
+
+// ArchiveOptions selects the issues moved to the archive tier.
+type ArchiveOptions struct {
+	// Project limits archiving to one project; empty means all.
+	Project string
+	// ResolvedBefore archives the issues resolved before this time.
+	ResolvedBefore time.Time
+	// DryRun reports what would be archived without changing the cache.
+	DryRun bool
+}
+
+// ArchiveResult counts what ArchiveIssues moved.
+type ArchiveResult struct {
+	Issues  int
+	Files   int
+	Bytes   int64
+	Bundles []string
+}
+
+// ArchiveIssues moves the issues resolved before opts.ResolvedBefore, with
+// their changelogs, comments and worklogs, from the cache directory into
+// the bundles of the archive tier. Bundles and the index are written
+// before any file is removed, so an interrupted run leaves issues in both
+// tiers rather than in neither. Archived files are dropped from the manifest.
+func ArchiveIssues(s *DirStore, opts ArchiveOptions) (ArchiveResult, error) {
+	var result ArchiveResult
+	if err := s.Flush(); err != nil {
+		return result, err
+	}
+	index, err := LoadArchiveIndex(s.Dir)
+	if err != nil {
+		return result, err
+	}
+
+	byBundle := map[string][]string{}
+	resolvedAt := map[string]time.Time{}
+	for _, key := range s.hotIssueKeys(opts.Project) {
+		issue, err := s.ReadIssue(key)
+		if err != nil {
+			continue
+		}
+		resolved, err := issue.ResolvedTime()
+		if err != nil || !resolved.Before(opts.ResolvedBefore) {
+			continue
+		}
+		bundle := fmt.Sprintf("%s-%d%s", projectOf(key), resolved.Year(), BundleSuffix)
+		byBundle[bundle] = append(byBundle[bundle], key)
+		resolvedAt[key] = resolved
+	}
+
+	var bundles []string
+	for bundle := range byBundle {
+		bundles = append(bundles, bundle)
+	}
+	sort.Strings(bundles)
+	if !opts.DryRun {
+		if err := os.MkdirAll(filepath.Join(s.Dir, ArchiveDir), 0755); err != nil {
+			return result, err
+		}
+	}
+
+	var moved, replaced []string
+	for _, bundle := range bundles {
+		bundlePath := filepath.Join(s.Dir, ArchiveDir, bundle)
+		files := map[string][]byte{}
+		if !opts.DryRun {
+			if existing, err := readBundle(bundlePath); err == nil {
+				files = existing
+			} else if !errors.Is(err, os.ErrNotExist) {
+				return result, err
+			}
+			// Issues archived into a gzip bundle of the same project and
+			// year move into the zstd one.
+			legacy := strings.TrimSuffix(bundle, BundleSuffix) + LegacyBundleSuffix
+			if existing, err := readBundle(filepath.Join(s.Dir, ArchiveDir, legacy)); err == nil {
+				for name, data := range existing {
+					files[name] = data
+				}
+				for key, entry := range index.Issues {
+					if entry.Bundle == legacy {
+						entry.Bundle = bundle
+						index.Issues[key] = entry
+					}
+				}
+				replaced = append(replaced, legacy)
+			} else if !errors.Is(err, os.ErrNotExist) {
+				return result, err
+			}
+		}
+		for _, key := range byBundle[bundle] {
+			entry := ArchiveEntry{Bundle: bundle, Resolved: resolvedAt[key].UTC().Format(time.RFC3339)}
+			if updated, ok := s.IssueUpdated(key); ok {
+				entry.Updated = updated.UTC().Format(time.RFC3339)
+			}
+			for _, name := range []string{key + ".json", key + ".changelog.json", key + ".comments.json", key + ".worklogs.json", key + ".watchers.json"} {
+				// Bundles hold the plain form; every form leaves the
+				// cache directory.
+				data, err := ReadCacheFile(filepath.Join(s.Dir, name))
+				if os.IsNotExist(err) {
+					continue
+				}
+				if err != nil {
+					return result, err
+				}
+				files[name] = data
+				entry.Files = append(entry.Files, name)
+				result.Files++
+				result.Bytes += int64(len(data))
+				moved = append(moved, cacheFileForms(name)...)
+			}
+			index.Issues[key] = entry
+			result.Issues++
+		}
+		result.Bundles = append(result.Bundles, bundle)
+		if opts.DryRun {
+			continue
+		}
+		if err := writeBundle(bundlePath, files); err != nil {
+			return result, fmt.Errorf("write %s: %w", bundle, err)
+		}
+	}
+	if opts.DryRun || result.Issues == 0 {
+		return result, nil
+	}
+	if err := index.Save(s.Dir); err != nil {
+		return result, fmt.Errorf("save archive index: %w", err)
+	}
+
+	for _, name := range moved {
+		if err := os.Remove(filepath.Join(s.Dir, name)); err != nil && !os.IsNotExist(err) {
+			return result, err
+		}
+	}
+	for _, bundle := range replaced {
+		if err := os.Remove(filepath.Join(s.Dir, ArchiveDir, bundle)); err != nil && !os.IsNotExist(err) {
+			return result, err
+		}
+	}
+	m, err := LoadManifest(s.Dir)
+	if err != nil {
+		return result, err
+	}
+	for _, name := range moved {
+		delete(m.Files, name)
+	}
+	if err := m.Save(s.Dir); err != nil {
+		return result, err
+	}
+	s.resetArchive()
+	return result, nil
+}
@@ -0,0 +1,268 @@
+package jira
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// AttachmentsDir is the directory under the cache holding downloaded
+// attachments, one subdirectory per issue.
+const AttachmentsDir = "attachments"
+
+// attachmentIndexFile records what was downloaded for an issue.
+const attachmentIndexFile = "index.json"
+
+type Attachment struct {
+	ID       string `json:"id"`
+	Filename string `json:"filename"`
+	Author   *User  `json:"author,omitempty"`
+	Created  string `json:"created"`
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+	Content  string `json:"content"`
+}
+
+// AttachmentRecord is an entry of attachments/{KEY}/index.json.
+type AttachmentRecord struct {
+	ID       string `json:"id"`
+	Filename string `json:"filename"`
+	// File is the name of the downloaded copy inside the issue directory.
+	File    string `json:"file,omitempty"`
+	Size    int64  `json:"size"`
+	SHA256  string `json:"sha256,omitempty"`
+	Created string `json:"created"`
+	// Skipped explains why the attachment was not downloaded.
+	Skipped string `json:"skipped,omitempty"`
+	// Removed is set when the attachment disappeared from the issue; the
+	// local copy is kept.
+	Removed string `json:"removed,omitempty"`
+}
+
+// DefaultAttachmentsDir is the attachments root next to a store: inside a
+// cache directory, or beside an SQLite database file.
+func DefaultAttachmentsDir(store Store) string {
+	switch s := store.(type) {
+	case *DirStore:
+		return filepath.Join(s.Dir, AttachmentsDir)
+	case *SQLiteStore:
+		return filepath.Join(filepath.Dir(s.Path), AttachmentsDir)
+	}
+	return AttachmentsDir
+}
+
+// AttachmentOptions controls SyncAttachments.
+type AttachmentOptions struct {
+	// Dir is the attachments root; files go to Dir/{KEY}/.
+	Dir string
+	// MaxSize skips attachments larger than this many bytes (0 for no cap).
+	MaxSize int64
+}
+
+// AttachmentResult counts what SyncAttachments did for an issue.
+type AttachmentResult struct {
+	Downloaded int
+	Unchanged  int
+	Skipped    int
+	Bytes      int64
+}
+
+// safeFilename keeps attachment names usable on every filesystem.
+func safeFilename(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.Map(func(r rune) rune {
+		if r < 32 || strings.ContainsRune(`<>:"/\|?*`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	if name == "" || name == "." || name == ".." {
+		name = "attachment"
+	}
+	return name
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// ReadAttachmentIndex reads attachments/{KEY}/index.json below dir; a
+// missing index yields no records.
+func ReadAttachmentIndex(dir, key string) ([]AttachmentRecord, error) {
+	data, err := os.ReadFile(filepath.Join(dir, key, attachmentIndexFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var records []AttachmentRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, corruptEntry(filepath.Join(key, attachmentIndexFile), err)
+	}
+	return records, nil
+}
+
+func writeAttachmentIndex(dir, key string, records []AttachmentRecord) error {
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(dir, key, attachmentIndexFile), data)
+}
+
+// Download streams an authenticated GET to w, retrying as the Retry policy
+// allows.
+func (c *Client) Download(ctx context.Context, url string, w io.Writer) (int64, error) {
+	retry := c.retry()
+	attempts := retry.attempts()
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if err := c.limiter().Wait(ctx); err != nil {
+			return 0, err
+		}
+		slog.Debug("GET", "url", url)
+		req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+		if err != nil {
+			return 0, fmt.Errorf("failed to create request: %w", err)
+		}
+		if err := c.authenticator().Authorize(ctx, req); err != nil {
+			return 0, fmt.Errorf("authorize request: %w", err)
+		}
+		resp, err := c.httpClient().Do(req)
+		if err != nil {
+			return 0, fmt.Errorf("request error: %w", err)
+		}
+		if retry.Retries(resp.StatusCode) && attempt < attempts {
+			resp.Body.Close()
+			if err := sleepContext(ctx, retry.Delay(attempt, resp.Header)); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		if resp.StatusCode != 200 {
+			body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+			resp.Body.Close()
+			return 0, &StatusError{StatusCode: resp.StatusCode, Body: string(body)}
+		}
+		n, err := io.Copy(w, resp.Body)
+		resp.Body.Close()
+		return n, err
+	}
+	return 0, fmt.Errorf("exceeded retries for GET %s", url)
+}
+
+// SyncAttachments downloads the attachments of a cached issue into
+// opts.Dir/{KEY}/. Attachments already on disk whose size and SHA-256 still
+// match the index are not refetched, attachments over the size cap are
+// recorded as skipped, and attachments removed from the issue are marked in
+// the index but their files kept.
+func (c *Client) SyncAttachments(ctx context.Context, store Store, key string, opts AttachmentOptions) (AttachmentResult, error) {
+	var result AttachmentResult
+	issue, err := store.ReadIssue(key)
+	if err != nil {
+		return result, err
+	}
+	previous, err := ReadAttachmentIndex(opts.Dir, key)
+	if err != nil {
+		return result, err
+	}
+	if len(issue.Fields.Attachments) == 0 && len(previous) == 0 {
+		return result, nil
+	}
+	issueDir := filepath.Join(opts.Dir, key)
+	if err := os.MkdirAll(issueDir, 0o755); err != nil {
+		return result, err
+	}
+
+	byID := make(map[string]AttachmentRecord, len(previous))
+	for _, r := range previous {
+		byID[r.ID] = r
+	}
+	seen := make(map[string]bool, len(issue.Fields.Attachments))
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	var records []AttachmentRecord
+	for _, a := range issue.Fields.Attachments {
+		rec := AttachmentRecord{ID: a.ID, Filename: a.Filename, Size: a.Size, Created: a.Created}
+		seen[a.ID] = true
+
+		if opts.MaxSize > 0 && a.Size > opts.MaxSize {
+			rec.Skipped = fmt.Sprintf("larger than %d bytes", opts.MaxSize)
+			result.Skipped++
+			records = append(records, rec)
+			continue
+		}
+
+		rec.File = a.ID + "-" + safeFilename(a.Filename)
+		path := filepath.Join(issueDir, rec.File)
+		if old, ok := byID[a.ID]; ok && old.SHA256 != "" && old.Size == a.Size {
+			if sum, err := hashFile(path); err == nil && sum == old.SHA256 {
+				rec.SHA256 = sum
+				result.Unchanged++
+				records = append(records, rec)
+				continue
+			}
+		}
+
+		sum, n, err := c.downloadTo(ctx, a.Content, path)
+		if err != nil {
+			return result, fmt.Errorf("download %s attachment %s: %w", key, a.Filename, err)
+		}
+		rec.SHA256 = sum
+		result.Downloaded++
+		result.Bytes += n
+		records = append(records, rec)
+	}
+
+	// Keep the history of removed attachments for audits.
+	for _, old := range previous {
+		if !seen[old.ID] {
+			if old.Removed == "" {
+				old.Removed = now
+			}
+			records = append(records, old)
+		}
+	}
+	return result, writeAttachmentIndex(opts.Dir, key, records)
+}
+
+// downloadTo fetches url into path via a temporary file, returning the
+// SHA-256 of the content and its size.
+func (c *Client) downloadTo(ctx context.Context, url, path string) (string, int64, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".download-*")
+	if err != nil {
+		return "", 0, err
+	}
+	defer os.Remove(tmp.Name())
+
+	h := sha256.New()
+	n, err := c.Download(ctx, url, io.MultiWriter(tmp, h))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", 0, err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
@@ -0,0 +1,163 @@
+package jira
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AuditEntry records one request made to Jira.
+type AuditEntry struct {
+	Time time.Time `json:"time"`
+	// Path is the request path and query, without the host.
+	Path     string `json:"path"`
+	Endpoint string `json:"endpoint"`
+	Project  string `json:"project,omitempty"`
+	// Status is the HTTP status, or 0 when the request failed outright.
+	Status     int   `json:"status"`
+	DurationMs int64 `json:"durationMs"`
+	Bytes      int64 `json:"bytes"`
+	Attempt    int   `json:"attempt"`
+}
+
+// AuditLog appends an AuditEntry per request to an NDJSON file. Each entry
+// is one append, so several processes may share the file.
+type AuditLog struct {
+	Path string
+	mu   sync.Mutex
+}
+
+// DefaultAuditLog is the AuditLog of new clients; nil records nothing.
+var DefaultAuditLog *AuditLog
+
+// Record appends an entry. Failures are reported once per call and do not
+// fail the request being audited.
+func (a *AuditLog) Record(e AuditEntry) error {
+	if a == nil || a.Path == "" {
+		return nil
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	f, err := os.OpenFile(a.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("open audit log: %w", err)
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("write audit log: %w", err)
+	}
+	return f.Close()
+}
+
+// ReadAuditLog reads the entries of an audit log, skipping lines that do
+// not parse, such as one cut short by a crash.
+func ReadAuditLog(path string) ([]AuditEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var entries []AuditEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var e AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
+
+var (
+	issuePathPattern  = regexp.MustCompile(`/issue/([A-Z][A-Z0-9_]*)-\d+`)
+	projectPattern    = regexp.MustCompile(`(?i)\bproject\s*(?:=|in\s*\()\s*"?([A-Z][A-Z0-9_]*)`)
+	projectPathPrefix = regexp.MustCompile(`/project/([A-Z][A-Z0-9_]*)`)
+)
+
+// ClassifyEndpoint names the kind of Jira endpoint a request path hits,
+// such as search, issue, changelog or sprint, and the project it concerns
+// when the path or JQL tells.
+func ClassifyEndpoint(rawPath string) (endpoint, project string) {
+	u, err := url.Parse(rawPath)
+	if err != nil {
+		return "other", ""
+	}
+	path := u.Path
+	if m := issuePathPattern.FindStringSubmatch(path); m != nil {
+		project = m[1]
+	} else if m := projectPathPrefix.FindStringSubmatch(path); m != nil {
+		project = m[1]
+	} else if jql := u.Query().Get("jql"); jql != "" {
+		if m := projectPattern.FindStringSubmatch(jql); m != nil {
+			project = strings.ToUpper(m[1])
+		}
+	}
+
+	switch {
+	case strings.Contains(path, "/rest/agile/"):
+		switch {
+		case strings.Contains(path, "/sprint"):
+			endpoint = "agile-sprint"
+		case strings.Contains(path, "/board"):
+			endpoint = "agile-board"
+		default:
+			endpoint = "agile"
+		}
+	case strings.HasSuffix(path, "/search"):
+		endpoint = "search"
+	case strings.HasSuffix(path, "/changelog"):
+		endpoint = "changelog"
+	case strings.Contains(path, "/comment"):
+		endpoint = "comment"
+	case strings.Contains(path, "/worklog"):
+		endpoint = "worklog"
+	case strings.HasSuffix(path, "/watchers"):
+		endpoint = "watchers"
+	case strings.Contains(path, "/attachment"):
+		endpoint = "attachment"
+	case strings.Contains(path, "/issue/"):
+		endpoint = "issue"
+	case strings.HasSuffix(path, "/field"):
+		endpoint = "field"
+	case strings.HasSuffix(path, "/versions"):
+		endpoint = "versions"
+	case strings.Contains(path, "/project"):
+		endpoint = "project"
+	case strings.HasSuffix(path, "/myself") || strings.HasSuffix(path, "/serverInfo"):
+		endpoint = "session"
+	default:
+		endpoint = "other"
+	}
+	return endpoint, project
+}
+
+// auditEntry builds the entry of a request to rawURL.
+func auditEntry(rawURL string, sent time.Time, status int, bytes int64, attempt int) AuditEntry {
+	path := rawURL
+	if u, err := url.Parse(rawURL); err == nil {
+		path = u.RequestURI()
+	}
+	endpoint, project := ClassifyEndpoint(path)
+	return AuditEntry{
+		Time:       sent.UTC(),
+		Path:       path,
+		Endpoint:   endpoint,
+		Project:    project,
+		Status:     status,
+		DurationMs: time.Since(sent).Milliseconds(),
+		Bytes:      bytes,
+		Attempt:    attempt,
+	}
+}
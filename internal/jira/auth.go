@@ -0,0 +1,138 @@
+package jira
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Authenticator adds credentials to outgoing Jira requests.
+type Authenticator interface {
+	Authorize(ctx context.Context, req *http.Request) error
+}
+
+// Reauthenticator is implemented by authenticators that can recover from a
+// 401 by obtaining fresh credentials.
+type Reauthenticator interface {
+	Invalidate()
+}
+
+// BearerAuth sends a Jira Server/Data Center personal access token.
+type BearerAuth struct {
+	Token string
+}
+
+func (a BearerAuth) Authorize(ctx context.Context, req *http.Request) error {
+	req.Header.Set("Authorization", "Bearer "+a.Token)
+	return nil
+}
+
+// BasicAuth sends an email address and API token, as used by Jira Cloud.
+type BasicAuth struct {
+	Email string
+	Token string
+}
+
+func (a BasicAuth) Authorize(ctx context.Context, req *http.Request) error {
+	req.SetBasicAuth(a.Email, a.Token)
+	return nil
+}
+
+// OAuth2Auth uses an OAuth 2.0 refresh token to obtain short lived access
+// tokens, refreshing them shortly before they expire.
+type OAuth2Auth struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	RefreshToken string
+	HTTPClient   *http.Client
+	// OnRefresh, when set, is called with a rotated refresh token so it can
+	// be persisted for the next run.
+	OnRefresh func(refreshToken string)
+
+	mu          sync.Mutex
+	accessToken string
+	expiry      time.Time
+}
+
+// AtlassianTokenURL is the OAuth 2.0 token endpoint of Jira Cloud.
+const AtlassianTokenURL = "https://auth.atlassian.com/oauth/token"
+
+func (a *OAuth2Auth) Authorize(ctx context.Context, req *http.Request) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.accessToken == "" || time.Now().Add(time.Minute).After(a.expiry) {
+		if err := a.refresh(ctx); err != nil {
+			return err
+		}
+	}
+	req.Header.Set("Authorization", "Bearer "+a.accessToken)
+	return nil
+}
+
+// Invalidate forces a refresh before the next request.
+func (a *OAuth2Auth) Invalidate() {
+	a.mu.Lock()
+	a.accessToken = ""
+	a.mu.Unlock()
+}
+
+func (a *OAuth2Auth) refresh(ctx context.Context) error {
+	tokenURL := a.TokenURL
+	if tokenURL == "" {
+		tokenURL = AtlassianTokenURL
+	}
+	form := url.Values{
+		"grant_type":    {"refresh_token"},
+		"client_id":     {a.ClientID},
+		"client_secret": {a.ClientSecret},
+		"refresh_token": {a.RefreshToken},
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("oauth refresh: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	httpClient := a.HTTPClient
+	if httpClient == nil {
+		httpClient = DefaultHTTPClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("oauth refresh: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("oauth refresh: %w", &StatusError{StatusCode: resp.StatusCode, Body: string(body)})
+	}
+
+	var token struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		ExpiresIn    int    `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil {
+		return fmt.Errorf("oauth refresh: parse response: %w", err)
+	}
+	if token.AccessToken == "" {
+		return fmt.Errorf("oauth refresh: no access token in response")
+	}
+	a.accessToken = token.AccessToken
+	a.expiry = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	if token.RefreshToken != "" && token.RefreshToken != a.RefreshToken {
+		a.RefreshToken = token.RefreshToken
+		if a.OnRefresh != nil {
+			a.OnRefresh(token.RefreshToken)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package jira
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// BoardsFile holds the column configuration of boards saved by
+// "boards fetch".
+const BoardsFile = "boards.json"
+
+// BoardColumn is a column of a board and the statuses it shows.
+type BoardColumn struct {
+	Name     string   `json:"name"`
+	Statuses []string `json:"statuses"`
+}
+
+// BoardConfig is the column layout of an agile board, with statuses by
+// name so cached issues can be placed in columns.
+type BoardConfig struct {
+	ID        int           `json:"id"`
+	Name      string        `json:"name"`
+	Columns   []BoardColumn `json:"columns"`
+	FetchedAt string        `json:"fetchedAt"`
+}
+
+// ColumnOf returns the column showing a status, compared
+// case-insensitively.
+func (b BoardConfig) ColumnOf(status string) (string, bool) {
+	for _, c := range b.Columns {
+		for _, s := range c.Statuses {
+			if strings.EqualFold(s, status) {
+				return c.Name, true
+			}
+		}
+	}
+	return "", false
+}
+
+// ColumnNames lists the columns from left to right.
+func (b BoardConfig) ColumnNames() []string {
+	names := make([]string, len(b.Columns))
+	for i, c := range b.Columns {
+		names[i] = c.Name
+	}
+	return names
+}
+
+// BoardMap is the set of board configurations saved in a cache.
+type BoardMap struct {
+	Boards []BoardConfig `json:"boards"`
+}
+
+// Board returns the saved configuration of a board.
+func (m BoardMap) Board(id int) (BoardConfig, bool) {
+	for _, b := range m.Boards {
+		if b.ID == id {
+			return b, true
+		}
+	}
+	return BoardConfig{}, false
+}
+
+// Put adds a board configuration, replacing an earlier one of the board.
+func (m *BoardMap) Put(b BoardConfig) {
+	for i := range m.Boards {
+		if m.Boards[i].ID == b.ID {
+			m.Boards[i] = b
+			return
+		}
+	}
+	m.Boards = append(m.Boards, b)
+	sort.Slice(m.Boards, func(i, j int) bool { return m.Boards[i].ID < m.Boards[j].ID })
+}
+
+// ListStatuses returns the names of the statuses of the Jira instance by
+// id.
+func (c *Client) ListStatuses(ctx context.Context) (map[string]string, error) {
+	body, err := c.Get(ctx, c.apiURL(ctx, "/status"))
+	if err != nil {
+		return nil, fmt.Errorf("list statuses: %w", err)
+	}
+	var statuses []struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(body, &statuses); err != nil {
+		return nil, fmt.Errorf("parse statuses: %w", err)
+	}
+	names := make(map[string]string, len(statuses))
+	for _, s := range statuses {
+		names[s.ID] = s.Name
+	}
+	return names, nil
+}
+
+// FetchBoardConfig reads the column configuration of a board from the
+// Agile API. Columns list statuses by id, which are resolved to names
+// through statusNames (see ListStatuses); unknown ids are kept as they
+// are.
+func (c *Client) FetchBoardConfig(ctx context.Context, boardID int, statusNames map[string]string) (BoardConfig, error) {
+	body, err := c.Get(ctx, fmt.Sprintf("%s/rest/agile/1.0/board/%d/configuration", c.BaseURL, boardID))
+	if err != nil {
+		return BoardConfig{}, fmt.Errorf("board %d configuration: %w", boardID, err)
+	}
+	var raw struct {
+		ID           int    `json:"id"`
+		Name         string `json:"name"`
+		ColumnConfig struct {
+			Columns []struct {
+				Name     string `json:"name"`
+				Statuses []struct {
+					ID string `json:"id"`
+				} `json:"statuses"`
+			} `json:"columns"`
+		} `json:"columnConfig"`
+	}
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return BoardConfig{}, fmt.Errorf("parse board %d configuration: %w", boardID, err)
+	}
+	board := BoardConfig{ID: boardID, Name: raw.Name, FetchedAt: time.Now().UTC().Format(time.RFC3339)}
+	for _, col := range raw.ColumnConfig.Columns {
+		column := BoardColumn{Name: col.Name, Statuses: []string{}}
+		for _, s := range col.Statuses {
+			name, ok := statusNames[s.ID]
+			if !ok {
+				name = s.ID
+			}
+			column.Statuses = append(column.Statuses, name)
+		}
+		board.Columns = append(board.Columns, column)
+	}
+	return board, nil
+}
+
+func (s *DirStore) ReadBoards() (BoardMap, error) {
+	var m BoardMap
+	data, err := s.readFile(BoardsFile)
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return m, err
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return m, corruptEntry(BoardsFile, err)
+	}
+	return m, nil
+}
+
+func (s *DirStore) SaveBoards(m BoardMap) error {
+	data, err := s.marshal(m)
+	if err != nil {
+		return fmt.Errorf("marshal boards: %w", err)
+	}
+	if err := s.writeFile(BoardsFile, data); err != nil {
+		return fmt.Errorf("write %s: %w", path.Join(s.Dir, BoardsFile), err)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) ReadBoards() (BoardMap, error) {
+	var m BoardMap
+	var data []byte
+	err := s.db.QueryRow(`SELECT data FROM metadata WHERE name = 'boards'`).Scan(&data)
+	if err == sql.ErrNoRows {
+		return m, nil
+	}
+	if err != nil {
+		return m, fmt.Errorf("read boards: %w", err)
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return m, corruptEntry("boards", err)
+	}
+	return m, nil
+}
+
+func (s *SQLiteStore) SaveBoards(m BoardMap) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("marshal boards: %w", err)
+	}
+	if _, err := s.db.Exec(`INSERT OR REPLACE INTO metadata (name, data) VALUES ('boards', ?)`, data); err != nil {
+		return fmt.Errorf("write boards: %w", err)
+	}
+	return nil
+}
@@ -6,11 +6,40 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
 )
 
+var issueFilePattern = regexp.MustCompile(`^[A-Z][A-Z0-9_]*-[0-9]+\.json(\.zst|\.gz)?$`)
+
+// IsIssueFile reports whether a cache file name holds an issue, as opposed
+// to changelogs, denied markers, manifests and other sidecar files. The
+// file may be compressed.
+func IsIssueFile(name string) bool {
+	return issueFilePattern.MatchString(name)
+}
+
+// issueKeysInDir lists the issues with a file in dir whose names start
+// with prefix, once each even while both forms of a file exist.
+func issueKeysInDir(dir, prefix string) []string {
+	var keys []string
+	seen := map[string]bool{}
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, prefix) || !IsIssueFile(name) {
+			continue
+		}
+		if key := issueFileKey(name); !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
 func LookupSprintIDFromDisk(dir, project, sprintName string, sprintField string) (int, error) {
 	prefix := strings.ToUpper(project) + "-"
 	entries, err := os.ReadDir(dir)
@@ -21,12 +50,12 @@ func LookupSprintIDFromDisk(dir, project, sprintName string, sprintField string)
 
 	for _, entry := range entries {
 		name := entry.Name()
-		if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ".json") || strings.HasSuffix(name, ".changelog.json") {
+		if !strings.HasPrefix(name, prefix) || !IsIssueFile(name) {
 			continue
 		}
 
 		fullPath := filepath.Join(dir, name)
-		data, err := os.ReadFile(fullPath)
+		data, err := ReadCacheFile(fullPath)
 		if err != nil {
 			continue
 		}
@@ -47,57 +76,48 @@ func LookupSprintIDFromDisk(dir, project, sprintName string, sprintField string)
 }
 
 func GetAllProjectIssueKeys(dir, project string) []string {
-	var keys []string
-	prefix := strings.ToUpper(project) + "-"
-
-	entries, _ := os.ReadDir(dir)
-	for _, entry := range entries {
-		name := entry.Name()
-		if strings.HasPrefix(name, prefix) && strings.HasSuffix(name, ".json") && !strings.HasSuffix(name, ".changelog.json") {
-			key := strings.TrimSuffix(name, ".json")
-			keys = append(keys, key)
-		}
-	}
-	return keys
+	return issueKeysInDir(dir, strings.ToUpper(project)+"-")
 }
 
 func GetAllCachedIssueKeys(dir string) []string {
+	return issueKeysInDir(dir, "")
+}
+
+// GetDeniedIssueKeys lists the keys that were marked as denied (403).
+func GetDeniedIssueKeys(dir, project string) []string {
 	var keys []string
+	prefix := strings.ToUpper(project) + "-"
 
 	entries, _ := os.ReadDir(dir)
 	for _, entry := range entries {
 		name := entry.Name()
-		if strings.HasSuffix(name, ".json") && !strings.HasSuffix(name, ".changelog.json") && !strings.HasSuffix(name, ".denied") {
-			key := strings.TrimSuffix(name, ".json")
-			keys = append(keys, key)
+		if strings.HasPrefix(name, prefix) && strings.HasSuffix(name, ".denied") {
+			keys = append(keys, strings.TrimSuffix(name, ".denied"))
 		}
 	}
 	return keys
 }
 
-func GetProjectNumbersOnDisk(dir, project string) map[int]struct{} {
+func GetProjectNumbersOnDisk(dir, project string) (map[int]struct{}, error) {
 	found := make(map[int]struct{})
 
 	entries, err := os.ReadDir(dir)
 	if err != nil {
-		log.Fatalf("failed to read directory %s: %v", dir, err)
+		return nil, fmt.Errorf("failed to read directory %s: %w", dir, err)
 	}
 
 	prefix := strings.ToUpper(project) + "-"
 	for _, entry := range entries {
 		name := entry.Name()
-		if strings.HasPrefix(name, prefix) &&
-			(strings.HasSuffix(name, ".json") || strings.HasSuffix(name, ".denied")) {
-
-			base := strings.TrimSuffix(strings.TrimSuffix(name, ".json"), ".denied")
-			numStr := strings.TrimPrefix(base, prefix)
+		if strings.HasPrefix(name, prefix) && (IsIssueFile(name) || strings.HasSuffix(name, ".denied") || strings.HasSuffix(name, ".tombstone")) {
+			numStr := strings.TrimPrefix(issueFileKey(name), prefix)
 			if num, err := strconv.Atoi(numStr); err == nil {
 				found[num] = struct{}{}
 			}
 		}
 	}
 
-	return found
+	return found, nil
 }
 
 func FindLatestUpdatedTimestamp(dirpath string, project string) time.Time {
@@ -109,16 +129,16 @@ func FindLatestUpdatedTimestamp(dirpath string, project string) time.Time {
 			return nil
 		}
 		filename := filepath.Base(path)
-		if !strings.HasSuffix(filename, ".json") || strings.HasSuffix(filename, ".changelog.json") || !strings.HasPrefix(filename, projectPrefix) {
+		if !IsIssueFile(filename) || !strings.HasPrefix(filename, projectPrefix) {
 			return nil
 		}
 
-		deniedFile := filepath.Join(dirpath, strings.TrimSuffix(filename, ".json")+".denied")
+		deniedFile := filepath.Join(dirpath, issueFileKey(filename)+".denied")
 		if _, err := os.Stat(deniedFile); err == nil {
 			return nil
 		}
 
-		data, err := os.ReadFile(path)
+		data, err := ReadCacheFile(path)
 		if err != nil {
 			return nil
 		}
@@ -159,7 +179,7 @@ func FilterRecentlyFetchedIssues(dir string, keys []string, window time.Duration
 	for _, key := range keys {
 		fullPath := filepath.Join(dir, key+".json")
 
-		data, err := os.ReadFile(fullPath)
+		data, err := ReadCacheFile(fullPath)
 		if err != nil {
 			remaining = append(remaining, key)
 			continue
@@ -196,7 +216,7 @@ func FilterRecentlyFetchedIssues(dir string, keys []string, window time.Duration
 func GetIssueChangelogFromCache(dir string, key string) (Changelog, error) {
 	var changelog Changelog
 	changelogPath := dir + "/" + key + ".changelog.json"
-	changelogData, err := os.ReadFile(changelogPath)
+	changelogData, err := ReadCacheFile(changelogPath)
 	if err != nil {
 		return changelog, err
 	}
@@ -204,20 +224,42 @@ func GetIssueChangelogFromCache(dir string, key string) (Changelog, error) {
 	if err := json.Unmarshal(changelogData, &changelog); err != nil {
 		return changelog, err
 	}
+	changelog.SortHistories()
 
 	return changelog, nil
 }
 
-func GetIssueFromCache(dir string, key string) JiraIssueWithSprints {
-	var issueData JiraIssueWithSprints
+func GetIssueFromCache(dir string, key string) (JiraIssueWithSprints, error) {
+	var issue JiraIssueWithSprints
 	path := dir + "/" + key + ".json"
-	issueData, err := os.ReadFile(path)
+	issueData, err := ReadCacheFile(path)
 	if err != nil {
-		return issueData, fmt.Errorf("failed to read %s: %w", path, err)
+		return issue, fmt.Errorf("failed to read %s: %w", path, err)
 	}
-	var issue jira.JiraIssueWithSprints
 	if err := json.Unmarshal(issueData, &issue); err != nil {
-		return issueData, fmt.Errorf("parse json: %s %w", path, err)
+		return issue, fmt.Errorf("parse json: %s %w", path, err)
+	}
+	return issue, nil
+}
+
+// LoadCachedIssues reads every cached issue, optionally restricted to a
+// project. Unreadable files are logged and skipped.
+func LoadCachedIssues(dir string, project string) []JiraIssueWithSprints {
+	var keys []string
+	if project != "" {
+		keys = GetAllProjectIssueKeys(dir, project)
+	} else {
+		keys = GetAllCachedIssueKeys(dir)
+	}
+
+	var issues []JiraIssueWithSprints
+	for _, key := range keys {
+		issue, err := GetIssueFromCache(dir, key)
+		if err != nil {
+			log.Printf("skipping %s: %v", key, err)
+			continue
+		}
+		issues = append(issues, issue)
 	}
-	return issueData, nil
+	return issues
 }
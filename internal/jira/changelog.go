@@ -1,16 +1,154 @@
 package jira
 
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
 type HistoryItem struct {
 	Field      string `json:"field"`
+	From       string `json:"from,omitempty"`
+	To         string `json:"to,omitempty"`
 	ToString   string `json:"toString"`
 	FromString string `json:"fromString"`
 }
 
 type HistoryEntry struct {
+	ID      string        `json:"id,omitempty"`
+	Author  *User         `json:"author,omitempty"`
 	Created string        `json:"created"`
 	Items   []HistoryItem `json:"items"`
 }
 
 type Changelog struct {
 	Histories []HistoryEntry `json:"histories"`
+	// PersistedFields lists the only fields kept when the changelog was
+	// saved with a field filter; empty means every field was kept.
+	PersistedFields []string `json:"persistedFields,omitempty"`
+}
+
+// historySeq is the numeric id Jira assigns to a history entry in sequence,
+// or -1 when the entry has none (such as synthesized histories).
+func historySeq(h HistoryEntry) int64 {
+	n, err := strconv.ParseInt(h.ID, 10, 64)
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+// historyLess orders histories by creation time and, for histories logged
+// at the same instant (bulk operations), by history id. Entries with an
+// unparsable timestamp sort after all others.
+func historyLess(a HistoryEntry, aAt time.Time, aOK bool, b HistoryEntry, bAt time.Time, bOK bool) bool {
+	if aOK != bOK {
+		return aOK
+	}
+	if !aAt.Equal(bAt) {
+		return aAt.Before(bAt)
+	}
+	return historySeq(a) < historySeq(b)
+}
+
+// SortHistories puts the histories in a deterministic chronological order,
+// breaking timestamp ties by history id so replaying a changelog does not
+// depend on the order Jira happened to return it in.
+func (c *Changelog) SortHistories() {
+	times := make([]time.Time, len(c.Histories))
+	valid := make([]bool, len(c.Histories))
+	for i, h := range c.Histories {
+		t, err := ParseJiraTime(h.Created)
+		times[i], valid[i] = t, err == nil
+	}
+	idx := make([]int, len(c.Histories))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(i, j int) bool {
+		a, b := idx[i], idx[j]
+		return historyLess(c.Histories[a], times[a], valid[a], c.Histories[b], times[b], valid[b])
+	})
+	sorted := make([]HistoryEntry, len(idx))
+	for i, k := range idx {
+		sorted[i] = c.Histories[k]
+	}
+	c.Histories = sorted
+}
+
+// DefaultChangelogFields are the changelog fields the reports rely on.
+var DefaultChangelogFields = []string{
+	"Sprint", "status", "Story Points", "assignee", "Rank",
+	"resolution", "Fix Version", "timeoriginalestimate",
+}
+
+// ParseChangelogFields parses a --changelog-fields value: "" keeps every
+// field, "default" selects DefaultChangelogFields, anything else is a comma
+// separated list of field names.
+func ParseChangelogFields(s string) []string {
+	s = strings.TrimSpace(s)
+	switch s {
+	case "", "all":
+		return nil
+	case "default":
+		return DefaultChangelogFields
+	}
+	var fields []string
+	for _, f := range strings.Split(s, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// FilterChangelog drops every history item of a raw changelog (as fetched
+// from Jira) whose field is not listed, and histories left without items.
+// Field names are compared case-insensitively.
+func FilterChangelog(changelog interface{}, fields []string) interface{} {
+	obj, ok := changelog.(map[string]interface{})
+	if !ok || len(fields) == 0 {
+		return changelog
+	}
+	keep := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		keep[strings.ToLower(f)] = true
+	}
+
+	histories, _ := obj["histories"].([]interface{})
+	var kept []interface{}
+	for _, h := range histories {
+		entry, ok := h.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		items, _ := entry["items"].([]interface{})
+		var keptItems []interface{}
+		for _, it := range items {
+			item, ok := it.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if field, _ := item["field"].(string); keep[strings.ToLower(field)] {
+				keptItems = append(keptItems, item)
+			}
+		}
+		if len(keptItems) == 0 {
+			continue
+		}
+		entry["items"] = keptItems
+		kept = append(kept, entry)
+	}
+
+	filtered := make(map[string]interface{}, len(obj)+1)
+	for k, v := range obj {
+		filtered[k] = v
+	}
+	if kept == nil {
+		kept = []interface{}{}
+	}
+	filtered["histories"] = kept
+	filtered["persistedFields"] = fields
+	return filtered
 }
@@ -0,0 +1,141 @@
+package jira
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// bulkChangelog has two status transitions logged in the same millisecond,
+// stored in the reverse of their history id order.
+func bulkChangelog() Changelog {
+	return Changelog{Histories: []HistoryEntry{
+		{ID: "1002", Created: "2025-03-01T10:00:00.000+0000", Items: []HistoryItem{{Field: "status", FromString: "In Progress", ToString: "Review"}}},
+		{ID: "999", Created: "2025-03-01T10:00:00.000+0000", Items: []HistoryItem{{Field: "status", FromString: "New", ToString: "In Progress"}}},
+		{ID: "900", Created: "2025-02-28T09:00:00.000+0000", Items: []HistoryItem{{Field: "assignee", ToString: "alice"}}},
+	}}
+}
+
+func historyIDs(c Changelog) []string {
+	var ids []string
+	for _, h := range c.Histories {
+		ids = append(ids, h.ID)
+	}
+	return ids
+}
+
+func equalIDs(t *testing.T, got, want []string) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got ids %v, want %v", got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("got ids %v, want %v", got, want)
+		}
+	}
+}
+
+func TestSortHistoriesBreaksTiesByID(t *testing.T) {
+	c := bulkChangelog()
+	c.SortHistories()
+	// 999 sorts before 1002 numerically, not lexically.
+	equalIDs(t, historyIDs(c), []string{"900", "999", "1002"})
+}
+
+func TestSortHistoriesIsIndependentOfInputOrder(t *testing.T) {
+	orders := [][]int{{0, 1, 2}, {1, 0, 2}, {2, 1, 0}, {1, 2, 0}}
+	base := bulkChangelog()
+	for _, order := range orders {
+		var c Changelog
+		for _, i := range order {
+			c.Histories = append(c.Histories, base.Histories[i])
+		}
+		c.SortHistories()
+		equalIDs(t, historyIDs(c), []string{"900", "999", "1002"})
+	}
+}
+
+func TestSortHistoriesEqualInstantsAcrossZones(t *testing.T) {
+	c := Changelog{Histories: []HistoryEntry{
+		{ID: "20", Created: "2025-03-01T12:00:00.000+0200"},
+		{ID: "10", Created: "2025-03-01T10:00:00.000+0000"},
+	}}
+	c.SortHistories()
+	equalIDs(t, historyIDs(c), []string{"10", "20"})
+}
+
+func TestSortHistoriesWithoutIDsKeepsFileOrder(t *testing.T) {
+	c := Changelog{Histories: []HistoryEntry{
+		{ID: "7", Created: "2025-03-01T10:00:00.000+0000"},
+		{Created: "2025-03-01T10:00:00.000+0000", Items: []HistoryItem{{Field: "a"}}},
+		{Created: "not a time"},
+		{Created: "2025-03-01T10:00:00.000+0000", Items: []HistoryItem{{Field: "b"}}},
+	}}
+	c.SortHistories()
+	if c.Histories[0].Items[0].Field != "a" || c.Histories[1].Items[0].Field != "b" {
+		t.Fatalf("histories without ids were reordered: %+v", c.Histories)
+	}
+	if c.Histories[2].ID != "7" {
+		t.Fatalf("history with id should follow those without at the same instant: %+v", c.Histories)
+	}
+	if c.Histories[3].Created != "not a time" {
+		t.Fatalf("unparsable timestamps should sort last: %+v", c.Histories)
+	}
+}
+
+func TestValueAtUsesLastTransitionOfBulkUpdate(t *testing.T) {
+	at := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	value, ok := ValueAt(bulkChangelog(), "status", at)
+	if !ok || value != "Review" {
+		t.Fatalf("ValueAt = %q, %v; want Review", value, ok)
+	}
+	value, ok = ValueAt(bulkChangelog(), "status", at.Add(-time.Millisecond))
+	if !ok || value != "New" {
+		t.Fatalf("ValueAt before bulk update = %q, %v; want New", value, ok)
+	}
+}
+
+func TestInitialTimeEffortIsTheOriginalEstimate(t *testing.T) {
+	estimate := 4 * 3600
+	issue := JiraIssueWithSprints{Key: "DEMO-1"}
+	issue.Fields.TimeOriginalEstimate = &estimate
+	if got := EffortTime.InitialEffort(issue, Changelog{}); got != 4 {
+		t.Fatalf("InitialEffort without changes = %v, want the current 4 hours", got)
+	}
+	changelog := Changelog{Histories: []HistoryEntry{
+		{ID: "1", Created: "2025-03-01T10:00:00.000+0000", Items: []HistoryItem{{Field: "timeoriginalestimate", FromString: "7200", ToString: "10800"}}},
+		{ID: "2", Created: "2025-03-02T10:00:00.000+0000", Items: []HistoryItem{{Field: "timeoriginalestimate", FromString: "10800", ToString: "14400"}}},
+	}}
+	if got := EffortTime.InitialEffort(issue, changelog); got != 2 {
+		t.Fatalf("InitialEffort = %v, want the 2 hours first estimated", got)
+	}
+	changelog.Histories[0].Items[0].FromString = ""
+	if got := EffortTime.InitialEffort(issue, changelog); got != 0 {
+		t.Fatalf("InitialEffort before any estimate = %v, want 0", got)
+	}
+}
+
+func TestChangelogLoadersSortHistories(t *testing.T) {
+	dir := t.TempDir()
+	data := `{"histories": [
+		{"id": "1002", "created": "2025-03-01T10:00:00.000+0000", "items": []},
+		{"id": "999", "created": "2025-03-01T10:00:00.000+0000", "items": []}
+	]}`
+	if err := os.WriteFile(filepath.Join(dir, "ABC-1.changelog.json"), []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := GetIssueChangelogFromCache(dir, "ABC-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	equalIDs(t, historyIDs(c), []string{"999", "1002"})
+
+	c, err = (&DirStore{Dir: dir}).ReadChangelog("ABC-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	equalIDs(t, historyIDs(c), []string{"999", "1002"})
+}
@@ -0,0 +1,271 @@
+package jira
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// Keys of the high-water mark stored in a raw changelog saved by an
+// incremental fetch: the number of histories Jira held when it was last
+// fetched, before any field filter, and the id of the newest of them.
+const (
+	changelogNextKey   = "nextStartAt"
+	changelogLastIDKey = "lastHistoryId"
+)
+
+// RawChangelogReader is implemented by stores that can return a changelog
+// as saved, which incremental fetches extend.
+type RawChangelogReader interface {
+	ReadRawChangelog(key string) ([]byte, error)
+}
+
+func (s *DirStore) ReadRawChangelog(key string) ([]byte, error) {
+	return s.readFile(fmt.Sprintf("%s.changelog.json", key))
+}
+
+func (s *SQLiteStore) ReadRawChangelog(key string) ([]byte, error) {
+	var data []byte
+	err := s.db.QueryRow(`SELECT data FROM changelogs WHERE key = ?`, key).Scan(&data)
+	return data, err
+}
+
+// changelogPageSize is the maxResults of changelog endpoint requests.
+const changelogPageSize = 100
+
+// FetchIssue returns the raw issue without its changelog, or a MovedError
+// when Jira answers with an issue of another key.
+func (c *Client) FetchIssue(ctx context.Context, issueKey string) (map[string]interface{}, error) {
+	body, err := c.Get(ctx, c.apiURL(ctx, "/issue/%s", issueKey))
+	if err != nil {
+		return nil, fmt.Errorf("fetch failed: %w", err)
+	}
+	var issueData map[string]interface{}
+	if err := json.Unmarshal(body, &issueData); err != nil {
+		return nil, fmt.Errorf("parse json: %w", err)
+	}
+	if err := checkMoved(issueKey, issueData); err != nil {
+		return nil, err
+	}
+	delete(issueData, "changelog")
+	c.convertADF(ctx, issueData)
+	return issueData, nil
+}
+
+// FetchChangelog pages through the changelog endpoint from startAt,
+// returning the raw histories oldest first and Jira's total.
+func (c *Client) FetchChangelog(ctx context.Context, issueKey string, startAt int) ([]interface{}, int, error) {
+	var histories []interface{}
+	for {
+		reqURL := c.apiURL(ctx, "/issue/%s/changelog?startAt=%d&maxResults=%d", issueKey, startAt, changelogPageSize)
+		body, err := c.Get(ctx, reqURL)
+		if err != nil {
+			return nil, 0, err
+		}
+		var page struct {
+			Total  int           `json:"total"`
+			IsLast bool          `json:"isLast"`
+			Values []interface{} `json:"values"`
+		}
+		if err := json.Unmarshal(body, &page); err != nil {
+			return nil, 0, fmt.Errorf("parse changelog page: %w", err)
+		}
+		histories = append(histories, page.Values...)
+		startAt += len(page.Values)
+		if page.IsLast || len(page.Values) == 0 || startAt >= page.Total {
+			return histories, page.Total, nil
+		}
+	}
+}
+
+// completeChangelog fetches the histories missing from the changelog Jira
+// embedded in an issue, which it caps at 100, through the changelog
+// endpoint. Jira Server embeds the oldest histories and only the rest are
+// fetched; Jira Cloud embeds the newest and the whole changelog is. Where
+// the endpoint is missing the changelog is left short, with a warning.
+func (c *Client) completeChangelog(ctx context.Context, issueKey string, changelog map[string]interface{}) error {
+	histories, _ := changelog["histories"].([]interface{})
+	total, _ := changelog["total"].(float64)
+	if int(total) <= len(histories) {
+		return nil
+	}
+	if changelogEndpointMissing.Load() {
+		slog.Warn("changelog truncated by Jira", "key", issueKey, "histories", len(histories), "total", int(total))
+		return nil
+	}
+	var rest []interface{}
+	var err error
+	if startAt, _ := changelog["startAt"].(float64); startAt == 0 {
+		rest, _, err = c.FetchChangelog(ctx, issueKey, len(histories))
+		rest = append(histories, rest...)
+	} else {
+		rest, _, err = c.FetchChangelog(ctx, issueKey, 0)
+	}
+	if IsStatus(err, 404) {
+		log.Printf("changelog endpoint not available; changelogs longer than Jira embeds stay truncated")
+		changelogEndpointMissing.Store(true)
+		slog.Warn("changelog truncated by Jira", "key", issueKey, "histories", len(histories), "total", int(total))
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	slog.Debug("paged changelog beyond the embedded histories", "key", issueKey, "embedded", len(histories), "histories", len(rest))
+	changelog["histories"] = rest
+	changelog["startAt"] = 0
+	changelog["maxResults"] = len(rest)
+	changelog["total"] = max(int(total), len(rest))
+	return nil
+}
+
+func historyID(h interface{}) string {
+	entry, _ := h.(map[string]interface{})
+	id, _ := entry["id"].(string)
+	return id
+}
+
+// markChangelog records the high-water mark of a raw changelog whose
+// unfiltered histories are all, numbering next.
+func markChangelog(changelog map[string]interface{}, all []interface{}, next int) {
+	last := ""
+	for _, h := range all {
+		if id := historyID(h); historySeq(HistoryEntry{ID: id}) > historySeq(HistoryEntry{ID: last}) {
+			last = id
+		}
+	}
+	changelog[changelogNextKey] = next
+	if last != "" {
+		changelog[changelogLastIDKey] = last
+	}
+}
+
+// sameFields reports whether a saved changelog was filtered to the fields
+// asked for now, so new histories can be appended to it.
+func sameFields(saved interface{}, fields []string) bool {
+	list, _ := saved.([]interface{})
+	if len(list) != len(fields) {
+		return false
+	}
+	a := make([]string, len(fields))
+	b := make([]string, len(fields))
+	for i := range fields {
+		s, _ := list[i].(string)
+		a[i], b[i] = strings.ToLower(s), strings.ToLower(fields[i])
+	}
+	sort.Strings(a)
+	sort.Strings(b)
+	return strings.Join(a, "\x00") == strings.Join(b, "\x00")
+}
+
+// changelogEndpointMissing is set once Jira answers 404 for the changelog
+// endpoint, which older Jira Server releases lack.
+var changelogEndpointMissing atomic.Bool
+
+// syncIssueIncremental fetches an issue and only the changelog histories
+// newer than the high-water mark of its saved changelog, appending them.
+// It falls back to a full fetch when there is no usable mark, when the
+// history at the mark is not the one recorded (histories were deleted),
+// or when Jira lacks the changelog endpoint.
+func (c *Client) syncIssueIncremental(ctx context.Context, store Store, key string, fields []string) error {
+	saved := c.savedChangelog(store, key, fields)
+	if saved == nil || changelogEndpointMissing.Load() {
+		return c.syncIssueFull(ctx, store, key, fields)
+	}
+	next := int(saved[changelogNextKey].(float64))
+	lastID, _ := saved[changelogLastIDKey].(string)
+
+	issue, err := c.FetchIssue(ctx, key)
+	if err != nil {
+		return err
+	}
+	// Refetch the newest known history to check the mark still holds.
+	startAt := next
+	if next > 0 {
+		startAt = next - 1
+	}
+	histories, total, err := c.FetchChangelog(ctx, key, startAt)
+	if IsStatus(err, 404) {
+		log.Printf("changelog endpoint not available, fetching whole changelogs")
+		changelogEndpointMissing.Store(true)
+		return c.syncIssueFull(ctx, store, key, fields)
+	}
+	if err != nil {
+		return err
+	}
+	if next > 0 {
+		if len(histories) == 0 || historyID(histories[0]) != lastID {
+			log.Printf("changelog of %s changed before its high-water mark, fetching it whole", key)
+			return c.syncIssueFull(ctx, store, key, fields)
+		}
+		histories = histories[1:]
+	}
+
+	existing, _ := saved["histories"].([]interface{})
+	fresh := map[string]interface{}{"histories": histories}
+	filtered, _ := FilterChangelog(fresh, fields).(map[string]interface{})
+	added, _ := filtered["histories"].([]interface{})
+	saved["histories"] = append(existing, added...)
+	saved["total"] = total
+	if len(histories) > 0 {
+		markChangelog(saved, histories, next+len(histories))
+	}
+	if len(added) > 0 {
+		slog.Debug("appended changelog histories", "key", key, "histories", len(added))
+	}
+	return store.SaveIssue(key, issue, saved)
+}
+
+// savedChangelog returns the saved raw changelog of an issue when it has
+// a high-water mark and was filtered the same way, otherwise nil.
+func (c *Client) savedChangelog(store Store, key string, fields []string) map[string]interface{} {
+	reader, ok := store.(RawChangelogReader)
+	if !ok {
+		return nil
+	}
+	data, err := reader.ReadRawChangelog(key)
+	if err != nil {
+		return nil
+	}
+	var saved map[string]interface{}
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil
+	}
+	if _, ok := saved[changelogNextKey].(float64); !ok {
+		return nil
+	}
+	if !sameFields(saved["persistedFields"], fields) {
+		return nil
+	}
+	return saved
+}
+
+// syncIssueFull fetches an issue with its whole changelog and records the
+// high-water mark later incremental fetches start from.
+func (c *Client) syncIssueFull(ctx context.Context, store Store, key string, fields []string) error {
+	issue, changelog, err := c.FetchIssueWithChangelog(ctx, key)
+	if err != nil {
+		return err
+	}
+	raw, ok := changelog.(map[string]interface{})
+	if !ok {
+		return store.SaveIssue(key, issue, changelog)
+	}
+	all, _ := raw["histories"].([]interface{})
+	out, _ := FilterChangelog(raw, fields).(map[string]interface{})
+	if out == nil {
+		out = raw
+	}
+	// A changelog left truncated, for lack of the changelog endpoint,
+	// still ends at the total.
+	next := len(all)
+	if total, ok := raw["total"].(float64); ok && int(total) > next {
+		next = int(total)
+	}
+	markChangelog(out, all, next)
+	return store.SaveIssue(key, issue, out)
+}
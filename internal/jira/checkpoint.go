@@ -0,0 +1,131 @@
+package jira
+
+import (
+	"errors"
+	"log"
+	"net"
+	"time"
+)
+
+// maxUnreachable is how many issues in a row may fail to reach Jira before
+// a sync gives up and checkpoints, instead of burning through the rest of
+// its queue while the network is down.
+const maxUnreachable = 10
+
+// SyncCheckpoint records where an interrupted sync stopped, so a run with
+// SyncOptions.Resume can pick up there instead of starting over.
+type SyncCheckpoint struct {
+	Phase string `json:"phase"`
+	// LastKey is the issue being fetched when the sync stopped.
+	LastKey string `json:"last_key,omitempty"`
+	// Pending are the issues of the phase not yet fetched, LastKey first.
+	Pending []string `json:"pending"`
+	At      string   `json:"at"`
+	Reason  string   `json:"reason,omitempty"`
+}
+
+// syncPhases are the phases of SyncProject in the order they run.
+var syncPhases = []string{SyncPhaseUpdated, SyncPhaseBackfill, SyncPhaseReconcile, SyncPhaseDenied, SyncPhaseForce, SyncPhaseSmart, SyncPhaseSprint}
+
+func phaseIndex(phase string) int {
+	for i, p := range syncPhases {
+		if p == phase {
+			return i
+		}
+	}
+	return -1
+}
+
+// loadCheckpoint reads the checkpoint of a previous run. With Resume it is
+// kept to resume from; otherwise the run starts over and replaces it.
+func (s *syncer) loadCheckpoint(stateKey string) {
+	s.stateKey = stateKey
+	state, err := s.store.ReadSyncState(stateKey)
+	if err != nil {
+		log.Printf("read checkpoint: %v", err)
+		return
+	}
+	c := state.Checkpoint
+	switch {
+	case c == nil && s.opts.Resume:
+		log.Printf("no checkpoint to resume for %s; running a full sync", stateKey)
+	case c == nil:
+	case s.opts.Resume:
+		log.Printf("resuming %s in the %s phase at %s with %d issues pending", stateKey, c.Phase, c.LastKey, len(c.Pending))
+		s.resumeFrom = c
+	default:
+		log.Printf("a previous sync of %s stopped in the %s phase at %s with %d issues pending; --resume continues from there", stateKey, c.Phase, c.LastKey, len(c.Pending))
+	}
+}
+
+// skipPhase reports whether a phase ran to completion before the run being
+// resumed stopped.
+func (s *syncer) skipPhase(phase string) bool {
+	if s.resumeFrom == nil {
+		return false
+	}
+	at, i := phaseIndex(s.resumeFrom.Phase), phaseIndex(phase)
+	return at >= 0 && i >= 0 && i < at
+}
+
+// resumeKeys returns the keys a phase fetches: the pending queue of the
+// checkpoint for the phase it stopped in, none for phases it had finished.
+func (s *syncer) resumeKeys(phase string, keys []string) []string {
+	if s.resumeFrom == nil {
+		return keys
+	}
+	if s.skipPhase(phase) {
+		return nil
+	}
+	if s.resumeFrom.Phase == phase {
+		keys = s.resumeFrom.Pending
+		s.resumeFrom = nil
+	}
+	return keys
+}
+
+// saveCheckpoint records the pending keys of a phase that stopped early.
+func (s *syncer) saveCheckpoint(phase string, pending []string, reason error) {
+	if s.stateKey == "" || len(pending) == 0 {
+		return
+	}
+	state, err := s.store.ReadSyncState(s.stateKey)
+	if err != nil {
+		log.Printf("read sync state: %v", err)
+		return
+	}
+	state.Checkpoint = &SyncCheckpoint{
+		Phase:   phase,
+		LastKey: pending[0],
+		Pending: pending,
+		At:      time.Now().UTC().Format(time.RFC3339),
+	}
+	if reason != nil {
+		state.Checkpoint.Reason = reason.Error()
+	}
+	if err := s.store.SaveSyncState(s.stateKey, state); err != nil {
+		log.Printf("save checkpoint: %v", err)
+		return
+	}
+	log.Printf("checkpointed %s in the %s phase at %s with %d issues pending; rerun with --resume", s.stateKey, phase, pending[0], len(pending))
+}
+
+// clearCheckpoint drops the checkpoint once a sync has run to completion.
+func (s *syncer) clearCheckpoint() error {
+	if s.stateKey == "" {
+		return nil
+	}
+	state, err := s.store.ReadSyncState(s.stateKey)
+	if err != nil || state.Checkpoint == nil {
+		return err
+	}
+	state.Checkpoint = nil
+	return s.store.SaveSyncState(s.stateKey, state)
+}
+
+// isUnreachable reports whether err means Jira could not be reached at all,
+// as opposed to answering with an error.
+func isUnreachable(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
@@ -0,0 +1,150 @@
+package jira
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// CategoryField is the pseudo field name under which rule categories are
+// exposed to extractors and queries.
+const CategoryField = "category"
+
+type RuleCondition struct {
+	Field  string `json:"field"`
+	Regex  string `json:"regex,omitempty"`
+	Equals string `json:"equals,omitempty"`
+
+	pattern *regexp.Regexp
+}
+
+// Rule tags an issue with Category when its conditions match. Match is
+// "all" (default) or "any".
+type Rule struct {
+	Category   string          `json:"category"`
+	Match      string          `json:"match,omitempty"`
+	Conditions []RuleCondition `json:"conditions"`
+}
+
+type Classifier struct {
+	Rules      []Rule
+	extractors Extractors
+}
+
+// LoadClassifier reads a JSON rules file of the form
+// {"rules": [{"category": "CVE", "conditions": [{"field": "summary", "regex": "CVE-\\d+-\\d+"}]}]}.
+// Condition fields are resolved through the given extractors so custom
+// fields can be used by name.
+func LoadClassifier(path string, extractors Extractors) (*Classifier, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read rules: %w", err)
+	}
+	var cfg struct {
+		Rules []Rule `json:"rules"`
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse rules %s: %w", path, err)
+	}
+	return NewClassifier(cfg.Rules, extractors)
+}
+
+func NewClassifier(rules []Rule, extractors Extractors) (*Classifier, error) {
+	if extractors == nil {
+		extractors = DefaultExtractors()
+	}
+	for i := range rules {
+		r := &rules[i]
+		if r.Category == "" {
+			return nil, fmt.Errorf("rule %d has no category", i+1)
+		}
+		if r.Match == "" {
+			r.Match = "all"
+		}
+		if r.Match != "all" && r.Match != "any" {
+			return nil, fmt.Errorf("rule %s: match must be all or any", r.Category)
+		}
+		for j := range r.Conditions {
+			c := &r.Conditions[j]
+			if _, ok := extractors.Lookup(c.Field); !ok && c.Field != "description" {
+				return nil, fmt.Errorf("rule %s: unknown field %q", r.Category, c.Field)
+			}
+			if c.Regex != "" {
+				pattern, err := regexp.Compile(c.Regex)
+				if err != nil {
+					return nil, fmt.Errorf("rule %s: %w", r.Category, err)
+				}
+				c.pattern = pattern
+			}
+		}
+	}
+	return &Classifier{Rules: rules, extractors: extractors}, nil
+}
+
+func (c *Classifier) conditionMatches(rc RuleCondition, issue JiraIssueWithSprints) bool {
+	var text string
+	if rc.Field == "description" {
+		text = issue.Fields.Description
+	} else if fe, ok := c.extractors.Lookup(rc.Field); ok {
+		value, present := fe.Extract(issue.Fields)
+		if !present {
+			return false
+		}
+		text = value.Text
+	}
+
+	if rc.Equals != "" {
+		for _, part := range strings.Split(text, ",") {
+			if strings.EqualFold(part, rc.Equals) {
+				return true
+			}
+		}
+		return false
+	}
+	if rc.pattern != nil {
+		return rc.pattern.MatchString(text)
+	}
+	return text != ""
+}
+
+// Categories returns the sorted categories whose rules match the issue.
+func (c *Classifier) Categories(issue JiraIssueWithSprints) []string {
+	seen := map[string]bool{}
+	for _, r := range c.Rules {
+		if len(r.Conditions) == 0 {
+			continue
+		}
+		matched := r.Match == "all"
+		for _, rc := range r.Conditions {
+			ok := c.conditionMatches(rc, issue)
+			if r.Match == "any" && ok {
+				matched = true
+				break
+			}
+			if r.Match == "all" && !ok {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			seen[r.Category] = true
+		}
+	}
+
+	var categories []string
+	for category := range seen {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	return categories
+}
+
+// Apply tags every issue in place with its categories.
+func (c *Classifier) Apply(issues []JiraIssueWithSprints) {
+	for i := range issues {
+		issues[i].Fields.Categories = c.Categories(issues[i])
+	}
+}
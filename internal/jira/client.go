@@ -0,0 +1,516 @@
+package jira
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// StatusError is returned for non-200 responses from Jira.
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+// maxErrorBody is how much of a response body an error message quotes;
+// error pages of proxies and Jira itself run to kilobytes of HTML.
+const maxErrorBody = 200
+
+func (e *StatusError) Error() string {
+	if e.StatusCode == 404 {
+		return "resource not found (404)"
+	}
+	body := strings.Join(strings.Fields(e.Body), " ")
+	if len(body) > maxErrorBody {
+		body = body[:maxErrorBody] + "..."
+	}
+	return fmt.Sprintf("unexpected status %d: %s", e.StatusCode, body)
+}
+
+// IsStatus reports whether err wraps a StatusError with the given code.
+func IsStatus(err error, code int) bool {
+	var statusErr *StatusError
+	return errors.As(err, &statusErr) && statusErr.StatusCode == code
+}
+
+// Client talks to a single Jira instance. HTTPClient carries proxy, TLS
+// and instrumentation through its Transport.
+type Client struct {
+	BaseURL    string
+	Token      string
+	HTTPClient *http.Client
+	// Auth, when set, replaces the Bearer Token.
+	Auth Authenticator
+	// Limiter paces requests; when nil they are spaced MinInterval apart.
+	// New clients share DefaultLimiter.
+	Limiter RateLimiter
+	// MinInterval is the least time between two requests.
+	MinInterval time.Duration
+	// Retry decides which failed requests are retried; the zero value
+	// means DefaultRetryPolicy.
+	Retry RetryPolicy
+	// Audit, when set, records every request attempt.
+	Audit *AuditLog
+	// RequestTimeout bounds each attempt of a request, including reading
+	// the response; zero means no limit beyond the context.
+	RequestTimeout time.Duration
+	// APIVersion selects the REST API: APIVersion2, APIVersion3 or
+	// APIVersionAuto to ask the server. Empty uses 3 for Atlassian Cloud
+	// sites and 2 otherwise.
+	APIVersion string
+	// ADFFormat is what the ADF documents API v3 returns are converted to
+	// before caching: ADFMarkdown or ADFText.
+	ADFFormat string
+
+	mu        sync.Mutex
+	clockSkew time.Duration
+	skewKnown bool
+	interval  *IntervalLimiter
+
+	apiMu      sync.Mutex
+	negotiated string
+
+	requests atomic.Int64
+}
+
+// Versions of the Jira REST API. Server and Data Center speak 2; Cloud
+// speaks both, but returns rich text as ADF and pages searches by token
+// in 3, and is retiring the search endpoint of 2.
+const (
+	APIVersion2    = "2"
+	APIVersion3    = "3"
+	APIVersionAuto = "auto"
+)
+
+// APIVersions lists the accepted values of Client.APIVersion.
+var APIVersions = []string{APIVersion2, APIVersion3, APIVersionAuto}
+
+// IsCloudURL reports whether a base URL is an Atlassian Cloud site.
+func IsCloudURL(baseURL string) bool {
+	u, err := url.Parse(baseURL)
+	return err == nil && strings.HasSuffix(strings.ToLower(u.Hostname()), ".atlassian.net")
+}
+
+// API returns the REST API version requests use. With APIVersionAuto the
+// deployment type in serverInfo decides, asked once per client; when the
+// server cannot be asked, version 2 is used.
+func (c *Client) API(ctx context.Context) string {
+	switch c.APIVersion {
+	case APIVersion2, APIVersion3:
+		return c.APIVersion
+	case "":
+		if IsCloudURL(c.BaseURL) {
+			return APIVersion3
+		}
+		return APIVersion2
+	}
+	c.apiMu.Lock()
+	defer c.apiMu.Unlock()
+	if c.negotiated != "" {
+		return c.negotiated
+	}
+	version, err := c.negotiate(ctx)
+	if err != nil {
+		if ctx.Err() != nil {
+			return APIVersion2
+		}
+		log.Printf("could not tell the Jira deployment type, using API v%s: %v", version, err)
+	}
+	c.negotiated = version
+	slog.Debug("negotiated API version", "version", version)
+	return version
+}
+
+func (c *Client) negotiate(ctx context.Context) (string, error) {
+	body, err := c.Get(ctx, c.BaseURL+"/rest/api/2/serverInfo")
+	if err != nil {
+		return APIVersion2, err
+	}
+	var info struct {
+		DeploymentType string `json:"deploymentType"`
+	}
+	if err := json.Unmarshal(body, &info); err != nil {
+		return APIVersion2, fmt.Errorf("parse serverInfo: %w", err)
+	}
+	if strings.EqualFold(info.DeploymentType, "Cloud") {
+		return APIVersion3, nil
+	}
+	return APIVersion2, nil
+}
+
+// apiURL formats the URL of a REST API resource, e.g.
+// c.apiURL(ctx, "/issue/%s", key), in the version the client speaks.
+func (c *Client) apiURL(ctx context.Context, format string, args ...interface{}) string {
+	return c.BaseURL + "/rest/api/" + c.API(ctx) + fmt.Sprintf(format, args...)
+}
+
+// convertADF replaces the ADF documents of a decoded API v3 response by
+// text in the client's ADFFormat; responses of API v2 hold none.
+func (c *Client) convertADF(ctx context.Context, v interface{}) interface{} {
+	if c.API(ctx) != APIVersion3 {
+		return v
+	}
+	return convertADF(v, c.ADFFormat)
+}
+
+// decode unmarshals a response into v, converting ADF documents first.
+func (c *Client) decode(ctx context.Context, body []byte, v interface{}) error {
+	if c.API(ctx) != APIVersion3 {
+		return json.Unmarshal(body, v)
+	}
+	var raw interface{}
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return err
+	}
+	converted, err := json.Marshal(convertADF(raw, c.ADFFormat))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(converted, v)
+}
+
+// ClockSkew returns the server clock minus the local clock as seen in the
+// Date header of the most recent response.
+func (c *Client) ClockSkew() (time.Duration, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.clockSkew, c.skewKnown
+}
+
+func (c *Client) observeDate(header string, sent, received time.Time) {
+	serverTime, err := http.ParseTime(header)
+	if err != nil {
+		return
+	}
+	// the header has second precision and was generated somewhere between
+	// sending and receiving, so compare against the midpoint
+	local := sent.Add(received.Sub(sent) / 2).Truncate(time.Second)
+	c.mu.Lock()
+	c.clockSkew = serverTime.Sub(local)
+	c.skewKnown = true
+	c.mu.Unlock()
+}
+
+func (c *Client) limiter() RateLimiter {
+	if c.Limiter != nil {
+		return c.Limiter
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.interval == nil || c.interval.Interval != c.MinInterval {
+		c.interval = &IntervalLimiter{Interval: c.MinInterval}
+	}
+	return c.interval
+}
+
+func (c *Client) retry() RetryPolicy {
+	if c.Retry.MaxAttempts == 0 {
+		return DefaultRetryPolicy
+	}
+	return c.Retry
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return DefaultHTTPClient
+}
+
+func (c *Client) authenticator() Authenticator {
+	if c.Auth != nil {
+		return c.Auth
+	}
+	return BearerAuth{Token: c.Token}
+}
+
+// DefaultMinInterval is the MinInterval of new clients, which only applies
+// to those whose Limiter is cleared.
+var DefaultMinInterval = 500 * time.Millisecond
+
+// DefaultRequestTimeout is the RequestTimeout of new clients.
+var DefaultRequestTimeout = 2 * time.Minute
+
+// DefaultAPIVersion and DefaultADFFormat are the APIVersion and ADFFormat
+// of new clients.
+var (
+	DefaultAPIVersion = ""
+	DefaultADFFormat  = ADFMarkdown
+)
+
+func NewClient(baseURL, token string) *Client {
+	return &Client{
+		BaseURL:        baseURL,
+		Token:          token,
+		HTTPClient:     DefaultHTTPClient,
+		Limiter:        DefaultLimiter,
+		MinInterval:    DefaultMinInterval,
+		Retry:          DefaultRetryPolicy,
+		RequestTimeout: DefaultRequestTimeout,
+		Audit:          DefaultAuditLog,
+		APIVersion:     DefaultAPIVersion,
+		ADFFormat:      DefaultADFFormat,
+	}
+}
+
+func (c *Client) audit(url string, sent time.Time, status int, bytes int64, attempt int) {
+	if err := c.Audit.Record(auditEntry(url, sent, status, bytes, attempt)); err != nil {
+		log.Printf("audit: %v", err)
+	}
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// send makes one attempt at an authenticated GET and reads the whole
+// response, giving up after RequestTimeout when it is set.
+func (c *Client) send(ctx context.Context, url string) (*http.Response, []byte, time.Time, error) {
+	if err := c.limiter().Wait(ctx); err != nil {
+		return nil, nil, time.Time{}, err
+	}
+	if c.RequestTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.RequestTimeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, nil, time.Time{}, fmt.Errorf("failed to create request: %w", err)
+	}
+	if err := c.authenticator().Authorize(ctx, req); err != nil {
+		return nil, nil, time.Time{}, fmt.Errorf("authorize request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	sent := time.Now()
+	c.requests.Add(1)
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return nil, nil, sent, fmt.Errorf("request error: %w", err)
+	}
+	defer resp.Body.Close()
+	c.observeDate(resp.Header.Get("Date"), sent, time.Now())
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp, nil, sent, fmt.Errorf("error reading response: %w", err)
+	}
+	return resp, body, sent, nil
+}
+
+// Requests counts the request attempts the client has sent, retries
+// included.
+func (c *Client) Requests() int64 {
+	return c.requests.Load()
+}
+
+// Get performs an authenticated GET, retrying as the Retry policy allows.
+func (c *Client) Get(ctx context.Context, url string) ([]byte, error) {
+	reauthenticated := false
+	retry := c.retry()
+	attempts := retry.attempts()
+	for attempt := 1; attempt <= attempts; attempt++ {
+		slog.Debug("GET", "url", url, "attempt", attempt)
+		resp, body, sent, err := c.send(ctx, url)
+		if resp == nil {
+			if !sent.IsZero() {
+				c.audit(url, sent, 0, 0, attempt)
+			}
+			return nil, err
+		}
+		if resp.StatusCode != 200 {
+			c.audit(url, sent, resp.StatusCode, 0, attempt)
+		}
+		if retry.Retries(resp.StatusCode) && attempt < attempts {
+			delay := retry.Delay(attempt, resp.Header)
+			slog.Warn("request failed, retrying", "url", url, "status", resp.StatusCode, "attempt", attempt, "sleep", delay)
+			if err := sleepContext(ctx, delay); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if re, ok := c.authenticator().(Reauthenticator); ok && resp.StatusCode == 401 && !reauthenticated {
+			slog.Warn("access token rejected, reauthenticating")
+			re.Invalidate()
+			reauthenticated = true
+			// The retry with fresh credentials does not use up an attempt,
+			// so a token expiring on the last one is still recovered.
+			attempts++
+			continue
+		}
+
+		if resp.StatusCode != 200 {
+			slog.Debug("error response", "url", url, "status", resp.StatusCode, "body", string(body))
+			return nil, &StatusError{StatusCode: resp.StatusCode, Body: string(body)}
+		}
+
+		c.audit(url, sent, resp.StatusCode, int64(len(body)), attempt)
+		if err != nil {
+			return nil, err
+		}
+		return body, nil
+	}
+
+	return nil, fmt.Errorf("exceeded retries for GET %s", url)
+}
+
+// searchPage is one page of search results, in either API version.
+type searchPage struct {
+	StartAt       int               `json:"startAt"`
+	MaxResults    int               `json:"maxResults"`
+	Total         int               `json:"total"`
+	Issues        []json.RawMessage `json:"issues"`
+	NextPageToken string            `json:"nextPageToken"`
+	IsLast        bool              `json:"isLast"`
+}
+
+// Search pages through the issues matching a JQL query, asking for the
+// given comma separated fields, and calls page with the raw issues of each
+// page until it returns false or the results end. An empty page ends the
+// results without a call. API v2 pages by startAt and total, API v3 by
+// nextPageToken.
+func (c *Client) Search(ctx context.Context, jql, fields string, pageSize int, page func(issues []json.RawMessage) (bool, error)) error {
+	startAt := 0
+	token := ""
+	for {
+		var reqURL string
+		if c.API(ctx) == APIVersion3 {
+			reqURL = c.apiURL(ctx, "/search/jql?jql=%s&fields=%s&maxResults=%d", url.QueryEscape(jql), fields, pageSize)
+			if token != "" {
+				reqURL += "&nextPageToken=" + url.QueryEscape(token)
+			}
+		} else {
+			reqURL = c.apiURL(ctx, "/search?jql=%s&fields=%s&startAt=%d&maxResults=%d", url.QueryEscape(jql), fields, startAt, pageSize)
+		}
+
+		body, err := c.Get(ctx, reqURL)
+		if err != nil {
+			return fmt.Errorf("search failed: %w", err)
+		}
+		var result searchPage
+		if err := json.Unmarshal(body, &result); err != nil {
+			return fmt.Errorf("failed to parse search response: %w", err)
+		}
+		slog.Debug("search page", "issues", len(result.Issues), "start_at", startAt, "total", result.Total, "next_page_token", result.NextPageToken)
+
+		if len(result.Issues) == 0 {
+			return nil
+		}
+		more, err := page(result.Issues)
+		if err != nil || !more {
+			return err
+		}
+		startAt += len(result.Issues)
+		if c.API(ctx) == APIVersion3 {
+			if result.IsLast || result.NextPageToken == "" {
+				return nil
+			}
+			token = result.NextPageToken
+		} else if startAt >= result.Total {
+			return nil
+		}
+	}
+}
+
+// HighestIssueKey returns the most recently created issue key in a project.
+func (c *Client) HighestIssueKey(ctx context.Context, project string) (string, error) {
+	key := ""
+	err := c.Search(ctx, JQL{}.Equals("project", project).OrderBy("created", Desc).String(), "key", 1, func(issues []json.RawMessage) (bool, error) {
+		var issue struct {
+			Key string `json:"key"`
+		}
+		if err := json.Unmarshal(issues[0], &issue); err != nil {
+			return false, fmt.Errorf("failed to parse response: %w", err)
+		}
+		key = issue.Key
+		return false, nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch latest issue: %w", err)
+	}
+	if key == "" {
+		return "", fmt.Errorf("no issues found in project %s", project)
+	}
+	return key, nil
+}
+
+// FetchIssueWithChangelog returns the raw issue with its whole changelog
+// split out, paging the histories Jira leaves out of the embedded one, or
+// a MovedError when Jira answers with an issue of another key.
+func (c *Client) FetchIssueWithChangelog(ctx context.Context, issueKey string) (map[string]interface{}, interface{}, error) {
+	body, err := c.Get(ctx, c.apiURL(ctx, "/issue/%s?expand=changelog", issueKey))
+	if err != nil {
+		return nil, nil, fmt.Errorf("fetch failed: %w", err)
+	}
+
+	var issueData map[string]interface{}
+	if err := json.Unmarshal(body, &issueData); err != nil {
+		return nil, nil, fmt.Errorf("parse json: %w", err)
+	}
+
+	if err := checkMoved(issueKey, issueData); err != nil {
+		return nil, nil, err
+	}
+
+	changelog := issueData["changelog"]
+	delete(issueData, "changelog")
+	if raw, ok := changelog.(map[string]interface{}); ok {
+		if err := c.completeChangelog(ctx, issueKey, raw); err != nil {
+			return nil, nil, fmt.Errorf("fetch changelog: %w", err)
+		}
+	}
+	c.convertADF(ctx, issueData)
+	return issueData, changelog, nil
+}
+
+// SearchIssueKeys pages through a JQL query returning keys and updated times.
+// When stop returns true for an issue, paging ends before that issue.
+func (c *Client) SearchIssueKeys(ctx context.Context, jql string, stop func(key string, updated time.Time) bool) ([]UpdatedIssue, error) {
+	var results []UpdatedIssue
+	err := c.Search(ctx, jql, "key,updated", 100, func(issues []json.RawMessage) (bool, error) {
+		for _, raw := range issues {
+			var issue struct {
+				Key    string `json:"key"`
+				Fields struct {
+					Updated string `json:"updated"`
+				} `json:"fields"`
+			}
+			if err := json.Unmarshal(raw, &issue); err != nil {
+				return false, fmt.Errorf("failed to parse search response: %w", err)
+			}
+			updated, err := time.Parse("2006-01-02T15:04:05.000-0700", issue.Fields.Updated)
+			if err != nil {
+				slog.Warn("could not parse updated time", "key", issue.Key, "err", err)
+				continue
+			}
+			if stop != nil && stop(issue.Key, updated) {
+				slog.Debug("stopping search early, issue already up to date", "key", issue.Key)
+				return false, nil
+			}
+			results = append(results, UpdatedIssue{
+				Key:         issue.Key,
+				UpdatedTime: updated,
+			})
+		}
+		return true, nil
+	})
+	return results, err
+}
@@ -0,0 +1,153 @@
+package jira
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+)
+
+type Comment struct {
+	ID      string `json:"id"`
+	Author  *User  `json:"author,omitempty"`
+	Body    string `json:"body"`
+	Created string `json:"created"`
+	Updated string `json:"updated"`
+}
+
+// CommentList is the content of {KEY}.comments.json. Fetched records when
+// the comments were last refreshed from Jira.
+type CommentList struct {
+	StartAt    int       `json:"startAt"`
+	MaxResults int       `json:"maxResults"`
+	Total      int       `json:"total"`
+	Comments   []Comment `json:"comments"`
+	Fetched    string    `json:"fetched,omitempty"`
+}
+
+// LatestUpdated returns the newest created or updated time of any comment,
+// or the zero time when there are no comments.
+func (l CommentList) LatestUpdated() time.Time {
+	var latest time.Time
+	for _, c := range l.Comments {
+		for _, s := range []string{c.Created, c.Updated} {
+			if t, err := ParseJiraTime(s); err == nil && t.After(latest) {
+				latest = t
+			}
+		}
+	}
+	return latest
+}
+
+// FetchedTime parses the refresh stamp of a cached comment list.
+func (l CommentList) FetchedTime() (time.Time, bool) {
+	t, err := time.Parse(time.RFC3339, l.Fetched)
+	return t, err == nil
+}
+
+// FetchComments pages through every comment on an issue.
+func (c *Client) FetchComments(ctx context.Context, issueKey string) (CommentList, error) {
+	var all CommentList
+	startAt := 0
+	pageSize := 100
+
+	for {
+		reqURL := c.apiURL(ctx, "/issue/%s/comment?startAt=%d&maxResults=%d&orderBy=created", issueKey, startAt, pageSize)
+		body, err := c.Get(ctx, reqURL)
+		if err != nil {
+			return all, fmt.Errorf("fetch comments failed: %w", err)
+		}
+
+		var page CommentList
+		if err := c.decode(ctx, body, &page); err != nil {
+			return all, fmt.Errorf("parse comments: %w", err)
+		}
+		all.Comments = append(all.Comments, page.Comments...)
+
+		startAt += len(page.Comments)
+		if startAt >= page.Total || len(page.Comments) == 0 {
+			break
+		}
+	}
+
+	all.MaxResults = len(all.Comments)
+	all.Total = len(all.Comments)
+	return all, nil
+}
+
+// commentsChanged reports how many comments were added or edited, and
+// whether any were deleted, between two comment lists.
+func commentsChanged(prev, next CommentList) (changed int, deleted bool) {
+	byID := make(map[string]string, len(prev.Comments))
+	for _, c := range prev.Comments {
+		byID[c.ID] = c.Updated
+	}
+	for _, c := range next.Comments {
+		if updated, ok := byID[c.ID]; !ok || updated != c.Updated {
+			changed++
+		}
+		delete(byID, c.ID)
+	}
+	return changed, len(byID) > 0
+}
+
+// SyncComments refreshes the cached comments of an issue. Comments are only
+// refetched when the issue was updated after the last refresh and after the
+// newest cached comment, and the cache is only rewritten when comments were
+// added, edited or deleted. It returns whether the cache was written.
+func (c *Client) SyncComments(ctx context.Context, store Store, key string) (bool, error) {
+	issue, err := store.ReadIssue(key)
+	if err != nil {
+		return false, err
+	}
+	cached, cacheErr := store.ReadComments(key)
+	if cacheErr == nil {
+		if issueUpdated, err := issue.UpdatedTime(); err == nil {
+			fetched, ok := cached.FetchedTime()
+			if !issueUpdated.After(cached.LatestUpdated()) || (ok && !issueUpdated.After(fetched)) {
+				return false, nil
+			}
+		}
+	}
+
+	comments, err := c.FetchComments(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	if cacheErr == nil {
+		changed, deleted := commentsChanged(cached, comments)
+		if changed == 0 && !deleted {
+			// refresh the stamp so the next sync can skip the request
+			cached.Fetched = time.Now().UTC().Format(time.RFC3339)
+			return false, store.SaveComments(key, cached)
+		}
+		log.Printf("%s: %d new or edited comments", key, changed)
+	}
+	comments.Fetched = time.Now().UTC().Format(time.RFC3339)
+	if err := store.SaveComments(key, comments); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func GetIssueCommentsFromCache(dir string, key string) (CommentList, error) {
+	var comments CommentList
+	commentsPath := dir + "/" + key + ".comments.json"
+	data, err := ReadCacheFile(commentsPath)
+	if err != nil {
+		return comments, err
+	}
+
+	if err := json.Unmarshal(data, &comments); err != nil {
+		return comments, err
+	}
+
+	return comments, nil
+}
+
+func FetchAndSaveComments(ctx context.Context, issueKey, baseURL, token, outputDir string) error {
+	store := &DirStore{Dir: outputDir}
+	_, err := NewClient(baseURL, token).SyncComments(ctx, store, issueKey)
+	return err
+}
@@ -0,0 +1,250 @@
+package jira
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// CompressedSuffix marks a cache file written zstd-compressed, such as
+// KEY.json.zst or KEY.changelog.json.zst. Only the files of an issue are
+// compressed; readers accept every form and prefer the plain one, and
+// writing one form removes the others.
+const CompressedSuffix = ".zst"
+
+// LegacyCompressedSuffix marks a gzip-compressed cache file written before
+// the cache switched to zstd. Such files are still read, after the plain
+// and zstd forms, and cache compact rewrites them.
+const LegacyCompressedSuffix = ".gz"
+
+// compressedSuffixes are the compressed forms in the order readers prefer
+// them.
+var compressedSuffixes = []string{CompressedSuffix, LegacyCompressedSuffix}
+
+// TrimCompressedSuffix returns a cache file name without the suffix of
+// its compressed form, if it has one.
+func TrimCompressedSuffix(name string) string {
+	for _, suffix := range compressedSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return strings.TrimSuffix(name, suffix)
+		}
+	}
+	return name
+}
+
+// IsCompressible reports whether a cache file name, plain or compressed,
+// holds one of the JSON files of an issue.
+func IsCompressible(name string) bool {
+	name = TrimCompressedSuffix(name)
+	return strings.HasSuffix(name, ".json") && IsIssueFile(issueFileKey(name)+".json")
+}
+
+// formRank orders the forms of a cache file as readers prefer them, the
+// plain form first.
+func formRank(name string) int {
+	for i, suffix := range compressedSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// cacheFileForms lists the names of every form of a compressible cache
+// file, plain first, in the order readers prefer them.
+func cacheFileForms(name string) []string {
+	if !IsCompressible(name) {
+		return nil
+	}
+	plain := TrimCompressedSuffix(name)
+	forms := []string{plain}
+	for _, suffix := range compressedSuffixes {
+		forms = append(forms, plain+suffix)
+	}
+	return forms
+}
+
+// The encoder and decoder are shared: EncodeAll and DecodeAll may be
+// called concurrently, and creating them without options cannot fail.
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil)
+)
+
+func compressBytes(data []byte) []byte {
+	return zstdEncoder.EncodeAll(data, nil)
+}
+
+// decompressBytes decodes a compressed cache file, as gzip when its name
+// has the legacy suffix and as zstd otherwise.
+func decompressBytes(name string, data []byte) ([]byte, error) {
+	if strings.HasSuffix(name, LegacyCompressedSuffix) {
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, corruptEntry(name, err)
+		}
+		plain, err := io.ReadAll(zr)
+		if err != nil {
+			return nil, corruptEntry(name, err)
+		}
+		return plain, nil
+	}
+	plain, err := zstdDecoder.DecodeAll(data, nil)
+	if err != nil {
+		return nil, corruptEntry(name, err)
+	}
+	return plain, nil
+}
+
+// ReadCacheFile reads a cache file by path, decompressing it when the
+// path names a compressed form. A plain path that does not exist falls
+// back to its compressed forms; when none exists the error is that of
+// the plain path.
+func ReadCacheFile(path string) ([]byte, error) {
+	if TrimCompressedSuffix(path) != path {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return decompressBytes(filepath.Base(path), data)
+	}
+	data, err := os.ReadFile(path)
+	if !os.IsNotExist(err) || !IsCompressible(filepath.Base(path)) {
+		return data, err
+	}
+	for _, suffix := range compressedSuffixes {
+		compressed, zerr := os.ReadFile(path + suffix)
+		if zerr == nil {
+			return decompressBytes(filepath.Base(path)+suffix, compressed)
+		}
+	}
+	return nil, err
+}
+
+// removeCounterpart deletes the other forms of a cache file just written,
+// so a stale copy cannot shadow it, and drops them from the manifest.
+func removeCounterpart(dir, name string) error {
+	for _, other := range cacheFileForms(name) {
+		if other == name {
+			continue
+		}
+		if err := removeCacheFile(dir, other); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeCacheFile deletes a cache file that may not exist and drops it
+// from the manifest.
+func removeCacheFile(dir, name string) error {
+	err := os.Remove(filepath.Join(dir, name))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return appendManifestRemoval(dir, name)
+}
+
+// CompactOptions selects how CompactCache rewrites a directory cache.
+type CompactOptions struct {
+	// Compress writes the files of every issue zstd-compressed, gzip ones
+	// included; without it compressed files are written back plain.
+	Compress bool
+	// DryRun counts what would change without writing.
+	DryRun bool
+}
+
+// CompactResult counts what CompactCache rewrote.
+type CompactResult struct {
+	Files  int
+	Before int64
+	After  int64
+}
+
+// CompactCache rewrites the JSON files of every issue in a directory cache
+// without indentation, compressed or not as selected. Each file is written
+// before its old form is removed, so an interrupted run leaves a readable
+// cache.
+func CompactCache(s *DirStore, opts CompactOptions) (CompactResult, error) {
+	var result CompactResult
+	if err := s.Flush(); err != nil {
+		return result, err
+	}
+	entries, err := os.ReadDir(s.Dir)
+	if err != nil {
+		return result, fmt.Errorf("read dir: %w", err)
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !IsCompressible(name) {
+			continue
+		}
+		if shadowed(s.Dir, name) {
+			// Readers prefer another form, so this one is stale.
+			if !opts.DryRun {
+				if err := removeCacheFile(s.Dir, name); err != nil {
+					return result, err
+				}
+			}
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return result, err
+		}
+		data, err := ReadCacheFile(filepath.Join(s.Dir, name))
+		if err != nil {
+			return result, err
+		}
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, data); err != nil {
+			return result, corruptEntry(name, err)
+		}
+		out, target := buf.Bytes(), TrimCompressedSuffix(name)
+		if opts.Compress {
+			out, target = compressBytes(out), target+CompressedSuffix
+		}
+		result.Files++
+		result.Before += info.Size()
+		result.After += int64(len(out))
+		if opts.DryRun {
+			continue
+		}
+		if err := writeFileAtomic(filepath.Join(s.Dir, target), out); err != nil {
+			return result, err
+		}
+		if err := AppendManifestJournal(s.Dir, target, out); err != nil {
+			return result, err
+		}
+		if target != name {
+			if err := removeCounterpart(s.Dir, target); err != nil {
+				return result, err
+			}
+		}
+	}
+	return result, nil
+}
+
+// shadowed reports whether a form of a cache file that readers prefer
+// exists next to it.
+func shadowed(dir, name string) bool {
+	for _, form := range cacheFileForms(name) {
+		if form == name {
+			return false
+		}
+		if _, err := os.Stat(filepath.Join(dir, form)); err == nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+package jira
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+)
+
+// SyncPhaseChangelogs is the phase of BackfillChangelogs.
+const SyncPhaseChangelogs = "changelogs"
+
+// IsMissingChangelog reports whether an error of ReadChangelog means the
+// changelog was never saved, as opposed to saved but unreadable.
+func IsMissingChangelog(err error) bool {
+	return errors.Is(err, os.ErrNotExist) || errors.Is(err, sql.ErrNoRows)
+}
+
+// ChangelogCoverage counts the issues a report looked at and how many of
+// them had a changelog to analyze. Early caches did not save changelogs,
+// and reports skip or misjudge issues without one.
+type ChangelogCoverage struct {
+	Issues  int
+	Covered int
+	Missing int
+}
+
+// Add counts an issue by the error of reading its changelog.
+func (c *ChangelogCoverage) Add(err error) {
+	c.Issues++
+	switch {
+	case err == nil:
+		c.Covered++
+	case IsMissingChangelog(err):
+		c.Missing++
+	}
+}
+
+// Percent is the share of issues with a changelog, 100 when there were
+// no issues.
+func (c ChangelogCoverage) Percent() float64 {
+	if c.Issues == 0 {
+		return 100
+	}
+	return 100 * float64(c.Covered) / float64(c.Issues)
+}
+
+func (c ChangelogCoverage) String() string {
+	return fmt.Sprintf("changelog coverage %.0f%% (%d of %d issues)", c.Percent(), c.Covered, c.Issues)
+}
+
+// Log reports the coverage of a report, pointing at the backfill when
+// changelogs are missing.
+func (c ChangelogCoverage) Log() {
+	switch {
+	case c.Missing > 0:
+		log.Printf("%s; %d changelogs are missing, backfill them with fetch --changelogs-only", c, c.Missing)
+	case c.Covered < c.Issues:
+		log.Printf("%s; %d changelogs could not be read", c, c.Issues-c.Covered)
+	default:
+		log.Print(c)
+	}
+}
+
+// MissingChangelogKeys lists the cached issues of a project, or of every
+// project, that have no saved changelog. Denied issues are left out.
+func MissingChangelogKeys(store Store, project string) []string {
+	var keys []string
+	for _, key := range store.IssueKeys(project) {
+		if store.IsDenied(key) {
+			continue
+		}
+		if _, err := store.ReadChangelog(key); IsMissingChangelog(err) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// BackfillChangelogs refetches only the cached issues of opts.Project that
+// lack a changelog, saving each with its changelog. Nothing else is
+// searched for or refreshed.
+func BackfillChangelogs(ctx context.Context, client *Client, store Store, opts SyncOptions) (SyncResult, error) {
+	s := &syncer{ctx: ctx, client: client, store: store, opts: opts}
+	keys := MissingChangelogKeys(store, opts.Project)
+	log.Printf("%d cached issues have no changelog", len(keys))
+	if err := s.fetchAll(SyncPhaseChangelogs, keys, true); err != nil {
+		return s.result, err
+	}
+	return s.result, nil
+}
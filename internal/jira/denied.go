@@ -0,0 +1,41 @@
+package jira
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Denial is what a denied marker records: when Jira last refused to show
+// the issue, and with which status.
+type Denial struct {
+	Time   time.Time `json:"denied"`
+	Status int       `json:"status"`
+}
+
+// parseDenial reads the content of a {KEY}.denied marker. Markers written
+// before denials were recorded hold the word "denied"; they count as 403s
+// from modTime, the time the file was written.
+func parseDenial(data []byte, modTime time.Time) Denial {
+	var d Denial
+	if err := json.Unmarshal(data, &d); err != nil || d.Time.IsZero() {
+		d.Time = modTime
+	}
+	if d.Status == 0 {
+		d.Status = 403
+	}
+	return d
+}
+
+// DeniedPolicy decides which issues marked denied a sync asks Jira for
+// again, as permissions change over time. The zero value never does.
+type DeniedPolicy struct {
+	// All retries every denied issue.
+	All bool
+	// After retries issues last denied at least this long ago.
+	After time.Duration
+}
+
+// Due reports whether an issue denied as d is retried at now.
+func (p DeniedPolicy) Due(d Denial, now time.Time) bool {
+	return p.All || (p.After > 0 && !d.Time.IsZero() && now.Sub(d.Time) >= p.After)
+}
@@ -0,0 +1,160 @@
+package jira
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// DiffDir is the directory below a directory cache holding the diff log of
+// each issue, diffs/{KEY}.jsonl, one IssueDiff per line. It records every
+// field that changed between two fetches, including those Jira leaves out
+// of the changelog.
+const DiffDir = "diffs"
+
+// FieldDiff is one field of an issue that changed between two fetches.
+// From or To is absent when the field was added or removed.
+type FieldDiff struct {
+	Field string          `json:"field"`
+	From  json.RawMessage `json:"from,omitempty"`
+	To    json.RawMessage `json:"to,omitempty"`
+}
+
+// IssueDiff is what changed in an issue between the fetch at Since and the
+// fetch at At.
+type IssueDiff struct {
+	Key     string      `json:"key"`
+	Since   string      `json:"since,omitempty"`
+	At      string      `json:"at"`
+	Changes []FieldDiff `json:"changes"`
+}
+
+// rawFields returns the fields of a raw issue with each value marshalled
+// compactly, so equal values compare equal whatever their formatting.
+func rawFields(issue map[string]interface{}) (map[string]json.RawMessage, error) {
+	data, err := json.Marshal(issue["fields"])
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	for name, v := range fields {
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, v); err == nil {
+			fields[name] = buf.Bytes()
+		}
+	}
+	return fields, nil
+}
+
+// DiffRawIssues compares the fields of two raw issues as saved in the
+// cache, sorted by field name. Fields that are null in one version and
+// absent in the other are unchanged.
+func DiffRawIssues(prev, next map[string]interface{}) ([]FieldDiff, error) {
+	before, err := rawFields(prev)
+	if err != nil {
+		return nil, err
+	}
+	after, err := rawFields(next)
+	if err != nil {
+		return nil, err
+	}
+	isNull := func(v json.RawMessage) bool { return len(v) == 0 || string(v) == "null" }
+	var diffs []FieldDiff
+	for name, from := range before {
+		to := after[name]
+		if isNull(from) && isNull(to) || bytes.Equal(from, to) {
+			continue
+		}
+		d := FieldDiff{Field: name}
+		if !isNull(from) {
+			d.From = from
+		}
+		if !isNull(to) {
+			d.To = to
+		}
+		diffs = append(diffs, d)
+	}
+	for name, to := range after {
+		if _, ok := before[name]; !ok && !isNull(to) {
+			diffs = append(diffs, FieldDiff{Field: name, To: to})
+		}
+	}
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].Field < diffs[j].Field })
+	return diffs, nil
+}
+
+// recordDiff appends the changes between the cached version of an issue
+// and the one about to replace it to the issue's diff log. First fetches
+// and refetches without changes record nothing.
+func (s *DirStore) recordDiff(key string, next map[string]interface{}) error {
+	data, err := s.readFile(key + ".json")
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var prev map[string]interface{}
+	if err := json.Unmarshal(data, &prev); err != nil {
+		return corruptEntry(key+".json", err)
+	}
+	changes, err := DiffRawIssues(prev, next)
+	if err != nil || len(changes) == 0 {
+		return err
+	}
+	diff := IssueDiff{Key: key, At: fmt.Sprint(next["fetched"]), Changes: changes}
+	if since, ok := prev["fetched"].(string); ok {
+		diff.Since = since
+	}
+	line, err := json.Marshal(diff)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Join(s.Dir, DiffDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, key+".jsonl"), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ReadDiffs reads the diff log of an issue, oldest first; an issue without
+// one has no diffs.
+func (s *DirStore) ReadDiffs(key string) ([]IssueDiff, error) {
+	f, err := os.Open(filepath.Join(s.Dir, DiffDir, key+".jsonl"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var diffs []IssueDiff
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var d IssueDiff
+		if err := json.Unmarshal(scanner.Bytes(), &d); err != nil {
+			return diffs, corruptEntry(filepath.Join(DiffDir, key+".jsonl"), err)
+		}
+		diffs = append(diffs, d)
+	}
+	return diffs, scanner.Err()
+}
@@ -0,0 +1,130 @@
+package jira
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EffortSource selects how much "work" an issue represents in the reports.
+type EffortSource string
+
+const (
+	EffortPoints EffortSource = "points"
+	EffortTime   EffortSource = "time"
+	EffortCount  EffortSource = "count"
+)
+
+func ParseEffortSource(s string) (EffortSource, error) {
+	switch EffortSource(strings.ToLower(strings.TrimSpace(s))) {
+	case "", EffortPoints:
+		return EffortPoints, nil
+	case EffortTime:
+		return EffortTime, nil
+	case EffortCount:
+		return EffortCount, nil
+	default:
+		return "", fmt.Errorf("invalid effort source %q (expected points, time or count)", s)
+	}
+}
+
+// ColumnName is the header used for the effort column in CSV output.
+func (e EffortSource) ColumnName() string {
+	switch e {
+	case EffortTime:
+		return "estimate_hours"
+	case EffortCount:
+		return "effort_count"
+	default:
+		return "story_points"
+	}
+}
+
+// ChangelogField is the changelog history field that carries effort changes,
+// or "" when the effort never changes over the life of an issue.
+func (e EffortSource) ChangelogField() string {
+	switch e {
+	case EffortPoints:
+		return StoryPointsName
+	case EffortTime:
+		return "timeoriginalestimate"
+	default:
+		return ""
+	}
+}
+
+// ParseChangelogValue converts the ToString of an effort changelog item.
+// Time estimates are stored by Jira in seconds and are reported in hours.
+func (e EffortSource) ParseChangelogValue(item HistoryItem) (float64, bool) {
+	if item.ToString == "" {
+		return 0, false
+	}
+	val, err := strconv.ParseFloat(strings.TrimSpace(item.ToString), 64)
+	if err != nil {
+		return 0, false
+	}
+	if e == EffortTime {
+		return val / 3600, true
+	}
+	return val, true
+}
+
+// IssueEffort returns the current effort of an issue from its cached fields.
+func (e EffortSource) IssueEffort(issue JiraIssueWithSprints) float64 {
+	switch e {
+	case EffortCount:
+		return 1
+	case EffortTime:
+		if issue.Fields.TimeOriginalEstimate != nil && *issue.Fields.TimeOriginalEstimate > 0 {
+			return float64(*issue.Fields.TimeOriginalEstimate) / 3600
+		}
+		if issue.Fields.TimeSpent != nil {
+			return float64(*issue.Fields.TimeSpent) / 3600
+		}
+		return 0
+	default:
+		if issue.Fields.StoryPoints != nil {
+			return *issue.Fields.StoryPoints
+		}
+		return 0
+	}
+}
+
+// EffortAt returns the effort of an issue at time at, replaying the
+// changelog of the effort field and falling back to the cached fields.
+func (e EffortSource) EffortAt(issue JiraIssueWithSprints, changelog Changelog, at time.Time) float64 {
+	if field := e.ChangelogField(); field != "" {
+		if value, ok := ValueAt(changelog, field, at); ok {
+			effort, _ := e.ParseChangelogValue(HistoryItem{ToString: value})
+			return effort
+		}
+	}
+	return e.IssueEffort(issue)
+}
+
+// RemainingEffort returns the effort still outstanding on an issue. For time
+// based effort this is Jira's remaining estimate when it is set.
+func (e EffortSource) RemainingEffort(issue JiraIssueWithSprints) float64 {
+	if issue.IsDone() {
+		return 0
+	}
+	if e == EffortTime && issue.Fields.TimeEstimate != nil {
+		return float64(*issue.Fields.TimeEstimate) / 3600
+	}
+	return e.IssueEffort(issue)
+}
+
+// InitialEffort is the effort assumed before any changelog entry is seen.
+// For time it is the original estimate the first change of the changelog
+// replaced, or the current one when the changelog never changes it.
+func (e EffortSource) InitialEffort(issue JiraIssueWithSprints, changelog Changelog) float64 {
+	switch e {
+	case EffortCount:
+		return 1
+	case EffortTime:
+		return e.EffortAt(issue, changelog, time.Time{})
+	default:
+		return 0
+	}
+}
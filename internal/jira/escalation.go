@@ -0,0 +1,137 @@
+package jira
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// priorityWeights rank the urgency of blocked work; unknown priorities
+// weigh as Normal.
+var priorityWeights = map[string]float64{
+	"Blocker":   5,
+	"Critical":  4,
+	"Major":     3,
+	"Normal":    2,
+	"Minor":     1,
+	"Undefined": 2,
+}
+
+// Escalation is a stale open issue that blocks work in an active sprint.
+type Escalation struct {
+	Key      string    `json:"key"`
+	Summary  string    `json:"summary"`
+	Status   string    `json:"status"`
+	Assignee string    `json:"assignee,omitempty"`
+	Updated  time.Time `json:"updated"`
+	// DaysStale is the number of whole days since the blocker was updated.
+	DaysStale int `json:"daysStale"`
+	// Blocked lists the active sprint issues waiting on the blocker.
+	Blocked []string `json:"blocked"`
+	Sprints []string `json:"sprints"`
+	// Priority is the highest priority among the blocked issues.
+	Priority string `json:"priority"`
+	// Score orders escalations: days stale times the summed priority
+	// weight of the blocked issues.
+	Score float64 `json:"score"`
+}
+
+// ActiveSprints returns the names of the active sprints the issue is in.
+func (i JiraIssueWithSprints) ActiveSprints() []string {
+	var names []string
+	for _, s := range i.Fields.Sprints {
+		if strings.EqualFold(s.State, "active") {
+			names = append(names, s.Name)
+		}
+	}
+	return names
+}
+
+// FindEscalations flags cached open issues that block open work in an
+// active sprint and have not been updated for staleAfter, most urgent
+// first. Blockers missing from the cache have no known update time and are
+// counted in uncached instead.
+func FindEscalations(issues []JiraIssueWithSprints, staleAfter time.Duration, now time.Time) (escalations []Escalation, uncached int) {
+	byKey := make(map[string]JiraIssueWithSprints, len(issues))
+	for _, issue := range issues {
+		byKey[issue.Key] = issue
+	}
+
+	// blockers maps a blocker key to the active sprint issues it holds up.
+	// Links may only be present on one side, so both directions are read.
+	blocked := map[string]map[string]bool{}
+	addEdge := func(blocker, key string) {
+		issue, ok := byKey[key]
+		if !ok || issue.IsDone() || len(issue.ActiveSprints()) == 0 {
+			return
+		}
+		if blocked[blocker] == nil {
+			blocked[blocker] = map[string]bool{}
+		}
+		blocked[blocker][key] = true
+	}
+	for _, issue := range issues {
+		for _, b := range issue.Blockers() {
+			addEdge(b.Key, issue.Key)
+		}
+		for _, b := range issue.Blocks() {
+			addEdge(issue.Key, b.Key)
+		}
+	}
+
+	for key, waiting := range blocked {
+		blocker, ok := byKey[key]
+		if !ok {
+			uncached++
+			continue
+		}
+		if blocker.IsDone() {
+			continue
+		}
+		updated, err := blocker.UpdatedTime()
+		if err != nil || now.Sub(updated) < staleAfter {
+			continue
+		}
+
+		e := Escalation{
+			Key:       key,
+			Summary:   blocker.Fields.Summary,
+			Status:    blocker.Fields.Status.Name,
+			Assignee:  blocker.AssigneeID(),
+			Updated:   updated,
+			DaysStale: int(now.Sub(updated).Hours() / 24),
+		}
+		sprints := map[string]bool{}
+		weight, top := 0.0, 0.0
+		for k := range waiting {
+			issue := byKey[k]
+			e.Blocked = append(e.Blocked, k)
+			for _, s := range issue.ActiveSprints() {
+				sprints[s] = true
+			}
+			w, ok := priorityWeights[issue.PriorityName()]
+			if !ok {
+				w = priorityWeights["Normal"]
+			}
+			weight += w
+			if w > top {
+				top, e.Priority = w, issue.PriorityName()
+			}
+		}
+		for s := range sprints {
+			e.Sprints = append(e.Sprints, s)
+		}
+		sort.Strings(e.Blocked)
+		sort.Strings(e.Sprints)
+		e.Score = float64(e.DaysStale) * weight
+		escalations = append(escalations, e)
+	}
+
+	sort.Slice(escalations, func(i, j int) bool {
+		if escalations[i].Score != escalations[j].Score {
+			return escalations[i].Score > escalations[j].Score
+		}
+		return escalations[i].Key < escalations[j].Key
+	})
+	return escalations, uncached
+}
@@ -0,0 +1,82 @@
+package jira
+
+import (
+	"strings"
+	"time"
+)
+
+// IssueColumns are the flattened fields FlattenIssue always fills, in
+// output order.
+var IssueColumns = columnNames(issueSchema)
+
+// FlattenIssue turns an issue into one flat record of scalars and string
+// lists, ready for jq, DuckDB or pandas. Fields of the extractors are added
+// under their names, numbers as numbers, unless they clash with a column.
+// Timestamps are RFC 3339 strings; IssueValues keeps them typed.
+func FlattenIssue(issue JiraIssueWithSprints, extractors Extractors) map[string]interface{} {
+	record := IssueValues(issue, extractors)
+	for name, value := range record {
+		if t, ok := value.(time.Time); ok {
+			record[name] = t.Format(time.RFC3339)
+		}
+	}
+	return record
+}
+
+func nonNil(list []string) []string {
+	if list == nil {
+		return []string{}
+	}
+	return list
+}
+
+func projectOf(key string) string {
+	project, _, _ := strings.Cut(key, "-")
+	return project
+}
+
+// FieldChange is one field change from an issue changelog, with Jira's
+// nested history entries flattened to a row per changed field.
+type FieldChange struct {
+	Key     string    `json:"key"`
+	Project string    `json:"project"`
+	At      time.Time `json:"at"`
+	Author  string    `json:"author,omitempty"`
+	Field   string    `json:"field"`
+	From    string    `json:"from,omitempty"`
+	To      string    `json:"to,omitempty"`
+	// FromID and ToID are the raw ids Jira records next to the display
+	// strings, such as status or user ids.
+	FromID string `json:"fromId,omitempty"`
+	ToID   string `json:"toId,omitempty"`
+}
+
+// FieldChanges flattens a changelog into events in time order, skipping
+// entries with unparseable timestamps.
+func FieldChanges(key string, changelog Changelog) []FieldChange {
+	var events []FieldChange
+	for _, h := range changelog.Histories {
+		at, err := ParseJiraTime(h.Created)
+		if err != nil {
+			continue
+		}
+		author := ""
+		if h.Author != nil {
+			author = h.Author.ID()
+		}
+		for _, item := range h.Items {
+			events = append(events, FieldChange{
+				Key:     key,
+				Project: projectOf(key),
+				At:      at.UTC(),
+				Author:  author,
+				Field:   item.Field,
+				From:    item.FromString,
+				To:      item.ToString,
+				FromID:  item.From,
+				ToID:    item.To,
+			})
+		}
+	}
+	return events
+}
@@ -0,0 +1,235 @@
+package jira
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Extractor types supported in the fields config.
+const (
+	ExtractString = "string"
+	ExtractNumber = "number"
+	ExtractOption = "option"
+)
+
+// FieldExtractor maps a raw Jira field (usually a customfield_NNNNN) to a
+// named, typed value usable in group-by and where clauses.
+type FieldExtractor struct {
+	Name  string `json:"name"`
+	Field string `json:"field"`
+	Type  string `json:"type"`
+}
+
+type FieldValue struct {
+	Text    string
+	Number  float64
+	Numeric bool
+}
+
+// Extractors is a registry of extractors keyed by lower-cased name.
+type Extractors map[string]FieldExtractor
+
+func DefaultExtractors() Extractors {
+	e := Extractors{}
+	for _, fe := range []FieldExtractor{
+		{Name: "status", Field: "status", Type: ExtractOption},
+		{Name: "issuetype", Field: "issuetype", Type: ExtractOption},
+		{Name: "priority", Field: "priority", Type: ExtractOption},
+		{Name: "assignee", Field: "assignee", Type: ExtractOption},
+		{Name: "project", Field: "project", Type: ExtractOption},
+		{Name: "labels", Field: "labels", Type: ExtractOption},
+		{Name: "components", Field: "components", Type: ExtractOption},
+		{Name: "summary", Field: "summary", Type: ExtractString},
+		{Name: "points", Field: StoryPointsField, Type: ExtractNumber},
+		{Name: CategoryField, Field: CategoryField, Type: ExtractOption},
+	} {
+		e[fe.Name] = fe
+	}
+	return e
+}
+
+// LoadExtractors reads a JSON fields config of the form
+// {"fields": [{"name": "Team", "field": "customfield_123", "type": "option"}]}
+// and merges it over the default extractors.
+func LoadExtractors(path string) (Extractors, error) {
+	e := DefaultExtractors()
+	if path == "" {
+		return e, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read fields config: %w", err)
+	}
+	var cfg struct {
+		Fields []FieldExtractor `json:"fields"`
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse fields config %s: %w", path, err)
+	}
+	for _, fe := range cfg.Fields {
+		if err := e.Register(fe); err != nil {
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+func (e Extractors) Register(fe FieldExtractor) error {
+	if fe.Name == "" || fe.Field == "" {
+		return fmt.Errorf("field extractor needs a name and a field: %+v", fe)
+	}
+	switch fe.Type {
+	case "":
+		fe.Type = ExtractString
+	case ExtractString, ExtractNumber, ExtractOption:
+	default:
+		return fmt.Errorf("field extractor %s: unknown type %q", fe.Name, fe.Type)
+	}
+	e[strings.ToLower(fe.Name)] = fe
+	return nil
+}
+
+func (e Extractors) Lookup(name string) (FieldExtractor, bool) {
+	fe, ok := e[strings.ToLower(name)]
+	return fe, ok
+}
+
+// Names returns the registered extractor names in sorted order.
+func (e Extractors) Names() []string {
+	var names []string
+	for _, fe := range e {
+		names = append(names, fe.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// optionText pulls a display string out of option/user/object shaped values.
+func optionText(raw json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	var n float64
+	if err := json.Unmarshal(raw, &n); err == nil {
+		return strconv.FormatFloat(n, 'f', -1, 64)
+	}
+	var list []json.RawMessage
+	if err := json.Unmarshal(raw, &list); err == nil {
+		var parts []string
+		for _, item := range list {
+			if text := optionText(item); text != "" {
+				parts = append(parts, text)
+			}
+		}
+		return strings.Join(parts, ",")
+	}
+	var obj map[string]interface{}
+	if err := json.Unmarshal(raw, &obj); err == nil {
+		for _, k := range []string{"value", "name", "key", "displayName"} {
+			if v, ok := obj[k].(string); ok {
+				return v
+			}
+		}
+	}
+	return ""
+}
+
+// Extract returns the typed value of the field, or false when it is unset.
+func (fe FieldExtractor) Extract(fields Fields) (FieldValue, bool) {
+	if fe.Field == CategoryField {
+		text := strings.Join(fields.Categories, ",")
+		return FieldValue{Text: text}, text != ""
+	}
+
+	raw, ok := fe.raw(fields)
+	if !ok || string(raw) == "null" {
+		return FieldValue{}, false
+	}
+
+	switch fe.Type {
+	case ExtractNumber:
+		var n float64
+		if err := json.Unmarshal(raw, &n); err != nil {
+			text := optionText(raw)
+			if n, err = strconv.ParseFloat(text, 64); err != nil {
+				return FieldValue{Text: text}, text != ""
+			}
+		}
+		return FieldValue{Text: strconv.FormatFloat(n, 'f', -1, 64), Number: n, Numeric: true}, true
+	case ExtractOption:
+		text := optionText(raw)
+		return FieldValue{Text: text}, text != ""
+	default:
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			s = optionText(raw)
+		}
+		return FieldValue{Text: s}, s != ""
+	}
+}
+
+// Condition is a single "name op value" filter used by --where.
+type Condition struct {
+	Name  string
+	Op    string
+	Value string
+}
+
+var conditionOps = []string{"!=", ">=", "<=", "~", "=", ">", "<"}
+
+func ParseCondition(expr string) (Condition, error) {
+	for _, op := range conditionOps {
+		if idx := strings.Index(expr, op); idx > 0 {
+			return Condition{
+				Name:  strings.TrimSpace(expr[:idx]),
+				Op:    op,
+				Value: strings.Trim(strings.TrimSpace(expr[idx+len(op):]), `"`),
+			}, nil
+		}
+	}
+	return Condition{}, fmt.Errorf("invalid condition %q (expected name op value)", expr)
+}
+
+// Match evaluates the condition against an extracted value.
+func (c Condition) Match(value FieldValue, present bool) bool {
+	switch c.Op {
+	case "=":
+		return present && strings.EqualFold(value.Text, c.Value)
+	case "!=":
+		return !present || !strings.EqualFold(value.Text, c.Value)
+	case "~":
+		return present && strings.Contains(strings.ToLower(value.Text), strings.ToLower(c.Value))
+	}
+
+	target, err := strconv.ParseFloat(c.Value, 64)
+	if !present || !value.Numeric || err != nil {
+		return false
+	}
+	switch c.Op {
+	case ">":
+		return value.Number > target
+	case "<":
+		return value.Number < target
+	case ">=":
+		return value.Number >= target
+	case "<=":
+		return value.Number <= target
+	}
+	return false
+}
+
+// MatchIssue evaluates the condition against an issue using the registry.
+func (e Extractors) MatchIssue(c Condition, fields Fields) (bool, error) {
+	fe, ok := e.Lookup(c.Name)
+	if !ok {
+		return false, fmt.Errorf("unknown field %q", c.Name)
+	}
+	value, present := fe.Extract(fields)
+	return c.Match(value, present), nil
+}
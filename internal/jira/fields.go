@@ -0,0 +1,237 @@
+package jira
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FieldMapFile holds the field definitions found by "fields discover".
+const FieldMapFile = "fields.json"
+
+// Schema custom types of the Jira Software fields the reports depend on.
+const (
+	sprintSchemaType   = "com.pyxis.greenhopper.jira:gh-sprint"
+	epicLinkSchemaType = "com.pyxis.greenhopper.jira:gh-epic-link"
+)
+
+// StoryPointsName is the changelog field name of story points. Jira records
+// custom fields in the changelog by display name, which differs between
+// instances ("Story point estimate" on Jira Cloud).
+var StoryPointsName = "Story Points"
+
+// storyPointsNames are the display names story points go by, in order of
+// preference.
+var storyPointsNames = []string{"Story Points", "Story point estimate"}
+
+// FieldSchema describes the type of a Jira field.
+type FieldSchema struct {
+	Type   string `json:"type,omitempty"`
+	Items  string `json:"items,omitempty"`
+	Custom string `json:"custom,omitempty"`
+}
+
+// FieldDef is a field as listed by /rest/api/2/field.
+type FieldDef struct {
+	ID     string      `json:"id"`
+	Name   string      `json:"name"`
+	Custom bool        `json:"custom"`
+	Schema FieldSchema `json:"schema"`
+}
+
+// FieldMap is the name to id mapping of a Jira instance's fields.
+type FieldMap struct {
+	BaseURL      string     `json:"baseUrl,omitempty"`
+	DiscoveredAt string     `json:"discoveredAt"`
+	Fields       []FieldDef `json:"fields"`
+}
+
+// ListFields returns every field defined in the Jira instance.
+func (c *Client) ListFields(ctx context.Context) ([]FieldDef, error) {
+	body, err := c.Get(ctx, c.apiURL(ctx, "/field"))
+	if err != nil {
+		return nil, fmt.Errorf("list fields: %w", err)
+	}
+	var fields []FieldDef
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, fmt.Errorf("parse fields: %w", err)
+	}
+	return fields, nil
+}
+
+// DiscoverFields builds a FieldMap from the fields of the Jira instance.
+func (c *Client) DiscoverFields(ctx context.Context) (FieldMap, error) {
+	fields, err := c.ListFields(ctx)
+	if err != nil {
+		return FieldMap{}, err
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i].ID < fields[j].ID })
+	return FieldMap{
+		BaseURL:      c.BaseURL,
+		DiscoveredAt: time.Now().UTC().Format(time.RFC3339),
+		Fields:       fields,
+	}, nil
+}
+
+// Lookup returns the fields with the given id or display name, compared
+// case-insensitively. Several custom fields may share a name.
+func (m FieldMap) Lookup(name string) []FieldDef {
+	var found []FieldDef
+	for _, f := range m.Fields {
+		if strings.EqualFold(f.ID, name) || strings.EqualFold(f.Name, name) {
+			found = append(found, f)
+		}
+	}
+	return found
+}
+
+// ID resolves a field name to its id. Ids are returned unchanged, and a
+// name shared by several fields resolves to the lowest id.
+func (m FieldMap) ID(name string) (string, bool) {
+	found := m.Lookup(name)
+	if len(found) == 0 {
+		return "", false
+	}
+	return found[0].ID, true
+}
+
+// bySchema returns the first custom field of the given schema type.
+func (m FieldMap) bySchema(custom string) (FieldDef, bool) {
+	for _, f := range m.Fields {
+		if f.Schema.Custom == custom {
+			return f, true
+		}
+	}
+	return FieldDef{}, false
+}
+
+// CustomFields resolves the sprint, story points and epic link fields: by
+// schema type where Jira Software defines one, otherwise by name.
+func (m FieldMap) CustomFields() (sprint, storyPoints, epicLink FieldDef) {
+	sprint, _ = m.bySchema(sprintSchemaType)
+	epicLink, _ = m.bySchema(epicLinkSchemaType)
+	for _, name := range storyPointsNames {
+		for _, f := range m.Lookup(name) {
+			if f.Custom && f.Schema.Type == "number" {
+				return sprint, f, epicLink
+			}
+		}
+	}
+	return sprint, storyPoints, epicLink
+}
+
+// discoveredFields is the field map in use, letting extractors name fields
+// by display name instead of id.
+var discoveredFields FieldMap
+
+// raw returns the raw value of the extractor's field, resolving a display
+// name such as "Team" to its id through the discovered field map.
+func (fe FieldExtractor) raw(fields Fields) (json.RawMessage, bool) {
+	if raw, ok := fields.Raw[fe.Field]; ok {
+		return raw, true
+	}
+	if id, ok := discoveredFields.ID(fe.Field); ok {
+		raw, ok := fields.Raw[id]
+		return raw, ok
+	}
+	return nil, false
+}
+
+// customFieldsPinned records the custom fields set explicitly through
+// SetCustomFields, which a discovered field map must not override.
+var customFieldsPinned = map[string]bool{}
+
+// UseFieldMap points the sprint, story points and epic link fields at the
+// ids discovered in the Jira instance, except those set explicitly.
+func UseFieldMap(m FieldMap) {
+	discoveredFields = m
+	sprint, points, epic := m.CustomFields()
+	if sprint.ID != "" && !customFieldsPinned["sprint"] {
+		SprintField = sprint.ID
+	}
+	if points.ID != "" && !customFieldsPinned["storyPoints"] {
+		StoryPointsField = points.ID
+		setStoryPointsName(points.Name)
+	}
+	if epic.ID != "" && !customFieldsPinned["epicLink"] {
+		EpicLinkField = epic.ID
+	}
+}
+
+func setStoryPointsName(name string) {
+	if name == "" || name == StoryPointsName {
+		return
+	}
+	if i := slices.Index(DefaultChangelogFields, StoryPointsName); i >= 0 {
+		DefaultChangelogFields[i] = name
+	}
+	StoryPointsName = name
+}
+
+// applyFieldMap uses the field map saved in a store, if there is one.
+func applyFieldMap(store Store) {
+	m, err := store.ReadFieldMap()
+	if err == nil && len(m.Fields) > 0 {
+		UseFieldMap(m)
+	}
+}
+
+func (s *DirStore) ReadFieldMap() (FieldMap, error) {
+	var m FieldMap
+	data, err := s.readFile(FieldMapFile)
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return m, err
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return m, corruptEntry(FieldMapFile, err)
+	}
+	return m, nil
+}
+
+func (s *DirStore) SaveFieldMap(m FieldMap) error {
+	data, err := s.marshal(m)
+	if err != nil {
+		return fmt.Errorf("marshal field map: %w", err)
+	}
+	if err := s.writeFile(FieldMapFile, data); err != nil {
+		return fmt.Errorf("write %s: %w", path.Join(s.Dir, FieldMapFile), err)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) ReadFieldMap() (FieldMap, error) {
+	var m FieldMap
+	var data []byte
+	err := s.db.QueryRow(`SELECT data FROM metadata WHERE name = 'fields'`).Scan(&data)
+	if err == sql.ErrNoRows {
+		return m, nil
+	}
+	if err != nil {
+		return m, fmt.Errorf("read field map: %w", err)
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return m, corruptEntry("field map", err)
+	}
+	return m, nil
+}
+
+func (s *SQLiteStore) SaveFieldMap(m FieldMap) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("marshal field map: %w", err)
+	}
+	if _, err := s.db.Exec(`INSERT OR REPLACE INTO metadata (name, data) VALUES ('fields', ?)`, data); err != nil {
+		return fmt.Errorf("write field map: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package jira
+
+import (
+	"sort"
+	"strings"
+)
+
+// Relations of hierarchy edges in a LinkGraph; link edges use the outward
+// name of their link type, such as "blocks" or "relates to".
+const (
+	RelationEpic   = "epic"
+	RelationParent = "parent"
+)
+
+// GraphNode is an issue in a LinkGraph. Issues known only from the links
+// of cached issues are not Cached and carry what the link embeds.
+type GraphNode struct {
+	Key     string `json:"key"`
+	Summary string `json:"summary"`
+	Type    string `json:"type,omitempty"`
+	Status  string `json:"status"`
+	Epic    string `json:"epic,omitempty"`
+	Cached  bool   `json:"cached"`
+}
+
+// GraphEdge is a directed relationship. Link edges point the outward way
+// of the link type, so "A blocks B" goes from A to B; epic and parent
+// edges go from the child to its epic or parent.
+type GraphEdge struct {
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Type     string `json:"type"`
+	Relation string `json:"relation"`
+}
+
+// LinkGraph holds the issues and relationships among the cached issues.
+type LinkGraph struct {
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+// LinkGraphOptions narrow a LinkGraph.
+type LinkGraphOptions struct {
+	// Types keeps only edges whose link type name or relation contains
+	// one of these, case-insensitively, with "epic" and "parent" naming
+	// the hierarchy edges. Empty keeps all.
+	Types []string
+	// Epics keeps only issues of these epics, the epics themselves and
+	// the issues they are directly related to. Empty keeps all.
+	Epics []string
+}
+
+func (o LinkGraphOptions) keepEdge(e GraphEdge) bool {
+	if len(o.Types) == 0 {
+		return true
+	}
+	for _, t := range o.Types {
+		t = strings.ToLower(t)
+		if strings.Contains(strings.ToLower(e.Type), t) || strings.Contains(e.Relation, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// BuildLinkGraph extracts issue links and epic and parent relationships.
+// Jira records a link on both of its issues, so each link becomes one
+// edge however many of its ends are cached.
+func BuildLinkGraph(issues []JiraIssueWithSprints, opts LinkGraphOptions) *LinkGraph {
+	nodes := map[string]*GraphNode{}
+	for _, issue := range issues {
+		nodes[issue.Key] = &GraphNode{
+			Key:     issue.Key,
+			Summary: issue.Fields.Summary,
+			Type:    issue.Fields.IssueType.Name,
+			Status:  issue.Fields.Status.Name,
+			Epic:    issue.EpicKey(),
+			Cached:  true,
+		}
+	}
+	linked := func(li *LinkedIssue) {
+		if _, ok := nodes[li.Key]; !ok {
+			nodes[li.Key] = &GraphNode{Key: li.Key, Summary: li.Fields.Summary, Status: li.Fields.Status.Name}
+		}
+	}
+	stub := func(key string) {
+		if _, ok := nodes[key]; !ok {
+			nodes[key] = &GraphNode{Key: key}
+		}
+	}
+
+	seen := map[GraphEdge]bool{}
+	var edges []GraphEdge
+	add := func(e GraphEdge) {
+		if seen[e] || !opts.keepEdge(e) {
+			return
+		}
+		seen[e] = true
+		edges = append(edges, e)
+	}
+	for _, issue := range issues {
+		for _, link := range issue.Fields.IssueLinks {
+			relation := strings.ToLower(link.Type.Outward)
+			if relation == "" {
+				relation = strings.ToLower(link.Type.Name)
+			}
+			if link.OutwardIssue != nil {
+				linked(link.OutwardIssue)
+				add(GraphEdge{From: issue.Key, To: link.OutwardIssue.Key, Type: link.Type.Name, Relation: relation})
+			}
+			if link.InwardIssue != nil {
+				linked(link.InwardIssue)
+				add(GraphEdge{From: link.InwardIssue.Key, To: issue.Key, Type: link.Type.Name, Relation: relation})
+			}
+		}
+		if epic := issue.EpicKey(); epic != "" {
+			stub(epic)
+			add(GraphEdge{From: issue.Key, To: epic, Type: "Epic", Relation: RelationEpic})
+		}
+		if parent := issue.Fields.Parent.Key; parent != "" {
+			stub(parent)
+			add(GraphEdge{From: issue.Key, To: parent, Type: "Parent", Relation: RelationParent})
+		}
+	}
+
+	if len(opts.Epics) > 0 {
+		edges = epicNeighbourhood(nodes, edges, opts.Epics)
+	}
+
+	g := &LinkGraph{Edges: edges}
+	used := map[string]bool{}
+	for _, e := range edges {
+		used[e.From], used[e.To] = true, true
+	}
+	for key, n := range nodes {
+		// Without an epic filter every cached issue is kept, linked or not.
+		if used[key] || (len(opts.Epics) == 0 && n.Cached) {
+			g.Nodes = append(g.Nodes, *n)
+		}
+	}
+	sort.Slice(g.Nodes, func(i, j int) bool { return LessKey(g.Nodes[i].Key, g.Nodes[j].Key) })
+	sort.SliceStable(g.Edges, func(i, j int) bool {
+		a, b := g.Edges[i], g.Edges[j]
+		if a.From != b.From {
+			return LessKey(a.From, b.From)
+		}
+		if a.To != b.To {
+			return LessKey(a.To, b.To)
+		}
+		return a.Relation < b.Relation
+	})
+	return g
+}
+
+// epicNeighbourhood keeps the edges touching the epics or their issues.
+func epicNeighbourhood(nodes map[string]*GraphNode, edges []GraphEdge, epics []string) []GraphEdge {
+	members := map[string]bool{}
+	for _, epic := range epics {
+		members[strings.ToUpper(epic)] = true
+	}
+	for key, n := range nodes {
+		if members[n.Epic] {
+			members[key] = true
+		}
+	}
+	var kept []GraphEdge
+	for _, e := range edges {
+		if members[e.From] || members[e.To] {
+			kept = append(kept, e)
+		}
+	}
+	return kept
+}
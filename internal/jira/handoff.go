@@ -0,0 +1,87 @@
+package jira
+
+import (
+	"sort"
+	"time"
+)
+
+// Handoff is a change of assignee from one person to another.
+type Handoff struct {
+	From string
+	To   string
+	At   time.Time
+}
+
+// changelogUser prefers the id Jira Server records for user fields, which
+// matches AssigneeID, over the display string.
+func changelogUser(id, display string) string {
+	if id != "" {
+		return id
+	}
+	return display
+}
+
+// AssigneeChain replays the assignee changes of an issue. It returns every
+// person the issue was assigned to in order and the handoffs between them.
+// Unassigning an issue is not a handoff; assigning it to someone new later
+// hands it off from the last assignee.
+func AssigneeChain(changelog Changelog) (chain []string, handoffs []Handoff) {
+	last := ""
+	for _, h := range changelog.Histories {
+		at, err := ParseJiraTime(h.Created)
+		if err != nil {
+			continue
+		}
+		for _, item := range h.Items {
+			if item.Field != "assignee" {
+				continue
+			}
+			if len(chain) == 0 {
+				// The first change also tells who held the issue before.
+				if from := changelogUser(item.From, item.FromString); from != "" {
+					chain = append(chain, from)
+					last = from
+				}
+			}
+			to := changelogUser(item.To, item.ToString)
+			if to == "" || to == last {
+				continue
+			}
+			if last != "" {
+				handoffs = append(handoffs, Handoff{From: last, To: to, At: at})
+			}
+			chain = append(chain, to)
+			last = to
+		}
+	}
+	return chain, handoffs
+}
+
+// HandoffPair counts the handoffs from one person to another.
+type HandoffPair struct {
+	From  string
+	To    string
+	Count int
+}
+
+// CountHandoffPairs tallies handoffs by direction, most common first.
+func CountHandoffPairs(handoffs []Handoff) []HandoffPair {
+	counts := map[[2]string]int{}
+	for _, h := range handoffs {
+		counts[[2]string{h.From, h.To}]++
+	}
+	pairs := make([]HandoffPair, 0, len(counts))
+	for k, n := range counts {
+		pairs = append(pairs, HandoffPair{From: k[0], To: k[1], Count: n})
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].Count != pairs[j].Count {
+			return pairs[i].Count > pairs[j].Count
+		}
+		if pairs[i].From != pairs[j].From {
+			return pairs[i].From < pairs[j].From
+		}
+		return pairs[i].To < pairs[j].To
+	})
+	return pairs
+}
@@ -0,0 +1,313 @@
+package jira
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// GetAllChangelogKeys lists the keys that have a cached changelog file.
+func GetAllChangelogKeys(dir, project string) []string {
+	var keys []string
+	prefix := ""
+	if project != "" {
+		prefix = strings.ToUpper(project) + "-"
+	}
+
+	seen := map[string]bool{}
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		name := TrimCompressedSuffix(entry.Name())
+		if !strings.HasSuffix(name, ".changelog.json") || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		key := strings.TrimSuffix(name, ".changelog.json")
+		if IsIssueFile(key+".json") && !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+type timedHistory struct {
+	at    time.Time
+	entry HistoryEntry
+}
+
+func sortedHistories(c Changelog) []timedHistory {
+	var histories []timedHistory
+	for _, h := range c.Histories {
+		t, err := ParseJiraTime(h.Created)
+		if err != nil {
+			continue
+		}
+		histories = append(histories, timedHistory{at: t, entry: h})
+	}
+	sort.SliceStable(histories, func(i, j int) bool {
+		a, b := histories[i], histories[j]
+		return historyLess(a.entry, a.at, true, b.entry, b.at, true)
+	})
+	return histories
+}
+
+// itemValue picks the representation of a changelog value that matches
+// the cached fields: user names for assignee, display strings otherwise.
+func itemValue(item HistoryItem, to bool) string {
+	if strings.EqualFold(item.Field, "assignee") || strings.EqualFold(item.Field, "reporter") {
+		if to && item.To != "" {
+			return item.To
+		}
+		if !to && item.From != "" {
+			return item.From
+		}
+	}
+	if to {
+		return item.ToString
+	}
+	return item.FromString
+}
+
+// ValueAt returns the value of a changelog field at time at: the last "to"
+// value recorded at or before at, or the first "from" value after it. The
+// bool is false when the field never appears in the changelog.
+func ValueAt(c Changelog, field string, at time.Time) (string, bool) {
+	value, found := "", false
+	for _, h := range sortedHistories(c) {
+		for _, item := range h.entry.Items {
+			if !strings.EqualFold(item.Field, field) {
+				continue
+			}
+			if !h.at.After(at) {
+				value, found = itemValue(item, true), true
+				continue
+			}
+			if !found {
+				return itemValue(item, false), true
+			}
+			return value, true
+		}
+	}
+	return value, found
+}
+
+// ChangelogUntil returns the histories recorded at or before at.
+func ChangelogUntil(c Changelog, at time.Time) Changelog {
+	var result Changelog
+	for _, h := range sortedHistories(c) {
+		if !h.at.After(at) {
+			result.Histories = append(result.Histories, h.entry)
+		}
+	}
+	return result
+}
+
+// Changelog field names tracked in snapshots.
+var snapshotFields = []string{"summary", "description", "status", "assignee", "priority", "resolution", "Sprint", "Story Points", "labels", "Fix Version", "issuetype"}
+
+// IssueSnapshot is the reconstructed state of an issue at a point in time.
+type IssueSnapshot struct {
+	Key     string
+	Project string
+	Created time.Time
+	At      time.Time
+	Values  map[string]string
+}
+
+func currentValue(issue *JiraIssueWithSprints, field string) string {
+	if issue == nil {
+		return ""
+	}
+	f := issue.Fields
+	switch field {
+	case "summary":
+		return f.Summary
+	case "description":
+		return f.Description
+	case "status":
+		return f.Status.Name
+	case "assignee":
+		return issue.AssigneeID()
+	case "priority":
+		if f.Priority != nil {
+			return f.Priority.Name
+		}
+	case "resolution":
+		if f.Resolution != nil {
+			return f.Resolution.Name
+		}
+	case "Sprint":
+		var names []string
+		for _, s := range f.Sprints {
+			names = append(names, s.Name)
+		}
+		return strings.Join(names, ",")
+	case "Story Points":
+		if f.StoryPoints != nil {
+			return fmt.Sprintf("%g", *f.StoryPoints)
+		}
+	case "labels":
+		return strings.Join(f.Labels, " ")
+	case "Fix Version":
+		var names []string
+		for _, v := range f.FixVersions {
+			names = append(names, v.Name)
+		}
+		return strings.Join(names, ",")
+	case "issuetype":
+		return f.IssueType.Name
+	}
+	return ""
+}
+
+// SnapshotIssue reconstructs an issue at time at from its changelog, using
+// the current cached issue (if any) for fields that never changed. It
+// returns false when the issue did not exist yet at that time.
+func SnapshotIssue(key string, current *JiraIssueWithSprints, changelog Changelog, at time.Time) (*IssueSnapshot, bool) {
+	snap := &IssueSnapshot{Key: key, At: at, Values: map[string]string{}}
+	snap.Project, _, _ = strings.Cut(key, "-")
+
+	if current != nil {
+		if created, err := current.CreatedTime(); err == nil {
+			snap.Created = created
+		}
+	}
+	if snap.Created.IsZero() {
+		histories := sortedHistories(changelog)
+		if len(histories) == 0 {
+			return nil, false
+		}
+		// Without the issue itself the first history is the best estimate.
+		snap.Created = histories[0].at
+	}
+	if snap.Created.After(at) {
+		return nil, false
+	}
+
+	for _, field := range snapshotFields {
+		// Snapshots always say "Story Points" whatever the instance calls it.
+		name := field
+		if field == "Story Points" {
+			name = StoryPointsName
+		}
+		if value, ok := ValueAt(changelog, name, at); ok {
+			snap.Values[field] = value
+		} else {
+			snap.Values[field] = currentValue(current, field)
+		}
+	}
+	return snap, true
+}
+
+func splitNonEmpty(s, sep string) []string {
+	var parts []string
+	for _, p := range strings.Split(s, sep) {
+		if p = strings.TrimSpace(p); p != "" {
+			parts = append(parts, p)
+		}
+	}
+	return parts
+}
+
+// IssueJSON renders the snapshot in the cached issue layout so the
+// existing tools can read a snapshot directory like a live cache.
+func (s *IssueSnapshot) IssueJSON() map[string]interface{} {
+	named := func(v string) interface{} {
+		if v == "" {
+			return nil
+		}
+		return map[string]string{"name": v}
+	}
+
+	var sprints []map[string]string
+	for _, name := range splitNonEmpty(s.Values["Sprint"], ",") {
+		sprints = append(sprints, map[string]string{"name": name})
+	}
+	var versions []map[string]string
+	for _, name := range splitNonEmpty(s.Values["Fix Version"], ",") {
+		versions = append(versions, map[string]string{"name": name})
+	}
+
+	fields := map[string]interface{}{
+		"summary":        s.Values["summary"],
+		"description":    s.Values["description"],
+		"created":        s.Created.Format(JiraTimeLayout),
+		"updated":        s.At.Format(JiraTimeLayout),
+		"status":         named(s.Values["status"]),
+		"assignee":       named(s.Values["assignee"]),
+		"priority":       named(s.Values["priority"]),
+		"resolution":     named(s.Values["resolution"]),
+		"issuetype":      named(s.Values["issuetype"]),
+		"project":        map[string]string{"key": s.Project},
+		"labels":         splitNonEmpty(s.Values["labels"], " "),
+		"fixVersions":    versions,
+		SprintField:      sprints,
+		StoryPointsField: nil,
+	}
+	var points float64
+	if _, err := fmt.Sscanf(s.Values["Story Points"], "%g", &points); err == nil {
+		fields[StoryPointsField] = points
+	}
+
+	return map[string]interface{}{
+		"key":         s.Key,
+		"fields":      fields,
+		"snapshot_at": s.At.UTC().Format(time.RFC3339),
+	}
+}
+
+// TextVersion is one value of a text field such as the summary or
+// description, as set at At by Author.
+type TextVersion struct {
+	At     time.Time
+	Author string
+	Text   string
+	// Source is "changelog", "current" or the snapshot it was read from.
+	Source string
+}
+
+// TextHistory returns the successive values of a text field: the value at
+// creation, every changelog edit, and any extra versions (e.g. from
+// snapshots), ordered by time with consecutive duplicates dropped.
+func TextHistory(issue *JiraIssueWithSprints, changelog Changelog, field string, extra []TextVersion) []TextVersion {
+	var versions []TextVersion
+	var created time.Time
+	if issue != nil {
+		created, _ = issue.CreatedTime()
+	}
+
+	first := true
+	for _, h := range sortedHistories(changelog) {
+		for _, item := range h.entry.Items {
+			if !strings.EqualFold(item.Field, field) {
+				continue
+			}
+			if first {
+				versions = append(versions, TextVersion{At: created, Text: item.FromString, Source: "changelog"})
+				first = false
+			}
+			author := ""
+			if h.entry.Author != nil {
+				author = h.entry.Author.ID()
+			}
+			versions = append(versions, TextVersion{At: h.at, Author: author, Text: item.ToString, Source: "changelog"})
+		}
+	}
+	if first && issue != nil {
+		versions = append(versions, TextVersion{At: created, Text: currentValue(issue, field), Source: "current"})
+	}
+
+	versions = append(versions, extra...)
+	sort.SliceStable(versions, func(i, j int) bool { return versions[i].At.Before(versions[j].At) })
+
+	var deduped []TextVersion
+	for _, v := range versions {
+		if len(deduped) > 0 && deduped[len(deduped)-1].Text == v.Text {
+			continue
+		}
+		deduped = append(deduped, v)
+	}
+	return deduped
+}
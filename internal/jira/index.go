@@ -0,0 +1,303 @@
+package jira
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The index of a directory cache summarizes every issue in it, so listing
+// and filtering can skip the issues that do not match without reading
+// them. It lives below index/ rather than next to the issues, so saving
+// it leaves the cache version alone. UpdateCacheIndex keeps it current by
+// re-reading only the issue files whose size or modification time changed.
+const (
+	IndexDir  = "index"
+	IndexFile = "index.json"
+)
+
+// IndexSprint is a sprint of an indexed issue.
+type IndexSprint struct {
+	ID    int    `json:"id"`
+	Board int    `json:"board,omitempty"`
+	Name  string `json:"name"`
+	State string `json:"state,omitempty"`
+}
+
+// IndexEntry summarizes one cached issue.
+type IndexEntry struct {
+	Key            string        `json:"key"`
+	Project        string        `json:"project"`
+	Status         string        `json:"status"`
+	StatusCategory string        `json:"statusCategory,omitempty"`
+	Sprints        []IndexSprint `json:"sprints,omitempty"`
+	Points         *float64      `json:"points,omitempty"`
+	Updated        string        `json:"updated,omitempty"`
+	Fetched        string        `json:"fetched,omitempty"`
+	// Extracted holds the text of the fields of the IndexExtractors the
+	// issue has, by extractor name.
+	Extracted map[string]string `json:"extracted,omitempty"`
+
+	// File, Size and ModTime identify the indexed version of the file.
+	File    string `json:"file"`
+	Size    int64  `json:"size"`
+	ModTime int64  `json:"modTime"`
+}
+
+// CacheIndex maps issue keys to their summaries.
+type CacheIndex struct {
+	Version int `json:"version"`
+	// Fields are the custom field ids the sprints and points were read
+	// through, and the IndexExtractors; the index is rebuilt when they
+	// change.
+	Fields string                `json:"fields"`
+	Issues map[string]IndexEntry `json:"issues"`
+}
+
+// IndexExtractors are the extractors of the fields config whose values
+// the index keeps; see SetIndexExtractors.
+var IndexExtractors = Extractors{}
+
+// SetIndexExtractors makes the index keep the values of the configured
+// extractors that are not among the DefaultExtractors, whose fields are
+// either indexed already or too large to be worth it.
+func SetIndexExtractors(e Extractors) {
+	defaults := DefaultExtractors()
+	IndexExtractors = Extractors{}
+	for key, fe := range e {
+		if fe != defaults[key] {
+			IndexExtractors[key] = fe
+		}
+	}
+}
+
+func indexFields() string {
+	fields := SprintField + "," + StoryPointsField
+	for _, name := range IndexExtractors.Names() {
+		fe, _ := IndexExtractors.Lookup(name)
+		fields += "," + fe.Name + "=" + fe.Field + ":" + fe.Type
+	}
+	return fields
+}
+
+func newIndexEntry(issue JiraIssueWithSprints, name string, info os.FileInfo) IndexEntry {
+	e := IndexEntry{
+		Key:            issue.Key,
+		Project:        issue.Fields.Project.Key,
+		Status:         issue.Fields.Status.Name,
+		StatusCategory: issue.Fields.Status.StatusCategory.Key,
+		Points:         issue.Fields.StoryPoints,
+		Updated:        issue.Fields.Updated,
+		Fetched:        issue.Fetched,
+		File:           name,
+		Size:           info.Size(),
+		ModTime:        info.ModTime().UnixNano(),
+	}
+	for _, s := range issue.Fields.Sprints {
+		e.Sprints = append(e.Sprints, IndexSprint{ID: s.ID, Board: s.RapidViewID, Name: s.Name, State: s.State})
+	}
+	for _, name := range IndexExtractors.Names() {
+		fe, _ := IndexExtractors.Lookup(name)
+		if value, ok := fe.Extract(issue.Fields); ok {
+			if e.Extracted == nil {
+				e.Extracted = map[string]string{}
+			}
+			e.Extracted[fe.Name] = value.Text
+		}
+	}
+	return e
+}
+
+// Value returns the indexed value of an extractor, typed as it is, for
+// --where conditions; false when the issue has none or the extractor is
+// not indexed.
+func (e IndexEntry) Value(name string) (FieldValue, bool) {
+	fe, ok := IndexExtractors.Lookup(name)
+	if !ok {
+		return FieldValue{}, false
+	}
+	text, ok := e.Extracted[fe.Name]
+	if !ok {
+		return FieldValue{}, false
+	}
+	value := FieldValue{Text: text}
+	if fe.Type == ExtractNumber {
+		if n, err := strconv.ParseFloat(text, 64); err == nil {
+			value.Number, value.Numeric = n, true
+		}
+	}
+	return value, true
+}
+
+// Issue is the indexed part of the issue: key, project, status, sprints,
+// story points and timestamps. Other fields are empty.
+func (e IndexEntry) Issue() JiraIssueWithSprints {
+	issue := JiraIssueWithSprints{Key: e.Key, Fetched: e.Fetched}
+	issue.Fields.Project.Key = e.Project
+	issue.Fields.Status.Name = e.Status
+	issue.Fields.Status.StatusCategory.Key = e.StatusCategory
+	issue.Fields.StoryPoints = e.Points
+	issue.Fields.Updated = e.Updated
+	for _, s := range e.Sprints {
+		issue.Fields.Sprints = append(issue.Fields.Sprints, Sprint{ID: s.ID, RapidViewID: s.Board, Name: s.Name, State: s.State})
+	}
+	return issue
+}
+
+// InSprint reports whether the issue is in the named sprint, or in one
+// with the same canonical name.
+func (e IndexEntry) InSprint(name string) bool {
+	for _, s := range e.Sprints {
+		if SameSprint(s.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadCacheIndex reads index/index.json below dir; a cache without one,
+// or whose index was built through other custom fields, has an empty
+// index.
+func LoadCacheIndex(dir string) (*CacheIndex, error) {
+	x := &CacheIndex{Version: 1, Fields: indexFields(), Issues: map[string]IndexEntry{}}
+	name := filepath.Join(IndexDir, IndexFile)
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if os.IsNotExist(err) {
+		return x, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var saved CacheIndex
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, corruptEntry(name, err)
+	}
+	if saved.Fields != x.Fields || saved.Issues == nil {
+		return x, nil
+	}
+	return &saved, nil
+}
+
+// Save writes the index below dir.
+func (x *CacheIndex) Save(dir string) error {
+	data, err := json.Marshal(x)
+	if err != nil {
+		return fmt.Errorf("marshal cache index: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, IndexDir), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(dir, IndexDir, IndexFile), data)
+}
+
+// Keys lists the indexed issues of a project, or all of them, sorted.
+func (x *CacheIndex) Keys(project string) []string {
+	prefix := strings.ToUpper(project) + "-"
+	var keys []string
+	for key := range x.Issues {
+		if project == "" || strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Summaries lists the indexed part of the issues of a project, or of all
+// of them, sorted by key. See IndexEntry.Issue.
+func (x *CacheIndex) Summaries(project string) []JiraIssueWithSprints {
+	keys := x.Keys(project)
+	issues := make([]JiraIssueWithSprints, 0, len(keys))
+	for _, key := range keys {
+		issues = append(issues, x.Issues[key].Issue())
+	}
+	return issues
+}
+
+// UpdateCacheIndex brings the index of a directory cache up to date and
+// saves it when anything changed. Only new issue files and those whose
+// size or modification time differ from the index are read; issues whose
+// files are gone are dropped, and unreadable files are left out until
+// they are repaired. With rebuild every file is read again.
+func UpdateCacheIndex(dir string, rebuild bool) (*CacheIndex, error) {
+	x, err := LoadCacheIndex(dir)
+	if err != nil {
+		log.Printf("rebuilding cache index: %v", err)
+		rebuild = true
+	}
+	if rebuild {
+		x = &CacheIndex{Version: 1, Fields: indexFields(), Issues: map[string]IndexEntry{}}
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read dir: %w", err)
+	}
+
+	// Readers prefer the plain form of a file, then zstd, then gzip.
+	files := map[string]os.DirEntry{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !IsIssueFile(name) {
+			continue
+		}
+		key := issueFileKey(name)
+		if prev, ok := files[key]; ok && formRank(prev.Name()) < formRank(name) {
+			continue
+		}
+		files[key] = entry
+	}
+
+	changed := false
+	for key := range x.Issues {
+		if _, ok := files[key]; !ok {
+			delete(x.Issues, key)
+			changed = true
+		}
+	}
+	for key, entry := range files {
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		name := entry.Name()
+		if e, ok := x.Issues[key]; ok && e.File == name && e.Size == info.Size() && e.ModTime == info.ModTime().UnixNano() {
+			continue
+		}
+		if _, ok := x.Issues[key]; ok {
+			delete(x.Issues, key)
+			changed = true
+		}
+		data, err := ReadCacheFile(filepath.Join(dir, name))
+		if err != nil {
+			log.Printf("index: skipping %s: %v", name, err)
+			continue
+		}
+		var issue JiraIssueWithSprints
+		if err := json.Unmarshal(data, &issue); err != nil {
+			log.Printf("index: skipping %s: %v", name, corruptEntry(name, err))
+			continue
+		}
+		x.Issues[key] = newIndexEntry(issue, name, info)
+		changed = true
+	}
+	if changed {
+		if err := x.Save(dir); err != nil {
+			return nil, err
+		}
+	}
+	return x, nil
+}
+
+// Index brings the index of the cache directory up to date and returns
+// it. Archived issues are not indexed.
+func (s *DirStore) Index() (*CacheIndex, error) {
+	if err := s.Flush(); err != nil {
+		return nil, err
+	}
+	return UpdateCacheIndex(s.Dir, false)
+}
@@ -6,6 +6,13 @@ import (
 	"time"
 )
 
+// JiraTimeLayout is the timestamp format used by the Jira REST API.
+const JiraTimeLayout = "2006-01-02T15:04:05.000-0700"
+
+func ParseJiraTime(s string) (time.Time, error) {
+	return time.Parse(JiraTimeLayout, s)
+}
+
 type UpdatedIssue struct {
 	Key         string
 	UpdatedTime time.Time
@@ -41,35 +48,259 @@ func (s *SprintList) UnmarshalJSON(data []byte) error {
 	return fmt.Errorf("unsupported sprint format: %s", string(data))
 }
 
+type Status struct {
+	Name           string `json:"name"`
+	StatusCategory struct {
+		Key string `json:"key"`
+	} `json:"statusCategory"`
+}
+
+// IsDone reports whether the status belongs to the "done" category. Older
+// cached issues lack the category so well known terminal names are accepted.
+func (s Status) IsDone() bool {
+	if s.StatusCategory.Key != "" {
+		return s.StatusCategory.Key == "done"
+	}
+	switch s.Name {
+	case "Resolved", "Closed", "Done":
+		return true
+	}
+	return false
+}
+
+// Rank orders statuses by their category: 0 for to do, 1 for in progress
+// and 2 for done. Statuses without a category rank by IsDone and the usual
+// names of initial statuses.
+func (s Status) Rank() int {
+	switch s.StatusCategory.Key {
+	case "new":
+		return 0
+	case "indeterminate":
+		return 1
+	case "done":
+		return 2
+	}
+	switch {
+	case s.IsDone():
+		return 2
+	case s.Name == "New" || s.Name == "To Do" || s.Name == "Backlog" || s.Name == "Open":
+		return 0
+	}
+	return 1
+}
+
+type Version struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Archived    bool   `json:"archived"`
+	Released    bool   `json:"released"`
+	StartDate   string `json:"startDate,omitempty"`
+	ReleaseDate string `json:"releaseDate"`
+}
+
+type Component struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// User is a Jira user reference as found in assignee/reporter/author fields
+type User struct {
+	Name         string `json:"name"`
+	Key          string `json:"key"`
+	AccountID    string `json:"accountId"`
+	DisplayName  string `json:"displayName"`
+	EmailAddress string `json:"emailAddress"`
+}
+
+// ID returns the most stable identifier available for the user.
+func (u User) ID() string {
+	if u.Name != "" {
+		return u.Name
+	}
+	if u.AccountID != "" {
+		return u.AccountID
+	}
+	return u.DisplayName
+}
+
 // Fields is the inner portion of the issue
 type Fields struct {
 	Summary     string `json:"summary"`
 	Description string `json:"description"`
-	Created     string `json:"created`
+	Created     string `json:"created"`
+
+	Status Status `json:"status"`
 
-	Status struct {
+	Resolution *struct {
 		Name string `json:"name"`
-	} `json:"status"`
+	} `json:"resolution"`
 
-	IssueType struct {
+	Priority *struct {
 		Name string `json:"name"`
+	} `json:"priority"`
+
+	Updated        string `json:"updated"`
+	ResolutionDate string `json:"resolutiondate"`
+
+	Labels []string `json:"labels"`
+
+	IssueType struct {
+		Name    string `json:"name"`
+		Subtask bool   `json:"subtask"`
 	} `json:"issuetype"`
 
 	Parent struct {
 		Key string `json:"key"`
 	} `json:"parent"`
 
+	// Subtasks are listed on their parent issue.
+	Subtasks []IssueRef `json:"subtasks"`
+
 	Project struct {
 		Key string `json:"key"`
 	} `json:"project"`
 
+	Assignee *User `json:"assignee"`
+
+	FixVersions []Version `json:"fixVersions"`
+
+	Components []Component `json:"components"`
+
+	IssueLinks []IssueLink `json:"issuelinks"`
+
+	Attachments []Attachment `json:"attachment"`
+
+	// The tags hold the default custom field ids; see SetCustomFields.
+	EpicLink string `json:"customfield_12311140"`
+
 	Sprints SprintList `json:"customfield_12310940"`
+
+	StoryPoints          *float64 `json:"customfield_12310243"`
+	TimeOriginalEstimate *int     `json:"timeoriginalestimate"`
+	TimeEstimate         *int     `json:"timeestimate"`
+	TimeSpent            *int     `json:"timespent"`
+
+	// Categories are assigned locally by a Classifier, never by Jira.
+	Categories []string `json:"-"`
+
+	// Raw holds every field as returned by Jira so custom fields can be
+	// read through the configured extractors.
+	Raw map[string]json.RawMessage `json:"-"`
+}
+
+func (f *Fields) UnmarshalJSON(data []byte) error {
+	type fieldsAlias Fields
+	var alias fieldsAlias
+	if err := json.Unmarshal(data, &alias); err != nil {
+		return err
+	}
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*f = Fields(alias)
+	f.Raw = raw
+	return f.decodeCustomFields()
+}
+
+// decodeCustomFields re-reads the custom fields from Raw when they were
+// configured away from the ids in the struct tags.
+func (f *Fields) decodeCustomFields() error {
+	if SprintField != defaultSprintField {
+		f.Sprints = nil
+		if v, ok := f.Raw[SprintField]; ok {
+			if err := json.Unmarshal(v, &f.Sprints); err != nil {
+				return fmt.Errorf("decode %s: %w", SprintField, err)
+			}
+		}
+	}
+	if StoryPointsField != defaultStoryPointsField {
+		f.StoryPoints = nil
+		if v, ok := f.Raw[StoryPointsField]; ok {
+			if err := json.Unmarshal(v, &f.StoryPoints); err != nil {
+				return fmt.Errorf("decode %s: %w", StoryPointsField, err)
+			}
+		}
+	}
+	if EpicLinkField != defaultEpicLinkField {
+		f.EpicLink = ""
+		if v, ok := f.Raw[EpicLinkField]; ok {
+			if err := json.Unmarshal(v, &f.EpicLink); err != nil {
+				return fmt.Errorf("decode %s: %w", EpicLinkField, err)
+			}
+		}
+	}
+	return nil
 }
 
 // JiraIssueWithSprints represents a complete issue
 type JiraIssueWithSprints struct {
 	Key    string `json:"key"`
 	Fields Fields `json:"fields"`
+	// Fetched is when the issue was saved to the cache (RFC 3339).
+	Fetched string `json:"fetched,omitempty"`
+}
+
+// AssigneeID returns the assignee identifier or "" when unassigned.
+func (i JiraIssueWithSprints) AssigneeID() string {
+	if i.Fields.Assignee == nil {
+		return ""
+	}
+	return i.Fields.Assignee.ID()
+}
+
+// PriorityName returns the priority name or "Undefined" when unset.
+func (i JiraIssueWithSprints) PriorityName() string {
+	if i.Fields.Priority == nil || i.Fields.Priority.Name == "" {
+		return "Undefined"
+	}
+	return i.Fields.Priority.Name
+}
+
+func (i JiraIssueWithSprints) CreatedTime() (time.Time, error) {
+	return ParseJiraTime(i.Fields.Created)
+}
+
+func (i JiraIssueWithSprints) UpdatedTime() (time.Time, error) {
+	return ParseJiraTime(i.Fields.Updated)
+}
+
+// ResolvedTime parses the resolution date; it fails for unresolved issues.
+func (i JiraIssueWithSprints) ResolvedTime() (time.Time, error) {
+	return ParseJiraTime(i.Fields.ResolutionDate)
+}
+
+// ComponentNames lists the names of the components an issue belongs to.
+func (i JiraIssueWithSprints) ComponentNames() []string {
+	var names []string
+	for _, c := range i.Fields.Components {
+		names = append(names, c.Name)
+	}
+	return names
+}
+
+// IsDone reports whether the issue is resolved or in a done status.
+func (i JiraIssueWithSprints) IsDone() bool {
+	return i.Fields.Resolution != nil || i.Fields.Status.IsDone()
+}
+
+// HasFixVersion reports whether the issue targets the named fix version.
+func (i JiraIssueWithSprints) HasFixVersion(name string) bool {
+	for _, v := range i.Fields.FixVersions {
+		if v.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// EpicKey returns the epic an issue belongs to, via Epic Link or the parent.
+func (i JiraIssueWithSprints) EpicKey() string {
+	if i.Fields.EpicLink != "" {
+		return i.Fields.EpicLink
+	}
+	return i.Fields.Parent.Key
 }
 
 func ToChangelog(issue JiraIssueWithSprints) (*Changelog, error) {
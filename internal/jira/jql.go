@@ -0,0 +1,133 @@
+package jira
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// JQLTimeLayout is the minute precision JQL compares dates with.
+const JQLTimeLayout = "2006-01-02 15:04"
+
+// Sort directions of JQL.OrderBy.
+const (
+	Asc  = "ASC"
+	Desc = "DESC"
+)
+
+// JQL composes a search query out of clauses joined by AND, and an ORDER
+// BY. Every value is written as a quoted literal, so sprint names or
+// project keys holding quotes, spaces or reserved words cannot change the
+// query. Methods return a new query; the zero value matches every issue.
+type JQL struct {
+	clauses []jqlClause
+	order   []string
+}
+
+type jqlClause struct {
+	text string
+	// raw clauses are the user's JQL, parenthesized next to others.
+	raw bool
+}
+
+// QuoteJQL writes s as a JQL string literal.
+func QuoteJQL(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// plainField matches the field names JQL accepts unquoted: words, and
+// custom field references such as cf[12310940].
+var plainField = regexp.MustCompile(`^(?:[A-Za-z][A-Za-z0-9_]*|cf\[[0-9]+\])$`)
+
+// jqlField writes a field name, quoted when it is not a plain word, as
+// custom fields are by their display name ("Story Points").
+func jqlField(name string) string {
+	if plainField.MatchString(name) {
+		return name
+	}
+	return QuoteJQL(name)
+}
+
+// jqlValue writes a value as a JQL literal: integers bare, times at minute
+// precision in UTC, and anything else as a quoted string.
+func jqlValue(v any) string {
+	switch v := v.(type) {
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case time.Time:
+		return QuoteJQL(v.UTC().Format(JQLTimeLayout))
+	case string:
+		return QuoteJQL(v)
+	default:
+		return QuoteJQL(fmt.Sprint(v))
+	}
+}
+
+func (q JQL) where(clause jqlClause) JQL {
+	q.clauses = append(q.clauses[:len(q.clauses):len(q.clauses)], clause)
+	return q
+}
+
+// Equals adds field = value.
+func (q JQL) Equals(field string, value any) JQL {
+	return q.where(jqlClause{text: jqlField(field) + " = " + jqlValue(value)})
+}
+
+// Contains adds field ~ text, Jira's text search, which for sprints
+// matches their names.
+func (q JQL) Contains(field, text string) JQL {
+	return q.where(jqlClause{text: jqlField(field) + " ~ " + QuoteJQL(text)})
+}
+
+// Since adds field >= t, at minute precision.
+func (q JQL) Since(field string, t time.Time) JQL {
+	return q.where(jqlClause{text: jqlField(field) + " >= " + jqlValue(t)})
+}
+
+// In adds field IN (values...).
+func (q JQL) In(field string, values ...any) JQL {
+	literals := make([]string, len(values))
+	for i, v := range values {
+		literals[i] = jqlValue(v)
+	}
+	return q.where(jqlClause{text: jqlField(field) + " IN (" + strings.Join(literals, ", ") + ")"})
+}
+
+// Raw adds a query written in JQL by the user, as it is. Next to other
+// clauses it is parenthesized so its ORs stay within it; it must not have
+// an ORDER BY of its own.
+func (q JQL) Raw(query string) JQL {
+	return q.where(jqlClause{text: strings.TrimSpace(query), raw: true})
+}
+
+// OrderBy adds a sort key, Asc or Desc.
+func (q JQL) OrderBy(field, direction string) JQL {
+	if direction != Desc {
+		direction = Asc
+	}
+	q.order = append(q.order[:len(q.order):len(q.order)], jqlField(field)+" "+direction)
+	return q
+}
+
+// String writes the query.
+func (q JQL) String() string {
+	clauses := make([]string, len(q.clauses))
+	for i, c := range q.clauses {
+		clauses[i] = c.text
+		if c.raw && len(q.clauses) > 1 {
+			clauses[i] = "(" + c.text + ")"
+		}
+	}
+	s := strings.Join(clauses, " AND ")
+	if len(q.order) > 0 {
+		if s != "" {
+			s += " "
+		}
+		s += "ORDER BY " + strings.Join(q.order, ", ")
+	}
+	return s
+}
@@ -0,0 +1,73 @@
+package jira
+
+import "strings"
+
+type IssueLinkType struct {
+	Name    string `json:"name"`
+	Inward  string `json:"inward"`
+	Outward string `json:"outward"`
+}
+
+// LinkedIssue is the abbreviated issue Jira embeds inside an issue link.
+type LinkedIssue struct {
+	Key    string `json:"key"`
+	Fields struct {
+		Summary string `json:"summary"`
+		Status  Status `json:"status"`
+	} `json:"fields"`
+}
+
+type IssueLink struct {
+	ID           string        `json:"id"`
+	Type         IssueLinkType `json:"type"`
+	InwardIssue  *LinkedIssue  `json:"inwardIssue,omitempty"`
+	OutwardIssue *LinkedIssue  `json:"outwardIssue,omitempty"`
+}
+
+// blocksOutward reports whether the outward direction of a link type means
+// "this issue must finish before the other one".
+func blocksOutward(t IssueLinkType) bool {
+	switch strings.ToLower(t.Name) {
+	case "blocks":
+		return true
+	}
+	return strings.HasPrefix(strings.ToLower(t.Outward), "blocks")
+}
+
+// dependsOutward reports whether the outward direction of a link type means
+// "this issue waits on the other one".
+func dependsOutward(t IssueLinkType) bool {
+	switch strings.ToLower(t.Name) {
+	case "depend", "dependency":
+		return true
+	}
+	return strings.HasPrefix(strings.ToLower(t.Outward), "depends on")
+}
+
+// Blockers returns the linked issues that block this issue.
+func (i JiraIssueWithSprints) Blockers() []LinkedIssue {
+	var blockers []LinkedIssue
+	for _, link := range i.Fields.IssueLinks {
+		switch {
+		case link.InwardIssue != nil && blocksOutward(link.Type):
+			blockers = append(blockers, *link.InwardIssue)
+		case link.OutwardIssue != nil && dependsOutward(link.Type):
+			blockers = append(blockers, *link.OutwardIssue)
+		}
+	}
+	return blockers
+}
+
+// Blocks returns the linked issues that this issue blocks.
+func (i JiraIssueWithSprints) Blocks() []LinkedIssue {
+	var blocked []LinkedIssue
+	for _, link := range i.Fields.IssueLinks {
+		switch {
+		case link.OutwardIssue != nil && blocksOutward(link.Type):
+			blocked = append(blocked, *link.OutwardIssue)
+		case link.InwardIssue != nil && dependsOutward(link.Type):
+			blocked = append(blocked, *link.InwardIssue)
+		}
+	}
+	return blocked
+}
@@ -0,0 +1,145 @@
+package jira
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// LockFile is the advisory lock of a directory cache. Processes writing
+// the cache, such as fetch, hold it exclusively and record themselves in
+// it; reports hold it shared, so they never read a sync half done.
+const LockFile = ".lock"
+
+// ErrCacheLocked is wrapped by the errors of locks still held by another
+// process when the wait for them ran out.
+var ErrCacheLocked = errors.New("cache is locked")
+
+// errLockBusy is returned by flock when another process holds the lock.
+var errLockBusy = errors.New("lock busy")
+
+// lockPoll is the time between attempts to take a busy lock.
+const lockPoll = 100 * time.Millisecond
+
+// CacheLock is a held lock of a directory cache.
+type CacheLock struct {
+	f *os.File
+}
+
+// LockCache takes the lock of a directory cache, exclusive or shared,
+// waiting up to wait while another process holds it. Readers of a cache
+// they cannot create the lock file in, such as a read-only copy, go
+// without it.
+func LockCache(dir string, exclusive bool, wait time.Duration) (*CacheLock, error) {
+	name := filepath.Join(dir, LockFile)
+	f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil && !exclusive {
+		if f, err = os.Open(name); err != nil {
+			slog.Debug("cache not locked", "dir", dir, "err", err)
+			return nil, nil
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("lock cache: %w", err)
+	}
+	deadline := time.Now().Add(wait)
+	for {
+		err = flock(f, exclusive)
+		if err != errLockBusy || !time.Now().Before(deadline) {
+			break
+		}
+		time.Sleep(lockPoll)
+	}
+	if err == errLockBusy {
+		// Only a writer records itself; a writer kept waiting may be
+		// waiting on readers.
+		holder := ""
+		if !exclusive {
+			if data, err := os.ReadFile(name); err == nil && len(data) > 0 {
+				holder = " (" + strings.TrimSpace(string(data)) + ")"
+			}
+		}
+		f.Close()
+		return nil, fmt.Errorf("%s: %w by another process%s", dir, ErrCacheLocked, holder)
+	}
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("lock cache: %w", err)
+	}
+	if exclusive {
+		command := filepath.Base(os.Args[0])
+		if len(os.Args) > 1 {
+			command += " " + os.Args[1]
+		}
+		if err := f.Truncate(0); err == nil {
+			f.WriteAt([]byte(fmt.Sprintf("pid %d, %s\n", os.Getpid(), command)), 0)
+		}
+	}
+	return &CacheLock{f: f}, nil
+}
+
+// Unlock releases the lock. Unlocking a nil lock does nothing.
+func (l *CacheLock) Unlock() error {
+	if l == nil || l.f == nil {
+		return nil
+	}
+	err := funlock(l.f)
+	if closeErr := l.f.Close(); err == nil {
+		err = closeErr
+	}
+	l.f = nil
+	return err
+}
+
+// Lock takes the lock of the cache directory until Unlock or Close.
+func (s *DirStore) Lock(exclusive bool, wait time.Duration) error {
+	if s.lock != nil {
+		return fmt.Errorf("%s: cache already locked", s.Dir)
+	}
+	lock, err := LockCache(s.Dir, exclusive, wait)
+	if err != nil {
+		return err
+	}
+	s.lock = lock
+	return nil
+}
+
+// Unlock releases the lock taken by Lock, after writing what is batched.
+func (s *DirStore) Unlock() error {
+	err := s.Flush()
+	if unlockErr := s.lock.Unlock(); err == nil {
+		err = unlockErr
+	}
+	s.lock = nil
+	return err
+}
+
+// LockStore takes the lock of a directory cache, seen through a
+// read-through store too. Other backends lock themselves and are left
+// alone.
+func LockStore(store Store, exclusive bool, wait time.Duration) error {
+	if dir := dirStoreOf(store); dir != nil {
+		return dir.Lock(exclusive, wait)
+	}
+	return nil
+}
+
+// UnlockStore releases the lock taken by LockStore.
+func UnlockStore(store Store) error {
+	if dir := dirStoreOf(store); dir != nil {
+		return dir.Unlock()
+	}
+	return nil
+}
+
+func dirStoreOf(store Store) *DirStore {
+	if rt, ok := store.(*ReadThroughStore); ok {
+		store = rt.Store
+	}
+	dir, _ := store.(*DirStore)
+	return dir
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package jira
+
+import "os"
+
+// Without flock the lock file is created but not locked.
+
+func flock(f *os.File, exclusive bool) error { return nil }
+
+func funlock(f *os.File) error { return nil }
@@ -0,0 +1,309 @@
+package jira
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ManifestFile        = "manifest.json"
+	ManifestJournalFile = "manifest.journal"
+)
+
+type ManifestEntry struct {
+	SHA256   string `json:"sha256"`
+	Size     int64  `json:"size"`
+	Recorded string `json:"recorded"`
+}
+
+// Manifest maps cache file names to their content hash. Writes append to a
+// journal so it can be kept current without rewriting the whole manifest;
+// LoadManifest replays the journal over the last compacted manifest.
+type Manifest struct {
+	Version int                      `json:"version"`
+	Files   map[string]ManifestEntry `json:"files"`
+}
+
+func hashBytes(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func newManifestEntry(data []byte) ManifestEntry {
+	return ManifestEntry{
+		SHA256:   hashBytes(data),
+		Size:     int64(len(data)),
+		Recorded: time.Now().UTC().Format(time.RFC3339),
+	}
+}
+
+// isManifestFile reports whether a cache file is part of the manifest itself.
+func isManifestFile(name string) bool {
+	return name == ManifestFile || name == ManifestJournalFile || strings.HasPrefix(name, ".")
+}
+
+func LoadManifest(dir string) (*Manifest, error) {
+	m := &Manifest{Version: 1, Files: map[string]ManifestEntry{}}
+
+	data, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("read manifest: %w", err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, m); err != nil {
+			return nil, corruptEntry(ManifestFile, err)
+		}
+		if m.Files == nil {
+			m.Files = map[string]ManifestEntry{}
+		}
+	}
+
+	f, err := os.Open(filepath.Join(dir, ManifestJournalFile))
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read manifest journal: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var line struct {
+			Name    string `json:"name"`
+			Removed bool   `json:"removed,omitempty"`
+			ManifestEntry
+		}
+		// a torn final line from an interrupted write is ignored
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil || line.Name == "" {
+			continue
+		}
+		if line.Removed {
+			delete(m.Files, line.Name)
+			continue
+		}
+		m.Files[line.Name] = line.ManifestEntry
+	}
+	return m, scanner.Err()
+}
+
+// Save writes the manifest and truncates the journal it now contains.
+func (m *Manifest) Save(dir string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal manifest: %w", err)
+	}
+	if err := writeFileAtomic(filepath.Join(dir, ManifestFile), data); err != nil {
+		return fmt.Errorf("write manifest: %w", err)
+	}
+	if err := os.Remove(filepath.Join(dir, ManifestJournalFile)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("truncate manifest journal: %w", err)
+	}
+	return nil
+}
+
+var journalMu sync.Mutex
+
+// AppendManifestJournal records the hash of a freshly written cache file.
+func AppendManifestJournal(dir, name string, data []byte) error {
+	line, err := json.Marshal(struct {
+		Name string `json:"name"`
+		ManifestEntry
+	}{Name: name, ManifestEntry: newManifestEntry(data)})
+	if err != nil {
+		return err
+	}
+
+	journalMu.Lock()
+	defer journalMu.Unlock()
+	f, err := os.OpenFile(filepath.Join(dir, ManifestJournalFile), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+// appendManifestRemoval records that a cache file was deleted.
+func appendManifestRemoval(dir, name string) error {
+	line, err := json.Marshal(struct {
+		Name    string `json:"name"`
+		Removed bool   `json:"removed"`
+	}{Name: name, Removed: true})
+	if err != nil {
+		return err
+	}
+
+	journalMu.Lock()
+	defer journalMu.Unlock()
+	f, err := os.OpenFile(filepath.Join(dir, ManifestJournalFile), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+func cacheFileNames(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read dir: %w", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() || isManifestFile(entry.Name()) {
+			continue
+		}
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+type hashResult struct {
+	name  string
+	entry ManifestEntry
+	err   error
+}
+
+// hashFiles hashes the named files using a pool of workers.
+func hashFiles(dir string, names []string, workers int) []hashResult {
+	if workers < 1 {
+		workers = 1
+	}
+	jobs := make(chan int)
+	results := make([]hashResult, len(names))
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				data, err := os.ReadFile(filepath.Join(dir, names[i]))
+				results[i] = hashResult{name: names[i], err: err}
+				if err == nil {
+					results[i].entry = newManifestEntry(data)
+				}
+			}
+		}()
+	}
+	for i := range names {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+// BuildManifest hashes every file in the cache and writes a fresh manifest.
+func BuildManifest(dir string, workers int) (*Manifest, error) {
+	names, err := cacheFileNames(dir)
+	if err != nil {
+		return nil, err
+	}
+	m := &Manifest{Version: 1, Files: map[string]ManifestEntry{}}
+	for _, r := range hashFiles(dir, names, workers) {
+		if r.err != nil {
+			return nil, fmt.Errorf("hash %s: %w", r.name, r.err)
+		}
+		m.Files[r.name] = r.entry
+	}
+	return m, m.Save(dir)
+}
+
+// Manifest problem kinds reported by VerifyManifest.
+const (
+	ManifestMissing    = "missing"
+	ManifestModified   = "modified"
+	ManifestTruncated  = "size-mismatch"
+	ManifestUntracked  = "untracked"
+	ManifestUnreadable = "unreadable"
+)
+
+type ManifestProblem struct {
+	Name   string
+	Kind   string
+	Detail string
+}
+
+// VerifyManifest rehashes the cache and compares it against the manifest.
+func VerifyManifest(dir string, workers int) ([]ManifestProblem, error) {
+	m, err := LoadManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+	names, err := cacheFileNames(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var problems []ManifestProblem
+	onDisk := make(map[string]bool, len(names))
+	for _, r := range hashFiles(dir, names, workers) {
+		onDisk[r.name] = true
+		expected, tracked := m.Files[r.name]
+		switch {
+		case r.err != nil:
+			problems = append(problems, ManifestProblem{Name: r.name, Kind: ManifestUnreadable, Detail: r.err.Error()})
+		case !tracked:
+			problems = append(problems, ManifestProblem{Name: r.name, Kind: ManifestUntracked})
+		case expected.Size != r.entry.Size:
+			problems = append(problems, ManifestProblem{Name: r.name, Kind: ManifestTruncated, Detail: fmt.Sprintf("expected %d bytes, found %d", expected.Size, r.entry.Size)})
+		case expected.SHA256 != r.entry.SHA256:
+			problems = append(problems, ManifestProblem{Name: r.name, Kind: ManifestModified, Detail: "content hash differs"})
+		}
+	}
+
+	var missing []string
+	for name := range m.Files {
+		if !onDisk[name] {
+			missing = append(missing, name)
+		}
+	}
+	sort.Strings(missing)
+	for _, name := range missing {
+		problems = append(problems, ManifestProblem{Name: name, Kind: ManifestMissing})
+	}
+	return problems, nil
+}
+
+// CacheVersion fingerprints the state of a cache directory cheaply. Every
+// write through a DirStore appends to the manifest journal, so its size and
+// modification time change whenever the cached data does.
+func CacheVersion(dir string) (string, error) {
+	h := sha256.New()
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for _, name := range []string{".", ManifestFile, ManifestJournalFile} {
+		info, err := os.Stat(filepath.Join(abs, name))
+		if os.IsNotExist(err) {
+			fmt.Fprintf(h, "%s:absent\n", name)
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%s:%d:%d\n", name, info.Size(), info.ModTime().UnixNano())
+	}
+	entries, err := os.ReadDir(abs)
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintf(h, "entries:%d\n", len(entries))
+	return hex.EncodeToString(h.Sum(nil))[:16], nil
+}
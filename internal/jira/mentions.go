@@ -0,0 +1,68 @@
+package jira
+
+import (
+	"encoding/json"
+	"regexp"
+)
+
+// Sources of mentions.
+const (
+	MentionInDescription = "description"
+	MentionInComment     = "comment"
+)
+
+// Mention is a user @mentioned in an issue.
+type Mention struct {
+	// User is the user name, or account id on Jira Cloud, as written.
+	User string `json:"user"`
+	// Source is MentionInDescription or MentionInComment.
+	Source string `json:"source"`
+	// By is who wrote the comment, for mentions in comments.
+	By string `json:"by,omitempty"`
+}
+
+// Jira keeps @mentions in wiki markup as [~name], or [~accountid:ID] on
+// Jira Cloud. Mentions in rich text converted from API v3 documents are
+// only a display name and are not recognized.
+var mentionPattern = regexp.MustCompile(`\[~(?:accountid:)?([^\[\]\s|]+)\]`)
+
+// FindMentions returns the users a text mentions, each once, in the order
+// they appear.
+func FindMentions(text string) []string {
+	var users []string
+	seen := map[string]bool{}
+	for _, m := range mentionPattern.FindAllStringSubmatch(text, -1) {
+		if !seen[m[1]] {
+			seen[m[1]] = true
+			users = append(users, m[1])
+		}
+	}
+	return users
+}
+
+// Mentions collects the users mentioned in the description of an issue
+// and in each of its comments: the cached ones, or those embedded in the
+// issue when none are cached. A user mentioned in several comments is
+// listed once for each.
+func (i JiraIssueWithSprints) Mentions(comments []Comment) []Mention {
+	var out []Mention
+	for _, user := range FindMentions(i.Fields.Description) {
+		out = append(out, Mention{User: user, Source: MentionInDescription})
+	}
+	if comments == nil {
+		var embedded CommentList
+		if raw, ok := i.Fields.Raw["comment"]; ok && json.Unmarshal(raw, &embedded) == nil {
+			comments = embedded.Comments
+		}
+	}
+	for _, c := range comments {
+		by := ""
+		if c.Author != nil {
+			by = c.Author.ID()
+		}
+		for _, user := range FindMentions(c.Body) {
+			out = append(out, Mention{User: user, Source: MentionInComment, By: by})
+		}
+	}
+	return out
+}
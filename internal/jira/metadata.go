@@ -0,0 +1,147 @@
+package jira
+
+import (
+	"sort"
+	"strings"
+)
+
+// MetadataValue is a distinct value of an issue field seen in the cache,
+// with the number of cached issues carrying it.
+type MetadataValue struct {
+	Project string
+	Name    string
+	// Detail is field specific: the status category, the version release
+	// date, or the sprint state.
+	Detail string
+	Issues int
+}
+
+// BoardSummary describes a board as seen through the sprints of cached
+// issues.
+type BoardSummary struct {
+	ID      int
+	Sprints []string
+	Active  []string
+	Issues  int
+}
+
+func addValue(values map[string]*MetadataValue, project, name, detail string) {
+	if name == "" {
+		return
+	}
+	k := project + "\x00" + name
+	v := values[k]
+	if v == nil {
+		v = &MetadataValue{Project: project, Name: name, Detail: detail}
+		values[k] = v
+	}
+	if v.Detail == "" {
+		v.Detail = detail
+	}
+	v.Issues++
+}
+
+func sortedValues(values map[string]*MetadataValue) []MetadataValue {
+	out := make([]MetadataValue, 0, len(values))
+	for _, v := range values {
+		out = append(out, *v)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Project != out[j].Project {
+			return out[i].Project < out[j].Project
+		}
+		return strings.ToLower(out[i].Name) < strings.ToLower(out[j].Name)
+	})
+	return out
+}
+
+// CachedStatuses lists the statuses of cached issues. Statuses are shared
+// across projects so Project is left empty.
+func CachedStatuses(issues []JiraIssueWithSprints) []MetadataValue {
+	values := map[string]*MetadataValue{}
+	for _, issue := range issues {
+		addValue(values, "", issue.Fields.Status.Name, issue.Fields.Status.StatusCategory.Key)
+	}
+	return sortedValues(values)
+}
+
+// CachedComponents lists the components of cached issues per project.
+func CachedComponents(issues []JiraIssueWithSprints) []MetadataValue {
+	values := map[string]*MetadataValue{}
+	for _, issue := range issues {
+		for _, c := range issue.Fields.Components {
+			addValue(values, issue.Fields.Project.Key, c.Name, "")
+		}
+	}
+	return sortedValues(values)
+}
+
+// CachedVersions lists the fix versions of cached issues per project, with
+// the release date (or "released"/"unreleased") as Detail.
+func CachedVersions(issues []JiraIssueWithSprints) []MetadataValue {
+	values := map[string]*MetadataValue{}
+	for _, issue := range issues {
+		for _, v := range issue.Fields.FixVersions {
+			detail := v.ReleaseDate
+			if detail == "" {
+				detail = "unreleased"
+				if v.Released {
+					detail = "released"
+				}
+			}
+			addValue(values, issue.Fields.Project.Key, v.Name, detail)
+		}
+	}
+	return sortedValues(values)
+}
+
+// CachedSprints lists the sprints of cached issues with their state.
+func CachedSprints(issues []JiraIssueWithSprints) []MetadataValue {
+	values := map[string]*MetadataValue{}
+	for _, issue := range issues {
+		for _, s := range issue.Fields.Sprints {
+			addValue(values, "", s.Name, strings.ToLower(s.State))
+		}
+	}
+	return sortedValues(values)
+}
+
+// CachedBoards lists the boards (rapid views) referenced by the sprints of
+// cached issues.
+func CachedBoards(issues []JiraIssueWithSprints) []BoardSummary {
+	boards := map[int]*BoardSummary{}
+	seen := map[int]map[string]bool{}
+	for _, issue := range issues {
+		counted := map[int]bool{}
+		for _, s := range issue.Fields.Sprints {
+			if s.RapidViewID == 0 {
+				continue
+			}
+			b := boards[s.RapidViewID]
+			if b == nil {
+				b = &BoardSummary{ID: s.RapidViewID}
+				boards[s.RapidViewID] = b
+				seen[s.RapidViewID] = map[string]bool{}
+			}
+			if !counted[b.ID] {
+				b.Issues++
+				counted[b.ID] = true
+			}
+			if !seen[b.ID][s.Name] {
+				seen[b.ID][s.Name] = true
+				b.Sprints = append(b.Sprints, s.Name)
+				if strings.EqualFold(s.State, "active") {
+					b.Active = append(b.Active, s.Name)
+				}
+			}
+		}
+	}
+	out := make([]BoardSummary, 0, len(boards))
+	for _, b := range boards {
+		sort.Strings(b.Sprints)
+		sort.Strings(b.Active)
+		out = append(out, *b)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
@@ -0,0 +1,68 @@
+package jira
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+type Project struct {
+	ID   string `json:"id"`
+	Key  string `json:"key"`
+	Name string `json:"name"`
+}
+
+// ListProjects returns every project visible to the token.
+func (c *Client) ListProjects(ctx context.Context) ([]Project, error) {
+	body, err := c.Get(ctx, c.apiURL(ctx, "/project"))
+	if err != nil {
+		return nil, fmt.Errorf("list projects: %w", err)
+	}
+	var projects []Project
+	if err := json.Unmarshal(body, &projects); err != nil {
+		return nil, fmt.Errorf("parse projects: %w", err)
+	}
+	return projects, nil
+}
+
+// DiscoverProjects returns the keys of visible projects matching a glob
+// pattern such as "RHOAI*", compared case-insensitively.
+func (c *Client) DiscoverProjects(ctx context.Context, pattern string) ([]string, error) {
+	pattern = strings.ToUpper(pattern)
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid project pattern %q: %w", pattern, err)
+	}
+	projects, err := c.ListProjects(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	for _, p := range projects {
+		if ok, _ := path.Match(pattern, strings.ToUpper(p.Key)); ok {
+			keys = append(keys, p.Key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// ProjectComponents returns the names of the components defined in a
+// project, whether or not any issue uses them.
+func (c *Client) ProjectComponents(ctx context.Context, project string) ([]string, error) {
+	body, err := c.Get(ctx, c.apiURL(ctx, "/project/%s/components", project))
+	if err != nil {
+		return nil, fmt.Errorf("list components of %s: %w", project, err)
+	}
+	var components []Component
+	if err := json.Unmarshal(body, &components); err != nil {
+		return nil, fmt.Errorf("parse components of %s: %w", project, err)
+	}
+	names := make([]string, 0, len(components))
+	for _, comp := range components {
+		names = append(names, comp.Name)
+	}
+	return names, nil
+}
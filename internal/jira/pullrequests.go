@@ -0,0 +1,130 @@
+package jira
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Kinds of pull requests.
+const (
+	PullRequestGitHub = "github"
+	PullRequestGitLab = "gitlab"
+)
+
+// Sources of pull request links other than fields.
+const (
+	PullRequestInDescription = "description"
+	PullRequestInComment     = "comment"
+)
+
+// PullRequest is a GitHub pull request or GitLab merge request an issue
+// links to.
+type PullRequest struct {
+	// Kind is PullRequestGitHub or PullRequestGitLab.
+	Kind string `json:"kind"`
+	// Server is the host name: github.com, gitlab.com or a self-hosted
+	// instance.
+	Server string `json:"server"`
+	// Repo is owner/name on GitHub and the full project path on GitLab.
+	Repo   string `json:"repo"`
+	Number int    `json:"number"`
+	// Source is where the link was found: PullRequestInDescription,
+	// PullRequestInComment or the id of the field holding it, such as a
+	// "Git Pull Request" custom field.
+	Source string `json:"source"`
+}
+
+// URL is the canonical web address of the pull request.
+func (p PullRequest) URL() string {
+	if p.Kind == PullRequestGitLab {
+		return fmt.Sprintf("https://%s/%s/-/merge_requests/%d", p.Server, p.Repo, p.Number)
+	}
+	return fmt.Sprintf("https://%s/%s/pull/%d", p.Server, p.Repo, p.Number)
+}
+
+var (
+	// Any host serving /owner/repo/pull/N is taken for GitHub, so GitHub
+	// Enterprise instances are recognized too.
+	githubPullPattern  = regexp.MustCompile(`https?://([A-Za-z0-9.-]+(?::\d+)?)/([\w.-]+/[\w.-]+)/pull/(\d+)`)
+	gitlabMergePattern = regexp.MustCompile(`https?://([A-Za-z0-9.-]+(?::\d+)?)/((?:[\w.-]+/)+[\w.-]+)/-/merge_requests/(\d+)`)
+)
+
+// FindPullRequests returns the pull and merge requests a text links to,
+// each once, in the order they appear.
+func FindPullRequests(text, source string) []PullRequest {
+	type match struct {
+		at int
+		pr PullRequest
+	}
+	var matches []match
+	for _, p := range []struct {
+		kind    string
+		pattern *regexp.Regexp
+	}{{PullRequestGitHub, githubPullPattern}, {PullRequestGitLab, gitlabMergePattern}} {
+		for _, m := range p.pattern.FindAllStringSubmatchIndex(text, -1) {
+			number, err := strconv.Atoi(text[m[6]:m[7]])
+			if err != nil {
+				continue
+			}
+			matches = append(matches, match{at: m[0], pr: PullRequest{
+				Kind:   p.kind,
+				Server: strings.ToLower(text[m[2]:m[3]]),
+				Repo:   text[m[4]:m[5]],
+				Number: number,
+				Source: source,
+			}})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].at < matches[j].at })
+	var out []PullRequest
+	seen := map[string]bool{}
+	for _, m := range matches {
+		if url := m.pr.URL(); !seen[url] {
+			seen[url] = true
+			out = append(out, m.pr)
+		}
+	}
+	return out
+}
+
+// PullRequests collects the pull requests an issue links to: first those
+// in its fields, where development integrations and "Git Pull Request"
+// custom fields keep them, then those mentioned in its description and
+// comments, cached or embedded in the issue. Each is listed once, with
+// the first place it was found.
+func (i JiraIssueWithSprints) PullRequests(comments []Comment) []PullRequest {
+	var out []PullRequest
+	seen := map[string]bool{}
+	add := func(prs []PullRequest) {
+		for _, pr := range prs {
+			if url := pr.URL(); !seen[url] {
+				seen[url] = true
+				out = append(out, pr)
+			}
+		}
+	}
+
+	fields := make([]string, 0, len(i.Fields.Raw))
+	for id := range i.Fields.Raw {
+		switch id {
+		case "description", "comment":
+		default:
+			fields = append(fields, id)
+		}
+	}
+	sort.Strings(fields)
+	for _, id := range fields {
+		add(FindPullRequests(string(i.Fields.Raw[id]), id))
+	}
+	add(FindPullRequests(i.Fields.Description, PullRequestInDescription))
+	for _, c := range comments {
+		add(FindPullRequests(c.Body, PullRequestInComment))
+	}
+	// Issues fetched with their first comments embed them, which covers
+	// caches saved without comment files.
+	add(FindPullRequests(string(i.Fields.Raw["comment"]), PullRequestInComment))
+	return out
+}
@@ -0,0 +1,98 @@
+package jira
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io/fs"
+	"log"
+	"strings"
+)
+
+// IsNotCached reports whether a store read failed because the entry is not
+// in the cache, as opposed to being unreadable.
+func IsNotCached(err error) bool {
+	return errors.Is(err, fs.ErrNotExist) || errors.Is(err, sql.ErrNoRows)
+}
+
+// ReadThroughStore fetches issues missing from the cache from Jira when
+// they are first read, and saves them, so exploring the cache does not
+// dead-end on gaps in the mirror. Each key is fetched at most once, and
+// keys the token was denied are not retried.
+type ReadThroughStore struct {
+	Store
+	Client *Client
+	ctx    context.Context
+	tried  map[string]bool
+	// Fetched counts the issues added to the cache on demand.
+	Fetched int
+}
+
+func NewReadThroughStore(ctx context.Context, store Store, client *Client) *ReadThroughStore {
+	return &ReadThroughStore{Store: store, Client: client, ctx: ctx, tried: map[string]bool{}}
+}
+
+// fetch syncs a missing issue into the cache, reporting whether it is now
+// there.
+func (s *ReadThroughStore) fetch(key string) bool {
+	if s.tried[key] || s.Store.IsDenied(key) {
+		return false
+	}
+	s.tried[key] = true
+	if err := s.Client.SyncIssue(s.ctx, s.Store, key); err != nil {
+		log.Printf("%s is not cached and could not be fetched: %v", key, err)
+		return false
+	}
+	if flusher, ok := s.Store.(interface{ Flush() error }); ok {
+		if err := flusher.Flush(); err != nil {
+			log.Printf("failed to save %s: %v", key, err)
+			return false
+		}
+	}
+	s.Fetched++
+	log.Printf("fetched %s from Jira into the cache", key)
+	return true
+}
+
+func (s *ReadThroughStore) ReadIssue(key string) (JiraIssueWithSprints, error) {
+	issue, err := s.Store.ReadIssue(key)
+	if IsNotCached(err) && s.fetch(key) {
+		return s.Store.ReadIssue(key)
+	}
+	return issue, err
+}
+
+func (s *ReadThroughStore) ReadChangelog(key string) (Changelog, error) {
+	changelog, err := s.Store.ReadChangelog(key)
+	if IsNotCached(err) && s.fetch(key) {
+		return s.Store.ReadChangelog(key)
+	}
+	return changelog, err
+}
+
+// LoadLinked adds the uncached blockers and blocked issues linked from
+// issues when the store reads through to Jira; other stores return issues
+// unchanged. Reports use it so cross-project links resolve to real issues.
+func LoadLinked(store Store, issues []JiraIssueWithSprints) []JiraIssueWithSprints {
+	if _, ok := store.(*ReadThroughStore); !ok {
+		return issues
+	}
+	have := make(map[string]bool, len(issues))
+	for _, issue := range issues {
+		have[issue.Key] = true
+	}
+	var linked []JiraIssueWithSprints
+	for _, issue := range issues {
+		for _, l := range append(issue.Blockers(), issue.Blocks()...) {
+			key := strings.ToUpper(l.Key)
+			if have[key] {
+				continue
+			}
+			have[key] = true
+			if next, err := store.ReadIssue(key); err == nil {
+				linked = append(linked, next)
+			}
+		}
+	}
+	return append(issues, linked...)
+}
@@ -0,0 +1,205 @@
+package jira
+
+import (
+	"time"
+)
+
+// ColumnType is the type of an exported column, for formats such as
+// Parquet that store typed columns rather than JSON values.
+type ColumnType int
+
+const (
+	ColumnString ColumnType = iota
+	ColumnInt
+	ColumnFloat
+	// ColumnTime columns hold UTC timestamps.
+	ColumnTime
+	// ColumnStrings columns hold a list of strings, never null.
+	ColumnStrings
+)
+
+// Column is one typed column of an exported table.
+type Column struct {
+	Name string
+	Type ColumnType
+}
+
+var issueSchema = []Column{
+	{"key", ColumnString},
+	{"project", ColumnString},
+	{"type", ColumnString},
+	{"status", ColumnString},
+	{"status_category", ColumnString},
+	{"priority", ColumnString},
+	{"resolution", ColumnString},
+	{"assignee", ColumnString},
+	{"summary", ColumnString},
+	{"created", ColumnTime},
+	{"updated", ColumnTime},
+	{"resolved", ColumnTime},
+	{"labels", ColumnStrings},
+	{"components", ColumnStrings},
+	{"fix_versions", ColumnStrings},
+	{"sprints", ColumnStrings},
+	{"story_points", ColumnFloat},
+	{"epic", ColumnString},
+	{"parent", ColumnString},
+	// Jira reports estimates in seconds.
+	{"original_estimate", ColumnInt},
+	{"remaining_estimate", ColumnInt},
+	{"time_spent", ColumnInt},
+}
+
+// EventSchema are the columns of a changelog event from FieldChanges.
+var EventSchema = []Column{
+	{"key", ColumnString},
+	{"project", ColumnString},
+	{"at", ColumnTime},
+	{"author", ColumnString},
+	{"field", ColumnString},
+	{"from", ColumnString},
+	{"to", ColumnString},
+	{"fromId", ColumnString},
+	{"toId", ColumnString},
+}
+
+func columnNames(columns []Column) []string {
+	names := make([]string, len(columns))
+	for i, c := range columns {
+		names[i] = c.Name
+	}
+	return names
+}
+
+// IssueSchema returns the issue columns followed by those of the
+// extractors that do not clash with them: floats for number extractors,
+// strings otherwise.
+func IssueSchema(extractors Extractors) []Column {
+	columns := append([]Column{}, issueSchema...)
+	taken := map[string]bool{}
+	for _, c := range columns {
+		taken[c.Name] = true
+	}
+	for _, name := range extractors.Names() {
+		if taken[name] {
+			continue
+		}
+		fe, _ := extractors.Lookup(name)
+		typ := ColumnString
+		if fe.Type == ExtractNumber {
+			typ = ColumnFloat
+		}
+		columns = append(columns, Column{Name: name, Type: typ})
+	}
+	return columns
+}
+
+// IssueValues returns the values of the IssueSchema columns for an issue,
+// typed as the schema says: string, int64, float64, time.Time or []string,
+// or nil where the issue has no value.
+func IssueValues(issue JiraIssueWithSprints, extractors Extractors) map[string]interface{} {
+	f := issue.Fields
+	record := map[string]interface{}{
+		"key":                issue.Key,
+		"project":            f.Project.Key,
+		"type":               f.IssueType.Name,
+		"status":             f.Status.Name,
+		"status_category":    f.Status.StatusCategory.Key,
+		"priority":           nil,
+		"resolution":         nil,
+		"assignee":           nil,
+		"summary":            f.Summary,
+		"created":            timeOrNil(issue.CreatedTime()),
+		"updated":            timeOrNil(issue.UpdatedTime()),
+		"resolved":           timeOrNil(issue.ResolvedTime()),
+		"labels":             nonNil(f.Labels),
+		"components":         nonNil(issue.ComponentNames()),
+		"story_points":       nil,
+		"epic":               nil,
+		"parent":             nil,
+		"original_estimate":  intOrNil(f.TimeOriginalEstimate),
+		"remaining_estimate": intOrNil(f.TimeEstimate),
+		"time_spent":         intOrNil(f.TimeSpent),
+	}
+	if record["project"] == "" {
+		record["project"] = projectOf(issue.Key)
+	}
+	if f.Priority != nil {
+		record["priority"] = f.Priority.Name
+	}
+	if f.Resolution != nil {
+		record["resolution"] = f.Resolution.Name
+	}
+	if id := issue.AssigneeID(); id != "" {
+		record["assignee"] = id
+	}
+	if f.StoryPoints != nil {
+		record["story_points"] = *f.StoryPoints
+	}
+	if epic := issue.EpicKey(); epic != "" {
+		record["epic"] = epic
+	}
+	if f.Parent.Key != "" {
+		record["parent"] = f.Parent.Key
+	}
+	versions := []string{}
+	for _, v := range f.FixVersions {
+		versions = append(versions, v.Name)
+	}
+	record["fix_versions"] = versions
+	sprints := []string{}
+	for _, s := range f.Sprints {
+		sprints = append(sprints, s.Name)
+	}
+	record["sprints"] = sprints
+
+	for _, name := range extractors.Names() {
+		if _, taken := record[name]; taken {
+			continue
+		}
+		fe, _ := extractors.Lookup(name)
+		value, ok := fe.Extract(f)
+		switch {
+		case !ok:
+			record[name] = nil
+		case fe.Type == ExtractNumber:
+			if value.Numeric {
+				record[name] = value.Number
+			} else {
+				record[name] = nil
+			}
+		default:
+			record[name] = value.Text
+		}
+	}
+	return record
+}
+
+// EventValues returns the values of the EventSchema columns for an event.
+func EventValues(e FieldChange) map[string]interface{} {
+	return map[string]interface{}{
+		"key":     e.Key,
+		"project": e.Project,
+		"at":      e.At,
+		"author":  e.Author,
+		"field":   e.Field,
+		"from":    e.From,
+		"to":      e.To,
+		"fromId":  e.FromID,
+		"toId":    e.ToID,
+	}
+}
+
+func timeOrNil(t time.Time, err error) interface{} {
+	if err != nil {
+		return nil
+	}
+	return t.UTC()
+}
+
+func intOrNil(n *int) interface{} {
+	if n == nil {
+		return nil
+	}
+	return int64(*n)
+}
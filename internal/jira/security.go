@@ -0,0 +1,28 @@
+package jira
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var cvePattern = regexp.MustCompile(`(?i)\bCVE-\d{4}-\d{4,}\b`)
+
+// FindCVEs returns the distinct CVE identifiers mentioned in the summary or
+// labels of an issue.
+func FindCVEs(issue JiraIssueWithSprints) []string {
+	seen := map[string]bool{}
+	texts := append([]string{issue.Fields.Summary}, issue.Fields.Labels...)
+	for _, text := range texts {
+		for _, match := range cvePattern.FindAllString(text, -1) {
+			seen[strings.ToUpper(match)] = true
+		}
+	}
+
+	var cves []string
+	for cve := range seen {
+		cves = append(cves, cve)
+	}
+	sort.Strings(cves)
+	return cves
+}
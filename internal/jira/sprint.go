@@ -23,6 +23,37 @@ type Sprint struct {
 	IncompleteIssuesDestination *string `json:"incompleteIssuesDestinationId,omitempty"`
 }
 
+// parseSprintTime accepts the timestamps of both the sprint field string
+// and the Agile API.
+func parseSprintTime(values ...string) (time.Time, bool) {
+	for _, value := range values {
+		if value == "" || value == "<null>" {
+			continue
+		}
+		if t, err := time.Parse(JiraTimeLayout, value); err == nil {
+			return t, true
+		}
+		if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// StartTime is when the sprint started, or is planned to.
+func (s Sprint) StartTime() (time.Time, bool) {
+	return parseSprintTime(s.StartDate, s.ActivatedDate)
+}
+
+// EndTime is when the sprint was completed, or else is planned to end.
+func (s Sprint) EndTime() (time.Time, bool) {
+	complete := ""
+	if s.CompleteDate != nil {
+		complete = *s.CompleteDate
+	}
+	return parseSprintTime(complete, s.EndDate)
+}
+
 type SprintWindow struct {
 	Sprint   string
 	FromTime time.Time
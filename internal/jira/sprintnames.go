@@ -0,0 +1,91 @@
+package jira
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// SprintRule gives the sprints whose names match Pattern one canonical
+// name, so that "RHOAI Sprint 42" and "Sprint 42 - Platform" are grouped
+// as the same sprint. Name may refer to the groups of Pattern as $1 or
+// ${name}; an empty Name keeps the matched text.
+type SprintRule struct {
+	Pattern *regexp.Regexp
+	Name    string
+	// Team, when set, is the team whose sprints the rule recognizes.
+	Team string
+}
+
+// sprintRules are the rules CanonicalSprint applies, set from the config
+// file.
+var sprintRules []SprintRule
+
+// NewSprintRule compiles a sprint naming rule.
+func NewSprintRule(pattern, name, team string) (SprintRule, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return SprintRule{}, fmt.Errorf("invalid sprint pattern %q: %w", pattern, err)
+	}
+	return SprintRule{Pattern: re, Name: name, Team: team}, nil
+}
+
+// SetSprintRules replaces the sprint naming rules.
+func SetSprintRules(rules []SprintRule) {
+	sprintRules = rules
+}
+
+// CanonicalSprint returns the canonical name of a sprint: the name the
+// first matching rule gives it, or the name itself when none matches.
+func CanonicalSprint(name string) string {
+	for _, r := range sprintRules {
+		match := r.Pattern.FindStringSubmatchIndex(name)
+		if match == nil {
+			continue
+		}
+		if r.Name == "" {
+			return name[match[0]:match[1]]
+		}
+		return string(r.Pattern.ExpandString(nil, r.Name, name, match))
+	}
+	return name
+}
+
+// SprintTeam returns the team of the first rule matching a sprint name, or
+// "" when none does or it names no team.
+func SprintTeam(name string) string {
+	for _, r := range sprintRules {
+		if r.Pattern.MatchString(name) {
+			return r.Team
+		}
+	}
+	return ""
+}
+
+// SameSprint reports whether two sprint names have the same canonical
+// name.
+func SameSprint(a, b string) bool {
+	return a == b || CanonicalSprint(a) == CanonicalSprint(b)
+}
+
+// InSprint reports whether the issue is in the sprint, or in one with the
+// same canonical name.
+func (i JiraIssueWithSprints) InSprint(name string) bool {
+	for _, s := range i.Fields.Sprints {
+		if SameSprint(s.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// SprintRulesKey identifies the sprint naming rules in effect, so results
+// grouped by canonical sprint are not reused once the rules change. It is
+// empty when there are none.
+func SprintRulesKey() string {
+	var b strings.Builder
+	for _, r := range sprintRules {
+		fmt.Fprintf(&b, "\x00%s=%s@%s", r.Pattern, r.Name, r.Team)
+	}
+	return b.String()
+}
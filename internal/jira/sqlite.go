@@ -0,0 +1,493 @@
+package jira
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+)
+
+// sqliteDriver is the database/sql driver name used for SQLite caches. The
+// driver itself is linked in by building with -tags sqlite.
+const sqliteDriver = "sqlite"
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS issues (
+	key     TEXT PRIMARY KEY,
+	project TEXT NOT NULL,
+	number  INTEGER NOT NULL,
+	updated INTEGER,
+	fetched INTEGER,
+	data    BLOB NOT NULL
+);
+CREATE INDEX IF NOT EXISTS issues_project ON issues (project, number);
+CREATE INDEX IF NOT EXISTS issues_updated ON issues (project, updated);
+CREATE TABLE IF NOT EXISTS changelogs (
+	key  TEXT PRIMARY KEY,
+	data BLOB NOT NULL
+);
+CREATE TABLE IF NOT EXISTS comments (
+	key  TEXT PRIMARY KEY,
+	data BLOB NOT NULL
+);
+CREATE TABLE IF NOT EXISTS worklogs (
+	key  TEXT PRIMARY KEY,
+	data BLOB NOT NULL
+);
+CREATE TABLE IF NOT EXISTS watchers (
+	key  TEXT PRIMARY KEY,
+	data BLOB NOT NULL
+);
+CREATE TABLE IF NOT EXISTS sync_state (
+	project TEXT PRIMARY KEY,
+	data    BLOB NOT NULL
+);
+CREATE TABLE IF NOT EXISTS denied (
+	key     TEXT PRIMARY KEY,
+	project TEXT NOT NULL,
+	number  INTEGER NOT NULL,
+	marked  INTEGER NOT NULL,
+	status  INTEGER NOT NULL DEFAULT 403
+);
+CREATE TABLE IF NOT EXISTS tombstones (
+	key     TEXT PRIMARY KEY,
+	project TEXT NOT NULL,
+	number  INTEGER NOT NULL,
+	data    BLOB NOT NULL,
+	issue   BLOB
+);
+CREATE TABLE IF NOT EXISTS issue_sprints (
+	key         TEXT NOT NULL,
+	project     TEXT NOT NULL,
+	sprint_id   INTEGER NOT NULL,
+	sprint_name TEXT NOT NULL,
+	PRIMARY KEY (key, sprint_id)
+);
+CREATE INDEX IF NOT EXISTS issue_sprints_name ON issue_sprints (project, sprint_name);
+CREATE TABLE IF NOT EXISTS metadata (
+	name TEXT PRIMARY KEY,
+	data BLOB NOT NULL
+);
+`
+
+// SQLiteStore keeps the cache in a single SQLite database with indexed
+// lookups by key, project, sprint and updated time.
+type SQLiteStore struct {
+	Path string
+	db   *sql.DB
+}
+
+func NewSQLiteStore(path string) (*SQLiteStore, error) {
+	db, err := sql.Open(sqliteDriver, path)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w (build with -tags sqlite for SQLite support)", path, err)
+	}
+	// a single connection avoids SQLITE_BUSY between our own writers
+	db.SetMaxOpenConns(1)
+	for _, pragma := range []string{"PRAGMA journal_mode=WAL", "PRAGMA synchronous=NORMAL"} {
+		if _, err := db.Exec(pragma); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("open %s: %w", path, err)
+		}
+	}
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("create schema in %s: %w", path, err)
+	}
+	if err := addColumn(db, "denied", "status", "INTEGER NOT NULL DEFAULT 403"); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("upgrade schema in %s: %w", path, err)
+	}
+	return &SQLiteStore{Path: path, db: db}, nil
+}
+
+// addColumn adds a column to a table of a database created before the
+// column was part of the schema.
+func addColumn(db *sql.DB, table, column, definition string) error {
+	rows, err := db.Query(fmt.Sprintf(`SELECT name FROM pragma_table_info('%s')`, table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+	_, err = db.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, table, column, definition))
+	return err
+}
+
+// splitKey returns the project and number of an issue key such as ABC-12.
+func splitKey(key string) (string, int) {
+	i := strings.LastIndex(key, "-")
+	if i < 0 {
+		return key, 0
+	}
+	return key[:i], issueNumber(key)
+}
+
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *SQLiteStore) strings(query string, args ...interface{}) []string {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err == nil {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func (s *SQLiteStore) IssueKeys(project string) []string {
+	if project == "" {
+		return s.strings(`SELECT key FROM issues ORDER BY project, number`)
+	}
+	return s.strings(`SELECT key FROM issues WHERE project = ? ORDER BY number`, strings.ToUpper(project))
+}
+
+func (s *SQLiteStore) DeniedKeys(project string) []string {
+	return s.strings(`SELECT key FROM denied WHERE project = ? ORDER BY number`, strings.ToUpper(project))
+}
+
+func (s *SQLiteStore) IssueNumbers(project string) (map[int]struct{}, error) {
+	found := make(map[int]struct{})
+	rows, err := s.db.Query(`SELECT number FROM issues WHERE project = ?1 UNION SELECT number FROM denied WHERE project = ?1 UNION SELECT number FROM tombstones WHERE project = ?1`, strings.ToUpper(project))
+	if err != nil {
+		return nil, fmt.Errorf("read issue numbers: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var n int
+		if err := rows.Scan(&n); err != nil {
+			return nil, fmt.Errorf("read issue numbers: %w", err)
+		}
+		found[n] = struct{}{}
+	}
+	return found, rows.Err()
+}
+
+func (s *SQLiteStore) LatestUpdated(project string) time.Time {
+	var latest sql.NullInt64
+	err := s.db.QueryRow(`SELECT MAX(updated) FROM issues WHERE project = ? AND key NOT IN (SELECT key FROM denied)`,
+		strings.ToUpper(project)).Scan(&latest)
+	if err != nil || !latest.Valid {
+		return time.Now().Add(-30 * 24 * time.Hour) // default to 30 days ago
+	}
+	return time.UnixMilli(latest.Int64)
+}
+
+func (s *SQLiteStore) IssueUpdated(key string) (time.Time, bool) {
+	var updated sql.NullInt64
+	if err := s.db.QueryRow(`SELECT updated FROM issues WHERE key = ?`, key).Scan(&updated); err != nil || !updated.Valid {
+		return time.Time{}, false
+	}
+	return time.UnixMilli(updated.Int64), true
+}
+
+func (s *SQLiteStore) ReadIssue(key string) (JiraIssueWithSprints, error) {
+	var issue JiraIssueWithSprints
+	var data []byte
+	if err := s.db.QueryRow(`SELECT data FROM issues WHERE key = ?`, key).Scan(&data); err != nil {
+		return issue, fmt.Errorf("failed to read %s: %w", key, err)
+	}
+	if err := json.Unmarshal(data, &issue); err != nil {
+		return issue, corruptEntry(key, err)
+	}
+	return issue, nil
+}
+
+func (s *SQLiteStore) ReadChangelog(key string) (Changelog, error) {
+	var changelog Changelog
+	var data []byte
+	if err := s.db.QueryRow(`SELECT data FROM changelogs WHERE key = ?`, key).Scan(&data); err != nil {
+		return changelog, fmt.Errorf("failed to read changelog for %s: %w", key, err)
+	}
+	if err := json.Unmarshal(data, &changelog); err != nil {
+		return changelog, corruptEntry(key+" changelog", err)
+	}
+	changelog.SortHistories()
+	return changelog, nil
+}
+
+func (s *SQLiteStore) ReadComments(key string) (CommentList, error) {
+	var comments CommentList
+	var data []byte
+	if err := s.db.QueryRow(`SELECT data FROM comments WHERE key = ?`, key).Scan(&data); err != nil {
+		return comments, fmt.Errorf("failed to read comments for %s: %w", key, err)
+	}
+	if err := json.Unmarshal(data, &comments); err != nil {
+		return comments, corruptEntry(key+" comments", err)
+	}
+	return comments, nil
+}
+
+func (s *SQLiteStore) SaveComments(key string, comments CommentList) error {
+	data, err := json.Marshal(comments)
+	if err != nil {
+		return fmt.Errorf("marshal comments: %w", err)
+	}
+	if _, err := s.db.Exec(`INSERT OR REPLACE INTO comments (key, data) VALUES (?, ?)`, key, data); err != nil {
+		return fmt.Errorf("write comments: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) ReadWorklogs(key string) (WorklogList, error) {
+	var worklogs WorklogList
+	var data []byte
+	if err := s.db.QueryRow(`SELECT data FROM worklogs WHERE key = ?`, key).Scan(&data); err != nil {
+		return worklogs, fmt.Errorf("failed to read worklogs for %s: %w", key, err)
+	}
+	if err := json.Unmarshal(data, &worklogs); err != nil {
+		return worklogs, corruptEntry(key+" worklogs", err)
+	}
+	return worklogs, nil
+}
+
+func (s *SQLiteStore) SaveWorklogs(key string, worklogs WorklogList) error {
+	data, err := json.Marshal(worklogs)
+	if err != nil {
+		return fmt.Errorf("marshal worklogs: %w", err)
+	}
+	if _, err := s.db.Exec(`INSERT OR REPLACE INTO worklogs (key, data) VALUES (?, ?)`, key, data); err != nil {
+		return fmt.Errorf("write worklogs: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) ReadWatchers(key string) (WatcherList, error) {
+	var watchers WatcherList
+	var data []byte
+	if err := s.db.QueryRow(`SELECT data FROM watchers WHERE key = ?`, key).Scan(&data); err != nil {
+		return watchers, fmt.Errorf("failed to read watchers for %s: %w", key, err)
+	}
+	if err := json.Unmarshal(data, &watchers); err != nil {
+		return watchers, corruptEntry(key+" watchers", err)
+	}
+	return watchers, nil
+}
+
+func (s *SQLiteStore) SaveWatchers(key string, watchers WatcherList) error {
+	data, err := json.Marshal(watchers)
+	if err != nil {
+		return fmt.Errorf("marshal watchers: %w", err)
+	}
+	if _, err := s.db.Exec(`INSERT OR REPLACE INTO watchers (key, data) VALUES (?, ?)`, key, data); err != nil {
+		return fmt.Errorf("write watchers: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) ReadSyncState(project string) (SyncState, error) {
+	var state SyncState
+	var data []byte
+	err := s.db.QueryRow(`SELECT data FROM sync_state WHERE project = ?`, project).Scan(&data)
+	if err == sql.ErrNoRows {
+		return state, nil
+	}
+	if err != nil {
+		return state, fmt.Errorf("read sync state: %w", err)
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return state, corruptEntry("sync state", err)
+	}
+	return state, nil
+}
+
+func (s *SQLiteStore) SaveSyncState(project string, state SyncState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("marshal sync state: %w", err)
+	}
+	if _, err := s.db.Exec(`INSERT OR REPLACE INTO sync_state (project, data) VALUES (?, ?)`, project, data); err != nil {
+		return fmt.Errorf("write sync state: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) StaleIssueKeys(project string, window time.Duration) []string {
+	cutoff := time.Now().Add(-window).UnixMilli()
+	return s.strings(`SELECT key FROM issues WHERE project = ? AND COALESCE(fetched, updated, 0) <= ? ORDER BY number`,
+		strings.ToUpper(project), cutoff)
+}
+
+func (s *SQLiteStore) LookupSprintID(project, sprintName string) (int, error) {
+	var id int
+	err := s.db.QueryRow(`SELECT sprint_id FROM issue_sprints WHERE project = ? AND sprint_name = ? LIMIT 1`,
+		strings.ToUpper(project), sprintName).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("sprint %q not found in local cache", sprintName)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("lookup sprint: %w", err)
+	}
+	return id, nil
+}
+
+func (s *SQLiteStore) IsDenied(key string) bool {
+	var n int
+	return s.db.QueryRow(`SELECT 1 FROM denied WHERE key = ?`, key).Scan(&n) == nil
+}
+
+func (s *SQLiteStore) MarkDenied(key string, status int) error {
+	project, number := splitKey(key)
+	_, err := s.db.Exec(`INSERT OR REPLACE INTO denied (key, project, number, marked, status) VALUES (?, ?, ?, ?, ?)`,
+		key, project, number, time.Now().UnixMilli(), status)
+	if err != nil {
+		return fmt.Errorf("mark %s denied: %w", key, err)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) Denial(key string) (Denial, bool) {
+	var marked int64
+	var d Denial
+	if err := s.db.QueryRow(`SELECT marked, status FROM denied WHERE key = ?`, key).Scan(&marked, &d.Status); err != nil {
+		return Denial{}, false
+	}
+	d.Time = time.UnixMilli(marked).UTC()
+	return d, true
+}
+
+func (s *SQLiteStore) ClearDenied(key string) error {
+	if _, err := s.db.Exec(`DELETE FROM denied WHERE key = ?`, key); err != nil {
+		return fmt.Errorf("clear denied mark of %s: %w", key, err)
+	}
+	return nil
+}
+
+// Tombstone keeps the last cached version of the issue with the
+// tombstone and removes the issue from the other tables.
+func (s *SQLiteStore) Tombstone(key string, t Tombstone) error {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("tombstone %s: %w", key, err)
+	}
+	defer tx.Rollback()
+	var issue []byte
+	if err := tx.QueryRow(`SELECT data FROM issues WHERE key = ?`, key).Scan(&issue); err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("tombstone %s: %w", key, err)
+	}
+	project, number := splitKey(key)
+	if _, err := tx.Exec(`INSERT OR REPLACE INTO tombstones (key, project, number, data, issue) VALUES (?, ?, ?, ?, ?)`,
+		key, project, number, data, issue); err != nil {
+		return fmt.Errorf("tombstone %s: %w", key, err)
+	}
+	for _, table := range []string{"issues", "changelogs", "comments", "worklogs", "watchers", "issue_sprints"} {
+		if _, err := tx.Exec(`DELETE FROM `+table+` WHERE key = ?`, key); err != nil {
+			return fmt.Errorf("tombstone %s: %w", key, err)
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) Tombstones(project string) []Tombstone {
+	query, args := `SELECT data FROM tombstones`, []interface{}{}
+	if project != "" {
+		query, args = query+` WHERE project = ?`, append(args, strings.ToUpper(project))
+	}
+	var tombstones []Tombstone
+	for _, data := range s.strings(query, args...) {
+		var t Tombstone
+		if err := json.Unmarshal([]byte(data), &t); err == nil {
+			tombstones = append(tombstones, t)
+		}
+	}
+	sortTombstones(tombstones)
+	return tombstones
+}
+
+func (s *SQLiteStore) SaveIssue(key string, issueData map[string]interface{}, changelog interface{}) error {
+	fetched := time.Now().UTC()
+	issueData["fetched"] = fetched.Format(time.RFC3339)
+	data, err := json.Marshal(issueData)
+	if err != nil {
+		return fmt.Errorf("marshal issue without changelog: %w", err)
+	}
+	var issue JiraIssueWithSprints
+	if err := json.Unmarshal(data, &issue); err != nil {
+		return fmt.Errorf("parse issue %s: %w", key, err)
+	}
+	var updated sql.NullInt64
+	if t, err := issue.UpdatedTime(); err == nil {
+		updated = sql.NullInt64{Int64: t.UnixMilli(), Valid: true}
+	}
+	project, number := splitKey(key)
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if changelog != nil {
+		changelogBytes, err := json.Marshal(changelog)
+		if err != nil {
+			return fmt.Errorf("marshal changelog: %w", err)
+		}
+		if _, err := tx.Exec(`INSERT OR REPLACE INTO changelogs (key, data) VALUES (?, ?)`, key, changelogBytes); err != nil {
+			return fmt.Errorf("write changelog: %w", err)
+		}
+	}
+	if _, err := tx.Exec(`INSERT OR REPLACE INTO issues (key, project, number, updated, fetched, data) VALUES (?, ?, ?, ?, ?, ?)`,
+		key, project, number, updated, fetched.UnixMilli(), data); err != nil {
+		return fmt.Errorf("write issue: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM issue_sprints WHERE key = ?`, key); err != nil {
+		return fmt.Errorf("write sprints: %w", err)
+	}
+	for _, sprint := range issue.Fields.Sprints {
+		if _, err := tx.Exec(`INSERT OR REPLACE INTO issue_sprints (key, project, sprint_id, sprint_name) VALUES (?, ?, ?, ?)`,
+			key, project, sprint.ID, sprint.Name); err != nil {
+			return fmt.Errorf("write sprints: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("write issue: %w", err)
+	}
+	slog.Debug("saved", "key", key, "path", s.Path)
+	return nil
+}
+
+// Version fingerprints the database and its write-ahead log.
+func (s *SQLiteStore) Version() (string, error) {
+	h := sha256.New()
+	for _, name := range []string{s.Path, s.Path + "-wal"} {
+		info, err := os.Stat(name)
+		if os.IsNotExist(err) {
+			fmt.Fprintf(h, "absent\n")
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%d:%d\n", info.Size(), info.ModTime().UnixNano())
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16], nil
+}
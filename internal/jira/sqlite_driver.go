@@ -0,0 +1,5 @@
+//go:build sqlite
+
+package jira
+
+import _ "modernc.org/sqlite"
@@ -0,0 +1,34 @@
+//go:build sqlite
+
+package jira
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestSQLiteStoreRoundTrip(t *testing.T) {
+	s, err := NewSQLiteStore(filepath.Join(t.TempDir(), "cache.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	issue := map[string]interface{}{"key": "DEMO-7", "fields": map[string]interface{}{
+		"project": map[string]interface{}{"key": "DEMO"},
+		"summary": "stored in sqlite",
+		"updated": "2024-03-01T10:00:00.000+0000",
+	}}
+	if err := s.SaveIssue("DEMO-7", issue, nil); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.ReadIssue("DEMO-7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Key != "DEMO-7" || got.Fields.Summary != "stored in sqlite" {
+		t.Errorf("got %s %q, want DEMO-7 %q", got.Key, got.Fields.Summary, "stored in sqlite")
+	}
+	if keys := s.IssueKeys("DEMO"); len(keys) != 1 || keys[0] != "DEMO-7" {
+		t.Errorf("IssueKeys = %v, want [DEMO-7]", keys)
+	}
+}
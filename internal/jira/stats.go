@@ -0,0 +1,141 @@
+package jira
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ValueCount is one distinct value of a field and how many issues carry it.
+type ValueCount struct {
+	Value string
+	Count int
+}
+
+// FieldStats summarises one field across a set of issues.
+type FieldStats struct {
+	Name   string
+	Field  string
+	Issues int
+	// Present counts issues where the field is set and non-empty.
+	Present int
+	// Values counts every value seen; multi-valued fields such as labels
+	// contribute one value per element.
+	Values int
+	// Distribution lists each distinct value, most frequent first.
+	Distribution []ValueCount
+	// Numeric is set when every present value parsed as a number.
+	Numeric                bool
+	Min, Max, Mean, Median float64
+}
+
+// NullRate is the fraction of issues where the field is unset or empty.
+func (s FieldStats) NullRate() float64 {
+	if s.Issues == 0 {
+		return 0
+	}
+	return float64(s.Issues-s.Present) / float64(s.Issues)
+}
+
+// Cardinality is the number of distinct values.
+func (s FieldStats) Cardinality() int {
+	return len(s.Distribution)
+}
+
+// ExtractValues returns every value of the field, one per element for list
+// fields, or nil when it is unset.
+func (fe FieldExtractor) ExtractValues(fields Fields) []string {
+	raw, ok := fe.raw(fields)
+	if fe.Field == CategoryField || !ok {
+		if v, ok := fe.Extract(fields); ok {
+			return strings.Split(v.Text, ",")
+		}
+		return nil
+	}
+	var list []json.RawMessage
+	if err := json.Unmarshal(raw, &list); err == nil {
+		var values []string
+		for _, item := range list {
+			if text := optionText(item); text != "" {
+				values = append(values, text)
+			}
+		}
+		return values
+	}
+	if v, ok := fe.Extract(fields); ok {
+		return []string{v.Text}
+	}
+	return nil
+}
+
+// RawFieldNames lists every field id present on any of the issues.
+func RawFieldNames(issues []JiraIssueWithSprints) []string {
+	seen := map[string]bool{}
+	for _, issue := range issues {
+		for name := range issue.Fields.Raw {
+			seen[name] = true
+		}
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ComputeFieldStats summarises a field across issues.
+func ComputeFieldStats(fe FieldExtractor, issues []JiraIssueWithSprints) FieldStats {
+	stats := FieldStats{Name: fe.Name, Field: fe.Field, Issues: len(issues), Numeric: true}
+	counts := map[string]int{}
+	var numbers []float64
+	for _, issue := range issues {
+		values := fe.ExtractValues(issue.Fields)
+		if len(values) == 0 {
+			continue
+		}
+		stats.Present++
+		for _, v := range values {
+			stats.Values++
+			counts[v]++
+			n, err := strconv.ParseFloat(v, 64)
+			if err != nil || math.IsNaN(n) {
+				stats.Numeric = false
+				continue
+			}
+			numbers = append(numbers, n)
+		}
+	}
+
+	for v, c := range counts {
+		stats.Distribution = append(stats.Distribution, ValueCount{Value: v, Count: c})
+	}
+	sort.Slice(stats.Distribution, func(i, j int) bool {
+		a, b := stats.Distribution[i], stats.Distribution[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Value < b.Value
+	})
+
+	if !stats.Numeric || len(numbers) == 0 {
+		stats.Numeric = false
+		return stats
+	}
+	sort.Float64s(numbers)
+	stats.Min, stats.Max = numbers[0], numbers[len(numbers)-1]
+	var sum float64
+	for _, n := range numbers {
+		sum += n
+	}
+	stats.Mean = sum / float64(len(numbers))
+	mid := len(numbers) / 2
+	if len(numbers)%2 == 0 {
+		stats.Median = (numbers[mid-1] + numbers[mid]) / 2
+	} else {
+		stats.Median = numbers[mid]
+	}
+	return stats
+}
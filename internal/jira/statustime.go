@@ -0,0 +1,44 @@
+package jira
+
+import "time"
+
+// StatusPeriod is a stretch of time an issue spent in one status.
+type StatusPeriod struct {
+	Status string
+	From   time.Time
+	To     time.Time
+}
+
+// StatusPeriods replays the status transitions of an issue from its
+// creation to until, oldest first. The issue starts in the status the
+// first transition left, or its current status when it never moved; the
+// last period runs to until.
+func StatusPeriods(issue JiraIssueWithSprints, changelog Changelog, until time.Time) []StatusPeriod {
+	created, err := issue.CreatedTime()
+	if err != nil {
+		return nil
+	}
+	status, from := issue.Fields.Status.Name, created
+	first := true
+	var periods []StatusPeriod
+	for _, h := range sortedHistories(changelog) {
+		for _, item := range h.entry.Items {
+			if item.Field != "status" {
+				continue
+			}
+			if first {
+				status = item.FromString
+				first = false
+			}
+			if h.at.After(from) {
+				periods = append(periods, StatusPeriod{Status: status, From: from, To: h.at})
+				from = h.at
+			}
+			status = item.ToString
+		}
+	}
+	if until.After(from) {
+		periods = append(periods, StatusPeriod{Status: status, From: from, To: until})
+	}
+	return periods
+}
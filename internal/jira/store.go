@@ -0,0 +1,594 @@
+package jira
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Custom field ids of sprint membership, story points and epic link. The
+// defaults are those of issues.redhat.com; SetCustomFields changes them for
+// other Jira instances.
+var (
+	SprintField      = defaultSprintField
+	StoryPointsField = defaultStoryPointsField
+	EpicLinkField    = defaultEpicLinkField
+)
+
+const (
+	defaultSprintField      = "customfield_12310940"
+	defaultStoryPointsField = "customfield_12310243"
+	defaultEpicLinkField    = "customfield_12311140"
+)
+
+// SetCustomFields overrides the custom field ids, taking precedence over a
+// discovered field map; empty ids keep their current value. It must be
+// called before any issue is decoded.
+func SetCustomFields(sprint, storyPoints, epicLink string) {
+	if sprint != "" {
+		SprintField = sprint
+		customFieldsPinned["sprint"] = true
+	}
+	if storyPoints != "" {
+		StoryPointsField = storyPoints
+		customFieldsPinned["storyPoints"] = true
+	}
+	if epicLink != "" {
+		EpicLinkField = epicLink
+		customFieldsPinned["epicLink"] = true
+	}
+}
+
+// ErrCorruptCache is wrapped by errors for cache entries that exist but
+// cannot be parsed.
+var ErrCorruptCache = errors.New("corrupt cache entry")
+
+func corruptEntry(name string, err error) error {
+	return fmt.Errorf("parse json: %s %w (%w)", name, err, ErrCorruptCache)
+}
+
+// Store is where fetched issues are persisted and looked up, both during a
+// sync and by the reports.
+type Store interface {
+	// IssueKeys lists cached issues, optionally restricted to a project.
+	IssueKeys(project string) []string
+	// IssueNumbers lists the numbers of the cached and denied issues of a
+	// project.
+	IssueNumbers(project string) (map[int]struct{}, error)
+	LatestUpdated(project string) time.Time
+	IssueUpdated(key string) (time.Time, bool)
+	ReadIssue(key string) (JiraIssueWithSprints, error)
+	ReadChangelog(key string) (Changelog, error)
+	ReadComments(key string) (CommentList, error)
+	ReadWorklogs(key string) (WorklogList, error)
+	ReadWatchers(key string) (WatcherList, error)
+	StaleIssueKeys(project string, window time.Duration) []string
+	LookupSprintID(project, sprintName string) (int, error)
+	IsDenied(key string) bool
+	// MarkDenied records that Jira refused the issue with status.
+	MarkDenied(key string, status int) error
+	// Denial returns what was recorded when the issue was marked denied.
+	Denial(key string) (Denial, bool)
+	// ClearDenied removes the denied mark of an issue that became
+	// readable.
+	ClearDenied(key string) error
+	DeniedKeys(project string) []string
+	// Tombstone puts away the cached files of an issue that is gone from
+	// Jira under its key, recording why.
+	Tombstone(key string, t Tombstone) error
+	// Tombstones lists the tombstoned issues of a project, or of all.
+	Tombstones(project string) []Tombstone
+	SaveIssue(key string, issue map[string]interface{}, changelog interface{}) error
+	SaveComments(key string, comments CommentList) error
+	SaveWorklogs(key string, worklogs WorklogList) error
+	SaveWatchers(key string, watchers WatcherList) error
+	ReadSyncState(project string) (SyncState, error)
+	SaveSyncState(project string, state SyncState) error
+	// ReadFieldMap returns the saved field map, empty when there is none.
+	ReadFieldMap() (FieldMap, error)
+	SaveFieldMap(m FieldMap) error
+	// ReadBoards returns the saved board configurations, empty when there
+	// are none.
+	ReadBoards() (BoardMap, error)
+	SaveBoards(m BoardMap) error
+	// ReadVersions returns the saved project versions, empty when there
+	// are none.
+	ReadVersions() (VersionMap, error)
+	SaveVersions(m VersionMap) error
+	// Version changes whenever the cached data does.
+	Version() (string, error)
+	Close() error
+}
+
+// OpenStore opens a cache from a spec such as "issues", "dir:issues" or
+// "sqlite:issues.db". Custom fields are resolved through the field map
+// saved in the cache, if any.
+func OpenStore(spec string) (Store, error) {
+	var store Store
+	var err error
+	kind, target, found := strings.Cut(spec, ":")
+	switch {
+	case !found:
+		store, err = NewDirStore(spec)
+	case kind == "dir":
+		store, err = NewDirStore(target)
+	case kind == "sqlite":
+		store, err = NewSQLiteStore(target)
+	default:
+		return nil, fmt.Errorf("unknown cache backend %q (expected dir or sqlite)", kind)
+	}
+	if err != nil {
+		return nil, err
+	}
+	applyFieldMap(store)
+	return store, nil
+}
+
+// LoadIssues reads every issue in a store, optionally restricted to a
+// project. Unreadable issues are logged and skipped.
+func LoadIssues(store Store, project string) []JiraIssueWithSprints {
+	var issues []JiraIssueWithSprints
+	for _, key := range store.IssueKeys(project) {
+		issue, err := store.ReadIssue(key)
+		if err != nil {
+			log.Printf("skipping %s: %v", key, err)
+			continue
+		}
+		issues = append(issues, issue)
+	}
+	return issues
+}
+
+// DirStore is the flat directory layout used by the fetcher: {KEY}.json,
+// {KEY}.changelog.json, and {KEY}.denied and {KEY}.tombstone markers.
+type DirStore struct {
+	Dir string
+	// Compact writes JSON without indentation.
+	Compact bool
+	// Compress writes the files of each issue zstd-compressed, with
+	// CompressedSuffix appended to their names.
+	Compress bool
+	// Writer, when set, batches writes instead of writing synchronously.
+	Writer *BatchWriter
+	// IncludeArchived lists the issues of the archive tier in IssueKeys
+	// too. They are read by key either way.
+	IncludeArchived bool
+	// RecordDiffs appends what changed in an issue to its diff log under
+	// DiffDir whenever a fetch replaces it.
+	RecordDiffs bool
+
+	archiveMu sync.Mutex
+	archive   *archiveReader
+	// lock is the cache lock taken by Lock.
+	lock *CacheLock
+}
+
+func NewDirStore(dir string) (*DirStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create output directory: %w", err)
+	}
+	return &DirStore{Dir: dir}, nil
+}
+
+func (s *DirStore) IssueKeys(project string) []string {
+	keys := s.hotIssueKeys(project)
+	if !s.IncludeArchived {
+		return keys
+	}
+	index, err := s.archiveTier().loadIndex()
+	if err != nil {
+		log.Printf("archive: %v", err)
+		return keys
+	}
+	hot := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		hot[key] = true
+	}
+	for _, key := range index.Keys(project) {
+		if !hot[key] {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// hotIssueKeys lists the issues in the cache directory itself.
+func (s *DirStore) hotIssueKeys(project string) []string {
+	if project == "" {
+		return GetAllCachedIssueKeys(s.Dir)
+	}
+	return GetAllProjectIssueKeys(s.Dir, project)
+}
+
+func (s *DirStore) archiveTier() *archiveReader {
+	s.archiveMu.Lock()
+	defer s.archiveMu.Unlock()
+	if s.archive == nil {
+		s.archive = &archiveReader{dir: s.Dir}
+	}
+	return s.archive
+}
+
+// resetArchive makes the next read reload the archive index.
+func (s *DirStore) resetArchive() {
+	s.archiveMu.Lock()
+	defer s.archiveMu.Unlock()
+	s.archive = nil
+}
+
+func (s *DirStore) DeniedKeys(project string) []string {
+	return GetDeniedIssueKeys(s.Dir, project)
+}
+
+func (s *DirStore) Version() (string, error) {
+	return CacheVersion(s.Dir)
+}
+
+// IssueNumbers includes archived issues, so a sync does not fetch them
+// again as missing.
+func (s *DirStore) IssueNumbers(project string) (map[int]struct{}, error) {
+	numbers, err := GetProjectNumbersOnDisk(s.Dir, project)
+	if err != nil {
+		return nil, err
+	}
+	if index, err := s.archiveTier().loadIndex(); err == nil {
+		prefix := strings.ToUpper(project) + "-"
+		for _, key := range index.Keys(project) {
+			if n, err := strconv.Atoi(strings.TrimPrefix(key, prefix)); err == nil {
+				numbers[n] = struct{}{}
+			}
+		}
+	}
+	return numbers, nil
+}
+
+// LatestUpdated reads the update times from the cache index, falling back
+// to reading every issue when the index cannot be updated.
+func (s *DirStore) LatestUpdated(project string) time.Time {
+	x, err := s.Index()
+	if err != nil {
+		log.Printf("cache index: %v", err)
+		return FindLatestUpdatedTimestamp(s.Dir, project)
+	}
+	denied := map[string]bool{}
+	for _, key := range s.DeniedKeys(project) {
+		denied[key] = true
+	}
+	var latest time.Time
+	for _, key := range x.Keys(project) {
+		if denied[key] {
+			continue
+		}
+		t, err := time.Parse(JiraTimeLayout, x.Issues[key].Updated)
+		if err == nil && t.After(latest) {
+			latest = t
+		}
+	}
+	if latest.IsZero() {
+		return time.Now().Add(-30 * 24 * time.Hour) // default to 30 days ago
+	}
+	return latest
+}
+
+// Flush waits until batched writes are on disk.
+func (s *DirStore) Flush() error {
+	if s.Writer == nil {
+		return nil
+	}
+	return s.Writer.Flush()
+}
+
+// Close also releases the cache lock.
+func (s *DirStore) Close() error {
+	var err error
+	if s.Writer != nil {
+		err = s.Writer.Close()
+	}
+	if unlockErr := s.lock.Unlock(); err == nil {
+		err = unlockErr
+	}
+	s.lock = nil
+	return err
+}
+
+func (s *DirStore) marshal(v interface{}) ([]byte, error) {
+	if s.Compact {
+		return json.Marshal(v)
+	}
+	return json.MarshalIndent(v, "", "  ")
+}
+
+// readFile reads a cache file, seeing writes that are still queued and
+// falling back to the archive tier.
+func (s *DirStore) readFile(name string) ([]byte, error) {
+	if s.Writer != nil {
+		if data, ok := s.Writer.Pending(name); ok {
+			return data, nil
+		}
+		if data, ok := s.Writer.Pending(name + CompressedSuffix); ok {
+			return decompressBytes(name+CompressedSuffix, data)
+		}
+	}
+	data, err := ReadCacheFile(path.Join(s.Dir, name))
+	if os.IsNotExist(err) {
+		if archived, archiveErr := s.archiveTier().readFile(issueFileKey(name), name); archiveErr == nil {
+			return archived, nil
+		}
+	}
+	return data, err
+}
+
+func (s *DirStore) IssueUpdated(key string) (time.Time, bool) {
+	data, err := s.readFile(fmt.Sprintf("%s.json", key))
+	if err != nil {
+		return time.Time{}, false
+	}
+	var obj struct {
+		Fields struct {
+			Updated string `json:"updated"`
+		} `json:"fields"`
+	}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return time.Time{}, false
+	}
+	updated, err := time.Parse("2006-01-02T15:04:05.000-0700", obj.Fields.Updated)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return updated, true
+}
+
+func (s *DirStore) ReadIssue(key string) (JiraIssueWithSprints, error) {
+	var issue JiraIssueWithSprints
+	name := fmt.Sprintf("%s.json", key)
+	data, err := s.readFile(name)
+	if err != nil {
+		return issue, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	if err := json.Unmarshal(data, &issue); err != nil {
+		return issue, corruptEntry(name, err)
+	}
+	return issue, nil
+}
+
+func (s *DirStore) ReadChangelog(key string) (Changelog, error) {
+	var changelog Changelog
+	name := fmt.Sprintf("%s.changelog.json", key)
+	data, err := s.readFile(name)
+	if err != nil {
+		return changelog, err
+	}
+	if err := json.Unmarshal(data, &changelog); err != nil {
+		return changelog, corruptEntry(name, err)
+	}
+	changelog.SortHistories()
+	return changelog, nil
+}
+
+func (s *DirStore) ReadComments(key string) (CommentList, error) {
+	var comments CommentList
+	name := fmt.Sprintf("%s.comments.json", key)
+	data, err := s.readFile(name)
+	if err != nil {
+		return comments, err
+	}
+	if err := json.Unmarshal(data, &comments); err != nil {
+		return comments, corruptEntry(name, err)
+	}
+	return comments, nil
+}
+
+func (s *DirStore) SaveComments(key string, comments CommentList) error {
+	data, err := s.marshal(comments)
+	if err != nil {
+		return fmt.Errorf("marshal comments: %w", err)
+	}
+	name := fmt.Sprintf("%s.comments.json", key)
+	if err := s.writeFile(name, data); err != nil {
+		return fmt.Errorf("write comments: %w", err)
+	}
+	slog.Debug("saved", "path", path.Join(s.Dir, name))
+	return nil
+}
+
+func (s *DirStore) ReadWorklogs(key string) (WorklogList, error) {
+	var worklogs WorklogList
+	name := fmt.Sprintf("%s.worklogs.json", key)
+	data, err := s.readFile(name)
+	if err != nil {
+		return worklogs, err
+	}
+	if err := json.Unmarshal(data, &worklogs); err != nil {
+		return worklogs, corruptEntry(name, err)
+	}
+	return worklogs, nil
+}
+
+func (s *DirStore) SaveWorklogs(key string, worklogs WorklogList) error {
+	data, err := s.marshal(worklogs)
+	if err != nil {
+		return fmt.Errorf("marshal worklogs: %w", err)
+	}
+	name := fmt.Sprintf("%s.worklogs.json", key)
+	if err := s.writeFile(name, data); err != nil {
+		return fmt.Errorf("write worklogs: %w", err)
+	}
+	slog.Debug("saved", "path", path.Join(s.Dir, name))
+	return nil
+}
+
+func (s *DirStore) ReadWatchers(key string) (WatcherList, error) {
+	var watchers WatcherList
+	name := fmt.Sprintf("%s.watchers.json", key)
+	data, err := s.readFile(name)
+	if err != nil {
+		return watchers, err
+	}
+	if err := json.Unmarshal(data, &watchers); err != nil {
+		return watchers, corruptEntry(name, err)
+	}
+	return watchers, nil
+}
+
+func (s *DirStore) SaveWatchers(key string, watchers WatcherList) error {
+	data, err := s.marshal(watchers)
+	if err != nil {
+		return fmt.Errorf("marshal watchers: %w", err)
+	}
+	name := fmt.Sprintf("%s.watchers.json", key)
+	if err := s.writeFile(name, data); err != nil {
+		return fmt.Errorf("write watchers: %w", err)
+	}
+	slog.Debug("saved", "path", path.Join(s.Dir, name))
+	return nil
+}
+
+func (s *DirStore) StaleIssueKeys(project string, window time.Duration) []string {
+	return FilterRecentlyFetchedIssues(s.Dir, GetAllProjectIssueKeys(s.Dir, project), window)
+}
+
+func (s *DirStore) LookupSprintID(project, sprintName string) (int, error) {
+	return LookupSprintIDFromDisk(s.Dir, project, sprintName, SprintField)
+}
+
+func (s *DirStore) IsDenied(key string) bool {
+	_, err := os.Stat(path.Join(s.Dir, fmt.Sprintf("%s.denied", key)))
+	return err == nil
+}
+
+func (s *DirStore) MarkDenied(key string, status int) error {
+	data, err := json.Marshal(Denial{Time: time.Now().UTC(), Status: status})
+	if err != nil {
+		return err
+	}
+	return s.writeFile(fmt.Sprintf("%s.denied", key), data)
+}
+
+func (s *DirStore) Denial(key string) (Denial, bool) {
+	name := path.Join(s.Dir, fmt.Sprintf("%s.denied", key))
+	info, err := os.Stat(name)
+	if err != nil {
+		return Denial{}, false
+	}
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return Denial{}, false
+	}
+	return parseDenial(data, info.ModTime()), true
+}
+
+func (s *DirStore) ClearDenied(key string) error {
+	if err := s.Flush(); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s.denied", key)
+	if err := os.Remove(path.Join(s.Dir, name)); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	return appendManifestRemoval(s.Dir, name)
+}
+
+// TempPrefix starts the names of the temporary files cache writes go
+// through. They are hidden from listings and the manifest; one left behind
+// by a killed process is harmless and cache verify removes it.
+const TempPrefix = ".tmp-"
+
+// writeFileAtomic writes a file through a temporary file in the same
+// directory renamed over it, so readers never see it half written. The
+// data is synced before the rename, so a crash cannot leave the new name
+// pointing at a truncated file; syncing the directory to make the rename
+// itself durable is up to the caller.
+func writeFileAtomic(name string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(name), TempPrefix+filepath.Base(name)+"-*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Chmod(tmp, 0644); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, name); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// writeFile writes a cache file, compressed if the store compresses, and
+// records its hash in the manifest journal.
+func (s *DirStore) writeFile(name string, data []byte) error {
+	if s.Compress && IsCompressible(name) {
+		name, data = name+CompressedSuffix, compressBytes(data)
+	}
+	if s.Writer != nil {
+		return s.Writer.Write(name, data)
+	}
+	if err := writeFileAtomic(path.Join(s.Dir, name), data); err != nil {
+		return err
+	}
+	if err := AppendManifestJournal(s.Dir, name, data); err != nil {
+		log.Printf("failed to record %s in manifest journal: %v", name, err)
+	}
+	return removeCounterpart(s.Dir, name)
+}
+
+func (s *DirStore) SaveIssue(key string, issueData map[string]interface{}, changelog interface{}) error {
+	if changelog != nil {
+		changelogBytes, err := s.marshal(changelog)
+		if err != nil {
+			return fmt.Errorf("marshal changelog: %w", err)
+		}
+
+		changelogPath := path.Join(s.Dir, fmt.Sprintf("%s.changelog.json", key))
+		if err := s.writeFile(fmt.Sprintf("%s.changelog.json", key), changelogBytes); err != nil {
+			return fmt.Errorf("write changelog: %w", err)
+		}
+		slog.Debug("saved", "path", changelogPath)
+	}
+
+	issueData["fetched"] = time.Now().UTC().Format(time.RFC3339)
+	if s.RecordDiffs {
+		if err := s.recordDiff(key, issueData); err != nil {
+			log.Printf("failed to record diff of %s: %v", key, err)
+		}
+	}
+	strippedBytes, err := s.marshal(issueData)
+	if err != nil {
+		return fmt.Errorf("marshal issue without changelog: %w", err)
+	}
+
+	fullPath := path.Join(s.Dir, fmt.Sprintf("%s.json", key))
+	if err := s.writeFile(fmt.Sprintf("%s.json", key), strippedBytes); err != nil {
+		return fmt.Errorf("write issue: %w", err)
+	}
+	slog.Debug("saved", "path", fullPath)
+
+	return nil
+}
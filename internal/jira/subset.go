@@ -0,0 +1,222 @@
+package jira
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SubsetFile records how an extracted sub-cache was produced.
+const SubsetFile = "extract.json"
+
+// SubsetOptions selects the issues of a sub-cache: those of a sprint or an
+// epic, widened by their parents, subtasks and links.
+type SubsetOptions struct {
+	Sprint string
+	Epic   string
+	// LinkDepth is how many hops of issue links are followed from the
+	// selected issues; 0 follows none.
+	LinkDepth int
+}
+
+// Subset is the outcome of selecting a sub-cache.
+type Subset struct {
+	Source    string   `json:"source"`
+	Sprint    string   `json:"sprint,omitempty"`
+	Epic      string   `json:"epic,omitempty"`
+	LinkDepth int      `json:"linkDepth"`
+	Extracted string   `json:"extracted"`
+	Keys      []string `json:"keys"`
+	// Missing lists parents and linked issues that are not in the source
+	// cache, so the gaps of the dataset are known.
+	Missing []string `json:"missing,omitempty"`
+}
+
+// inSprintHistory reports whether the issue is or ever was in the sprint,
+// according to its sprint field and changelog.
+func inSprintHistory(issue JiraIssueWithSprints, changelog Changelog, sprint string) bool {
+	if issue.InSprint(sprint) {
+		return true
+	}
+	for _, h := range changelog.Histories {
+		for _, item := range h.Items {
+			if item.Field != "Sprint" {
+				continue
+			}
+			for _, value := range []string{item.FromString, item.ToString} {
+				for _, name := range strings.Split(value, ",") {
+					if SameSprint(strings.TrimSpace(name), sprint) {
+						return true
+					}
+				}
+			}
+		}
+	}
+	return false
+}
+
+// linkedKeys returns the keys of every issue linked from issue.
+func linkedKeys(issue JiraIssueWithSprints) []string {
+	var keys []string
+	for _, link := range issue.Fields.IssueLinks {
+		if link.InwardIssue != nil {
+			keys = append(keys, link.InwardIssue.Key)
+		}
+		if link.OutwardIssue != nil {
+			keys = append(keys, link.OutwardIssue.Key)
+		}
+	}
+	return keys
+}
+
+// SelectSubset picks the keys of a sub-cache from a store. Issues removed
+// from the sprint part way through are included, as a retro needs them.
+func SelectSubset(store Store, opts SubsetOptions) (*Subset, error) {
+	if (opts.Sprint == "") == (opts.Epic == "") {
+		return nil, fmt.Errorf("exactly one of a sprint or an epic must be given")
+	}
+	epic := strings.ToUpper(opts.Epic)
+	issues := LoadIssues(store, "")
+	byKey := make(map[string]JiraIssueWithSprints, len(issues))
+	for _, issue := range issues {
+		byKey[issue.Key] = issue
+	}
+
+	selected := map[string]bool{}
+	for _, issue := range issues {
+		switch {
+		case opts.Sprint != "":
+			changelog, _ := store.ReadChangelog(issue.Key)
+			if inSprintHistory(issue, changelog, opts.Sprint) {
+				selected[issue.Key] = true
+			}
+		case issue.Key == epic || issue.EpicKey() == epic:
+			selected[issue.Key] = true
+		}
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("no cached issues match")
+	}
+
+	// Subtasks follow the selected issues, which in turn bring their
+	// parents and epics.
+	for _, issue := range issues {
+		if parent := issue.Fields.Parent.Key; parent != "" && selected[parent] {
+			selected[issue.Key] = true
+		}
+	}
+	missing := map[string]bool{}
+	add := func(key string) bool {
+		if key == "" || selected[key] {
+			return false
+		}
+		if _, ok := byKey[key]; !ok {
+			missing[key] = true
+			return false
+		}
+		selected[key] = true
+		return true
+	}
+	frontier := make([]string, 0, len(selected))
+	for key := range selected {
+		frontier = append(frontier, key)
+	}
+	for _, key := range frontier {
+		issue := byKey[key]
+		if add(issue.Fields.Parent.Key) {
+			frontier = append(frontier, issue.Fields.Parent.Key)
+		}
+		if epic := issue.EpicKey(); add(epic) {
+			frontier = append(frontier, epic)
+		}
+	}
+	for depth := 0; depth < opts.LinkDepth && len(frontier) > 0; depth++ {
+		var next []string
+		for _, key := range frontier {
+			for _, linked := range linkedKeys(byKey[key]) {
+				if add(linked) {
+					next = append(next, linked)
+				}
+			}
+		}
+		frontier = next
+	}
+
+	subset := &Subset{
+		Sprint:    opts.Sprint,
+		Epic:      epic,
+		LinkDepth: opts.LinkDepth,
+		Extracted: time.Now().UTC().Format(time.RFC3339),
+	}
+	for key := range selected {
+		subset.Keys = append(subset.Keys, key)
+	}
+	for key := range missing {
+		subset.Missing = append(subset.Missing, key)
+	}
+	sort.Slice(subset.Keys, func(i, j int) bool { return LessKey(subset.Keys[i], subset.Keys[j]) })
+	sort.Slice(subset.Missing, func(i, j int) bool { return LessKey(subset.Missing[i], subset.Missing[j]) })
+	return subset, nil
+}
+
+// LessKey orders issue keys by project, then numerically.
+func LessKey(a, b string) bool {
+	pa, pb := projectOf(a), projectOf(b)
+	if pa != pb {
+		return pa < pb
+	}
+	return issueNumber(a) < issueNumber(b)
+}
+
+// CopyIssues copies issues with their changelogs, comments, worklogs and
+// watchers from one store to another, along with the field map the reports
+// need to read custom fields, the board column configurations and the
+// project versions.
+func CopyIssues(src, dst Store, keys []string) error {
+	if m, err := src.ReadFieldMap(); err == nil && len(m.Fields) > 0 {
+		if err := dst.SaveFieldMap(m); err != nil {
+			return err
+		}
+	}
+	if m, err := src.ReadBoards(); err == nil && len(m.Boards) > 0 {
+		if err := dst.SaveBoards(m); err != nil {
+			return err
+		}
+	}
+	if m, err := src.ReadVersions(); err == nil && len(m.Projects) > 0 {
+		if err := dst.SaveVersions(m); err != nil {
+			return err
+		}
+	}
+	for _, key := range keys {
+		issue, err := src.ReadIssue(key)
+		if err != nil {
+			return err
+		}
+		raw := map[string]interface{}{"key": key, "fields": issue.Fields.Raw}
+		var changelog interface{}
+		if cl, err := src.ReadChangelog(key); err == nil {
+			changelog = cl
+		}
+		if err := dst.SaveIssue(key, raw, changelog); err != nil {
+			return fmt.Errorf("copy %s: %w", key, err)
+		}
+		if comments, err := src.ReadComments(key); err == nil {
+			if err := dst.SaveComments(key, comments); err != nil {
+				return fmt.Errorf("copy comments of %s: %w", key, err)
+			}
+		}
+		if worklogs, err := src.ReadWorklogs(key); err == nil {
+			if err := dst.SaveWorklogs(key, worklogs); err != nil {
+				return fmt.Errorf("copy worklogs of %s: %w", key, err)
+			}
+		}
+		if watchers, err := src.ReadWatchers(key); err == nil {
+			if err := dst.SaveWatchers(key, watchers); err != nil {
+				return fmt.Errorf("copy watchers of %s: %w", key, err)
+			}
+		}
+	}
+	return nil
+}
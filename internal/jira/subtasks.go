@@ -0,0 +1,87 @@
+package jira
+
+import "strings"
+
+// IssueRef is a reference to another issue, such as the subtasks listed on
+// their parent.
+type IssueRef struct {
+	ID  string `json:"id"`
+	Key string `json:"key"`
+}
+
+// IsSubtask reports whether the issue is a subtask. Jira flags subtask
+// issue types; older cached issues lack the flag, so the usual names are
+// accepted. On Jira Cloud the parent of other issues is their epic.
+func (i JiraIssueWithSprints) IsSubtask() bool {
+	if i.Fields.IssueType.Subtask {
+		return true
+	}
+	switch strings.ToLower(i.Fields.IssueType.Name) {
+	case "sub-task", "subtask":
+		return true
+	}
+	return false
+}
+
+// SubtaskParent returns the story a subtask belongs to, or "" for issues
+// that are not subtasks.
+func (i JiraIssueWithSprints) SubtaskParent() string {
+	if !i.IsSubtask() {
+		return ""
+	}
+	return i.Fields.Parent.Key
+}
+
+// SubtasksByParent groups the subtasks among issues by the key of their
+// parent, in the order they are listed.
+func SubtasksByParent(issues []JiraIssueWithSprints) map[string][]JiraIssueWithSprints {
+	out := map[string][]JiraIssueWithSprints{}
+	for _, issue := range issues {
+		if parent := issue.SubtaskParent(); parent != "" {
+			out[parent] = append(out[parent], issue)
+		}
+	}
+	return out
+}
+
+// RollupEffort is the effort of a story rolled up from its subtasks: the
+// sum of theirs when any of them carries effort, the story's own when
+// none does, so a story estimated as a whole is not counted twice.
+func RollupEffort(parent float64, subtasks []float64) float64 {
+	sum := 0.0
+	for _, e := range subtasks {
+		sum += e
+	}
+	if sum == 0 {
+		return parent
+	}
+	return sum
+}
+
+// RollupStatus is the status of a story rolled up from its subtasks. The
+// story is done when all of its subtasks are, taking the story's status
+// when it is done itself and the last subtask's otherwise. While subtasks
+// are open it takes the status of the most advanced open one when that is
+// ahead of its own, when its own is not known, or when the story was closed
+// before its subtasks.
+func RollupStatus(parent Status, subtasks []Status) Status {
+	var open *Status
+	for i := range subtasks {
+		s := &subtasks[i]
+		if !s.IsDone() && (open == nil || s.Rank() > open.Rank()) {
+			open = s
+		}
+	}
+	switch {
+	case len(subtasks) == 0:
+		return parent
+	case open == nil:
+		if parent.IsDone() {
+			return parent
+		}
+		return subtasks[len(subtasks)-1]
+	case parent.Name == "" || parent.IsDone() || open.Rank() > parent.Rank():
+		return *open
+	}
+	return parent
+}
@@ -0,0 +1,541 @@
+package jira
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Sync phases reported through SyncOptions.Progress.
+const (
+	SyncPhaseUpdated   = "updated"
+	SyncPhaseBackfill  = "backfill"
+	SyncPhaseDenied    = "denied"
+	SyncPhaseReconcile = "reconcile"
+	SyncPhaseForce     = "force"
+	SyncPhaseSmart     = "smart"
+	SyncPhaseSprint    = "sprint"
+	SyncPhaseJQL       = "jql"
+)
+
+var orderByPattern = regexp.MustCompile(`(?i)\border\s+by\b`)
+
+type SyncOptions struct {
+	Project string
+	// Lookback is subtracted from the newest cached updated timestamp.
+	Lookback time.Duration
+	// AutoLookback derives Lookback from the index lag and clock skew
+	// observed by previous syncs of the project.
+	AutoLookback bool
+	// ForceUpdate refetches every issue number up to the highest key.
+	ForceUpdate bool
+	// SmartUpdate refetches issues not fetched within Lookback.
+	SmartUpdate bool
+	// Sprint refetches every issue in the named sprint.
+	Sprint string
+	// JQL, for SyncJQL, selects the issues to mirror instead of a project.
+	JQL string
+	// ChangelogFields, when set, limits the persisted changelog to these
+	// fields (see FilterChangelog).
+	ChangelogFields []string
+	// RetryDenied selects the issues marked denied that are asked for
+	// again, in their own phase of a project sync and wherever else a
+	// sync comes across them.
+	RetryDenied DeniedPolicy
+	// Reconcile checks the cached issues of a project that its search no
+	// longer lists, tombstoning those deleted or moved away.
+	Reconcile bool
+	// Resume continues from the checkpoint an interrupted sync left in the
+	// sync state instead of starting over.
+	Resume bool
+	// IncrementalChangelog fetches only the changelog histories newer than
+	// the high-water mark saved with each changelog, through the paginated
+	// changelog endpoint, and appends them.
+	IncrementalChangelog bool
+	// Comments also refreshes {KEY}.comments.json for every fetched issue.
+	Comments bool
+	// Worklogs also refreshes {KEY}.worklogs.json for every fetched issue
+	// with logged time.
+	Worklogs bool
+	// Watchers also refreshes {KEY}.watchers.json for every fetched issue
+	// somebody watches.
+	Watchers bool
+	// Attachments, when set, downloads the attachments of every fetched
+	// issue (see SyncAttachments).
+	Attachments *AttachmentOptions
+	// Progress, when set, is called after every issue is processed.
+	Progress func(SyncProgress)
+	// OnChange, when set, receives the change events detected for each
+	// successfully refetched issue.
+	OnChange func([]ChangeEvent)
+}
+
+type SyncProgress struct {
+	Phase string
+	Key   string
+	Done  int
+	Total int
+	Err   error
+}
+
+type SyncResult struct {
+	Since      time.Time
+	HighestKey string
+	Fetched    int
+	Denied     int
+	Failed     int
+	Skipped    int
+	// Lookback is the overlap window that was used.
+	Lookback time.Duration
+	// Missed counts fetched issues the updated-issues search should have
+	// returned but did not.
+	Missed int
+	// Comments counts issues whose cached comments changed.
+	Comments int
+	// Worklogs counts issues whose cached worklogs were refreshed.
+	Worklogs int
+	// Watchers counts issues whose cached watchers were refreshed.
+	Watchers int
+	// Attachments counts downloaded attachment files.
+	Attachments int
+	// Recovered counts denied issues that were readable when retried.
+	Recovered int
+	// Deleted and Moved count the issues tombstoned because Jira no
+	// longer has them under their key.
+	Deleted int
+	Moved   int
+}
+
+func issueNumber(issueKey string) int {
+	parts := strings.Split(issueKey, "-")
+	if len(parts) != 2 {
+		return 0
+	}
+	n, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+type syncer struct {
+	ctx    context.Context
+	client *Client
+	store  Store
+	opts   SyncOptions
+	result SyncResult
+
+	// searched holds the keys returned by the updated-issues search, which
+	// ran at searchedAt (server time).
+	searched   map[string]bool
+	searchedAt time.Time
+	maxLag     time.Duration
+
+	// stateKey names the sync state checkpoints are saved to; empty
+	// disables them. resumeFrom is the checkpoint being resumed.
+	stateKey   string
+	resumeFrom *SyncCheckpoint
+}
+
+// observeMiss checks whether a fetched issue changed inside the searched
+// window without being returned, which means Jira's index lagged behind.
+// prev is the updated time cached before the fetch, zero for new issues.
+func (s *syncer) observeMiss(key string, prev time.Time) {
+	if s.searched == nil || s.searched[key] {
+		return
+	}
+	updated, ok := s.store.IssueUpdated(key)
+	if !ok || !updated.After(prev) || updated.Before(s.result.Since) || !updated.Before(s.searchedAt) {
+		return
+	}
+	s.result.Missed++
+	if lag := s.searchedAt.Sub(updated); lag > s.maxLag {
+		s.maxLag = lag
+	}
+}
+
+func (s *syncer) fetch(phase string, key string, done, total int) error {
+	var prev *JiraIssueWithSprints
+	if s.opts.OnChange != nil {
+		if issue, err := s.store.ReadIssue(key); err == nil {
+			prev = &issue
+		}
+	}
+
+	var prevUpdated time.Time
+	if s.searched != nil && !s.searched[key] {
+		prevUpdated, _ = s.store.IssueUpdated(key)
+	}
+
+	err := s.client.syncIssueWith(s.ctx, s.store, key, s.opts.ChangelogFields, s.opts.IncrementalChangelog)
+	var tombstoned *TombstonedError
+	switch {
+	case err == nil:
+		s.result.Fetched++
+		s.observeMiss(key, prevUpdated)
+		if s.opts.Comments {
+			changed, commentErr := s.client.SyncComments(s.ctx, s.store, key)
+			if commentErr != nil {
+				err = fmt.Errorf("comments: %w", commentErr)
+			} else if changed {
+				s.result.Comments++
+			}
+		}
+		if s.opts.Worklogs && err == nil {
+			changed, worklogErr := s.client.SyncWorklogs(s.ctx, s.store, key)
+			if worklogErr != nil {
+				err = fmt.Errorf("worklogs: %w", worklogErr)
+			} else if changed {
+				s.result.Worklogs++
+			}
+		}
+		if s.opts.Watchers && err == nil {
+			changed, watcherErr := s.client.SyncWatchers(s.ctx, s.store, key)
+			if watcherErr != nil {
+				err = fmt.Errorf("watchers: %w", watcherErr)
+			} else if changed {
+				s.result.Watchers++
+			}
+		}
+		if s.opts.Attachments != nil && err == nil {
+			downloaded, attachErr := s.client.SyncAttachments(s.ctx, s.store, key, *s.opts.Attachments)
+			s.result.Attachments += downloaded.Downloaded
+			if attachErr != nil {
+				err = fmt.Errorf("attachments: %w", attachErr)
+			}
+		}
+		if s.opts.OnChange != nil {
+			if next, readErr := s.store.ReadIssue(key); readErr == nil {
+				if events := DiffIssues(prev, next); len(events) > 0 {
+					s.opts.OnChange(events)
+				}
+			}
+		}
+	case IsStatus(err, 403):
+		s.result.Denied++
+	case errors.As(err, &tombstoned):
+		if tombstoned.Tombstone.Reason == TombstoneMoved {
+			s.result.Moved++
+		} else {
+			s.result.Deleted++
+		}
+	default:
+		s.result.Failed++
+	}
+	if s.opts.Progress != nil {
+		s.opts.Progress(SyncProgress{Phase: phase, Key: key, Done: done, Total: total, Err: err})
+	}
+	return err
+}
+
+// fetchAll fetches the keys of a phase. When the sync is cancelled, or Jira
+// stays unreachable for maxUnreachable issues in a row, the keys not yet
+// fetched are checkpointed and the error returned.
+func (s *syncer) fetchAll(phase string, keys []string, skipDenied bool) error {
+	keys = s.resumeKeys(phase, keys)
+	unreachable := 0
+	for i, key := range keys {
+		if err := s.ctx.Err(); err != nil {
+			s.saveCheckpoint(phase, keys[i:], err)
+			return err
+		}
+		retry := false
+		if s.store.IsDenied(key) {
+			if skipDenied && !s.denialDue(key) {
+				s.result.Skipped++
+				continue
+			}
+			retry = true
+		}
+		err := s.fetch(phase, key, i+1, len(keys))
+		if retry && err == nil {
+			s.result.Recovered++
+		}
+		if ctxErr := s.ctx.Err(); ctxErr != nil {
+			// The fetch was cut short, so the key is still pending.
+			s.saveCheckpoint(phase, keys[i:], ctxErr)
+			return ctxErr
+		}
+		if !isUnreachable(err) {
+			unreachable = 0
+			continue
+		}
+		if unreachable++; unreachable >= maxUnreachable {
+			s.saveCheckpoint(phase, keys[i-unreachable+1:], err)
+			return fmt.Errorf("giving up after %d issues in a row could not reach Jira: %w", unreachable, err)
+		}
+	}
+	return nil
+}
+
+// denialDue reports whether the retry policy asks for a denied issue again.
+func (s *syncer) denialDue(key string) bool {
+	d, ok := s.store.Denial(key)
+	return ok && s.opts.RetryDenied.Due(d, time.Now())
+}
+
+// SyncIssue fetches a single issue with its changelog into the store,
+// marking it as denied when Jira answers 403 and clearing the mark when
+// an issue marked before is readable again. A cached issue Jira no longer
+// has, or answers with another key, is tombstoned and a TombstonedError
+// returned.
+func (c *Client) SyncIssue(ctx context.Context, store Store, key string) error {
+	return c.syncIssue(ctx, store, key, nil)
+}
+
+func (c *Client) syncIssue(ctx context.Context, store Store, key string, changelogFields []string) error {
+	return c.syncIssueWith(ctx, store, key, changelogFields, false)
+}
+
+// syncIssueWith fetches an issue, its changelog whole or, with
+// incremental, only the histories newer than those saved.
+func (c *Client) syncIssueWith(ctx context.Context, store Store, key string, changelogFields []string, incremental bool) error {
+	var err error
+	if incremental {
+		err = c.syncIssueIncremental(ctx, store, key, changelogFields)
+	} else {
+		var issue map[string]interface{}
+		var changelog interface{}
+		issue, changelog, err = c.FetchIssueWithChangelog(ctx, key)
+		if err == nil {
+			err = store.SaveIssue(key, issue, FilterChangelog(changelog, changelogFields))
+		}
+	}
+	if err == nil && store.IsDenied(key) {
+		return store.ClearDenied(key)
+	}
+	if t, ok := tombstoneFor(store, key, err); ok {
+		if tombErr := store.Tombstone(key, t); tombErr != nil {
+			return fmt.Errorf("%w (and failed to tombstone: %v)", err, tombErr)
+		}
+		return &TombstonedError{Tombstone: t, Err: err}
+	}
+	if IsStatus(err, 403) {
+		if markErr := store.MarkDenied(key, 403); markErr != nil {
+			return fmt.Errorf("%w (and failed to mark denied: %v)", err, markErr)
+		}
+	}
+	return err
+}
+
+// SyncProject performs the full incremental update of a project: recently
+// updated issues, missing issue numbers, and the optional force/smart/sprint
+// refreshes. Per-issue failures are counted in the result; only failures that
+// prevent the sync from continuing are returned as errors.
+func SyncProject(ctx context.Context, client *Client, store Store, opts SyncOptions) (SyncResult, error) {
+	if opts.Project == "" {
+		return SyncResult{}, fmt.Errorf("project is required")
+	}
+	project := strings.ToUpper(opts.Project)
+	s := &syncer{ctx: ctx, client: client, store: store, opts: opts}
+	s.loadCheckpoint(project)
+
+	var state SyncState
+	if opts.AutoLookback {
+		var err error
+		if state, err = store.ReadSyncState(project); err != nil {
+			return s.result, fmt.Errorf("read sync state: %w", err)
+		}
+		opts.Lookback = state.Lookback()
+		s.opts.Lookback = opts.Lookback
+	}
+	s.result.Lookback = opts.Lookback
+
+	// Issues updated since the newest cached timestamp
+	s.result.Since = store.LatestUpdated(project).Add(-opts.Lookback)
+	if !s.skipPhase(SyncPhaseUpdated) {
+		s.searchedAt = time.Now()
+		jql := JQL{}.Equals("project", project).Since("updated", s.result.Since).OrderBy("updated", Desc).String()
+		updated, err := client.SearchIssueKeys(ctx, jql, func(key string, updated time.Time) bool {
+			onDisk, ok := store.IssueUpdated(key)
+			return ok && !updated.After(onDisk)
+		})
+		if err != nil {
+			return s.result, fmt.Errorf("failed to query updated issues: %w", err)
+		}
+		if skew, ok := client.ClockSkew(); ok {
+			s.searchedAt = s.searchedAt.Add(skew)
+		}
+		s.searched = make(map[string]bool)
+		var updatedKeys []string
+		for _, issue := range updated {
+			updatedKeys = append(updatedKeys, issue.Key)
+			s.searched[issue.Key] = true
+		}
+		if err := s.fetchAll(SyncPhaseUpdated, updatedKeys, true); err != nil {
+			return s.result, err
+		}
+	}
+
+	// Missing issue numbers, newest first
+	var err error
+	s.result.HighestKey, err = client.HighestIssueKey(ctx, project)
+	if err != nil {
+		return s.result, err
+	}
+	maxNumber := issueNumber(s.result.HighestKey)
+	if maxNumber == 0 {
+		return s.result, fmt.Errorf("failed to extract numeric part of issue key from %s", s.result.HighestKey)
+	}
+
+	numbersOnDisk, err := store.IssueNumbers(project)
+	if err != nil {
+		return s.result, err
+	}
+	var missing []string
+	for i := maxNumber; i >= 1; i-- {
+		if _, exists := numbersOnDisk[i]; !exists {
+			missing = append(missing, fmt.Sprintf("%s-%d", project, i))
+		}
+	}
+	if err := s.fetchAll(SyncPhaseBackfill, missing, false); err != nil {
+		return s.result, err
+	}
+
+	// Cached issues the project no longer lists, newest first
+	if opts.Reconcile && !s.skipPhase(SyncPhaseReconcile) {
+		listed, err := client.SearchIssueKeys(ctx, JQL{}.Equals("project", project).OrderBy("key", Desc).String(), nil)
+		if err != nil {
+			return s.result, fmt.Errorf("failed to list issues to reconcile: %w", err)
+		}
+		found := make(map[string]bool, len(listed))
+		for _, issue := range listed {
+			found[issue.Key] = true
+		}
+		var gone []string
+		for _, key := range store.IssueKeys(project) {
+			if !found[key] {
+				gone = append(gone, key)
+			}
+		}
+		sort.Slice(gone, func(i, j int) bool {
+			return issueNumber(gone[i]) > issueNumber(gone[j])
+		})
+		if err := s.fetchAll(SyncPhaseReconcile, gone, true); err != nil {
+			return s.result, err
+		}
+	}
+
+	// Denied issues due for another try, newest first
+	if !s.skipPhase(SyncPhaseDenied) {
+		var due []string
+		denied := store.DeniedKeys(project)
+		for i := len(denied) - 1; i >= 0; i-- {
+			if s.denialDue(denied[i]) {
+				due = append(due, denied[i])
+			}
+		}
+		if err := s.fetchAll(SyncPhaseDenied, due, false); err != nil {
+			return s.result, err
+		}
+	}
+
+	if opts.ForceUpdate && !s.skipPhase(SyncPhaseForce) {
+		var all []string
+		for i := maxNumber; i >= 1; i-- {
+			all = append(all, fmt.Sprintf("%s-%d", project, i))
+		}
+		if err := s.fetchAll(SyncPhaseForce, all, false); err != nil {
+			return s.result, err
+		}
+	}
+
+	if opts.SmartUpdate && !s.skipPhase(SyncPhaseSmart) {
+		staleKeys := store.StaleIssueKeys(project, opts.Lookback)
+		sort.Slice(staleKeys, func(i, j int) bool {
+			return issueNumber(staleKeys[i]) > issueNumber(staleKeys[j])
+		})
+		if err := s.fetchAll(SyncPhaseSmart, staleKeys, true); err != nil {
+			return s.result, err
+		}
+	}
+
+	if opts.Sprint != "" {
+		sprintID, err := store.LookupSprintID(project, opts.Sprint)
+		if err != nil {
+			return s.result, err
+		}
+		jql := JQL{}.Equals("project", project).Equals("Sprint", sprintID).OrderBy("key", Asc).String()
+		sprintIssues, err := client.SearchIssueKeys(ctx, jql, nil)
+		if err != nil {
+			return s.result, fmt.Errorf("fetch sprint issues: %w", err)
+		}
+		var sprintKeys []string
+		for _, issue := range sprintIssues {
+			sprintKeys = append(sprintKeys, issue.Key)
+		}
+		if err := s.fetchAll(SyncPhaseSprint, sprintKeys, true); err != nil {
+			return s.result, err
+		}
+	}
+
+	if opts.AutoLookback {
+		skew, skewKnown := client.ClockSkew()
+		state.Observe(s.maxLag, s.result.Missed, skew, skewKnown)
+		if err := store.SaveSyncState(project, state); err != nil {
+			return s.result, fmt.Errorf("save sync state: %w", err)
+		}
+	}
+	if err := s.clearCheckpoint(); err != nil {
+		return s.result, fmt.Errorf("clear checkpoint: %w", err)
+	}
+
+	return s.result, nil
+}
+
+// SyncJQL mirrors every issue matching an arbitrary JQL query. Without an
+// explicit ORDER BY the results are ordered by updated time so paging stops
+// at the first issue that is already current on disk; otherwise every page
+// is read and current issues are skipped individually. ForceUpdate refetches
+// all matches.
+func SyncJQL(ctx context.Context, client *Client, store Store, opts SyncOptions) (SyncResult, error) {
+	if strings.TrimSpace(opts.JQL) == "" {
+		return SyncResult{}, fmt.Errorf("jql is required")
+	}
+	s := &syncer{ctx: ctx, client: client, store: store, opts: opts}
+	s.loadCheckpoint(SyncPhaseJQL)
+	if s.resumeFrom != nil {
+		if err := s.fetchAll(SyncPhaseJQL, nil, !opts.ForceUpdate); err != nil {
+			return s.result, err
+		}
+		return s.result, s.clearCheckpoint()
+	}
+
+	jql := opts.JQL
+	ordered := !orderByPattern.MatchString(jql)
+	if ordered {
+		jql = JQL{}.Raw(jql).OrderBy("updated", Desc).String()
+	}
+	isCurrent := func(key string, updated time.Time) bool {
+		if opts.ForceUpdate {
+			return false
+		}
+		onDisk, ok := store.IssueUpdated(key)
+		return ok && !updated.After(onDisk)
+	}
+
+	matches, err := client.SearchIssueKeys(ctx, jql, func(key string, updated time.Time) bool {
+		return ordered && isCurrent(key, updated)
+	})
+	if err != nil {
+		return s.result, fmt.Errorf("failed to query issues: %w", err)
+	}
+
+	var keys []string
+	for _, issue := range matches {
+		if isCurrent(issue.Key, issue.UpdatedTime) {
+			s.result.Skipped++
+			continue
+		}
+		keys = append(keys, issue.Key)
+	}
+	if err := s.fetchAll(SyncPhaseJQL, keys, !opts.ForceUpdate); err != nil {
+		return s.result, err
+	}
+	return s.result, s.clearCheckpoint()
+}
@@ -0,0 +1,839 @@
+package jira
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/jctanner/rhoai-jira/internal/testsuite"
+)
+
+var base = time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+
+// fakeProject serves three issues of project DEMO, DEMO-2 with a status
+// change in its changelog.
+func fakeProject(t *testing.T) *testsuite.Jira {
+	t.Helper()
+	two := testsuite.NewIssue("DEMO-2", "second", "In Progress", base.Add(2*time.Hour))
+	two.Histories = []map[string]any{testsuite.History("10", base.Add(time.Hour), "status", "New", "In Progress")}
+	return testsuite.NewJira(t,
+		testsuite.NewIssue("DEMO-1", "first", "New", base.Add(time.Hour)),
+		two,
+		testsuite.NewIssue("DEMO-3", "third", "Closed", base.Add(3*time.Hour)),
+	)
+}
+
+func testClient(j *testsuite.Jira) *Client {
+	c := NewClient(j.URL, "token")
+	c.Limiter = nil
+	c.MinInterval = 0
+	c.Audit = nil
+	c.Retry = RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond, Statuses: []int{429, 503}}
+	return c
+}
+
+func testStore(t *testing.T) Store {
+	t.Helper()
+	store, err := OpenStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestSyncProjectFetchesEveryIssue(t *testing.T) {
+	j := fakeProject(t)
+	store := testStore(t)
+	result, err := SyncProject(context.Background(), testClient(j), store, SyncOptions{Project: "DEMO"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Fetched != 3 || result.Failed != 0 || result.HighestKey != "DEMO-3" {
+		t.Fatalf("got %+v, want 3 issues fetched up to DEMO-3", result)
+	}
+	changelog, err := store.ReadChangelog("DEMO-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changelog.Histories) != 1 || changelog.Histories[0].ID != "10" {
+		t.Fatalf("got histories %+v, want the status change", changelog.Histories)
+	}
+}
+
+func TestSyncProjectOnlyRefetchesUpdatedIssues(t *testing.T) {
+	j := fakeProject(t)
+	store := testStore(t)
+	client := testClient(j)
+	if _, err := SyncProject(context.Background(), client, store, SyncOptions{Project: "DEMO"}); err != nil {
+		t.Fatal(err)
+	}
+	j.Update("DEMO-1", "summary", "first, renamed", base.Add(5*time.Hour))
+	before := j.Count("/issue/")
+	result, err := SyncProject(context.Background(), client, store, SyncOptions{Project: "DEMO"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Fetched != 1 || j.Count("/issue/")-before != 1 {
+		t.Fatalf("fetched %d issues in %d requests, want only DEMO-1", result.Fetched, j.Count("/issue/")-before)
+	}
+	issue, err := store.ReadIssue("DEMO-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if issue.Fields.Summary != "first, renamed" {
+		t.Fatalf("got summary %q, want the updated one", issue.Fields.Summary)
+	}
+}
+
+func TestSyncProjectMarksDeniedIssues(t *testing.T) {
+	j := fakeProject(t)
+	j.Deny("DEMO-2")
+	store := testStore(t)
+	client := testClient(j)
+	result, err := SyncProject(context.Background(), client, store, SyncOptions{Project: "DEMO"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Denied != 1 || result.Fetched != 2 {
+		t.Fatalf("got %+v, want 2 fetched and 1 denied", result)
+	}
+	if !store.IsDenied("DEMO-2") {
+		t.Fatal("DEMO-2 was not marked denied")
+	}
+
+	// The denied marker keeps the next sync from asking again.
+	before := j.Count("/issue/DEMO-2")
+	if _, err := SyncProject(context.Background(), client, store, SyncOptions{Project: "DEMO"}); err != nil {
+		t.Fatal(err)
+	}
+	if n := j.Count("/issue/DEMO-2") - before; n != 0 {
+		t.Fatalf("refetched denied DEMO-2 %d times", n)
+	}
+}
+
+func TestSyncProjectRetriesDeniedIssuesByPolicy(t *testing.T) {
+	j := fakeProject(t)
+	j.Deny("DEMO-2")
+	store := testStore(t)
+	client := testClient(j)
+	if _, err := SyncProject(context.Background(), client, store, SyncOptions{Project: "DEMO"}); err != nil {
+		t.Fatal(err)
+	}
+	denial, ok := store.Denial("DEMO-2")
+	if !ok || denial.Status != 403 || time.Since(denial.Time) > time.Minute {
+		t.Fatalf("got denial %+v, %v; want a 403 recorded just now", denial, ok)
+	}
+
+	// A fresh denial is not due for a re-check after 30 days.
+	j.Allow("DEMO-2")
+	before := j.Count("/issue/DEMO-2")
+	monthly := DeniedPolicy{After: 30 * 24 * time.Hour}
+	if _, err := SyncProject(context.Background(), client, store, SyncOptions{Project: "DEMO", RetryDenied: monthly}); err != nil {
+		t.Fatal(err)
+	}
+	if n := j.Count("/issue/DEMO-2") - before; n != 0 {
+		t.Fatalf("retried DEMO-2 %d times, want none", n)
+	}
+	if !monthly.Due(Denial{Time: time.Now().Add(-31 * 24 * time.Hour)}, time.Now()) {
+		t.Fatal("a denial 31 days old is not due")
+	}
+
+	result, err := SyncProject(context.Background(), client, store, SyncOptions{Project: "DEMO", RetryDenied: DeniedPolicy{All: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Recovered != 1 || store.IsDenied("DEMO-2") {
+		t.Fatalf("got %+v, want DEMO-2 recovered and no longer denied", result)
+	}
+	if _, err := store.ReadIssue("DEMO-2"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSyncProjectTombstonesDeletedAndMovedIssues(t *testing.T) {
+	j := fakeProject(t)
+	store := testStore(t)
+	client := testClient(j)
+	if _, err := SyncProject(context.Background(), client, store, SyncOptions{Project: "DEMO"}); err != nil {
+		t.Fatal(err)
+	}
+	j.Delete("DEMO-1")
+	j.Move("DEMO-2", "OTHER-7")
+
+	result, err := SyncProject(context.Background(), client, store, SyncOptions{Project: "DEMO", Reconcile: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Deleted != 1 || result.Moved != 1 || result.Failed != 0 {
+		t.Fatalf("got %+v, want 1 deleted and 1 moved", result)
+	}
+	if keys := store.IssueKeys("DEMO"); len(keys) != 1 || keys[0] != "DEMO-3" {
+		t.Fatalf("got cached keys %v, want only DEMO-3", keys)
+	}
+	tombstones := store.Tombstones("DEMO")
+	if len(tombstones) != 2 || tombstones[0].Reason != TombstoneDeleted || tombstones[1].MovedTo != "OTHER-7" {
+		t.Fatalf("got tombstones %+v", tombstones)
+	}
+
+	// Tombstoned numbers are not fetched again as missing.
+	tombstoned := func() int { return j.Count("/issue/DEMO-1") + j.Count("/issue/DEMO-2") }
+	before := tombstoned()
+	if _, err := SyncProject(context.Background(), client, store, SyncOptions{Project: "DEMO"}); err != nil {
+		t.Fatal(err)
+	}
+	if n := tombstoned() - before; n != 0 {
+		t.Fatalf("asked for tombstoned issues %d times again", n)
+	}
+}
+
+func TestSyncProjectPagesChangelogsLongerThanJiraEmbeds(t *testing.T) {
+	long := testsuite.NewIssue("DEMO-4", "long-lived", "Closed", base.Add(10*time.Hour))
+	statuses := []string{"New", "In Progress", "Review", "In Progress", "Review", "Closed"}
+	for i := 1; i < len(statuses); i++ {
+		long.Histories = append(long.Histories, testsuite.History(strconv.Itoa(100+i), base.Add(time.Duration(i)*time.Hour), "status", statuses[i-1], statuses[i]))
+	}
+	j := fakeProject(t)
+	j.Add(long)
+	j.ChangelogPageSize = 2
+	store := testStore(t)
+	if _, err := SyncProject(context.Background(), testClient(j), store, SyncOptions{Project: "DEMO"}); err != nil {
+		t.Fatal(err)
+	}
+	changelog, err := store.ReadChangelog("DEMO-4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changelog.Histories) != 5 {
+		t.Fatalf("got %d histories, want all 5", len(changelog.Histories))
+	}
+	for i, h := range changelog.Histories {
+		if want := strconv.Itoa(101 + i); h.ID != want {
+			t.Fatalf("history %d has id %s, want %s", i, h.ID, want)
+		}
+	}
+	if n := j.Count("/issue/DEMO-4/changelog"); n != 2 {
+		t.Fatalf("made %d changelog requests for DEMO-4, want 2", n)
+	}
+	if n := j.Count("/issue/DEMO-2/changelog"); n != 0 {
+		t.Fatalf("made %d changelog requests for DEMO-2, whose changelog was whole", n)
+	}
+}
+
+func TestSyncProjectCountsFailedIssues(t *testing.T) {
+	j := fakeProject(t)
+	j.Fail("/issue/DEMO-3", http.StatusInternalServerError, 1)
+	store := testStore(t)
+	result, err := SyncProject(context.Background(), testClient(j), store, SyncOptions{Project: "DEMO"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Failed != 1 || result.Fetched != 2 {
+		t.Fatalf("got %+v, want 2 fetched and 1 failed", result)
+	}
+	if store.IsDenied("DEMO-3") {
+		t.Fatal("a 500 marked DEMO-3 denied")
+	}
+}
+
+func TestGetRetriesRateLimitedRequests(t *testing.T) {
+	j := fakeProject(t)
+	j.RateLimit(2)
+	key, err := testClient(j).HighestIssueKey(context.Background(), "DEMO")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key != "DEMO-3" || len(j.Requests()) != 3 {
+		t.Fatalf("got %s after %d requests, want DEMO-3 after 3", key, len(j.Requests()))
+	}
+}
+
+func TestGetGivesUpAfterMaxAttempts(t *testing.T) {
+	j := fakeProject(t)
+	j.Fail("", http.StatusServiceUnavailable, 5)
+	_, err := testClient(j).HighestIssueKey(context.Background(), "DEMO")
+	if !IsStatus(err, http.StatusServiceUnavailable) {
+		t.Fatalf("got %v, want a 503 status error", err)
+	}
+	if n := len(j.Requests()); n != 3 {
+		t.Fatalf("made %d attempts, want 3", n)
+	}
+}
+
+func TestGetDoesNotRetryServerErrors(t *testing.T) {
+	j := fakeProject(t)
+	j.Fail("", http.StatusInternalServerError, 1)
+	_, err := testClient(j).HighestIssueKey(context.Background(), "DEMO")
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != 500 {
+		t.Fatalf("got %v, want a 500 status error", err)
+	}
+	if n := len(j.Requests()); n != 1 {
+		t.Fatalf("made %d attempts, want 1", n)
+	}
+}
+
+func TestSyncProjectRecordsDiffs(t *testing.T) {
+	j := fakeProject(t)
+	store := &DirStore{Dir: t.TempDir(), RecordDiffs: true}
+	client := testClient(j)
+	if _, err := SyncProject(context.Background(), client, store, SyncOptions{Project: "DEMO"}); err != nil {
+		t.Fatal(err)
+	}
+	j.Update("DEMO-1", "summary", "first, renamed", base.Add(5*time.Hour))
+	if _, err := SyncProject(context.Background(), client, store, SyncOptions{Project: "DEMO"}); err != nil {
+		t.Fatal(err)
+	}
+	diffs, err := store.ReadDiffs("DEMO-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 1 {
+		t.Fatalf("got %d diffs, want 1", len(diffs))
+	}
+	var fields []string
+	for _, c := range diffs[0].Changes {
+		fields = append(fields, c.Field)
+	}
+	if len(fields) != 2 || fields[0] != "summary" || fields[1] != "updated" {
+		t.Fatalf("got changed fields %v, want summary and updated", fields)
+	}
+	if string(diffs[0].Changes[0].From) != `"first"` || string(diffs[0].Changes[0].To) != `"first, renamed"` {
+		t.Fatalf("got summary change %s -> %s", diffs[0].Changes[0].From, diffs[0].Changes[0].To)
+	}
+	if other, _ := store.ReadDiffs("DEMO-2"); len(other) != 0 {
+		t.Fatalf("recorded %d diffs for unchanged DEMO-2", len(other))
+	}
+}
+
+func TestSyncProjectWritesCompressedCache(t *testing.T) {
+	j := fakeProject(t)
+	dir := t.TempDir()
+	store := &DirStore{Dir: dir, Compress: true}
+	if _, err := SyncProject(context.Background(), testClient(j), store, SyncOptions{Project: "DEMO"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "DEMO-2.json.zst")); err != nil {
+		t.Fatalf("issue not written compressed: %v", err)
+	}
+	if keys := store.IssueKeys("DEMO"); len(keys) != 3 {
+		t.Fatalf("got keys %v, want 3", keys)
+	}
+	issue, err := store.ReadIssue("DEMO-2")
+	if err != nil || issue.Fields.Summary != "second" {
+		t.Fatalf("got %q, %v reading DEMO-2", issue.Fields.Summary, err)
+	}
+
+	// Refetching without compression replaces the compressed files.
+	store.Compress = false
+	if _, err := SyncProject(context.Background(), testClient(j), store, SyncOptions{Project: "DEMO", ForceUpdate: true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "DEMO-2.changelog.json.zst")); !os.IsNotExist(err) {
+		t.Fatalf("compressed changelog left behind: %v", err)
+	}
+	if changelog, err := store.ReadChangelog("DEMO-2"); err != nil || len(changelog.Histories) != 1 {
+		t.Fatalf("got %d histories, %v", len(changelog.Histories), err)
+	}
+}
+
+func TestCompactCacheMigratesGzipFiles(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, data []byte) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	gzipped := func(data string) []byte {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Write([]byte(data))
+		zw.Close()
+		return buf.Bytes()
+	}
+	// Written by a version that gzipped the cache.
+	write("DEMO-1.json.gz", gzipped(`{"key": "DEMO-1", "fields": {"summary": "legacy"}}`))
+	write("DEMO-1.changelog.json.gz", gzipped(`{"histories": []}`))
+	// A stale gzip copy next to a newer zstd file.
+	write("DEMO-2.json.zst", compressBytes([]byte(`{"key": "DEMO-2", "fields": {"summary": "current"}}`)))
+	write("DEMO-2.json.gz", gzipped(`{"key": "DEMO-2", "fields": {"summary": "stale"}}`))
+
+	store, err := NewDirStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]string{"DEMO-1": "legacy", "DEMO-2": "current"} {
+		if issue, err := store.ReadIssue(key); err != nil || issue.Fields.Summary != want {
+			t.Fatalf("got %q, %v reading %s before compacting, want %q", issue.Fields.Summary, err, key, want)
+		}
+	}
+
+	result, err := CompactCache(store, CompactOptions{Compress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Files != 3 {
+		t.Errorf("rewrote %d files, want 3", result.Files)
+	}
+	for _, name := range []string{"DEMO-1.json.gz", "DEMO-1.changelog.json.gz", "DEMO-2.json.gz"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s left behind: %v", name, err)
+		}
+	}
+	for _, name := range []string{"DEMO-1.json.zst", "DEMO-1.changelog.json.zst", "DEMO-2.json.zst"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("%s not written: %v", name, err)
+		}
+	}
+	for key, want := range map[string]string{"DEMO-1": "legacy", "DEMO-2": "current"} {
+		if issue, err := store.ReadIssue(key); err != nil || issue.Fields.Summary != want {
+			t.Errorf("got %q, %v reading %s after compacting, want %q", issue.Fields.Summary, err, key, want)
+		}
+	}
+}
+
+func TestBatchWriterKeepsOldFormWhenWriteFails(t *testing.T) {
+	dir := t.TempDir()
+	old := []byte(`{"key": "DEMO-1", "fields": {"summary": "plain"}}`)
+	if err := os.WriteFile(filepath.Join(dir, "DEMO-1.json"), old, 0644); err != nil {
+		t.Fatal(err)
+	}
+	// A directory in the way of the compressed form makes its write fail,
+	// even for root.
+	if err := os.MkdirAll(filepath.Join(dir, "DEMO-1.json.zst", "blocker"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	w := NewBatchWriter(dir, 4)
+	if err := w.Write("DEMO-1.json.zst", compressBytes([]byte(`{"key": "DEMO-1"}`))); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err == nil {
+		t.Fatal("write over a directory succeeded")
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "DEMO-1.json"))
+	if err != nil || !bytes.Equal(data, old) {
+		t.Fatalf("old form lost after a failed write: %q, %v", data, err)
+	}
+	if journal, _ := os.ReadFile(filepath.Join(dir, ManifestJournalFile)); bytes.Contains(journal, []byte("DEMO-1.json")) {
+		t.Errorf("journal records the failed write: %s", journal)
+	}
+}
+
+func TestArchiveIssuesWritesZstdBundlesAndMergesGzipOnes(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewDirStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, resolved := range map[string]string{"DEMO-1": "2020-05-01T10:00:00.000+0000", "DEMO-2": ""} {
+		issue := map[string]interface{}{"key": key, "fields": map[string]interface{}{
+			"project":        map[string]interface{}{"key": "DEMO"},
+			"summary":        key,
+			"resolutiondate": resolved,
+		}}
+		if err := store.SaveIssue(key, issue, map[string]interface{}{"histories": []interface{}{}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// DEMO-9 was archived by a version that wrote gzip bundles.
+	if err := os.MkdirAll(filepath.Join(dir, ArchiveDir), 0755); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(zw)
+	legacy := []byte(`{"key": "DEMO-9", "fields": {"summary": "archived long ago"}}`)
+	tw.WriteHeader(&tar.Header{Name: "DEMO-9.json", Mode: 0644, Size: int64(len(legacy)), Typeflag: tar.TypeReg})
+	tw.Write(legacy)
+	tw.Close()
+	zw.Close()
+	if err := os.WriteFile(filepath.Join(dir, ArchiveDir, "DEMO-2020.tar.gz"), buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	index := &ArchiveIndex{Version: 1, Issues: map[string]ArchiveEntry{
+		"DEMO-9": {Bundle: "DEMO-2020.tar.gz", Resolved: "2020-01-01T00:00:00Z", Files: []string{"DEMO-9.json"}},
+	}}
+	if err := index.Save(dir); err != nil {
+		t.Fatal(err)
+	}
+	if issue, err := store.ReadIssue("DEMO-9"); err != nil || issue.Fields.Summary != "archived long ago" {
+		t.Fatalf("got %q, %v reading DEMO-9 from the gzip bundle", issue.Fields.Summary, err)
+	}
+
+	result, err := ArchiveIssues(store, ArchiveOptions{ResolvedBefore: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Issues != 1 || !slices.Equal(result.Bundles, []string{"DEMO-2020.tar.zst"}) {
+		t.Fatalf("archived %d issues into %v, want DEMO-1 into DEMO-2020.tar.zst", result.Issues, result.Bundles)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ArchiveDir, "DEMO-2020.tar.gz")); !os.IsNotExist(err) {
+		t.Errorf("gzip bundle left behind: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, ArchiveDir, "DEMO-2020.tar.zst"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(data, []byte{0x28, 0xb5, 0x2f, 0xfd}) {
+		t.Errorf("bundle starts % x, want the zstd magic", data[:4])
+	}
+	index, err = LoadArchiveIndex(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"DEMO-1", "DEMO-9"} {
+		if bundle := index.Issues[key].Bundle; bundle != "DEMO-2020.tar.zst" {
+			t.Errorf("%s indexed in %q, want DEMO-2020.tar.zst", key, bundle)
+		}
+	}
+
+	store, err = NewDirStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys := store.IssueKeys("DEMO"); !slices.Equal(keys, []string{"DEMO-2"}) {
+		t.Errorf("got hot keys %v, want [DEMO-2]", keys)
+	}
+	for key, want := range map[string]string{"DEMO-1": "DEMO-1", "DEMO-9": "archived long ago"} {
+		if issue, err := store.ReadIssue(key); err != nil || issue.Fields.Summary != want {
+			t.Errorf("got %q, %v reading archived %s, want %q", issue.Fields.Summary, err, key, want)
+		}
+	}
+	if changelog, err := store.ReadChangelog("DEMO-1"); err != nil || changelog.Histories == nil {
+		t.Errorf("got %+v, %v reading the archived changelog of DEMO-1", changelog, err)
+	}
+}
+
+func TestSyncProjectFetchesWorklogsOfIssuesWithLoggedTime(t *testing.T) {
+	logged := testsuite.NewIssue("DEMO-1", "first", "In Progress", base.Add(time.Hour))
+	logged.Fields["timespent"] = 7200
+	logged.Worklogs = []map[string]any{
+		{"id": "1", "author": map[string]any{"name": "alice"}, "started": base.Format(testsuite.TimeFormat), "timeSpentSeconds": 3600},
+		{"id": "2", "author": map[string]any{"name": "bob"}, "started": base.Format(testsuite.TimeFormat), "timeSpentSeconds": 3600},
+	}
+	j := testsuite.NewJira(t, logged, testsuite.NewIssue("DEMO-2", "second", "New", base.Add(2*time.Hour)))
+	store := testStore(t)
+	client := testClient(j)
+	result, err := SyncProject(context.Background(), client, store, SyncOptions{Project: "DEMO", Worklogs: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Worklogs != 1 {
+		t.Fatalf("refreshed the worklogs of %d issues, want 1", result.Worklogs)
+	}
+	worklogs, err := store.ReadWorklogs("DEMO-1")
+	if err != nil || len(worklogs.Worklogs) != 2 || worklogs.TotalHours() != 2 {
+		t.Fatalf("got %+v, %v; want two worklogs of an hour", worklogs, err)
+	}
+	if _, err := store.ReadWorklogs("DEMO-2"); !IsNotCached(err) {
+		t.Fatalf("got %v, want no worklogs for an issue without logged time", err)
+	}
+
+	// Issues not updated since are not asked for their worklogs again.
+	before := j.Count("/worklog")
+	if before != 1 {
+		t.Fatalf("made %d worklog requests, want 1", before)
+	}
+	if _, err := SyncProject(context.Background(), client, store, SyncOptions{Project: "DEMO", Worklogs: true, ForceUpdate: true}); err != nil {
+		t.Fatal(err)
+	}
+	if n := j.Count("/worklog") - before; n != 0 {
+		t.Fatalf("made %d worklog requests, want none", n)
+	}
+}
+
+func TestSyncProjectFetchesWatchersWhenTheWatchCountChanges(t *testing.T) {
+	watched := testsuite.NewIssue("DEMO-1", "first", "In Progress", base.Add(time.Hour))
+	watched.Watchers = []map[string]any{{"name": "alice"}}
+	j := testsuite.NewJira(t, watched, testsuite.NewIssue("DEMO-2", "second", "New", base.Add(2*time.Hour)))
+	store := testStore(t)
+	client := testClient(j)
+	result, err := SyncProject(context.Background(), client, store, SyncOptions{Project: "DEMO", Watchers: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Watchers != 1 {
+		t.Fatalf("refreshed the watchers of %d issues, want 1", result.Watchers)
+	}
+	watchers, err := store.ReadWatchers("DEMO-1")
+	if err != nil || len(watchers.Watchers) != 1 || watchers.Watchers[0].Name != "alice" {
+		t.Fatalf("got %+v, %v; want alice watching", watchers, err)
+	}
+	if _, err := store.ReadWatchers("DEMO-2"); !IsNotCached(err) {
+		t.Fatalf("got %v, want no watchers for an issue nobody watches", err)
+	}
+
+	// Refetching unchanged issues does not ask for their watchers again.
+	opts := SyncOptions{Project: "DEMO", Watchers: true, ForceUpdate: true}
+	if _, err := SyncProject(context.Background(), client, store, opts); err != nil {
+		t.Fatal(err)
+	}
+	if n := j.Count("/watchers"); n != 1 {
+		t.Fatalf("made %d watcher requests, want 1", n)
+	}
+
+	// Watching does not update the issue, but changes its watch count.
+	j.Watch("DEMO-1", map[string]any{"name": "bob"})
+	if _, err := SyncProject(context.Background(), client, store, opts); err != nil {
+		t.Fatal(err)
+	}
+	if watchers, err := store.ReadWatchers("DEMO-1"); err != nil || len(watchers.Watchers) != 2 {
+		t.Fatalf("got %+v, %v; want alice and bob watching", watchers, err)
+	}
+}
+
+func TestSearchQueriesQuoteTheirValues(t *testing.T) {
+	j := fakeProject(t)
+	if _, err := SyncProject(context.Background(), testClient(j), testStore(t), SyncOptions{Project: "DEMO", Reconcile: true}); err != nil {
+		t.Fatal(err)
+	}
+	var queries []string
+	for _, r := range j.Requests() {
+		if u, err := url.Parse(r); err == nil && u.Query().Get("jql") != "" {
+			queries = append(queries, u.Query().Get("jql"))
+		}
+	}
+	updated := regexp.MustCompile(`^project = "DEMO" AND updated >= "\d{4}-\d\d-\d\d \d\d:\d\d" ORDER BY updated DESC$`)
+	if !slices.Contains(queries, `project = "DEMO" ORDER BY created DESC`) ||
+		!slices.Contains(queries, `project = "DEMO" ORDER BY key DESC`) ||
+		!slices.ContainsFunc(queries, updated.MatchString) {
+		t.Errorf("got searches %q, want the project quoted in each", queries)
+	}
+
+	sprint := `Sprint 1" OR project = "SECRET`
+	got := JQL{}.Equals("project", "DEMO").Contains("Sprint", sprint).Raw("a = 1 OR b = 2").String()
+	if want := `project = "DEMO" AND Sprint ~ "Sprint 1\" OR project = \"SECRET" AND (a = 1 OR b = 2)`; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
+
+func TestHighestIssueKeyOfEmptyProject(t *testing.T) {
+	j := testsuite.NewJira(t)
+	_, err := testClient(j).HighestIssueKey(context.Background(), "DEMO")
+	if err == nil || err.Error() != "no issues found in project DEMO" {
+		t.Fatalf("got error %v, want no issues found", err)
+	}
+}
+
+// expiringToken is a bearer token that is replaced when invalidated.
+type expiringToken struct{ token string }
+
+func (e *expiringToken) Authorize(ctx context.Context, req *http.Request) error {
+	req.Header.Set("Authorization", "Bearer "+e.token)
+	return nil
+}
+
+func (e *expiringToken) Invalidate() { e.token = "fresh" }
+
+func TestGetReauthenticatesOnTheLastAttempt(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch {
+		case requests < 3:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case r.Header.Get("Authorization") != "Bearer fresh":
+			w.WriteHeader(http.StatusUnauthorized)
+		default:
+			w.Write([]byte(`{"ok": true}`))
+		}
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, "")
+	c.Limiter = nil
+	c.MinInterval = 0
+	c.Audit = nil
+	c.Auth = &expiringToken{token: "expired"}
+	c.Retry = RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond, Statuses: []int{503}}
+	body, err := c.Get(context.Background(), srv.URL+"/rest/api/2/myself")
+	if err != nil {
+		t.Fatalf("got %v after the token expired on the last attempt", err)
+	}
+	if string(body) != `{"ok": true}` || requests != 4 {
+		t.Errorf("got %s after %d requests, want the body after 4", body, requests)
+	}
+}
+
+func TestWebhookEmitterGivesUpOnEndpointsThatDoNotAnswer(t *testing.T) {
+	hang := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { <-hang }))
+	defer srv.Close()
+	defer close(hang)
+	defer func(d time.Duration) { DefaultWebhookTimeout = d }(DefaultWebhookTimeout)
+	DefaultWebhookTimeout = 50 * time.Millisecond
+
+	emitter := &WebhookEmitter{Endpoints: []string{srv.URL}}
+	done := make(chan error, 1)
+	go func() {
+		done <- emitter.Emit(context.Background(), []ChangeEvent{{Key: "DEMO-1", Type: EventStatusChanged}})
+	}()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("got no error from an endpoint that never answered")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Emit still waiting on an endpoint that never answers")
+	}
+}
+
+func TestCacheIndexKeepsConfiguredExtractorValues(t *testing.T) {
+	defer func(e Extractors) { IndexExtractors = e }(IndexExtractors)
+	extractors := DefaultExtractors()
+	extractors.Register(FieldExtractor{Name: "Team", Field: "customfield_900", Type: ExtractOption})
+	extractors.Register(FieldExtractor{Name: "RICE", Field: "customfield_901", Type: ExtractNumber})
+	SetIndexExtractors(extractors)
+
+	dir := t.TempDir()
+	store, err := OpenStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issue := map[string]interface{}{"key": "DEMO-1", "fields": map[string]interface{}{
+		"project":         map[string]interface{}{"key": "DEMO"},
+		"status":          map[string]interface{}{"name": "New"},
+		"customfield_900": map[string]interface{}{"value": "Platform"},
+		"customfield_901": 12.5,
+		"customfield_902": "S1",
+	}}
+	if err := store.SaveIssue("DEMO-1", issue, nil); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	x, err := UpdateCacheIndex(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := x.Issues["DEMO-1"]
+	if v, ok := entry.Value("team"); !ok || v.Text != "Platform" {
+		t.Errorf("Team = %+v, %v; want Platform", v, ok)
+	}
+	if v, ok := entry.Value("RICE"); !ok || !v.Numeric || v.Number != 12.5 {
+		t.Errorf("RICE = %+v, %v; want 12.5", v, ok)
+	}
+	if _, ok := entry.Value("summary"); ok {
+		t.Error("default extractors should not be indexed")
+	}
+
+	// A new extractor in the config rebuilds the index with its values.
+	extractors.Register(FieldExtractor{Name: "Severity", Field: "customfield_902"})
+	SetIndexExtractors(extractors)
+	if x, err = UpdateCacheIndex(dir, false); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := x.Issues["DEMO-1"].Value("Severity"); !ok || v.Text != "S1" {
+		t.Errorf("Severity = %+v, %v; want S1", v, ok)
+	}
+}
+
+func adfDoc(content ...any) map[string]any {
+	return map[string]any{"type": "doc", "version": 1, "content": content}
+}
+
+func adfParagraph(content ...any) map[string]any {
+	return map[string]any{"type": "paragraph", "content": content}
+}
+
+func adfText(text string, marks ...string) map[string]any {
+	node := map[string]any{"type": "text", "text": text}
+	if len(marks) > 0 {
+		var list []any
+		for _, m := range marks {
+			list = append(list, map[string]any{"type": m})
+		}
+		node["marks"] = list
+	}
+	return node
+}
+
+func TestSyncProjectConvertsADFFromJiraCloud(t *testing.T) {
+	issue := testsuite.NewIssue("DEMO-1", "first", "New", base.Add(time.Hour))
+	issue.Fields["description"] = adfDoc(
+		map[string]any{"type": "heading", "attrs": map[string]any{"level": 2}, "content": []any{adfText("Goal")}},
+		adfParagraph(adfText("Ship "), adfText("it", "strong")),
+		map[string]any{"type": "bulletList", "content": []any{
+			map[string]any{"type": "listItem", "content": []any{adfParagraph(adfText("one"))}},
+			map[string]any{"type": "listItem", "content": []any{adfParagraph(adfText("two", "code"))}},
+		}},
+	)
+	issue.Comments = []map[string]any{{"id": "1", "body": adfDoc(adfParagraph(adfText("looks "), adfText("good", "em"))), "created": base.Format(testsuite.TimeFormat)}}
+	j := testsuite.NewJira(t, issue, testsuite.NewIssue("DEMO-2", "second", "New", base.Add(2*time.Hour)))
+	j.Cloud = true
+	store := testStore(t)
+	client := testClient(j)
+	client.APIVersion = APIVersionAuto
+	if _, err := SyncProject(context.Background(), client, store, SyncOptions{Project: "DEMO", Comments: true}); err != nil {
+		t.Fatal(err)
+	}
+	if n := j.Count("/rest/api/2/"); n != 1 {
+		t.Fatalf("made %d API v2 requests, want only the serverInfo probe", n)
+	}
+
+	cached, err := store.ReadIssue("DEMO-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "## Goal\n\nShip **it**\n\n- one\n- `two`"
+	if cached.Fields.Description != want {
+		t.Fatalf("got description %q, want %q", cached.Fields.Description, want)
+	}
+	comments, err := store.ReadComments("DEMO-1")
+	if err != nil || len(comments.Comments) != 1 || comments.Comments[0].Body != "looks *good*" {
+		t.Fatalf("got %+v, %v; want the comment as markdown", comments, err)
+	}
+	if text := ADFToText(issue.Fields["description"], ADFText); text != "Goal\n\nShip it\n\n- one\n- two" {
+		t.Fatalf("got plain text %q", text)
+	}
+
+	// Searches page by nextPageToken.
+	var keys []string
+	err = client.Search(context.Background(), "project = DEMO", "key", 1, func(issues []json.RawMessage) (bool, error) {
+		for _, raw := range issues {
+			var issue struct{ Key string }
+			json.Unmarshal(raw, &issue)
+			keys = append(keys, issue.Key)
+		}
+		return true, nil
+	})
+	if err != nil || len(keys) != 2 || j.Count("nextPageToken=") == 0 {
+		t.Fatalf("got %v, %v after %d token requests; want both issues over two pages", keys, err, j.Count("nextPageToken="))
+	}
+}
+
+func TestTokenBucketPacesClientsSharingIt(t *testing.T) {
+	j := fakeProject(t)
+	bucket := NewTokenBucket(50, 2)
+	var clients []*Client
+	for i := 0; i < 2; i++ {
+		c := testClient(j)
+		c.Limiter = bucket
+		clients = append(clients, c)
+	}
+	start := time.Now()
+	for i := 0; i < 6; i++ {
+		if _, err := clients[i%2].HighestIssueKey(context.Background(), "DEMO"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Two requests go out in a burst, the other four 20ms apart.
+	if elapsed := time.Since(start); elapsed < 75*time.Millisecond {
+		t.Fatalf("six requests took %v, want about 80ms", elapsed)
+	}
+}
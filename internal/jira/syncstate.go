@@ -0,0 +1,145 @@
+package jira
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"time"
+)
+
+// SyncStateFile holds the per-project observations used to tune the
+// lookback window of incremental syncs.
+const SyncStateFile = "sync_state.json"
+
+const (
+	minLookback = 5 * time.Minute
+	maxLookback = 7 * 24 * time.Hour
+	// lagDecay shrinks the remembered lag on every sync that sees no
+	// misses, so one slow reindex does not widen the window forever.
+	lagDecay = 0.9
+)
+
+// SyncState records how far behind Jira's search index and clock were
+// observed to be for a project.
+type SyncState struct {
+	// IndexLag is the largest observed delay between an issue being updated
+	// and the updated-issues search returning it.
+	IndexLag time.Duration `json:"index_lag"`
+	// ClockSkew is the server clock minus the local clock.
+	ClockSkew time.Duration `json:"clock_skew"`
+	// Missed counts issues the updated-issues search failed to return.
+	Missed   int    `json:"missed"`
+	Syncs    int    `json:"syncs"`
+	LastSync string `json:"last_sync,omitempty"`
+
+	// LastAttempt and LastSuccess are the start times of the latest sync
+	// run and the latest one that completed without error.
+	LastAttempt string `json:"last_attempt,omitempty"`
+	LastSuccess string `json:"last_success,omitempty"`
+	// LastError is the error of the latest run, cleared on success.
+	LastError string `json:"last_error,omitempty"`
+	// ConsecutiveFailures counts failed runs since the last success.
+	ConsecutiveFailures int `json:"consecutive_failures,omitempty"`
+	// Checkpoint is where the latest run stopped when it was interrupted.
+	Checkpoint *SyncCheckpoint `json:"checkpoint,omitempty"`
+}
+
+// Lookback derives a safe overlap window: twice the observed index lag plus
+// clock skew, plus a minute because JQL dates have minute precision.
+func (s SyncState) Lookback() time.Duration {
+	skew := s.ClockSkew
+	if skew < 0 {
+		skew = -skew
+	}
+	window := 2*(s.IndexLag+skew) + time.Minute
+	if window < minLookback {
+		return minLookback
+	}
+	if window > maxLookback {
+		return maxLookback
+	}
+	return window
+}
+
+// Observe folds the measurements of one sync into the state.
+func (s *SyncState) Observe(lag time.Duration, missed int, skew time.Duration, skewKnown bool) {
+	decayed := time.Duration(float64(s.IndexLag) * lagDecay)
+	if lag > decayed {
+		s.IndexLag = lag
+	} else {
+		s.IndexLag = decayed
+	}
+	if skewKnown {
+		s.ClockSkew = skew
+	}
+	s.Missed += missed
+	s.Syncs++
+	s.LastSync = time.Now().UTC().Format(time.RFC3339)
+}
+
+// RecordRun notes the outcome of a sync run that started at start.
+func (s *SyncState) RecordRun(start time.Time, err error) {
+	s.LastAttempt = start.UTC().Format(time.RFC3339)
+	if err != nil {
+		s.LastError = err.Error()
+		s.ConsecutiveFailures++
+		return
+	}
+	s.LastSuccess = s.LastAttempt
+	s.LastError = ""
+	s.ConsecutiveFailures = 0
+}
+
+// RecordSyncRun updates the stored sync state of a project with the outcome
+// of a run.
+func RecordSyncRun(store Store, project string, start time.Time, runErr error) error {
+	state, err := store.ReadSyncState(project)
+	if err != nil {
+		return fmt.Errorf("read sync state: %w", err)
+	}
+	state.RecordRun(start, runErr)
+	if err := store.SaveSyncState(project, state); err != nil {
+		return fmt.Errorf("save sync state: %w", err)
+	}
+	return nil
+}
+
+func (s *DirStore) readSyncStates() (map[string]SyncState, error) {
+	states := map[string]SyncState{}
+	data, err := s.readFile(SyncStateFile)
+	if os.IsNotExist(err) {
+		return states, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &states); err != nil {
+		return nil, corruptEntry(SyncStateFile, err)
+	}
+	return states, nil
+}
+
+func (s *DirStore) ReadSyncState(project string) (SyncState, error) {
+	states, err := s.readSyncStates()
+	if err != nil {
+		return SyncState{}, err
+	}
+	return states[project], nil
+}
+
+func (s *DirStore) SaveSyncState(project string, state SyncState) error {
+	states, err := s.readSyncStates()
+	if err != nil {
+		return err
+	}
+	states[project] = state
+	data, err := s.marshal(states)
+	if err != nil {
+		return fmt.Errorf("marshal sync state: %w", err)
+	}
+	if err := s.writeFile(SyncStateFile, data); err != nil {
+		return fmt.Errorf("write %s: %w", path.Join(s.Dir, SyncStateFile), err)
+	}
+	return nil
+}
@@ -0,0 +1,295 @@
+package jira
+
+import (
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Taxonomy finding kinds.
+const (
+	FindingDuplicateLabel     = "duplicate-label"
+	FindingDuplicateComponent = "duplicate-component"
+	FindingSingleUseLabel     = "single-use-label"
+	FindingUnusedComponent    = "unused-component"
+	FindingStaleComponent     = "stale-component"
+)
+
+// TaxonomyFinding is one suggested clean up of labels or components.
+type TaxonomyFinding struct {
+	Kind    string
+	Project string
+	Name    string
+	Issues  int
+	// Suggestion is the value to merge Name into, if any.
+	Suggestion       string
+	SuggestionIssues int
+	Reason           string
+}
+
+// TaxonomyOptions tunes AuditTaxonomy.
+type TaxonomyOptions struct {
+	// MaxDistance is the largest edit distance between normalized names
+	// that still counts as a typo variant.
+	MaxDistance int
+	// MinLength is the shortest normalized name compared by edit distance;
+	// short names like "ui" and "qe" are too close to tell apart.
+	MinLength int
+	// StaleAfter flags components no cached issue has been updated in for
+	// this long; zero disables the check.
+	StaleAfter time.Duration
+	Now        time.Time
+	// Defined lists the components Jira defines per project so components
+	// without any cached issue can be reported.
+	Defined map[string][]string
+}
+
+// NormalizeTerm folds case and drops separators so "Model-Serving",
+// "model_serving" and "modelserving" compare equal.
+func NormalizeTerm(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// EditDistance is the Levenshtein distance between two strings.
+func EditDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+type termUse struct {
+	name    string
+	issues  int
+	updated time.Time
+}
+
+// similar explains why two names look like variants of each other, or
+// returns "" when they do not.
+func similar(a, b string, opts TaxonomyOptions) string {
+	na, nb := NormalizeTerm(a), NormalizeTerm(b)
+	if na == "" || nb == "" {
+		return ""
+	}
+	if na == nb {
+		if strings.EqualFold(a, b) {
+			return "casing variant"
+		}
+		return "separator variant"
+	}
+	if len(na) < opts.MinLength || len(nb) < opts.MinLength {
+		return ""
+	}
+	if d := EditDistance(na, nb); d <= opts.MaxDistance {
+		return "possible typo"
+	}
+	return ""
+}
+
+// mergeCandidates groups near-duplicate names and suggests merging every
+// variant into the most used one.
+func mergeCandidates(uses []termUse, kind, project string, opts TaxonomyOptions) []TaxonomyFinding {
+	parent := make([]int, len(uses))
+	reasons := make([]string, len(uses))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	for i := range uses {
+		for j := i + 1; j < len(uses); j++ {
+			reason := similar(uses[i].name, uses[j].name, opts)
+			if reason == "" {
+				continue
+			}
+			parent[find(j)] = find(i)
+			if reasons[i] == "" {
+				reasons[i] = reason
+			}
+			if reasons[j] == "" {
+				reasons[j] = reason
+			}
+		}
+	}
+
+	groups := map[int][]int{}
+	for i := range uses {
+		groups[find(i)] = append(groups[find(i)], i)
+	}
+	var findings []TaxonomyFinding
+	for _, members := range groups {
+		if len(members) < 2 {
+			continue
+		}
+		// The canonical name is the most used, then the shortest.
+		best := members[0]
+		for _, m := range members[1:] {
+			u, b := uses[m], uses[best]
+			if u.issues > b.issues || u.issues == b.issues && (len(u.name) < len(b.name) || len(u.name) == len(b.name) && u.name < b.name) {
+				best = m
+			}
+		}
+		for _, m := range members {
+			if m == best {
+				continue
+			}
+			findings = append(findings, TaxonomyFinding{
+				Kind:             kind,
+				Project:          project,
+				Name:             uses[m].name,
+				Issues:           uses[m].issues,
+				Suggestion:       uses[best].name,
+				SuggestionIssues: uses[best].issues,
+				Reason:           reasons[m],
+			})
+		}
+	}
+	return findings
+}
+
+func sortedUses(m map[string]*termUse) []termUse {
+	out := make([]termUse, 0, len(m))
+	for _, u := range m {
+		out = append(out, *u)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].name < out[j].name })
+	return out
+}
+
+// AuditTaxonomy looks for labels and components that need cleaning up:
+// near-duplicate names, labels used only once, and components that are
+// unused or no longer active. Labels are global in Jira while components
+// belong to a project, so only components are compared per project.
+func AuditTaxonomy(issues []JiraIssueWithSprints, opts TaxonomyOptions) []TaxonomyFinding {
+	labels := map[string]*termUse{}
+	components := map[string]map[string]*termUse{}
+	for _, issue := range issues {
+		updated, _ := issue.UpdatedTime()
+		for _, l := range issue.Fields.Labels {
+			u := labels[l]
+			if u == nil {
+				u = &termUse{name: l}
+				labels[l] = u
+			}
+			u.issues++
+		}
+		project := issue.Fields.Project.Key
+		for _, c := range issue.Fields.Components {
+			if components[project] == nil {
+				components[project] = map[string]*termUse{}
+			}
+			u := components[project][c.Name]
+			if u == nil {
+				u = &termUse{name: c.Name}
+				components[project][c.Name] = u
+			}
+			u.issues++
+			if updated.After(u.updated) {
+				u.updated = updated
+			}
+		}
+	}
+
+	labelUses := sortedUses(labels)
+	findings := mergeCandidates(labelUses, FindingDuplicateLabel, "", opts)
+	merged := map[string]bool{}
+	for _, f := range findings {
+		merged[f.Name] = true
+	}
+	for _, u := range labelUses {
+		if u.issues == 1 && !merged[u.name] {
+			findings = append(findings, TaxonomyFinding{Kind: FindingSingleUseLabel, Name: u.name, Issues: 1, Reason: "used by one issue"})
+		}
+	}
+
+	var projects []string
+	for p := range components {
+		projects = append(projects, p)
+	}
+	for p := range opts.Defined {
+		if components[p] == nil {
+			projects = append(projects, p)
+		}
+	}
+	sort.Strings(projects)
+	for _, project := range projects {
+		used := sortedUses(components[project])
+		// Unused components take part in the duplicate check so a stray
+		// variant is merged rather than just reported as unused.
+		all := map[string]*termUse{}
+		for name, u := range components[project] {
+			all[name] = u
+		}
+		for _, name := range opts.Defined[project] {
+			if all[name] == nil {
+				all[name] = &termUse{name: name}
+			}
+		}
+		duplicates := mergeCandidates(sortedUses(all), FindingDuplicateComponent, project, opts)
+		findings = append(findings, duplicates...)
+		merged := map[string]bool{}
+		for _, f := range duplicates {
+			merged[f.Name] = true
+		}
+		for _, name := range opts.Defined[project] {
+			if _, ok := components[project][name]; !ok && !merged[name] {
+				findings = append(findings, TaxonomyFinding{Kind: FindingUnusedComponent, Project: project, Name: name, Reason: "no cached issues"})
+			}
+		}
+		if opts.StaleAfter <= 0 {
+			continue
+		}
+		for _, u := range used {
+			if !u.updated.IsZero() && opts.Now.Sub(u.updated) > opts.StaleAfter {
+				findings = append(findings, TaxonomyFinding{
+					Kind:    FindingStaleComponent,
+					Project: project,
+					Name:    u.name,
+					Issues:  u.issues,
+					Reason:  "last issue update " + u.updated.Format("2006-01-02"),
+				})
+			}
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.Project != b.Project {
+			return a.Project < b.Project
+		}
+		if a.Suggestion != b.Suggestion {
+			return a.Suggestion < b.Suggestion
+		}
+		return a.Name < b.Name
+	})
+	return findings
+}
@@ -0,0 +1,161 @@
+package jira
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Issues deleted from Jira or moved to another project leave their files
+// behind in the cache, where they would keep counting in reports. A sync
+// that finds one gone tombstones it: its files move below tombstones/, out
+// of sight of the reports, and a {KEY}.tombstone marker records why, so the
+// number is not fetched again as missing.
+const TombstoneDir = "tombstones"
+
+// Tombstone reasons.
+const (
+	TombstoneDeleted = "deleted"
+	TombstoneMoved   = "moved"
+)
+
+// Tombstone records an issue that is no longer in Jira under its key.
+type Tombstone struct {
+	Key    string `json:"key"`
+	Reason string `json:"reason"`
+	// MovedTo is the key of a moved issue.
+	MovedTo string    `json:"movedTo,omitempty"`
+	Status  int       `json:"status,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+// MovedError is returned for an issue Jira answers with another key, as
+// it does for issues moved to another project.
+type MovedError struct {
+	Key    string
+	NewKey string
+}
+
+func (e *MovedError) Error() string {
+	return fmt.Sprintf("%s was moved to %s", e.Key, e.NewKey)
+}
+
+// checkMoved returns a MovedError when the raw issue Jira returned for key
+// has another key.
+func checkMoved(key string, issueData map[string]interface{}) error {
+	got, _ := issueData["key"].(string)
+	if got != "" && !strings.EqualFold(got, key) {
+		return &MovedError{Key: key, NewKey: got}
+	}
+	return nil
+}
+
+// TombstonedError is returned by a sync for an issue it tombstoned; Err is
+// what told it the issue was gone.
+type TombstonedError struct {
+	Tombstone Tombstone
+	Err       error
+}
+
+func (e *TombstonedError) Error() string {
+	return fmt.Sprintf("%s tombstoned as %s: %v", e.Tombstone.Key, e.Tombstone.Reason, e.Err)
+}
+
+func (e *TombstonedError) Unwrap() error { return e.Err }
+
+// tombstoneFor returns the tombstone of a cached issue whose fetch failed
+// with err, or false when err does not mean the issue is gone.
+func tombstoneFor(store Store, key string, err error) (Tombstone, bool) {
+	t := Tombstone{Key: key, Time: time.Now().UTC()}
+	var moved *MovedError
+	switch {
+	case errors.As(err, &moved):
+		t.Reason, t.MovedTo = TombstoneMoved, moved.NewKey
+		return t, true
+	case IsStatus(err, 404):
+		// Numbers never seen are left to fail as before; only cached
+		// issues have files to put away.
+		if _, ok := store.IssueUpdated(key); !ok {
+			return t, false
+		}
+		t.Reason, t.Status = TombstoneDeleted, 404
+		return t, true
+	}
+	return t, false
+}
+
+func tombstoneFiles(key string) []string {
+	var names []string
+	for _, name := range []string{key + ".json", key + ".changelog.json", key + ".comments.json", key + ".worklogs.json", key + ".watchers.json"} {
+		names = append(names, cacheFileForms(name)...)
+	}
+	return names
+}
+
+func (s *DirStore) Tombstone(key string, t Tombstone) error {
+	if err := s.Flush(); err != nil {
+		return err
+	}
+	dir := filepath.Join(s.Dir, TombstoneDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for _, name := range tombstoneFiles(key) {
+		if err := os.Rename(filepath.Join(s.Dir, name), filepath.Join(dir, name)); err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+		if err := appendManifestRemoval(s.Dir, name); err != nil {
+			return err
+		}
+	}
+	data, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	return s.writeFile(key+".tombstone", data)
+}
+
+func (s *DirStore) Tombstones(project string) []Tombstone {
+	var tombstones []Tombstone
+	prefix := ""
+	if project != "" {
+		prefix = strings.ToUpper(project) + "-"
+	}
+	entries, _ := os.ReadDir(s.Dir)
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ".tombstone") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(s.Dir, name))
+		if err != nil {
+			continue
+		}
+		var t Tombstone
+		if err := json.Unmarshal(data, &t); err != nil {
+			continue
+		}
+		tombstones = append(tombstones, t)
+	}
+	sortTombstones(tombstones)
+	return tombstones
+}
+
+func sortTombstones(tombstones []Tombstone) {
+	sort.Slice(tombstones, func(i, j int) bool {
+		pi, ni := splitKey(tombstones[i].Key)
+		pj, nj := splitKey(tombstones[j].Key)
+		if pi != pj {
+			return pi < pj
+		}
+		return ni < nj
+	})
+}
@@ -0,0 +1,211 @@
+package jira
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimiter paces the requests of a Client. Wait blocks until the next
+// request may be sent.
+type RateLimiter interface {
+	Wait(ctx context.Context) error
+}
+
+// IntervalLimiter spaces requests at least Interval apart.
+type IntervalLimiter struct {
+	Interval time.Duration
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+func (l *IntervalLimiter) Wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+	at := l.next
+	if at.Before(now) {
+		at = now
+	}
+	l.next = at.Add(l.Interval)
+	l.mu.Unlock()
+	return sleepContext(ctx, at.Sub(now))
+}
+
+// TokenBucket lets requests through at Rate a second on average, allowing
+// bursts of up to Burst requests after a quiet spell. Unlike an
+// IntervalLimiter it suits being shared: every client of a process paces
+// itself against DefaultLimiter, so concurrent syncs and worker pools
+// together stay under the rate instead of each running at it.
+type TokenBucket struct {
+	// Rate is the requests a second; zero or less lets every request
+	// through.
+	Rate float64
+	// Burst is the most requests sent back to back; at least one.
+	Burst int
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// NewTokenBucket returns a full bucket.
+func NewTokenBucket(rate float64, burst int) *TokenBucket {
+	return &TokenBucket{Rate: rate, Burst: burst}
+}
+
+// Wait takes a token, sleeping until one is available. Callers queue in
+// the order they call, each reserving the next token, and a caller whose
+// context ends hands its token back.
+func (b *TokenBucket) Wait(ctx context.Context) error {
+	if b.Rate <= 0 {
+		return ctx.Err()
+	}
+	b.mu.Lock()
+	now := time.Now()
+	burst := float64(max(b.Burst, 1))
+	if b.last.IsZero() {
+		b.tokens = burst
+	} else {
+		b.tokens = min(burst, b.tokens+now.Sub(b.last).Seconds()*b.Rate)
+	}
+	b.last = now
+	b.tokens--
+	var wait time.Duration
+	if b.tokens < 0 {
+		wait = time.Duration(-b.tokens / b.Rate * float64(time.Second))
+	}
+	b.mu.Unlock()
+	if err := sleepContext(ctx, wait); err != nil {
+		b.mu.Lock()
+		b.tokens++
+		b.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// DefaultRate and DefaultBurst are the pace of DefaultLimiter unless the
+// config file sets another: two requests a second without bursts, as
+// requests were spaced 500ms apart before.
+const (
+	DefaultRate  = 2.0
+	DefaultBurst = 1
+)
+
+// DefaultLimiter is the Limiter of new clients, shared by all of them.
+var DefaultLimiter RateLimiter = NewTokenBucket(DefaultRate, DefaultBurst)
+
+// RetryPolicy decides which failed requests are retried and how long to
+// wait before the next attempt.
+type RetryPolicy struct {
+	// MaxAttempts is the most attempts made at one request.
+	MaxAttempts int
+	// BaseDelay is the wait after the first failed attempt; it doubles
+	// with every further attempt up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// Statuses are the response codes worth retrying.
+	Statuses []int
+}
+
+// DefaultRetryPolicy is the RetryPolicy of new clients: rate limiting and
+// the gateway errors Jira answers with while it is restarting.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 5,
+	BaseDelay:   time.Second,
+	MaxDelay:    time.Minute,
+	Statuses:    []int{429, 502, 503, 504},
+}
+
+// Retries reports whether a response with the given status is retried.
+func (p RetryPolicy) Retries(status int) bool {
+	for _, s := range p.Statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// Delay returns the wait before the attempt following a failed one,
+// honouring a Retry-After header given in seconds.
+func (p RetryPolicy) Delay(attempt int, header http.Header) time.Duration {
+	if header != nil {
+		if secs, err := strconv.Atoi(header.Get("Retry-After")); err == nil && secs >= 0 {
+			d := time.Duration(secs) * time.Second
+			if p.MaxDelay > 0 && d > p.MaxDelay {
+				d = p.MaxDelay
+			}
+			return d
+		}
+	}
+	d := p.BaseDelay
+	for i := 1; i < attempt && (p.MaxDelay <= 0 || d < p.MaxDelay); i++ {
+		d *= 2
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	return d
+}
+
+func (p RetryPolicy) attempts() int {
+	if p.MaxAttempts < 1 {
+		return 1
+	}
+	return p.MaxAttempts
+}
+
+// TransportOptions configure the HTTP client used to reach Jira.
+type TransportOptions struct {
+	// Proxy is the URL of an HTTP proxy; empty uses the environment
+	// (HTTPS_PROXY and friends).
+	Proxy string
+	// CAFile is a PEM bundle trusted in addition to the system roots.
+	CAFile string
+	// InsecureSkipVerify turns off TLS certificate verification.
+	InsecureSkipVerify bool
+}
+
+// NewHTTPClient builds an http.Client for the given options.
+func NewHTTPClient(opts TransportOptions) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if opts.Proxy != "" {
+		proxy, err := url.Parse(opts.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy %q: %w", opts.Proxy, err)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+	if opts.CAFile != "" || opts.InsecureSkipVerify {
+		tlsConfig := &tls.Config{InsecureSkipVerify: opts.InsecureSkipVerify}
+		if opts.CAFile != "" {
+			pem, err := os.ReadFile(opts.CAFile)
+			if err != nil {
+				return nil, fmt.Errorf("read CA file: %w", err)
+			}
+			pool, err := x509.SystemCertPool()
+			if err != nil {
+				pool = x509.NewCertPool()
+			}
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no certificates found in %s", opts.CAFile)
+			}
+			tlsConfig.RootCAs = pool
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+	return &http.Client{Transport: transport}, nil
+}
+
+// DefaultHTTPClient is the HTTPClient of new clients and of the OAuth token
+// refresh.
+var DefaultHTTPClient = http.DefaultClient
@@ -0,0 +1,108 @@
+package jira
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Cache problem kinds reported by VerifyCache.
+const (
+	CacheEmpty   = "empty"
+	CacheCorrupt = "corrupt"
+	CacheTemp    = "temp-file"
+)
+
+// CacheProblem is a damaged file in a directory cache.
+type CacheProblem struct {
+	Name string
+	// Key is the issue the file belongs to; empty for temporary files.
+	Key    string
+	Kind   string
+	Detail string
+}
+
+// checkCacheFile reads one file of an issue and reports why it is
+// unusable, or nil when it parses.
+func checkCacheFile(dir, name string) *CacheProblem {
+	p := &CacheProblem{Name: name, Key: issueFileKey(name)}
+	data, err := ReadCacheFile(filepath.Join(dir, name))
+	switch {
+	case err != nil:
+		p.Kind, p.Detail = CacheCorrupt, err.Error()
+	case len(strings.TrimSpace(string(data))) == 0:
+		p.Kind = CacheEmpty
+	case !json.Valid(data):
+		p.Kind, p.Detail = CacheCorrupt, "not valid JSON"
+	default:
+		return nil
+	}
+	return p
+}
+
+// VerifyCache reads the files of every issue in a directory cache, using a
+// pool of workers, and reports those that are empty, truncated or
+// otherwise do not parse, and temporary files left by interrupted writes.
+// Problems are sorted by file name.
+func VerifyCache(dir string, workers int) ([]CacheProblem, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read dir: %w", err)
+	}
+	var problems []CacheProblem
+	var names []string
+	for _, entry := range entries {
+		name := entry.Name()
+		switch {
+		case entry.IsDir():
+		case strings.HasPrefix(name, TempPrefix):
+			problems = append(problems, CacheProblem{Name: name, Kind: CacheTemp})
+		case IsCompressible(name):
+			names = append(names, name)
+		}
+	}
+
+	if workers < 1 {
+		workers = 1
+	}
+	jobs := make(chan string)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range jobs {
+				if p := checkCacheFile(dir, name); p != nil {
+					mu.Lock()
+					problems = append(problems, *p)
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for _, name := range names {
+		jobs <- name
+	}
+	close(jobs)
+	wg.Wait()
+
+	sort.Slice(problems, func(i, j int) bool { return problems[i].Name < problems[j].Name })
+	return problems, nil
+}
+
+// RemoveCacheFile deletes a damaged cache file, so readers treat it as
+// missing, and drops it from the manifest.
+func RemoveCacheFile(dir, name string) error {
+	if err := os.Remove(filepath.Join(dir, name)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if strings.HasPrefix(name, TempPrefix) {
+		return nil
+	}
+	return appendManifestRemoval(dir, name)
+}
@@ -0,0 +1,151 @@
+package jira
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// VersionsFile holds the versions of projects saved by "releases fetch".
+const VersionsFile = "versions.json"
+
+// StartTime parses the start date of a version; false when it has none.
+func (v Version) StartTime() (time.Time, bool) {
+	return parseVersionDate(v.StartDate)
+}
+
+// ReleaseTime parses the release date of a version, the end of that day;
+// false when it has none.
+func (v Version) ReleaseTime() (time.Time, bool) {
+	t, ok := parseVersionDate(v.ReleaseDate)
+	if !ok {
+		return t, false
+	}
+	return t.Add(24*time.Hour - time.Nanosecond), true
+}
+
+// Version dates are days, without a time zone.
+func parseVersionDate(s string) (time.Time, bool) {
+	t, err := time.ParseInLocation("2006-01-02", s, time.Local)
+	return t, err == nil
+}
+
+// ProjectVersions is the list of versions of one project.
+type ProjectVersions struct {
+	Project   string    `json:"project"`
+	Versions  []Version `json:"versions"`
+	FetchedAt string    `json:"fetchedAt"`
+}
+
+// Version returns the version of the project with a name, compared
+// case-insensitively.
+func (p ProjectVersions) Version(name string) (Version, bool) {
+	for _, v := range p.Versions {
+		if strings.EqualFold(v.Name, name) {
+			return v, true
+		}
+	}
+	return Version{}, false
+}
+
+// VersionMap is the set of project versions saved in a cache.
+type VersionMap struct {
+	Projects []ProjectVersions `json:"projects"`
+}
+
+// Project returns the saved versions of a project.
+func (m VersionMap) Project(key string) (ProjectVersions, bool) {
+	for _, p := range m.Projects {
+		if strings.EqualFold(p.Project, key) {
+			return p, true
+		}
+	}
+	return ProjectVersions{}, false
+}
+
+// Put adds the versions of a project, replacing those saved earlier.
+func (m *VersionMap) Put(p ProjectVersions) {
+	for i := range m.Projects {
+		if strings.EqualFold(m.Projects[i].Project, p.Project) {
+			m.Projects[i] = p
+			return
+		}
+	}
+	m.Projects = append(m.Projects, p)
+	sort.Slice(m.Projects, func(i, j int) bool { return m.Projects[i].Project < m.Projects[j].Project })
+}
+
+// FetchVersions lists the versions of a project, released, unreleased and
+// archived, in the order the project sorts them. The endpoint is not
+// paginated.
+func (c *Client) FetchVersions(ctx context.Context, project string) (ProjectVersions, error) {
+	p := ProjectVersions{Project: project}
+	body, err := c.Get(ctx, c.apiURL(ctx, "/project/%s/versions", project))
+	if err != nil {
+		return p, fmt.Errorf("fetch versions of %s: %w", project, err)
+	}
+	if err := json.Unmarshal(body, &p.Versions); err != nil {
+		return p, fmt.Errorf("parse versions of %s: %w", project, err)
+	}
+	p.FetchedAt = time.Now().UTC().Format(time.RFC3339)
+	return p, nil
+}
+
+func (s *DirStore) ReadVersions() (VersionMap, error) {
+	var m VersionMap
+	data, err := s.readFile(VersionsFile)
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return m, err
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return m, corruptEntry(VersionsFile, err)
+	}
+	return m, nil
+}
+
+func (s *DirStore) SaveVersions(m VersionMap) error {
+	data, err := s.marshal(m)
+	if err != nil {
+		return fmt.Errorf("marshal versions: %w", err)
+	}
+	if err := s.writeFile(VersionsFile, data); err != nil {
+		return fmt.Errorf("write %s: %w", path.Join(s.Dir, VersionsFile), err)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) ReadVersions() (VersionMap, error) {
+	var m VersionMap
+	var data []byte
+	err := s.db.QueryRow(`SELECT data FROM metadata WHERE name = 'versions'`).Scan(&data)
+	if err == sql.ErrNoRows {
+		return m, nil
+	}
+	if err != nil {
+		return m, fmt.Errorf("read versions: %w", err)
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return m, corruptEntry("versions", err)
+	}
+	return m, nil
+}
+
+func (s *SQLiteStore) SaveVersions(m VersionMap) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("marshal versions: %w", err)
+	}
+	if _, err := s.db.Exec(`INSERT OR REPLACE INTO metadata (name, data) VALUES ('versions', ?)`, data); err != nil {
+		return fmt.Errorf("write versions: %w", err)
+	}
+	return nil
+}
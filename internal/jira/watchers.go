@@ -0,0 +1,87 @@
+package jira
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// WatcherList is the content of {KEY}.watchers.json. Fetched records when
+// the watchers were last refreshed from Jira.
+type WatcherList struct {
+	WatchCount int    `json:"watchCount"`
+	Watchers   []User `json:"watchers"`
+	Fetched    string `json:"fetched,omitempty"`
+}
+
+// FetchedTime parses the refresh stamp of a cached watcher list.
+func (l WatcherList) FetchedTime() (time.Time, bool) {
+	t, err := time.Parse(time.RFC3339, l.Fetched)
+	return t, err == nil
+}
+
+// WatchCount returns the number of watchers Jira reported with the issue,
+// from its watches field.
+func (i JiraIssueWithSprints) WatchCount() (int, bool) {
+	raw, ok := i.Fields.Raw["watches"]
+	if !ok {
+		return 0, false
+	}
+	var watches struct {
+		WatchCount *int `json:"watchCount"`
+	}
+	if err := json.Unmarshal(raw, &watches); err != nil || watches.WatchCount == nil {
+		return 0, false
+	}
+	return *watches.WatchCount, true
+}
+
+// FetchWatchers asks Jira who watches an issue. The endpoint is not
+// paginated.
+func (c *Client) FetchWatchers(ctx context.Context, issueKey string) (WatcherList, error) {
+	var list WatcherList
+	reqURL := c.apiURL(ctx, "/issue/%s/watchers", issueKey)
+	body, err := c.Get(ctx, reqURL)
+	if err != nil {
+		return list, fmt.Errorf("fetch watchers failed: %w", err)
+	}
+	if err := c.decode(ctx, body, &list); err != nil {
+		return list, fmt.Errorf("parse watchers: %w", err)
+	}
+	return list, nil
+}
+
+// SyncWatchers refreshes the cached watchers of an issue. Starting or
+// stopping to watch does not update the issue, but does change the watch
+// count it is fetched with, so watchers are only refetched when the issue
+// was updated after the last refresh or its watch count no longer matches
+// the cached list, and not at all for issues nobody watches that have none
+// cached. It returns whether the cache was written.
+func (c *Client) SyncWatchers(ctx context.Context, store Store, key string) (bool, error) {
+	issue, err := store.ReadIssue(key)
+	if err != nil {
+		return false, err
+	}
+	count, counted := issue.WatchCount()
+	cached, cacheErr := store.ReadWatchers(key)
+	if cacheErr != nil && counted && count == 0 {
+		return false, nil
+	}
+	if cacheErr == nil && (!counted || count == len(cached.Watchers)) {
+		fetched, ok := cached.FetchedTime()
+		if issueUpdated, err := issue.UpdatedTime(); err == nil && ok && !issueUpdated.After(fetched) {
+			return false, nil
+		}
+	}
+
+	watchers, err := c.FetchWatchers(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	watchers.Fetched = time.Now().UTC().Format(time.RFC3339)
+	if err := store.SaveWatchers(key, watchers); err != nil {
+		return false, err
+	}
+	return true, nil
+}
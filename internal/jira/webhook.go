@@ -0,0 +1,170 @@
+package jira
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Change event types emitted during sync.
+const (
+	EventIssueCreated  = "issue_created"
+	EventIssueUpdated  = "issue_updated"
+	EventStatusChanged = "status_changed"
+	EventSprintChanged = "sprint_changed"
+	// EventStatusRegressed accompanies a status_changed event moving an
+	// issue back to an earlier status category, such as a reopened bug.
+	EventStatusRegressed = "status_regressed"
+	// EventBlockerAdded is emitted for every issue newly linked as
+	// blocking the issue; To holds its key.
+	EventBlockerAdded = "blocker_added"
+)
+
+// ChangeEvent is a normalized change detected between two fetches of an issue.
+type ChangeEvent struct {
+	Type     string    `json:"type"`
+	Key      string    `json:"key"`
+	Project  string    `json:"project"`
+	Summary  string    `json:"summary"`
+	From     string    `json:"from,omitempty"`
+	To       string    `json:"to,omitempty"`
+	Updated  string    `json:"updated,omitempty"`
+	Detected time.Time `json:"detected"`
+}
+
+func sprintNames(issue JiraIssueWithSprints) string {
+	var names []string
+	for _, s := range issue.Fields.Sprints {
+		names = append(names, s.Name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+// DiffIssues compares the previously cached issue (nil when new) with the
+// freshly fetched one and returns the change events between them.
+func DiffIssues(prev *JiraIssueWithSprints, next JiraIssueWithSprints) []ChangeEvent {
+	now := time.Now().UTC()
+	base := ChangeEvent{
+		Key:      next.Key,
+		Project:  next.Fields.Project.Key,
+		Summary:  next.Fields.Summary,
+		Updated:  next.Fields.Updated,
+		Detected: now,
+	}
+
+	if prev == nil {
+		e := base
+		e.Type = EventIssueCreated
+		return []ChangeEvent{e}
+	}
+	if prev.Fields.Updated == next.Fields.Updated {
+		return nil
+	}
+
+	var events []ChangeEvent
+	e := base
+	e.Type = EventIssueUpdated
+	events = append(events, e)
+
+	if prev.Fields.Status.Name != next.Fields.Status.Name {
+		e := base
+		e.Type = EventStatusChanged
+		e.From = prev.Fields.Status.Name
+		e.To = next.Fields.Status.Name
+		events = append(events, e)
+		if next.Fields.Status.Rank() < prev.Fields.Status.Rank() {
+			e.Type = EventStatusRegressed
+			events = append(events, e)
+		}
+	}
+	if from, to := sprintNames(*prev), sprintNames(next); from != to {
+		e := base
+		e.Type = EventSprintChanged
+		e.From = from
+		e.To = to
+		events = append(events, e)
+	}
+	known := map[string]bool{}
+	for _, b := range prev.Blockers() {
+		known[b.Key] = true
+	}
+	for _, b := range next.Blockers() {
+		if !known[b.Key] {
+			known[b.Key] = true
+			e := base
+			e.Type = EventBlockerAdded
+			e.To = b.Key
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+// DefaultWebhookTimeout bounds each request of emitters without an
+// HTTPClient, so an endpoint that never answers cannot stall a sync.
+var DefaultWebhookTimeout = 30 * time.Second
+
+// WebhookEmitter POSTs change events as JSON to a set of endpoints. When a
+// secret is set every request carries an HMAC-SHA256 signature of the body.
+type WebhookEmitter struct {
+	Endpoints []string
+	Secret    string
+	// HTTPClient sends the requests; nil uses one that gives up after
+	// DefaultWebhookTimeout.
+	HTTPClient *http.Client
+}
+
+func (w *WebhookEmitter) Emit(ctx context.Context, events []ChangeEvent) error {
+	if len(events) == 0 || len(w.Endpoints) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(struct {
+		Events []ChangeEvent `json:"events"`
+	}{Events: events})
+	if err != nil {
+		return fmt.Errorf("marshal events: %w", err)
+	}
+
+	httpClient := w.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: DefaultWebhookTimeout}
+	}
+
+	var errs []error
+	for _, endpoint := range w.Endpoints {
+		req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(body))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", endpoint, err))
+			continue
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if w.Secret != "" {
+			mac := hmac.New(sha256.New, []byte(w.Secret))
+			mac.Write(body)
+			req.Header.Set("X-Rhoai-Jira-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+		}
+
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", endpoint, err))
+			continue
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			errs = append(errs, fmt.Errorf("%s: unexpected status %d", endpoint, resp.StatusCode))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,75 @@
+package jira
+
+import "time"
+
+// AssigneeTransition is a change of assignee. Unlike a Handoff it includes
+// assigning an unassigned issue and unassigning one.
+type AssigneeTransition struct {
+	From string
+	To   string
+	At   time.Time
+}
+
+// Reassignment reports whether the issue went from one person to another.
+func (t AssigneeTransition) Reassignment() bool {
+	return t.From != "" && t.To != ""
+}
+
+// AssigneeTransitions lists the assignee changes of an issue oldest first,
+// leaving out those that kept the same assignee.
+func AssigneeTransitions(changelog Changelog) []AssigneeTransition {
+	var transitions []AssigneeTransition
+	for _, h := range sortedHistories(changelog) {
+		for _, item := range h.entry.Items {
+			if item.Field != "assignee" {
+				continue
+			}
+			t := AssigneeTransition{
+				From: changelogUser(item.From, item.FromString),
+				To:   changelogUser(item.To, item.ToString),
+				At:   h.at,
+			}
+			if t.From != t.To {
+				transitions = append(transitions, t)
+			}
+		}
+	}
+	return transitions
+}
+
+// IssueLoad is what an issue adds to the workload of its assignee at one
+// time.
+type IssueLoad struct {
+	Assignee string
+	Open     bool
+	Effort   float64
+}
+
+// LoadAt replays the changelog of an issue to find who it was assigned to
+// at time at, whether it was still open and its effort then. It returns
+// false when the issue did not exist yet.
+func LoadAt(issue JiraIssueWithSprints, changelog Changelog, effort EffortSource, at time.Time) (IssueLoad, bool) {
+	if created, err := issue.CreatedTime(); err != nil || created.After(at) {
+		return IssueLoad{}, false
+	}
+	load := IssueLoad{Assignee: issue.AssigneeID(), Effort: effort.EffortAt(issue, changelog, at)}
+	if value, ok := ValueAt(changelog, "assignee", at); ok {
+		load.Assignee = value
+	}
+
+	done := false
+	if value, ok := ValueAt(changelog, "resolution", at); ok {
+		done = value != ""
+	} else if resolved, err := issue.ResolvedTime(); err == nil {
+		done = !resolved.After(at)
+	}
+	if !done {
+		if value, ok := ValueAt(changelog, "status", at); ok {
+			done = Status{Name: value}.IsDone()
+		} else {
+			done = issue.IsDone()
+		}
+	}
+	load.Open = !done
+	return load, true
+}
@@ -0,0 +1,128 @@
+package jira
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+type Worklog struct {
+	ID               string `json:"id"`
+	Author           User   `json:"author"`
+	Comment          string `json:"comment"`
+	Started          string `json:"started"`
+	Created          string `json:"created"`
+	Updated          string `json:"updated"`
+	TimeSpentSeconds int    `json:"timeSpentSeconds"`
+}
+
+// WorklogList is the content of {KEY}.worklogs.json. Fetched records when
+// the worklogs were last refreshed from Jira.
+type WorklogList struct {
+	StartAt    int       `json:"startAt"`
+	MaxResults int       `json:"maxResults"`
+	Total      int       `json:"total"`
+	Worklogs   []Worklog `json:"worklogs"`
+	Fetched    string    `json:"fetched,omitempty"`
+}
+
+// StartedTime parses the time the work was started, falling back to created.
+func (w Worklog) StartedTime() (time.Time, error) {
+	if w.Started != "" {
+		return time.Parse("2006-01-02T15:04:05.000-0700", w.Started)
+	}
+	return time.Parse("2006-01-02T15:04:05.000-0700", w.Created)
+}
+
+// TotalHours sums the logged time of all worklogs.
+func (l WorklogList) TotalHours() float64 {
+	seconds := 0
+	for _, w := range l.Worklogs {
+		seconds += w.TimeSpentSeconds
+	}
+	return float64(seconds) / 3600
+}
+
+// FetchWorklogs pages through every worklog of an issue.
+func (c *Client) FetchWorklogs(ctx context.Context, issueKey string) (WorklogList, error) {
+	var all WorklogList
+	startAt := 0
+	pageSize := 100
+
+	for {
+		reqURL := c.apiURL(ctx, "/issue/%s/worklog?startAt=%d&maxResults=%d", issueKey, startAt, pageSize)
+		body, err := c.Get(ctx, reqURL)
+		if err != nil {
+			return all, fmt.Errorf("fetch worklogs failed: %w", err)
+		}
+
+		var page WorklogList
+		if err := c.decode(ctx, body, &page); err != nil {
+			return all, fmt.Errorf("parse worklogs: %w", err)
+		}
+		all.Worklogs = append(all.Worklogs, page.Worklogs...)
+
+		startAt += len(page.Worklogs)
+		if startAt >= page.Total || len(page.Worklogs) == 0 {
+			break
+		}
+	}
+
+	all.MaxResults = len(all.Worklogs)
+	all.Total = len(all.Worklogs)
+	return all, nil
+}
+
+// SyncWorklogs refreshes the cached worklogs of an issue. Logging work
+// updates the issue, so worklogs are only refetched when the issue was
+// updated after the last refresh, and not at all for issues without
+// logged time that have none cached. It returns whether the cache was
+// written.
+func (c *Client) SyncWorklogs(ctx context.Context, store Store, key string) (bool, error) {
+	issue, err := store.ReadIssue(key)
+	if err != nil {
+		return false, err
+	}
+	cached, cacheErr := store.ReadWorklogs(key)
+	if cacheErr != nil && (issue.Fields.TimeSpent == nil || *issue.Fields.TimeSpent == 0) {
+		return false, nil
+	}
+	if cacheErr == nil {
+		fetched, ok := cached.FetchedTime()
+		if issueUpdated, err := issue.UpdatedTime(); err == nil && ok && !issueUpdated.After(fetched) {
+			return false, nil
+		}
+	}
+
+	worklogs, err := c.FetchWorklogs(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	worklogs.Fetched = time.Now().UTC().Format(time.RFC3339)
+	if err := store.SaveWorklogs(key, worklogs); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// FetchedTime parses the refresh stamp of a cached worklog list.
+func (l WorklogList) FetchedTime() (time.Time, bool) {
+	t, err := time.Parse(time.RFC3339, l.Fetched)
+	return t, err == nil
+}
+
+func GetIssueWorklogsFromCache(dir string, key string) (WorklogList, error) {
+	var worklogs WorklogList
+	worklogPath := dir + "/" + key + ".worklogs.json"
+	data, err := ReadCacheFile(worklogPath)
+	if err != nil {
+		return worklogs, err
+	}
+
+	if err := json.Unmarshal(data, &worklogs); err != nil {
+		return worklogs, err
+	}
+
+	return worklogs, nil
+}
@@ -0,0 +1,186 @@
+package jira
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+type writeRequest struct {
+	name  string
+	data  []byte
+	seq   uint64
+	flush chan error
+}
+
+// BatchWriter queues cache writes and applies them in batches from a single
+// goroutine. The bounded queue blocks submitters when the disk falls behind,
+// and manifest journal lines are appended, and the journal and directory
+// synced, once per batch instead of per file.
+type BatchWriter struct {
+	dir       string
+	batchSize int
+	queue     chan writeRequest
+
+	mu      sync.Mutex
+	pending map[string]writeRequest
+	seq     uint64
+	err     error
+
+	done chan struct{}
+}
+
+func NewBatchWriter(dir string, batchSize int) *BatchWriter {
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	w := &BatchWriter{
+		dir:       dir,
+		batchSize: batchSize,
+		queue:     make(chan writeRequest, batchSize*2),
+		pending:   make(map[string]writeRequest),
+		done:      make(chan struct{}),
+	}
+	go w.loop()
+	return w
+}
+
+// Write queues a file for writing, blocking while the queue is full.
+func (w *BatchWriter) Write(name string, data []byte) error {
+	w.mu.Lock()
+	if w.err != nil {
+		err := w.err
+		w.mu.Unlock()
+		return err
+	}
+	w.seq++
+	req := writeRequest{name: name, data: data, seq: w.seq}
+	w.pending[name] = req
+	w.mu.Unlock()
+
+	w.queue <- req
+	return nil
+}
+
+// Pending returns data queued for a file that has not reached disk yet.
+func (w *BatchWriter) Pending(name string) ([]byte, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	req, ok := w.pending[name]
+	return req.data, ok
+}
+
+// Flush blocks until every queued write is on disk and returns the first
+// write error encountered so far.
+func (w *BatchWriter) Flush() error {
+	ch := make(chan error, 1)
+	w.queue <- writeRequest{flush: ch}
+	return <-ch
+}
+
+// Close flushes and stops the writer.
+func (w *BatchWriter) Close() error {
+	err := w.Flush()
+	close(w.queue)
+	<-w.done
+	return err
+}
+
+func (w *BatchWriter) loop() {
+	defer close(w.done)
+	var batch []writeRequest
+	for req := range w.queue {
+		if req.flush != nil {
+			w.writeBatch(batch)
+			batch = batch[:0]
+			w.mu.Lock()
+			req.flush <- w.err
+			w.mu.Unlock()
+			continue
+		}
+		batch = append(batch, req)
+		if len(batch) >= w.batchSize || len(w.queue) == 0 {
+			w.writeBatch(batch)
+			batch = batch[:0]
+		}
+	}
+	w.writeBatch(batch)
+}
+
+func (w *BatchWriter) setErr(err error) {
+	w.mu.Lock()
+	if w.err == nil {
+		w.err = err
+	}
+	w.mu.Unlock()
+}
+
+func (w *BatchWriter) writeBatch(batch []writeRequest) {
+	if len(batch) == 0 {
+		return
+	}
+
+	var journal []byte
+	var written []writeRequest
+	for _, req := range batch {
+		if err := writeFileAtomic(filepath.Join(w.dir, req.name), req.data); err != nil {
+			w.setErr(fmt.Errorf("write %s: %w", req.name, err))
+			continue
+		}
+		written = append(written, req)
+		line, err := json.Marshal(struct {
+			Name string `json:"name"`
+			ManifestEntry
+		}{Name: req.name, ManifestEntry: newManifestEntry(req.data)})
+		if err == nil {
+			journal = append(journal, line...)
+			journal = append(journal, '\n')
+		}
+	}
+
+	// The other form of a file is removed once the new one is written;
+	// its removal goes to the journal before the batch's own lines. A
+	// failed write keeps the old form, which is still the only copy.
+	for _, req := range written {
+		if err := removeCounterpart(w.dir, req.name); err != nil {
+			w.setErr(fmt.Errorf("remove old form of %s: %w", req.name, err))
+		}
+	}
+
+	if len(journal) > 0 {
+		journalMu.Lock()
+		f, err := os.OpenFile(filepath.Join(w.dir, ManifestJournalFile), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err == nil {
+			_, err = f.Write(journal)
+			if err == nil {
+				err = f.Sync()
+			}
+			f.Close()
+		}
+		journalMu.Unlock()
+		if err != nil {
+			w.setErr(fmt.Errorf("append manifest journal: %w", err))
+		}
+	}
+
+	// Each file was synced before its rename; one directory fsync makes
+	// the renames of the whole batch durable.
+	d, err := os.Open(w.dir)
+	if err == nil {
+		err = d.Sync()
+		d.Close()
+	}
+	if err != nil {
+		w.setErr(fmt.Errorf("sync %s: %w", w.dir, err))
+	}
+
+	w.mu.Lock()
+	for _, req := range batch {
+		if current, ok := w.pending[req.name]; ok && current.seq == req.seq {
+			delete(w.pending, req.name)
+		}
+	}
+	w.mu.Unlock()
+}
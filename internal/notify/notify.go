@@ -0,0 +1,201 @@
+// Package notify posts a summary of the notable changes of a sync to chat
+// webhooks: issues moved into or out of tracked sprints, new blockers and
+// status regressions. Slack and Google Chat incoming webhooks both accept
+// a JSON body with a "text" field, so one message format serves both.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/jctanner/rhoai-jira/internal/jira"
+)
+
+// maxLines caps the changes listed in one message; the rest are counted.
+const maxLines = 40
+
+// Notifier collects the change events of a sync and posts the notable
+// ones. It is safe for concurrent use.
+type Notifier struct {
+	// Webhooks are the Slack or Google Chat incoming webhook URLs.
+	Webhooks []string
+	// Sprints limits sprint moves to these sprints; empty reports all.
+	Sprints []string
+	// BaseURL, when set, links issue keys to Jira.
+	BaseURL    string
+	HTTPClient *http.Client
+
+	mu     sync.Mutex
+	events []jira.ChangeEvent
+}
+
+// Collect keeps the notable events among events.
+func (n *Notifier) Collect(events []jira.ChangeEvent) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for _, e := range events {
+		if n.notable(e) {
+			n.events = append(n.events, e)
+		}
+	}
+}
+
+func splitSprints(s string) map[string]bool {
+	set := map[string]bool{}
+	for _, name := range strings.Split(s, ",") {
+		if name != "" {
+			set[name] = true
+		}
+	}
+	return set
+}
+
+// sprintMoves returns the tracked sprints an issue entered and left.
+func (n *Notifier) sprintMoves(e jira.ChangeEvent) (entered, left []string) {
+	from, to := splitSprints(e.From), splitSprints(e.To)
+	tracked := func(name string) bool {
+		if len(n.Sprints) == 0 {
+			return true
+		}
+		for _, s := range n.Sprints {
+			if s == name {
+				return true
+			}
+		}
+		return false
+	}
+	for name := range to {
+		if !from[name] && tracked(name) {
+			entered = append(entered, name)
+		}
+	}
+	for name := range from {
+		if !to[name] && tracked(name) {
+			left = append(left, name)
+		}
+	}
+	sort.Strings(entered)
+	sort.Strings(left)
+	return entered, left
+}
+
+func (n *Notifier) notable(e jira.ChangeEvent) bool {
+	switch e.Type {
+	case jira.EventBlockerAdded, jira.EventStatusRegressed:
+		return true
+	case jira.EventSprintChanged:
+		entered, left := n.sprintMoves(e)
+		return len(entered) > 0 || len(left) > 0
+	}
+	return false
+}
+
+func (n *Notifier) link(key string) string {
+	if n.BaseURL == "" {
+		return key
+	}
+	return fmt.Sprintf("<%s/browse/%s|%s>", strings.TrimRight(n.BaseURL, "/"), key, key)
+}
+
+// Message formats the collected events, grouped by kind; it is empty when
+// nothing notable happened.
+func (n *Notifier) Message(title string) string {
+	n.mu.Lock()
+	events := append([]jira.ChangeEvent(nil), n.events...)
+	n.mu.Unlock()
+	if len(events) == 0 {
+		return ""
+	}
+
+	var moves, blockers, regressions []string
+	for _, e := range events {
+		issue := fmt.Sprintf("%s %s", n.link(e.Key), e.Summary)
+		switch e.Type {
+		case jira.EventSprintChanged:
+			entered, left := n.sprintMoves(e)
+			for _, s := range entered {
+				moves = append(moves, fmt.Sprintf("• %s moved into %s", issue, s))
+			}
+			for _, s := range left {
+				moves = append(moves, fmt.Sprintf("• %s moved out of %s", issue, s))
+			}
+		case jira.EventBlockerAdded:
+			blockers = append(blockers, fmt.Sprintf("• %s is now blocked by %s", issue, n.link(e.To)))
+		case jira.EventStatusRegressed:
+			regressions = append(regressions, fmt.Sprintf("• %s went back from %s to %s", issue, e.From, e.To))
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%s*\n", title)
+	written := 0
+	section := func(heading string, lines []string) {
+		if len(lines) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "\n*%s*\n", heading)
+		for _, line := range lines {
+			if written == maxLines {
+				return
+			}
+			b.WriteString(line + "\n")
+			written++
+		}
+	}
+	section("Sprint scope", moves)
+	section("New blockers", blockers)
+	section("Status regressions", regressions)
+	if total := len(moves) + len(blockers) + len(regressions); total > written {
+		fmt.Fprintf(&b, "\n_and %d more_\n", total-written)
+	}
+	return b.String()
+}
+
+// Flush posts the collected events to every webhook and forgets them.
+// Nothing is posted when nothing notable happened.
+func (n *Notifier) Flush(ctx context.Context, title string) error {
+	text := n.Message(title)
+	n.mu.Lock()
+	n.events = nil
+	n.mu.Unlock()
+	if text == "" || len(n.Webhooks) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return err
+	}
+	httpClient := n.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	var errs []error
+	for i, url := range n.Webhooks {
+		// The URLs are secrets, so errors name the webhook by position.
+		req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("webhook %d: invalid URL", i+1))
+			continue
+		}
+		req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("webhook %d: request failed", i+1))
+			continue
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			errs = append(errs, fmt.Errorf("webhook %d: unexpected status %d", i+1, resp.StatusCode))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,473 @@
+// Package parquet writes flat tables as Apache Parquet files: optional
+// string, integer, double and timestamp columns plus lists of strings,
+// PLAIN encoded with one data page per column and row group. It covers
+// what the exports need without an external dependency.
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"time"
+)
+
+// Type is the type of a column.
+type Type int
+
+const (
+	String Type = iota
+	Int64
+	Double
+	// Timestamp columns hold UTC instants with millisecond precision.
+	Timestamp
+	// StringList columns hold a possibly empty list of strings per row.
+	StringList
+)
+
+// Column is one column of the file schema. Every column but a StringList
+// may hold nulls.
+type Column struct {
+	Name string
+	Type Type
+}
+
+// Codec compresses data pages.
+type Codec struct {
+	// ID is the Parquet CompressionCodec: 0 uncompressed, 2 gzip, 6 zstd.
+	ID  int32
+	New func(w io.Writer) (io.WriteCloser, error)
+}
+
+// Options tune the writer.
+type Options struct {
+	// RowGroupRows is the number of rows buffered per row group.
+	RowGroupRows int
+	// Codec compresses pages; nil leaves them uncompressed.
+	Codec *Codec
+	// CreatedBy names the writing application in the footer.
+	CreatedBy string
+	// Metadata is stored as key/value metadata in the footer.
+	Metadata map[string]string
+}
+
+// Parquet enums used by the writer.
+const (
+	typeInt64     = 2
+	typeDouble    = 5
+	typeByteArray = 6
+
+	repRequired = 0
+	repOptional = 1
+	repRepeated = 2
+
+	convertedUTF8            = 0
+	convertedList            = 3
+	convertedTimestampMillis = 9
+
+	encodingPlain = 0
+	encodingRLE   = 3
+
+	pageData = 0
+)
+
+const magic = "PAR1"
+
+type column struct {
+	Column
+	reps   []uint8
+	defs   []uint8
+	values bytes.Buffer
+}
+
+func (c *column) physical() int32 {
+	switch c.Type {
+	case Int64, Timestamp:
+		return typeInt64
+	case Double:
+		return typeDouble
+	}
+	return typeByteArray
+}
+
+func (c *column) path() []string {
+	if c.Type == StringList {
+		return []string{c.Name, "list", "element"}
+	}
+	return []string{c.Name}
+}
+
+type chunkMeta struct {
+	column       *column
+	values       int64
+	offset       int64
+	uncompressed int64
+	compressed   int64
+}
+
+type rowGroup struct {
+	chunks []chunkMeta
+	rows   int64
+	bytes  int64
+}
+
+// Writer streams rows into a Parquet file.
+type Writer struct {
+	w       io.Writer
+	offset  int64
+	opts    Options
+	columns []*column
+	rows    int
+	total   int64
+	groups  []rowGroup
+	err     error
+}
+
+// NewWriter writes the file header and returns a writer for rows of the
+// given columns.
+func NewWriter(w io.Writer, columns []Column, opts Options) (*Writer, error) {
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("parquet: no columns")
+	}
+	if opts.RowGroupRows <= 0 {
+		opts.RowGroupRows = 50000
+	}
+	pw := &Writer{w: w, opts: opts}
+	for _, c := range columns {
+		pw.columns = append(pw.columns, &column{Column: c})
+	}
+	if err := pw.write([]byte(magic)); err != nil {
+		return nil, err
+	}
+	return pw, nil
+}
+
+func (w *Writer) write(p []byte) error {
+	if w.err != nil {
+		return w.err
+	}
+	n, err := w.w.Write(p)
+	w.offset += int64(n)
+	w.err = err
+	return err
+}
+
+// Write appends a row holding one value per column: nil, string, int64,
+// float64, time.Time or []string as the column type requires.
+func (w *Writer) Write(row []interface{}) error {
+	if len(row) != len(w.columns) {
+		return fmt.Errorf("parquet: row has %d values for %d columns", len(row), len(w.columns))
+	}
+	for i, c := range w.columns {
+		if err := c.append(row[i]); err != nil {
+			return fmt.Errorf("parquet: column %s: %w", c.Name, err)
+		}
+	}
+	w.rows++
+	w.total++
+	if w.rows >= w.opts.RowGroupRows {
+		return w.flush()
+	}
+	return nil
+}
+
+func (c *column) append(v interface{}) error {
+	if c.Type == StringList {
+		list, ok := v.([]string)
+		if v != nil && !ok {
+			return fmt.Errorf("want []string, got %T", v)
+		}
+		if len(list) == 0 {
+			c.reps = append(c.reps, 0)
+			c.defs = append(c.defs, 0)
+			return nil
+		}
+		for i, s := range list {
+			rep := uint8(1)
+			if i == 0 {
+				rep = 0
+			}
+			c.reps = append(c.reps, rep)
+			c.defs = append(c.defs, 1)
+			plainBytes(&c.values, s)
+		}
+		return nil
+	}
+
+	if v == nil {
+		c.defs = append(c.defs, 0)
+		return nil
+	}
+	var b [8]byte
+	switch c.Type {
+	case String:
+		s, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("want string, got %T", v)
+		}
+		plainBytes(&c.values, s)
+	case Int64:
+		n, ok := v.(int64)
+		if !ok {
+			return fmt.Errorf("want int64, got %T", v)
+		}
+		binary.LittleEndian.PutUint64(b[:], uint64(n))
+		c.values.Write(b[:])
+	case Double:
+		f, ok := v.(float64)
+		if !ok {
+			return fmt.Errorf("want float64, got %T", v)
+		}
+		binary.LittleEndian.PutUint64(b[:], math.Float64bits(f))
+		c.values.Write(b[:])
+	case Timestamp:
+		t, ok := v.(time.Time)
+		if !ok {
+			return fmt.Errorf("want time.Time, got %T", v)
+		}
+		binary.LittleEndian.PutUint64(b[:], uint64(t.UnixMilli()))
+		c.values.Write(b[:])
+	}
+	c.defs = append(c.defs, 1)
+	return nil
+}
+
+func plainBytes(buf *bytes.Buffer, s string) {
+	var n [4]byte
+	binary.LittleEndian.PutUint32(n[:], uint32(len(s)))
+	buf.Write(n[:])
+	buf.WriteString(s)
+}
+
+// levels encodes repetition or definition levels of bit width 1 with the
+// RLE/bit-packed hybrid, using RLE runs only, behind a 4-byte length.
+func levels(buf *bytes.Buffer, values []uint8) {
+	var runs bytes.Buffer
+	var tmp [binary.MaxVarintLen64]byte
+	for i := 0; i < len(values); {
+		j := i
+		for j < len(values) && values[j] == values[i] {
+			j++
+		}
+		n := binary.PutUvarint(tmp[:], uint64(j-i)<<1)
+		runs.Write(tmp[:n])
+		runs.WriteByte(values[i])
+		i = j
+	}
+	var size [4]byte
+	binary.LittleEndian.PutUint32(size[:], uint32(runs.Len()))
+	buf.Write(size[:])
+	buf.Write(runs.Bytes())
+}
+
+// flush writes the buffered rows as a row group.
+func (w *Writer) flush() error {
+	if w.rows == 0 {
+		return w.err
+	}
+	group := rowGroup{rows: int64(w.rows)}
+	for _, c := range w.columns {
+		var page bytes.Buffer
+		if c.Type == StringList {
+			levels(&page, c.reps)
+		}
+		levels(&page, c.defs)
+		page.Write(c.values.Bytes())
+
+		data := page.Bytes()
+		if w.opts.Codec != nil {
+			var compressed bytes.Buffer
+			zw, err := w.opts.Codec.New(&compressed)
+			if err != nil {
+				return err
+			}
+			if _, err := zw.Write(data); err != nil {
+				return err
+			}
+			if err := zw.Close(); err != nil {
+				return err
+			}
+			data = compressed.Bytes()
+		}
+
+		var header thriftWriter
+		header.begin(0)
+		header.i32(1, pageData)
+		header.i32(2, int32(page.Len()))
+		header.i32(3, int32(len(data)))
+		header.begin(5)
+		header.i32(1, int32(len(c.defs)))
+		header.i32(2, encodingPlain)
+		header.i32(3, encodingRLE)
+		header.i32(4, encodingRLE)
+		header.end()
+		header.end()
+
+		meta := chunkMeta{
+			column:       c,
+			values:       int64(len(c.defs)),
+			offset:       w.offset,
+			uncompressed: int64(header.buf.Len() + page.Len()),
+			compressed:   int64(header.buf.Len() + len(data)),
+		}
+		if err := w.write(header.buf.Bytes()); err != nil {
+			return err
+		}
+		if err := w.write(data); err != nil {
+			return err
+		}
+		group.chunks = append(group.chunks, meta)
+		group.bytes += meta.uncompressed
+
+		c.reps, c.defs = c.reps[:0], c.defs[:0]
+		c.values.Reset()
+	}
+	w.groups = append(w.groups, group)
+	w.rows = 0
+	return nil
+}
+
+// Rows returns the number of rows written so far.
+func (w *Writer) Rows() int64 {
+	return w.total
+}
+
+// Close flushes the last row group and writes the footer. It does not
+// close the underlying writer.
+func (w *Writer) Close() error {
+	if err := w.flush(); err != nil {
+		return err
+	}
+	var t thriftWriter
+	t.begin(0)
+	t.i32(1, 1)
+	t.list(2, thriftStruct, w.schemaElements())
+	t.lastID = 0
+	w.writeSchema(&t)
+	t.lastID = 2
+	t.i64(3, w.total)
+	t.list(4, thriftStruct, len(w.groups))
+	for _, g := range w.groups {
+		w.writeRowGroup(&t, g)
+	}
+	t.lastID = 4
+	if len(w.opts.Metadata) > 0 {
+		keys := sortedKeys(w.opts.Metadata)
+		t.list(5, thriftStruct, len(keys))
+		for _, k := range keys {
+			t.begin(0)
+			t.string(1, k)
+			t.string(2, w.opts.Metadata[k])
+			t.end()
+		}
+		t.lastID = 5
+	}
+	if w.opts.CreatedBy != "" {
+		t.string(6, w.opts.CreatedBy)
+	}
+	t.end()
+
+	footer := t.buf.Bytes()
+	var size [4]byte
+	binary.LittleEndian.PutUint32(size[:], uint32(len(footer)))
+	if err := w.write(footer); err != nil {
+		return err
+	}
+	if err := w.write(size[:]); err != nil {
+		return err
+	}
+	return w.write([]byte(magic))
+}
+
+func (w *Writer) schemaElements() int {
+	n := 1
+	for _, c := range w.columns {
+		if c.Type == StringList {
+			n += 3
+		} else {
+			n++
+		}
+	}
+	return n
+}
+
+// writeSchema writes the schema as the depth-first list of elements
+// Parquet expects, lists in the standard three-level layout.
+func (w *Writer) writeSchema(t *thriftWriter) {
+	t.begin(0)
+	t.string(4, "schema")
+	t.i32(5, int32(len(w.columns)))
+	t.end()
+	for _, c := range w.columns {
+		if c.Type == StringList {
+			t.begin(0)
+			t.i32(3, repRequired)
+			t.string(4, c.Name)
+			t.i32(5, 1)
+			t.i32(6, convertedList)
+			t.end()
+			t.begin(0)
+			t.i32(3, repRepeated)
+			t.string(4, "list")
+			t.i32(5, 1)
+			t.end()
+			t.begin(0)
+			t.i32(1, typeByteArray)
+			t.i32(3, repRequired)
+			t.string(4, "element")
+			t.i32(6, convertedUTF8)
+			t.end()
+			continue
+		}
+		t.begin(0)
+		t.i32(1, c.physical())
+		t.i32(3, repOptional)
+		t.string(4, c.Name)
+		switch c.Type {
+		case String:
+			t.i32(6, convertedUTF8)
+		case Timestamp:
+			t.i32(6, convertedTimestampMillis)
+		}
+		t.end()
+	}
+}
+
+func (w *Writer) writeRowGroup(t *thriftWriter, g rowGroup) {
+	codec := int32(0)
+	if w.opts.Codec != nil {
+		codec = w.opts.Codec.ID
+	}
+	t.begin(0)
+	t.list(1, thriftStruct, len(g.chunks))
+	for _, m := range g.chunks {
+		t.begin(0)
+		t.i64(2, m.offset)
+		t.begin(3)
+		t.i32(1, m.column.physical())
+		t.listI32(2, []int32{encodingPlain, encodingRLE})
+		t.listString(3, m.column.path())
+		t.i32(4, codec)
+		t.i64(5, m.values)
+		t.i64(6, m.uncompressed)
+		t.i64(7, m.compressed)
+		t.i64(9, m.offset)
+		t.end()
+		t.end()
+	}
+	t.lastID = 1
+	t.i64(2, g.bytes)
+	t.i64(3, g.rows)
+	t.end()
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,300 @@
+package parquet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// thriftReader decodes the Thrift compact protocol into maps of field id
+// to value, for the types the writer uses.
+type thriftReader struct {
+	t *testing.T
+	b []byte
+	i int
+}
+
+type thriftStructValue = map[int16]interface{}
+
+func (r *thriftReader) byte() byte {
+	if r.i >= len(r.b) {
+		r.t.Fatalf("thrift: read past the end at %d", r.i)
+	}
+	r.i++
+	return r.b[r.i-1]
+}
+
+func (r *thriftReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.b[r.i:])
+	if n <= 0 {
+		r.t.Fatalf("thrift: bad varint at %d", r.i)
+	}
+	r.i += n
+	return v
+}
+
+func (r *thriftReader) varint() int64 {
+	v := r.uvarint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (r *thriftReader) value(typ byte) interface{} {
+	switch typ {
+	case thriftI32, thriftI64:
+		return r.varint()
+	case thriftBinary:
+		n := int(r.uvarint())
+		s := string(r.b[r.i : r.i+n])
+		r.i += n
+		return s
+	case thriftList:
+		header := r.byte()
+		n, elem := int(header>>4), header&0x0f
+		if n == 15 {
+			n = int(r.uvarint())
+		}
+		list := []interface{}{}
+		for ; n > 0; n-- {
+			list = append(list, r.value(elem))
+		}
+		return list
+	case thriftStruct:
+		return r.structure()
+	}
+	r.t.Fatalf("thrift: unexpected type %d at %d", typ, r.i)
+	return nil
+}
+
+func (r *thriftReader) structure() thriftStructValue {
+	fields := thriftStructValue{}
+	var last int16
+	for {
+		header := r.byte()
+		if header == 0 {
+			return fields
+		}
+		id := last + int16(header>>4)
+		if header>>4 == 0 {
+			id = int16(r.varint())
+		}
+		last = id
+		fields[id] = r.value(header & 0x0f)
+	}
+}
+
+// readLevels decodes levels written by levels: RLE runs behind a length.
+func readLevels(t *testing.T, data []byte, n int) ([]uint8, []byte) {
+	size := int(binary.LittleEndian.Uint32(data))
+	runs, rest := data[4:4+size], data[4+size:]
+	var out []uint8
+	for len(runs) > 0 {
+		header, k := binary.Uvarint(runs)
+		if header&1 != 0 {
+			t.Fatalf("bit-packed run in levels")
+		}
+		for i := uint64(0); i < header>>1; i++ {
+			out = append(out, runs[k])
+		}
+		runs = runs[k+1:]
+	}
+	if len(out) != n {
+		t.Fatalf("got %d levels, want %d", len(out), n)
+	}
+	return out, rest
+}
+
+// readColumn decodes the page of a column chunk into one value per row.
+func readColumn(t *testing.T, file []byte, c Column, offset int64, codec int64) []interface{} {
+	r := &thriftReader{t: t, b: file, i: int(offset)}
+	header := r.structure()
+	if header[1] != int64(pageData) {
+		t.Fatalf("%s: page type %v, want a data page", c.Name, header[1])
+	}
+	data := file[r.i : r.i+int(header[3].(int64))]
+	switch codec {
+	case 0:
+	case 2:
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if data, err = io.ReadAll(zr); err != nil {
+			t.Fatal(err)
+		}
+	default:
+		t.Fatalf("unexpected codec %d", codec)
+	}
+	if int64(len(data)) != header[2] {
+		t.Fatalf("%s: page is %d bytes, header says %v", c.Name, len(data), header[2])
+	}
+	n := int(header[5].(thriftStructValue)[1].(int64))
+
+	var reps, defs []uint8
+	if c.Type == StringList {
+		reps, data = readLevels(t, data, n)
+	}
+	defs, data = readLevels(t, data, n)
+	next := func(size int) []byte {
+		v := data[:size]
+		data = data[size:]
+		return v
+	}
+	str := func() string {
+		return string(next(int(binary.LittleEndian.Uint32(next(4)))))
+	}
+
+	var rows []interface{}
+	for i, def := range defs {
+		if c.Type == StringList {
+			if reps[i] == 0 {
+				rows = append(rows, []string(nil))
+			}
+			if def == 1 {
+				last := len(rows) - 1
+				rows[last] = append(rows[last].([]string), str())
+			}
+			continue
+		}
+		if def == 0 {
+			rows = append(rows, nil)
+			continue
+		}
+		switch c.Type {
+		case String:
+			rows = append(rows, str())
+		case Int64:
+			rows = append(rows, int64(binary.LittleEndian.Uint64(next(8))))
+		case Double:
+			rows = append(rows, math.Float64frombits(binary.LittleEndian.Uint64(next(8))))
+		case Timestamp:
+			rows = append(rows, time.UnixMilli(int64(binary.LittleEndian.Uint64(next(8)))).UTC())
+		}
+	}
+	if len(data) != 0 {
+		t.Fatalf("%s: %d bytes left in the page", c.Name, len(data))
+	}
+	return rows
+}
+
+func TestWriterRoundTrip(t *testing.T) {
+	columns := []Column{{"key", String}, {"points", Int64}, {"ratio", Double}, {"updated", Timestamp}, {"labels", StringList}}
+	ts := time.Date(2025, 3, 1, 10, 30, 0, 123e6, time.UTC)
+	rows := [][]interface{}{
+		{"DEMO-1", int64(3), 0.5, ts, []string{"ui", "api"}},
+		{"DEMO-2", nil, nil, nil, nil},
+		{"", int64(-8), math.Inf(1), ts.Add(-time.Hour), []string{"only"}},
+		{nil, int64(1 << 40), -2.25, ts.Add(time.Millisecond), []string{}},
+		{"DEMO-5 ünïcode", int64(0), 0.0, time.UnixMilli(0).UTC(), []string{"", "x"}},
+	}
+	gzipCodec := &Codec{ID: 2, New: func(w io.Writer) (io.WriteCloser, error) { return gzip.NewWriter(w), nil }}
+
+	for _, codec := range []*Codec{nil, gzipCodec} {
+		var buf bytes.Buffer
+		w, err := NewWriter(&buf, columns, Options{RowGroupRows: 2, Codec: codec, CreatedBy: "test", Metadata: map[string]string{"b": "2", "a": "1"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, row := range rows {
+			if err := w.Write(row); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if w.Rows() != int64(len(rows)) {
+			t.Errorf("Rows() = %d, want %d", w.Rows(), len(rows))
+		}
+
+		file := buf.Bytes()
+		if !bytes.HasPrefix(file, []byte(magic)) || !bytes.HasSuffix(file, []byte(magic)) {
+			t.Fatalf("file does not start and end with %s", magic)
+		}
+		size := int(binary.LittleEndian.Uint32(file[len(file)-8:]))
+		start := len(file) - 8 - size
+		r := &thriftReader{t: t, b: file[:len(file)-8], i: start}
+		meta := r.structure()
+		if r.i != len(file)-8 {
+			t.Fatalf("footer decoded to %d bytes, length says %d", r.i-start, size)
+		}
+		if meta[1] != int64(1) || meta[3] != int64(len(rows)) || meta[6] != "test" {
+			t.Errorf("got version %v, %v rows, created by %v", meta[1], meta[3], meta[6])
+		}
+		var names []string
+		for _, e := range meta[2].([]interface{}) {
+			names = append(names, e.(thriftStructValue)[4].(string))
+		}
+		if want := []string{"schema", "key", "points", "ratio", "updated", "labels", "list", "element"}; !reflect.DeepEqual(names, want) {
+			t.Errorf("got schema %v, want %v", names, want)
+		}
+		var kv []string
+		for _, e := range meta[5].([]interface{}) {
+			kv = append(kv, e.(thriftStructValue)[1].(string)+"="+e.(thriftStructValue)[2].(string))
+		}
+		if !reflect.DeepEqual(kv, []string{"a=1", "b=2"}) {
+			t.Errorf("got metadata %v", kv)
+		}
+
+		got := make([][]interface{}, 0, len(rows))
+		groups := meta[4].([]interface{})
+		if len(groups) != 3 {
+			t.Fatalf("got %d row groups, want 3", len(groups))
+		}
+		for _, g := range groups {
+			group := g.(thriftStructValue)
+			var groupRows [][]interface{}
+			for i, c := range group[1].([]interface{}) {
+				cm := c.(thriftStructValue)[3].(thriftStructValue)
+				wantCodec := int64(0)
+				if codec != nil {
+					wantCodec = int64(codec.ID)
+				}
+				if cm[4] != wantCodec {
+					t.Errorf("%s: codec %v, want %d", columns[i].Name, cm[4], wantCodec)
+				}
+				values := readColumn(t, file, columns[i], cm[9].(int64), wantCodec)
+				if int64(len(values)) != group[3] {
+					t.Fatalf("%s: got %d values in a group of %v rows", columns[i].Name, len(values), group[3])
+				}
+				for j, v := range values {
+					if i == 0 {
+						groupRows = append(groupRows, make([]interface{}, len(columns)))
+					}
+					groupRows[j][i] = v
+				}
+			}
+			got = append(got, groupRows...)
+		}
+
+		for i, row := range rows {
+			want := append([]interface{}{}, row...)
+			if list, ok := want[4].([]string); !ok || len(list) == 0 {
+				want[4] = []string(nil)
+			}
+			if !reflect.DeepEqual(got[i], want) {
+				t.Errorf("codec %v row %d: got %v, want %v", codec != nil, i, got[i], want)
+			}
+		}
+	}
+}
+
+func TestWriterRejectsMismatchedRows(t *testing.T) {
+	w, err := NewWriter(io.Discard, []Column{{"key", String}, {"points", Int64}}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write([]interface{}{"DEMO-1"}); err == nil {
+		t.Error("short row accepted")
+	}
+	if err := w.Write([]interface{}{"DEMO-1", 3}); err == nil {
+		t.Error("int accepted for an Int64 column")
+	}
+	if _, err := NewWriter(io.Discard, nil, Options{}); err == nil {
+		t.Error("writer without columns accepted")
+	}
+}
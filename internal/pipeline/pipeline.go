@@ -0,0 +1,359 @@
+// Package pipeline runs a declared sequence of rhoai-jira subcommands
+// (typically fetch, then reports) and publishes their outputs.
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jctanner/rhoai-jira/internal/yaml"
+)
+
+// Error handling modes for Pipeline.OnError and Step.OnError.
+const (
+	OnErrorStop     = "stop"
+	OnErrorContinue = "continue"
+)
+
+// Step statuses reported in StepResult.
+const (
+	StatusOK      = "ok"
+	StatusFailed  = "failed"
+	StatusSkipped = "skipped"
+)
+
+type Pipeline struct {
+	Name string `json:"name"`
+	// Env is added to the environment of every step.
+	Env map[string]string `json:"env"`
+	// OnError is the default for steps: "stop" (default) or "continue".
+	OnError string `json:"on_error"`
+	Steps   []Step `json:"steps"`
+}
+
+// Step runs one subcommand. Flags are passed as -name=value (lists repeat the
+// flag, true booleans are passed bare) before the positional Args. When
+// Projects is set the step runs once per project with -project set and
+// "{project}" replaced in flags, args, output and artifact paths.
+type Step struct {
+	Name     string                 `json:"name"`
+	Command  string                 `json:"command"`
+	Projects []string               `json:"projects"`
+	Flags    map[string]interface{} `json:"flags"`
+	Args     []string               `json:"args"`
+	Env      map[string]string      `json:"env"`
+	// Output receives the step's stdout; empty passes it through.
+	Output string `json:"output"`
+	// Artifacts are other files the step writes (such as charts) that are
+	// published along with Output.
+	Artifacts []string `json:"artifacts"`
+	Publish   []Sink   `json:"publish"`
+	OnError   string   `json:"on_error"`
+	Timeout   string   `json:"timeout"`
+}
+
+// Sink is a publish destination: a file path (a directory keeps the file
+// name) or a URL the file is POSTed to.
+type Sink struct {
+	File    string            `json:"file"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers"`
+}
+
+type StepResult struct {
+	Step     string
+	Project  string
+	Status   string
+	Duration time.Duration
+	Err      error
+}
+
+// Load reads a pipeline from a YAML (or JSON) file and validates it.
+// Environment variables in string values are expanded when steps run.
+func Load(path string) (*Pipeline, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read pipeline: %w", err)
+	}
+	var p Pipeline
+	if err := yaml.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if err := p.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &p, nil
+}
+
+func validOnError(s string) bool {
+	return s == "" || s == OnErrorStop || s == OnErrorContinue
+}
+
+func (p *Pipeline) Validate() error {
+	if len(p.Steps) == 0 {
+		return fmt.Errorf("pipeline has no steps")
+	}
+	if !validOnError(p.OnError) {
+		return fmt.Errorf("on_error must be %q or %q", OnErrorStop, OnErrorContinue)
+	}
+	for i := range p.Steps {
+		s := &p.Steps[i]
+		if strings.TrimSpace(s.Command) == "" {
+			return fmt.Errorf("step %d: command is required", i+1)
+		}
+		if s.Name == "" {
+			s.Name = fmt.Sprintf("%d-%s", i+1, strings.Join(strings.Fields(s.Command), "-"))
+		}
+		if !validOnError(s.OnError) {
+			return fmt.Errorf("step %s: on_error must be %q or %q", s.Name, OnErrorStop, OnErrorContinue)
+		}
+		if s.Timeout != "" {
+			if _, err := time.ParseDuration(s.Timeout); err != nil {
+				return fmt.Errorf("step %s: invalid timeout: %w", s.Name, err)
+			}
+		}
+		for _, sink := range s.Publish {
+			if (sink.File == "") == (sink.URL == "") {
+				return fmt.Errorf("step %s: each publish entry needs exactly one of file or url", s.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// Runner executes the subcommands of a pipeline.
+type Runner struct {
+	// Executable is the rhoai-jira binary the steps run as.
+	Executable string
+	Stdout     io.Writer
+	Stderr     io.Writer
+	// Logf reports step progress.
+	Logf func(format string, args ...interface{})
+}
+
+func (r *Runner) logf(format string, args ...interface{}) {
+	if r.Logf != nil {
+		r.Logf(format, args...)
+	}
+}
+
+// Run executes the steps in order. A failing step stops the pipeline unless
+// its on_error (or the pipeline's) is "continue"; steps after a stop are
+// reported as skipped.
+func (r *Runner) Run(ctx context.Context, p *Pipeline) []StepResult {
+	var results []StepResult
+	stopped := false
+	for _, step := range p.Steps {
+		projects := step.Projects
+		if len(projects) == 0 {
+			projects = []string{""}
+		}
+		for _, project := range projects {
+			result := StepResult{Step: step.Name, Project: project}
+			if stopped {
+				result.Status = StatusSkipped
+				results = append(results, result)
+				continue
+			}
+			start := time.Now()
+			result.Err = r.runStep(ctx, p, step, project)
+			result.Duration = time.Since(start)
+			result.Status = StatusOK
+			if result.Err != nil {
+				result.Status = StatusFailed
+				onError := step.OnError
+				if onError == "" {
+					onError = p.OnError
+				}
+				if onError != OnErrorContinue || ctx.Err() != nil {
+					stopped = true
+				}
+			}
+			r.logf("step %s: %s in %s", label(result), result.Status, result.Duration.Round(time.Millisecond))
+			if result.Err != nil {
+				r.logf("step %s: %v", label(result), result.Err)
+			}
+			results = append(results, result)
+		}
+	}
+	return results
+}
+
+func label(r StepResult) string {
+	if r.Project == "" {
+		return r.Step
+	}
+	return r.Step + "[" + r.Project + "]"
+}
+
+// Failed reports whether any step failed.
+func Failed(results []StepResult) bool {
+	for _, r := range results {
+		if r.Status == StatusFailed {
+			return true
+		}
+	}
+	return false
+}
+
+func expand(s, project string) string {
+	return os.ExpandEnv(strings.ReplaceAll(s, "{project}", project))
+}
+
+func flagValues(v interface{}) []string {
+	switch v := v.(type) {
+	case []interface{}:
+		var out []string
+		for _, item := range v {
+			out = append(out, flagValues(item)...)
+		}
+		return out
+	case float64:
+		return []string{strconv.FormatFloat(v, 'f', -1, 64)}
+	case nil:
+		return []string{""}
+	}
+	return []string{fmt.Sprint(v)}
+}
+
+// CommandLine returns the subcommand and arguments of a step for a project.
+// Command may hold several words, as in "list components".
+func (s Step) CommandLine(project string) []string {
+	args := strings.Fields(s.Command)
+	if project != "" {
+		args = append(args, "-project="+project)
+	}
+	names := make([]string, 0, len(s.Flags))
+	for name := range s.Flags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		flag := "-" + strings.TrimLeft(name, "-")
+		if b, ok := s.Flags[name].(bool); ok && b {
+			args = append(args, flag)
+			continue
+		}
+		for _, v := range flagValues(s.Flags[name]) {
+			args = append(args, flag+"="+expand(v, project))
+		}
+	}
+	for _, a := range s.Args {
+		args = append(args, expand(a, project))
+	}
+	return args
+}
+
+func environ(p *Pipeline, s Step, project string) []string {
+	env := os.Environ()
+	for _, vars := range []map[string]string{p.Env, s.Env} {
+		for k, v := range vars {
+			env = append(env, k+"="+expand(v, project))
+		}
+	}
+	return env
+}
+
+func (r *Runner) runStep(ctx context.Context, p *Pipeline, s Step, project string) error {
+	if s.Timeout != "" {
+		timeout, _ := time.ParseDuration(s.Timeout)
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	stdout := r.Stdout
+	output := expand(s.Output, project)
+	if output != "" {
+		if err := os.MkdirAll(filepath.Dir(output), 0o755); err != nil {
+			return err
+		}
+		f, err := os.Create(output)
+		if err != nil {
+			return fmt.Errorf("create output: %w", err)
+		}
+		defer f.Close()
+		stdout = f
+	}
+
+	args := s.CommandLine(project)
+	r.logf("step %s: %s %s", label(StepResult{Step: s.Name, Project: project}), filepath.Base(r.Executable), strings.Join(args, " "))
+	cmd := exec.CommandContext(ctx, r.Executable, args...)
+	cmd.Env = environ(p, s, project)
+	cmd.Stdout = stdout
+	cmd.Stderr = r.Stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("timed out after %s", s.Timeout)
+		}
+		return fmt.Errorf("%s: %w", args[0], err)
+	}
+
+	var files []string
+	if output != "" {
+		files = append(files, output)
+	}
+	for _, a := range s.Artifacts {
+		files = append(files, expand(a, project))
+	}
+	for _, sink := range s.Publish {
+		for _, file := range files {
+			if err := publish(ctx, sink, file, project); err != nil {
+				return fmt.Errorf("publish %s: %w", file, err)
+			}
+		}
+	}
+	return nil
+}
+
+func publish(ctx context.Context, sink Sink, file, project string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	if sink.File != "" {
+		dest := expand(sink.File, project)
+		if info, err := os.Stat(dest); (err == nil && info.IsDir()) || strings.HasSuffix(dest, "/") {
+			dest = filepath.Join(dest, filepath.Base(file))
+		}
+		if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+			return err
+		}
+		return os.WriteFile(dest, data, 0o644)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", expand(sink.URL, project), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	contentType := mime.TypeByExtension(filepath.Ext(file))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("X-Filename", filepath.Base(file))
+	for k, v := range sink.Headers {
+		req.Header.Set(k, expand(v, project))
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s answered %s", sink.URL, resp.Status)
+	}
+	return nil
+}
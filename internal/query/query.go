@@ -0,0 +1,679 @@
+// Package query implements a practical subset of JQL evaluated against
+// locally cached issues.
+package query
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jctanner/rhoai-jira/internal/jira"
+)
+
+// Node is a parsed boolean expression.
+type Node interface {
+	Eval(issue jira.JiraIssueWithSprints) bool
+}
+
+type andNode struct{ left, right Node }
+
+func (n andNode) Eval(issue jira.JiraIssueWithSprints) bool {
+	return n.left.Eval(issue) && n.right.Eval(issue)
+}
+
+type orNode struct{ left, right Node }
+
+func (n orNode) Eval(issue jira.JiraIssueWithSprints) bool {
+	return n.left.Eval(issue) || n.right.Eval(issue)
+}
+
+type notNode struct{ inner Node }
+
+func (n notNode) Eval(issue jira.JiraIssueWithSprints) bool {
+	return !n.inner.Eval(issue)
+}
+
+type matchAll struct{}
+
+func (matchAll) Eval(jira.JiraIssueWithSprints) bool { return true }
+
+// OrderBy is a single ORDER BY term.
+type OrderBy struct {
+	Field string
+	Desc  bool
+}
+
+// Query is a parsed JQL-lite query.
+type Query struct {
+	Where   Node
+	OrderBy []OrderBy
+}
+
+func (q *Query) Match(issue jira.JiraIssueWithSprints) bool {
+	return q.Where.Eval(issue)
+}
+
+// Filter returns the matching issues in ORDER BY order.
+func (q *Query) Filter(issues []jira.JiraIssueWithSprints) []jira.JiraIssueWithSprints {
+	var matched []jira.JiraIssueWithSprints
+	for _, issue := range issues {
+		if q.Match(issue) {
+			matched = append(matched, issue)
+		}
+	}
+	q.Sort(matched)
+	return matched
+}
+
+func compareIssues(a, b jira.JiraIssueWithSprints, field string) int {
+	switch field {
+	case "key":
+		an, bn := keyNumber(a.Key), keyNumber(b.Key)
+		if a.Fields.Project.Key == b.Fields.Project.Key && an != bn {
+			if an < bn {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.Key, b.Key)
+	case "updated", "created", "resolved":
+		at, _ := dateValue(a, field)
+		bt, _ := dateValue(b, field)
+		return at.Compare(bt)
+	case "points":
+		an, _ := numberValue(a, field)
+		bn, _ := numberValue(b, field)
+		switch {
+		case an < bn:
+			return -1
+		case an > bn:
+			return 1
+		}
+		return 0
+	default:
+		return strings.Compare(strings.Join(fieldValues(a, field), ","), strings.Join(fieldValues(b, field), ","))
+	}
+}
+
+func (q *Query) Sort(issues []jira.JiraIssueWithSprints) {
+	if len(q.OrderBy) == 0 {
+		return
+	}
+	sort.SliceStable(issues, func(i, j int) bool {
+		for _, o := range q.OrderBy {
+			c := compareIssues(issues[i], issues[j], o.Field)
+			if c == 0 {
+				continue
+			}
+			if o.Desc {
+				return c > 0
+			}
+			return c < 0
+		}
+		return false
+	})
+}
+
+func keyNumber(key string) int {
+	_, num, _ := strings.Cut(key, "-")
+	n, _ := strconv.Atoi(num)
+	return n
+}
+
+// Fields supported by the engine and their canonical names.
+var fieldAliases = map[string]string{
+	"project":     "project",
+	"status":      "status",
+	"sprint":      "sprint",
+	"labels":      "labels",
+	"label":       "labels",
+	"assignee":    "assignee",
+	"updated":     "updated",
+	"created":     "created",
+	"resolved":    "resolved",
+	"text":        "text",
+	"summary":     "summary",
+	"key":         "key",
+	"issuekey":    "key",
+	"type":        "type",
+	"issuetype":   "type",
+	"priority":    "priority",
+	"epic":        "epic",
+	"parent":      "epic",
+	"fixversion":  "fixversion",
+	"category":    "category",
+	"points":      "points",
+	"storypoints": "points",
+}
+
+func canonicalField(name string) (string, bool) {
+	f, ok := fieldAliases[strings.ToLower(name)]
+	return f, ok
+}
+
+func isDateField(field string) bool {
+	return field == "updated" || field == "created" || field == "resolved"
+}
+
+func isNumberField(field string) bool {
+	return field == "points"
+}
+
+// fieldValues returns the string values of a field for comparison. Multi
+// valued fields (labels, sprint, fixversion) return one entry per value.
+func fieldValues(issue jira.JiraIssueWithSprints, field string) []string {
+	f := issue.Fields
+	single := func(v string) []string {
+		if v == "" {
+			return nil
+		}
+		return []string{v}
+	}
+	switch field {
+	case "project":
+		return single(f.Project.Key)
+	case "status":
+		return single(f.Status.Name)
+	case "sprint":
+		var names []string
+		for _, s := range f.Sprints {
+			names = append(names, s.Name)
+		}
+		return names
+	case "labels":
+		return f.Labels
+	case "assignee":
+		if f.Assignee == nil {
+			return nil
+		}
+		return []string{f.Assignee.ID(), f.Assignee.DisplayName, f.Assignee.EmailAddress}
+	case "summary":
+		return single(f.Summary)
+	case "text":
+		return single(f.Summary + "\n" + f.Description)
+	case "key":
+		return single(issue.Key)
+	case "type":
+		return single(f.IssueType.Name)
+	case "priority":
+		if f.Priority == nil {
+			return nil
+		}
+		return single(f.Priority.Name)
+	case "epic":
+		return single(issue.EpicKey())
+	case "fixversion":
+		var names []string
+		for _, v := range f.FixVersions {
+			names = append(names, v.Name)
+		}
+		return names
+	case "category":
+		return f.Categories
+	case "updated":
+		return single(f.Updated)
+	case "created":
+		return single(f.Created)
+	case "resolved":
+		return single(f.ResolutionDate)
+	case "points":
+		if f.StoryPoints == nil {
+			return nil
+		}
+		return single(strconv.FormatFloat(*f.StoryPoints, 'f', -1, 64))
+	}
+	return nil
+}
+
+func dateValue(issue jira.JiraIssueWithSprints, field string) (time.Time, bool) {
+	values := fieldValues(issue, field)
+	if len(values) == 0 {
+		return time.Time{}, false
+	}
+	t, err := jira.ParseJiraTime(values[0])
+	return t, err == nil
+}
+
+func numberValue(issue jira.JiraIssueWithSprints, field string) (float64, bool) {
+	values := fieldValues(issue, field)
+	if len(values) == 0 {
+		return 0, false
+	}
+	n, err := strconv.ParseFloat(values[0], 64)
+	return n, err == nil
+}
+
+// ParseDate accepts absolute dates ("2025-01-31", "2025-01-31 14:00") and
+// relative offsets from now ("-7d", "-2w", "-12h", "now()").
+func ParseDate(value string, now time.Time) (time.Time, error) {
+	v := strings.TrimSpace(value)
+	if strings.EqualFold(v, "now()") {
+		return now, nil
+	}
+	if len(v) >= 2 && (v[0] == '-' || v[0] == '+') {
+		unit := v[len(v)-1]
+		n, err := strconv.Atoi(v[1 : len(v)-1])
+		if err == nil {
+			var d time.Duration
+			switch unit {
+			case 'm':
+				d = time.Minute
+			case 'h':
+				d = time.Hour
+			case 'd':
+				d = 24 * time.Hour
+			case 'w':
+				d = 7 * 24 * time.Hour
+			default:
+				return time.Time{}, fmt.Errorf("invalid relative date %q", value)
+			}
+			offset := time.Duration(n) * d
+			if v[0] == '-' {
+				offset = -offset
+			}
+			return now.Add(offset), nil
+		}
+	}
+	for _, layout := range []string{"2006-01-02 15:04", "2006/01/02 15:04", "2006-01-02", "2006/01/02"} {
+		if t, err := time.ParseInLocation(layout, v, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", value)
+}
+
+type clause struct {
+	field   string
+	op      string // = != ~ !~ < <= > >= in notin empty notempty
+	values  []string
+	dates   []time.Time
+	numbers []float64
+}
+
+func sprintFunctionMatch(issue jira.JiraIssueWithSprints, fn string) bool {
+	for _, s := range issue.Fields.Sprints {
+		switch fn {
+		case "opensprints()":
+			if s.State == "ACTIVE" || s.State == "FUTURE" {
+				return true
+			}
+		case "closedsprints()":
+			if s.State == "CLOSED" {
+				return true
+			}
+		case "futuresprints()":
+			if s.State == "FUTURE" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (c clause) equalsAny(issue jira.JiraIssueWithSprints) bool {
+	if isNumberField(c.field) {
+		n, ok := numberValue(issue, c.field)
+		for _, want := range c.numbers {
+			if ok && n == want {
+				return true
+			}
+		}
+		return false
+	}
+	values := fieldValues(issue, c.field)
+	for _, want := range c.values {
+		if c.field == "sprint" && strings.HasSuffix(want, "()") {
+			if sprintFunctionMatch(issue, strings.ToLower(want)) {
+				return true
+			}
+			continue
+		}
+		for _, v := range values {
+			if strings.EqualFold(v, want) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// dateEqualsAny reports whether the issue's date is one of the clause's
+// dates; ok is false when the issue has no such date.
+func (c clause) dateEqualsAny(issue jira.JiraIssueWithSprints) (match, ok bool) {
+	t, ok := dateValue(issue, c.field)
+	if !ok {
+		return false, false
+	}
+	for _, d := range c.dates {
+		if t.Equal(d) {
+			return true, true
+		}
+	}
+	return false, true
+}
+
+func (c clause) containsAny(issue jira.JiraIssueWithSprints) bool {
+	want := strings.ToLower(c.values[0])
+	for _, v := range fieldValues(issue, c.field) {
+		if strings.Contains(strings.ToLower(v), want) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c clause) Eval(issue jira.JiraIssueWithSprints) bool {
+	switch c.op {
+	case "empty":
+		return len(fieldValues(issue, c.field)) == 0
+	case "notempty":
+		return len(fieldValues(issue, c.field)) > 0
+	case "=", "in":
+		if isDateField(c.field) {
+			match, ok := c.dateEqualsAny(issue)
+			return ok && match
+		}
+		return c.equalsAny(issue)
+	case "!=", "notin":
+		if isDateField(c.field) {
+			// An issue without the date is not unequal to one.
+			match, ok := c.dateEqualsAny(issue)
+			return ok && !match
+		}
+		return !c.equalsAny(issue)
+	case "~":
+		return c.containsAny(issue)
+	case "!~":
+		return !c.containsAny(issue)
+	}
+
+	if isNumberField(c.field) {
+		n, ok := numberValue(issue, c.field)
+		if !ok {
+			return false
+		}
+		target := c.numbers[0]
+		switch c.op {
+		case "<":
+			return n < target
+		case "<=":
+			return n <= target
+		case ">":
+			return n > target
+		case ">=":
+			return n >= target
+		}
+		return false
+	}
+
+	if isDateField(c.field) {
+		t, ok := dateValue(issue, c.field)
+		if !ok {
+			return false
+		}
+		target := c.dates[0]
+		switch c.op {
+		case "<":
+			return t.Before(target)
+		case "<=":
+			return !t.After(target)
+		case ">":
+			return t.After(target)
+		case ">=":
+			return !t.Before(target)
+		}
+		return false
+	}
+
+	// Lexical ordering for everything else keeps "key > ABC-10" usable.
+	values := fieldValues(issue, c.field)
+	if len(values) == 0 {
+		return false
+	}
+	cmp := 0
+	if c.field == "key" {
+		cmp = keyNumber(values[0]) - keyNumber(c.values[0])
+	} else {
+		cmp = strings.Compare(strings.ToLower(values[0]), strings.ToLower(c.values[0]))
+	}
+	switch c.op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	}
+	return false
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+	now    time.Time
+}
+
+func (p *parser) peek() token { return p.tokens[p.pos] }
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("at position %d: %s", p.peek().pos, fmt.Sprintf(format, args...))
+}
+
+// Parse parses a JQL-lite query. An empty query matches every issue.
+func Parse(input string) (*Query, error) {
+	return ParseAt(input, time.Now())
+}
+
+// ParseAt parses a query resolving relative dates against now.
+func ParseAt(input string, now time.Time) (*Query, error) {
+	tokens, err := lex(input)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens, now: now}
+
+	q := &Query{Where: matchAll{}}
+	if p.peek().kind != tokEOF && !p.peek().is("order") {
+		if q.Where, err = p.parseOr(); err != nil {
+			return nil, err
+		}
+	}
+	if p.peek().is("order") {
+		p.next()
+		if !p.next().is("by") {
+			return nil, p.errorf("expected BY after ORDER")
+		}
+		for {
+			t := p.next()
+			if t.kind != tokIdent {
+				return nil, p.errorf("expected field after ORDER BY")
+			}
+			field, ok := canonicalField(t.text)
+			if !ok {
+				return nil, fmt.Errorf("unknown field %q", t.text)
+			}
+			o := OrderBy{Field: field}
+			if p.peek().is("desc") {
+				p.next()
+				o.Desc = true
+			} else if p.peek().is("asc") {
+				p.next()
+			}
+			q.OrderBy = append(q.OrderBy, o)
+			if p.peek().kind != tokComma {
+				break
+			}
+			p.next()
+		}
+	}
+	if p.peek().kind != tokEOF {
+		return nil, p.errorf("unexpected %q", p.peek().text)
+	}
+	return q, nil
+}
+
+func (p *parser) parseOr() (Node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().is("or") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (Node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().is("and") {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (Node, error) {
+	switch {
+	case p.peek().is("not"):
+		p.next()
+		inner, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{inner}, nil
+	case p.peek().kind == tokLParen:
+		p.next()
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.next().kind != tokRParen {
+			return nil, p.errorf("expected )")
+		}
+		return inner, nil
+	}
+	return p.parseClause()
+}
+
+func (p *parser) parseValue() (string, error) {
+	t := p.next()
+	if t.kind != tokIdent && t.kind != tokString {
+		return "", p.errorf("expected value")
+	}
+	return t.text, nil
+}
+
+func (p *parser) parseClause() (Node, error) {
+	t := p.next()
+	if t.kind != tokIdent {
+		return nil, p.errorf("expected field name")
+	}
+	field, ok := canonicalField(t.text)
+	if !ok {
+		return nil, fmt.Errorf("unknown field %q", t.text)
+	}
+	c := clause{field: field}
+
+	switch op := p.peek(); {
+	case op.kind == tokOp:
+		p.next()
+		c.op = op.text
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		c.values = []string{value}
+		if (c.op == "~" || c.op == "!~") && (isDateField(field) || isNumberField(field)) {
+			return nil, fmt.Errorf("operator %s not supported on %s", c.op, field)
+		}
+	case op.is("is"):
+		p.next()
+		c.op = "empty"
+		if p.peek().is("not") {
+			p.next()
+			c.op = "notempty"
+		}
+		if v := p.next(); !v.is("empty") && !v.is("null") {
+			return nil, p.errorf("expected EMPTY")
+		}
+		return c, nil
+	case op.is("in"), op.is("not"):
+		p.next()
+		c.op = "in"
+		if op.is("not") {
+			if !p.next().is("in") {
+				return nil, p.errorf("expected IN after NOT")
+			}
+			c.op = "notin"
+		}
+		if fn := p.peek(); fn.kind == tokIdent && strings.HasSuffix(fn.text, "()") {
+			p.next()
+			c.values = []string{fn.text}
+			break
+		}
+		if p.next().kind != tokLParen {
+			return nil, p.errorf("expected ( after IN")
+		}
+		for {
+			value, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			c.values = append(c.values, value)
+			if p.peek().kind == tokComma {
+				p.next()
+				continue
+			}
+			break
+		}
+		if p.next().kind != tokRParen {
+			return nil, p.errorf("expected ) to close IN list")
+		}
+	default:
+		return nil, p.errorf("expected operator after %s", t.text)
+	}
+
+	if isDateField(field) {
+		for _, v := range c.values {
+			d, err := ParseDate(v, p.now)
+			if err != nil {
+				return nil, err
+			}
+			c.dates = append(c.dates, d)
+		}
+	}
+	if isNumberField(field) {
+		for _, v := range c.values {
+			n, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %q for %s", v, field)
+			}
+			c.numbers = append(c.numbers, n)
+		}
+	}
+	return c, nil
+}
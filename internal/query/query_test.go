@@ -0,0 +1,228 @@
+package query
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jctanner/rhoai-jira/internal/jira"
+)
+
+var now = time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+
+// testIssues are two projects' issues in each sprint state, with and
+// without labels, points and sprints.
+func testIssues(t *testing.T) []jira.JiraIssueWithSprints {
+	t.Helper()
+	docs := []string{
+		`{"key": "DEMO-1", "fields": {"project": {"key": "DEMO"}, "summary": "Login page broken", "status": {"name": "Done"},
+			"labels": ["ui"], "customfield_12310243": 3, "assignee": {"name": "alice", "displayName": "Alice"},
+			"customfield_12310940": [{"id": 1, "name": "Sprint 1", "state": "CLOSED"}],
+			"updated": "2025-03-01T10:00:00.000+0000", "created": "2025-01-05T10:00:00.000+0000"}}`,
+		`{"key": "DEMO-2", "fields": {"project": {"key": "DEMO"}, "summary": "API timeout", "status": {"name": "In Progress"},
+			"labels": ["api", "ui"], "customfield_12310243": 5,
+			"customfield_12310940": [{"id": 1, "name": "Sprint 1", "state": "CLOSED"}, {"id": 2, "name": "Sprint 2", "state": "ACTIVE"}],
+			"updated": "2025-03-09T10:00:00.000+0000", "created": "2025-01-06T10:00:00.000+0000"}}`,
+		`{"key": "DEMO-10", "fields": {"project": {"key": "DEMO"}, "summary": "Docs", "status": {"name": "New"},
+			"customfield_12310940": [{"id": 3, "name": "Sprint 3", "state": "FUTURE"}],
+			"updated": "2025-03-10T06:00:00.000+0000", "created": "2025-03-10T06:00:00.000+0000"}}`,
+		`{"key": "OTHER-3", "fields": {"project": {"key": "OTHER"}, "summary": "Other project", "status": {"name": "New"},
+			"labels": ["api"], "customfield_12310243": 1,
+			"updated": "2025-02-01T10:00:00.000+0000", "created": "2025-01-01T10:00:00.000+0000"}}`,
+	}
+	var issues []jira.JiraIssueWithSprints
+	for _, doc := range docs {
+		var issue jira.JiraIssueWithSprints
+		if err := json.Unmarshal([]byte(doc), &issue); err != nil {
+			t.Fatal(err)
+		}
+		issues = append(issues, issue)
+	}
+	return issues
+}
+
+func keys(issues []jira.JiraIssueWithSprints) []string {
+	out := []string{}
+	for _, issue := range issues {
+		out = append(out, issue.Key)
+	}
+	return out
+}
+
+func TestParseDate(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want time.Time
+	}{
+		{"now()", now},
+		{"NOW()", now},
+		{"-7d", now.AddDate(0, 0, -7)},
+		{"+2w", now.AddDate(0, 0, 14)},
+		{"-12h", now.Add(-12 * time.Hour)},
+		{"-30m", now.Add(-30 * time.Minute)},
+		{"+0d", now},
+		{" -1d ", now.AddDate(0, 0, -1)},
+		{"2025-01-31", time.Date(2025, 1, 31, 0, 0, 0, 0, time.Local)},
+		{"2025/01/31", time.Date(2025, 1, 31, 0, 0, 0, 0, time.Local)},
+		{"2025-01-31 14:05", time.Date(2025, 1, 31, 14, 5, 0, 0, time.Local)},
+	} {
+		got, err := ParseDate(tc.in, now)
+		if err != nil {
+			t.Errorf("ParseDate(%q): %v", tc.in, err)
+			continue
+		}
+		if !got.Equal(tc.want) {
+			t.Errorf("ParseDate(%q) = %v, want %v", tc.in, got, tc.want)
+		}
+	}
+	for _, in := range []string{"-7y", "-d", "yesterday", "2025-13-01", ""} {
+		if got, err := ParseDate(in, now); err == nil {
+			t.Errorf("ParseDate(%q) = %v, want an error", in, got)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	issues := testIssues(t)
+	for _, tc := range []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"DEMO-1", "DEMO-2", "DEMO-10", "OTHER-3"}},
+		{"project = DEMO", []string{"DEMO-1", "DEMO-2", "DEMO-10"}},
+		{`status = "in progress"`, []string{"DEMO-2"}},
+		{"status != Done", []string{"DEMO-2", "DEMO-10", "OTHER-3"}},
+		{"labels in (ui, docs)", []string{"DEMO-1", "DEMO-2"}},
+		{"label not in (api)", []string{"DEMO-1", "DEMO-10"}},
+		{"labels is EMPTY", []string{"DEMO-10"}},
+		{"storyPoints is not null", []string{"DEMO-1", "DEMO-2", "OTHER-3"}},
+		{"summary ~ TIMEOUT", []string{"DEMO-2"}},
+		{"summary !~ page", []string{"DEMO-2", "DEMO-10", "OTHER-3"}},
+		{"points >= 3", []string{"DEMO-1", "DEMO-2"}},
+		{"points = 5", []string{"DEMO-2"}},
+		{"points < 3", []string{"OTHER-3"}},
+		{"project = DEMO AND key > DEMO-2", []string{"DEMO-10"}},
+		{"assignee = alice", []string{"DEMO-1"}},
+		{"assignee = Alice", []string{"DEMO-1"}},
+		{"updated >= -2d", []string{"DEMO-2", "DEMO-10"}},
+		{"created > -1w", []string{"DEMO-10"}},
+		{"updated < 2025-02-15", []string{"OTHER-3"}},
+		{"sprint = \"Sprint 1\"", []string{"DEMO-1", "DEMO-2"}},
+
+		// Dates match exactly, against every date of an IN list; issues
+		// without the date match neither = nor !=. DEMO-10 was created 6h
+		// before now, DEMO-1 1538h and DEMO-2 1514h.
+		{"created in (-6h, -1538h)", []string{"DEMO-1", "DEMO-10"}},
+		{"created = -1514h", []string{"DEMO-2"}},
+		{"created != -6h", []string{"DEMO-1", "DEMO-2", "OTHER-3"}},
+		{"created not in (-6h, -1538h)", []string{"DEMO-2", "OTHER-3"}},
+		{"resolved != -6h", []string{}},
+		{"resolved not in (-6h, -1538h)", []string{}},
+
+		// Sprint functions match on the state of any of an issue's sprints.
+		{"sprint in openSprints()", []string{"DEMO-2", "DEMO-10"}},
+		{"sprint in closedSprints()", []string{"DEMO-1", "DEMO-2"}},
+		{"sprint in futureSprints()", []string{"DEMO-10"}},
+		{"sprint not in openSprints()", []string{"DEMO-1", "OTHER-3"}},
+		{"sprint in OPENSPRINTS()", []string{"DEMO-2", "DEMO-10"}},
+
+		// AND binds tighter than OR, NOT tighter than both.
+		{"status = Done OR labels = api AND project = OTHER", []string{"DEMO-1", "OTHER-3"}},
+		{"(status = Done OR labels = api) AND project = OTHER", []string{"OTHER-3"}},
+		{"project = OTHER AND labels = api OR status = Done", []string{"DEMO-1", "OTHER-3"}},
+		{"NOT status = Done AND project = DEMO", []string{"DEMO-2", "DEMO-10"}},
+		{"NOT (status = Done AND project = DEMO)", []string{"DEMO-2", "DEMO-10", "OTHER-3"}},
+		{"not not project = OTHER", []string{"OTHER-3"}},
+		{"project = demo and status = New or key = DEMO-1", []string{"DEMO-1", "DEMO-10"}},
+	} {
+		q, err := ParseAt(tc.query, now)
+		if err != nil {
+			t.Errorf("%s: %v", tc.query, err)
+			continue
+		}
+		if got := keys(q.Filter(issues)); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.query, got, tc.want)
+		}
+	}
+}
+
+func TestParseOrderBy(t *testing.T) {
+	q, err := Parse("project = DEMO order by StoryPoints desc, issuekey ASC, updated")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []OrderBy{{Field: "points", Desc: true}, {Field: "key"}, {Field: "updated"}}
+	if !reflect.DeepEqual(q.OrderBy, want) {
+		t.Errorf("got %+v, want %+v", q.OrderBy, want)
+	}
+	if q, err = Parse("ORDER BY key"); err != nil || len(q.OrderBy) != 1 || q.Where != (matchAll{}) {
+		t.Errorf("got %+v, %v for a query of only ORDER BY", q, err)
+	}
+}
+
+func TestSort(t *testing.T) {
+	issues := testIssues(t)
+	for _, tc := range []struct {
+		query string
+		want  []string
+	}{
+		// Keys of a project sort by number, not as text.
+		{"ORDER BY key", []string{"DEMO-1", "DEMO-2", "DEMO-10", "OTHER-3"}},
+		{"ORDER BY key DESC", []string{"OTHER-3", "DEMO-10", "DEMO-2", "DEMO-1"}},
+		{"ORDER BY updated", []string{"OTHER-3", "DEMO-1", "DEMO-2", "DEMO-10"}},
+		{"ORDER BY created DESC", []string{"DEMO-10", "DEMO-2", "DEMO-1", "OTHER-3"}},
+		// Issues without points sort as zero.
+		{"ORDER BY points DESC", []string{"DEMO-2", "DEMO-1", "OTHER-3", "DEMO-10"}},
+		// Later terms break ties of earlier ones; without a term the
+		// input order is kept.
+		{"ORDER BY status, key DESC", []string{"DEMO-1", "DEMO-2", "OTHER-3", "DEMO-10"}},
+		{"ORDER BY status", []string{"DEMO-1", "DEMO-2", "DEMO-10", "OTHER-3"}},
+		{"ORDER BY project DESC, points", []string{"OTHER-3", "DEMO-10", "DEMO-1", "DEMO-2"}},
+		// Multi-valued fields sort by their joined values.
+		{"ORDER BY labels", []string{"DEMO-10", "OTHER-3", "DEMO-2", "DEMO-1"}},
+		{"project = DEMO ORDER BY key DESC", []string{"DEMO-10", "DEMO-2", "DEMO-1"}},
+	} {
+		q, err := ParseAt(tc.query, now)
+		if err != nil {
+			t.Errorf("%s: %v", tc.query, err)
+			continue
+		}
+		if got := keys(q.Filter(issues)); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.query, got, tc.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, tc := range []struct {
+		query string
+		want  string
+	}{
+		{"status", "expected operator after status"},
+		{"status =", "expected value"},
+		{"colour = red", `unknown field "colour"`},
+		{"status = Done AND", "expected field name"},
+		{"(status = Done", "expected )"},
+		{"labels in (a, b", "expected ) to close IN list"},
+		{"labels in a", "expected ( after IN"},
+		{"labels not a", "expected IN after NOT"},
+		{"labels is done", "expected EMPTY"},
+		{"status = Done ORDER key", "expected BY after ORDER"},
+		{"ORDER BY colour", `unknown field "colour"`},
+		{"ORDER BY", "expected field after ORDER BY"},
+		{"points ~ 3", "operator ~ not supported on points"},
+		{"points = many", `invalid number "many" for points`},
+		{"updated > yesterday", `invalid date "yesterday"`},
+		{"updated > -3y", `invalid relative date "-3y"`},
+		{"status = Done status = New", `unexpected "status"`},
+		{`summary ~ "open`, "unterminated string"},
+		{"status ! Done", "unexpected '!'"},
+	} {
+		_, err := ParseAt(tc.query, now)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: got error %v, want %s", tc.query, err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package render
+
+import (
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"strings"
+)
+
+// Series is one line of a LineChart.
+type Series struct {
+	Name   string
+	Color  string // #rrggbb
+	Dashed bool
+	Values []float64
+}
+
+// Annotation marks an event at one x position of a chart.
+type Annotation struct {
+	Index int
+	Label string
+	Color string // #rrggbb, grey when empty
+}
+
+// LineChart is a simple multi-series line chart over labelled x positions.
+type LineChart struct {
+	Title       string
+	Labels      []string
+	Series      []Series
+	Annotations []Annotation
+	Width       int
+	Height      int
+}
+
+func (a Annotation) color() string {
+	if a.Color == "" {
+		return "#7f7f7f"
+	}
+	return a.Color
+}
+
+const (
+	chartMarginLeft   = 60
+	chartMarginRight  = 150
+	chartMarginTop    = 40
+	chartMarginBottom = 60
+)
+
+func (c *LineChart) size() (int, int) {
+	w, h := c.Width, c.Height
+	if w == 0 {
+		w = 900
+	}
+	if h == 0 {
+		h = 450
+	}
+	return w, h
+}
+
+func (c *LineChart) maxValue() float64 {
+	max := 0.0
+	for _, s := range c.Series {
+		for _, v := range s.Values {
+			if v > max {
+				max = v
+			}
+		}
+	}
+	if max == 0 {
+		return 1
+	}
+	return max
+}
+
+// point maps a value index and value to pixel coordinates.
+func (c *LineChart) point(i int, v, max float64) (float64, float64) {
+	w, h := c.size()
+	plotW := float64(w - chartMarginLeft - chartMarginRight)
+	plotH := float64(h - chartMarginTop - chartMarginBottom)
+	x := float64(chartMarginLeft)
+	if len(c.Labels) > 1 {
+		x += plotW * float64(i) / float64(len(c.Labels)-1)
+	}
+	y := float64(chartMarginTop) + plotH*(1-v/max)
+	return x, y
+}
+
+// WriteSVG renders the chart as SVG.
+func (c *LineChart) WriteSVG(w io.Writer) error {
+	width, height := c.size()
+	max := c.maxValue()
+	var b strings.Builder
+
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" font-family="sans-serif" font-size="12">`+"\n", width, height)
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="white"/>`+"\n", width, height)
+	if c.Title != "" {
+		fmt.Fprintf(&b, `<text x="%d" y="24" font-size="16">%s</text>`+"\n", chartMarginLeft, html.EscapeString(c.Title))
+	}
+
+	// axes and horizontal grid lines
+	x0, y0 := c.point(0, 0, max)
+	xN, _ := c.point(len(c.Labels)-1, 0, max)
+	for i := 0; i <= 4; i++ {
+		v := max * float64(i) / 4
+		_, y := c.point(0, v, max)
+		fmt.Fprintf(&b, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="#ddd"/>`+"\n", x0, y, xN, y)
+		fmt.Fprintf(&b, `<text x="%.1f" y="%.1f" text-anchor="end">%.0f</text>`+"\n", x0-6, y+4, v)
+	}
+	fmt.Fprintf(&b, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="black"/>`+"\n", x0, y0, xN, y0)
+
+	step := 1
+	if len(c.Labels) > 15 {
+		step = (len(c.Labels) + 14) / 15
+	}
+	for i := 0; i < len(c.Labels); i += step {
+		x, _ := c.point(i, 0, max)
+		fmt.Fprintf(&b, `<text x="%.1f" y="%.1f" text-anchor="end" transform="rotate(-45 %.1f %.1f)">%s</text>`+"\n", x, y0+16, x, y0+16, html.EscapeString(c.Labels[i]))
+	}
+
+	// annotations: a dotted line per event, labels stacked when several
+	// events share a position
+	stacked := map[int]int{}
+	for _, a := range c.Annotations {
+		if a.Index < 0 || a.Index >= len(c.Labels) {
+			continue
+		}
+		x, _ := c.point(a.Index, 0, max)
+		fmt.Fprintf(&b, `<line x1="%.1f" y1="%d" x2="%.1f" y2="%.1f" stroke="%s" stroke-dasharray="2 3"/>`+"\n", x, chartMarginTop, x, y0, a.color())
+		ty := chartMarginTop + 4 + 14*stacked[a.Index]
+		stacked[a.Index]++
+		fmt.Fprintf(&b, `<text x="%.1f" y="%d" fill="%s" font-size="10">%s</text>`+"\n", x+3, ty+6, a.color(), html.EscapeString(a.Label))
+	}
+
+	for si, s := range c.Series {
+		var points []string
+		for i, v := range s.Values {
+			x, y := c.point(i, v, max)
+			points = append(points, fmt.Sprintf("%.1f,%.1f", x, y))
+		}
+		dash := ""
+		if s.Dashed {
+			dash = ` stroke-dasharray="6 4"`
+		}
+		fmt.Fprintf(&b, `<polyline fill="none" stroke="%s" stroke-width="2"%s points="%s"/>`+"\n", s.Color, dash, strings.Join(points, " "))
+
+		ly := chartMarginTop + 20*si
+		lx := width - chartMarginRight + 15
+		fmt.Fprintf(&b, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="%s" stroke-width="2"%s/>`+"\n", lx, ly, lx+20, ly, s.Color, dash)
+		fmt.Fprintf(&b, `<text x="%d" y="%d">%s</text>`+"\n", lx+26, ly+4, html.EscapeString(s.Name))
+	}
+	b.WriteString("</svg>\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func parseHexColor(s string) color.RGBA {
+	var r, g, b uint8
+	if _, err := fmt.Sscanf(strings.TrimPrefix(s, "#"), "%02x%02x%02x", &r, &g, &b); err != nil {
+		return color.RGBA{A: 255}
+	}
+	return color.RGBA{R: r, G: g, B: b, A: 255}
+}
+
+func drawLine(img *image.RGBA, x0, y0, x1, y1 float64, c color.RGBA, dashed bool) {
+	dx, dy := x1-x0, y1-y0
+	steps := int(math.Max(math.Abs(dx), math.Abs(dy)))
+	if steps == 0 {
+		img.SetRGBA(int(x0), int(y0), c)
+		return
+	}
+	for i := 0; i <= steps; i++ {
+		if dashed && (i/6)%2 == 1 {
+			continue
+		}
+		x := x0 + dx*float64(i)/float64(steps)
+		y := y0 + dy*float64(i)/float64(steps)
+		img.SetRGBA(int(x), int(y), c)
+		img.SetRGBA(int(x), int(y)+1, c)
+	}
+}
+
+// WritePNG renders the chart lines and axes as PNG. Text is only drawn in
+// the SVG output.
+func (c *LineChart) WritePNG(w io.Writer) error {
+	width, height := c.size()
+	max := c.maxValue()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for i := range img.Pix {
+		img.Pix[i] = 255
+	}
+
+	grid := color.RGBA{R: 221, G: 221, B: 221, A: 255}
+	x0, y0 := c.point(0, 0, max)
+	xN, _ := c.point(len(c.Labels)-1, 0, max)
+	for i := 1; i <= 4; i++ {
+		_, y := c.point(0, max*float64(i)/4, max)
+		drawLine(img, x0, y, xN, y, grid, false)
+	}
+	drawLine(img, x0, y0, xN, y0, color.RGBA{A: 255}, false)
+	drawLine(img, x0, y0, x0, float64(chartMarginTop), color.RGBA{A: 255}, false)
+	for _, a := range c.Annotations {
+		if a.Index < 0 || a.Index >= len(c.Labels) {
+			continue
+		}
+		x, _ := c.point(a.Index, 0, max)
+		drawLine(img, x, float64(chartMarginTop), x, y0, parseHexColor(a.color()), true)
+	}
+
+	for si, s := range c.Series {
+		col := parseHexColor(s.Color)
+		for i := 1; i < len(s.Values); i++ {
+			ax, ay := c.point(i-1, s.Values[i-1], max)
+			bx, by := c.point(i, s.Values[i], max)
+			drawLine(img, ax, ay, bx, by, col, s.Dashed)
+		}
+		// legend swatch
+		ly := float64(chartMarginTop + 20*si)
+		lx := float64(width - chartMarginRight + 15)
+		drawLine(img, lx, ly, lx+20, ly, col, s.Dashed)
+	}
+
+	return png.Encode(w, img)
+}
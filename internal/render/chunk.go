@@ -0,0 +1,334 @@
+package render
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// ManifestSuffix names the chunk manifest written next to chunked output.
+const ManifestSuffix = ".manifest.json"
+
+// Compressor wraps output files in a compressing writer.
+type Compressor struct {
+	// Ext is appended to the names of compressed files, such as ".gz".
+	Ext string
+	New func(w io.Writer) (io.WriteCloser, error)
+}
+
+var compressors = map[string]Compressor{
+	"gzip": {Ext: ".gz", New: func(w io.Writer) (io.WriteCloser, error) { return gzip.NewWriter(w), nil }},
+	"zstd": {Ext: ".zst", New: func(w io.Writer) (io.WriteCloser, error) { return zstd.NewWriter(w) }},
+}
+
+// LookupCompressor returns the named compressor, or nil for "" and
+// "none", for outputs that compress internally such as Parquet pages.
+func LookupCompressor(name string) (*Compressor, error) {
+	return compressor(name)
+}
+
+// compressor returns the named compressor; "" and "none" mean none.
+func compressor(name string) (*Compressor, error) {
+	if name == "" || name == "none" {
+		return nil, nil
+	}
+	if c, ok := compressors[name]; ok {
+		return &c, nil
+	}
+	var names []string
+	for n := range compressors {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return nil, fmt.Errorf("unknown compression %q (have none, %s)", name, strings.Join(names, ", "))
+}
+
+// ChunkOptions splits large outputs into numbered files and compresses
+// them, so downstream loaders can ingest the pieces incrementally.
+type ChunkOptions struct {
+	// Rows and Bytes cap each chunk; zero means no limit. Bytes counts
+	// uncompressed output, header included. A chunk holds at least one row.
+	Rows  int
+	Bytes int64
+	// Compress names the compression format: none, gzip or zstd.
+	Compress string
+}
+
+// Chunked reports whether output is split into chunks.
+func (c ChunkOptions) Chunked() bool {
+	return c.Rows > 0 || c.Bytes > 0
+}
+
+// AddChunkFlags registers -chunk-rows, -chunk-bytes and -compress.
+func AddChunkFlags(fs *flag.FlagSet, c *ChunkOptions) {
+	fs.IntVar(&c.Rows, "chunk-rows", 0, "Split --out into numbered files of at most this many rows, listed in a .manifest.json (0 for no limit)")
+	fs.Int64Var(&c.Bytes, "chunk-bytes", 0, "Split --out into numbered files of at most this many uncompressed bytes (0 for no limit)")
+	fs.StringVar(&c.Compress, "compress", "none", "Compress output: none, gzip or zstd")
+}
+
+// Chunk describes one file of chunked output.
+type Chunk struct {
+	File string `json:"file"`
+	// FirstRow is the zero-based index of the chunk's first row in the
+	// whole output.
+	FirstRow int    `json:"firstRow"`
+	Rows     int    `json:"rows"`
+	Bytes    int64  `json:"bytes"`
+	SHA256   string `json:"sha256"`
+}
+
+// ChunkManifest lists the chunks of an output. It is rewritten as each
+// chunk is finished, with Complete set once the last one is, so loaders
+// may start on the listed chunks while the export is still running.
+type ChunkManifest struct {
+	Format      string      `json:"format"`
+	Compression string      `json:"compression,omitempty"`
+	Complete    bool        `json:"complete"`
+	Rows        int         `json:"rows"`
+	Chunks      []Chunk     `json:"chunks"`
+	Provenance  *Provenance `json:"provenance,omitempty"`
+}
+
+// ChunkWriter writes records to a sequence of chunk files named
+// <stem>-00001<ext>, each starting with the same header.
+type ChunkWriter struct {
+	opts     ChunkOptions
+	comp     *Compressor
+	stem     string
+	ext      string
+	header   []byte
+	manifest ChunkManifest
+	options  Options
+
+	file    *os.File
+	zw      io.WriteCloser
+	w       io.Writer
+	sum     hash.Hash
+	written *countingWriter
+	chunk   Chunk
+	bytes   int64
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// NewChunkWriter starts chunked output for path, whose extension gives the
+// format of the chunks. header is repeated at the top of every chunk.
+func (o Options) NewChunkWriter(path string, header []byte) (*ChunkWriter, error) {
+	comp, err := compressor(o.Chunk.Compress)
+	if err != nil {
+		return nil, err
+	}
+	ext := filepath.Ext(path)
+	cw := &ChunkWriter{
+		opts:    o.Chunk,
+		comp:    comp,
+		stem:    strings.TrimSuffix(path, ext),
+		ext:     ext,
+		header:  header,
+		options: o,
+		manifest: ChunkManifest{
+			Format: strings.TrimPrefix(ext, "."),
+			Chunks: []Chunk{},
+		},
+	}
+	if comp != nil {
+		cw.manifest.Compression = o.Chunk.Compress
+	}
+	return cw, nil
+}
+
+// ManifestPath is where the manifest of the chunked output is written.
+func (c *ChunkWriter) ManifestPath() string {
+	return c.stem + ManifestSuffix
+}
+
+// WriteRecord writes one encoded row, starting a new chunk first when the
+// current one is full.
+func (c *ChunkWriter) WriteRecord(record []byte) error {
+	if c.file != nil && c.full(len(record)) {
+		if err := c.finish(); err != nil {
+			return err
+		}
+	}
+	if c.file == nil {
+		if err := c.start(); err != nil {
+			return err
+		}
+	}
+	if _, err := c.w.Write(record); err != nil {
+		return err
+	}
+	c.bytes += int64(len(record))
+	c.chunk.Rows++
+	c.manifest.Rows++
+	return nil
+}
+
+func (c *ChunkWriter) full(next int) bool {
+	if c.chunk.Rows == 0 {
+		return false
+	}
+	if c.opts.Rows > 0 && c.chunk.Rows >= c.opts.Rows {
+		return true
+	}
+	return c.opts.Bytes > 0 && c.bytes+int64(next) > c.opts.Bytes
+}
+
+func (c *ChunkWriter) start() error {
+	name := fmt.Sprintf("%s-%05d%s", c.stem, len(c.manifest.Chunks)+1, c.ext)
+	if c.comp != nil {
+		name += c.comp.Ext
+	}
+	f, err := os.Create(name)
+	if err != nil {
+		return fmt.Errorf("create chunk: %w", err)
+	}
+	c.file = f
+	c.sum = sha256.New()
+	c.written = &countingWriter{w: io.MultiWriter(f, c.sum)}
+	c.w = c.written
+	c.zw = nil
+	if c.comp != nil {
+		if c.zw, err = c.comp.New(c.written); err != nil {
+			f.Close()
+			return err
+		}
+		c.w = c.zw
+	}
+	c.chunk = Chunk{File: filepath.Base(name), FirstRow: c.manifest.Rows}
+	c.bytes = int64(len(c.header))
+	_, err = c.w.Write(c.header)
+	return err
+}
+
+// finish closes the current chunk and records it in the manifest.
+func (c *ChunkWriter) finish() error {
+	if c.zw != nil {
+		if err := c.zw.Close(); err != nil {
+			c.file.Close()
+			return err
+		}
+	}
+	if err := c.file.Close(); err != nil {
+		return err
+	}
+	c.chunk.Bytes = c.written.n
+	c.chunk.SHA256 = hex.EncodeToString(c.sum.Sum(nil))
+	c.manifest.Chunks = append(c.manifest.Chunks, c.chunk)
+	log.Printf("wrote %s (%d rows)", c.chunk.File, c.chunk.Rows)
+	c.file = nil
+	return c.writeManifest()
+}
+
+// Close finishes the last chunk and marks the manifest complete. An output
+// without rows still gets one chunk holding the header.
+func (c *ChunkWriter) Close() error {
+	if c.file == nil && len(c.manifest.Chunks) == 0 {
+		if err := c.start(); err != nil {
+			return err
+		}
+	}
+	if c.file != nil {
+		if err := c.finish(); err != nil {
+			return err
+		}
+	}
+	c.manifest.Complete = true
+	if c.options.Provenance != ProvenanceNone {
+		p := c.options.provenance(c.manifest.Rows)
+		c.manifest.Provenance = &p
+	}
+	return c.writeManifest()
+}
+
+// writeManifest replaces the manifest in one rename so a loader polling it
+// never reads a partial file.
+func (c *ChunkWriter) writeManifest() error {
+	data, err := json.MarshalIndent(c.manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := c.ManifestPath()
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// csvHeader encodes what starts every CSV chunk: the byte order mark,
+// provenance comment and header row, as chosen by the options.
+func (o Options) csvHeader(t *Table) ([]byte, error) {
+	var buf bytes.Buffer
+	format := o.CSV
+	if o.Provenance == ProvenanceComment {
+		if format.BOM {
+			buf.WriteString("\uFEFF")
+			format.BOM = false
+		}
+		buf.WriteString(o.provenance(len(t.Rows)).comment())
+	}
+	if err := format.Write(&buf, &Table{Headers: t.Headers}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeChunks writes the table as chunked CSV under path.
+func (o Options) writeChunks(path string, t *Table) error {
+	header, err := o.csvHeader(t)
+	if err != nil {
+		return err
+	}
+	cw, err := o.NewChunkWriter(path, header)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	writer.Comma = o.CSV.delimiter()
+	numeric := t.numericColumns()
+	row := make([]string, 0, len(t.Headers))
+	for _, r := range t.Rows {
+		row = row[:0]
+		for i, c := range r {
+			row = append(row, o.CSV.cell(c, i < len(numeric) && numeric[i]))
+		}
+		buf.Reset()
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+		writer.Flush()
+		if err := cw.WriteRecord(buf.Bytes()); err != nil {
+			return err
+		}
+	}
+	if err := cw.Close(); err != nil {
+		return err
+	}
+	log.Printf("wrote %d rows in %d chunks, listed in %s", cw.manifest.Rows, len(cw.manifest.Chunks), cw.ManifestPath())
+	return nil
+}
@@ -0,0 +1,299 @@
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Output formats for the --format flag.
+const (
+	FormatCSV      = "csv"
+	FormatTSV      = "tsv"
+	FormatJSON     = "json"
+	FormatJSONL    = "jsonl"
+	FormatMarkdown = "markdown"
+	FormatHTML     = "html"
+)
+
+// Format is a way of writing tables out.
+type Format struct {
+	// Exts are the --out extensions that select the format when --format
+	// is not given, such as ".md".
+	Exts []string
+	// Write writes the table, with the title, notes and provenance the
+	// format has room for.
+	Write func(o Options, w io.Writer, t *Table) error
+}
+
+var formats = map[string]Format{
+	FormatCSV:      {Exts: []string{".csv"}, Write: writeCSV},
+	FormatTSV:      {Exts: []string{".tsv", ".tab"}, Write: writeTSV},
+	FormatJSON:     {Exts: []string{".json"}, Write: writeJSON},
+	FormatJSONL:    {Exts: []string{".jsonl", ".ndjson"}, Write: writeJSONL},
+	FormatMarkdown: {Exts: []string{".md", ".markdown"}, Write: writeMarkdown},
+	FormatHTML:     {Exts: []string{".html", ".htm"}, Write: Options.WriteHTML},
+}
+
+// RegisterFormat makes an output format available to --format.
+func RegisterFormat(name string, f Format) {
+	formats[name] = f
+}
+
+// Formats lists the accepted output formats.
+func Formats() []string {
+	names := make([]string, 0, len(formats))
+	for name := range formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+type formatFlag struct{ s *string }
+
+func (f formatFlag) String() string {
+	if f.s == nil {
+		return ""
+	}
+	return *f.s
+}
+
+func (f formatFlag) Set(s string) error {
+	s = strings.ToLower(s)
+	if s == "md" {
+		s = FormatMarkdown
+	}
+	if _, ok := formats[s]; !ok {
+		return fmt.Errorf("must be one of %s", strings.Join(Formats(), ", "))
+	}
+	*f.s = s
+	return nil
+}
+
+// format is the selected output format: --format, or the one the --out
+// extension names, or CSV.
+func (o Options) format() string {
+	if o.Format != "" {
+		return o.Format
+	}
+	ext := strings.ToLower(filepath.Ext(o.Out))
+	for name, f := range formats {
+		for _, e := range f.Exts {
+			if e == ext {
+				return name
+			}
+		}
+	}
+	return FormatCSV
+}
+
+// OutputFormat returns the format the table will be written in, for
+// commands that also write documents of their own in some formats.
+func (o Options) OutputFormat() string {
+	return o.format()
+}
+
+// HTML reports whether the table is written as an HTML report, so commands
+// only compute the charts and notes that go into one when it is.
+func (o Options) HTML() bool {
+	return o.format() == FormatHTML
+}
+
+// writeCSV writes the table as CSV, preceded by the provenance comment in
+// comment mode (after the byte order mark, if any).
+func writeCSV(o Options, w io.Writer, t *Table) error {
+	format := o.CSV
+	if o.Provenance == ProvenanceComment {
+		if format.BOM {
+			if _, err := io.WriteString(w, "\uFEFF"); err != nil {
+				return err
+			}
+			format.BOM = false
+		}
+		if _, err := io.WriteString(w, o.provenance(len(t.Rows)).comment()); err != nil {
+			return err
+		}
+	}
+	return format.Write(w, t)
+}
+
+// tsvReplacer keeps a cell on its line and in its column: TSV has no
+// quoting, so tabs and line breaks become spaces.
+var tsvReplacer = strings.NewReplacer("\t", " ", "\r\n", " ", "\n", " ", "\r", " ")
+
+// writeTSV writes the table as tab separated values, as spreadsheets and
+// Unix tools read them without CSV quoting rules.
+func writeTSV(o Options, w io.Writer, t *Table) error {
+	var b strings.Builder
+	if o.Provenance == ProvenanceComment {
+		b.WriteString(o.provenance(len(t.Rows)).comment())
+	}
+	numeric := t.numericColumns()
+	line := func(cells []string, convert bool) {
+		for i, c := range cells {
+			if i > 0 {
+				b.WriteByte('\t')
+			}
+			if convert {
+				c = o.CSV.cell(c, i < len(numeric) && numeric[i])
+			}
+			b.WriteString(tsvReplacer.Replace(c))
+		}
+		b.WriteByte('\n')
+	}
+	line(t.Headers, false)
+	for _, r := range t.Rows {
+		line(r, true)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// plainNumber matches the cells written as JSON numbers; others, including
+// percentages, durations and empty cells, stay strings.
+var plainNumber = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?$`)
+
+// rowJSON encodes a row as an object keyed by the headers, in column
+// order.
+func rowJSON(headers, row []string) ([]byte, error) {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, h := range headers {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		key, err := json.Marshal(h)
+		if err != nil {
+			return nil, err
+		}
+		b.Write(key)
+		b.WriteByte(':')
+		cell := ""
+		if i < len(row) {
+			cell = row[i]
+		}
+		if plainNumber.MatchString(cell) {
+			b.WriteString(cell)
+			continue
+		}
+		value, err := json.Marshal(cell)
+		if err != nil {
+			return nil, err
+		}
+		b.Write(value)
+	}
+	b.WriteByte('}')
+	return []byte(b.String()), nil
+}
+
+// writeJSONL writes one JSON object per row. A comment line would not be
+// JSON, so provenance goes in the sidecar only.
+func writeJSONL(o Options, w io.Writer, t *Table) error {
+	if o.Provenance == ProvenanceComment {
+		return fmt.Errorf("--provenance comment cannot be combined with --format jsonl; use sidecar")
+	}
+	for _, r := range t.Rows {
+		line, err := rowJSON(t.Headers, r)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(append(line, '\n')); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeJSON writes the rows as one JSON array of objects.
+func writeJSON(o Options, w io.Writer, t *Table) error {
+	if o.Provenance == ProvenanceComment {
+		return fmt.Errorf("--provenance comment cannot be combined with --format json; use sidecar")
+	}
+	var b strings.Builder
+	b.WriteString("[")
+	for i, r := range t.Rows {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		line, err := rowJSON(t.Headers, r)
+		if err != nil {
+			return err
+		}
+		b.WriteString("\n  ")
+		b.Write(line)
+	}
+	if len(t.Rows) > 0 {
+		b.WriteString("\n")
+	}
+	b.WriteString("]\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+var markdownReplacer = strings.NewReplacer("|", `\|`, "\r\n", "<br>", "\n", "<br>", "\r", "<br>")
+
+// writeMarkdown writes a GitHub-flavored Markdown table, under the title
+// and notes when the command set them, ready to paste into a document.
+// Numeric columns are right-aligned.
+func writeMarkdown(o Options, w io.Writer, t *Table) error {
+	var b strings.Builder
+	if o.Provenance == ProvenanceComment {
+		fmt.Fprintf(&b, "<!-- %s -->\n\n", strings.TrimSuffix(strings.TrimPrefix(o.provenance(len(t.Rows)).comment(), "# "), "\n"))
+	}
+	if o.Title != "" {
+		fmt.Fprintf(&b, "## %s\n\n", markdownReplacer.Replace(o.Title))
+	}
+	for _, n := range o.Notes {
+		fmt.Fprintf(&b, "- %s\n", markdownReplacer.Replace(n))
+	}
+	if len(o.Notes) > 0 {
+		b.WriteString("\n")
+	}
+	numeric := make([]bool, len(t.Headers))
+	for i := range t.Headers {
+		numeric[i] = numericColumn(t, i)
+	}
+	line := func(cells []string) {
+		b.WriteString("|")
+		for _, c := range cells {
+			b.WriteString(" " + markdownReplacer.Replace(c) + " |")
+		}
+		b.WriteString("\n")
+	}
+	line(t.Headers)
+	b.WriteString("|")
+	for i := range t.Headers {
+		if numeric[i] {
+			b.WriteString(" ---: |")
+		} else {
+			b.WriteString(" --- |")
+		}
+	}
+	b.WriteString("\n")
+	for _, r := range t.Rows {
+		line(r)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// numericColumn reports whether every non-empty cell of a column is a
+// number, and one at least is.
+func numericColumn(t *Table, col int) bool {
+	seen := false
+	for _, r := range t.Rows {
+		if col >= len(r) || r[col] == "" {
+			continue
+		}
+		if !plainNumber.MatchString(strings.TrimSuffix(r[col], "%")) {
+			return false
+		}
+		seen = true
+	}
+	return seen
+}
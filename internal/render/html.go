@@ -0,0 +1,117 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// AddChart embeds a chart above the table of an HTML report. Other formats
+// ignore it.
+func (o *Options) AddChart(c *LineChart) {
+	o.Charts = append(o.Charts, c)
+}
+
+// AddNote adds a line to the summary at the top of an HTML report, such as
+// the totals a command also logs. Other formats ignore it.
+func (o *Options) AddNote(format string, args ...interface{}) {
+	o.Notes = append(o.Notes, fmt.Sprintf(format, args...))
+}
+
+type htmlReport struct {
+	Title      string
+	Notes      []string
+	Charts     []template.HTML
+	Headers    []string
+	Rows       [][]htmlCell
+	Provenance string
+}
+
+type htmlCell struct {
+	Text    string
+	Numeric bool
+}
+
+var htmlTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+h1 { font-size: 1.4em; }
+ul.notes { padding-left: 1.2em; }
+figure { margin: 1em 0; }
+figure svg { max-width: 100%; height: auto; }
+table { border-collapse: collapse; font-size: 0.9em; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; vertical-align: top; }
+th { background: #f0f0f0; }
+tr:nth-child(even) td { background: #fafafa; }
+td.num { text-align: right; font-variant-numeric: tabular-nums; }
+footer { margin-top: 2em; font-size: 0.8em; color: #777; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+{{- if .Notes}}
+<ul class="notes">
+{{- range .Notes}}
+<li>{{.}}</li>
+{{- end}}
+</ul>
+{{- end}}
+{{- range .Charts}}
+<figure>
+{{.}}</figure>
+{{- end}}
+<table>
+<thead><tr>{{range .Headers}}<th>{{.}}</th>{{end}}</tr></thead>
+<tbody>
+{{- range .Rows}}
+<tr>{{range .}}<td{{if .Numeric}} class="num"{{end}}>{{.Text}}</td>{{end}}</tr>
+{{- end}}
+</tbody>
+</table>
+<p>{{len .Rows}} rows</p>
+{{- if .Provenance}}
+<footer>{{.Provenance}}</footer>
+{{- end}}
+</body>
+</html>
+`))
+
+// WriteHTML writes the table as a self-contained HTML page: the title and
+// notes, the charts as inline SVG, then the table. No scripts, styles or
+// images are loaded from elsewhere, so the file can be mailed as is.
+func (o Options) WriteHTML(w io.Writer, t *Table) error {
+	report := htmlReport{Title: o.Title, Notes: o.Notes, Headers: t.Headers}
+	p := o.provenance(len(t.Rows))
+	if report.Title == "" {
+		report.Title = p.Report
+	}
+	if o.Provenance != ProvenanceNone {
+		report.Provenance = strings.TrimSuffix(strings.TrimPrefix(p.comment(), "# "), "\n")
+	}
+	for _, c := range o.Charts {
+		if len(c.Labels) == 0 {
+			continue
+		}
+		var buf bytes.Buffer
+		if err := c.WriteSVG(&buf); err != nil {
+			return err
+		}
+		report.Charts = append(report.Charts, template.HTML(buf.String()))
+	}
+	for _, r := range t.Rows {
+		row := make([]htmlCell, len(r))
+		for i, c := range r {
+			_, err := strconv.ParseFloat(strings.TrimSuffix(c, "%"), 64)
+			row[i] = htmlCell{Text: c, Numeric: err == nil}
+		}
+		report.Rows = append(report.Rows, row)
+	}
+	return htmlTemplate.Execute(w, report)
+}
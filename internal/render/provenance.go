@@ -0,0 +1,209 @@
+package render
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Provenance modes for the --provenance flag.
+const (
+	ProvenanceSidecar = "sidecar"
+	ProvenanceComment = "comment"
+	ProvenanceNone    = "none"
+)
+
+// Statement types of the sidecar, following the in-toto attestation layout.
+const (
+	inTotoStatementType = "https://in-toto.io/Statement/v1"
+	ReportPredicateType = "https://github.com/jctanner/rhoai-jira/report-provenance/v1"
+)
+
+// Provenance records how an output file was produced so numbers can be
+// traced back to the data and parameters behind them.
+type Provenance struct {
+	Tool         string            `json:"tool"`
+	Version      string            `json:"version"`
+	Revision     string            `json:"revision,omitempty"`
+	Report       string            `json:"report"`
+	Parameters   map[string]string `json:"parameters,omitempty"`
+	Args         []string          `json:"args,omitempty"`
+	CacheVersion string            `json:"cacheVersion,omitempty"`
+	GeneratedAt  string            `json:"generatedAt"`
+	Rows         *int              `json:"rows,omitempty"`
+}
+
+type provenanceSubject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+type provenanceStatement struct {
+	Type          string              `json:"_type"`
+	Subject       []provenanceSubject `json:"subject"`
+	PredicateType string              `json:"predicateType"`
+	Predicate     Provenance          `json:"predicate"`
+}
+
+// ToolVersion reports the module version and VCS revision of the binary.
+func ToolVersion() (version, revision string) {
+	version = "(devel)"
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return version, ""
+	}
+	if info.Main.Version != "" {
+		version = info.Main.Version
+	}
+	modified := false
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			revision = s.Value
+		case "vcs.modified":
+			modified = s.Value == "true"
+		}
+	}
+	if revision != "" && modified {
+		revision += "-dirty"
+	}
+	return version, revision
+}
+
+type provenanceFlag struct{ s *string }
+
+func (p provenanceFlag) String() string {
+	if p.s == nil {
+		return ""
+	}
+	return *p.s
+}
+
+func (p provenanceFlag) Set(s string) error {
+	switch s {
+	case ProvenanceSidecar, ProvenanceComment, ProvenanceNone:
+		*p.s = s
+		return nil
+	}
+	return fmt.Errorf("must be %s, %s or %s", ProvenanceSidecar, ProvenanceComment, ProvenanceNone)
+}
+
+// Versioned is a data source whose version identifies its content, such as
+// a jira.Store.
+type Versioned interface {
+	Version() (string, error)
+}
+
+// SetSource records the version of the store a report reads from.
+func (o *Options) SetSource(src Versioned) {
+	if version, err := src.Version(); err == nil {
+		o.cacheVersion = version
+	}
+}
+
+// SetCacheVersion records the version of the cache the report was computed
+// from (see jira.Store.Version) for its provenance.
+func (o *Options) SetCacheVersion(version string) {
+	o.cacheVersion = version
+}
+
+// provenance describes the current invocation.
+func (o Options) provenance(rows int) Provenance {
+	version, revision := ToolVersion()
+	p := Provenance{
+		Tool:         "rhoai-jira",
+		Version:      version,
+		Revision:     revision,
+		CacheVersion: o.cacheVersion,
+		GeneratedAt:  time.Now().UTC().Format(time.RFC3339),
+	}
+	if rows >= 0 {
+		p.Rows = &rows
+	}
+	fs := o.flags
+	if fs == nil {
+		fs = flag.CommandLine
+	}
+	p.Report = fs.Name()
+	fs.Visit(func(f *flag.Flag) {
+		if strings.Contains(f.Name, "token") || strings.Contains(f.Name, "secret") || strings.Contains(f.Name, "password") {
+			return
+		}
+		if p.Parameters == nil {
+			p.Parameters = map[string]string{}
+		}
+		p.Parameters[f.Name] = f.Value.String()
+	})
+	p.Args = fs.Args()
+	return p
+}
+
+// comment renders the provenance as a single "# key=value" line.
+func (p Provenance) comment() string {
+	parts := []string{"tool=" + p.Tool, "version=" + p.Version}
+	if p.Revision != "" {
+		parts = append(parts, "revision="+p.Revision)
+	}
+	parts = append(parts, "report="+p.Report)
+	if p.CacheVersion != "" {
+		parts = append(parts, "cache="+p.CacheVersion)
+	}
+	parts = append(parts, "generated="+p.GeneratedAt)
+	var names []string
+	for name := range p.Parameters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf("-%s=%q", name, p.Parameters[name]))
+	}
+	for _, a := range p.Args {
+		parts = append(parts, fmt.Sprintf("%q", a))
+	}
+	return "# provenance: " + strings.Join(parts, " ") + "\n"
+}
+
+// writeTable writes the table in the selected format.
+func (o Options) writeTable(w io.Writer, t *Table) error {
+	return formats[o.format()].Write(o, w, t)
+}
+
+// WriteSidecar writes path.meta.json describing how the file at path was
+// produced, unless provenance is disabled. rows is negative for outputs
+// such as charts that are not tables.
+func (o Options) WriteSidecar(path string, rows int) error {
+	if o.Provenance == ProvenanceNone {
+		return nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	h := sha256.New()
+	_, err = io.Copy(h, f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+
+	statement := provenanceStatement{
+		Type:          inTotoStatementType,
+		Subject:       []provenanceSubject{{Name: filepath.Base(path), Digest: map[string]string{"sha256": hex.EncodeToString(h.Sum(nil))}}},
+		PredicateType: ReportPredicateType,
+		Predicate:     o.provenance(rows),
+	}
+	data, err := json.MarshalIndent(statement, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path+".meta.json", append(data, '\n'), 0o644)
+}
@@ -0,0 +1,380 @@
+package render
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Table is the tabular result of a report before it is written out.
+type Table struct {
+	Headers []string
+	Rows    [][]string
+
+	// Numeric holds the headers of the columns marked with SetNumeric.
+	Numeric map[string]bool `json:"numeric,omitempty"`
+}
+
+func NewTable(headers ...string) *Table {
+	return &Table{Headers: headers}
+}
+
+func (t *Table) Append(row ...string) {
+	t.Rows = append(t.Rows, row)
+}
+
+// SetNumeric marks columns as holding numbers. Only their cells are
+// written with a decimal comma; other columns, such as versions, are
+// written as they are.
+func (t *Table) SetNumeric(headers ...string) {
+	if t.Numeric == nil {
+		t.Numeric = map[string]bool{}
+	}
+	for _, h := range headers {
+		t.Numeric[h] = true
+	}
+}
+
+// numericColumns reports for each column whether SetNumeric marked it.
+func (t *Table) numericColumns() []bool {
+	numeric := make([]bool, len(t.Headers))
+	for i, h := range t.Headers {
+		numeric[i] = t.Numeric[h]
+	}
+	return numeric
+}
+
+func (t *Table) column(name string) int {
+	for i, h := range t.Headers {
+		if strings.EqualFold(h, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Options controls which rows of a table are written and where.
+type Options struct {
+	Out    string
+	Limit  int
+	Offset int
+	Sort   string
+
+	CacheResults bool
+
+	CSV CSVFormat
+
+	// Format is one of Formats; empty picks the one the --out extension
+	// names, or csv. Title and Notes appear in HTML and Markdown output,
+	// Charts in HTML reports only.
+	Format string
+	Title  string
+	Notes  []string
+	Charts []*LineChart
+
+	// Chunk splits and compresses large outputs.
+	Chunk ChunkOptions
+
+	// Provenance selects how output is stamped with its origin: a
+	// .meta.json sidecar next to --out files, a leading comment line, or
+	// nothing.
+	Provenance   string
+	cacheVersion string
+
+	// flags is the flag set the options were registered on; its other
+	// flags form part of the result cache key.
+	flags *flag.FlagSet
+}
+
+// CSVFormat controls locale-sensitive details of CSV output so the files
+// open cleanly in spreadsheet applications configured for other locales.
+type CSVFormat struct {
+	// Delimiter separates fields; zero means ',' or ';' with DecimalComma.
+	Delimiter rune
+	// DecimalComma writes the cells of numeric columns with ',' as the
+	// decimal separator.
+	DecimalComma bool
+	// BOM prefixes the output with a UTF-8 byte order mark for Excel.
+	BOM bool
+}
+
+type delimiterFlag struct{ r *rune }
+
+func (d delimiterFlag) String() string {
+	if d.r == nil || *d.r == 0 {
+		return ""
+	}
+	return string(*d.r)
+}
+
+func (d delimiterFlag) Set(s string) error {
+	switch strings.ToLower(s) {
+	case "tab", `\t`:
+		*d.r = '\t'
+		return nil
+	case "semicolon":
+		*d.r = ';'
+		return nil
+	case "comma":
+		*d.r = ','
+		return nil
+	}
+	runes := []rune(s)
+	if len(runes) != 1 || runes[0] == '"' || runes[0] == '\r' || runes[0] == '\n' {
+		return fmt.Errorf("delimiter must be a single character, tab, comma or semicolon")
+	}
+	*d.r = runes[0]
+	return nil
+}
+
+// AddExportFlags registers the output flags of commands that write records
+// rather than tables: -out, chunking, compression and provenance.
+func AddExportFlags(fs *flag.FlagSet, o *Options) {
+	o.flags = fs
+	fs.StringVar(&o.Out, "out", "", "Output file (omit to print to stdout)")
+	AddChunkFlags(fs, &o.Chunk)
+	o.Provenance = ProvenanceSidecar
+	fs.Var(provenanceFlag{&o.Provenance}, "provenance", "Record tool version, cache version and parameters: sidecar (.meta.json next to --out) or none")
+}
+
+// AddFlags registers the shared output flags on a flag set.
+func AddFlags(fs *flag.FlagSet, o *Options) {
+	o.flags = fs
+	fs.StringVar(&o.Out, "out", "", "Output file (omit to print to stdout)")
+	fs.IntVar(&o.Limit, "limit", 0, "Maximum number of rows to output (0 for all)")
+	fs.IntVar(&o.Offset, "offset", 0, "Number of rows to skip before output")
+	fs.StringVar(&o.Sort, "sort", "", "Sort rows by this column (prefix with - for descending)")
+	// Commands with a --format of their own keep it; their tables are
+	// still written in the format the --out extension names.
+	if fs.Lookup("format") == nil {
+		fs.Var(formatFlag{&o.Format}, "format", "Output format: "+strings.Join(Formats(), ", ")+"; html is a self-contained report with charts (default: from the --out extension, else csv)")
+	}
+	fs.Var(delimiterFlag{&o.CSV.Delimiter}, "delimiter", "CSV field delimiter: a single character, tab, comma or semicolon (default , or ; with --decimal-comma)")
+	fs.BoolVar(&o.CSV.DecimalComma, "decimal-comma", false, "Write decimal numbers with a comma separator")
+	fs.BoolVar(&o.CSV.BOM, "bom", false, "Prefix CSV output with a UTF-8 byte order mark (for Excel)")
+	AddChunkFlags(fs, &o.Chunk)
+	o.Provenance = ProvenanceSidecar
+	fs.Var(provenanceFlag{&o.Provenance}, "provenance", "Record tool version, cache version and parameters: sidecar (.meta.json next to --out), comment (leading # line) or none")
+}
+
+func lessCell(a, b string) bool {
+	af, aErr := strconv.ParseFloat(a, 64)
+	bf, bErr := strconv.ParseFloat(b, 64)
+	if aErr == nil && bErr == nil {
+		return af < bf
+	}
+	return a < b
+}
+
+// Apply sorts and pages the table rows in place.
+func (o Options) Apply(t *Table) error {
+	if o.Sort != "" {
+		name := strings.TrimPrefix(o.Sort, "-")
+		desc := strings.HasPrefix(o.Sort, "-")
+		col := t.column(name)
+		if col < 0 {
+			return fmt.Errorf("unknown sort column %q (have %s)", name, strings.Join(t.Headers, ", "))
+		}
+		sort.SliceStable(t.Rows, func(i, j int) bool {
+			a, b := t.Rows[i][col], t.Rows[j][col]
+			if desc {
+				return lessCell(b, a)
+			}
+			return lessCell(a, b)
+		})
+	}
+
+	if o.Offset < 0 || o.Limit < 0 {
+		return fmt.Errorf("--offset and --limit must not be negative")
+	}
+	if o.Offset >= len(t.Rows) {
+		t.Rows = nil
+	} else {
+		t.Rows = t.Rows[o.Offset:]
+	}
+	if o.Limit > 0 && o.Limit < len(t.Rows) {
+		t.Rows = t.Rows[:o.Limit]
+	}
+	return nil
+}
+
+// WriteCSV writes the table as CSV.
+func (t *Table) WriteCSV(w io.Writer) error {
+	return CSVFormat{}.Write(w, t)
+}
+
+func (f CSVFormat) delimiter() rune {
+	if f.Delimiter != 0 {
+		return f.Delimiter
+	}
+	if f.DecimalComma {
+		return ';'
+	}
+	return ','
+}
+
+// cell converts a plain decimal number in a numeric column, such as
+// "12.5", to "12,5". Cells of other columns are left alone, as are dates
+// and other dotted values.
+func (f CSVFormat) cell(s string, numeric bool) string {
+	if !f.DecimalComma || !numeric || !strings.Contains(s, ".") {
+		return s
+	}
+	if _, err := strconv.ParseFloat(s, 64); err != nil || strings.ContainsAny(s, "eEnN") {
+		return s
+	}
+	return strings.Replace(s, ".", ",", 1)
+}
+
+// Write writes a table as CSV in this format.
+func (f CSVFormat) Write(w io.Writer, t *Table) error {
+	if f.DecimalComma && f.delimiter() == ',' {
+		return fmt.Errorf("--decimal-comma cannot be combined with a comma delimiter")
+	}
+	if f.BOM {
+		if _, err := io.WriteString(w, "\uFEFF"); err != nil {
+			return err
+		}
+	}
+	writer := csv.NewWriter(w)
+	writer.Comma = f.delimiter()
+	if err := writer.Write(t.Headers); err != nil {
+		return err
+	}
+	numeric := t.numericColumns()
+	row := make([]string, 0, len(t.Headers))
+	for _, r := range t.Rows {
+		row = row[:0]
+		for i, c := range r {
+			row = append(row, f.cell(c, i < len(numeric) && numeric[i]))
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// OutputDir, when set, is where relative output file names are written.
+var OutputDir string
+
+// OutputPath places a relative output file name under OutputDir, creating
+// the directory as needed.
+func OutputPath(name string) (string, error) {
+	if OutputDir == "" || filepath.IsAbs(name) {
+		return name, nil
+	}
+	path := filepath.Join(OutputDir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", fmt.Errorf("create output directory: %w", err)
+	}
+	return path, nil
+}
+
+// compressedFile closes the compressor before the file under it.
+type compressedFile struct {
+	io.WriteCloser
+	file io.Closer
+}
+
+func (c compressedFile) Close() error {
+	err := c.WriteCloser.Close()
+	if c.file != nil {
+		if closeErr := c.file.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// Create opens the --out file, or stdout when there is none, compressed
+// as selected. The returned path is empty for stdout and carries the
+// compression extension otherwise.
+func (o Options) Create() (io.WriteCloser, string, error) {
+	comp, err := compressor(o.Chunk.Compress)
+	if err != nil {
+		return nil, "", err
+	}
+	if o.Out == "" {
+		if comp == nil {
+			return nopCloser{os.Stdout}, "", nil
+		}
+		zw, err := comp.New(os.Stdout)
+		if err != nil {
+			return nil, "", err
+		}
+		return compressedFile{WriteCloser: zw}, "", nil
+	}
+
+	path, err := OutputPath(o.Out)
+	if err != nil {
+		return nil, "", err
+	}
+	if comp != nil && !strings.HasSuffix(path, comp.Ext) {
+		path += comp.Ext
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create output file: %w", err)
+	}
+	log.Printf("writing to %s", path)
+	if comp == nil {
+		return f, path, nil
+	}
+	zw, err := comp.New(f)
+	if err != nil {
+		f.Close()
+		return nil, "", err
+	}
+	return compressedFile{WriteCloser: zw, file: f}, path, nil
+}
+
+type nopCloser struct{ io.Writer }
+
+func (nopCloser) Close() error { return nil }
+
+// Write applies the paging options and writes the table to the configured
+// output file or stdout, stamped with its provenance. Chunked output is
+// split into numbered files listed in a manifest.
+func (o Options) Write(t *Table) error {
+	if err := o.Apply(t); err != nil {
+		return err
+	}
+	if o.Chunk.Chunked() {
+		if o.Out == "" {
+			return fmt.Errorf("--chunk-rows and --chunk-bytes need --out")
+		}
+		if format := o.format(); format != FormatCSV {
+			return fmt.Errorf("--chunk-rows and --chunk-bytes write CSV; they cannot be combined with --format %s", format)
+		}
+		path, err := OutputPath(o.Out)
+		if err != nil {
+			return err
+		}
+		return o.writeChunks(path, t)
+	}
+
+	w, path, err := o.Create()
+	if err != nil {
+		return err
+	}
+	if err := o.writeTable(w, t); err != nil {
+		w.Close()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	if path != "" && o.Provenance == ProvenanceSidecar {
+		return o.WriteSidecar(path, len(t.Rows))
+	}
+	return nil
+}
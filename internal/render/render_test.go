@@ -0,0 +1,41 @@
+package render
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDecimalCommaConvertsOnlyNumericColumns(t *testing.T) {
+	table := NewTable("key", "fix_version", "points", "updated")
+	table.SetNumeric("points")
+	table.Append("DEMO-1", "2.10", "2.5", "2025.03.01")
+	table.Append("DEMO-2", "1.5", "-0.25", "")
+	table.Append("DEMO-3", "", "3", "")
+
+	var buf bytes.Buffer
+	if err := (CSVFormat{DecimalComma: true}).Write(&buf, table); err != nil {
+		t.Fatal(err)
+	}
+	want := "key;fix_version;points;updated\nDEMO-1;2.10;2,5;2025.03.01\nDEMO-2;1.5;-0,25;\nDEMO-3;;3;\n"
+	if got := buf.String(); got != want {
+		t.Errorf("CSV: got %q, want %q", got, want)
+	}
+
+	buf.Reset()
+	if err := writeTSV(Options{CSV: CSVFormat{DecimalComma: true}}, &buf, table); err != nil {
+		t.Fatal(err)
+	}
+	want = "key\tfix_version\tpoints\tupdated\nDEMO-1\t2.10\t2,5\t2025.03.01\nDEMO-2\t1.5\t-0,25\t\nDEMO-3\t\t3\t\n"
+	if got := buf.String(); got != want {
+		t.Errorf("TSV: got %q, want %q", got, want)
+	}
+
+	buf.Reset()
+	if err := (CSVFormat{Delimiter: ';'}).Write(&buf, table); err != nil {
+		t.Fatal(err)
+	}
+	want = "key;fix_version;points;updated\nDEMO-1;2.10;2.5;2025.03.01\nDEMO-2;1.5;-0.25;\nDEMO-3;;3;\n"
+	if got := buf.String(); got != want {
+		t.Errorf("without --decimal-comma: got %q, want %q", got, want)
+	}
+}
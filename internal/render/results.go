@@ -0,0 +1,101 @@
+package render
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// paging and formatting flags are applied after caching and do not affect
+// the result key.
+var pagingFlags = map[string]bool{
+	"out": true, "limit": true, "offset": true, "sort": true, "cache-results": true,
+	"delimiter": true, "decimal-comma": true, "bom": true, "provenance": true,
+}
+
+// AddCacheFlag registers --cache-results for commands that support it.
+func AddCacheFlag(fs *flag.FlagSet, o *Options) {
+	fs.BoolVar(&o.CacheResults, "cache-results", false, "Reuse the previous result when the cache has not changed since it was computed")
+}
+
+func resultsDir() string {
+	base, err := os.UserCacheDir()
+	if err != nil {
+		base = os.TempDir()
+	}
+	return filepath.Join(base, "rhoai-jira", "results")
+}
+
+// resultKey combines the report name, the parameters it was run with and
+// the version of the data it was computed from.
+func (o Options) resultKey(report string, version string) string {
+	fs := o.flags
+	if fs == nil {
+		fs = flag.CommandLine
+	}
+	var params []string
+	fs.Visit(func(f *flag.Flag) {
+		if !pagingFlags[f.Name] {
+			params = append(params, f.Name+"="+f.Value.String())
+		}
+	})
+	params = append(params, fs.Args()...)
+	sort.Strings(params)
+
+	sum := sha256.Sum256([]byte(report + "\x00" + version + "\x00" + strings.Join(params, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+// LoadCached returns a previously stored result for this report, parameters
+// and data version when --cache-results is set.
+func (o Options) LoadCached(report string, version string) (*Table, bool) {
+	if !o.CacheResults || version == "" {
+		return nil, false
+	}
+	data, err := os.ReadFile(filepath.Join(resultsDir(), o.resultKey(report, version)+".json"))
+	if err != nil {
+		return nil, false
+	}
+	var t Table
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, false
+	}
+	log.Printf("using cached %s result", report)
+	return &t, true
+}
+
+// StoreCached saves a computed result for later LoadCached calls. It must be
+// called before Write, which pages the table in place.
+func (o Options) StoreCached(report string, version string, t *Table) {
+	if !o.CacheResults || version == "" {
+		return
+	}
+	data, err := json.Marshal(t)
+	if err != nil {
+		return
+	}
+	dir := resultsDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.Printf("failed to cache result: %v", err)
+		return
+	}
+	path := filepath.Join(dir, o.resultKey(report, version)+".json")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		log.Printf("failed to cache result: %v", err)
+	}
+}
+
+// ClearCachedResults removes every stored report result.
+func ClearCachedResults() error {
+	if err := os.RemoveAll(resultsDir()); err != nil {
+		return fmt.Errorf("clear results: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,423 @@
+// Package testsuite holds helpers for tests, chiefly a fake Jira serving
+// the search, issue, changelog, comment, worklog and watcher endpoints from canned
+// issues, as Server or as Cloud, with switches to make it answer 429, 403
+// or 500 the way the real one does under load or for restricted issues.
+package testsuite
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TimeFormat is the layout of timestamps in Jira responses.
+const TimeFormat = "2006-01-02T15:04:05.000-0700"
+
+// Issue is a canned issue. Fields are served as they are, with updated
+// kept in step with Updated; Histories make up its changelog.
+type Issue struct {
+	Key       string
+	Updated   time.Time
+	Fields    map[string]any
+	Histories []map[string]any
+	Comments  []map[string]any
+	Worklogs  []map[string]any
+	Watchers  []map[string]any
+}
+
+// NewIssue returns an issue with a summary, a status and an updated time.
+func NewIssue(key, summary, status string, updated time.Time) Issue {
+	return Issue{
+		Key:     key,
+		Updated: updated,
+		Fields: map[string]any{
+			"summary":   summary,
+			"status":    map[string]any{"name": status},
+			"issuetype": map[string]any{"name": "Story"},
+			"created":   updated.Format(TimeFormat),
+		},
+	}
+}
+
+// History returns a changelog entry changing one field.
+func History(id string, created time.Time, field, from, to string) map[string]any {
+	return map[string]any{
+		"id":      id,
+		"created": created.Format(TimeFormat),
+		"author":  map[string]any{"name": "tester"},
+		"items": []any{map[string]any{
+			"field":      field,
+			"fromString": from,
+			"toString":   to,
+		}},
+	}
+}
+
+type fault struct {
+	match  string
+	status int
+	left   int
+}
+
+// Jira is a fake Jira server. It is safe for concurrent use.
+type Jira struct {
+	URL string
+	// ChangelogPageSize caps the histories of one changelog page, and of
+	// the changelog embedded in an issue, as Jira caps them at 100.
+	ChangelogPageSize int
+	// Cloud makes it answer as Jira Cloud: serverInfo says so, API v3 is
+	// served with search paged by nextPageToken, and the search endpoint
+	// of API v2 is gone.
+	Cloud bool
+
+	server   *httptest.Server
+	mu       sync.Mutex
+	issues   map[string]Issue
+	denied   map[string]bool
+	moved    map[string]string
+	faults   []*fault
+	requests []string
+}
+
+// NewJira starts a fake Jira serving issues, closed when the test ends.
+func NewJira(t testing.TB, issues ...Issue) *Jira {
+	t.Helper()
+	j := &Jira{ChangelogPageSize: 100, issues: map[string]Issue{}, denied: map[string]bool{}, moved: map[string]string{}}
+	j.server = httptest.NewServer(http.HandlerFunc(j.serve))
+	j.URL = j.server.URL
+	t.Cleanup(j.server.Close)
+	j.Add(issues...)
+	return j
+}
+
+// Add adds or replaces issues.
+func (j *Jira) Add(issues ...Issue) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, issue := range issues {
+		j.issues[issue.Key] = issue
+	}
+}
+
+// Update changes a field of an issue and moves its updated time.
+func (j *Jira) Update(key, field string, value any, updated time.Time) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	issue := j.issues[key]
+	issue.Fields[field] = value
+	issue.Updated = updated
+	j.issues[key] = issue
+}
+
+// Watch adds watchers to an issue. As in Jira, the issue's updated time
+// does not move; only its watch count does.
+func (j *Jira) Watch(key string, watchers ...map[string]any) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	issue := j.issues[key]
+	issue.Watchers = append(issue.Watchers, watchers...)
+	j.issues[key] = issue
+}
+
+// Deny makes the issue endpoints answer 403 for the issues, which also
+// drop out of search results, as restricted issues do.
+func (j *Jira) Deny(keys ...string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, key := range keys {
+		j.denied[key] = true
+	}
+}
+
+// Delete removes issues, which then answer 404.
+func (j *Jira) Delete(keys ...string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, key := range keys {
+		delete(j.issues, key)
+	}
+}
+
+// Move gives an issue a new key. The old key keeps answering with the
+// issue under its new key, as Jira follows moved issues.
+func (j *Jira) Move(key, newKey string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	issue := j.issues[key]
+	delete(j.issues, key)
+	issue.Key = newKey
+	j.issues[newKey] = issue
+	j.moved[key] = newKey
+}
+
+// Allow lifts Deny, as when permissions are granted.
+func (j *Jira) Allow(keys ...string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, key := range keys {
+		delete(j.denied, key)
+	}
+}
+
+// Fail answers the next times requests whose path and query contain match
+// with status; an empty match fails any request.
+func (j *Jira) Fail(match string, status, times int) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.faults = append(j.faults, &fault{match: match, status: status, left: times})
+}
+
+// RateLimit answers the next times requests with 429.
+func (j *Jira) RateLimit(times int) {
+	j.Fail("", http.StatusTooManyRequests, times)
+}
+
+// Requests returns the path and query of every request served so far.
+func (j *Jira) Requests() []string {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return append([]string(nil), j.requests...)
+}
+
+// Count returns how many requests had a path and query containing match.
+func (j *Jira) Count(match string) int {
+	n := 0
+	for _, r := range j.Requests() {
+		if strings.Contains(r, match) {
+			n++
+		}
+	}
+	return n
+}
+
+func (j *Jira) serve(w http.ResponseWriter, r *http.Request) {
+	target := r.URL.Path
+	if r.URL.RawQuery != "" {
+		target += "?" + r.URL.RawQuery
+	}
+	j.mu.Lock()
+	j.requests = append(j.requests, target)
+	for _, f := range j.faults {
+		if f.left > 0 && strings.Contains(target, f.match) {
+			f.left--
+			j.mu.Unlock()
+			if f.status == http.StatusTooManyRequests {
+				w.Header().Set("Retry-After", "0")
+			}
+			writeError(w, f.status, fmt.Sprintf("injected %d", f.status))
+			return
+		}
+	}
+	j.mu.Unlock()
+
+	w.Header().Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	j.mu.Lock()
+	cloud := j.Cloud
+	j.mu.Unlock()
+	v3 := strings.HasPrefix(r.URL.Path, "/rest/api/3/")
+	path := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/rest/api/2/"), "/rest/api/3/")
+	switch {
+	case v3 && !cloud:
+		writeError(w, http.StatusNotFound, "no such endpoint")
+	case path == "serverInfo":
+		deployment := "Server"
+		if cloud {
+			deployment = "Cloud"
+		}
+		writeJSON(w, map[string]any{"deploymentType": deployment})
+	case path == "search" && cloud:
+		writeError(w, http.StatusGone, "The requested API has been removed. Please migrate to the /rest/api/3/search/jql API.")
+	case path == "search", path == "search/jql" && v3:
+		j.search(w, r)
+	case strings.HasPrefix(path, "issue/"):
+		parts := strings.Split(strings.TrimPrefix(path, "issue/"), "/")
+		key := parts[0]
+		sub := ""
+		if len(parts) > 1 {
+			sub = parts[1]
+		}
+		j.issue(w, r, key, sub)
+	default:
+		writeError(w, http.StatusNotFound, "no such endpoint")
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{"errorMessages": []string{msg}, "errors": map[string]any{}})
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func intParam(r *http.Request, name string, def int) int {
+	if v, err := strconv.Atoi(r.URL.Query().Get(name)); err == nil {
+		return v
+	}
+	return def
+}
+
+func issueNumber(key string) int {
+	_, n, _ := strings.Cut(key, "-")
+	v, _ := strconv.Atoi(n)
+	return v
+}
+
+var (
+	projectClause = regexp.MustCompile(`(?i)project\s*=\s*"?([A-Za-z0-9_]+)"?`)
+	updatedClause = regexp.MustCompile(`(?i)updated\s*>=\s*"([^"]+)"`)
+	orderClause   = regexp.MustCompile(`(?i)order\s+by\s+(\w+)`)
+)
+
+// search understands the project and updated >= clauses of the JQL and
+// orders by updated or, by default, by key, newest first. The search/jql
+// endpoint of API v3 pages by nextPageToken instead of startAt.
+func (j *Jira) search(w http.ResponseWriter, r *http.Request) {
+	jql := r.URL.Query().Get("jql")
+	var since time.Time
+	if m := updatedClause.FindStringSubmatch(jql); m != nil {
+		since, _ = time.Parse("2006-01-02 15:04", m[1])
+	}
+	project := ""
+	if m := projectClause.FindStringSubmatch(jql); m != nil {
+		project = strings.ToUpper(m[1])
+	}
+	byUpdated := false
+	if m := orderClause.FindStringSubmatch(jql); m != nil {
+		byUpdated = strings.EqualFold(m[1], "updated")
+	}
+
+	j.mu.Lock()
+	var matches []Issue
+	for key, issue := range j.issues {
+		if j.denied[key] {
+			continue
+		}
+		if project != "" && !strings.HasPrefix(key, project+"-") {
+			continue
+		}
+		if issue.Updated.Before(since) {
+			continue
+		}
+		matches = append(matches, issue)
+	}
+	j.mu.Unlock()
+	sort.Slice(matches, func(a, b int) bool {
+		if byUpdated && !matches[a].Updated.Equal(matches[b].Updated) {
+			return matches[a].Updated.After(matches[b].Updated)
+		}
+		return issueNumber(matches[a].Key) > issueNumber(matches[b].Key)
+	})
+
+	startAt, maxResults := intParam(r, "startAt", 0), intParam(r, "maxResults", 50)
+	tokens := strings.HasSuffix(r.URL.Path, "/search/jql")
+	if tokens {
+		startAt = intParam(r, "nextPageToken", 0)
+	}
+	page := []any{}
+	for i := startAt; i < len(matches) && i < startAt+maxResults; i++ {
+		page = append(page, map[string]any{
+			"key":    matches[i].Key,
+			"fields": map[string]any{"updated": matches[i].Updated.Format(TimeFormat)},
+		})
+	}
+	if tokens {
+		result := map[string]any{"issues": page, "isLast": startAt+maxResults >= len(matches)}
+		if startAt+maxResults < len(matches) {
+			result["nextPageToken"] = strconv.Itoa(startAt + maxResults)
+		}
+		writeJSON(w, result)
+		return
+	}
+	writeJSON(w, map[string]any{"startAt": startAt, "maxResults": maxResults, "total": len(matches), "issues": page})
+}
+
+func (j *Jira) issue(w http.ResponseWriter, r *http.Request, key, sub string) {
+	j.mu.Lock()
+	if newKey, ok := j.moved[key]; ok {
+		key = newKey
+	}
+	issue, ok := j.issues[key]
+	denied := j.denied[key]
+	pageSize := j.ChangelogPageSize
+	j.mu.Unlock()
+	switch {
+	case denied:
+		writeError(w, http.StatusForbidden, "You do not have the permission to see the specified issue.")
+		return
+	case !ok:
+		writeError(w, http.StatusNotFound, "Issue Does Not Exist")
+		return
+	}
+
+	switch sub {
+	case "":
+		fields := map[string]any{}
+		for k, v := range issue.Fields {
+			fields[k] = v
+		}
+		fields["updated"] = issue.Updated.Format(TimeFormat)
+		fields["watches"] = map[string]any{"watchCount": len(issue.Watchers)}
+		body := map[string]any{"id": strconv.Itoa(issueNumber(key)), "key": key, "fields": fields}
+		if strings.Contains(r.URL.Query().Get("expand"), "changelog") {
+			n := min(len(issue.Histories), pageSize)
+			body["changelog"] = map[string]any{"startAt": 0, "maxResults": n, "total": len(issue.Histories), "histories": histories(issue.Histories[:n])}
+		}
+		writeJSON(w, body)
+	case "changelog":
+		startAt := intParam(r, "startAt", 0)
+		maxResults := min(intParam(r, "maxResults", pageSize), pageSize)
+		end := min(startAt+maxResults, len(issue.Histories))
+		startAt = min(startAt, end)
+		writeJSON(w, map[string]any{
+			"startAt":    startAt,
+			"maxResults": maxResults,
+			"total":      len(issue.Histories),
+			"isLast":     end >= len(issue.Histories),
+			"values":     histories(issue.Histories[startAt:end]),
+		})
+	case "comment":
+		startAt := min(intParam(r, "startAt", 0), len(issue.Comments))
+		end := min(startAt+intParam(r, "maxResults", 50), len(issue.Comments))
+		comments := []any{}
+		for _, c := range issue.Comments[startAt:end] {
+			comments = append(comments, c)
+		}
+		writeJSON(w, map[string]any{"startAt": startAt, "maxResults": end - startAt, "total": len(issue.Comments), "comments": comments})
+	case "worklog":
+		startAt := min(intParam(r, "startAt", 0), len(issue.Worklogs))
+		end := min(startAt+intParam(r, "maxResults", 50), len(issue.Worklogs))
+		worklogs := []any{}
+		for _, wl := range issue.Worklogs[startAt:end] {
+			worklogs = append(worklogs, wl)
+		}
+		writeJSON(w, map[string]any{"startAt": startAt, "maxResults": end - startAt, "total": len(issue.Worklogs), "worklogs": worklogs})
+	case "watchers":
+		watchers := []any{}
+		for _, u := range issue.Watchers {
+			watchers = append(watchers, u)
+		}
+		writeJSON(w, map[string]any{"isWatching": false, "watchCount": len(watchers), "watchers": watchers})
+	default:
+		writeError(w, http.StatusNotFound, "no such endpoint")
+	}
+}
+
+func histories(h []map[string]any) []any {
+	out := make([]any, 0, len(h))
+	for _, entry := range h {
+		out = append(out, entry)
+	}
+	return out
+}
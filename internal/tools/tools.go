@@ -42,3 +42,19 @@ func FilterByIndexValue(listOfLists [][]string, index int, match string) [][]str
 	}
 	return result
 }
+
+// StringList is a repeatable flag.Value that also accepts comma separated values.
+type StringList []string
+
+func (s *StringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *StringList) Set(value string) error {
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			*s = append(*s, part)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,447 @@
+// Package yaml parses the subset of YAML used by rhoai-jira configuration
+// files: block mappings and sequences, flow [lists] and {maps}, quoted and
+// plain scalars, literal (|) and folded (>) block scalars, and comments.
+// Anchors, tags and multi-document streams are not supported.
+package yaml
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type line struct {
+	num    int
+	indent int
+	text   string
+}
+
+type parser struct {
+	lines []line
+	pos   int
+}
+
+// Unmarshal decodes YAML into v the way encoding/json would decode the
+// equivalent JSON document, so struct fields use json tags.
+func Unmarshal(data []byte, v interface{}) error {
+	doc, err := Parse(data)
+	if err != nil {
+		return err
+	}
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, v)
+}
+
+// Parse decodes a YAML document into map[string]interface{},
+// []interface{}, string, bool, int64, float64 or nil values.
+func Parse(data []byte) (interface{}, error) {
+	p := &parser{}
+	for i, raw := range strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n") {
+		if strings.Contains(raw, "\t") && strings.TrimLeft(raw, " ") != strings.TrimLeft(raw, " \t") {
+			return nil, fmt.Errorf("line %d: tabs are not allowed for indentation", i+1)
+		}
+		text := strings.TrimRight(raw, " \t")
+		trimmed := strings.TrimLeft(text, " ")
+		if trimmed == "---" && len(p.lines) == 0 {
+			continue
+		}
+		p.lines = append(p.lines, line{num: i + 1, indent: len(text) - len(trimmed), text: trimmed})
+	}
+	p.skipBlank()
+	if p.pos >= len(p.lines) {
+		return nil, nil
+	}
+	v, err := p.block(p.lines[p.pos].indent)
+	if err != nil {
+		return nil, err
+	}
+	p.skipBlank()
+	if p.pos < len(p.lines) {
+		l := p.lines[p.pos]
+		return nil, fmt.Errorf("line %d: unexpected indentation", l.num)
+	}
+	return v, nil
+}
+
+func isBlank(l line) bool {
+	return l.text == "" || strings.HasPrefix(l.text, "#")
+}
+
+func (p *parser) skipBlank() {
+	for p.pos < len(p.lines) && isBlank(p.lines[p.pos]) {
+		p.pos++
+	}
+}
+
+func isSeqItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// block parses the mapping or sequence starting at the current line.
+func (p *parser) block(indent int) (interface{}, error) {
+	if isSeqItem(p.lines[p.pos].text) {
+		return p.sequence(indent)
+	}
+	return p.mapping(indent)
+}
+
+func (p *parser) sequence(indent int) (interface{}, error) {
+	items := []interface{}{}
+	for {
+		p.skipBlank()
+		if p.pos >= len(p.lines) {
+			break
+		}
+		l := p.lines[p.pos]
+		if l.indent < indent || (l.indent == indent && !isSeqItem(l.text)) {
+			break
+		}
+		if l.indent > indent {
+			return nil, fmt.Errorf("line %d: unexpected indentation", l.num)
+		}
+		rest := strings.TrimLeft(strings.TrimPrefix(l.text, "-"), " ")
+		if rest == "" || strings.HasPrefix(rest, "#") {
+			p.pos++
+			p.skipBlank()
+			if p.pos < len(p.lines) && p.lines[p.pos].indent > indent {
+				v, err := p.block(p.lines[p.pos].indent)
+				if err != nil {
+					return nil, err
+				}
+				items = append(items, v)
+			} else {
+				items = append(items, nil)
+			}
+			continue
+		}
+		if _, _, ok := splitKey(rest); ok || isSeqItem(rest) {
+			// "- key: value" starts a mapping (or "- - x" a sequence)
+			// indented to the column of its first key.
+			p.lines[p.pos] = line{num: l.num, indent: l.indent + len(l.text) - len(rest), text: rest}
+			v, err := p.block(p.lines[p.pos].indent)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, v)
+			continue
+		}
+		p.pos++
+		v, err := p.inline(rest, l.num)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, v)
+	}
+	return items, nil
+}
+
+func (p *parser) mapping(indent int) (interface{}, error) {
+	m := map[string]interface{}{}
+	for {
+		p.skipBlank()
+		if p.pos >= len(p.lines) {
+			break
+		}
+		l := p.lines[p.pos]
+		if l.indent < indent {
+			break
+		}
+		if l.indent > indent {
+			return nil, fmt.Errorf("line %d: unexpected indentation", l.num)
+		}
+		if isSeqItem(l.text) {
+			break
+		}
+		key, rest, ok := splitKey(l.text)
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected \"key: value\"", l.num)
+		}
+		if _, dup := m[key]; dup {
+			return nil, fmt.Errorf("line %d: duplicate key %q", l.num, key)
+		}
+		p.pos++
+
+		switch {
+		case rest == "" || strings.HasPrefix(rest, "#"):
+			p.skipBlank()
+			if p.pos < len(p.lines) {
+				next := p.lines[p.pos]
+				if next.indent > indent || (next.indent == indent && isSeqItem(next.text)) {
+					v, err := p.block(next.indent)
+					if err != nil {
+						return nil, err
+					}
+					m[key] = v
+					continue
+				}
+			}
+			m[key] = nil
+		case strings.HasPrefix(rest, "|") || strings.HasPrefix(rest, ">"):
+			m[key] = p.blockScalar(indent, rest)
+		default:
+			v, err := p.inline(rest, l.num)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = v
+		}
+	}
+	return m, nil
+}
+
+// blockScalar reads the lines of a | or > scalar indented past indent.
+func (p *parser) blockScalar(indent int, header string) string {
+	var lines []string
+	contentIndent := -1
+	for p.pos < len(p.lines) {
+		l := p.lines[p.pos]
+		if l.text != "" && l.indent <= indent {
+			break
+		}
+		if l.text != "" && contentIndent < 0 {
+			contentIndent = l.indent
+		}
+		text := ""
+		if l.text != "" {
+			text = strings.Repeat(" ", l.indent-contentIndent) + l.text
+		}
+		lines = append(lines, text)
+		p.pos++
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	var s string
+	if strings.HasPrefix(header, ">") {
+		var b strings.Builder
+		for i, l := range lines {
+			switch {
+			case i == 0, l != "" && lines[i-1] == "":
+			case l == "":
+				b.WriteString("\n")
+			default:
+				b.WriteString(" ")
+			}
+			b.WriteString(l)
+		}
+		s = b.String()
+	} else {
+		s = strings.Join(lines, "\n")
+	}
+	if !strings.HasSuffix(header, "-") && s != "" {
+		s += "\n"
+	}
+	return s
+}
+
+// splitKey splits "key: rest" outside of quotes and flow collections.
+func splitKey(text string) (string, string, bool) {
+	if text == "" || strings.ContainsRune("[{#", rune(text[0])) {
+		return "", "", false
+	}
+	if text[0] == '"' || text[0] == '\'' {
+		end := closingQuote(text)
+		if end < 0 || end+1 >= len(text) || text[end+1] != ':' {
+			return "", "", false
+		}
+		key, err := unquote(text[:end+1])
+		if err != nil {
+			return "", "", false
+		}
+		rest := text[end+2:]
+		if rest != "" && rest[0] != ' ' {
+			return "", "", false
+		}
+		return key, strings.TrimSpace(rest), true
+	}
+	for i := 0; i < len(text); i++ {
+		if text[i] == ':' && (i+1 == len(text) || text[i+1] == ' ') {
+			return strings.TrimSpace(text[:i]), strings.TrimSpace(text[i+1:]), true
+		}
+		if text[i] == ' ' && i+1 < len(text) && text[i+1] == '#' {
+			break
+		}
+	}
+	return "", "", false
+}
+
+func closingQuote(s string) int {
+	q := s[0]
+	for i := 1; i < len(s); i++ {
+		switch {
+		case q == '"' && s[i] == '\\':
+			i++
+		case q == '\'' && s[i] == '\'' && i+1 < len(s) && s[i+1] == '\'':
+			i++
+		case s[i] == q:
+			return i
+		}
+	}
+	return -1
+}
+
+func unquote(s string) (string, error) {
+	if s[0] == '\'' {
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+	}
+	return strconv.Unquote(s)
+}
+
+// inline parses a value on the rest of a line: a flow collection, a quoted
+// string or a plain scalar, followed by an optional comment.
+func (p *parser) inline(text string, num int) (interface{}, error) {
+	f := &flow{s: text, num: num}
+	v, err := f.value(false)
+	if err != nil {
+		return nil, err
+	}
+	f.space()
+	if f.i < len(f.s) && f.s[f.i] != '#' {
+		return nil, fmt.Errorf("line %d: unexpected %q", num, f.s[f.i:])
+	}
+	return v, nil
+}
+
+type flow struct {
+	s   string
+	i   int
+	num int
+}
+
+func (f *flow) space() {
+	for f.i < len(f.s) && f.s[f.i] == ' ' {
+		f.i++
+	}
+}
+
+func (f *flow) value(nested bool) (interface{}, error) {
+	f.space()
+	if f.i >= len(f.s) {
+		return nil, nil
+	}
+	switch f.s[f.i] {
+	case '[':
+		return f.list()
+	case '{':
+		return f.object()
+	case '"', '\'':
+		end := closingQuote(f.s[f.i:])
+		if end < 0 {
+			return nil, fmt.Errorf("line %d: unterminated string", f.num)
+		}
+		s, err := unquote(f.s[f.i : f.i+end+1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", f.num, err)
+		}
+		f.i += end + 1
+		return s, nil
+	}
+	start := f.i
+	for f.i < len(f.s) {
+		c := f.s[f.i]
+		if nested && (c == ',' || c == ']' || c == '}') {
+			break
+		}
+		if nested && c == ':' && (f.i+1 == len(f.s) || f.s[f.i+1] == ' ') {
+			break
+		}
+		if c == '#' && f.i > start && f.s[f.i-1] == ' ' {
+			break
+		}
+		f.i++
+	}
+	return scalar(strings.TrimSpace(f.s[start:f.i])), nil
+}
+
+func (f *flow) list() (interface{}, error) {
+	f.i++
+	items := []interface{}{}
+	for {
+		f.space()
+		if f.i >= len(f.s) {
+			return nil, fmt.Errorf("line %d: unterminated [", f.num)
+		}
+		if f.s[f.i] == ']' {
+			f.i++
+			return items, nil
+		}
+		v, err := f.value(true)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, v)
+		if err := f.separator(']'); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (f *flow) object() (interface{}, error) {
+	f.i++
+	m := map[string]interface{}{}
+	for {
+		f.space()
+		if f.i >= len(f.s) {
+			return nil, fmt.Errorf("line %d: unterminated {", f.num)
+		}
+		if f.s[f.i] == '}' {
+			f.i++
+			return m, nil
+		}
+		k, err := f.value(true)
+		if err != nil {
+			return nil, err
+		}
+		f.space()
+		if f.i >= len(f.s) || f.s[f.i] != ':' {
+			return nil, fmt.Errorf("line %d: expected : in flow mapping", f.num)
+		}
+		f.i++
+		v, err := f.value(true)
+		if err != nil {
+			return nil, err
+		}
+		m[fmt.Sprint(k)] = v
+		if err := f.separator('}'); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// separator skips the comma after an item of a flow collection. Anything
+// but a comma or the closing bracket there, such as a second colon, is an
+// error: the item would be parsed again without moving on.
+func (f *flow) separator(closing byte) error {
+	f.space()
+	switch {
+	case f.i >= len(f.s) || f.s[f.i] == closing:
+	case f.s[f.i] == ',':
+		f.i++
+	default:
+		return fmt.Errorf("line %d: unexpected %q", f.num, f.s[f.i:])
+	}
+	return nil
+}
+
+// scalar resolves a plain scalar to null, a bool, a number or a string.
+func scalar(s string) interface{} {
+	switch s {
+	case "", "~", "null", "Null", "NULL":
+		return nil
+	case "true", "True", "TRUE":
+		return true
+	case "false", "False", "FALSE":
+		return false
+	}
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return n
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil && !strings.ContainsAny(s, "nNxX") {
+		return f
+	}
+	return s
+}
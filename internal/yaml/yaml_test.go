@@ -0,0 +1,113 @@
+package yaml
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type obj = map[string]interface{}
+type list = []interface{}
+
+func TestParse(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		in   string
+		want interface{}
+	}{
+		{"empty", "", nil},
+		{"comments only", "# nothing\n\n", nil},
+		{"scalars", "s: text\ni: 42\nf: 1.5\nt: true\nn: null\ntilde: ~\nempty:\n", obj{"s": "text", "i": int64(42), "f": 1.5, "t": true, "n": nil, "tilde": nil, "empty": nil}},
+		{"quoted", `a: "x: \"y\""` + "\nb: 'it''s'\nc: \"42\"\n", obj{"a": `x: "y"`, "b": "it's", "c": "42"}},
+		{"hex stays a string", "v: 0x10\n", obj{"v": "0x10"}},
+		{"comment after value", "a: b # note\nc: d#e\n", obj{"a": "b", "c": "d#e"}},
+		{"document marker", "---\na: 1\n", obj{"a": int64(1)}},
+		{"nested mapping", "a:\n  b:\n    c: 1\n  d: 2\n", obj{"a": obj{"b": obj{"c": int64(1)}, "d": int64(2)}}},
+		{"sequence", "- a\n- 2\n-\n", list{"a", int64(2), nil}},
+		{"sequence under key at same indent", "steps:\n- a\n- b\n", obj{"steps": list{"a", "b"}}},
+		{"sequence of mappings", "steps:\n  - name: fetch\n    args: [--dir, x]\n  - name: report\n", obj{"steps": list{
+			obj{"name": "fetch", "args": list{"--dir", "x"}},
+			obj{"name": "report"},
+		}}},
+		{"nested sequence", "- - a\n  - b\n- c\n", list{list{"a", "b"}, "c"}},
+		{"flow list", "a: [1, two, 'three, 3', [x], {k: v}, ]\n", obj{"a": list{int64(1), "two", "three, 3", list{"x"}, obj{"k": "v"}}}},
+		{"flow map", "a: {x: 1, y: [2, 3], z: }\n", obj{"a": obj{"x": int64(1), "y": list{int64(2), int64(3)}, "z": nil}}},
+		{"empty flow", "a: []\nb: {}\n", obj{"a": list{}, "b": obj{}}},
+		{"literal block", "a: |\n  one\n    two\n\n  three\nb: 1\n", obj{"a": "one\n  two\n\nthree\n", "b": int64(1)}},
+		{"folded block", "a: >-\n  one\n  two\n\n  three\n", obj{"a": "one two\nthree"}},
+		{"windows line endings", "a: 1\r\nb: 2\r\n", obj{"a": int64(1), "b": int64(2)}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Parse([]byte(tc.in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %#v, want %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"colon in flow list", "k: [a: b]\n", `line 1: unexpected ": b]"`},
+		{"second colon in flow map", "k: {a: b: c}\n", `line 1: unexpected ": c}"`},
+		{"brace closing a list", "k: [a}\n", `line 1: unexpected "}"`},
+		{"bracket closing a map", "k: {a: b]\n", `line 1: unexpected "]"`},
+		{"unterminated list", "k: [a, b\n", "line 1: unterminated ["},
+		{"unterminated map", "k: {a: b\n", "line 1: unterminated {"},
+		{"flow map without colon", "k: {a}\n", "line 1: expected : in flow mapping"},
+		{"unterminated string", "k: \"abc\n", "line 1: unterminated string"},
+		{"text after flow", "k: [a] b\n", `line 1: unexpected "b"`},
+		{"tab indent", "a:\n\tb: 1\n", "line 2: tabs are not allowed for indentation"},
+		{"duplicate key", "a: 1\na: 2\n", `line 2: duplicate key "a"`},
+		{"not a key", "a: 1\njust text\n", `line 2: expected "key: value"`},
+		{"over-indented", "a: 1\n  b: 2\n", "line 2: unexpected indentation"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Parse([]byte(tc.in))
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("got error %v, want %s", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestUnmarshalUsesJSONTags(t *testing.T) {
+	var cfg struct {
+		Name  string   `json:"name"`
+		Every string   `json:"every"`
+		Steps []string `json:"steps"`
+		Retry int      `json:"retry"`
+	}
+	in := "name: nightly\nevery: 24h\nretry: 2\nsteps:\n  - fetch\n  - report\n"
+	if err := Unmarshal([]byte(in), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Name != "nightly" || cfg.Every != "24h" || cfg.Retry != 2 || !reflect.DeepEqual(cfg.Steps, []string{"fetch", "report"}) {
+		t.Errorf("got %+v", cfg)
+	}
+}
+
+func TestParseExampleConfigs(t *testing.T) {
+	paths, err := filepath.Glob("../../scripts/*.yaml.example")
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("no example configs: %v", err)
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Parse(data); err != nil {
+			t.Errorf("%s: %v", path, err)
+		}
+	}
+}
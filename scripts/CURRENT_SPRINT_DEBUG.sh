@@ -1,3 +1,3 @@
 #!/bin/bash
 
- ~/go/bin/go1.24.3 run cmd/sprint_tracker/main.go -debug -project=RHOAIENG -sprint-filter="Platform 2025: Q2-4"
\ No newline at end of file
+ ~/go/bin/go1.24.3 run ./cmd/rhoai-jira track -debug -project=RHOAIENG -sprint-filter="Platform 2025: Q2-4"
\ No newline at end of file
@@ -1,4 +1,4 @@
 #!/bin/bash
 
-~/go/bin/go1.24.3 run cmd/fetcher/main.go -project=RHOAIENG -sprint="Platform 2025: Q2-4"
+~/go/bin/go1.24.3 run ./cmd/rhoai-jira fetch -project=RHOAIENG -sprint="Platform 2025: Q2-4"
 
@@ -1,4 +1,4 @@
 #!/bin/bash
 
- ~/go/bin/go1.24.3 run cmd/sprint_tracker/main.go -project=RHOAIENG -sprint-filter="Platform 2025: Q2-3" | column -s, -t
+ ~/go/bin/go1.24.3 run ./cmd/rhoai-jira track -project=RHOAIENG -sprint-filter="Platform 2025: Q2-3" | column -s, -t
 